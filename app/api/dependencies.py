@@ -5,33 +5,197 @@ These dependencies resolve tenant databases and create tenant-scoped service ins
 per request.
 """
 
-from typing import Optional
+import uuid
+from contextlib import asynccontextmanager
+from typing import AsyncIterator, Callable, Optional
 from fastapi import Depends, HTTPException, status
 
 from app.db.database import Database
+from app.db.read_sessions import ReadSessionManager
 from app.db.tenant_db_manager import TenantDatabaseManager
 from app.repository import (
     NodeRepository,
     NodeTypeRepository,
     RelationshipRepository,
+    RelationshipTypeRepository,
+    WriteHookRepository,
+    AttachmentRepository,
+    GraphStatsRepository,
+    OperationRepository,
+    DataMigrationRepository,
+    DirectoryRepository,
+    TombstoneRepository,
+    NodeAliasRepository,
+    NodeProtectionRepository,
+    SearchCursorRepository,
+    UniqueConstraintRepository,
+    SavedQueryRepository,
+    NodeViewRepository,
 )
 from app.service import (
     NodeService,
     NodeTypeService,
     RelationshipService,
+    RelationshipTypeService,
+    WriteHookService,
+    AttachmentService,
+    GraphStatsService,
+    OperationService,
+    BulkService,
+    ExpansionService,
+    DataMigrationService,
+    RelationshipImportService,
+    NodeImportService,
+    UniqueConstraintService,
+    DirectoryService,
+    ExportService,
+    NodeAliasService,
+    SubgraphService,
+    GraphViewService,
+    SyncService,
+    BatchCreateService,
+    SavedQueryService,
+    NodeViewService,
+    CentralityService,
+    CommunityService,
+    SearchService,
+    ValidationReportService,
+    OrphanCollectionService,
 )
+from app.service.change_stream import ChangeStreamManager
+from app.service.dual_write_service import DualWriteService
+from app.service.limits import TenantLimits, TenantLimitsCache
+from app.service.maintenance import TenantMaintenanceCache
+from app.service.import_throttle import ImportThrottle
+from app.service.query_cache import QueryCache, QueryCacheService
+from app.service.tenant_key_service import TenantKeyService
+from app.repository.dual_write import (
+    DualWriteNodeRepository,
+    DualWriteNodeTypeRepository,
+    DualWriteRelationshipRepository,
+    DualWriteRelationshipTypeRepository,
+)
+from app.repository.encryption import DataKey
+from app.search import get_search_index
 
 
 # Global tenant database manager (set by main.py)
 _tenant_db_manager: Optional[TenantDatabaseManager] = None
 
 
+# Per-tenant limits (set by main.py; server defaults apply when unset)
+_tenant_limits_cache: Optional[TenantLimitsCache] = None
+
+# Tenants in read-only maintenance (set by main.py; writes are never blocked when unset)
+_tenant_maintenance_cache: Optional[TenantMaintenanceCache] = None
+
+# Per-tenant data encryption keys (set by main.py; documents are stored unencrypted when unset)
+_tenant_key_service: Optional[TenantKeyService] = None
+
+# Snapshot read sessions (replaced by main.py with configured bounds)
+_read_session_manager = ReadSessionManager()
+
+# Change subscriptions (replaced by main.py with the configured poll interval and bounds)
+_change_stream_manager = ChangeStreamManager()
+
+# Query result cache shared by all tenants (replaced by main.py with the configured size)
+_query_cache = QueryCache()
+
+# Import slots and pacing shared by all tenants (replaced by main.py with the configured limits)
+_import_throttle = ImportThrottle()
+
+# Search indexer cursors (set by main.py with a search index; searches with consistency tokens use PostgreSQL when unset)
+_search_cursor_repo: Optional[SearchCursorRepository] = None
+
+# Backend migrations' dual writes (set by main.py; writes go to the tenant database only when unset)
+_dual_write_service: Optional[DualWriteService] = None
+
+# Decodes external IDs in streamed import rows (set by main.py when EXTERNAL_ID_KEY is configured)
+_external_id_decoder: Optional[Callable[[str], Optional[uuid.UUID]]] = None
+
+
 def set_tenant_db_manager(manager: TenantDatabaseManager) -> None:
     """Set the global tenant database manager."""
     global _tenant_db_manager
     _tenant_db_manager = manager
 
 
+def set_tenant_limits_cache(cache: TenantLimitsCache) -> None:
+    """Set the global per-tenant limits cache."""
+    global _tenant_limits_cache
+    _tenant_limits_cache = cache
+
+
+def set_tenant_maintenance_cache(cache: TenantMaintenanceCache) -> None:
+    """Set the global tenant maintenance cache."""
+    global _tenant_maintenance_cache
+    _tenant_maintenance_cache = cache
+
+
+async def check_tenant_writable(tenant_id: str) -> None:
+    """Reject a write to a tenant in read-only maintenance (PreconditionFailedError)."""
+    if _tenant_maintenance_cache:
+        await _tenant_maintenance_cache.check_writable(tenant_id)
+
+
+def set_tenant_key_service(service: TenantKeyService) -> None:
+    """Set the global tenant data key service."""
+    global _tenant_key_service
+    _tenant_key_service = service
+
+
+def set_read_session_manager(manager: ReadSessionManager) -> None:
+    """Set the global snapshot read session manager."""
+    global _read_session_manager
+    _read_session_manager = manager
+
+
+def get_read_session_manager() -> ReadSessionManager:
+    """Get the global snapshot read session manager."""
+    return _read_session_manager
+
+
+def set_change_stream_manager(manager: ChangeStreamManager) -> None:
+    """Set the global change subscription manager."""
+    global _change_stream_manager
+    _change_stream_manager = manager
+
+
+def get_change_stream_manager() -> ChangeStreamManager:
+    """Get the global change subscription manager."""
+    return _change_stream_manager
+
+
+def set_query_cache(cache: QueryCache) -> None:
+    """Set the global query result cache."""
+    global _query_cache
+    _query_cache = cache
+
+
+def set_import_throttle(throttle: ImportThrottle) -> None:
+    """Set the global import throttle."""
+    global _import_throttle
+    _import_throttle = throttle
+
+
+def set_search_cursor_repo(repo: SearchCursorRepository) -> None:
+    """Set the global search indexer cursor repository."""
+    global _search_cursor_repo
+    _search_cursor_repo = repo
+
+
+def set_dual_write_service(service: DualWriteService) -> None:
+    """Set the global dual-write service."""
+    global _dual_write_service
+    _dual_write_service = service
+
+
+def set_external_id_decoder(decode: Callable[[str], Optional[uuid.UUID]]) -> None:
+    """Set the global external ID decoder."""
+    global _external_id_decoder
+    _external_id_decoder = decode
+
+
 async def get_tenant_db(tenant_id: str) -> Database:
     """
     Get tenant database connection for a tenant.
@@ -58,40 +222,148 @@ async def get_tenant_db(tenant_id: str) -> Database:
         )
 
 
-def create_tenant_services(tenant_db: Database):
+def create_tenant_services(
+    tenant_db: Database,
+    tenant_id: str = "",
+    limits: Optional[TenantLimits] = None,
+    data_key: Optional[DataKey] = None,
+    secondary_db: Optional[Database] = None,
+):
     """
     Create tenant-scoped service instances.
     
     Args:
         tenant_db: Tenant database connection
+        tenant_id: Tenant ID, used to namespace object storage keys
+        limits: Tenant's page size, traversal and batch limits (default: server defaults)
+        data_key: Tenant's active data key when its documents are stored encrypted
+        secondary_db: Database the tenant's writes are mirrored to during a backend migration
         
     Returns:
-        Tuple of (NodeTypeService, NodeService, RelationshipService)
+        Dictionary of tenant-scoped services keyed by name
     """
     # Create tenant-scoped repositories
     node_type_repo = NodeTypeRepository(tenant_db)
-    node_repo = NodeRepository(tenant_db)
-    relationship_repo = RelationshipRepository(tenant_db)
+    node_repo = NodeRepository(tenant_db, data_key)
+    relationship_repo = RelationshipRepository(tenant_db, data_key)
+    relationship_type_repo = RelationshipTypeRepository(tenant_db)
+    if secondary_db:
+        node_type_repo = DualWriteNodeTypeRepository(node_type_repo, NodeTypeRepository(secondary_db), tenant_id)
+        node_repo = DualWriteNodeRepository(node_repo, NodeRepository(secondary_db, data_key), tenant_id)
+        relationship_repo = DualWriteRelationshipRepository(
+            relationship_repo, RelationshipRepository(secondary_db, data_key), tenant_id
+        )
+        relationship_type_repo = DualWriteRelationshipTypeRepository(
+            relationship_type_repo, RelationshipTypeRepository(secondary_db), tenant_id
+        )
+    write_hook_repo = WriteHookRepository(tenant_db)
+    attachment_repo = AttachmentRepository(tenant_db)
+    tombstone_repo = TombstoneRepository(tenant_db)
     
     # Create tenant-scoped services
-    node_type_svc = NodeTypeService(node_type_repo)
-    node_svc = NodeService(node_repo, node_type_repo)
-    relationship_svc = RelationshipService(relationship_repo, node_repo)
+    limits = limits or TenantLimits()
+    node_type_svc = NodeTypeService(node_type_repo, limits)
+    write_hook_svc = WriteHookService(write_hook_repo, node_type_repo, limits)
+    attachment_svc = AttachmentService(attachment_repo, node_repo, key_prefix=tenant_id, limits=limits)
+    operation_svc = OperationService(OperationRepository(tenant_db), limits)
+    data_migration_svc = DataMigrationService(
+        DataMigrationRepository(tenant_db), node_type_repo, node_repo, operation_svc
+    )
+    node_svc = NodeService(
+        node_repo, node_type_repo, write_hook_svc, attachment_svc,
+        relationship_repo, relationship_type_repo, limits, data_migration_svc, NodeProtectionRepository(tenant_db),
+    )
+    relationship_svc = RelationshipService(relationship_repo, node_repo, relationship_type_repo, limits)
+    relationship_type_svc = RelationshipTypeService(relationship_type_repo, node_type_repo, limits, relationship_repo)
+    graph_stats_repo = GraphStatsRepository(tenant_db)
+    graph_stats_svc = GraphStatsService(graph_stats_repo)
+    bulk_svc = BulkService(node_repo, node_svc, operation_svc, relationship_repo, limits)
+    expansion_svc = ExpansionService(
+        node_repo, relationship_repo, limits, data_migration_svc, relationship_type_repo
+    )
+    subgraph_svc = SubgraphService(node_repo, relationship_repo, limits, data_migration_svc)
+    node_alias_svc = NodeAliasService(NodeAliasRepository(tenant_db), node_svc)
     
     return {
         "node_type": node_type_svc,
         "node": node_svc,
         "relationship": relationship_svc,
+        "relationship_type": relationship_type_svc,
+        "write_hook": write_hook_svc,
+        "attachment": attachment_svc,
+        "graph_stats": graph_stats_svc,
+        "operation": operation_svc,
+        "bulk": bulk_svc,
+        "expansion": expansion_svc,
+        "data_migration": data_migration_svc,
+        "relationship_import": RelationshipImportService(
+            relationship_repo, node_repo, relationship_type_repo, operation_svc, limits,
+            NodeAliasRepository(tenant_db), _external_id_decoder, _import_throttle, tenant_id,
+        ),
+        "node_import": NodeImportService(
+            node_svc, node_type_repo, operation_svc, limits, _import_throttle, tenant_id
+        ),
+        "unique_constraint": UniqueConstraintService(UniqueConstraintRepository(tenant_db), node_type_repo, node_repo),
+        "directory": DirectoryService(DirectoryRepository(tenant_db), limits),
+        "export": ExportService(node_repo, relationship_repo, tombstone_repo, limits),
+        "sync": SyncService(node_svc, relationship_svc, limits),
+        "node_alias": node_alias_svc,
+        "batch_create": BatchCreateService(node_svc, node_alias_svc, limits),
+        "saved_query": SavedQueryService(SavedQueryRepository(tenant_db), limits),
+        "node_view": NodeViewService(NodeViewRepository(tenant_db), limits),
+        "subgraph": subgraph_svc,
+        "graph_view": GraphViewService(subgraph_svc, node_repo, node_type_repo),
+        "centrality": CentralityService(node_repo, graph_stats_repo, operation_svc, limits),
+        "community": CommunityService(node_repo, graph_stats_repo, operation_svc, limits),
+        "search": SearchService(node_repo, tenant_id, limits, get_search_index(), _search_cursor_repo),
+        "query_cache": QueryCacheService(tombstone_repo, tenant_id, limits, _query_cache),
+        "validation_report": ValidationReportService(
+            node_repo, node_type_repo, relationship_repo, relationship_type_repo, write_hook_svc,
+            operation_svc, data_migration_svc, tenant_id,
+        ),
+        "orphan_gc": OrphanCollectionService(relationship_repo, operation_svc),
     }
 
 
 # Helper function for route handlers
-async def resolve_tenant_services(tenant_id: str) -> dict:
+async def resolve_tenant_services(tenant_id: str, read_session: str = "") -> dict:
     """
     Resolve tenant services for a given tenant_id.
     
-    This is used by route handlers to get tenant-scoped services.
+    This is used by route handlers to get tenant-scoped services. With a
+    read session token, the services read from that session's snapshot.
     """
     tenant_db = await get_tenant_db(tenant_id)
-    return create_tenant_services(tenant_db)
+    secondary_db = None
+    if read_session:
+        tenant_db = _read_session_manager.get(read_session, tenant_id).database()
+    elif _dual_write_service:
+        secondary_db = await _dual_write_service.secondary_database(tenant_id)
+    limits = await _tenant_limits_cache.get(tenant_id) if _tenant_limits_cache else None
+    data_key = await _tenant_key_service.active_key(tenant_id) if _tenant_key_service else None
+    return create_tenant_services(tenant_db, tenant_id, limits, data_key, secondary_db)
+
+
+async def resolve_database_services(tenant_id: str, database: Database) -> dict:
+    """Tenant services for a tenant that read and write another database, such as its dual-write database."""
+    limits = await _tenant_limits_cache.get(tenant_id) if _tenant_limits_cache else None
+    data_key = await _tenant_key_service.active_key(tenant_id) if _tenant_key_service else None
+    return create_tenant_services(database, tenant_id, limits, data_key)
 
+
+@asynccontextmanager
+async def open_backup_services(tenant_id: str, database_name: str) -> AsyncIterator[dict]:
+    """
+    Tenant services for a tenant that read another database of the cluster,
+    such as a restored backup of the tenant's database, instead of its own.
+    The connection is closed on exit.
+    """
+    if not _tenant_db_manager:
+        raise RuntimeError("Tenant database manager not initialized")
+    backup_db = await _tenant_db_manager.connect_database(database_name)
+    try:
+        limits = await _tenant_limits_cache.get(tenant_id) if _tenant_limits_cache else None
+        data_key = await _tenant_key_service.active_key(tenant_id) if _tenant_key_service else None
+        yield create_tenant_services(backup_db, tenant_id, limits, data_key)
+    finally:
+        await backup_db.close()
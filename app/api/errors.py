@@ -3,13 +3,20 @@ Error handling utilities for REST API.
 """
 
 from fastapi import HTTPException
-from app.repository.errors import NotFoundError
+from app.repository.errors import AlreadyExistsError, NotFoundError, PreconditionFailedError
+from app.service.change_stream import TooManyStreamsError
 
 
 def handle_service_error(err: Exception) -> HTTPException:
     """Convert service exception to HTTP exception."""
     if isinstance(err, NotFoundError):
         return HTTPException(status_code=404, detail=str(err))
+    elif isinstance(err, PreconditionFailedError):
+        return HTTPException(status_code=412, detail=str(err))
+    elif isinstance(err, AlreadyExistsError):
+        return HTTPException(status_code=409, detail=str(err))
+    elif isinstance(err, TooManyStreamsError):
+        return HTTPException(status_code=503, detail=str(err))
     elif isinstance(err, ValueError):
         return HTTPException(status_code=400, detail=str(err))
     else:
@@ -2,7 +2,7 @@
 Pydantic models for request/response validation.
 """
 
-from typing import List, Optional
+from typing import Any, Dict, List, Optional
 from pydantic import BaseModel, Field
 
 
@@ -206,6 +206,7 @@ class Node(BaseModel):
     tenant_id: str = Field(..., description="Tenant ID")
     node_type_id: str = Field(..., description="Node type ID")
     data: str = Field(..., description="Node data as JSON string")
+    metadata: Dict[str, Any] = Field(default_factory=dict, description="System/integration metadata")
     created_at: str = Field(..., description="Creation timestamp")
     updated_at: str = Field(..., description="Last update timestamp")
 
@@ -254,6 +255,8 @@ class Relationship(BaseModel):
     data: str = Field(..., description="Relationship data as JSON string")
     created_at: str = Field(..., description="Creation timestamp")
     updated_at: str = Field(..., description="Last update timestamp")
+    valid_from: Optional[str] = Field(default=None, description="Start of validity (null = always valid before)")
+    valid_to: Optional[str] = Field(default=None, description="End of validity (null = valid indefinitely)")
 
 
 class RelationshipResponse(BaseModel):
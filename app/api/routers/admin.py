@@ -0,0 +1,115 @@
+"""
+Admin web console router.
+
+``GET /admin`` serves a self-contained page for browsing tenants, node
+types, nodes, relationships and node history, and for searching tenants
+and nodes. The page holds no data itself; it reads everything through
+``POST /admin/rpc``, which:
+
+- requires a configured administrator (``ADMIN_USER_IDS``; the console is
+  unavailable when none are configured) authenticated by a credential: an
+  admin-scoped API key, a mapped client certificate or a signed request
+  (the ``X-User-ID`` header alone is not enough),
+- only accepts read methods (``get_*`` and ``list_*``), so the console
+  cannot change production data,
+- otherwise dispatches like ``/jsonrpc``, including interceptors such as
+  authorization policies and the request log.
+"""
+
+import json
+import logging
+from pathlib import Path
+from typing import Any, Callable, Dict, Optional
+
+from fastapi import APIRouter, HTTPException, Request, Response, status
+from fastapi.responses import HTMLResponse
+from jsonrpcserver import Error, Result, async_dispatch
+
+from app.jsonrpc.context import RequestContext, reset_request_context, set_request_context
+from app.jsonrpc.interceptors import CallNext, RpcCall, dispatch_methods
+from app.jsonrpc.server import MessageTooLargeError, read_message, verify_request
+from app.repository import PermissionDeniedError
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter(prefix="/admin", tags=["Admin"])
+
+CONSOLE_PAGE = Path(__file__).resolve().parent.parent / "static" / "admin.html"
+READ_METHOD_PREFIXES = ("get_", "list_")
+
+# Whether a subject is an administrator (set by main.py; unset denies everyone)
+_is_admin: Optional[Callable[[str], bool]] = None
+
+
+def configure_admin_console(is_admin: Callable[[str], bool]) -> None:
+    """Set the check deciding which authenticated subjects may use the console."""
+    global _is_admin
+    _is_admin = is_admin
+
+
+def _read_only_error(request: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """Return a JSON-RPC error response for a request the console may not make."""
+    method = request.get("method") if isinstance(request, dict) else None
+    if isinstance(method, str) and method.startswith(READ_METHOD_PREFIXES):
+        return None
+    return {
+        "jsonrpc": "2.0",
+        "error": {"code": -32003, "message": f"the admin console is read-only: {method} is not allowed"},
+        "id": request.get("id") if isinstance(request, dict) else None,
+    }
+
+
+async def _require_admin_credential(call: RpcCall, call_next: CallNext) -> Result:
+    """Run after the credential interceptors: console calls take an administrator's credential."""
+    subject_id = call.context.admin_subject_id()
+    if not subject_id or not _is_admin or not _is_admin(subject_id):
+        return Error(
+            -32003,
+            "the admin console requires an administrator's admin API key, client certificate or signed request",
+        )
+    return await call_next(call)
+
+
+@router.get("", response_class=HTMLResponse, summary="Admin console")
+async def admin_console() -> HTMLResponse:
+    """Serve the admin console page."""
+    return HTMLResponse(CONSOLE_PAGE.read_text(encoding="utf-8"))
+
+
+@router.post("/rpc", summary="Admin console JSON-RPC (read-only)")
+async def admin_rpc(request: Request) -> Response:
+    """Dispatch the console's read-only JSON-RPC requests for administrators."""
+    ctx = RequestContext.from_headers(dict(request.headers), request.client.host if request.client else "")
+    if not _is_admin:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="the admin console is not configured")
+
+    try:
+        body = await read_message(request)
+        await verify_request(ctx, request.url.path, body)
+        payload = json.loads(body)
+    except MessageTooLargeError as e:
+        raise HTTPException(status_code=status.HTTP_413_REQUEST_ENTITY_TOO_LARGE, detail=str(e))
+    except PermissionDeniedError as e:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail=str(e))
+    except (json.JSONDecodeError, UnicodeDecodeError):
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="body must be a JSON-RPC request")
+
+    requests = payload if isinstance(payload, list) else [payload]
+    errors = [e for e in (_read_only_error(r) for r in requests) if e]
+    if errors:
+        body = errors if isinstance(payload, list) else errors[0]
+        return Response(content=json.dumps(body), media_type="application/json")
+
+    ctx.attributes["admin_console"] = True
+    token = set_request_context(ctx)
+    try:
+        response = await async_dispatch(json.dumps(payload), methods=dispatch_methods(_require_admin_credential))
+    except Exception:
+        logger.exception("Error handling admin console request")
+        raise HTTPException(status_code=status.HTTP_500_INTERNAL_SERVER_ERROR, detail="internal error")
+    finally:
+        reset_request_context(token)
+
+    if not response:
+        return Response(status_code=status.HTTP_204_NO_CONTENT)
+    return Response(content=response, media_type="application/json")
@@ -0,0 +1,31 @@
+"""
+Attachment content upload router.
+
+Attachments are reserved through the ``create_attachment_upload`` JSON-RPC
+method (which is subject to authorization policies); this endpoint only
+accepts the streamed content for a pending attachment.
+"""
+
+from fastapi import APIRouter, Request
+
+from app.api.errors import handle_service_error
+from app.api.dependencies import check_tenant_writable, resolve_tenant_services
+
+
+router = APIRouter(prefix="/tenants/{tenant_id}/attachments", tags=["Attachments"])
+
+
+@router.put(
+    "/{attachment_id}/content",
+    summary="Upload attachment content",
+    description="Stream the body of a reserved attachment into object storage.",
+)
+async def upload_attachment_content(tenant_id: str, attachment_id: str, request: Request):
+    """Upload attachment content from the request body stream."""
+    try:
+        await check_tenant_writable(tenant_id)
+        services = await resolve_tenant_services(tenant_id)
+        attachment = await services["attachment"].upload(attachment_id, request.stream())
+        return {"attachment": attachment.to_dict()}
+    except Exception as e:
+        raise handle_service_error(e)
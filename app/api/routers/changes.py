@@ -0,0 +1,137 @@
+"""
+Change stream router.
+
+Subscriptions are reserved through the ``begin_change_subscription``
+JSON-RPC method (which is subject to authorization policies); these
+endpoints stream the changes of a reserved subscription to browser clients,
+as Server-Sent Events or over a WebSocket (see app/service/change_stream.py).
+"""
+
+import json
+import logging
+import time
+from typing import AsyncIterator, Optional
+
+from fastapi import APIRouter, Header, Request, WebSocket, WebSocketDisconnect
+from fastapi.responses import StreamingResponse
+
+from app.api.errors import handle_service_error
+from app.api.dependencies import get_change_stream_manager, resolve_tenant_services
+from app.service.change_stream import ChangeSubscription, watch_changes
+
+logger = logging.getLogger(__name__)
+
+# Idle streams send a heartbeat this often, so proxies keep them open
+HEARTBEAT_SECONDS = 15.0
+
+# WebSocket close codes for a stream that ends with an error
+_WS_CLOSE_NOT_FOUND = 4404
+_WS_CLOSE_INVALID = 4400
+_WS_CLOSE_OVERLOADED = 1013
+
+
+router = APIRouter(prefix="/tenants/{tenant_id}/changes", tags=["Changes"])
+
+
+async def _batches(tenant_id: str, subscription: ChangeSubscription, sync_cursor: str) -> AsyncIterator[Optional[dict]]:
+    """Yield change batches as dicts, and None when a heartbeat is due."""
+    services = await resolve_tenant_services(tenant_id)
+    heartbeat_at = time.monotonic() + HEARTBEAT_SECONDS
+    async for batch in watch_changes(
+        services["export"],
+        sync_cursor or subscription.sync_cursor,
+        subscription.filter,
+        subscription.include_relationships,
+        subscription.transform,
+        get_change_stream_manager().poll_seconds,
+    ):
+        if not batch.empty:
+            heartbeat_at = time.monotonic() + HEARTBEAT_SECONDS
+            yield batch.to_dict()
+        elif time.monotonic() >= heartbeat_at:
+            heartbeat_at = time.monotonic() + HEARTBEAT_SECONDS
+            yield None
+
+
+@router.get(
+    "/{token}/events",
+    summary="Stream changes as Server-Sent Events",
+    description=(
+        "Stream the changes of a subscription reserved with begin_change_subscription as "
+        "text/event-stream. Each 'changes' event carries an export_tenant_changes-shaped batch; "
+        "its event ID is the sync cursor to resume from, which EventSource sends back as "
+        "Last-Event-ID when it reconnects. A failing stream ends with an 'error' event."
+    ),
+)
+async def stream_change_events(
+    tenant_id: str,
+    token: str,
+    request: Request,
+    last_event_id: str = Header("", alias="Last-Event-ID"),
+):
+    """Stream subscription changes as Server-Sent Events."""
+    manager = get_change_stream_manager()
+    try:
+        # Fail before the response starts, so the client sees the status code
+        manager.get(token, tenant_id)
+        manager.check_capacity()
+    except Exception as e:
+        raise handle_service_error(e)
+
+    async def events() -> AsyncIterator[str]:
+        try:
+            async with manager.stream(token, tenant_id) as subscription:
+                async for batch in _batches(tenant_id, subscription, last_event_id):
+                    if await request.is_disconnected():
+                        return
+                    if batch is None:
+                        yield ": heartbeat\n\n"
+                    else:
+                        yield f"id: {batch['sync_cursor']}\nevent: changes\ndata: {json.dumps(batch)}\n\n"
+        except Exception as e:
+            error = handle_service_error(e)
+            yield f"event: error\ndata: {json.dumps({'status': error.status_code, 'detail': error.detail})}\n\n"
+
+    return StreamingResponse(
+        events(),
+        media_type="text/event-stream",
+        headers={"Cache-Control": "no-cache", "X-Accel-Buffering": "no"},
+    )
+
+
+@router.websocket("/{token}/ws")
+async def stream_change_messages(websocket: WebSocket, tenant_id: str, token: str, sync_cursor: str = ""):
+    """
+    Stream subscription changes over a WebSocket.
+
+    Each message is a JSON object {"type": "changes", ...batch} or
+    {"type": "heartbeat"}. Pass the sync_cursor of the last applied batch as
+    a query parameter to resume after a reconnect.
+    """
+    manager = get_change_stream_manager()
+    try:
+        async with manager.stream(token, tenant_id) as subscription:
+            await websocket.accept()
+            async for batch in _batches(tenant_id, subscription, sync_cursor):
+                if batch is None:
+                    await websocket.send_json({"type": "heartbeat"})
+                else:
+                    await websocket.send_json({"type": "changes", **batch})
+    except WebSocketDisconnect:
+        return
+    except Exception as e:
+        error = handle_service_error(e)
+        if error.status_code == 404:
+            code = _WS_CLOSE_NOT_FOUND
+        elif error.status_code == 503:
+            code = _WS_CLOSE_OVERLOADED
+        elif error.status_code < 500:
+            code = _WS_CLOSE_INVALID
+        else:
+            logger.warning(f"Change stream for tenant {tenant_id} failed: {e}")
+            code = 1011
+        try:
+            # Close reasons are limited to 123 bytes
+            await websocket.close(code=code, reason=str(error.detail)[:120])
+        except RuntimeError:
+            pass  # the connection is already closed
@@ -0,0 +1,100 @@
+"""
+API contract documents router.
+
+Client generators pull the contracts of a running server from here instead
+of from the repository. Each contract is versioned with the server's API
+version and served with a content digest as its ETag, so generators can
+pin a version and skip downloads when nothing changed.
+
+Contracts:
+
+- ``openrpc``: the JSON-RPC API v1 (same document as /openrpc.json)
+- ``openrpc-v2``: the JSON-RPC API v2 (same document as /v2/openrpc.json)
+- ``openapi``: the plain HTTP endpoints, such as attachment uploads
+
+This server has no gRPC surface, so there are no protobuf descriptors to serve.
+"""
+
+import hashlib
+import json
+from typing import Any, Callable, Dict, Tuple
+
+from fastapi import APIRouter, HTTPException, Request, Response
+from fastapi.openapi.utils import get_openapi
+
+from app.jsonrpc.openrpc import SERVICE_NAME, SERVICE_VERSION, generate_openrpc_spec
+from app.jsonrpc.v2 import v2_spec
+
+
+router = APIRouter(prefix="/contracts", tags=["Contracts"])
+
+# Generated documents per contract name; they only change with the code, so once per process
+_documents: Dict[str, Tuple[bytes, str]] = {}
+
+
+def _openapi(request: Request) -> Dict[str, Any]:
+    return get_openapi(
+        title=SERVICE_NAME,
+        version=SERVICE_VERSION,
+        description="Plain HTTP endpoints of flex-db. Most of the API is JSON-RPC; see the openrpc contract.",
+        routes=request.app.routes,
+    )
+
+
+_GENERATORS: Dict[str, Callable[[Request], Dict[str, Any]]] = {
+    "openrpc": lambda request: generate_openrpc_spec(),
+    "openrpc-v2": lambda request: v2_spec(generate_openrpc_spec()),
+    "openapi": _openapi,
+}
+
+
+def _document(name: str, request: Request) -> Tuple[bytes, str]:
+    """Return a contract's JSON body and its digest."""
+    if name not in _GENERATORS:
+        raise HTTPException(status_code=404, detail=f"unknown contract: {name}")
+    if name not in _documents:
+        body = json.dumps(_GENERATORS[name](request), indent=2, sort_keys=True).encode()
+        _documents[name] = (body, "sha256:" + hashlib.sha256(body).hexdigest())
+    return _documents[name]
+
+
+def _respond(name: str, request: Request) -> Response:
+    body, digest = _document(name, request)
+    etag = f'"{digest}"'
+    headers = {"ETag": etag, "X-Contract-Version": SERVICE_VERSION, "Cache-Control": "no-cache"}
+    if etag in [t.strip() for t in request.headers.get("if-none-match", "").split(",")]:
+        return Response(status_code=304, headers=headers)
+    return Response(content=body, media_type="application/json", headers=headers)
+
+
+@router.get("", summary="List API contracts", description="Names, versions, digests and URLs of the served contracts.")
+async def list_contracts(request: Request):
+    """List the available contract documents."""
+    contracts = []
+    for name in _GENERATORS:
+        _, digest = _document(name, request)
+        contracts.append({
+            "name": name,
+            "version": SERVICE_VERSION,
+            "digest": digest,
+            "url": f"/contracts/{name}/{SERVICE_VERSION}.json",
+            "latest_url": f"/contracts/{name}.json",
+        })
+    return {"contracts": contracts}
+
+
+@router.get("/{name}.json", summary="Get the current version of a contract")
+async def get_latest_contract(name: str, request: Request):
+    """Return the contract of the running server's API version."""
+    return _respond(name, request)
+
+
+@router.get("/{name}/{version}.json", summary="Get a specific version of a contract")
+async def get_contract(name: str, version: str, request: Request):
+    """Return a contract if the running server serves that version (404 otherwise)."""
+    if version != SERVICE_VERSION:
+        raise HTTPException(
+            status_code=404,
+            detail=f"contract version {version} is not served here (this server serves {SERVICE_VERSION})",
+        )
+    return _respond(name, request)
@@ -0,0 +1,54 @@
+"""
+Bulk import upload router.
+
+Imports are reserved through JSON-RPC methods (which are subject to
+authorization policies); these endpoints only accept the streamed rows of
+a reserved import.
+"""
+
+from fastapi import APIRouter, Request
+
+from app.api.errors import handle_service_error
+from app.api.dependencies import check_tenant_writable, resolve_tenant_services
+
+
+router = APIRouter(prefix="/tenants/{tenant_id}/imports", tags=["Imports"])
+
+
+@router.post(
+    "/{import_id}/relationships",
+    summary="Upload relationship rows",
+    description=(
+        "Stream the newline-delimited JSON or CSV relationship rows of an import reserved with "
+        "begin_relationship_import. Returns the import operation and the rows that failed, "
+        "with the endpoint references that did not resolve."
+    ),
+)
+async def upload_relationship_rows(tenant_id: str, import_id: str, request: Request):
+    """Import relationship rows from the request body stream."""
+    try:
+        await check_tenant_writable(tenant_id)
+        services = await resolve_tenant_services(tenant_id)
+        op, failures = await services["relationship_import"].ingest(import_id, request.stream())
+        return {"operation": op.to_dict(), "failed_rows": [f.to_dict() for f in failures]}
+    except Exception as e:
+        raise handle_service_error(e)
+
+
+@router.post(
+    "/{import_id}/nodes",
+    summary="Upload node CSV",
+    description=(
+        "Stream the CSV (header first) of a node import reserved with begin_node_import. "
+        "Returns the import operation and the rows that failed."
+    ),
+)
+async def upload_node_rows(tenant_id: str, import_id: str, request: Request):
+    """Import CSV node rows from the request body stream."""
+    try:
+        await check_tenant_writable(tenant_id)
+        services = await resolve_tenant_services(tenant_id)
+        op, failures = await services["node_import"].ingest(import_id, request.stream())
+        return {"operation": op.to_dict(), "failed_rows": [f.to_dict() for f in failures]}
+    except Exception as e:
+        raise handle_service_error(e)
@@ -95,6 +95,7 @@ async def update_node(tenant_id: str, node_id: str, node: NodeUpdate):
     responses={
         204: {"description": "Node deleted successfully"},
         404: {"description": "Node or tenant not found", "model": ErrorResponse},
+        412: {"description": "Node is protected from deletion (see delete_node)", "model": ErrorResponse},
         500: {"description": "Internal server error", "model": ErrorResponse},
     },
 )
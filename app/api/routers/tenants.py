@@ -106,6 +106,7 @@ async def update_tenant(tenant_id: str, tenant: TenantUpdate):
     responses={
         204: {"description": "Tenant deleted successfully"},
         404: {"description": "Tenant not found", "model": ErrorResponse},
+        412: {"description": "Tenant is protected from deletion (see delete_tenant)", "model": ErrorResponse},
         500: {"description": "Internal server error", "model": ErrorResponse},
     },
 )
@@ -0,0 +1,32 @@
+"""
+Authorization module: CEL policies evaluated per JSON-RPC call.
+"""
+
+from app.authz.engine import (
+    PermissionDeniedError,
+    Policy,
+    PolicyEngine,
+    authz_interceptor,
+)
+from app.authz.api_keys import API_KEY_HEADER, api_key_interceptor
+from app.authz.client_certs import CLIENT_CERT_HEADER, CertificateMapper, client_cert_interceptor
+from app.authz.impersonation import IMPERSONATION_HEADER, impersonation_interceptor
+from app.authz.principals import principal_interceptor
+from app.authz.request_signing import RequestSignatureVerifier, request_signature_interceptor
+
+__all__ = [
+    "PermissionDeniedError",
+    "Policy",
+    "PolicyEngine",
+    "authz_interceptor",
+    "API_KEY_HEADER",
+    "api_key_interceptor",
+    "CLIENT_CERT_HEADER",
+    "CertificateMapper",
+    "client_cert_interceptor",
+    "IMPERSONATION_HEADER",
+    "impersonation_interceptor",
+    "principal_interceptor",
+    "RequestSignatureVerifier",
+    "request_signature_interceptor",
+]
@@ -0,0 +1,167 @@
+"""
+Scoped API keys of callers.
+
+A call carrying ``Authorization: Bearer fdb_...`` runs as the key's
+subject (see app/service/api_key_service.py), may only target the key's
+tenants (any tenant when it lists none), and may only call the methods of
+the key's scope:
+
+- ``read``: methods that read (get_*, list_*, search_*, lookup_*, ...),
+  exports and saved queries
+- ``export``: exports, export schedules and background operations
+- ``write``: every method except the administration methods below
+- ``admin``: every method
+
+Keys are checked before impersonation and authorization, so policies see
+the key's subject, and the administrator checks of admin methods still
+apply to it. A key limited to tenants may not call methods that target no
+tenant. Bearer tokens without the key prefix are left to other
+authentication layers; with require_authentication, calls that carry no
+credential at all (only an X-User-ID header) are rejected.
+"""
+
+import fnmatch
+import time
+from datetime import datetime, timezone
+from typing import Dict, Tuple
+
+from jsonrpcserver import Error, Result
+
+from app.authz.engine import ALWAYS_ALLOWED, is_read_method
+from app.authz.impersonation import IMPERSONATION_HEADER, call_tenant_id
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+from app.repository import ApiKey, PermissionDeniedError
+from app.service.api_key_service import TOKEN_PREFIX, ApiKeyService
+
+# Header carrying an API key as a bearer token
+API_KEY_HEADER = "authorization"
+
+# How long a resolved key is reused (a revoked key is rejected at most this late)
+DEFAULT_CACHE_SECONDS = 10.0
+MAX_CACHE_ENTRIES = 10000
+
+# Methods that only admin-scoped keys can call
+ADMIN_METHODS = (
+    "*_impersonation",
+    "*_api_key",
+    "provision_tenant",
+    "list_api_keys",
+    "*_authz_polic*",
+    "list_audit_events",
+    "admin_search_nodes",
+    "set_tenant_debug",
+    "compare_tenants",
+    "*_dual_write",
+    "*_shard*",
+    "rotate_tenant_key",
+    "list_tenant_keys",
+    "set_tenant_maintenance",
+    "get_system_stats",
+    "get_tenant_stats",
+    "get_tenant_bloat_report",
+    "run_tenant_maintenance",
+    "explain_query",
+    "list_billing_events",
+    "get_billing_rollup",
+)
+EXPORT_METHODS = (
+    "export_*",
+    "get_operation",
+    "list_export_*",
+    "get_export_schedule",
+    "run_export_schedule",
+    "begin_read_session",
+    "end_read_session",
+    "begin_change_subscription",
+    "end_change_subscription",
+)
+
+
+def _matches(method_name: str, patterns: Tuple[str, ...]) -> bool:
+    return any(fnmatch.fnmatchcase(method_name, p) for p in patterns)
+
+
+def scope_allows(scope: str, method_name: str) -> bool:
+    """Whether a key of the scope may call a method."""
+    if method_name in ALWAYS_ALLOWED or scope == "admin":
+        return True
+    if _matches(method_name, ADMIN_METHODS):
+        return False
+    if scope == "write":
+        return True
+    if scope == "read":
+        return is_read_method(method_name)
+    if scope == "export":
+        return _matches(method_name, EXPORT_METHODS)
+    return False
+
+
+def bearer_token(value: str) -> str:
+    """The token of an ``Authorization: Bearer`` header value ("" for other schemes)."""
+    scheme, _, token = value.strip().partition(" ")
+    return token.strip() if scheme.lower() == "bearer" else ""
+
+
+def _authenticated(call: RpcCall) -> bool:
+    """Whether a credential other than an API key came with the call (impersonation tokens are checked later)."""
+    attributes = call.context.attributes
+    return (
+        "client_certificate" in attributes
+        or "request_signature" in attributes
+        or bool(call.context.headers.get(IMPERSONATION_HEADER))
+    )
+
+
+def api_key_interceptor(
+    service: ApiKeyService,
+    cache_seconds: float = DEFAULT_CACHE_SECONDS,
+    require_authentication: bool = False,
+) -> Interceptor:
+    """Create an interceptor that runs calls as their API key's subject, within its scope.
+
+    With require_authentication, calls without an API key, client
+    certificate, request signature or impersonation token are rejected.
+    Register it after the client certificate interceptor and before the
+    impersonation interceptor.
+    """
+    cache: Dict[str, Tuple[float, ApiKey]] = {}
+
+    async def resolve(token: str) -> ApiKey:
+        now = time.monotonic()
+        cached = cache.get(token)
+        if cached and now - cached[0] < cache_seconds:
+            # Expiry is checked on every call; revocation once per cache period
+            expires_at = cached[1].expires_at
+            if expires_at is None or expires_at > datetime.now(timezone.utc):
+                return cached[1]
+        key = await service.resolve(token)
+        if len(cache) >= MAX_CACHE_ENTRIES:
+            cache.clear()
+        cache[token] = (now, key)
+        return key
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        token = bearer_token(call.context.headers.get(API_KEY_HEADER, ""))
+        if not token.startswith(TOKEN_PREFIX):
+            if require_authentication and not _authenticated(call) and call.method not in ALWAYS_ALLOWED:
+                return Error(-32003, f"{call.method} requires an api key, client certificate or signed request")
+            return await call_next(call)
+
+        try:
+            key = await resolve(token)
+        except PermissionDeniedError as e:
+            return Error(-32003, str(e))
+
+        tenant_id = call_tenant_id(call)
+        if key.tenant_ids and tenant_id not in key.tenant_ids and call.method not in ALWAYS_ALLOWED:
+            if not tenant_id:
+                return Error(-32003, f"api key {key.name} is limited to tenants and may not call {call.method}")
+            return Error(-32003, f"api key {key.name} may not access tenant {tenant_id}")
+        if not scope_allows(key.scope, call.method):
+            return Error(-32003, f"api key {key.name} has scope {key.scope} and may not call {call.method}")
+
+        call.context.subject_id = key.subject_id
+        call.context.attributes["api_key"] = {"id": key.id, "name": key.name, "scope": key.scope}
+        return await call_next(call)
+
+    return interceptor
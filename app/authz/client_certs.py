@@ -0,0 +1,214 @@
+"""
+Client certificate identities of callers.
+
+In a service mesh the sidecar terminates mTLS and forwards the verified
+client certificate in the ``X-Forwarded-Client-Cert`` header (Envoy's
+format: ``Hash=...;Subject="CN=billing,O=acme";URI=spiffe://acme/ns/billing/sa/api``).
+A mapping file (``CLIENT_CERT_MAPPING_FILE``) turns those identities into
+callers, for deployments that cannot issue their own tokens::
+
+    {"mappings": [
+        {"spiffe_id": "spiffe://acme/ns/billing/*", "subject_id": "svc-billing",
+         "tenant_ids": ["TENANT_ID"], "role": "admin"},
+        {"subject_cn": "reporting", "role": "viewer", "priority": "batch"}
+    ]}
+
+The first mapping whose ``spiffe_id`` or ``subject_cn`` glob matches wins.
+The call then runs as ``subject_id`` (the certificate identity when unset),
+may only target the listed tenants (any tenant when empty; when tenants
+are listed, methods that target no tenant are denied), has ``role`` as
+``subject.tenant_role`` in authorization policies, and runs at
+``priority`` (see app/jsonrpc/priority.py). Certificates
+that match no mapping are denied; calls without a certificate keep using
+``X-User-ID``. The header must only be trusted when the mesh strips it from
+inbound traffic, so it is ignored unless a mapping file is configured.
+"""
+
+import fnmatch
+import json
+import logging
+import os
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional
+
+from jsonrpcserver import Error, Result
+
+from app.authz.engine import ALWAYS_ALLOWED
+from app.db.priority import PRIORITIES
+from app.authz.impersonation import call_tenant_id
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+
+logger = logging.getLogger(__name__)
+
+# Header the mesh sidecar forwards the verified client certificate in
+CLIENT_CERT_HEADER = "x-forwarded-client-cert"
+
+
+@dataclass
+class CertificateMapping:
+    """Maps certificate identities matching a glob to a caller."""
+    spiffe_id: str = ""
+    subject_cn: str = ""
+    subject_id: str = ""
+    tenant_ids: List[str] = field(default_factory=list)
+    role: str = ""
+    # "interactive" or "batch"; empty is interactive
+    priority: str = ""
+
+    def matches(self, identity: Dict[str, str]) -> bool:
+        if self.spiffe_id and identity.get("spiffe_id"):
+            return fnmatch.fnmatchcase(identity["spiffe_id"], self.spiffe_id)
+        if self.subject_cn and identity.get("subject_cn"):
+            return fnmatch.fnmatchcase(identity["subject_cn"], self.subject_cn)
+        return False
+
+
+def _split_quoted(value: str, separator: str) -> List[str]:
+    """Split on a separator outside double quotes."""
+    parts, current, quoted = [], [], False
+    for ch in value:
+        if ch == '"':
+            quoted = not quoted
+        elif ch == separator and not quoted:
+            parts.append("".join(current))
+            current = []
+            continue
+        current.append(ch)
+    parts.append("".join(current))
+    return parts
+
+
+def parse_client_cert_header(value: str) -> Dict[str, str]:
+    """
+    Extract the SPIFFE ID and subject CN of the client certificate from an
+    ``X-Forwarded-Client-Cert`` value.
+
+    When the header lists several certificates (one per proxy hop), the last
+    one is the certificate presented to the nearest proxy. Returns an empty
+    dict when the header carries neither identity.
+    """
+    if not value:
+        return {}
+    element = _split_quoted(value, ",")[-1]
+    identity: Dict[str, str] = {}
+    for pair in _split_quoted(element, ";"):
+        key, _, raw = pair.partition("=")
+        raw = raw.strip().strip('"')
+        key = key.strip().lower()
+        if key == "uri" and raw.startswith("spiffe://"):
+            identity["spiffe_id"] = raw
+        elif key == "subject":
+            for rdn in _split_quoted(raw, ","):
+                name, _, cn = rdn.partition("=")
+                if name.strip().upper() == "CN" and cn:
+                    identity["subject_cn"] = cn.strip()
+    return identity
+
+
+def load_mapping_file(path: str) -> List[CertificateMapping]:
+    """
+    Load certificate mappings from a JSON file of the form
+    ``{"mappings": [{"spiffe_id" | "subject_cn", "subject_id", "tenant_ids", "role", "priority"}]}``.
+
+    Raises:
+        ValueError: If the file is malformed
+    """
+    with open(path) as f:
+        try:
+            doc = json.load(f)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"invalid JSON: {e}") from e
+
+    entries = doc.get("mappings", []) if isinstance(doc, dict) else doc
+    if not isinstance(entries, list):
+        raise ValueError("mappings must be a list")
+
+    mappings = []
+    for i, entry in enumerate(entries):
+        if not isinstance(entry, dict):
+            raise ValueError(f"mappings[{i}] must be an object")
+        mapping = CertificateMapping(
+            spiffe_id=str(entry.get("spiffe_id", "")),
+            subject_cn=str(entry.get("subject_cn", "")),
+            subject_id=str(entry.get("subject_id", "")),
+            tenant_ids=[str(t) for t in entry.get("tenant_ids", [])],
+            role=str(entry.get("role", "")),
+            priority=str(entry.get("priority", "")),
+        )
+        if not mapping.spiffe_id and not mapping.subject_cn:
+            raise ValueError(f"mappings[{i}] needs spiffe_id or subject_cn")
+        if mapping.priority and mapping.priority not in PRIORITIES:
+            raise ValueError(f"mappings[{i}].priority must be one of: {', '.join(PRIORITIES)}")
+        mappings.append(mapping)
+    return mappings
+
+
+class CertificateMapper:
+    """Matches certificate identities against a mapping file, reloaded on change."""
+
+    def __init__(self, mapping_file: str):
+        self.mapping_file = mapping_file
+        self._mappings: List[CertificateMapping] = []
+        self._mtime: Optional[float] = None
+
+    def match(self, identity: Dict[str, str]) -> Optional[CertificateMapping]:
+        """Return the first mapping matching an identity, or None."""
+        self._reload()
+        for mapping in self._mappings:
+            if mapping.matches(identity):
+                return mapping
+        return None
+
+    def _reload(self) -> None:
+        try:
+            mtime = os.path.getmtime(self.mapping_file)
+        except OSError:
+            if self._mappings:
+                logger.warning(f"Client certificate mapping file {self.mapping_file} disappeared; keeping last mappings")
+            return
+        if mtime == self._mtime:
+            return
+
+        try:
+            self._mappings = load_mapping_file(self.mapping_file)
+            self._mtime = mtime
+            logger.info(f"Loaded {len(self._mappings)} client certificate mappings from {self.mapping_file}")
+        except (OSError, ValueError) as e:
+            # Keep the previous mappings rather than failing open or closed
+            logger.error(f"Failed to load client certificate mapping file {self.mapping_file}: {e}")
+
+
+def client_cert_interceptor(mapper: CertificateMapper) -> Interceptor:
+    """Create an interceptor that runs calls as their client certificate's mapped caller.
+
+    Register it before the impersonation interceptor.
+    """
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        identity = parse_client_cert_header(call.context.headers.get(CLIENT_CERT_HEADER, ""))
+        if not identity:
+            return await call_next(call)
+
+        described = identity.get("spiffe_id") or f"CN={identity.get('subject_cn', '')}"
+        mapping = mapper.match(identity)
+        if mapping is None:
+            return Error(-32003, f"client certificate {described} is not mapped to a caller")
+
+        tenant_id = call_tenant_id(call)
+        if mapping.tenant_ids and tenant_id not in mapping.tenant_ids and call.method not in ALWAYS_ALLOWED:
+            if not tenant_id:
+                return Error(
+                    -32003, f"client certificate {described} is limited to tenants and may not call {call.method}"
+                )
+            return Error(-32003, f"client certificate {described} may not access tenant {tenant_id}")
+
+        call.context.subject_id = mapping.subject_id or identity.get("spiffe_id") or identity["subject_cn"]
+        call.context.attributes["client_certificate"] = {
+            "identity": described,
+            "tenant_ids": list(mapping.tenant_ids),
+            "role": mapping.role,
+            "priority": mapping.priority,
+        }
+        return await call_next(call)
+
+    return interceptor
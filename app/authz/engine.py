@@ -0,0 +1,289 @@
+"""
+CEL policy engine.
+
+Policies come from two sources, merged at evaluation time:
+
+- a JSON policy file (``AUTHZ_POLICY_FILE``), reloaded whenever its mtime changes
+- the ``authz_policies`` table in the control database, reloaded every
+  ``AUTHZ_REFRESH_SECONDS`` or immediately after an admin changes a policy
+
+Each policy is a CEL expression that sees these variables:
+
+- ``subject``: ``{"id", "tenant_role", "principal", "groups"}`` (role is empty for
+  non-members, the nearest ancestor tenant's role for members of a parent organization, or the mapped role of a client certificate; principal and groups describe the caller's directory user in the
+  target tenant and are empty if there is none)
+- ``tenant``: ``{"id"}`` (empty for control-level methods)
+- ``entity``: ``{"type", "id"}`` derived from the method name and params
+- ``operation``: the method verb, e.g. ``"create"`` or ``"list"``
+- ``method``: the full JSON-RPC method name
+- ``params``: the call parameters
+
+A matching ``deny`` policy always wins. Otherwise a matching ``allow``
+permits the call. When nothing matches, the default decision applies:
+deny if any policies are loaded, allow if none are (so an empty policy set
+keeps the server open).
+"""
+
+import asyncio
+import fnmatch
+import json
+import logging
+import os
+import time
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional
+
+from jsonrpcserver import Error, Result
+
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+from app.metrics import metrics
+from app.repository import AuthzPolicyRepository, PermissionDeniedError, UserRepository
+from app.scripting import ExpressionError, compile_expression, evaluate
+from app.service.authz_policy_service import validate_policy
+
+logger = logging.getLogger(__name__)
+
+DECISIONS = ("", "allow", "deny")
+
+# Methods that are always allowed (discovery must work to read the policy errors)
+ALWAYS_ALLOWED = ("rpc_discover",)
+# Verbs of methods that only read, and read methods with other verbs
+READ_OPERATIONS = ("get", "list", "search", "lookup", "check", "discover", "preview", "export")
+READ_METHODS = (
+    "begin_read_session",
+    "end_read_session",
+    "run_saved_query",
+    "begin_change_subscription",
+    "end_change_subscription",
+)
+
+
+@dataclass
+class Policy:
+    """A loaded policy, from the policy file or the policy table."""
+    name: str
+    effect: str
+    expression: str
+    methods: List[str] = field(default_factory=list)
+    source: str = "table"
+
+    def applies_to(self, method_name: str) -> bool:
+        return not self.methods or any(fnmatch.fnmatchcase(method_name, p) for p in self.methods)
+
+
+def describe_call(method_name: str) -> Dict[str, str]:
+    """Split a method name like ``create_node_type`` into operation and entity type."""
+    operation, _, entity_type = method_name.partition("_")
+    return {"operation": operation, "entity_type": entity_type}
+
+
+def is_read_method(method_name: str) -> bool:
+    """Whether a method only reads (for read-scoped API keys and tenants in maintenance)."""
+    return (
+        method_name in ALWAYS_ALLOWED
+        or describe_call(method_name)["operation"] in READ_OPERATIONS
+        or method_name in READ_METHODS
+    )
+
+
+class PolicyEngine:
+    """Evaluates authorization policies for JSON-RPC calls."""
+
+    def __init__(
+        self,
+        repo: Optional[AuthzPolicyRepository] = None,
+        user_repo: Optional[UserRepository] = None,
+        policy_file: str = "",
+        refresh_seconds: float = 30.0,
+        default_decision: str = "",
+    ):
+        if default_decision not in DECISIONS:
+            raise ValueError("default_decision must be one of: allow, deny")
+        self.repo = repo
+        self.user_repo = user_repo
+        self.policy_file = policy_file
+        self.refresh_seconds = refresh_seconds
+        self.default_decision = default_decision
+
+        self._file_policies: List[Policy] = []
+        self._file_mtime: Optional[float] = None
+        self._table_policies: List[Policy] = []
+        self._table_loaded_at: Optional[float] = None
+        self._lock = asyncio.Lock()
+
+    def invalidate(self) -> None:
+        """Force the policy table to be reloaded on the next call."""
+        self._table_loaded_at = None
+
+    async def policies(self) -> List[Policy]:
+        """Return the current policy set, reloading stale sources."""
+        async with self._lock:
+            self._reload_file()
+            await self._reload_table()
+        return self._file_policies + self._table_policies
+
+    async def check(self, call: RpcCall) -> None:
+        """
+        Authorize a call.
+
+        Raises:
+            PermissionDeniedError: If the call is denied
+        """
+        if call.method in ALWAYS_ALLOWED:
+            return
+
+        policies = [p for p in await self.policies() if p.applies_to(call.method)]
+        if not policies:
+            if self._default_allows(bool(self._file_policies or self._table_policies)):
+                return
+            raise PermissionDeniedError(f"permission denied: {call.method}")
+
+        activation = await self._activation(call)
+        allowed = False
+        for policy in sorted(policies, key=lambda p: p.effect != "deny"):
+            if await self._matches(policy, activation):
+                if policy.effect == "deny":
+                    metrics.inc("authz_decisions_total", labels={"decision": "deny"})
+                    raise PermissionDeniedError(f"permission denied by policy {policy.name}: {call.method}")
+                allowed = True
+                break
+
+        if allowed or self._default_allows(True):
+            metrics.inc("authz_decisions_total", labels={"decision": "allow"})
+            return
+        metrics.inc("authz_decisions_total", labels={"decision": "deny"})
+        raise PermissionDeniedError(f"permission denied: {call.method}")
+
+    def _default_allows(self, have_policies: bool) -> bool:
+        if self.default_decision:
+            return self.default_decision == "allow"
+        return not have_policies
+
+    async def _matches(self, policy: Policy, activation: Dict[str, Any]) -> bool:
+        """Evaluate a policy; evaluation errors are treated as no match."""
+        try:
+            result = await evaluate(compile_expression(policy.expression), activation)
+        except ExpressionError as e:
+            logger.warning(f"Authorization policy {policy.name} failed: {e}")
+            return False
+        return result is True
+
+    async def _activation(self, call: RpcCall) -> Dict[str, Any]:
+        described = describe_call(call.method)
+        tenant_id = str(call.params.get("tenant_id") or "")
+        if not tenant_id and described["entity_type"] == "tenant":
+            tenant_id = str(call.params.get("id") or "")
+
+        principal = call.context.attributes.get("principal") or {}
+        certificate = call.context.attributes.get("client_certificate") or {}
+        if certificate.get("role") and tenant_id:
+            tenant_role = certificate["role"]
+        else:
+            tenant_role = await self._tenant_role(tenant_id, call.context.subject_id)
+        return {
+            "subject": {
+                "id": call.context.subject_id,
+                "tenant_role": tenant_role,
+                "principal": principal,
+                "groups": list(principal.get("groups", [])),
+            },
+            "tenant": {"id": tenant_id},
+            "entity": {"type": described["entity_type"], "id": str(call.params.get("id") or "")},
+            "operation": described["operation"],
+            "method": call.method,
+            "params": {k: v for k, v in call.params.items() if v is not None},
+        }
+
+    async def _tenant_role(self, tenant_id: str, subject_id: str) -> str:
+        if not self.user_repo or not tenant_id or not subject_id:
+            return ""
+        try:
+            # Members of an organization keep their role in its sub-tenants
+            member = await self.user_repo.get_inherited_tenant_user(tenant_id, subject_id)
+        except Exception:
+            # Malformed IDs are not members of anything
+            return ""
+        return member.role if member and member.status == "active" else ""
+
+    def _reload_file(self) -> None:
+        if not self.policy_file:
+            return
+        try:
+            mtime = os.path.getmtime(self.policy_file)
+        except OSError:
+            if self._file_policies:
+                logger.warning(f"Authorization policy file {self.policy_file} disappeared; keeping last policies")
+            return
+        if mtime == self._file_mtime:
+            return
+
+        try:
+            self._file_policies = load_policy_file(self.policy_file)
+            self._file_mtime = mtime
+            logger.info(f"Loaded {len(self._file_policies)} authorization policies from {self.policy_file}")
+        except (OSError, ValueError) as e:
+            # Keep serving the previous policies rather than failing open or closed
+            logger.error(f"Failed to load authorization policy file {self.policy_file}: {e}")
+
+    async def _reload_table(self) -> None:
+        if not self.repo:
+            return
+        now = time.monotonic()
+        if self._table_loaded_at is not None and now - self._table_loaded_at < self.refresh_seconds:
+            return
+
+        try:
+            rows = await self.repo.list_enabled()
+        except Exception as e:
+            logger.error(f"Failed to load authorization policies: {e}")
+            return
+        self._table_policies = [
+            Policy(name=p.name, effect=p.effect, expression=p.expression, methods=list(p.methods))
+            for p in rows
+        ]
+        self._table_loaded_at = now
+
+
+def load_policy_file(path: str) -> List[Policy]:
+    """
+    Load policies from a JSON file of the form
+    ``{"policies": [{"name", "effect", "expression", "methods"}]}``.
+
+    Raises:
+        ValueError: If the file is malformed or a policy does not compile
+    """
+    with open(path) as f:
+        try:
+            doc = json.load(f)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"invalid JSON: {e}") from e
+
+    entries = doc.get("policies", []) if isinstance(doc, dict) else doc
+    if not isinstance(entries, list):
+        raise ValueError("policies must be a list")
+
+    policies = []
+    for entry in entries:
+        policy = Policy(
+            name=str(entry.get("name", "")),
+            effect=entry.get("effect", "allow"),
+            expression=entry.get("expression", ""),
+            methods=list(entry.get("methods", [])),
+            source="file",
+        )
+        validate_policy(policy.name, policy.effect, policy.expression)
+        policies.append(policy)
+    return policies
+
+
+def authz_interceptor(engine: PolicyEngine) -> Interceptor:
+    """Create an interceptor that rejects calls denied by the engine."""
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        try:
+            await engine.check(call)
+        except PermissionDeniedError as e:
+            return Error(-32003, str(e))
+        return await call_next(call)
+
+    return interceptor
@@ -0,0 +1,221 @@
+"""
+HMAC-signed requests of machine callers.
+
+Webhook-style callers that cannot obtain tokens sign each request with a
+shared secret instead. A keys file (``REQUEST_SIGNING_KEYS_FILE``) lists
+the secrets and the callers they stand for::
+
+    {"keys": [
+        {"key_id": "billing", "secret": "...", "subject_id": "svc-billing",
+         "tenant_ids": ["TENANT_ID"], "scope": "write"}
+    ]}
+
+A signed request carries four headers:
+
+- ``X-Signature-Key``: the key ID
+- ``X-Signature-Timestamp``: the signing time, in Unix seconds
+- ``X-Signature-Nonce``: a value unique to the request (up to 128 characters)
+- ``X-Signature``: the hex HMAC-SHA256 of
+  ``"<timestamp>\\n<nonce>\\n<request path>\\n"`` followed by the body
+
+The signature is checked once per HTTP request, before its calls are
+dispatched. Requests whose timestamp is more than the tolerance away from
+the server's clock are rejected, and each nonce is accepted once per key
+within that window (nonces are recorded in the control database, so a
+replay is rejected by every server). A verified request runs as
+``subject_id`` (the key ID when unset); like an API key (see
+app/authz/api_keys.py), the key may only target ``tenant_ids`` (any when
+empty; when tenants are listed, methods that target no tenant are denied)
+and call the methods of its ``scope`` (any when unset). The file is
+reloaded whenever it changes.
+"""
+
+import hashlib
+import hmac
+import json
+import logging
+import os
+import time
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta, timezone
+from typing import Dict, List, Optional
+
+from jsonrpcserver import Error, Result
+
+from app.authz.api_keys import scope_allows
+from app.authz.engine import ALWAYS_ALLOWED
+from app.authz.impersonation import call_tenant_id
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+from app.repository import PermissionDeniedError, RequestNonceRepository
+from app.service.api_key_service import SCOPES
+
+logger = logging.getLogger(__name__)
+
+SIGNATURE_HEADER = "x-signature"
+SIGNATURE_KEY_HEADER = "x-signature-key"
+SIGNATURE_TIMESTAMP_HEADER = "x-signature-timestamp"
+SIGNATURE_NONCE_HEADER = "x-signature-nonce"
+
+DEFAULT_TOLERANCE_SECONDS = 300
+MAX_NONCE_LENGTH = 128
+
+
+@dataclass
+class SigningKey:
+    """A shared secret and the caller its signed requests run as."""
+    key_id: str
+    secret: str = field(repr=False)
+    subject_id: str = ""
+    tenant_ids: List[str] = field(default_factory=list)
+    # API key scope ("read", "write", "export" or "admin"); empty allows every method
+    scope: str = ""
+
+
+def sign_request(secret: str, timestamp: str, nonce: str, path: str, body: bytes) -> str:
+    """The hex signature of a request."""
+    message = f"{timestamp}\n{nonce}\n{path}\n".encode() + body
+    return hmac.new(secret.encode(), message, hashlib.sha256).hexdigest()
+
+
+def load_signing_keys_file(path: str) -> Dict[str, SigningKey]:
+    """
+    Load signing keys from a JSON file of the form
+    ``{"keys": [{"key_id", "secret", "subject_id", "tenant_ids", "scope"}]}``.
+
+    Raises:
+        ValueError: If the file is malformed
+    """
+    with open(path) as f:
+        try:
+            doc = json.load(f)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"invalid JSON: {e}") from e
+
+    entries = doc.get("keys", []) if isinstance(doc, dict) else doc
+    if not isinstance(entries, list):
+        raise ValueError("keys must be a list")
+
+    keys: Dict[str, SigningKey] = {}
+    for i, entry in enumerate(entries):
+        if not isinstance(entry, dict):
+            raise ValueError(f"keys[{i}] must be an object")
+        key = SigningKey(
+            key_id=str(entry.get("key_id", "")),
+            secret=str(entry.get("secret", "")),
+            subject_id=str(entry.get("subject_id", "")),
+            tenant_ids=[str(t) for t in entry.get("tenant_ids", [])],
+            scope=str(entry.get("scope", "")),
+        )
+        if not key.key_id:
+            raise ValueError(f"keys[{i}] needs key_id")
+        if len(key.secret) < 32:
+            raise ValueError(f"keys[{i}].secret must be at least 32 characters")
+        if key.scope and key.scope not in SCOPES:
+            raise ValueError(f"keys[{i}].scope must be one of: {', '.join(SCOPES)}")
+        if key.key_id in keys:
+            raise ValueError(f"keys[{i}]: duplicate key_id {key.key_id}")
+        keys[key.key_id] = key
+    return keys
+
+
+class RequestSignatureVerifier:
+    """Verifies signed requests against a keys file, reloaded on change."""
+
+    def __init__(
+        self,
+        keys_file: str,
+        nonces: RequestNonceRepository,
+        tolerance_seconds: int = DEFAULT_TOLERANCE_SECONDS,
+    ):
+        self.keys_file = keys_file
+        self.nonces = nonces
+        self.tolerance_seconds = tolerance_seconds
+        self._keys: Dict[str, SigningKey] = {}
+        self._mtime: Optional[float] = None
+
+    async def verify(self, ctx: RequestContext, path: str, body: bytes, now: Optional[float] = None) -> None:
+        """
+        Check a request's signature and run it as the key's caller; unsigned requests are left alone.
+
+        Raises:
+            PermissionDeniedError: If the signature is missing parts, invalid, stale or replayed
+        """
+        signature = ctx.headers.get(SIGNATURE_HEADER, "")
+        key_id = ctx.headers.get(SIGNATURE_KEY_HEADER, "")
+        if not signature and not key_id:
+            return
+        timestamp = ctx.headers.get(SIGNATURE_TIMESTAMP_HEADER, "")
+        nonce = ctx.headers.get(SIGNATURE_NONCE_HEADER, "")
+        if not (signature and key_id and timestamp and nonce):
+            raise PermissionDeniedError(
+                "signed requests need X-Signature, X-Signature-Key, X-Signature-Timestamp and X-Signature-Nonce"
+            )
+        if len(nonce) > MAX_NONCE_LENGTH:
+            raise PermissionDeniedError(f"X-Signature-Nonce must be at most {MAX_NONCE_LENGTH} characters")
+
+        self._reload()
+        key = self._keys.get(key_id)
+        expected = sign_request(key.secret, timestamp, nonce, path, body) if key else ""
+        if not key or not hmac.compare_digest(expected, signature.strip().lower()):
+            raise PermissionDeniedError("invalid request signature")
+        try:
+            signed_at = int(timestamp)
+        except ValueError:
+            raise PermissionDeniedError("X-Signature-Timestamp must be Unix seconds")
+        if abs((now if now is not None else time.time()) - signed_at) > self.tolerance_seconds:
+            raise PermissionDeniedError(
+                f"request signature timestamp is more than {self.tolerance_seconds} seconds from the server's clock"
+            )
+        expires_at = datetime.fromtimestamp(signed_at, timezone.utc) + timedelta(seconds=self.tolerance_seconds)
+        if not await self.nonces.claim(key_id, nonce, expires_at):
+            raise PermissionDeniedError("request signature nonce has already been used")
+
+        ctx.subject_id = key.subject_id or key.key_id
+        ctx.attributes["request_signature"] = {
+            "key_id": key.key_id,
+            "tenant_ids": list(key.tenant_ids),
+            "scope": key.scope,
+        }
+
+    def _reload(self) -> None:
+        try:
+            mtime = os.path.getmtime(self.keys_file)
+        except OSError:
+            if self._keys:
+                logger.warning(f"Request signing keys file {self.keys_file} disappeared; keeping last keys")
+            return
+        if mtime == self._mtime:
+            return
+
+        try:
+            self._keys = load_signing_keys_file(self.keys_file)
+            self._mtime = mtime
+            logger.info(f"Loaded {len(self._keys)} request signing keys from {self.keys_file}")
+        except (OSError, ValueError) as e:
+            # Keep the previous keys rather than failing open or closed
+            logger.error(f"Failed to load request signing keys file {self.keys_file}: {e}")
+
+
+def request_signature_interceptor() -> Interceptor:
+    """Create an interceptor that limits signed requests to their key's tenants and scope."""
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        signed = call.context.attributes.get("request_signature")
+        if not signed or call.method in ALWAYS_ALLOWED:
+            return await call_next(call)
+
+        tenant_id = call_tenant_id(call)
+        if signed["tenant_ids"] and tenant_id not in signed["tenant_ids"]:
+            if not tenant_id:
+                return Error(
+                    -32003, f"signing key {signed['key_id']} is limited to tenants and may not call {call.method}"
+                )
+            return Error(-32003, f"signing key {signed['key_id']} may not access tenant {tenant_id}")
+        if signed["scope"] and not scope_allows(signed["scope"], call.method):
+            return Error(
+                -32003, f"signing key {signed['key_id']} has scope {signed['scope']} and may not call {call.method}"
+            )
+        return await call_next(call)
+
+    return interceptor
@@ -3,8 +3,11 @@ Database configuration module.
 """
 
 import os
+import ssl
 from dataclasses import dataclass
-from typing import Optional
+from typing import Any, Dict, Optional
+
+CLIENT_AUTH_MODES = ("none", "optional", "require")
 
 
 @dataclass
@@ -21,6 +24,115 @@ class Config:
     # Legacy: kept for backward compatibility during migration
     db_name: str = "dbaas"
     ssl_mode: str = "disable"
+    # Data residency: region=host[:port] database servers of tenant regions, and the region
+    # of DB_HOST, which new tenants get when they name none (empty: tenants have no region)
+    db_regions: str = ""
+    default_region: str = ""
+    # Sharding: name=host[:port] database servers new tenants of DB_HOST's region are spread
+    # over besides DB_HOST (the "default" shard), and how often each shard's health is checked
+    db_shards: str = ""
+    shard_health_interval_seconds: float = 30.0
+    # Prefix of database sessions' application_name, labeled with tenant and method (empty disables labels)
+    db_application_name: str = "flexdb"
+    # Compress node/relationship data at or above this size in bytes (0 disables)
+    compression_threshold_bytes: int = 65536
+    compression_level: int = 3
+    # Base64 master key (32 bytes) wrapping per-tenant data keys (empty disables tenant encryption)
+    encryption_master_key: str = ""
+    # Retries of transient database errors (attempts include the first; 1 disables)
+    db_retry_max_attempts: int = 3
+    db_retry_base_delay_ms: int = 50
+    db_retry_max_delay_ms: int = 1000
+    # Authorization policies: optional JSON policy file, table refresh interval,
+    # and decision when no policy matches ("" = deny only if policies exist)
+    authz_policy_file: str = ""
+    authz_refresh_seconds: float = 30.0
+    authz_default_decision: str = ""
+    # JSON file mapping forwarded client certificate identities to callers (empty ignores certificates)
+    client_cert_mapping_file: str = ""
+    # JSON file of HMAC request signing keys (empty ignores signatures) and accepted clock skew
+    request_signing_keys_file: str = ""
+    request_signing_tolerance_seconds: int = 300
+    # Reject calls without a credential (API key, client certificate, signature or impersonation token)
+    require_authentication: bool = False
+    # Work detached from calls (audit records of impersonated calls, export alerts): queue size, workers and deadline
+    side_effect_max_pending: int = 1000
+    side_effect_workers: int = 4
+    side_effect_deadline_seconds: float = 30.0
+    # StatsD/DogStatsD agent metrics are also pushed to (host:port; empty pushes none), its format,
+    # metric name prefix, constant DogStatsD tags ("key:value,...") and how often buffered metrics are sent
+    metrics_statsd_address: str = ""
+    metrics_statsd_format: str = "dogstatsd"
+    metrics_statsd_prefix: str = "flexdb"
+    metrics_statsd_tags: str = ""
+    metrics_statsd_flush_seconds: float = 1.0
+    # How pending migrations run: "direct" (each file in one transaction) or "online" (lock-heavy
+    # statements split into online steps; see app/db/online_migrations.py), with the lock wait
+    # of each step and the rows per batch of backfills
+    migration_mode: str = "direct"
+    migration_lock_timeout_ms: int = 5000
+    migration_backfill_batch_size: int = 1000
+    # Attachment object storage (attachments are disabled without a bucket)
+    attachment_s3_bucket: str = ""
+    attachment_s3_endpoint: str = ""
+    attachment_s3_region: str = ""
+    attachment_max_bytes: int = 100 * 1024 * 1024
+    attachment_upload_ttl_seconds: int = 900
+    attachment_url_expiry_seconds: int = 300
+    # Deleted tenants are recoverable for this long (0 purges immediately);
+    # the purger checks for expired deletions every tenant_purge_interval_seconds
+    tenant_delete_grace_seconds: int = 7 * 24 * 3600
+    tenant_purge_interval_seconds: float = 300.0
+    # Comma-separated user IDs allowed to impersonate tenants (empty disables impersonation)
+    admin_user_ids: str = ""
+    impersonation_max_ttl_seconds: int = 3600
+    # Snapshot read sessions: maximum lifetime and open sessions per server process
+    read_session_ttl_seconds: int = 300
+    read_session_max: int = 20
+    # Change subscriptions: poll interval of streams, open streams per server process, and reservation lifetime
+    change_stream_poll_seconds: float = 1.0
+    change_stream_max: int = 100
+    change_subscription_ttl_seconds: int = 3600
+    # Request log: fraction of calls logged at random, and latency at which calls are always logged
+    request_log_sample_rate: float = 0.0
+    request_log_slow_ms: float = 1000.0
+    # Shape limits of JSON documents in calls (0 disables a limit); see app/jsonrpc/json_limits.py
+    json_max_depth: int = 32
+    json_max_keys: int = 10000
+    json_max_string_length: int = 1024 * 1024
+    # Base64 32-byte key; when set, JSON-RPC exposes opaque external IDs (see app/jsonrpc/external_ids.py)
+    external_id_key: str = ""
+    # OpenSearch/Elasticsearch URL; when set, nodes are mirrored there and search_nodes queries it
+    opensearch_url: str = ""
+    opensearch_index_prefix: str = "flexdb-nodes-"
+    opensearch_username: str = ""
+    opensearch_password: str = ""
+    search_index_interval_seconds: float = 5.0
+    # Cached query results kept per process (tenants opt in with the query_cache_seconds limit)
+    query_cache_max_entries: int = 10000
+    # Bulk imports running at once per process, the longest wait between writes when the
+    # database slows down (0 disables backpressure), and how long an upload waits for a slot
+    import_max_concurrent: int = 4
+    import_max_delay_ms: int = 1000
+    import_queue_timeout_seconds: float = 300.0
+    # Share of each database pool batch-priority work may hold (1 disables the limit), and batch
+    # calls run at once per process (0 is unlimited)
+    db_batch_pool_share: float = 0.5
+    batch_max_concurrent_calls: int = 0
+    # Billing events: how often counted usage is written (0 disables) and tenant database sizes are sampled
+    billing_flush_interval_seconds: float = 60.0
+    billing_storage_interval_seconds: float = 3600.0
+    # Scheduled exports: how often due schedules are run (0 disables) and where file:// destinations may write
+    export_schedule_interval_seconds: float = 60.0
+    export_local_root: str = ""
+    # Orphaned relationships: how often every tenant is checked (0 disables) and whether orphans are deleted
+    orphan_gc_interval_seconds: float = 0.0
+    orphan_gc_delete: bool = False
+    # Table maintenance: how often every tenant's tables are checked (0 disables), and the
+    # dead tuple share and count past which a table is vacuumed
+    table_maintenance_interval_seconds: float = 0.0
+    table_maintenance_dead_ratio: float = 0.2
+    table_maintenance_min_dead_rows: int = 10000
 
     def connection_string(self, database: Optional[str] = None) -> str:
         """Return PostgreSQL connection string."""
@@ -56,4 +168,163 @@ def config_from_env() -> Config:
         tenant_db_prefix=os.getenv("DB_TENANT_PREFIX", "dbaas_tenant_"),
         db_name=os.getenv("DB_NAME", "dbaas"),  # Legacy, kept for compatibility
         ssl_mode=os.getenv("DB_SSL_MODE", "disable"),
+        db_regions=os.getenv("DB_REGIONS", ""),
+        default_region=os.getenv("DEFAULT_REGION", ""),
+        db_shards=os.getenv("DB_SHARDS", ""),
+        shard_health_interval_seconds=float(os.getenv("SHARD_HEALTH_INTERVAL_SECONDS", "30")),
+        db_application_name=os.getenv("DB_APPLICATION_NAME", "flexdb"),
+        compression_threshold_bytes=int(os.getenv("DATA_COMPRESSION_THRESHOLD", "65536")),
+        compression_level=int(os.getenv("DATA_COMPRESSION_LEVEL", "3")),
+        encryption_master_key=os.getenv("ENCRYPTION_MASTER_KEY", ""),
+        db_retry_max_attempts=int(os.getenv("DB_RETRY_MAX_ATTEMPTS", "3")),
+        db_retry_base_delay_ms=int(os.getenv("DB_RETRY_BASE_DELAY_MS", "50")),
+        db_retry_max_delay_ms=int(os.getenv("DB_RETRY_MAX_DELAY_MS", "1000")),
+        authz_policy_file=os.getenv("AUTHZ_POLICY_FILE", ""),
+        authz_refresh_seconds=float(os.getenv("AUTHZ_REFRESH_SECONDS", "30")),
+        authz_default_decision=os.getenv("AUTHZ_DEFAULT_DECISION", ""),
+        client_cert_mapping_file=os.getenv("CLIENT_CERT_MAPPING_FILE", ""),
+        request_signing_keys_file=os.getenv("REQUEST_SIGNING_KEYS_FILE", ""),
+        request_signing_tolerance_seconds=int(os.getenv("REQUEST_SIGNING_TOLERANCE_SECONDS", "300")),
+        require_authentication=os.getenv("REQUIRE_AUTHENTICATION", "false").lower() == "true",
+        side_effect_max_pending=int(os.getenv("SIDE_EFFECT_MAX_PENDING", "1000")),
+        side_effect_workers=int(os.getenv("SIDE_EFFECT_WORKERS", "4")),
+        side_effect_deadline_seconds=float(os.getenv("SIDE_EFFECT_DEADLINE_SECONDS", "30")),
+        migration_mode=os.getenv("MIGRATION_MODE", "direct"),
+        migration_lock_timeout_ms=int(os.getenv("MIGRATION_LOCK_TIMEOUT_MS", "5000")),
+        migration_backfill_batch_size=int(os.getenv("MIGRATION_BACKFILL_BATCH_SIZE", "1000")),
+        metrics_statsd_address=os.getenv("METRICS_STATSD_ADDRESS", ""),
+        metrics_statsd_format=os.getenv("METRICS_STATSD_FORMAT", "dogstatsd"),
+        metrics_statsd_prefix=os.getenv("METRICS_STATSD_PREFIX", "flexdb"),
+        metrics_statsd_tags=os.getenv("METRICS_STATSD_TAGS", ""),
+        metrics_statsd_flush_seconds=float(os.getenv("METRICS_STATSD_FLUSH_SECONDS", "1")),
+        tenant_delete_grace_seconds=int(os.getenv("TENANT_DELETE_GRACE_SECONDS", "604800")),
+        tenant_purge_interval_seconds=float(os.getenv("TENANT_PURGE_INTERVAL_SECONDS", "300")),
+        admin_user_ids=os.getenv("ADMIN_USER_IDS", ""),
+        impersonation_max_ttl_seconds=int(os.getenv("IMPERSONATION_MAX_TTL_SECONDS", "3600")),
+        read_session_ttl_seconds=int(os.getenv("READ_SESSION_TTL_SECONDS", "300")),
+        read_session_max=int(os.getenv("READ_SESSION_MAX", "20")),
+        change_stream_poll_seconds=float(os.getenv("CHANGE_STREAM_POLL_SECONDS", "1")),
+        change_stream_max=int(os.getenv("CHANGE_STREAM_MAX", "100")),
+        change_subscription_ttl_seconds=int(os.getenv("CHANGE_SUBSCRIPTION_TTL_SECONDS", "3600")),
+        request_log_sample_rate=float(os.getenv("REQUEST_LOG_SAMPLE_RATE", "0")),
+        request_log_slow_ms=float(os.getenv("REQUEST_LOG_SLOW_MS", "1000")),
+        json_max_depth=int(os.getenv("JSON_MAX_DEPTH", "32")),
+        json_max_keys=int(os.getenv("JSON_MAX_KEYS", "10000")),
+        json_max_string_length=int(os.getenv("JSON_MAX_STRING_LENGTH", str(1024 * 1024))),
+        external_id_key=os.getenv("EXTERNAL_ID_KEY", ""),
+        opensearch_url=os.getenv("OPENSEARCH_URL", ""),
+        opensearch_index_prefix=os.getenv("OPENSEARCH_INDEX_PREFIX", "flexdb-nodes-"),
+        opensearch_username=os.getenv("OPENSEARCH_USERNAME", ""),
+        opensearch_password=os.getenv("OPENSEARCH_PASSWORD", ""),
+        search_index_interval_seconds=float(os.getenv("SEARCH_INDEX_INTERVAL_SECONDS", "5")),
+        query_cache_max_entries=int(os.getenv("QUERY_CACHE_MAX_ENTRIES", "10000")),
+        import_max_concurrent=int(os.getenv("IMPORT_MAX_CONCURRENT", "4")),
+        import_max_delay_ms=int(os.getenv("IMPORT_MAX_DELAY_MS", "1000")),
+        import_queue_timeout_seconds=float(os.getenv("IMPORT_QUEUE_TIMEOUT_SECONDS", "300")),
+        db_batch_pool_share=float(os.getenv("DB_BATCH_POOL_SHARE", "0.5")),
+        batch_max_concurrent_calls=int(os.getenv("BATCH_MAX_CONCURRENT_CALLS", "0")),
+        billing_flush_interval_seconds=float(os.getenv("BILLING_FLUSH_INTERVAL_SECONDS", "60")),
+        billing_storage_interval_seconds=float(os.getenv("BILLING_STORAGE_INTERVAL_SECONDS", "3600")),
+        export_schedule_interval_seconds=float(os.getenv("EXPORT_SCHEDULE_INTERVAL_SECONDS", "60")),
+        export_local_root=os.getenv("EXPORT_LOCAL_ROOT", ""),
+        orphan_gc_interval_seconds=float(os.getenv("ORPHAN_GC_INTERVAL_SECONDS", "0")),
+        orphan_gc_delete=os.getenv("ORPHAN_GC_DELETE", "false").lower() == "true",
+        table_maintenance_interval_seconds=float(os.getenv("TABLE_MAINTENANCE_INTERVAL_SECONDS", "0")),
+        table_maintenance_dead_ratio=float(os.getenv("TABLE_MAINTENANCE_DEAD_RATIO", "0.2")),
+        table_maintenance_min_dead_rows=int(os.getenv("TABLE_MAINTENANCE_MIN_DEAD_ROWS", "10000")),
+        attachment_s3_bucket=os.getenv("ATTACHMENT_S3_BUCKET", ""),
+        attachment_s3_endpoint=os.getenv("ATTACHMENT_S3_ENDPOINT", ""),
+        attachment_s3_region=os.getenv("ATTACHMENT_S3_REGION", ""),
+        attachment_max_bytes=int(os.getenv("ATTACHMENT_MAX_BYTES", str(100 * 1024 * 1024))),
+        attachment_upload_ttl_seconds=int(os.getenv("ATTACHMENT_UPLOAD_TTL_SECONDS", "900")),
+        attachment_url_expiry_seconds=int(os.getenv("ATTACHMENT_URL_EXPIRY_SECONDS", "300")),
+    )
+
+
+@dataclass
+class ServerConfig:
+    """HTTP server listener configuration: address, TLS, keepalive and message sizes."""
+    host: str = "0.0.0.0"
+    port: int = 5000
+    # TLS is enabled when a certificate and key are given; a client CA enables mTLS
+    tls_cert_file: str = ""
+    tls_key_file: str = ""
+    tls_client_ca_file: str = ""
+    # Client certificates with a client CA: "require" rejects clients without one
+    tls_client_auth: str = "require"
+    # Idle keep-alive connections are closed after this many seconds
+    keepalive_timeout_seconds: float = 5.0
+    # Largest JSON-RPC request accepted and response sent, in bytes (0 = unlimited)
+    max_receive_bytes: int = 16 * 1024 * 1024
+    max_send_bytes: int = 64 * 1024 * 1024
+
+    @property
+    def tls_enabled(self) -> bool:
+        return bool(self.tls_cert_file)
+
+    def validate(self) -> None:
+        """
+        Check the settings are consistent and the TLS files are readable.
+
+        Raises:
+            ValueError: Describing the first invalid setting
+        """
+        if not 0 < self.port < 65536:
+            raise ValueError("JSONRPC_PORT must be between 1 and 65535")
+        if bool(self.tls_cert_file) != bool(self.tls_key_file):
+            raise ValueError("SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE must be set together")
+        if self.tls_client_ca_file and not self.tls_enabled:
+            raise ValueError("SERVER_TLS_CLIENT_CA_FILE requires SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE")
+        if self.tls_client_auth not in CLIENT_AUTH_MODES:
+            raise ValueError(f"SERVER_TLS_CLIENT_AUTH must be one of: {', '.join(CLIENT_AUTH_MODES)}")
+        for name, path in (
+            ("SERVER_TLS_CERT_FILE", self.tls_cert_file),
+            ("SERVER_TLS_KEY_FILE", self.tls_key_file),
+            ("SERVER_TLS_CLIENT_CA_FILE", self.tls_client_ca_file),
+        ):
+            if path and not os.access(path, os.R_OK):
+                raise ValueError(f"{name} is not a readable file: {path}")
+        if self.keepalive_timeout_seconds <= 0:
+            raise ValueError("SERVER_KEEPALIVE_TIMEOUT_SECONDS must be positive")
+        for name, value in (
+            ("SERVER_MAX_RECEIVE_BYTES", self.max_receive_bytes),
+            ("SERVER_MAX_SEND_BYTES", self.max_send_bytes),
+        ):
+            if value < 0 or 0 < value < 1024:
+                raise ValueError(f"{name} must be 0 (unlimited) or at least 1024")
+
+    def uvicorn_options(self) -> Dict[str, Any]:
+        """Listener options for uvicorn.run."""
+        options: Dict[str, Any] = {
+            "host": self.host,
+            "port": self.port,
+            "timeout_keep_alive": self.keepalive_timeout_seconds,
+        }
+        if self.tls_enabled:
+            options["ssl_certfile"] = self.tls_cert_file
+            options["ssl_keyfile"] = self.tls_key_file
+        if self.tls_client_ca_file:
+            options["ssl_ca_certs"] = self.tls_client_ca_file
+            options["ssl_cert_reqs"] = (
+                ssl.CERT_REQUIRED if self.tls_client_auth == "require"
+                else ssl.CERT_OPTIONAL if self.tls_client_auth == "optional"
+                else ssl.CERT_NONE
+            )
+        return options
+
+
+def server_config_from_env() -> ServerConfig:
+    """Load and validate the HTTP server configuration from environment variables."""
+    cfg = ServerConfig(
+        host=os.getenv("JSONRPC_HOST", "0.0.0.0"),
+        port=int(os.getenv("JSONRPC_PORT", "5000")),
+        tls_cert_file=os.getenv("SERVER_TLS_CERT_FILE", ""),
+        tls_key_file=os.getenv("SERVER_TLS_KEY_FILE", ""),
+        tls_client_ca_file=os.getenv("SERVER_TLS_CLIENT_CA_FILE", ""),
+        tls_client_auth=os.getenv("SERVER_TLS_CLIENT_AUTH", "require"),
+        keepalive_timeout_seconds=float(os.getenv("SERVER_KEEPALIVE_TIMEOUT_SECONDS", "5")),
+        max_receive_bytes=int(os.getenv("SERVER_MAX_RECEIVE_BYTES", str(16 * 1024 * 1024))),
+        max_send_bytes=int(os.getenv("SERVER_MAX_SEND_BYTES", str(64 * 1024 * 1024))),
     )
+    cfg.validate()
+    return cfg
@@ -14,7 +14,11 @@ from typing import Optional
 import asyncpg
 
 from app.config import Config
+from app.db.labels import pool_options
+from app.db.priority import prioritized_pool
+from app.db.tracing import trace_queries
 from app.db.database import Database
+from app.db.online_migrations import apply_online, plan_migration
 
 logger = logging.getLogger(__name__)
 
@@ -49,6 +53,8 @@ async def connect_control_db(cfg: Config) -> Database:
             min_size=1,
             max_size=10,
             ssl=ssl_context,
+            init=trace_queries,
+            **pool_options(cfg.db_application_name),
         )
         
         # Test the connection
@@ -56,13 +62,13 @@ async def connect_control_db(cfg: Config) -> Database:
             await conn.execute("SELECT 1")
         
         logger.info(f"Connected to control database: {cfg.control_db_name}")
-        return Database(pool)
+        return Database(prioritized_pool(pool))
     except Exception as e:
         raise Exception(f"Failed to connect to control database: {e}") from e
 
 
-async def run_control_migrations(db: Database) -> None:
-    """Apply all control database migrations."""
+async def run_control_migrations(db: Database, cfg: Optional[Config] = None) -> None:
+    """Apply all control database migrations; online (see app/db/online_migrations.py) if cfg says so."""
     async with db.pool.acquire() as conn:
         # Create migrations tracking table
         await conn.execute("""
@@ -92,14 +98,23 @@ async def run_control_migrations(db: Database) -> None:
 
             logger.info(f"Applying control migration {version}")
             content = (migrations_dir / filename).read_text()
-            
-            # Execute the migration in a transaction
-            async with conn.transaction():
-                await conn.execute(content)
+
+            async def record(conn: asyncpg.Connection, version: str = version) -> None:
                 await conn.execute(
                     "INSERT INTO schema_migrations (version) VALUES ($1)",
                     version
                 )
+
+            # A new database's tables are empty, so its migrations cannot hold heavy locks for long
+            if cfg and cfg.migration_mode == "online" and applied:
+                plan = plan_migration(version, content, cfg.migration_backfill_batch_size)
+                await apply_online(conn, plan, record, cfg.migration_lock_timeout_ms)
+                continue
+
+            # Execute the migration in a transaction
+            async with conn.transaction():
+                await conn.execute(content)
+                await record(conn)
         
         logger.info("Control database migrations completed")
 
@@ -0,0 +1,6 @@
+-- Migration: 002_add_sort_indexes.up.sql
+-- Indexes backing the default and common order_by columns of list queries.
+
+CREATE INDEX IF NOT EXISTS idx_tenants_created_at ON tenants(created_at DESC, id);
+CREATE INDEX IF NOT EXISTS idx_tenants_name ON tenants(name);
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at DESC, id);
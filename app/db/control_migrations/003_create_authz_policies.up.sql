@@ -0,0 +1,14 @@
+-- Migration: 003_create_authz_policies.up.sql
+-- Admin-defined CEL authorization policies evaluated per JSON-RPC call.
+
+CREATE TABLE IF NOT EXISTS authz_policies (
+    id          UUID PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT,
+    effect      TEXT NOT NULL,                 -- 'allow' or 'deny'
+    methods     TEXT[] NOT NULL DEFAULT '{}',  -- glob patterns; empty = all methods
+    expression  TEXT NOT NULL,
+    enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+-- Migration: 004_add_tenant_filter_indexes.up.sql
+-- Indexes backing list_tenants filters.
+
+CREATE INDEX IF NOT EXISTS idx_tenants_status ON tenants(status);
+CREATE INDEX IF NOT EXISTS idx_tenants_slug_prefix ON tenants(slug text_pattern_ops);
@@ -0,0 +1,6 @@
+-- Migration: 005_add_tenant_deletion.up.sql
+-- Deferred tenant deletion: tenants pending deletion are purged after delete_after.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS delete_after TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_tenants_delete_after ON tenants(delete_after) WHERE delete_after IS NOT NULL;
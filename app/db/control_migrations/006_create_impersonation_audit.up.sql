@@ -0,0 +1,30 @@
+-- Migration: 006_create_impersonation_audit.up.sql
+-- Admin impersonation tokens and the audit log of impersonated calls.
+
+CREATE TABLE IF NOT EXISTS impersonation_tokens (
+    id            UUID PRIMARY KEY,
+    token_hash    TEXT NOT NULL UNIQUE,        -- sha256 of the bearer token; the token itself is never stored
+    admin_user_id TEXT NOT NULL,
+    tenant_id     UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id       TEXT NOT NULL DEFAULT '',    -- tenant user acted as; empty = the admin within the tenant
+    reason        TEXT NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    revoked_at    TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id               BIGSERIAL PRIMARY KEY,
+    occurred_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    request_id       TEXT NOT NULL DEFAULT '',
+    actor_id         TEXT NOT NULL DEFAULT '',
+    impersonated_by  TEXT NOT NULL DEFAULT '',  -- admin user ID when the call was impersonated
+    impersonation_id UUID,
+    tenant_id        TEXT NOT NULL DEFAULT '',
+    method           TEXT NOT NULL,
+    outcome          TEXT NOT NULL,             -- 'ok' or 'error'
+    error_code       INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_tenant ON audit_events(tenant_id, occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_impersonated ON audit_events(occurred_at) WHERE impersonated_by <> '';
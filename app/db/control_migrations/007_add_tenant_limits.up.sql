@@ -0,0 +1,4 @@
+-- Migration: 007_add_tenant_limits.up.sql
+-- Per-tenant overrides of default and maximum page size, traversal depth and batch size.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS limits JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,6 @@
+-- Migration: 008_add_tenant_annotations.up.sql
+-- Free-form string annotations on tenants, filterable by containment.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS annotations JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_tenants_annotations ON tenants USING GIN (annotations jsonb_path_ops);
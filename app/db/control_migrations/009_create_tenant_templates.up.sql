@@ -0,0 +1,11 @@
+-- Migration: 009_create_tenant_templates.up.sql
+-- Named templates of node types, relationship types and seed data applied to new tenants.
+
+CREATE TABLE IF NOT EXISTS tenant_templates (
+    id          UUID PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    definition  JSONB NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
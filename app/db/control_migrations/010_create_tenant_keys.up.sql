@@ -0,0 +1,19 @@
+-- Migration: 010_create_tenant_keys.up.sql
+-- Per-tenant data encryption keys, wrapped with the server's master key.
+-- status: pending (being distributed), active (encrypts new writes),
+-- retiring (data is being re-encrypted away from it) or retired.
+
+CREATE TABLE IF NOT EXISTS tenant_keys (
+    id           UUID PRIMARY KEY,
+    tenant_id    UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    version      INTEGER NOT NULL,
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending',
+    wrapped_key  BYTEA NOT NULL,
+    operation_id UUID,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    activated_at TIMESTAMPTZ,
+    retired_at   TIMESTAMPTZ,
+    UNIQUE (tenant_id, version)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_keys_active ON tenant_keys(tenant_id) WHERE status = 'active';
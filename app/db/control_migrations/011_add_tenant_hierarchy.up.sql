@@ -0,0 +1,8 @@
+-- Migration: 011_add_tenant_hierarchy.up.sql
+-- Organizations with sub-tenants: sub-tenants inherit limits, feature flags,
+-- node and relationship types and memberships from their ancestors.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES tenants(id) ON DELETE SET NULL;
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS features JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_tenants_parent ON tenants(parent_id) WHERE parent_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Migration: 012_add_tenant_maintenance.up.sql
+-- Read-only maintenance mode: while maintenance_started_at is set, writes to
+-- the tenant are rejected with maintenance_reason (e.g. during a restore).
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS maintenance_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS maintenance_started_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+-- Migration: 013_add_tenant_timezone.up.sql
+-- Tenant time zone (IANA name): timestamps stay stored in UTC, and date
+-- histograms count per day (week, month) in the tenant's local time.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,9 @@
+-- Migration: 014_create_search_index_cursors.up.sql
+-- Position of the search indexer in each tenant's change stream: the sync
+-- cursor of the last changes mirrored into the tenant's search index.
+
+CREATE TABLE IF NOT EXISTS search_index_cursors (
+    tenant_id   UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    sync_cursor TEXT NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,17 @@
+-- Migration: 015_create_billing_events.up.sql
+-- Usage events for billing: API calls and export bytes counted per flush
+-- period, and changes in tenant database size. Tenants are not referenced,
+-- so usage of deleted tenants can still be billed.
+
+CREATE TABLE IF NOT EXISTS billing_events (
+    id           BIGSERIAL PRIMARY KEY,         -- inserts are serialized, so IDs are in commit order
+    tenant_id    UUID NOT NULL,
+    event_type   TEXT NOT NULL,                 -- 'api_calls', 'export_bytes' or 'storage_bytes'
+    dimension    TEXT NOT NULL DEFAULT '',      -- JSON-RPC method for api_calls and export_bytes
+    quantity     BIGINT NOT NULL,               -- storage_bytes: change in database size (may be negative)
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end   TIMESTAMPTZ NOT NULL,
+    recorded_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_billing_events_tenant ON billing_events(tenant_id, period_start);
@@ -0,0 +1,38 @@
+-- Migration: 016_create_export_schedules.up.sql
+-- Recurring tenant exports: a cron schedule (in the tenant's time zone),
+-- what to export and where to write it, and the history of its runs.
+
+CREATE TABLE IF NOT EXISTS export_schedules (
+    id                    UUID PRIMARY KEY,
+    tenant_id             UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name                  VARCHAR(255) NOT NULL,
+    cron                  TEXT NOT NULL,
+    format                TEXT NOT NULL,            -- 'ndjson', 'graphml' or 'dot'
+    destination           TEXT NOT NULL,            -- URL, e.g. s3://bucket/prefix/
+    filter                JSONB NOT NULL DEFAULT '{}',
+    include_relationships BOOLEAN NOT NULL DEFAULT TRUE,
+    alert_url             TEXT NOT NULL DEFAULT '', -- receives a POST when a run fails
+    enabled               BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at           TIMESTAMPTZ,              -- NULL while disabled
+    consecutive_failures  INTEGER NOT NULL DEFAULT 0,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_schedules_due ON export_schedules(next_run_at) WHERE enabled;
+
+CREATE TABLE IF NOT EXISTS export_runs (
+    id                 UUID PRIMARY KEY,
+    schedule_id        UUID NOT NULL REFERENCES export_schedules(id) ON DELETE CASCADE,
+    status             TEXT NOT NULL DEFAULT 'running', -- 'running', 'succeeded' or 'failed'
+    location           TEXT NOT NULL DEFAULT '',        -- URL of the written export
+    node_count         BIGINT NOT NULL DEFAULT 0,
+    relationship_count BIGINT NOT NULL DEFAULT 0,
+    size_bytes         BIGINT NOT NULL DEFAULT 0,
+    error              TEXT NOT NULL DEFAULT '',
+    started_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at        TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_runs_schedule ON export_runs(schedule_id, started_at DESC);
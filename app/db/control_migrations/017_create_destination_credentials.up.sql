@@ -0,0 +1,17 @@
+-- Migration: 017_create_destination_credentials.up.sql
+-- Tenants' credentials for export destinations (GCS, Azure Blob, SFTP, S3),
+-- sealed with the server's master key. Export schedules name the credential
+-- they write with.
+
+CREATE TABLE IF NOT EXISTS destination_credentials (
+    id         UUID PRIMARY KEY,
+    tenant_id  UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name       VARCHAR(255) NOT NULL,
+    kind       TEXT NOT NULL,   -- destination scheme: 's3', 'gcs', 'azure' or 'sftp'
+    secret     BYTEA NOT NULL,  -- sealed JSON object
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, name)
+);
+
+ALTER TABLE export_schedules ADD COLUMN IF NOT EXISTS credential TEXT NOT NULL DEFAULT '';
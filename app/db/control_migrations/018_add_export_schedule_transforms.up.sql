@@ -0,0 +1,5 @@
+-- Migration: 018_add_export_schedule_transforms.up.sql
+-- Field selection and redaction applied to what export schedules write
+-- (see app/service/export_transforms.py).
+
+ALTER TABLE export_schedules ADD COLUMN IF NOT EXISTS transform JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+-- Migration: 019_add_audit_event_details.up.sql
+-- What an audited call accessed, e.g. the value and matches of an admin search.
+
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS details JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+-- Migration: 020_add_tenant_debug_logging.up.sql
+-- Verbose request logging and query tracing switched on for one tenant until a deadline.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS debug_scopes TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS debug_until TIMESTAMPTZ;
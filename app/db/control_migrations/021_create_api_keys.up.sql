@@ -0,0 +1,18 @@
+-- Migration: 021_create_api_keys.up.sql
+-- Scoped API keys: bearer credentials that run as a subject and may only
+-- call the methods of their scope (read, write, export or admin).
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           UUID PRIMARY KEY,
+    token_hash   TEXT NOT NULL UNIQUE,        -- sha256 of the bearer key; the key itself is never stored
+    name         VARCHAR(255) NOT NULL,
+    subject_id   TEXT NOT NULL,               -- caller the key runs as
+    scope        TEXT NOT NULL,               -- 'read', 'write', 'export' or 'admin'
+    tenant_ids   TEXT[] NOT NULL DEFAULT '{}', -- tenants the key may access; empty = any
+    created_by   TEXT NOT NULL DEFAULT '',
+    expires_at   TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_subject ON api_keys(subject_id, created_at);
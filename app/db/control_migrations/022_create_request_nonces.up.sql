@@ -0,0 +1,12 @@
+-- Migration: 022_create_request_nonces.up.sql
+-- Nonces of signed requests, kept until their timestamp leaves the accepted
+-- window, so a captured request cannot be replayed (on any server).
+
+CREATE TABLE IF NOT EXISTS request_nonces (
+    key_id     TEXT NOT NULL,
+    nonce      TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (key_id, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_nonces_expires ON request_nonces(expires_at);
@@ -0,0 +1,7 @@
+-- Migration: 023_add_tenant_region.up.sql
+-- Data residency: the region a tenant's data must be stored in (empty for
+-- tenants without a region, which live on the default database server), and
+-- the region of the server each tenant database was created on.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS region TEXT NOT NULL DEFAULT '';
+ALTER TABLE tenant_databases ADD COLUMN IF NOT EXISTS region TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- Migration: 024_create_tenant_dual_writes.up.sql
+-- Backend migrations: the second database a tenant's writes are mirrored to
+-- while it is moved to a new storage backend (one row per tenant in dual-write mode).
+
+CREATE TABLE IF NOT EXISTS tenant_dual_writes (
+    tenant_id     UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    database_name TEXT NOT NULL,
+    started_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+-- Migration: 025_add_tenant_database_shard.up.sql
+-- Sharding: the shard (database server) each tenant database lives on; the
+-- default shard is DB_HOST, or the tenant's region server (see app/db/sharding.py).
+
+ALTER TABLE tenant_databases ADD COLUMN IF NOT EXISTS shard TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_tenant_databases_shard ON tenant_databases(shard);
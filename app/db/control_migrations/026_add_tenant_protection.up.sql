@@ -0,0 +1,5 @@
+-- Migration: 026_add_tenant_protection.up.sql
+-- Delete protection: a protected tenant is only deleted when the delete is
+-- forced by an administrator.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS protected BOOLEAN NOT NULL DEFAULT FALSE;
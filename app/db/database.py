@@ -12,6 +12,9 @@ from typing import Optional
 import asyncpg
 
 from app.config import Config
+from app.db.labels import pool_options
+from app.db.priority import prioritized_pool
+from app.db.tracing import trace_queries
 
 logger = logging.getLogger(__name__)
 
@@ -49,12 +52,14 @@ async def connect(cfg: Config) -> Database:
             min_size=1,
             max_size=10,
             ssl=ssl_context,
+            init=trace_queries,
+            **pool_options(cfg.db_application_name),
         )
         # Test the connection
         async with pool.acquire() as conn:
             await conn.execute("SELECT 1")
         
-        return Database(pool)
+        return Database(prioritized_pool(pool))
     except Exception as e:
         raise Exception(f"Failed to connect to database: {e}") from e
 
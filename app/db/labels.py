@@ -0,0 +1,54 @@
+"""
+Connection labels for database observability.
+
+Database sessions carry the tenant and JSON-RPC method they are serving in
+their application_name, e.g. ``flexdb:6f1c2a7e-...:search_nodes``, so
+pg_stat_activity and the server log (with %a in log_line_prefix, for slow
+query logging) attribute load to tenants.
+
+The JSON-RPC layer sets the label of a call in a context variable; pools
+created with pool_options relabel a connection as it is acquired when its
+label differs, which costs a round trip only when the tenant or method
+changes. Background work started by a call inherits its label, other
+background work uses the bare application name.
+"""
+
+import contextvars
+from typing import Any, Dict, Tuple
+
+# PostgreSQL truncates application_name to NAMEDATALEN - 1 bytes
+MAX_LABEL_BYTES = 63
+
+_label: contextvars.ContextVar[Tuple[str, str]] = contextvars.ContextVar("db_label", default=("", ""))
+
+
+def set_label(tenant_id: str, method: str) -> contextvars.Token:
+    """Label the connections of the current call; returns a token for reset_label."""
+    return _label.set((tenant_id, method))
+
+
+def reset_label(token: contextvars.Token) -> None:
+    """Restore the previous label."""
+    _label.reset(token)
+
+
+def connection_label(application_name: str) -> str:
+    """Return the application_name for the current call's connections."""
+    tenant_id, method = _label.get()
+    label = ":".join(p for p in (application_name, tenant_id, method) if p)
+    # The tenant is kept whole; a long method name is cut short
+    return label.encode()[:MAX_LABEL_BYTES].decode(errors="ignore")
+
+
+def pool_options(application_name: str) -> Dict[str, Any]:
+    """Return asyncpg.create_pool options labeling connections (none if application_name is empty)."""
+    if not application_name:
+        return {}
+
+    async def setup(conn: Any) -> None:
+        label = connection_label(application_name)
+        # application_name is reported by the server, so the current value is known without a query
+        if getattr(conn.get_settings(), "application_name", None) != label:
+            await conn.execute("SELECT set_config('application_name', $1, false)", label)
+
+    return {"setup": setup, "server_settings": {"application_name": application_name}}
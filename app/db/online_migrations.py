@@ -0,0 +1,479 @@
+"""
+Online (zero-downtime) schema migrations.
+
+A migration file is normally run as-is in one transaction, so every lock it
+takes is held until it commits: an index build blocks writes to its table
+for as long as it takes, and adding a NOT NULL constraint or a column with
+a volatile default scans or rewrites the table under an ACCESS EXCLUSIVE
+lock that blocks reads as well.
+
+plan_migration() splits a migration into statements and looks for such
+lock-heavy operations on tables the migration does not itself create
+(new tables are empty). The ones with a known online equivalent are
+rewritten into steps that only lock briefly:
+
+- ``CREATE INDEX`` becomes ``CREATE INDEX CONCURRENTLY`` (an invalid index
+  left by an earlier failed build is dropped first)
+- ``ADD CONSTRAINT ... CHECK/FOREIGN KEY`` is added ``NOT VALID`` and then
+  validated, which does not block writes
+- ``ALTER COLUMN ... SET NOT NULL`` is preceded by a validated
+  ``CHECK (column IS NOT NULL)``, so setting it does not scan the table
+- ``ADD COLUMN`` with a volatile default (``gen_random_uuid()``, serial
+  types, ...) adds the column without it, sets the default for new rows,
+  backfills existing rows in batches and then sets NOT NULL as above
+
+Operations without one (column type changes, NOT NULL columns without a
+default, VACUUM FULL, ...) block the migration. A file that accepts the
+locking says so with a ``-- migration: allow-locking`` comment.
+
+Online steps run outside the migration's transaction, so a migration
+interrupted part way is run again from the start: its statements must be
+re-runnable (``IF NOT EXISTS``), as the rewritten steps are. Every step
+runs with a lock timeout, so a step waiting behind a long transaction
+fails (and is retried) instead of queueing every other query behind it.
+
+Print the plan of migration files with:
+
+    python -m app.db.online_migrations --plan [FILE ...]
+
+(every control and tenant migration when no file is given); ``--check``
+only lists the findings and exits with status 1 if a migration is blocked.
+"""
+
+import argparse
+import asyncio
+import logging
+import re
+import sys
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Awaitable, Callable, List, Optional, Set
+
+import asyncpg
+
+logger = logging.getLogger(__name__)
+
+MIGRATION_MODES = ("direct", "online")
+ALLOW_LOCKING = re.compile(r"--\s*migration:\s*allow-locking", re.IGNORECASE)
+
+DEFAULT_LOCK_TIMEOUT_MS = 5000
+DEFAULT_BACKFILL_BATCH_SIZE = 1000
+# Attempts of a step that timed out waiting for a lock
+LOCK_ATTEMPTS = 3
+
+# Column defaults evaluated per row, which make ADD COLUMN rewrite the table
+_VOLATILE_DEFAULT = re.compile(
+    r"\b(gen_random_uuid|uuid_generate_v[14]|random|clock_timestamp|timeofday|nextval)\s*\(", re.IGNORECASE
+)
+_SERIAL_TYPE = re.compile(r"^(small|big)?serial\d?\b", re.IGNORECASE)
+# Keywords following the type and default of a column definition
+_COLUMN_CONSTRAINTS = r"NOT\s+NULL|NULL|CONSTRAINT|CHECK|REFERENCES|UNIQUE|PRIMARY\s+KEY|COLLATE|GENERATED"
+_NAME = r'(?:"[^"]+"|[\w$]+)(?:\.(?:"[^"]+"|[\w$]+))?'
+
+
+@dataclass
+class Step:
+    """One statement of a migration plan."""
+    sql: str
+    # False: run on its own, outside the migration's transaction (concurrent builds, validation, backfills)
+    transactional: bool = True
+    # Repeated until it updates no rows
+    batched: bool = False
+    # Index dropped first if an earlier concurrent build left it invalid
+    invalid_index: str = ""
+
+
+@dataclass
+class Finding:
+    """A lock-heavy statement: rewritten into online steps, or blocking the migration."""
+    statement: str
+    problem: str
+    rewritten: bool
+
+
+@dataclass
+class MigrationPlan:
+    """The steps a migration runs as in online mode, and what was found along the way."""
+    version: str
+    steps: List[Step] = field(default_factory=list)
+    findings: List[Finding] = field(default_factory=list)
+    allow_locking: bool = False
+
+    @property
+    def blocked(self) -> bool:
+        """Whether the migration has lock-heavy statements without an online equivalent, and does not allow them."""
+        return not self.allow_locking and any(not f.rewritten for f in self.findings)
+
+    def render(self) -> str:
+        """Describe the plan for people: findings, then numbered steps."""
+        lines = [f"{self.version}" + (" (BLOCKED)" if self.blocked else "")]
+        for f in self.findings:
+            action = "rewritten" if f.rewritten else ("allowed" if self.allow_locking else "blocking")
+            lines.append(f"  ! [{action}] {_shorten(f.statement)}")
+            lines.append(f"      {f.problem}")
+        for i, step in enumerate(self.steps, 1):
+            kind = "batched" if step.batched else ("transaction" if step.transactional else "online")
+            lines.append(f"  {i}. [{kind}] {step.sql}")
+        return "\n".join(lines)
+
+
+class MigrationSafetyError(Exception):
+    """Raised when an online migration has lock-heavy statements without an online equivalent."""
+
+    def __init__(self, plan: MigrationPlan):
+        problems = "; ".join(f"{_shorten(f.statement)}: {f.problem}" for f in plan.findings if not f.rewritten)
+        super().__init__(
+            f"migration {plan.version} would hold heavy locks ({problems}); "
+            "make it online or mark it with -- migration: allow-locking"
+        )
+        self.plan = plan
+
+
+def split_statements(sql: str) -> List[str]:
+    """Split SQL into statements, dropping comments; quoted and dollar-quoted text is kept intact."""
+    statements: List[str] = []
+    current: List[str] = []
+    i, n = 0, len(sql)
+    while i < n:
+        if sql.startswith("--", i):
+            end = sql.find("\n", i)
+            i = n if end < 0 else end
+        elif sql.startswith("/*", i):
+            end = sql.find("*/", i + 2)
+            i = n if end < 0 else end + 2
+            current.append(" ")
+        elif sql[i] in ("'", '"'):
+            end = i + 1
+            while end < n:
+                if sql[end] == sql[i]:
+                    if end + 1 < n and sql[end + 1] == sql[i]:
+                        end += 2
+                        continue
+                    break
+                end += 1
+            current.append(sql[i:end + 1])
+            i = end + 1
+        elif sql[i] == "$" and re.match(r"\$[A-Za-z_]*\$", sql[i:]):
+            tag = re.match(r"\$[A-Za-z_]*\$", sql[i:]).group(0)
+            end = sql.find(tag, i + len(tag))
+            end = n if end < 0 else end + len(tag)
+            current.append(sql[i:end])
+            i = end
+        elif sql[i] == ";":
+            statements.append("".join(current))
+            current = []
+            i += 1
+        else:
+            current.append(sql[i])
+            i += 1
+    statements.append("".join(current))
+    return [s.strip() for s in statements if s.strip()]
+
+
+def plan_migration(version: str, sql: str, batch_size: int = DEFAULT_BACKFILL_BATCH_SIZE) -> MigrationPlan:
+    """Plan a migration's online steps (see the module docstring)."""
+    plan = MigrationPlan(version=version, allow_locking=bool(ALLOW_LOCKING.search(sql)))
+    new_tables: Set[str] = set()
+    for statement in split_statements(sql):
+        _plan_statement(plan, statement, new_tables, batch_size)
+    return plan
+
+
+def _plan_statement(plan: MigrationPlan, statement: str, new_tables: Set[str], batch_size: int) -> None:
+    flat = " ".join(statement.split())
+
+    m = re.match(rf"CREATE\s+(?:UNLOGGED\s+|TEMP(?:ORARY)?\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?({_NAME})", flat, re.I)
+    if m:
+        new_tables.add(_table_key(m.group(1)))
+        plan.steps.append(Step(statement))
+        return
+
+    m = re.match(
+        rf"CREATE\s+(UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?({_NAME}\s+)?ON\s+(?:ONLY\s+)?({_NAME})(.*)$",
+        flat, re.I,
+    )
+    if m:
+        unique, concurrently, name, table, rest = m.groups()
+        if concurrently:
+            plan.steps.append(Step(statement, transactional=False, invalid_index=(name or "").strip()))
+        elif _table_key(table) in new_tables:
+            plan.steps.append(Step(statement))
+        else:
+            name = (name or "").strip()
+            plan.findings.append(Finding(flat, f"builds the index while blocking writes to {table}", True))
+            plan.steps.append(Step(
+                f"CREATE {unique or ''}INDEX CONCURRENTLY {'IF NOT EXISTS ' + name + ' ' if name else ''}"
+                f"ON {table}{rest}",
+                transactional=False,
+                invalid_index=name,
+            ))
+        return
+
+    m = re.match(rf"ALTER\s+TABLE\s+(IF\s+EXISTS\s+)?(ONLY\s+)?({_NAME})\s+(.*)$", flat, re.I)
+    if m and _table_key(m.group(3)) not in new_tables and not re.match(r"RENAME\b", m.group(4), re.I):
+        prefix = f"ALTER TABLE {m.group(1) or ''}{m.group(2) or ''}{m.group(3)}"
+        for action in _split_top_level(m.group(4)):
+            _plan_alter(plan, prefix, m.group(3), action, batch_size)
+        return
+
+    m = re.match(r"(VACUUM\s+(?:\(\s*)?FULL|CLUSTER|REINDEX(?!.*\bCONCURRENTLY\b)|LOCK\s+TABLE)\b", flat, re.I)
+    if m:
+        plan.findings.append(Finding(flat, f"{m.group(1).upper()} holds an exclusive lock for its whole run", False))
+    else:
+        m = re.match(rf"(UPDATE|DELETE\s+FROM)\s+(?:ONLY\s+)?({_NAME})(.*)$", flat, re.I)
+        if m and _table_key(m.group(2)) not in new_tables and not re.search(r"\bWHERE\b", m.group(3), re.I):
+            plan.findings.append(Finding(
+                flat, f"changes every row of {m.group(2)} in one transaction; backfill it in batches instead", False
+            ))
+    plan.steps.append(Step(statement))
+
+
+def _plan_alter(plan: MigrationPlan, prefix: str, table: str, action: str, batch_size: int) -> None:
+    statement = f"{prefix} {action}"
+
+    if re.match(r"ADD\s+(CONSTRAINT\s+\S+\s+)?(PRIMARY\s+KEY|UNIQUE|EXCLUDE)\b", action, re.I) and not re.search(
+        r"\bUSING\s+INDEX\b", action, re.I
+    ):
+        plan.findings.append(Finding(
+            statement,
+            f"builds an index while holding an ACCESS EXCLUSIVE lock on {table}; "
+            "create a unique index CONCURRENTLY and add the constraint USING INDEX",
+            False,
+        ))
+        plan.steps.append(Step(statement))
+        return
+
+    m = re.match(r"ADD\s+(?:CONSTRAINT\s+(\S+)\s+)?(CHECK|FOREIGN\s+KEY)\b(.*)$", action, re.I)
+    if m:
+        name, kind, rest = m.groups()
+        if re.search(r"\bNOT\s+VALID\s*$", action, re.I):
+            plan.steps.append(Step(statement))
+        elif not name:
+            plan.findings.append(Finding(
+                statement, f"checks every row of {table} while blocking writes; name the constraint so it can be "
+                "added NOT VALID and validated online", False,
+            ))
+            plan.steps.append(Step(statement))
+        else:
+            plan.findings.append(Finding(statement, f"checks every row of {table} while blocking writes", True))
+            plan.steps.append(Step(f"{prefix} DROP CONSTRAINT IF EXISTS {name}"))
+            plan.steps.append(Step(f"{prefix} ADD CONSTRAINT {name} {kind}{rest} NOT VALID"))
+            plan.steps.append(Step(f"{prefix} VALIDATE CONSTRAINT {name}", transactional=False))
+        return
+
+    m = re.match(r"ALTER\s+(?:COLUMN\s+)?(\S+)\s+SET\s+NOT\s+NULL$", action, re.I)
+    if m:
+        plan.findings.append(Finding(statement, f"scans {table} under an ACCESS EXCLUSIVE lock", True))
+        plan.steps.extend(_set_not_null_steps(prefix, table, m.group(1)))
+        return
+
+    m = re.match(r"ALTER\s+(?:COLUMN\s+)?(\S+)\s+(?:SET\s+DATA\s+)?TYPE\b", action, re.I)
+    if m:
+        plan.findings.append(Finding(
+            statement, f"rewrites {table} and its indexes under an ACCESS EXCLUSIVE lock; add a new column, "
+            "backfill it in batches and switch over instead", False,
+        ))
+        plan.steps.append(Step(statement))
+        return
+
+    if re.match(r"SET\s+(TABLESPACE|LOGGED|UNLOGGED)\b", action, re.I):
+        plan.findings.append(Finding(statement, f"rewrites {table} under an ACCESS EXCLUSIVE lock", False))
+        plan.steps.append(Step(statement))
+        return
+
+    m = re.match(r"ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(\S+)\s+(.*)$", action, re.I)
+    if m and not re.match(r"(CONSTRAINT|CHECK|FOREIGN|PRIMARY|UNIQUE|EXCLUDE)$", m.group(1), re.I):
+        _plan_add_column(plan, prefix, table, statement, m.group(1), m.group(2), batch_size)
+        return
+
+    plan.steps.append(Step(statement))
+
+
+def _plan_add_column(
+    plan: MigrationPlan, prefix: str, table: str, statement: str, column: str, definition: str, batch_size: int
+) -> None:
+    default_match = re.search(rf"\bDEFAULT\s+(.+?)(?=\s+(?:{_COLUMN_CONSTRAINTS})\b|$)", definition, re.I)
+    default = default_match.group(1).strip() if default_match else ""
+    not_null = bool(re.search(r"\bNOT\s+NULL\b", definition, re.I))
+    type_end = re.search(rf"\s+(?:DEFAULT|{_COLUMN_CONSTRAINTS})\b", definition, re.I)
+    column_type = definition[:type_end.start()] if type_end else definition
+
+    serial = _SERIAL_TYPE.match(column_type)
+    if re.search(r"\bGENERATED\s+ALWAYS\s+AS\s*\(", definition, re.I):
+        plan.findings.append(Finding(statement, f"computes the column for every row of {table} under an "
+                                     "ACCESS EXCLUSIVE lock", False))
+        plan.steps.append(Step(statement))
+    elif serial or (default and _VOLATILE_DEFAULT.search(default)):
+        if serial:
+            plan.findings.append(Finding(
+                statement, f"serial columns fill every row of {table} under an ACCESS EXCLUSIVE lock; "
+                "create a sequence and add the column with DEFAULT nextval(...) instead", False,
+            ))
+            plan.steps.append(Step(statement))
+            return
+        plan.findings.append(Finding(
+            statement, f"fills the volatile default into every row of {table} under an ACCESS EXCLUSIVE lock", True
+        ))
+        plan.steps.append(Step(f"{prefix} ADD COLUMN IF NOT EXISTS {column} {column_type}"))
+        plan.steps.append(Step(f"{prefix} ALTER COLUMN {column} SET DEFAULT {default}"))
+        plan.steps.append(Step(
+            f"UPDATE {table} SET {column} = {default} WHERE ctid = ANY(ARRAY("
+            f"SELECT ctid FROM {table} WHERE {column} IS NULL LIMIT {batch_size}))",
+            transactional=False,
+            batched=True,
+        ))
+        if not_null:
+            plan.steps.extend(_set_not_null_steps(prefix, table, column))
+    elif not_null and not default:
+        plan.findings.append(Finding(
+            statement, f"fails on a non-empty {table}; add the column nullable, backfill it and then SET NOT NULL",
+            False,
+        ))
+        plan.steps.append(Step(statement))
+    else:
+        # Constant defaults are stored once in the catalog (PostgreSQL 11+), without touching rows
+        plan.steps.append(Step(statement))
+
+
+def _set_not_null_steps(prefix: str, table: str, column: str) -> List[Step]:
+    # A validated CHECK (column IS NOT NULL) lets SET NOT NULL skip its scan (PostgreSQL 12+)
+    check = f"{_table_key(table).split('.')[-1]}_{column.strip(chr(34))}_not_null"[:63]
+    return [
+        Step(f"{prefix} DROP CONSTRAINT IF EXISTS {check}"),
+        Step(f"{prefix} ADD CONSTRAINT {check} CHECK ({column} IS NOT NULL) NOT VALID"),
+        Step(f"{prefix} VALIDATE CONSTRAINT {check}", transactional=False),
+        Step(f"{prefix} ALTER COLUMN {column} SET NOT NULL"),
+        Step(f"{prefix} DROP CONSTRAINT IF EXISTS {check}"),
+    ]
+
+
+def _split_top_level(actions: str) -> List[str]:
+    """Split the comma-separated actions of an ALTER TABLE, ignoring commas inside parentheses and quotes."""
+    parts, depth, start, quote = [], 0, 0, ""
+    for i, c in enumerate(actions):
+        if quote:
+            quote = "" if c == quote else quote
+        elif c in ("'", '"'):
+            quote = c
+        elif c == "(":
+            depth += 1
+        elif c == ")":
+            depth -= 1
+        elif c == "," and depth == 0:
+            parts.append(actions[start:i].strip())
+            start = i + 1
+    parts.append(actions[start:].strip())
+    return [p for p in parts if p]
+
+
+def _table_key(name: str) -> str:
+    name = name.replace('"', "").lower()
+    return name[len("public."):] if name.startswith("public.") else name
+
+
+def _shorten(statement: str, limit: int = 120) -> str:
+    flat = " ".join(statement.split())
+    return flat if len(flat) <= limit else flat[:limit - 3] + "..."
+
+
+async def apply_online(
+    conn: asyncpg.Connection,
+    plan: MigrationPlan,
+    record: Callable[[asyncpg.Connection], Awaitable[None]],
+    lock_timeout_ms: int = DEFAULT_LOCK_TIMEOUT_MS,
+) -> None:
+    """
+    Run a migration's plan: consecutive transactional steps together in a
+    transaction, other steps on their own. record() runs in the last
+    transaction to mark the migration applied.
+
+    Raises:
+        MigrationSafetyError: If the plan is blocked
+    """
+    if plan.blocked:
+        raise MigrationSafetyError(plan)
+
+    groups: List[List[Step]] = []
+    for step in plan.steps:
+        if step.transactional and groups and groups[-1][0].transactional:
+            groups[-1].append(step)
+        else:
+            groups.append([step])
+    if not groups or not groups[-1][0].transactional:
+        groups.append([])
+
+    await conn.execute(f"SET lock_timeout = {int(lock_timeout_ms)}")
+    try:
+        for i, group in enumerate(groups):
+            last = i == len(groups) - 1
+            for attempt in range(1, LOCK_ATTEMPTS + 1):
+                try:
+                    if group and not group[0].transactional:
+                        await _run_online_step(conn, plan.version, group[0])
+                    else:
+                        async with conn.transaction():
+                            for step in group:
+                                await conn.execute(step.sql)
+                            if last:
+                                await record(conn)
+                    break
+                except asyncpg.LockNotAvailableError:
+                    if attempt == LOCK_ATTEMPTS:
+                        raise
+                    logger.warning(f"Migration {plan.version} timed out waiting for a lock; retrying")
+                    await asyncio.sleep(attempt)
+    finally:
+        await conn.execute("RESET lock_timeout")
+
+
+async def _run_online_step(conn: asyncpg.Connection, version: str, step: Step) -> None:
+    if step.invalid_index:
+        invalid = await conn.fetchval(
+            "SELECT NOT i.indisvalid FROM pg_index i WHERE i.indexrelid = to_regclass($1)", step.invalid_index
+        )
+        if invalid:
+            logger.info(f"Migration {version}: dropping invalid index {step.invalid_index} left by a failed build")
+            await conn.execute(f"DROP INDEX CONCURRENTLY IF EXISTS {step.invalid_index}")
+    if not step.batched:
+        await conn.execute(step.sql)
+        return
+    total = 0
+    while True:
+        status = await conn.execute(step.sql)
+        updated = int(status.split()[-1])
+        if not updated:
+            break
+        total += updated
+    logger.info(f"Migration {version}: backfilled {total} rows")
+
+
+def main(argv: Optional[List[str]] = None) -> int:
+    parser = argparse.ArgumentParser(
+        prog="python -m app.db.online_migrations",
+        description="Show how migrations run in online mode, and which would hold heavy locks.",
+    )
+    parser.add_argument("files", nargs="*", help="migration files (default: every control and tenant migration)")
+    parser.add_argument("--plan", action="store_true", help="print each migration's steps (the default)")
+    parser.add_argument("--check", action="store_true", help="only list findings; exit 1 if a migration is blocked")
+    parser.add_argument("--batch-size", type=int, default=DEFAULT_BACKFILL_BATCH_SIZE, help="rows per backfill batch")
+    args = parser.parse_args(argv)
+
+    root = Path(__file__).parent
+    files = [Path(f) for f in args.files] or sorted(
+        [*(root / "control_migrations").glob("*.up.sql"), *(root / "tenant_migrations").glob("*.up.sql")]
+    )
+    blocked = False
+    for path in files:
+        plan = plan_migration(path.name.replace(".up.sql", ""), path.read_text(), args.batch_size)
+        blocked = blocked or plan.blocked
+        lines = plan.render().split("\n")
+        if args.check and not args.plan:
+            # The header and the findings (two lines each)
+            lines = lines[:1 + 2 * len(plan.findings)] if plan.findings else []
+        if lines:
+            print(f"{path.parent.name}/" + "\n".join(lines) + ("" if args.check and not args.plan else "\n"))
+    return 1 if blocked and args.check else 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,128 @@
+"""
+Priority classes of database work.
+
+Work runs as ``interactive`` (the default: user-facing calls) or ``batch``
+(bulk jobs: background operations, imports, periodic jobs, and calls that
+ask for it; see app/jsonrpc/priority.py). The class is kept in a context
+variable, so background work started by a call inherits it.
+
+Pools wrapped with prioritized_pool let batch work hold at most
+batch_pool_share of their connections; once that many are in use by batch
+work, further batch acquires wait while interactive work can still use the
+rest. Without contention both classes are served alike.
+"""
+
+import asyncio
+import contextvars
+import time
+from typing import Any, Optional, Set
+
+from app.metrics import metrics
+
+INTERACTIVE = "interactive"
+BATCH = "batch"
+# Lowest priority last
+PRIORITIES = (INTERACTIVE, BATCH)
+# Share of each pool's connections batch work may hold by default (DB_BATCH_POOL_SHARE)
+DEFAULT_BATCH_POOL_SHARE = 0.5
+
+_priority: contextvars.ContextVar[str] = contextvars.ContextVar("db_priority", default=INTERACTIVE)
+_batch_pool_share = DEFAULT_BATCH_POOL_SHARE
+
+
+def set_priority(priority: str) -> contextvars.Token:
+    """Set the priority of the current work; returns a token for reset_priority."""
+    return _priority.set(priority)
+
+
+def reset_priority(token: contextvars.Token) -> None:
+    """Restore the previous priority."""
+    _priority.reset(token)
+
+
+def current_priority() -> str:
+    """The priority of the current work."""
+    return _priority.get()
+
+
+def lowest_priority(*priorities: str) -> str:
+    """The lowest of some priorities (interactive when none are given)."""
+    return max((p for p in priorities if p), key=PRIORITIES.index, default=INTERACTIVE)
+
+
+def set_batch_pool_share(share: float) -> None:
+    """Set the share of connections batch work may hold in pools created afterwards (1 disables the limit)."""
+    global _batch_pool_share
+    if not 0 < share <= 1:
+        raise ValueError("batch pool share must be greater than 0 and at most 1")
+    _batch_pool_share = share
+
+
+def prioritized_pool(pool: Any) -> Any:
+    """Wrap a connection pool so batch work holds at most the configured share of it."""
+    if _batch_pool_share >= 1:
+        return pool
+    return PrioritizedPool(pool, max(1, int(pool.get_max_size() * _batch_pool_share)))
+
+
+class PrioritizedPool:
+    """Stand-in for an asyncpg pool that limits the connections held by batch work."""
+
+    def __init__(self, pool: Any, max_batch: int):
+        self._pool = pool
+        self.max_batch = max_batch
+        self._batch = asyncio.Semaphore(max_batch)
+        # Connections acquired by batch work, released to the semaphore with them
+        self._batch_conns: Set[int] = set()
+
+    def acquire(self, *, timeout: Optional[float] = None) -> "_Acquire":
+        """Acquire a connection, as ``async with pool.acquire()`` or ``await pool.acquire()``."""
+        return _Acquire(self, timeout)
+
+    async def release(self, conn: Any, *, timeout: Optional[float] = None) -> None:
+        """Release a connection acquired with ``await pool.acquire()``."""
+        try:
+            await self._pool.release(conn, timeout=timeout)
+        finally:
+            self._release_batch(conn)
+
+    def __getattr__(self, name: str) -> Any:
+        return getattr(self._pool, name)
+
+    async def _acquire(self, timeout: Optional[float]) -> Any:
+        if current_priority() != BATCH:
+            return await self._pool.acquire(timeout=timeout)
+        started = time.monotonic()
+        await self._batch.acquire()
+        metrics.observe("db_batch_wait_seconds", time.monotonic() - started)
+        try:
+            conn = await self._pool.acquire(timeout=timeout)
+        except BaseException:
+            self._batch.release()
+            raise
+        self._batch_conns.add(id(conn))
+        return conn
+
+    def _release_batch(self, conn: Any) -> None:
+        if id(conn) in self._batch_conns:
+            self._batch_conns.discard(id(conn))
+            self._batch.release()
+
+
+class _Acquire:
+    """The result of PrioritizedPool.acquire, usable like asyncpg's."""
+
+    def __init__(self, pool: PrioritizedPool, timeout: Optional[float]):
+        self.pool = pool
+        self.timeout = timeout
+        self.conn: Any = None
+
+    def __await__(self):
+        return self.pool._acquire(self.timeout).__await__()
+
+    async def __aenter__(self) -> Any:
+        self.conn = await self.pool._acquire(self.timeout)
+        return self.conn
+
+    async def __aexit__(self, *exc: Any) -> None:
+        await self.pool.release(self.conn)
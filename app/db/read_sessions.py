@@ -0,0 +1,169 @@
+"""
+Snapshot isolation read sessions.
+
+A read session pins a PostgreSQL snapshot so that a sequence of reads (for
+example paging through a large export) observes one consistent
+point-in-time view of a tenant database, even while writes continue.
+
+The session holds a REPEATABLE READ transaction open on a dedicated
+connection and exports its snapshot; every read in the session runs in its
+own read-only transaction that imports that snapshot. Sessions live in the
+server process that created them, so clients behind a load balancer need
+sticky routing while a session is open.
+"""
+
+import logging
+import secrets
+import time
+from contextlib import asynccontextmanager
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from typing import AsyncIterator, Dict, Optional
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.errors import NotFoundError
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_SESSION_TTL_SECONDS = 300
+DEFAULT_MAX_SESSIONS = 20
+TOKEN_PREFIX = "rs_"
+
+
+class SnapshotPool:
+    """
+    Stand-in for an asyncpg pool whose connections read from a snapshot.
+
+    Each acquired connection runs in a read-only transaction that imports
+    the snapshot, so repositories read through it unchanged; writes fail.
+    """
+
+    def __init__(self, pool: asyncpg.Pool, snapshot_id: str):
+        self._pool = pool
+        self._snapshot_id = snapshot_id
+
+    @asynccontextmanager
+    async def acquire(self) -> AsyncIterator[asyncpg.Connection]:
+        async with self._pool.acquire() as conn:
+            async with conn.transaction(isolation="repeatable_read", readonly=True):
+                # SET TRANSACTION SNAPSHOT does not accept parameters; the ID
+                # comes from pg_export_snapshot(), never from the client
+                await conn.execute(f"SET TRANSACTION SNAPSHOT '{self._snapshot_id}'")
+                yield conn
+
+
+@dataclass
+class ReadSession:
+    """An open snapshot read session."""
+    token: str
+    tenant_id: str
+    snapshot_id: str
+    created_at: datetime
+    expires_at: datetime
+    # Monotonic deadline used for expiry checks
+    deadline: float = 0.0
+    conn: Optional[asyncpg.Connection] = field(default=None, repr=False)
+    pool: Optional[asyncpg.Pool] = field(default=None, repr=False)
+
+    def database(self) -> Database:
+        """A Database whose reads observe this session's snapshot."""
+        return Database(SnapshotPool(self.pool, self.snapshot_id))
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "token": self.token,
+            "tenant_id": self.tenant_id,
+            "created_at": self.created_at.isoformat(),
+            "expires_at": self.expires_at.isoformat(),
+        }
+
+
+class ReadSessionManager:
+    """Opens, resolves and expires snapshot read sessions."""
+
+    def __init__(
+        self,
+        ttl_seconds: int = DEFAULT_SESSION_TTL_SECONDS,
+        max_sessions: int = DEFAULT_MAX_SESSIONS,
+    ):
+        self.ttl_seconds = ttl_seconds
+        self.max_sessions = max_sessions
+        self._sessions: Dict[str, ReadSession] = {}
+
+    async def begin(self, tenant_id: str, db: Database, ttl_seconds: int = 0) -> ReadSession:
+        """
+        Pin the current state of a tenant database and return the session.
+
+        Raises:
+            ValueError: If ttl_seconds is out of range or too many sessions are open
+        """
+        ttl_seconds = ttl_seconds or self.ttl_seconds
+        if ttl_seconds < 1 or ttl_seconds > self.ttl_seconds:
+            raise ValueError(f"ttl_seconds must be between 1 and {self.ttl_seconds}")
+        await self.expire()
+        if len(self._sessions) >= self.max_sessions:
+            raise ValueError(f"too many open read sessions (at most {self.max_sessions}); end unused sessions")
+
+        conn = await db.pool.acquire()
+        try:
+            await conn.execute("BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY")
+            snapshot_id = await conn.fetchval("SELECT pg_export_snapshot()")
+        except Exception:
+            await db.pool.release(conn)
+            raise
+
+        now = datetime.now(timezone.utc)
+        session = ReadSession(
+            token=TOKEN_PREFIX + secrets.token_urlsafe(24),
+            tenant_id=tenant_id,
+            snapshot_id=snapshot_id,
+            created_at=now,
+            expires_at=datetime.fromtimestamp(now.timestamp() + ttl_seconds, timezone.utc),
+            deadline=time.monotonic() + ttl_seconds,
+            conn=conn,
+            pool=db.pool,
+        )
+        self._sessions[session.token] = session
+        return session
+
+    def get(self, token: str, tenant_id: str) -> ReadSession:
+        """
+        Resolve an open session of a tenant.
+
+        Raises:
+            NotFoundError: If the session is unknown, expired or belongs to another tenant
+        """
+        session = self._sessions.get(token)
+        if not session or session.tenant_id != tenant_id or time.monotonic() >= session.deadline:
+            raise NotFoundError("read session not found or expired; begin a new one")
+        return session
+
+    async def end(self, token: str, tenant_id: str) -> None:
+        """Close a session and release its snapshot."""
+        session = self.get(token, tenant_id)
+        await self._close(session)
+
+    async def expire(self) -> int:
+        """Close sessions past their deadline; returns how many were closed."""
+        now = time.monotonic()
+        expired = [s for s in self._sessions.values() if now >= s.deadline]
+        for session in expired:
+            await self._close(session)
+        return len(expired)
+
+    async def close_all(self) -> None:
+        """Close every open session (on shutdown)."""
+        for session in list(self._sessions.values()):
+            await self._close(session)
+
+    async def _close(self, session: ReadSession) -> None:
+        self._sessions.pop(session.token, None)
+        try:
+            await session.conn.execute("ROLLBACK")
+        except Exception as e:
+            logger.warning(f"Failed to end read session transaction: {e}")
+        finally:
+            await session.pool.release(session.conn)
@@ -0,0 +1,167 @@
+"""
+Data residency routing.
+
+Tenants may be pinned to a region (such as "eu" or "us"); their databases
+are then created on, and only opened from, that region's PostgreSQL server.
+Regions are configured as DB_REGIONS, e.g.
+``eu=pg-eu.internal:5432,us=pg-us.internal``; servers share the DB_USER,
+DB_PASSWORD and DB_SSL_MODE credentials. DB_HOST is the server of
+DEFAULT_REGION and of tenants without a region.
+
+Residency is enforced on three levels before any repository gets a
+connection to a tenant database (see TenantDatabaseManager):
+
+- A tenant's database is created on its region's server, and the region is
+  recorded with the database mapping in the control database.
+- A mapping recorded for another region than the tenant's is refused.
+- Each tenant database stores a region marker (the data_residency table),
+  and servers may declare their region with the ``flexdb.region`` setting
+  (``ALTER SYSTEM SET flexdb.region = 'eu'``), so a server address pointing
+  at another region's server is refused too. Declaring it is recommended:
+  it is also checked before a tenant database is created.
+
+Refusals raise DataResidencyError before any repository uses the database.
+"""
+
+from typing import Dict, Optional, Tuple
+
+import asyncpg
+
+from app.repository.errors import DataResidencyError
+
+DEFAULT_PORT = 5432
+
+
+def parse_regions(spec: str, setting: str = "DB_REGIONS", key: str = "region") -> Dict[str, Tuple[str, int]]:
+    """
+    Parse ``region=host[:port]`` pairs separated by commas (also used for
+    DB_SHARDS, whose pairs name shards).
+
+    Raises:
+        ValueError: If a pair is malformed or a region is given twice
+    """
+    regions: Dict[str, Tuple[str, int]] = {}
+    for item in spec.split(","):
+        if not item.strip():
+            continue
+        region, sep, address = item.partition("=")
+        region, address = region.strip(), address.strip()
+        host, _, port = address.partition(":")
+        if not sep or not region or not host or (port and not port.isdigit()):
+            raise ValueError(f"invalid {setting} entry {item.strip()!r} (expected {key}=host[:port])")
+        if region in regions:
+            raise ValueError(f"{key} {region!r} is given twice in {setting}")
+        regions[region] = (host, int(port) if port else DEFAULT_PORT)
+    return regions
+
+
+class RegionRouter:
+    """Maps tenant regions to the database server their data is stored on."""
+
+    def __init__(
+        self,
+        default_host: str,
+        default_port: int,
+        regions: Optional[Dict[str, Tuple[str, int]]] = None,
+        default_region: str = "",
+    ):
+        self.default_region = default_region
+        self._servers = dict(regions or {})
+        if default_region:
+            self._servers.setdefault(default_region, (default_host, default_port))
+        self._default = (default_host, default_port)
+
+    @property
+    def regions(self) -> Tuple[str, ...]:
+        """Configured regions, sorted."""
+        return tuple(sorted(self._servers))
+
+    def check_region(self, region: str) -> str:
+        """
+        Validate a tenant region ("" selects DEFAULT_REGION); returns it.
+
+        Raises:
+            ValueError: If the region is not configured
+        """
+        region = region or self.default_region
+        if region and region not in self._servers:
+            configured = ", ".join(self.regions) or "none"
+            raise ValueError(f"region must be one of the configured regions ({configured})")
+        return region
+
+    def server(self, region: str) -> Tuple[str, int]:
+        """
+        The (host, port) of a region's database server.
+
+        Raises:
+            DataResidencyError: If the region is no longer configured
+        """
+        if not region:
+            return self._default
+        if region not in self._servers:
+            raise DataResidencyError(
+                f"no database server is configured for region {region!r}", tenant_region=region
+            )
+        return self._servers[region]
+
+
+def check_mapping(tenant_id: str, tenant_region: str, database_region: str) -> None:
+    """
+    Refuse a tenant database mapping recorded for another region.
+
+    Raises:
+        DataResidencyError: If the regions differ
+    """
+    if tenant_region != database_region:
+        raise DataResidencyError(
+            f"tenant {tenant_id} must be stored in region {tenant_region or 'default'}, "
+            f"but its database is in region {database_region or 'default'}",
+            tenant_region=tenant_region,
+            database_region=database_region,
+        )
+
+
+async def check_server(conn: asyncpg.Connection, tenant_id: str, region: str) -> None:
+    """
+    Check the region a database server declares, if it declares one.
+
+    Raises:
+        DataResidencyError: If the server declares another region
+    """
+    if not region:
+        return
+    declared = await conn.fetchval("SELECT current_setting('flexdb.region', true)")
+    if declared and declared != region:
+        raise DataResidencyError(
+            f"tenant {tenant_id} must be stored in region {region}, but the database server "
+            f"is in region {declared}; check DB_REGIONS",
+            tenant_region=region,
+            database_region=declared,
+        )
+
+
+async def check_marker(conn: asyncpg.Connection, tenant_id: str, region: str) -> None:
+    """
+    Check a tenant database's server and region marker, writing the marker on first use.
+
+    Raises:
+        DataResidencyError: If the server or database is in another region
+    """
+    if not region:
+        return
+    await check_server(conn, tenant_id, region)
+    marked = await conn.fetchval(
+        """
+        INSERT INTO data_residency (region) VALUES ($1)
+        ON CONFLICT (id) DO UPDATE SET region = data_residency.region
+        RETURNING region
+        """,
+        region,
+    )
+    if marked != region:
+        raise DataResidencyError(
+            f"the database of tenant {tenant_id} is marked for region {marked}, not {region}; "
+            f"check DB_REGIONS",
+            tenant_region=region,
+            database_region=marked,
+        )
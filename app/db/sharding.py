@@ -0,0 +1,243 @@
+"""
+Tenant sharding across PostgreSQL servers.
+
+Tenants that are not pinned to a region of their own (see residency.py)
+are spread over several database servers, the shards. DB_HOST is the
+"default" shard, and DB_SHARDS adds others, e.g.
+``s2=pg-2.internal:5432,s3=pg-3.internal``; shards share the DB_USER,
+DB_PASSWORD and DB_SSL_MODE credentials.
+
+A new tenant's database is placed by consistent hashing: the tenant ID is
+looked up on a ring of the shards (each at DEFAULT_VNODES points), skipping
+shards whose last health check failed. The shard is recorded with the
+tenant's database mapping in the control database, which is the shard map:
+configuring another shard changes where new tenants are placed, not where
+existing ones are. TenantDatabaseManager.move_tenant_database moves a
+tenant to another shard, and rebalancing plans list the tenants whose
+shard differs from their ring placement.
+"""
+
+import asyncio
+import bisect
+import hashlib
+from dataclasses import dataclass, field
+from datetime import datetime
+from typing import Callable, Dict, Iterable, List, Optional, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+
+DEFAULT_SHARD = "default"
+DEFAULT_VNODES = 64
+
+# Tables a tenant database's migrations fill, which a move does not copy
+_NOT_COPIED = ("schema_migrations", "data_residency")
+# COPY chunks buffered between the source and target of a table copy
+_COPY_BUFFER_CHUNKS = 16
+
+
+def _hash(key: str) -> int:
+    return int.from_bytes(hashlib.sha256(key.encode()).digest()[:8], "big")
+
+
+class HashRing:
+    """Consistent hash ring: each key belongs to the first member point at or after its hash."""
+
+    def __init__(self, members: Iterable[str], vnodes: int = DEFAULT_VNODES):
+        points = sorted((_hash(f"{member}#{i}"), member) for member in members for i in range(vnodes))
+        self._hashes = [h for h, _ in points]
+        self._members = [m for _, m in points]
+
+    def lookup(self, key: str, available: Optional[Callable[[str], bool]] = None) -> str:
+        """
+        The member a key belongs to. With available, unavailable members are
+        skipped (the key goes to the next member on the ring); when none is
+        available, the key's own member is returned.
+        """
+        if not self._hashes:
+            raise ValueError("the hash ring has no members")
+        start = bisect.bisect_left(self._hashes, _hash(key)) % len(self._hashes)
+        owner = self._members[start]
+        if available is None or available(owner):
+            return owner
+        for i in range(1, len(self._members)):
+            member = self._members[(start + i) % len(self._members)]
+            if available(member):
+                return member
+        return owner
+
+
+@dataclass
+class ShardHealth:
+    """Result of a shard's last health check."""
+    shard: str
+    healthy: bool = True
+    latency_ms: float = 0.0
+    error: str = ""
+    checked_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "healthy": self.healthy,
+            "latency_ms": round(self.latency_ms, 1),
+            "error": self.error,
+            "checked_at": self.checked_at.isoformat(),
+        }
+
+
+class ShardRouter:
+    """Maps shards to their database servers and places tenants on them."""
+
+    def __init__(
+        self,
+        default_host: str,
+        default_port: int,
+        shards: Optional[Dict[str, Tuple[str, int]]] = None,
+        vnodes: int = DEFAULT_VNODES,
+    ):
+        shards = dict(shards or {})
+        if DEFAULT_SHARD in shards:
+            raise ValueError(f"shard name {DEFAULT_SHARD!r} is reserved for DB_HOST")
+        self._servers = {DEFAULT_SHARD: (default_host, default_port), **shards}
+        self.ring = HashRing(self._servers, vnodes)
+        # Last health check of each shard; unchecked shards count as healthy
+        self.health: Dict[str, ShardHealth] = {}
+
+    @property
+    def shards(self) -> Tuple[str, ...]:
+        """Configured shards, the default shard first."""
+        return (DEFAULT_SHARD,) + tuple(sorted(s for s in self._servers if s != DEFAULT_SHARD))
+
+    def check_shard(self, shard: str) -> str:
+        """
+        Validate a shard name; returns it.
+
+        Raises:
+            ValueError: If the shard is not configured
+        """
+        if shard not in self._servers:
+            raise ValueError(f"shard must be one of the configured shards ({', '.join(self.shards)})")
+        return shard
+
+    def server(self, shard: str) -> Tuple[str, int]:
+        """
+        The (host, port) of a shard's database server.
+
+        Raises:
+            RuntimeError: If the shard is no longer configured
+        """
+        if shard not in self._servers:
+            raise RuntimeError(f"no database server is configured for shard {shard!r}; check DB_SHARDS")
+        return self._servers[shard]
+
+    def is_healthy(self, shard: str) -> bool:
+        """Whether a shard passed its last health check (or has not been checked)."""
+        health = self.health.get(shard)
+        return health is None or health.healthy
+
+    def place(self, tenant_id: str) -> str:
+        """The shard a tenant belongs on: its ring placement, or the next healthy shard."""
+        return self.ring.lookup(tenant_id, self.is_healthy)
+
+    def home(self, tenant_id: str) -> str:
+        """A tenant's ring placement, regardless of health (where rebalancing moves it)."""
+        return self.ring.lookup(tenant_id)
+
+
+async def copy_database(source: Database, target: Database) -> Dict[str, int]:
+    """
+    Copy a tenant database's rows into another database with its schema,
+    replacing the target's rows; returns the rows copied per table.
+
+    The source is read in one snapshot. The target is written in one
+    transaction with triggers off (session_replication_role = replica,
+    which needs a superuser), so foreign keys and change tracking
+    triggers do not fire. Transaction IDs recorded in rows (xid8 columns)
+    belong to the source server, so they are set to the copy's.
+
+    Raises:
+        RuntimeError: If the target lacks a table or column of the source
+    """
+    copied: Dict[str, int] = {}
+    async with source.pool.acquire() as src, target.pool.acquire() as dst:
+        async with src.transaction(isolation="repeatable_read", readonly=True):
+            columns = await _table_columns(src)
+            target_columns = await _table_columns(dst)
+            for table, names in columns.items():
+                missing = set(names) - set(target_columns.get(table, []))
+                if missing:
+                    raise RuntimeError(f"the target database lacks {table}.{sorted(missing)[0]}; migrate it first")
+
+            async with dst.transaction():
+                await dst.execute("SET LOCAL session_replication_role = replica")
+                if target_columns:
+                    await dst.execute(f"TRUNCATE {', '.join(_quote(t) for t in target_columns)}")
+                for table, names in columns.items():
+                    copied[table] = await _copy_table(src, dst, table, names)
+
+                for row in await dst.fetch(
+                    "SELECT table_name, column_name FROM information_schema.columns "
+                    "WHERE table_schema = 'public' AND data_type = 'xid8'"
+                ):
+                    await dst.execute(
+                        f"UPDATE {_quote(row[0])} SET {_quote(row[1])} = pg_current_xact_id()"
+                    )
+                for name, last_value in await src.fetch(
+                    "SELECT sequencename, last_value FROM pg_sequences WHERE schemaname = 'public'"
+                ):
+                    if last_value is not None:
+                        await dst.execute("SELECT setval($1::regclass, $2)", _quote(name), last_value)
+    return copied
+
+
+async def _table_columns(conn: asyncpg.Connection) -> Dict[str, List[str]]:
+    """The copied tables of a tenant database with their stored (not generated) columns, in order."""
+    rows = await conn.fetch(
+        """
+        SELECT c.relname, a.attname
+        FROM pg_class c
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped AND a.attgenerated = ''
+        WHERE n.nspname = 'public' AND c.relkind = 'r' AND c.relname <> ALL($1::text[])
+        ORDER BY c.relname, a.attnum
+        """,
+        list(_NOT_COPIED),
+    )
+    columns: Dict[str, List[str]] = {}
+    for table, column in rows:
+        columns.setdefault(table, []).append(column)
+    return columns
+
+
+async def _copy_table(src: asyncpg.Connection, dst: asyncpg.Connection, table: str, columns: List[str]) -> int:
+    """Stream a table's rows from one connection into another with COPY."""
+    chunks: asyncio.Queue = asyncio.Queue(maxsize=_COPY_BUFFER_CHUNKS)
+
+    async def produce() -> str:
+        try:
+            return await src.copy_from_table(table, columns=columns, output=chunks.put)
+        finally:
+            await chunks.put(None)
+
+    async def consume():
+        while True:
+            chunk = await chunks.get()
+            if chunk is None:
+                return
+            yield chunk
+
+    producer = asyncio.get_running_loop().create_task(produce())
+    try:
+        status = await dst.copy_to_table(table, columns=columns, source=consume())
+    except BaseException:
+        producer.cancel()
+        raise
+    await producer
+    # "COPY <rows>"
+    return int(status.split()[-1])
+
+
+def _quote(name: str) -> str:
+    return '"' + name.replace('"', '""') + '"'
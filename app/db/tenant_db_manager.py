@@ -5,20 +5,36 @@ Manages connection pools to tenant-specific databases and handles
 database creation and migrations on-demand.
 """
 
+import asyncio
 import logging
 import os
 import ssl
+import time
 from pathlib import Path
-from typing import Dict, Optional
+from typing import Dict, Optional, Tuple
 
 import asyncpg
 
 from app.config import Config
+from app.db.labels import pool_options
+from app.db.priority import prioritized_pool
+from app.db.tracing import trace_queries
 from app.db.database import Database
 from app.db.control_database import connect_control_db
+from app.db.online_migrations import apply_online, plan_migration
+from app.db.residency import RegionRouter, check_mapping, check_marker, check_server, parse_regions
+from app.db.sharding import DEFAULT_SHARD, ShardHealth, ShardRouter, copy_database
+from app.metrics import metrics
+from app.repository.errors import NotFoundError
 
 logger = logging.getLogger(__name__)
 
+# How long a cached pool is used before its tenant's status and shard are read
+# again, so instances stop serving a tenant another one deleted and follow a
+# tenant moved by another one
+POOL_RECHECK_SECONDS = 30.0
+SHARD_HEALTH_TIMEOUT_SECONDS = 5.0
+
 
 class TenantDatabaseManager:
     """
@@ -29,6 +45,8 @@ class TenantDatabaseManager:
     - Connection pool caching per tenant
     - Automatic database creation and migration
     - Integration with control database for tenant metadata
+    - Data residency: tenant databases live on their region's server (see residency.py)
+    - Sharding: tenants of DB_HOST's region are spread over its shards (see sharding.py)
     """
 
     def __init__(self, cfg: Config, control_db: Optional[Database] = None):
@@ -43,6 +61,10 @@ class TenantDatabaseManager:
         self.cfg = cfg
         self.control_db = control_db
         self._tenant_pools: Dict[str, Database] = {}  # tenant_id -> Database pool
+        # tenant_id -> (database name, shard, when the mapping was last read) of cached pools
+        self._pool_locations: Dict[str, Tuple[str, str, float]] = {}
+        self.regions = RegionRouter(cfg.host, cfg.port, parse_regions(cfg.db_regions), cfg.default_region)
+        self.shards = ShardRouter(cfg.host, cfg.port, parse_regions(cfg.db_shards, "DB_SHARDS", "shard"))
         self._pool_lock = None  # Will use asyncio.Lock if needed for thread safety
 
     async def get_tenant_db(self, tenant_id: str) -> Database:
@@ -64,7 +86,10 @@ class TenantDatabaseManager:
         """
         # Check cache first
         if tenant_id in self._tenant_pools:
-            return self._tenant_pools[tenant_id]
+            if await self._pool_is_current(tenant_id):
+                return self._tenant_pools[tenant_id]
+            # Deleted, or moved to another shard, by another instance
+            await self.evict_tenant_pool(tenant_id)
 
         # Get control database connection (use provided or create new)
         control_db = self.control_db
@@ -75,34 +100,39 @@ class TenantDatabaseManager:
         async with control_db.pool.acquire() as conn:
             # First, get tenant slug to determine database name
             tenant_row = await conn.fetchrow(
-                "SELECT slug FROM tenants WHERE id = $1",
+                "SELECT slug, status, region FROM tenants WHERE id = $1",
                 tenant_id
             )
             if not tenant_row:
                 raise ValueError(f"Tenant not found: {tenant_id}")
+            if tenant_row["status"] == "pending_deletion":
+                raise ValueError(f"Tenant is pending deletion: {tenant_id}")
 
             slug = tenant_row["slug"]
+            region = tenant_row["region"]
             db_name = self.cfg.tenant_db_name(slug)
 
             # Check if database mapping exists
             db_mapping = await conn.fetchrow(
-                "SELECT database_name, status FROM tenant_databases WHERE tenant_id = $1",
+                "SELECT database_name, status, region, shard FROM tenant_databases WHERE tenant_id = $1",
                 tenant_id
             )
 
             if db_mapping and db_mapping["status"] == "active":
-                # Database mapping exists, connect to it
+                # Database mapping exists, connect to it (only in the tenant's region)
+                check_mapping(tenant_id, region, db_mapping["region"])
                 db_name = db_mapping["database_name"]
+                shard = db_mapping["shard"]
             else:
                 # Need to create tenant database
-                await self._create_tenant_database(tenant_id, slug, db_name, control_db, conn)
+                shard = db_mapping["shard"] if db_mapping else self._place(tenant_id, region)
+                await self._create_tenant_database(tenant_id, slug, db_name, control_db, conn, region, shard)
 
         # Connect to tenant database and run migrations
-        tenant_db = await self._connect_tenant_database(db_name)
-        await self._run_tenant_migrations(tenant_id, tenant_db)
+        tenant_db = await self._open_tenant_database(tenant_id, db_name, region, shard=shard)
 
         # Cache the pool
-        self._tenant_pools[tenant_id] = tenant_db
+        self._cache_pool(tenant_id, tenant_db, db_name, shard)
         logger.info(f"Cached connection pool for tenant {tenant_id} (database: {db_name})")
 
         return tenant_db
@@ -111,7 +141,8 @@ class TenantDatabaseManager:
         self,
         tenant_id: str,
         slug: str,
-        control_db: Optional[Database] = None
+        control_db: Optional[Database] = None,
+        region: str = ""
     ) -> Database:
         """
         Create a new tenant database explicitly.
@@ -124,6 +155,7 @@ class TenantDatabaseManager:
             tenant_id: UUID of the tenant
             slug: Tenant slug (used for database naming)
             control_db: Optional control database connection
+            region: Tenant region, whose server the database is created on
             
         Returns:
             Database connection pool for the new tenant
@@ -135,15 +167,15 @@ class TenantDatabaseManager:
         if not control_db:
             control_db = await connect_control_db(self.cfg)
 
+        shard = self._place(tenant_id, region)
         async with control_db.pool.acquire() as conn:
-            await self._create_tenant_database(tenant_id, slug, db_name, control_db, conn)
+            await self._create_tenant_database(tenant_id, slug, db_name, control_db, conn, region, shard)
 
         # Connect to tenant database and run migrations
-        tenant_db = await self._connect_tenant_database(db_name)
-        await self._run_tenant_migrations(tenant_id, tenant_db)
+        tenant_db = await self._open_tenant_database(tenant_id, db_name, region, shard=shard)
 
         # Cache the pool
-        self._tenant_pools[tenant_id] = tenant_db
+        self._cache_pool(tenant_id, tenant_db, db_name, shard)
         logger.info(f"Created and cached tenant database: {db_name} for tenant {tenant_id}")
 
         return tenant_db
@@ -154,56 +186,29 @@ class TenantDatabaseManager:
         slug: str,
         db_name: str,
         control_db: Database,
-        control_conn
+        control_conn,
+        region: str = "",
+        shard: str = DEFAULT_SHARD
     ) -> None:
-        """Internal method to create tenant database and record mapping."""
+        """Internal method to create tenant database (on its region's or shard's server) and record mapping."""
         try:
-            # Connect to postgres database to create new database
-            ssl_context = None
-            if self.cfg.ssl_mode == "require":
-                ssl_context = "require"
-            elif self.cfg.ssl_mode == "prefer":
-                ssl_context = "prefer"
-            elif self.cfg.ssl_mode == "verify-ca" or self.cfg.ssl_mode == "verify-full":
-                ssl_context = ssl.create_default_context()
-
-            admin_conn = await asyncpg.connect(
-                host=self.cfg.host,
-                port=self.cfg.port,
-                user=self.cfg.user,
-                password=self.cfg.password,
-                database="postgres",  # Connect to default database
-                ssl=ssl_context,
-            )
-
-            try:
-                # Check if database already exists
-                db_exists = await admin_conn.fetchval(
-                    "SELECT 1 FROM pg_database WHERE datname = $1",
-                    db_name
-                )
-
-                if not db_exists:
-                    logger.info(f"Creating tenant database: {db_name}")
-                    # Create the database
-                    await admin_conn.execute(f'CREATE DATABASE "{db_name}"')
-                    logger.info(f"Tenant database created: {db_name}")
-                else:
-                    logger.info(f"Tenant database already exists: {db_name}")
-            finally:
-                await admin_conn.close()
+            await self._ensure_database(tenant_id, db_name, region, shard)
 
             # Record database mapping in control database
             await control_conn.execute(
                 """
-                INSERT INTO tenant_databases (tenant_id, database_name)
-                VALUES ($1, $2)
+                INSERT INTO tenant_databases (tenant_id, database_name, region, shard)
+                VALUES ($1, $2, $3, $4)
                 ON CONFLICT (tenant_id) DO UPDATE
                 SET database_name = EXCLUDED.database_name,
+                    region = EXCLUDED.region,
+                    shard = EXCLUDED.shard,
                     status = 'active'
                 """,
                 tenant_id,
-                db_name
+                db_name,
+                region,
+                shard
             )
             logger.info(f"Recorded tenant database mapping: {tenant_id} -> {db_name}")
 
@@ -213,56 +218,342 @@ class TenantDatabaseManager:
             # Still record the mapping
             await control_conn.execute(
                 """
-                INSERT INTO tenant_databases (tenant_id, database_name)
-                VALUES ($1, $2)
+                INSERT INTO tenant_databases (tenant_id, database_name, region, shard)
+                VALUES ($1, $2, $3, $4)
                 ON CONFLICT (tenant_id) DO UPDATE
                 SET database_name = EXCLUDED.database_name,
+                    region = EXCLUDED.region,
+                    shard = EXCLUDED.shard,
                     status = 'active'
                 """,
                 tenant_id,
-                db_name
+                db_name,
+                region,
+                shard
             )
         except Exception as e:
             logger.error(f"Failed to create tenant database {db_name}: {e}")
             raise
 
-    async def _connect_tenant_database(self, db_name: str) -> Database:
-        """Connect to a tenant database and return Database wrapper."""
+    async def _ensure_database(self, tenant_id: str, db_name: str, region: str, shard: str = DEFAULT_SHARD) -> None:
+        """Create a tenant's database on its region's (or shard's) server unless it exists."""
+        # Connect to postgres database to create new database
+        ssl_context = self._ssl_context()
+        host, port = self._server(region, shard)
+
+        admin_conn = await asyncpg.connect(
+            host=host,
+            port=port,
+            user=self.cfg.user,
+            password=self.cfg.password,
+            database="postgres",  # Connect to default database
+            ssl=ssl_context,
+        )
+
         try:
-            # Map SSL mode to asyncpg ssl parameter
-            ssl_context = None
-            if self.cfg.ssl_mode == "require":
-                ssl_context = "require"
-            elif self.cfg.ssl_mode == "prefer":
-                ssl_context = "prefer"
-            elif self.cfg.ssl_mode == "verify-ca" or self.cfg.ssl_mode == "verify-full":
-                ssl_context = ssl.create_default_context()
+            await check_server(admin_conn, tenant_id, region)
+
+            # Check if database already exists
+            db_exists = await admin_conn.fetchval(
+                "SELECT 1 FROM pg_database WHERE datname = $1",
+                db_name
+            )
+
+            if not db_exists:
+                logger.info(f"Creating tenant database: {db_name}")
+                # Create the database
+                await admin_conn.execute(f'CREATE DATABASE "{db_name}"')
+                logger.info(f"Tenant database created: {db_name}")
+            else:
+                logger.info(f"Tenant database already exists: {db_name}")
+        finally:
+            await admin_conn.close()
+
+    def _server(self, region: str, shard: str) -> Tuple[str, int]:
+        """The (host, port) of the server a tenant database of a region and shard lives on."""
+        if shard != DEFAULT_SHARD:
+            return self.shards.server(shard)
+        return self.regions.server(region)
+
+    def shardable(self, region: str) -> bool:
+        """Whether tenants of a region live on DB_HOST, so are spread over its shards."""
+        return self.regions.server(region) == (self.cfg.host, self.cfg.port)
+
+    def _place(self, tenant_id: str, region: str) -> str:
+        """The shard a new tenant database is created on."""
+        return self.shards.place(tenant_id) if self.shardable(region) else DEFAULT_SHARD
+
+    def _cache_pool(self, tenant_id: str, tenant_db: Database, db_name: str, shard: str) -> None:
+        self._tenant_pools[tenant_id] = tenant_db
+        self._pool_locations[tenant_id] = (db_name, shard, time.monotonic())
+
+    async def _pool_is_current(self, tenant_id: str) -> bool:
+        """
+        Whether a cached pool may still serve its tenant: read again past
+        POOL_RECHECK_SECONDS, it is not once the tenant is pending deletion
+        or its database has moved.
+        """
+        location = self._pool_locations.get(tenant_id)
+        if not location:
+            return True
+        db_name, shard, checked_at = location
+        if time.monotonic() - checked_at < POOL_RECHECK_SECONDS:
+            return True
+        control_db = self.control_db or await connect_control_db(self.cfg)
+        async with control_db.pool.acquire() as conn:
+            mapping = await conn.fetchrow(
+                """
+                SELECT d.database_name, d.shard, t.status
+                FROM tenant_databases d
+                JOIN tenants t ON t.id = d.tenant_id
+                WHERE d.tenant_id = $1 AND d.status = 'active'
+                """,
+                tenant_id
+            )
+        if not mapping or mapping["status"] == "pending_deletion":
+            return False
+        if (mapping["database_name"], mapping["shard"]) != (db_name, shard):
+            return False
+        self._pool_locations[tenant_id] = (db_name, shard, time.monotonic())
+        return True
+
+    def _ssl_context(self):
+        """Map the configured SSL mode to an asyncpg ssl parameter."""
+        if self.cfg.ssl_mode == "require":
+            return "require"
+        if self.cfg.ssl_mode == "prefer":
+            return "prefer"
+        if self.cfg.ssl_mode == "verify-ca" or self.cfg.ssl_mode == "verify-full":
+            return ssl.create_default_context()
+        return None
+
+    async def drop_tenant_database(self, tenant_id: str) -> None:
+        """
+        Permanently drop a tenant's database and remove its mapping.
+
+        Used by the tenant purger once a deleted tenant's grace period ends.
+        """
+        await self.evict_tenant_pool(tenant_id)
+
+        control_db = self.control_db
+        if not control_db:
+            control_db = await connect_control_db(self.cfg)
+
+        async with control_db.pool.acquire() as conn:
+            mapping = await conn.fetchrow(
+                "SELECT database_name, region, shard FROM tenant_databases WHERE tenant_id = $1",
+                tenant_id
+            )
+        if not mapping:
+            return
+        db_name = mapping["database_name"]
+        host, port = self._server(mapping["region"], mapping["shard"])
+
+        admin_conn = await asyncpg.connect(
+            host=host,
+            port=port,
+            user=self.cfg.user,
+            password=self.cfg.password,
+            database="postgres",
+            ssl=self._ssl_context(),
+        )
+        try:
+            await admin_conn.execute(f'DROP DATABASE IF EXISTS "{db_name}" WITH (FORCE)')
+            logger.info(f"Dropped tenant database: {db_name}")
+        finally:
+            await admin_conn.close()
+
+        async with control_db.pool.acquire() as conn:
+            await conn.execute("DELETE FROM tenant_migrations WHERE tenant_id = $1", tenant_id)
+            await conn.execute("DELETE FROM tenant_databases WHERE tenant_id = $1", tenant_id)
+
+    async def check_shards(self) -> Dict[str, ShardHealth]:
+        """
+        Check that each shard's server accepts connections. New tenants are
+        not placed on shards whose last check failed.
+        """
+        results = await asyncio.gather(*(self._check_shard(shard) for shard in self.shards.shards))
+        for health in results:
+            previous = self.shards.health.get(health.shard)
+            if not health.healthy and (previous is None or previous.healthy):
+                logger.warning(f"Shard {health.shard} is unhealthy, placing no new tenants on it: {health.error}")
+            elif health.healthy and previous is not None and not previous.healthy:
+                logger.info(f"Shard {health.shard} is healthy again")
+            self.shards.health[health.shard] = health
+        return dict(self.shards.health)
+
+    async def _check_shard(self, shard: str) -> ShardHealth:
+        host, port = self.shards.server(shard)
+        health = ShardHealth(shard)
+        started = time.monotonic()
+        try:
+            conn = await asyncpg.connect(
+                host=host,
+                port=port,
+                user=self.cfg.user,
+                password=self.cfg.password,
+                database="postgres",
+                ssl=self._ssl_context(),
+                timeout=SHARD_HEALTH_TIMEOUT_SECONDS,
+            )
+            try:
+                await conn.fetchval("SELECT 1", timeout=SHARD_HEALTH_TIMEOUT_SECONDS)
+            finally:
+                await conn.close()
+        except Exception as e:
+            health.healthy = False
+            health.error = str(e) or type(e).__name__
+            metrics.inc("shard_health_failures_total", labels={"shard": shard})
+        elapsed = time.monotonic() - started
+        health.latency_ms = elapsed * 1000
+        metrics.observe("shard_health_check_seconds", elapsed, labels={"shard": shard})
+        return health
+
+    async def move_tenant_database(self, tenant_id: str, shard: str) -> Dict[str, object]:
+        """
+        Move a tenant's database to another shard: a database of the same name
+        is created and migrated on the shard's server, the tenant's rows are
+        copied into it (see copy_database) and the tenant's mapping is switched
+        to it. The database on the previous shard is kept. Writes made during
+        the copy are lost, so the tenant should be read-only for maintenance.
+
+        Returns the previous shard, the database name and the rows copied per table.
+
+        Raises:
+            NotFoundError: If the tenant has no database
+            ValueError: If the shard is not configured or the tenant cannot move to it
+        """
+        self.shards.check_shard(shard)
+        control_db = self.control_db or await connect_control_db(self.cfg)
+        async with control_db.pool.acquire() as conn:
+            mapping = await conn.fetchrow(
+                """
+                SELECT database_name, region, shard,
+                       EXISTS (SELECT 1 FROM tenant_dual_writes WHERE tenant_id = $1) AS dual_writes
+                FROM tenant_databases WHERE tenant_id = $1 AND status = 'active'
+                """,
+                tenant_id
+            )
+        if not mapping:
+            raise NotFoundError(f"tenant database not found: {tenant_id}")
+        region, previous, db_name = mapping["region"], mapping["shard"], mapping["database_name"]
+        if not self.shardable(region):
+            raise ValueError(f"tenants of region {region} are not sharded")
+        if previous == shard:
+            raise ValueError(f"tenant {tenant_id} is on shard {shard} already")
+        if mapping["dual_writes"]:
+            raise ValueError(f"tenant {tenant_id} is in dual-write mode; stop it before moving the tenant")
+
+        source = await self.get_tenant_db(tenant_id)
+        await self._ensure_database(tenant_id, db_name, region, shard)
+        target = await self._open_tenant_database(tenant_id, db_name, region, tracked=False, shard=shard)
+        try:
+            copied = await copy_database(source, target)
+        finally:
+            await target.close()
+
+        async with control_db.pool.acquire() as conn:
+            async with conn.transaction():
+                await conn.execute("UPDATE tenant_databases SET shard = $2 WHERE tenant_id = $1", tenant_id, shard)
+                # Its sync cursor holds the previous server's transaction IDs, so the tenant is indexed again
+                await conn.execute("DELETE FROM search_index_cursors WHERE tenant_id = $1", tenant_id)
+        await self.evict_tenant_pool(tenant_id)
+        metrics.inc("shard_moves_total", labels={"shard": shard})
+        logger.info(f"Moved database {db_name} of tenant {tenant_id} from shard {previous} to shard {shard}")
+        return {"previous_shard": previous, "database_name": db_name, "rows": copied}
+
+    async def connect_database(self, db_name: str) -> Database:
+        """
+        Connect to another database of the cluster with a tenant database's
+        schema, such as a restored backup. The pool is not cached; the caller closes it.
+
+        Raises:
+            NotFoundError: If the database does not exist
+        """
+        control_db = self.control_db or await connect_control_db(self.cfg)
+        async with control_db.pool.acquire() as conn:
+            exists = await conn.fetchval("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", db_name)
+        if not exists:
+            raise NotFoundError(f"database not found: {db_name}")
+        return await self._connect_tenant_database(db_name)
+
+    async def open_secondary_database(self, tenant_id: str, db_name: str) -> Database:
+        """
+        Open a second database for a tenant, which its writes are mirrored to
+        while it is moved to a new storage backend. The database is created on
+        the server of the tenant's database (its region's or shard's) if it does
+        not exist, and migrated (tracked in the database only). The pool is not
+        cached; the caller closes it.
+
+        Raises:
+            NotFoundError: If the tenant does not exist
+            ValueError: If the database belongs to a tenant
+        """
+        control_db = self.control_db or await connect_control_db(self.cfg)
+        async with control_db.pool.acquire() as conn:
+            region = await conn.fetchval("SELECT region FROM tenants WHERE id = $1", tenant_id)
+            if region is None:
+                raise NotFoundError(f"tenant not found: {tenant_id}")
+            if await conn.fetchval(
+                "SELECT EXISTS (SELECT 1 FROM tenant_databases WHERE database_name = $1)", db_name
+            ):
+                raise ValueError(f"database {db_name} is a tenant's database")
+            shard = await conn.fetchval("SELECT shard FROM tenant_databases WHERE tenant_id = $1", tenant_id)
+            shard = shard or DEFAULT_SHARD
+
+        await self._ensure_database(tenant_id, db_name, region, shard)
+        return await self._open_tenant_database(tenant_id, db_name, region, tracked=False, shard=shard)
+
+    async def _open_tenant_database(
+        self, tenant_id: str, db_name: str, region: str, tracked: bool = True, shard: str = DEFAULT_SHARD
+    ) -> Database:
+        """Connect to a tenant's database in its region (and shard), migrate it and check its region marker."""
+        tenant_db = await self._connect_tenant_database(db_name, region, shard)
+        try:
+            async with tenant_db.pool.acquire() as conn:
+                await check_server(conn, tenant_id, region)
+            await self._run_tenant_migrations(tenant_id, tenant_db, tracked)
+            async with tenant_db.pool.acquire() as conn:
+                await check_marker(conn, tenant_id, region)
+        except Exception:
+            await tenant_db.close()
+            raise
+        return tenant_db
+
+    async def _connect_tenant_database(self, db_name: str, region: str = "", shard: str = DEFAULT_SHARD) -> Database:
+        """Connect to a tenant database (on a region's or shard's server) and return Database wrapper."""
+        host, port = self._server(region, shard)
+        try:
+            ssl_context = self._ssl_context()
 
             pool = await asyncpg.create_pool(
-                host=self.cfg.host,
-                port=self.cfg.port,
+                host=host,
+                port=port,
                 user=self.cfg.user,
                 password=self.cfg.password,
                 database=db_name,
                 min_size=1,
                 max_size=10,
                 ssl=ssl_context,
+                init=trace_queries,
+                **pool_options(self.cfg.db_application_name),
             )
 
             # Test the connection
             async with pool.acquire() as conn:
                 await conn.execute("SELECT 1")
 
-            return Database(pool)
+            return Database(prioritized_pool(pool))
         except Exception as e:
             raise Exception(f"Failed to connect to tenant database {db_name}: {e}") from e
 
-    async def _run_tenant_migrations(self, tenant_id: str, tenant_db: Database) -> None:
+    async def _run_tenant_migrations(self, tenant_id: str, tenant_db: Database, tracked: bool = True) -> None:
         """
         Run migrations on a tenant database.
         
         Tracks which migrations have been applied to which tenant database
-        in the control database's tenant_migrations table.
+        in the control database's tenant_migrations table (unless tracked is
+        False, as for a tenant's second database during a backend migration).
         """
         # Get control database connection
         control_db = self.control_db
@@ -270,12 +561,14 @@ class TenantDatabaseManager:
             control_db = await connect_control_db(self.cfg)
 
         # Get migrations already applied to this tenant (from control DB)
-        async with control_db.pool.acquire() as control_conn:
-            applied_rows = await control_conn.fetch(
-                "SELECT version FROM tenant_migrations WHERE tenant_id = $1",
-                tenant_id
-            )
-            applied = {row["version"] for row in applied_rows}
+        applied = set()
+        if tracked:
+            async with control_db.pool.acquire() as control_conn:
+                applied_rows = await control_conn.fetch(
+                    "SELECT version FROM tenant_migrations WHERE tenant_id = $1",
+                    tenant_id
+                )
+                applied = {row["version"] for row in applied_rows}
 
         async with tenant_db.pool.acquire() as conn:
             # Create migrations tracking table in tenant database
@@ -315,18 +608,26 @@ class TenantDatabaseManager:
                 logger.info(f"Applying tenant migration {version} to tenant {tenant_id}")
                 content = (migrations_dir / filename).read_text()
 
-                # Execute the migration in a transaction
-                async with conn.transaction():
-                    await conn.execute(content)
+                async def record(conn: asyncpg.Connection, version: str = version) -> None:
                     # Record in tenant database (use ON CONFLICT to handle race conditions)
                     await conn.execute(
                         "INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT (version) DO NOTHING",
                         version
                     )
-                    new_migrations.append(version)
+
+                # A new database's tables are empty, so its migrations cannot hold heavy locks for long
+                if self.cfg.migration_mode == "online" and all_applied:
+                    plan = plan_migration(version, content, self.cfg.migration_backfill_batch_size)
+                    await apply_online(conn, plan, record, self.cfg.migration_lock_timeout_ms)
+                else:
+                    # Execute the migration in a transaction
+                    async with conn.transaction():
+                        await conn.execute(content)
+                        await record(conn)
+                new_migrations.append(version)
 
             # Record new migrations in control database (batch insert)
-            if new_migrations:
+            if new_migrations and tracked:
                 async with control_db.pool.acquire() as control_conn:
                     for version in new_migrations:
                         await control_conn.execute(
@@ -347,6 +648,7 @@ class TenantDatabaseManager:
             except Exception as e:
                 logger.error(f"Error closing pool for tenant {tenant_id}: {e}")
         self._tenant_pools.clear()
+        self._pool_locations.clear()
 
     async def evict_tenant_pool(self, tenant_id: str) -> None:
         """Evict a specific tenant's connection pool from cache."""
@@ -356,5 +658,6 @@ class TenantDatabaseManager:
             except Exception as e:
                 logger.error(f"Error closing pool for tenant {tenant_id}: {e}")
             del self._tenant_pools[tenant_id]
+            self._pool_locations.pop(tenant_id, None)
             logger.info(f"Evicted pool for tenant {tenant_id}")
 
@@ -0,0 +1,6 @@
+-- Migration: 004_add_data_compression.up.sql
+-- Store large node/relationship documents zstd-compressed.
+-- When data_compressed is set, data holds an empty object placeholder.
+
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS data_compressed BYTEA;
+ALTER TABLE relationships ADD COLUMN IF NOT EXISTS data_compressed BYTEA;
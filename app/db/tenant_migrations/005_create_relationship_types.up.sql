@@ -0,0 +1,17 @@
+-- Migration: 005_create_relationship_types.up.sql
+-- Registry of relationship types with directionality and endpoint constraints.
+-- Empty allowed_*_node_type_ids arrays mean "any node type".
+
+CREATE TABLE IF NOT EXISTS relationship_types (
+    id                           UUID PRIMARY KEY,
+    name                         TEXT NOT NULL,
+    description                  TEXT,
+    directionality               TEXT NOT NULL DEFAULT 'directed',
+    allowed_source_node_type_ids UUID[] NOT NULL DEFAULT '{}',
+    allowed_target_node_type_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at                   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_relationship_types_name ON relationship_types(name);
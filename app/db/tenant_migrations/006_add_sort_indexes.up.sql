@@ -0,0 +1,9 @@
+-- Migration: 006_add_sort_indexes.up.sql
+-- Indexes backing the default and common order_by columns of list queries.
+-- Data path sorts (e.g. order_by "data.priority") can be accelerated per tenant
+-- with expression indexes such as: CREATE INDEX ON nodes ((data #> '{priority}'));
+
+CREATE INDEX IF NOT EXISTS idx_nodes_created_at ON nodes(created_at DESC, id);
+CREATE INDEX IF NOT EXISTS idx_nodes_updated_at ON nodes(updated_at);
+CREATE INDEX IF NOT EXISTS idx_relationships_created_at ON relationships(created_at DESC, id);
+CREATE INDEX IF NOT EXISTS idx_node_types_created_at ON node_types(created_at DESC, id);
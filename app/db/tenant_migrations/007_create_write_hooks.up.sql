@@ -0,0 +1,20 @@
+-- Migration: 007_create_write_hooks.up.sql
+-- Tenant-defined CEL scripts that run before/after node writes.
+
+CREATE TABLE IF NOT EXISTS write_hooks (
+    id           UUID PRIMARY KEY,
+    name         TEXT NOT NULL,
+    node_type_id UUID REFERENCES node_types(id) ON DELETE CASCADE,
+    phase        TEXT NOT NULL,               -- 'pre_write' or 'post_write'
+    action       TEXT NOT NULL,               -- 'validate' or 'enrich'
+    operations   TEXT[] NOT NULL DEFAULT '{create,update}',
+    expression   TEXT NOT NULL,
+    timeout_ms   INTEGER NOT NULL DEFAULT 100,
+    priority     INTEGER NOT NULL DEFAULT 0,
+    enabled      BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_write_hooks_node_type_id ON write_hooks(node_type_id);
@@ -0,0 +1,27 @@
+-- Migration: 008_create_node_versions.up.sql
+-- Bi-temporal node history. Each row is the node's data for a valid-time
+-- interval [valid_from, valid_to) as known during the transaction-time
+-- interval [recorded_from, recorded_to). NULL upper bounds are open-ended.
+-- Rows are never updated except to close recorded_to, so history survives
+-- corrections and node deletion.
+
+CREATE TABLE IF NOT EXISTS node_versions (
+    version_id      BIGSERIAL PRIMARY KEY,
+    node_id         UUID NOT NULL,
+    node_type_id    UUID NOT NULL,
+    data            JSONB NOT NULL DEFAULT '{}',
+    data_compressed BYTEA,
+    valid_from      TIMESTAMPTZ NOT NULL,
+    valid_to        TIMESTAMPTZ,
+    recorded_from   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    recorded_to     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_versions_node ON node_versions(node_id, recorded_from);
+CREATE INDEX IF NOT EXISTS idx_node_versions_type ON node_versions(node_type_id, valid_from);
+
+-- Existing nodes become versions valid (and recorded) since they were created
+INSERT INTO node_versions (node_id, node_type_id, data, data_compressed, valid_from, recorded_from)
+SELECT id, node_type_id, data, data_compressed, created_at, created_at
+FROM nodes
+WHERE NOT EXISTS (SELECT 1 FROM node_versions v WHERE v.node_id = nodes.id);
@@ -0,0 +1,19 @@
+-- Migration: 009_create_attachments.up.sql
+-- Metadata for binary attachments stored in object storage.
+-- Rows start 'pending' when an upload is reserved and become 'ready' once
+-- the content has been streamed to the object store.
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id           UUID PRIMARY KEY,
+    node_id      UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    filename     TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes   BIGINT NOT NULL DEFAULT 0,
+    sha256       TEXT,
+    storage_key  TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_node_id ON attachments(node_id, created_at);
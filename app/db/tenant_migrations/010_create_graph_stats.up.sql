@@ -0,0 +1,9 @@
+-- Migration: 010_create_graph_stats.up.sql
+-- Cached graph statistics snapshot (a single row, recomputed when stale).
+
+CREATE TABLE IF NOT EXISTS graph_stats (
+    id          SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    stats       JSONB NOT NULL,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
@@ -0,0 +1,8 @@
+-- Migration: 011_add_relationship_type_delete_rules.up.sql
+-- Per relationship type behavior when a source or target node is deleted:
+--   delete_edge: delete the relationship (default, previous behavior)
+--   delete_node: delete the node on the other end as well
+--   restrict:    refuse to delete the node while the relationship exists
+
+ALTER TABLE relationship_types ADD COLUMN IF NOT EXISTS on_source_delete TEXT NOT NULL DEFAULT 'delete_edge';
+ALTER TABLE relationship_types ADD COLUMN IF NOT EXISTS on_target_delete TEXT NOT NULL DEFAULT 'delete_edge';
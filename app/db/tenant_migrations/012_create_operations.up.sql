@@ -0,0 +1,20 @@
+-- Migration: 012_create_operations.up.sql
+-- Long-running operations (bulk updates and deletes) executed in the background.
+
+CREATE TABLE IF NOT EXISTS operations (
+    id              UUID PRIMARY KEY,
+    kind            TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'running',  -- 'running', 'completed' or 'failed'
+    params          JSONB NOT NULL DEFAULT '{}',
+    total_count     INTEGER NOT NULL DEFAULT 0,
+    processed_count INTEGER NOT NULL DEFAULT 0,
+    affected_count  INTEGER NOT NULL DEFAULT 0,
+    failed_count    INTEGER NOT NULL DEFAULT 0,
+    errors          JSONB NOT NULL DEFAULT '[]',      -- first failures, as {"id", "error"}
+    error           TEXT,                             -- why the operation itself failed
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_operations_created_at ON operations(created_at);
@@ -0,0 +1,5 @@
+-- Migration: 013_add_node_metadata.up.sql
+-- System and integration metadata on nodes (source system, sync cursors),
+-- kept apart from user data: it is not versioned and does not change the etag.
+
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,22 @@
+-- Migration: 014_create_data_migrations.up.sql
+-- Versioned node data schemas: each node type has a schema version, each
+-- node records the version its data was written at, and data migrations
+-- transform node data from one version to the next.
+
+ALTER TABLE node_types ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS data_migrations (
+    id           UUID PRIMARY KEY,
+    node_type_id UUID NOT NULL REFERENCES node_types(id) ON DELETE CASCADE,
+    from_version INTEGER NOT NULL,              -- migrates data from this version to from_version + 1
+    name         TEXT NOT NULL DEFAULT '',
+    rules        JSONB NOT NULL DEFAULT '[]',   -- JSON path remapping rules
+    expression   TEXT NOT NULL DEFAULT '',      -- CEL expression returning the new data
+    schema       JSONB,                         -- node type schema at from_version + 1
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (node_type_id, from_version)
+);
+
+-- Finds nodes a backfill still has to migrate
+CREATE INDEX IF NOT EXISTS idx_nodes_schema_version ON nodes(node_type_id, schema_version);
@@ -0,0 +1,24 @@
+-- Migration: 015_add_derived_relationship_types.up.sql
+-- Derived relationship types are computed from a path of other relationship
+-- types, e.g. COLLEAGUE = EMPLOYED_BY (out) then EMPLOYED_BY (in):
+--   {"path": [{"relationship_type": "EMPLOYED_BY", "direction": "out"},
+--             {"relationship_type": "EMPLOYED_BY", "direction": "in"}],
+--    "materialized": false}
+-- NULL derivation = relationships of the type are stored as usual.
+
+ALTER TABLE relationship_types ADD COLUMN IF NOT EXISTS derivation JSONB;
+
+-- Snapshot of materialized derived relationships, replaced on refresh
+CREATE TABLE IF NOT EXISTS derived_relationships (
+    id                UUID PRIMARY KEY,
+    relationship_type TEXT NOT NULL,
+    source_node_id    UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    target_node_id    UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    data              JSONB NOT NULL DEFAULT '{}',
+    created_at        TIMESTAMPTZ NOT NULL,
+    updated_at        TIMESTAMPTZ NOT NULL,
+    UNIQUE (relationship_type, source_node_id, target_node_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_derived_relationships_source ON derived_relationships(source_node_id);
+CREATE INDEX IF NOT EXISTS idx_derived_relationships_target ON derived_relationships(target_node_id);
@@ -0,0 +1,34 @@
+-- Migration: 016_create_directory.up.sql
+-- SCIM-style directory of the tenant's human users and their groups, used
+-- by authorization policies and to attribute node history to users.
+
+CREATE TABLE IF NOT EXISTS directory_users (
+    id           UUID PRIMARY KEY,
+    user_name    TEXT NOT NULL UNIQUE,           -- matched against the X-User-ID header
+    external_id  TEXT UNIQUE,                    -- ID in the customer's identity provider
+    display_name TEXT NOT NULL DEFAULT '',
+    email        TEXT NOT NULL DEFAULT '',
+    active       BOOLEAN NOT NULL DEFAULT TRUE,  -- disabled users are denied all calls
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS directory_groups (
+    id           UUID PRIMARY KEY,
+    display_name TEXT NOT NULL UNIQUE,
+    external_id  TEXT UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS directory_group_members (
+    group_id UUID NOT NULL REFERENCES directory_groups(id) ON DELETE CASCADE,
+    user_id  UUID NOT NULL REFERENCES directory_users(id) ON DELETE CASCADE,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_directory_group_members_user ON directory_group_members(user_id);
+
+-- Who recorded each node version: the directory user name, else the caller's X-User-ID
+ALTER TABLE node_versions ADD COLUMN IF NOT EXISTS recorded_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,54 @@
+-- Migration: 017_create_change_tracking.up.sql
+-- Change tracking for incremental exports: every node and relationship
+-- records the transaction that last wrote it, and deletions leave
+-- tombstones. Sync cursors are transaction ID horizons
+-- (pg_snapshot_xmin), so changes committed out of order are never skipped.
+
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE relationships ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS idx_nodes_change_xid ON nodes(change_xid);
+CREATE INDEX IF NOT EXISTS idx_relationships_change_xid ON relationships(change_xid);
+
+CREATE TABLE IF NOT EXISTS export_tombstones (
+    entity_id   UUID PRIMARY KEY,
+    entity_type TEXT NOT NULL,                   -- 'node' or 'relationship'
+    deleted_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    change_xid  xid8 NOT NULL DEFAULT pg_current_xact_id()
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_tombstones_change_xid ON export_tombstones(change_xid);
+CREATE INDEX IF NOT EXISTS idx_export_tombstones_deleted_at ON export_tombstones(deleted_at);
+
+CREATE OR REPLACE FUNCTION record_change_xid() RETURNS trigger AS $$
+BEGIN
+    NEW.change_xid := pg_current_xact_id();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_export_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO export_tombstones (entity_id, entity_type)
+    VALUES (OLD.id, TG_ARGV[0])
+    ON CONFLICT (entity_id) DO UPDATE
+    SET deleted_at = NOW(), change_xid = pg_current_xact_id();
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS nodes_change_xid ON nodes;
+CREATE TRIGGER nodes_change_xid BEFORE UPDATE ON nodes
+    FOR EACH ROW EXECUTE FUNCTION record_change_xid();
+
+DROP TRIGGER IF EXISTS relationships_change_xid ON relationships;
+CREATE TRIGGER relationships_change_xid BEFORE UPDATE ON relationships
+    FOR EACH ROW EXECUTE FUNCTION record_change_xid();
+
+DROP TRIGGER IF EXISTS nodes_export_tombstone ON nodes;
+CREATE TRIGGER nodes_export_tombstone AFTER DELETE ON nodes
+    FOR EACH ROW EXECUTE FUNCTION record_export_tombstone('node');
+
+DROP TRIGGER IF EXISTS relationships_export_tombstone ON relationships;
+CREATE TRIGGER relationships_export_tombstone AFTER DELETE ON relationships
+    FOR EACH ROW EXECUTE FUNCTION record_export_tombstone('relationship');
@@ -0,0 +1,15 @@
+-- Migration: 018_add_relationship_validity.up.sql
+-- Optional validity interval of relationships, e.g. a REPORTS_TO edge that
+-- held from one reorganization to the next. NULL bounds are open: a
+-- relationship without valid_from has always been valid, and one without
+-- valid_to is valid indefinitely. Expansions and derived relationship types
+-- only follow relationships valid at the time they are read.
+
+ALTER TABLE relationships ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ;
+ALTER TABLE relationships ADD COLUMN IF NOT EXISTS valid_to TIMESTAMPTZ;
+
+ALTER TABLE relationships DROP CONSTRAINT IF EXISTS relationships_validity_check;
+ALTER TABLE relationships ADD CONSTRAINT relationships_validity_check
+    CHECK (valid_from IS NULL OR valid_to IS NULL OR valid_to > valid_from);
+
+CREATE INDEX IF NOT EXISTS idx_relationships_valid_to ON relationships(valid_to) WHERE valid_to IS NOT NULL;
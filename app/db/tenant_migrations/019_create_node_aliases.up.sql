@@ -0,0 +1,13 @@
+-- Migration: 019_create_node_aliases.up.sql
+-- Named secondary identifiers of nodes (e.g. email, slug, legacy_id) that
+-- external systems use to reference them. Each (name, value) identifies at
+-- most one node; a node has at most one value per alias name.
+
+CREATE TABLE IF NOT EXISTS node_aliases (
+    node_id    UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    value      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (node_id, name),
+    UNIQUE (name, value)
+);
@@ -0,0 +1,5 @@
+-- Migration: 020_index_node_metadata.up.sql
+-- Containment index for filtering nodes by metadata, e.g. by the community
+-- IDs that community detection jobs store there.
+
+CREATE INDEX IF NOT EXISTS idx_nodes_metadata ON nodes USING GIN (metadata jsonb_path_ops);
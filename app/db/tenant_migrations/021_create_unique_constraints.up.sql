@@ -0,0 +1,16 @@
+-- Migration: 021_create_unique_constraints.up.sql
+-- Uniqueness of a node data path among the nodes of a node type. Each
+-- constraint is enforced by a partial unique expression index on nodes,
+-- uq_nodes_<constraint ID without dashes>, which the application builds
+-- concurrently (outside a migration transaction) and drops with the constraint.
+
+CREATE TABLE IF NOT EXISTS unique_constraints (
+    id               UUID PRIMARY KEY,
+    node_type_id     UUID NOT NULL REFERENCES node_types(id) ON DELETE CASCADE,
+    path             TEXT[] NOT NULL,
+    case_insensitive BOOLEAN NOT NULL DEFAULT FALSE,
+    -- building until the index is valid, then active
+    status           TEXT NOT NULL DEFAULT 'building',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (node_type_id, path)
+);
@@ -0,0 +1,4 @@
+-- Migration: 022_index_node_alias_values.up.sql
+-- Aliases looked up by value alone, whatever their name (admin search).
+
+CREATE INDEX IF NOT EXISTS idx_node_aliases_value ON node_aliases(value);
@@ -0,0 +1,4 @@
+-- Migration: 023_add_operation_results.up.sql
+-- Summary an operation reports when it finishes (such as an orphan collection report).
+
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS result JSONB NOT NULL DEFAULT '{}';
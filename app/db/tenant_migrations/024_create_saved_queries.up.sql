@@ -0,0 +1,17 @@
+-- Migration: 024_create_saved_queries.up.sql
+-- Named query definitions run by name with parameters, with their run statistics.
+
+CREATE TABLE IF NOT EXISTS saved_queries (
+    id            UUID PRIMARY KEY,
+    name          TEXT NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    method        TEXT NOT NULL,                   -- read method run, e.g. 'list_nodes'
+    params        JSONB NOT NULL DEFAULT '{}',     -- its parameters; "$name" values are substituted
+    parameters    JSONB NOT NULL DEFAULT '{}',     -- declared parameters and their defaults (null = required)
+    run_count     BIGINT NOT NULL DEFAULT 0,
+    total_run_ms  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    last_run_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (name)
+);
@@ -0,0 +1,14 @@
+-- Migration: 025_create_node_views.up.sql
+-- Named node filters with an optional projection, read like collections by list, search and export calls.
+
+CREATE TABLE IF NOT EXISTS node_views (
+    id            UUID PRIMARY KEY,
+    name          TEXT NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    filter        JSONB NOT NULL DEFAULT '{}',     -- bulk operation filter; "$name" values are substituted
+    projection    JSONB NOT NULL DEFAULT '{}',     -- export transform applied to the nodes read
+    parameters    JSONB NOT NULL DEFAULT '{}',     -- declared parameters and their defaults (null = required)
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (name)
+);
@@ -0,0 +1,12 @@
+-- Migration: 026_create_data_timestamp.up.sql
+-- Parses a data field as a timestamp for typed range filters (NULL if it is not one).
+-- Naive values are UTC, so the result does not depend on the session time zone and the
+-- function can back expression indexes such as ((data_timestamp(data #>> '{due}'))).
+
+CREATE OR REPLACE FUNCTION data_timestamp(value TEXT) RETURNS TIMESTAMPTZ AS $$
+BEGIN
+    RETURN value::timestamptz;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE SET timezone = 'UTC';
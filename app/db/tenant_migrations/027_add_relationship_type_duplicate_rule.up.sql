@@ -0,0 +1,12 @@
+-- Migration: 027_add_relationship_type_duplicate_rule.up.sql
+-- Per relationship type behavior when creating a relationship identical
+-- (same source, target and type) to an existing one:
+--   allow:           create another relationship (default, previous behavior)
+--   reject:          refuse to create it
+--   return_existing: return the existing relationship instead
+
+ALTER TABLE relationship_types ADD COLUMN IF NOT EXISTS on_duplicate TEXT NOT NULL DEFAULT 'allow';
+
+-- Looks up identical relationships on create
+CREATE INDEX IF NOT EXISTS idx_relationships_endpoints
+    ON relationships(source_node_id, target_node_id, relationship_type);
@@ -0,0 +1,11 @@
+-- Migration: 028_create_data_residency.up.sql
+-- Region marker of a tenant database, written when the database is first
+-- opened for a tenant with a region. A database whose marker names another
+-- region than its tenant's is refused, so a misrouted connection cannot
+-- write a tenant's data into another region's server.
+
+CREATE TABLE IF NOT EXISTS data_residency (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    region TEXT NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+-- Migration: 029_create_node_protections.up.sql
+-- Delete protection: nodes that are only deleted (directly or by a
+-- relationship type cascade) when the delete is forced by an administrator.
+
+CREATE TABLE IF NOT EXISTS node_protections (
+    node_id      UUID PRIMARY KEY REFERENCES nodes(id) ON DELETE CASCADE,
+    protected_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
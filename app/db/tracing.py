@@ -0,0 +1,99 @@
+"""
+Query tracing for per-tenant debug logging (see app/service/tenant_debug.py).
+
+Pools pass trace_queries as their connection ``init``, which adds a query
+logger to each new connection. Statements are only logged while the work
+that runs them has tracing started, so untraced work pays for nothing but
+the timing asyncpg takes for logged connections. asyncpg calls the logger
+in the context of the statement's task, which carries the trace.
+
+Work can also capture its statements (see app/service/explain_service.py),
+to explain the queries a call generates.
+"""
+
+import contextvars
+import json
+import logging
+from dataclasses import dataclass
+from typing import Any, List, Optional, Tuple
+
+debug_logger = logging.getLogger("flexdb.debug")
+
+# Characters of a statement's parameters logged
+MAX_LOGGED_ARGS = 2048
+
+_trace: contextvars.ContextVar[Optional[Tuple[str, str]]] = contextvars.ContextVar("db_trace", default=None)
+
+
+@dataclass
+class CapturedQuery:
+    """A statement run by work that captures its statements."""
+    query: str
+    args: Tuple[Any, ...]
+    # Database the statement ran on
+    database: str
+    duration_ms: float
+    error: Optional[str] = None
+
+
+_capture: contextvars.ContextVar[Optional[List[CapturedQuery]]] = contextvars.ContextVar(
+    "db_capture", default=None
+)
+
+
+def start_capture() -> contextvars.Token:
+    """Record the statements of the current work; returns a token for stop_capture."""
+    return _capture.set([])
+
+
+def capturing() -> bool:
+    """Whether the current work records its statements."""
+    return _capture.get() is not None
+
+
+def stop_capture(token: contextvars.Token) -> List[CapturedQuery]:
+    """Stop recording statements; returns those recorded, in the order they ran."""
+    captured = _capture.get() or []
+    _capture.reset(token)
+    return captured
+
+
+def start_tracing(request_id: str, tenant_id: str) -> contextvars.Token:
+    """Log the statements of the current work; returns a token for stop_tracing."""
+    return _trace.set((request_id, tenant_id))
+
+
+def stop_tracing(token: contextvars.Token) -> None:
+    """Stop logging statements."""
+    _trace.reset(token)
+
+
+async def trace_queries(conn: Any) -> None:
+    """Connection init callback that logs the statements of traced work."""
+    conn.add_query_logger(_log_query)
+
+
+def _log_query(record: Any) -> None:
+    captured = _capture.get()
+    if captured is not None:
+        captured.append(CapturedQuery(
+            query=record.query,
+            args=tuple(record.args or ()),
+            database=getattr(record.conn_params, "database", "") or "",
+            duration_ms=round(record.elapsed * 1000, 2),
+            error=str(record.exception) if record.exception else None,
+        ))
+    trace = _trace.get()
+    if trace is None:
+        return
+    request_id, tenant_id = trace
+    args = json.dumps(list(record.args or ()), default=str)
+    entry = {
+        "request_id": request_id,
+        "tenant_id": tenant_id,
+        "query": " ".join(record.query.split()),
+        "args": args if len(args) <= MAX_LOGGED_ARGS else args[:MAX_LOGGED_ARGS] + "...",
+        "duration_ms": round(record.elapsed * 1000, 2),
+        "error": str(record.exception) if record.exception else None,
+    }
+    debug_logger.info(json.dumps(entry))
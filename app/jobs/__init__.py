@@ -0,0 +1,11 @@
+"""
+Background jobs run by the server process.
+"""
+
+from app.jobs.periodic import PeriodicJob
+from app.jobs.side_effects import SideEffectQueue
+
+__all__ = [
+    "PeriodicJob",
+    "SideEffectQueue",
+]
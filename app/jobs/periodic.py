@@ -0,0 +1,56 @@
+"""
+Periodic background job runner.
+"""
+
+import asyncio
+import logging
+from typing import Awaitable, Callable, Optional
+
+from app.db.priority import BATCH, set_priority
+
+logger = logging.getLogger(__name__)
+
+
+class PeriodicJob:
+    """Runs an async function every interval_seconds until stopped.
+
+    Failures are logged and do not stop the job; the next run happens on schedule.
+    Scheduled runs use batch priority (see app/db/priority.py).
+    """
+
+    def __init__(self, name: str, interval_seconds: float, fn: Callable[[], Awaitable[object]]):
+        self.name = name
+        self.interval_seconds = interval_seconds
+        self.fn = fn
+        self._task: Optional[asyncio.Task] = None
+
+    def start(self) -> None:
+        """Start running the job on the current event loop."""
+        if self._task is None:
+            self._task = asyncio.get_running_loop().create_task(self._loop())
+            logger.info(f"Started job {self.name} (every {self.interval_seconds}s)")
+
+    async def stop(self) -> None:
+        """Stop the job and wait for a run in progress to be cancelled."""
+        if self._task is None:
+            return
+        self._task.cancel()
+        try:
+            await self._task
+        except asyncio.CancelledError:
+            pass
+        self._task = None
+
+    async def run_once(self) -> object:
+        """Run the job immediately, logging rather than raising failures."""
+        try:
+            return await self.fn()
+        except Exception as e:
+            logger.error(f"Job {self.name} failed: {e}")
+            return None
+
+    async def _loop(self) -> None:
+        set_priority(BATCH)
+        while True:
+            await self.run_once()
+            await asyncio.sleep(self.interval_seconds)
@@ -0,0 +1,110 @@
+"""
+Detached side effects of calls.
+
+Some work a call triggers must not share the call's fate: an audit record
+must be written even when the client disconnects and the call is cancelled,
+and an alert webhook must not hold the call for its timeout. Such work is
+submitted to a SideEffectQueue instead of being awaited by the call.
+
+Workers run each item under its own deadline, in a context of their own
+rather than the caller's (no request context, query tracing or capture,
+and batch priority), so cancelling the call does not cancel the work.
+The queue is bounded: when it is full, new work is dropped and logged
+rather than piling up behind a slow dependency. On shutdown, queued work
+gets a grace period to finish.
+"""
+
+import asyncio
+import logging
+import time
+from typing import Awaitable, Callable, List, Optional
+
+from app.db.priority import BATCH, set_priority
+from app.metrics import metrics
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_MAX_PENDING = 1000
+DEFAULT_WORKERS = 4
+DEFAULT_DEADLINE_SECONDS = 30.0
+
+SideEffect = Callable[[], Awaitable[object]]
+
+
+class SideEffectQueue:
+    """Runs submitted work on background workers, each item under a deadline."""
+
+    def __init__(
+        self,
+        max_pending: int = DEFAULT_MAX_PENDING,
+        workers: int = DEFAULT_WORKERS,
+        deadline_seconds: float = DEFAULT_DEADLINE_SECONDS,
+    ):
+        self.max_pending = max_pending
+        self.workers = workers
+        self.deadline_seconds = deadline_seconds
+        self._queue: Optional[asyncio.Queue] = None
+        self._tasks: List[asyncio.Task] = []
+
+    def start(self) -> None:
+        """Start the workers on the current event loop."""
+        if self._tasks:
+            return
+        self._queue = asyncio.Queue(self.max_pending)
+        loop = asyncio.get_running_loop()
+        self._tasks = [loop.create_task(self._work()) for _ in range(self.workers)]
+        logger.info(f"Started {self.workers} side effect workers (up to {self.max_pending} pending)")
+
+    def submit(self, name: str, fn: SideEffect) -> bool:
+        """
+        Queue work to run detached from the caller; returns False if it was dropped.
+
+        Never blocks, so it can be called while the caller is being cancelled.
+        """
+        if self._queue is None:
+            logger.error(f"Side effect {name} dropped: the queue is not running")
+            metrics.inc("side_effects_dropped_total", labels={"name": name})
+            return False
+        try:
+            self._queue.put_nowait((name, fn, time.monotonic()))
+        except asyncio.QueueFull:
+            logger.error(f"Side effect {name} dropped: {self.max_pending} side effects are pending")
+            metrics.inc("side_effects_dropped_total", labels={"name": name})
+            return False
+        return True
+
+    def pending(self) -> int:
+        """Number of queued items not yet started."""
+        return self._queue.qsize() if self._queue else 0
+
+    async def stop(self, grace_seconds: float = 10.0) -> None:
+        """Give queued work up to grace_seconds to finish, then stop the workers."""
+        if not self._tasks:
+            return
+        try:
+            await asyncio.wait_for(self._queue.join(), grace_seconds)
+        except asyncio.TimeoutError:
+            logger.error(f"Stopping with {self.pending()} side effects not run")
+        for task in self._tasks:
+            task.cancel()
+        await asyncio.gather(*self._tasks, return_exceptions=True)
+        self._tasks = []
+        self._queue = None
+
+    async def _work(self) -> None:
+        set_priority(BATCH)
+        while True:
+            name, fn, queued_at = await self._queue.get()
+            metrics.observe("side_effect_queue_seconds", time.monotonic() - queued_at)
+            try:
+                # wait_for runs the work in a task of its own, so context changes do not leak between items
+                await asyncio.wait_for(fn(), self.deadline_seconds)
+            except asyncio.TimeoutError:
+                logger.error(f"Side effect {name} did not finish within {self.deadline_seconds}s")
+                metrics.inc("side_effects_failed_total", labels={"name": name})
+            except Exception as e:
+                logger.error(f"Side effect {name} failed: {e}")
+                metrics.inc("side_effects_failed_total", labels={"name": name})
+            finally:
+                self._queue.task_done()
+
@@ -0,0 +1,42 @@
+"""
+Usage counting for billing.
+
+Counts each successful JSON-RPC call against a tenant, and the size of the
+results of export methods, into a UsageRecorder that BillingService flushes
+as billing events (see app/service/billing_service.py).
+"""
+
+import json
+
+from jsonrpcserver import Result
+
+from app.authz.impersonation import call_tenant_id
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall, result_error_code
+from app.service.billing_service import UsageRecorder
+
+# Methods whose result size is counted as export_bytes
+EXPORT_METHODS = frozenset({"export_tenant", "export_tenant_changes", "export_graph"})
+
+
+def billing_interceptor(recorder: UsageRecorder) -> Interceptor:
+    """
+    Create an interceptor that counts tenant calls and export bytes.
+
+    Register it after the external ID interceptor, so it sees stored tenant IDs.
+    """
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        result = await call_next(call)
+        tenant_id = call_tenant_id(call)
+        if not tenant_id or result_error_code(result) is not None:
+            return result
+        recorder.record(tenant_id, "api_calls", call.method)
+        if call.method in EXPORT_METHODS:
+            # Results are Either values wrapping a SuccessResult
+            value = getattr(result, "_value", None)
+            if value is not None and hasattr(value, "result"):
+                size = len(json.dumps(value.result, separators=(",", ":"), default=str).encode())
+                recorder.record(tenant_id, "export_bytes", call.method, size)
+        return result
+
+    return interceptor
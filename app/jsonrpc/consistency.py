@@ -0,0 +1,38 @@
+"""
+Consistency tokens on JSON-RPC write results.
+
+Every successful write to a tenant returns a ``consistency_token`` alongside
+its result. Passing it to a read that accepts one (search_nodes) guarantees
+the read observes that write (see app/service/consistency.py).
+"""
+
+from typing import Awaitable, Callable
+
+from jsonrpcserver import Result, Success
+
+from app.authz.impersonation import call_tenant_id
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall, result_error_code
+from app.jsonrpc.maintenance import is_write_method
+
+TOKEN_KEY = "consistency_token"
+
+
+def consistency_interceptor(commit_position: Callable[[str], Awaitable[str]]) -> Interceptor:
+    """
+    Create an interceptor that adds a consistency token to the results of tenant writes.
+
+    commit_position returns a tenant's first unassigned transaction ID.
+    """
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        result = await call_next(call)
+        tenant_id = call_tenant_id(call)
+        if not tenant_id or not is_write_method(call.method) or result_error_code(result) is not None:
+            return result
+        # Results are Either values wrapping a SuccessResult
+        value = getattr(result, "_value", None)
+        if value is None or not isinstance(getattr(value, "result", None), dict):
+            return result
+        return Success({**value.result, TOKEN_KEY: await commit_position(tenant_id)})
+
+    return interceptor
@@ -0,0 +1,83 @@
+"""
+Per-request context for JSON-RPC calls.
+
+The HTTP layer stores request metadata in a context variable so that
+interceptors and handlers can read it without threading it through every
+method signature.
+"""
+
+import contextvars
+import uuid
+from dataclasses import dataclass, field
+from typing import Any, Dict, Optional
+
+# Header carrying the ID of the user making the request
+SUBJECT_HEADER = "x-user-id"
+
+
+@dataclass
+class RequestContext:
+    """Metadata about the HTTP request carrying a JSON-RPC call."""
+    request_id: str = field(default_factory=lambda: str(uuid.uuid4()))
+    headers: Dict[str, str] = field(default_factory=dict)
+    client_host: str = ""
+    subject_id: str = ""
+    # Free-form values set by interceptors during the request
+    attributes: Dict[str, Any] = field(default_factory=dict)
+
+    @classmethod
+    def from_headers(cls, headers: Dict[str, str], client_host: str = "") -> "RequestContext":
+        """Build a context from (case-insensitive) HTTP headers."""
+        normalized = {k.lower(): v for k, v in headers.items()}
+        return cls(
+            request_id=normalized.get("x-request-id") or str(uuid.uuid4()),
+            headers=normalized,
+            client_host=client_host,
+            subject_id=normalized.get(SUBJECT_HEADER, ""),
+        )
+
+    def admin_subject_id(self) -> str:
+        """
+        The subject administrator checks may trust: subject_id when a credential
+        established it, else "". Any client can set the X-User-ID header, so it
+        never makes an administrator; an admin-scoped API key, a mapped client
+        certificate or a signed request (with an unscoped or admin-scoped
+        signing key) does. Impersonated calls act within one tenant and are
+        never administrative.
+        """
+        if "impersonation" in self.attributes:
+            return ""
+        # Signatures are verified before dispatch, then the client certificate and API key
+        # interceptors run; the last credential present set subject_id
+        if "api_key" in self.attributes:
+            return self.subject_id if self.attributes["api_key"].get("scope") == "admin" else ""
+        if "client_certificate" in self.attributes:
+            return self.subject_id
+        signature = self.attributes.get("request_signature")
+        if signature and signature.get("scope", "") in ("", "admin"):
+            return self.subject_id
+        return ""
+
+
+_current: contextvars.ContextVar[Optional[RequestContext]] = contextvars.ContextVar(
+    "request_context", default=None
+)
+
+
+def current_context() -> RequestContext:
+    """Return the context of the request being served (empty outside a request)."""
+    ctx = _current.get()
+    if ctx is None:
+        ctx = RequestContext()
+        _current.set(ctx)
+    return ctx
+
+
+def set_request_context(ctx: RequestContext) -> contextvars.Token:
+    """Install a request context; returns a token for reset_request_context."""
+    return _current.set(ctx)
+
+
+def reset_request_context(token: contextvars.Token) -> None:
+    """Restore the previous request context."""
+    _current.reset(token)
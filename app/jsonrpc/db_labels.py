@@ -0,0 +1,22 @@
+"""
+Database connection labels for JSON-RPC calls (see app/db/labels.py).
+"""
+
+from jsonrpcserver import Result
+
+from app.authz.impersonation import call_tenant_id
+from app.db.labels import reset_label, set_label
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+
+
+def db_label_interceptor() -> Interceptor:
+    """Create an interceptor labeling the database connections of a call with its tenant and method."""
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        token = set_label(call_tenant_id(call) or "", call.method)
+        try:
+            return await call_next(call)
+        finally:
+            reset_label(token)
+
+    return interceptor
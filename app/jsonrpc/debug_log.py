@@ -0,0 +1,71 @@
+"""
+Verbose logging of the calls to tenants with debug logging on (see app/service/tenant_debug.py).
+
+Each call to such a tenant is logged as one JSON line on the
+``flexdb.debug`` logger with its parameters and its result or error
+("requests" scope), and its SQL statements are traced ("queries" scope).
+"""
+
+import json
+import time
+
+from jsonrpcserver import Result
+
+from app.authz.impersonation import call_tenant_id
+from app.db.tracing import debug_logger, start_tracing, stop_tracing
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+from app.service.tenant_debug import TenantDebugCache
+
+# Characters of a call's parameters or outcome logged
+MAX_LOGGED_CHARS = 16384
+
+
+def _truncate(value: object) -> str:
+    text = json.dumps(value, default=str)
+    return text if len(text) <= MAX_LOGGED_CHARS else text[:MAX_LOGGED_CHARS] + "..."
+
+
+def _outcome(result: Result) -> dict:
+    # Results are Either values wrapping an ErrorResult or SuccessResult
+    value = getattr(result, "_value", result)
+    code = getattr(value, "code", None)
+    if isinstance(code, int):
+        return {"error": {"code": code, "message": getattr(value, "message", "")}}
+    return {"result": getattr(value, "result", None)}
+
+
+def debug_log_interceptor(cache: TenantDebugCache) -> Interceptor:
+    """Create an interceptor that logs and traces the calls to tenants with debug logging on.
+
+    Register it after ID translation, so tenant IDs are the stored ones.
+    """
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        tenant_id = call_tenant_id(call)
+        scopes = await cache.scopes(tenant_id) if tenant_id else ()
+        if not scopes:
+            return await call_next(call)
+
+        request_id = call.context.request_id
+        token = start_tracing(request_id, tenant_id) if "queries" in scopes else None
+        started = time.monotonic()
+        result = None
+        try:
+            result = await call_next(call)
+            return result
+        finally:
+            if token is not None:
+                stop_tracing(token)
+            if "requests" in scopes:
+                outcome = _outcome(result) if result is not None else {"error": {"code": -32603}}
+                debug_logger.info(json.dumps({
+                    "request_id": request_id,
+                    "tenant_id": tenant_id,
+                    "subject_id": call.context.subject_id,
+                    "method": call.method,
+                    "params": _truncate(call.params),
+                    "duration_ms": round((time.monotonic() - started) * 1000, 2),
+                    **{key: _truncate(value) for key, value in outcome.items()},
+                }))
+
+    return interceptor
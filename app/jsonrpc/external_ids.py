@@ -0,0 +1,144 @@
+"""
+Opaque external IDs for JSON-RPC calls.
+
+IDs are stored as UUIDs. With an external ID codec configured, responses
+carry an opaque encoding of each ID instead, and opaque IDs in parameters are
+translated back before the method runs, so services and storage keep the
+UUIDs. Clients see IDs that cannot be ordered, compared or enumerated.
+
+The translation applies to the values of ``id`` and of every key ending in
+``_id`` or ``_ids`` (strings or arrays of strings), at any depth of the
+parameters and results, except inside tenant documents (``data``,
+``metadata`` and the like), which are returned as stored. Only UUIDs are
+encoded. Parameters that are not external IDs, including internal UUIDs,
+pass through unchanged, so operators can still use the IDs they see in the
+database and logs.
+
+The default codec, AesIdCodec, encrypts the 16 UUID bytes as one AES block
+with a server key and writes them in 26 characters of lowercase base32. The
+translation is a permutation: every UUID has exactly one external ID.
+Another codec (such as a hashids style encoding) can be plugged in by
+implementing IdCodec.
+"""
+
+import base64
+import uuid
+from typing import Any, Optional, Protocol
+
+from cryptography.hazmat.primitives.ciphers import Cipher, algorithms, modes
+from jsonrpcserver import Result, Success
+
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall, result_error_code
+from app.jsonrpc.json_limits import DOCUMENT_KEYS
+
+KEY_BYTES = 32
+# Tenant documents, returned as stored (IDs in them are the tenant's own data)
+_OPAQUE_KEYS = set(DOCUMENT_KEYS) | {"data_object", "definition", "annotations"}
+
+
+class IdCodec(Protocol):
+    """Reversible translation between internal UUIDs and external IDs."""
+
+    def encode(self, internal_id: uuid.UUID) -> str:
+        """Return the external ID of an internal ID."""
+        ...
+
+    def decode(self, external_id: str) -> Optional[uuid.UUID]:
+        """Return the internal ID of an external ID, or None if the value is not an external ID."""
+        ...
+
+
+class AesIdCodec:
+    """External IDs that are UUIDs encrypted with AES-256, in lowercase base32."""
+
+    def __init__(self, key: str):
+        """
+        Create a codec from a base64-encoded 32-byte key.
+
+        Raises:
+            ValueError: If the key is not 32 base64-encoded bytes
+        """
+        try:
+            raw = base64.b64decode(key, validate=True)
+        except ValueError:
+            raise ValueError("EXTERNAL_ID_KEY must be base64")
+        if len(raw) != KEY_BYTES:
+            raise ValueError(f"EXTERNAL_ID_KEY must encode {KEY_BYTES} bytes")
+        # One block at a time, so ECB is a keyed permutation of the 128-bit UUIDs
+        self._cipher = Cipher(algorithms.AES(raw), modes.ECB())
+
+    def encode(self, internal_id: uuid.UUID) -> str:
+        encryptor = self._cipher.encryptor()
+        block = encryptor.update(internal_id.bytes) + encryptor.finalize()
+        return base64.b32encode(block).decode("ascii").rstrip("=").lower()
+
+    def decode(self, external_id: str) -> Optional[uuid.UUID]:
+        if len(external_id) != 26:
+            return None
+        try:
+            block = base64.b32decode(external_id.upper() + "======")
+        except ValueError:
+            return None
+        decryptor = self._cipher.decryptor()
+        return uuid.UUID(bytes=decryptor.update(block) + decryptor.finalize())
+
+
+def _is_id_key(key: Any) -> bool:
+    return isinstance(key, str) and (key == "id" or key.endswith("_id") or key.endswith("_ids"))
+
+
+def _encode_id(value: Any, codec: IdCodec) -> Any:
+    if isinstance(value, list):
+        return [_encode_id(v, codec) for v in value]
+    if isinstance(value, str):
+        try:
+            internal = uuid.UUID(value)
+        except ValueError:
+            return value
+        if str(internal) == value:
+            return codec.encode(internal)
+    return value
+
+
+def _decode_id(value: Any, codec: IdCodec) -> Any:
+    if isinstance(value, list):
+        return [_decode_id(v, codec) for v in value]
+    if isinstance(value, str):
+        internal = codec.decode(value)
+        if internal is not None:
+            return str(internal)
+    return value
+
+
+def translate_ids(value: Any, codec: IdCodec, encode: bool) -> Any:
+    """Return value with the IDs under ID keys encoded (or decoded), leaving tenant documents untouched."""
+    if isinstance(value, list):
+        return [translate_ids(v, codec, encode) for v in value]
+    if not isinstance(value, dict):
+        return value
+    translated = {}
+    for key, item in value.items():
+        if key in _OPAQUE_KEYS:
+            translated[key] = item
+        elif _is_id_key(key) and (isinstance(item, (str, list))):
+            translated[key] = _encode_id(item, codec) if encode else _decode_id(item, codec)
+        else:
+            translated[key] = translate_ids(item, codec, encode)
+    return translated
+
+
+def external_id_interceptor(codec: IdCodec) -> Interceptor:
+    """Create an interceptor that translates external IDs in parameters and results."""
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        call.params = translate_ids(call.params, codec, encode=False)
+        result = await call_next(call)
+        if result_error_code(result) is not None:
+            return result
+        # Results are Either values wrapping a SuccessResult
+        value = getattr(result, "_value", None)
+        if value is None or not hasattr(value, "result"):
+            return result
+        return Success(translate_ids(value.result, codec, encode=True))
+
+    return interceptor
@@ -2,54 +2,338 @@
 JSON-RPC handlers for all services.
 """
 
-from typing import Any, Dict, List, Optional
+import inspect
+import json
+import re
+import time
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple, Union
 from jsonrpcserver import method, Result, Success, Error
+from jsonrpcserver.methods import global_methods
 
 from app.service import (
     TenantService,
     UserService,
+    AuthzPolicyService,
+    AuditService,
+    ImpersonationService,
+    StatsService,
+    TemplateService,
+    TenantKeyService,
+    BillingService,
+    TenantComparisonService,
+    ExportScheduleService,
+    AdminSearchService,
+    TableMaintenanceService,
+    ExplainService,
+    ApiKeyService,
+    OnboardingService,
+    DualWriteService,
+    ShardService,
 )
-from app.repository.errors import NotFoundError
-from app.api.dependencies import resolve_tenant_services
+from app.repository.errors import (
+    AlreadyExistsError,
+    DataResidencyError,
+    DuplicateRelationshipError,
+    FieldViolationError,
+    NotFoundError,
+    PermissionDeniedError,
+    PreconditionFailedError,
+    SlugTakenError,
+)
+from app.service.bulk_service import parse_node_filter
+from app.service.export_transforms import ExportTransform, parse_transform
+from app.service.graph_formats import GRAPH_FORMATS
+from app.service.node_view_service import combine_filters
+from app.service.node_import import IMPORT_KIND as NODE_IMPORT_KIND
+from app.service.relationship_import import IMPORT_KIND as RELATIONSHIP_IMPORT_KIND
+from app.api.dependencies import (
+    get_change_stream_manager,
+    get_read_session_manager,
+    get_tenant_db,
+    resolve_tenant_services,
+)
+from app.jsonrpc.context import current_context
+from app.jsonrpc.interceptors import result_error_code
+
+# Entity data may be sent as a JSON object or, for older clients, a JSON-encoded string
+JsonData = Union[Dict[str, Any], str]
 
 # Global service instances (to be set by register_methods)
 _tenant_service: Optional[TenantService] = None
 _user_service: Optional[UserService] = None
+_authz_policy_service: Optional[AuthzPolicyService] = None
+_impersonation_service: Optional[ImpersonationService] = None
+_audit_service: Optional[AuditService] = None
+_stats_service: Optional[StatsService] = None
+_template_service: Optional[TemplateService] = None
+_tenant_key_service: Optional[TenantKeyService] = None
+_billing_service: Optional[BillingService] = None
+_comparison_service: Optional[TenantComparisonService] = None
+_export_schedule_service: Optional[ExportScheduleService] = None
+_admin_search_service: Optional[AdminSearchService] = None
+_table_maintenance_service: Optional[TableMaintenanceService] = None
+_explain_service: Optional[ExplainService] = None
+_api_key_service: Optional[ApiKeyService] = None
+_onboarding_service: Optional[OnboardingService] = None
+_dual_write_service: Optional[DualWriteService] = None
+_shard_service: Optional[ShardService] = None
 
 
 def register_methods(
     tenant_svc: TenantService,
     user_svc: UserService,
+    authz_policy_svc: Optional[AuthzPolicyService] = None,
+    impersonation_svc: Optional[ImpersonationService] = None,
+    audit_svc: Optional[AuditService] = None,
+    stats_svc: Optional[StatsService] = None,
+    template_svc: Optional[TemplateService] = None,
+    tenant_key_svc: Optional[TenantKeyService] = None,
+    billing_svc: Optional[BillingService] = None,
+    comparison_svc: Optional[TenantComparisonService] = None,
+    export_schedule_svc: Optional[ExportScheduleService] = None,
+    admin_search_svc: Optional[AdminSearchService] = None,
+    table_maintenance_svc: Optional[TableMaintenanceService] = None,
+    explain_svc: Optional[ExplainService] = None,
+    api_key_svc: Optional[ApiKeyService] = None,
+    onboarding_svc: Optional[OnboardingService] = None,
+    dual_write_svc: Optional[DualWriteService] = None,
+    shard_svc: Optional[ShardService] = None,
 ) -> None:
     """Register service instances for use by JSON-RPC methods."""
-    global _tenant_service, _user_service
+    global _tenant_service, _user_service, _authz_policy_service, _impersonation_service, _audit_service
+    global _stats_service, _template_service, _tenant_key_service, _billing_service, _comparison_service
+    global _export_schedule_service, _admin_search_service, _table_maintenance_service, _explain_service
+    global _api_key_service, _onboarding_service, _dual_write_service, _shard_service
     _tenant_service = tenant_svc
     _user_service = user_svc
+    _authz_policy_service = authz_policy_svc
+    _impersonation_service = impersonation_svc
+    _audit_service = audit_svc
+    _stats_service = stats_svc
+    _template_service = template_svc
+    _tenant_key_service = tenant_key_svc
+    _billing_service = billing_svc
+    _comparison_service = comparison_svc
+    _export_schedule_service = export_schedule_svc
+    _admin_search_service = admin_search_svc
+    _table_maintenance_service = table_maintenance_svc
+    _explain_service = explain_svc
+    _api_key_service = api_key_svc
+    _onboarding_service = onboarding_svc
+    _dual_write_service = dual_write_svc
+    _shard_service = shard_svc
+
+
+# Validation messages that start with the parameter they are about, e.g. "limit must be between 1 and 1000"
+_FIELD_MESSAGE = re.compile(r"^([a-z][a-z0-9_]*(?:\.[a-z0-9_]+)*) (?:is|are|must|cannot) ")
+
+
+def _field_violations(err: ValueError) -> List[Dict[str, str]]:
+    """The offending fields of a validation error, from FieldViolationError or the message."""
+    violations = getattr(err, "violations", None)
+    if violations is None:
+        match = _FIELD_MESSAGE.match(str(err))
+        violations = [(match.group(1), str(err))] if match else []
+    return [{"field": field, "description": description} for field, description in violations]
 
 
 def _handle_error(err: Exception) -> Error:
-    """Convert exception to JSON-RPC error."""
+    """
+    Convert exception to JSON-RPC error.
+
+    Validation errors naming their fields carry them as error data
+    {"field_violations": [{"field", "description"}]}, so clients can point at
+    the offending input without parsing the message. Taken tenant slugs
+    carry the suggested alternatives as {"suggested_slugs": [...]}, and
+    rejected duplicate relationships the existing one as
+    {"existing_relationship_id": ...}. Data residency refusals carry
+    {"tenant_region", "database_region"}.
+    """
     if isinstance(err, NotFoundError):
         return Error(-32001, str(err))
+    if isinstance(err, DataResidencyError):
+        return Error(-32003, str(err), {"tenant_region": err.tenant_region, "database_region": err.database_region})
+    if isinstance(err, PermissionDeniedError):
+        return Error(-32003, str(err))
+    if isinstance(err, PreconditionFailedError):
+        return Error(-32004, str(err))
+    if isinstance(err, SlugTakenError) and err.suggestions:
+        return Error(-32005, str(err), {"suggested_slugs": err.suggestions})
+    if isinstance(err, DuplicateRelationshipError):
+        return Error(-32005, str(err), {"existing_relationship_id": err.existing_id})
+    if isinstance(err, AlreadyExistsError):
+        return Error(-32005, str(err))
     if isinstance(err, ValueError):
+        violations = _field_violations(err)
+        if violations:
+            return Error(-32602, str(err), {"field_violations": violations})
         return Error(-32602, str(err))
     return Error(-32603, str(err))
 
 
+def _data_param(data: Any) -> str:
+    """
+    Normalize a data parameter to a JSON string, rejecting malformed JSON.
+
+    An empty string is passed through (it means "unchanged" on updates).
+    """
+    if data is None or data == "":
+        return ""
+    if isinstance(data, str):
+        try:
+            json.loads(data)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"data must be valid JSON: {e}") from e
+        return data
+    if isinstance(data, dict):
+        return json.dumps(data)
+    raise ValueError("data must be a JSON object or a JSON-encoded string")
+
+
+def _apply_read_mask(entity: Dict[str, Any], fields: Optional[List[str]]) -> Dict[str, Any]:
+    """
+    Project an entity dict onto the fields named in a read mask.
+
+    An empty mask returns the entity unchanged; "id" is always included.
+    """
+    if not fields:
+        return entity
+    unknown = [f for f in fields if f not in entity]
+    if unknown:
+        raise FieldViolationError(
+            f"unknown fields in read mask: {', '.join(unknown)} (allowed: {', '.join(entity)})",
+            [("fields", f"unknown field {f!r}") for f in unknown],
+        )
+    return {k: v for k, v in entity.items() if k == "id" or k in fields}
+
+
+def _mask_needs_data(fields: Optional[List[str]]) -> bool:
+    """Whether a read mask requires the entity data payload to be loaded."""
+    return not fields or "data" in fields or "data_object" in fields
+
+
+def _expand_param(services: Dict[str, Any], expand: Optional[List[str]], as_of: str) -> List[Any]:
+    """Parse an expand parameter; expansions always reflect current state."""
+    if expand and as_of:
+        raise ValueError("expand cannot be combined with valid_at or recorded_at")
+    return services["expansion"].parse(expand)
+
+
+async def _expanded(
+    services: Dict[str, Any], entities: List[Any], fields: Optional[List[str]], specs: List[Any]
+) -> List[Dict[str, Any]]:
+    """Serialize entities with a read mask, embedding expansions under "expanded"."""
+    results = [_apply_read_mask(e.to_dict(), fields) for e in entities]
+    if specs:
+        expanded = await services["expansion"].expand(entities, specs)
+        for result, entity in zip(results, entities):
+            result["expanded"] = expanded[entity.id]
+    return results
+
+
+async def _view_scope(
+    services: Dict[str, Any], view: str, view_params: Optional[Dict[str, Any]], narrowing: Dict[str, Any]
+) -> Tuple[Optional[Dict[str, Any]], Dict[str, Any], str]:
+    """
+    Resolve a view read by a call, narrowed by the call's own filter keys; returns
+    its filter, its projection and its version (None, {} and "" without a view).
+    """
+    if not view:
+        if view_params:
+            raise ValueError("view_params requires view")
+        return None, {}, ""
+    resolved = await services["node_view"].resolve(view, view_params)
+    return combine_filters(resolved.filter, narrowing), resolved.projection, resolved.view.updated_at.isoformat()
+
+
+def _project(nodes: List[Any], projection: Optional[ExportTransform]) -> List[Any]:
+    return [projection.node(n) for n in nodes] if projection else nodes
+
+
+def _not_modified(etag: str, if_none_match: str) -> bool:
+    """Whether a conditional read's If-None-Match (one or more comma-separated etags) matches."""
+    if not if_none_match:
+        return False
+    return if_none_match.strip() == "*" or etag in [t.strip() for t in if_none_match.split(",")]
+
+
 # ============================================================================
 # Tenant Service Methods
 # ============================================================================
 
 @method
-async def create_tenant(slug: str, name: str) -> Result:
-    """Create a new tenant."""
+async def create_tenant(
+    slug: str,
+    name: str,
+    annotations: Dict[str, str] = None,
+    from_template: str = "",
+    parent_id: str = "",
+    timezone: str = "",
+    suggest_slugs: bool = False,
+    region: str = ""
+) -> Result:
+    """
+    Create a new tenant.
+
+    annotations: Free-form string metadata, e.g. {"tier": "gold"}
+    from_template: Name of a tenant template to create node types, relationship types and seed data from
+    parent_id: Organization tenant to create this tenant under; it inherits the parent's settings and types
+    timezone: IANA time zone (e.g. "Europe/Berlin") that date histograms bucket by; default UTC
+    suggest_slugs: When the slug is taken, list available alternatives in the error data
+    region: Data residency region to store the tenant's data in (default: DEFAULT_REGION); cannot be changed
+    """
     try:
-        tenant = await _tenant_service.create(slug, name)
+        tenant = await _tenant_service.create(
+            slug, name, annotations, from_template, parent_id, timezone, suggest_slugs, region
+        )
         return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
+@method
+async def provision_tenant(
+    slug: str,
+    name: str,
+    annotations: Dict[str, str] = None,
+    from_template: str = "",
+    parent_id: str = "",
+    timezone: str = "",
+    api_key: Dict[str, Any] = None,
+    write_hooks: List[Dict[str, Any]] = None,
+    region: str = ""
+) -> Result:
+    """
+    Create a tenant, an API key for it and its write hooks in one call; if a step fails, nothing is left behind.
+
+    slug, name, annotations, from_template, parent_id, timezone, region: As for create_tenant
+    api_key: {"name", "scope", "ttl_seconds"} of the key issued for the tenant (default scope: admin)
+    write_hooks: create_write_hook settings, with "node_type" naming a node type instead of node_type_id
+    """
+    try:
+        if _onboarding_service is None:
+            raise RuntimeError("tenant provisioning is not configured")
+        # The key is issued to the caller, so X-User-ID alone does not identify them
+        provisioned = await _onboarding_service.provision(
+            current_context().admin_subject_id(), slug, name, annotations, from_template, parent_id, timezone,
+            api_key, write_hooks, region,
+        )
+        return Success(provisioned.to_dict())
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def check_slug_availability(slug: str) -> Result:
+    """Check whether a tenant slug is free; a taken slug comes with available alternatives."""
+    try:
+        available, suggestions = await _tenant_service.check_slug_availability(slug)
+        return Success({"slug": slug, "available": available, "suggested_slugs": suggestions})
+    except Exception as e:
+        return _handle_error(e)
+
+
 @method
 async def get_tenant(id: str) -> Result:
     """Get a tenant by ID."""
@@ -61,276 +345,422 @@ async def get_tenant(id: str) -> Result:
 
 
 @method
-async def update_tenant(id: str, slug: str = "", name: str = "", status: str = "") -> Result:
-    """Update an existing tenant."""
+async def get_tenant_limits(tenant_id: str) -> Result:
+    """Get a tenant's effective page size, traversal depth and batch size limits."""
     try:
-        tenant = await _tenant_service.update(id, slug, name, status)
-        return Success({"tenant": tenant.to_dict()})
+        limits = await _tenant_service.get_limits(tenant_id)
+        return Success({"limits": limits.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def delete_tenant(id: str) -> Result:
-    """Delete a tenant."""
+async def set_tenant_limits(tenant_id: str, limits: Dict[str, Any]) -> Result:
+    """
+    Override some of a tenant's limits; returns the effective limits.
+
+    limits: {"default_page_size", "max_page_size", "max_traversal_depth", "max_batch_size"}; null restores the default
+    """
     try:
-        await _tenant_service.delete(id)
-        return Success({})
+        effective = await _tenant_service.set_limits(tenant_id, limits)
+        return Success({"limits": effective.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def list_tenants(pagination: Dict[str, Any] = None) -> Result:
-    """List tenants with pagination."""
+async def get_tenant_features(tenant_id: str) -> Result:
+    """
+    Get a tenant's effective feature flags, including those inherited from its organization.
+
+    sources maps each flag to the ID of the tenant it is set on.
+    """
     try:
-        page_size = 10
-        page_token = ""
-        if pagination:
-            page_size = pagination.get("page_size", 10)
-            page_token = pagination.get("page_token", "")
-        
-        tenants, result = await _tenant_service.list(page_size, page_token)
-        return Success({
-            "tenants": [t.to_dict() for t in tenants],
-            "pagination": result.to_dict(),
-        })
+        features, sources = await _tenant_service.get_features(tenant_id)
+        return Success({"features": features, "sources": sources})
     except Exception as e:
         return _handle_error(e)
 
 
-# ============================================================================
-# User Service Methods
-# ============================================================================
+@method
+async def set_tenant_features(tenant_id: str, features: Dict[str, Any]) -> Result:
+    """
+    Set some of a tenant's feature flags; returns the effective flags.
+
+    features: {"name": true | false}; null removes the tenant's own value so it is inherited again
+    """
+    try:
+        features, sources = await _tenant_service.set_features(tenant_id, features)
+        return Success({"features": features, "sources": sources})
+    except Exception as e:
+        return _handle_error(e)
+
 
 @method
-async def create_user(email: str, display_name: str) -> Result:
-    """Create a new user."""
+async def set_tenant_maintenance(id: str, enabled: bool, reason: str = "") -> Result:
+    """
+    Put a tenant into read-only maintenance mode, or end it.
+
+    While enabled, calls that write to the tenant fail with -32004 and the reason
+    (e.g. "restoring from backup until 14:00 UTC"); reads keep working.
+    """
     try:
-        user = await _user_service.create(email, display_name)
-        return Success({"user": user.to_dict()})
+        tenant = await _tenant_service.set_maintenance(id, enabled, reason)
+        return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def get_user(id: str) -> Result:
-    """Get a user by ID."""
+async def set_tenant_debug(id: str, scopes: List[str], ttl_seconds: int = 0) -> Result:
+    """
+    Switch on verbose logging for one tenant for a limited time, or off with no scopes.
+
+    scopes: "requests" (calls with their parameters and results) and/or "queries" (SQL statements)
+    ttl_seconds: How long logging stays on (default 900, max 14400)
+    """
     try:
-        user = await _user_service.get_by_id(id)
-        return Success({"user": user.to_dict()})
+        _require_impersonation_service().require_admin(current_context().admin_subject_id())
+        tenant = await _tenant_service.set_debug(id, scopes, ttl_seconds)
+        return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def update_user(id: str, email: str = "", display_name: str = "") -> Result:
-    """Update an existing user."""
+async def set_tenant_parent(id: str, parent_id: str = "") -> Result:
+    """Move a tenant under an organization tenant, or make it top-level with an empty parent_id."""
     try:
-        user = await _user_service.update(id, email, display_name)
-        return Success({"user": user.to_dict()})
+        tenant = await _tenant_service.set_parent(id, parent_id)
+        return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def delete_user(id: str) -> Result:
-    """Delete a user."""
+async def sync_tenant_schemas(tenant_id: str) -> Result:
+    """
+    Push an organization's node and relationship types down to all of its sub-tenants,
+    creating or updating them by name; returns the created and updated counts per sub-tenant.
+    """
     try:
-        await _user_service.delete(id)
-        return Success({})
+        results = await _tenant_service.sync_schemas(tenant_id)
+        return Success({"tenants": results})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def list_users(pagination: Dict[str, Any] = None) -> Result:
-    """List users with pagination."""
+async def get_tenant_usage(tenant_id: str) -> Result:
+    """
+    Database size and node and relationship counts (estimates) of a tenant and
+    each of its sub-tenants, with totals for the whole organization.
+    """
+    try:
+        if _stats_service is None:
+            raise RuntimeError("statistics are not configured")
+        usage = await _stats_service.tenant_usage(tenant_id)
+        return Success({"usage": usage})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def compare_tenants(
+    tenant_id: str,
+    other_tenant_id: str = "",
+    database_name: str = "",
+    include_relationships: bool = True,
+    pagination: Dict[str, Any] = None
+) -> Result:
+    """
+    Compare a page of a tenant's nodes, then relationships, with another tenant or
+    a restored backup, matched by ID: added, removed and changed entities (admins only).
+
+    other_tenant_id: Tenant to compare with, such as a clone
+    database_name: Database of the cluster holding a restored backup of the tenant's database
+    """
     try:
-        page_size = 10
+        if _comparison_service is None:
+            raise RuntimeError("tenant comparison is not configured")
+        _require_impersonation_service().require_admin(current_context().admin_subject_id())
+        page_size = 0
         page_token = ""
         if pagination:
-            page_size = pagination.get("page_size", 10)
+            page_size = pagination.get("page_size", 0)
             page_token = pagination.get("page_token", "")
-        
-        users, result = await _user_service.list(page_size, page_token)
+
+        differences, compared, result = await _comparison_service.compare(
+            tenant_id, other_tenant_id, database_name, include_relationships, page_size, page_token
+        )
         return Success({
-            "users": [u.to_dict() for u in users],
+            "nodes": differences["nodes"],
+            "relationships": differences["relationships"],
+            "compared_count": compared,
             "pagination": result.to_dict(),
         })
     except Exception as e:
         return _handle_error(e)
 
 
+def _require_dual_write_service() -> DualWriteService:
+    if _dual_write_service is None:
+        raise RuntimeError("dual writes are not configured")
+    _require_impersonation_service().require_admin(current_context().admin_subject_id())
+    return _dual_write_service
+
+
 @method
-async def add_user_to_tenant(tenant_id: str, user_id: str, role: str = "") -> Result:
-    """Add a user to a tenant."""
+async def start_dual_write(tenant_id: str, database_name: str) -> Result:
+    """
+    Start mirroring a tenant's writes to a second database of the cluster during
+    a migration to a new storage backend; reads stay on its own database (admins only).
+
+    database_name: Database to mirror to, created and migrated if it does not exist
+    """
     try:
-        tenant_user = await _user_service.add_to_tenant(tenant_id, user_id, role)
-        return Success({"tenant_user": tenant_user.to_dict()})
+        target = await _require_dual_write_service().start(tenant_id, database_name)
+        return Success({"dual_write": target.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def remove_user_from_tenant(tenant_id: str, user_id: str) -> Result:
-    """Remove a user from a tenant."""
+async def get_dual_write(tenant_id: str) -> Result:
+    """Get the database a tenant's writes are mirrored to (admins only)."""
     try:
-        await _user_service.remove_from_tenant(tenant_id, user_id)
-        return Success({})
+        target = await _require_dual_write_service().get(tenant_id)
+        return Success({"dual_write": target.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def list_tenant_users(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
-    """List users in a tenant."""
+async def verify_dual_write(
+    tenant_id: str,
+    repair: bool = False,
+    include_relationships: bool = True,
+    pagination: Dict[str, Any] = None
+) -> Result:
+    """
+    Compare a page of a tenant's own database with the database its writes are
+    mirrored to: missing, extra and changed entities (admins only).
+
+    repair: Bring the differing entities of the mirror to their current state in the tenant's database
+    """
     try:
-        page_size = 10
+        service = _require_dual_write_service()
+        page_size = 0
         page_token = ""
         if pagination:
-            page_size = pagination.get("page_size", 10)
+            page_size = pagination.get("page_size", 0)
             page_token = pagination.get("page_token", "")
-        
-        tenant_users, result = await _user_service.list_tenant_users(tenant_id, page_size, page_token)
+
+        differences, compared, repaired, failed, result = await service.verify(
+            tenant_id, repair, include_relationships, page_size, page_token
+        )
         return Success({
-            "tenant_users": [tu.to_dict() for tu in tenant_users],
+            **differences,
+            "compared_count": compared,
+            "repaired_count": repaired,
+            "repair_failures": failed,
             "pagination": result.to_dict(),
         })
     except Exception as e:
         return _handle_error(e)
 
 
-# ============================================================================
-# NodeType Service Methods
-# ============================================================================
-
 @method
-async def create_node_type(tenant_id: str, name: str, description: str = "", schema: str = "") -> Result:
-    """Create a new node type."""
+async def stop_dual_write(tenant_id: str) -> Result:
+    """Stop mirroring a tenant's writes; the second database is kept (admins only)."""
     try:
-        services = await resolve_tenant_services(tenant_id)
-        node_type = await services["node_type"].create(name, description, schema)
-        return Success({"node_type": node_type.to_dict()})
+        await _require_dual_write_service().stop(tenant_id)
+        return Success({"success": True})
     except Exception as e:
         return _handle_error(e)
 
 
+def _require_shard_service() -> ShardService:
+    if _shard_service is None:
+        raise RuntimeError("sharding is not configured")
+    _require_impersonation_service().require_admin(current_context().admin_subject_id())
+    return _shard_service
+
+
 @method
-async def get_node_type(id: str, tenant_id: str) -> Result:
-    """Get a node type by ID."""
+async def list_shards() -> Result:
+    """List the shards tenant databases live on, with their health and tenant counts (admins only)."""
     try:
-        services = await resolve_tenant_services(tenant_id)
-        node_type = await services["node_type"].get_by_id(id)
-        return Success({"node_type": node_type.to_dict()})
+        shards = await _require_shard_service().list_shards()
+        return Success({"shards": shards})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def update_node_type(id: str, tenant_id: str, name: str = "", description: str = "", schema: str = "") -> Result:
-    """Update an existing node type."""
+async def plan_shard_rebalance(limit: int = 0) -> Result:
+    """
+    List tenants whose shard differs from their placement by consistent
+    hashing, with the shard to move each to (admins only).
+
+    limit: Moves to return (default 100, max 1000); total_count counts all
+    """
     try:
-        services = await resolve_tenant_services(tenant_id)
-        node_type = await services["node_type"].update(id, name, description, schema)
-        return Success({"node_type": node_type.to_dict()})
+        moves, total = await _require_shard_service().plan_rebalance(limit)
+        return Success({"moves": moves, "total_count": total})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def delete_node_type(id: str, tenant_id: str) -> Result:
-    """Delete a node type."""
+async def move_tenant_shard(tenant_id: str, shard: str) -> Result:
+    """
+    Move a tenant's database to another shard; the tenant must be in
+    maintenance, and its database on the previous shard is kept (admins only).
+    """
     try:
-        services = await resolve_tenant_services(tenant_id)
-        await services["node_type"].delete(id)
-        return Success({})
+        moved = await _require_shard_service().move(tenant_id, shard)
+        return Success(moved)
     except Exception as e:
         return _handle_error(e)
 
 
+def _require_tenant_key_service() -> TenantKeyService:
+    if _tenant_key_service is None:
+        raise RuntimeError("tenant encryption keys are not configured")
+    return _tenant_key_service
+
+
 @method
-async def list_node_types(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
-    """List node types for a tenant."""
+async def rotate_tenant_key(tenant_id: str) -> Result:
+    """
+    Create a new data encryption key version for a tenant and re-encrypt its
+    data with it in the background; the first rotation turns encryption on.
+
+    Poll get_operation with the returned operation for progress.
+    """
     try:
-        page_size = 10
-        page_token = ""
-        if pagination:
-            page_size = pagination.get("page_size", 10)
-            page_token = pagination.get("page_token", "")
-        
-        services = await resolve_tenant_services(tenant_id)
-        node_types, result = await services["node_type"].list(page_size, page_token)
-        return Success({
-            "node_types": [nt.to_dict() for nt in node_types],
-            "pagination": result.to_dict(),
-        })
+        key, op = await _require_tenant_key_service().rotate(tenant_id)
+        return Success({"key": key.to_dict(), "operation": op.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
-# ============================================================================
-# Node Service Methods
-# ============================================================================
+@method
+async def list_tenant_keys(tenant_id: str) -> Result:
+    """List a tenant's data encryption key versions and their status, newest first."""
+    try:
+        keys = await _require_tenant_key_service().list(tenant_id)
+        return Success({"keys": [k.to_dict() for k in keys]})
+    except Exception as e:
+        return _handle_error(e)
+
 
 @method
-async def create_node(tenant_id: str, node_type_id: str, data: str = "{}") -> Result:
-    """Create a new node."""
+async def update_tenant(
+    id: str,
+    slug: str = "",
+    name: str = "",
+    status: str = "",
+    annotations: Dict[str, Any] = None,
+    timezone: str = ""
+) -> Result:
+    """
+    Update an existing tenant.
+
+    annotations: Merged into the existing annotations; a null value removes that key
+    timezone: IANA time zone that date histograms bucket by
+    """
     try:
-        services = await resolve_tenant_services(tenant_id)
-        node = await services["node"].create(node_type_id, data)
-        return Success({"node": node.to_dict()})
+        tenant = await _tenant_service.update(id, slug, name, status, annotations, timezone)
+        return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
+def _require_force_privileges() -> None:
+    """Forcing the delete of protected entities and removing protection take an administrator (and an admin key)."""
+    ctx = current_context()
+    key = ctx.attributes.get("api_key")
+    if key and key["scope"] != "admin":
+        raise PermissionDeniedError(
+            f"api key {key['name']} has scope {key['scope']}; protected entities take an admin key"
+        )
+    _require_impersonation_service().require_admin(ctx.admin_subject_id())
+
+
 @method
-async def get_node(id: str, tenant_id: str) -> Result:
-    """Get a node by ID."""
+async def delete_tenant(id: str, force: bool = False) -> Result:
+    """
+    Schedule a tenant for deletion; it is recoverable with undelete_tenant until delete_after.
+
+    force: Delete the tenant even if it is protected (admins only)
+    """
     try:
-        services = await resolve_tenant_services(tenant_id)
-        node = await services["node"].get_by_id(id)
-        return Success({"node": node.to_dict()})
+        if force:
+            _require_force_privileges()
+        tenant = await _tenant_service.delete(id, force)
+        return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def update_node(id: str, tenant_id: str, data: str = "") -> Result:
-    """Update an existing node."""
+async def set_tenant_protection(id: str, protected: bool) -> Result:
+    """Protect a tenant from deletion without force, or remove its protection (removing it is for admins only)."""
     try:
-        services = await resolve_tenant_services(tenant_id)
-        node = await services["node"].update(id, data)
-        return Success({"node": node.to_dict()})
+        if not protected:
+            _require_force_privileges()
+        tenant = await _tenant_service.set_protected(id, protected)
+        return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def delete_node(id: str, tenant_id: str) -> Result:
-    """Delete a node."""
+async def undelete_tenant(id: str) -> Result:
+    """Restore a tenant that is pending deletion."""
     try:
-        services = await resolve_tenant_services(tenant_id)
-        await services["node"].delete(id)
-        return Success({})
+        tenant = await _tenant_service.undelete(id)
+        return Success({"tenant": tenant.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def list_nodes(tenant_id: str, node_type_id: str = "", pagination: Dict[str, Any] = None) -> Result:
-    """List nodes for a tenant with optional filtering."""
+async def list_tenants(
+    pagination: Dict[str, Any] = None,
+    order_by: str = "",
+    status: str = "",
+    slug_prefix: str = "",
+    name_contains: str = "",
+    created_after: str = "",
+    created_before: str = "",
+    annotations: Dict[str, str] = None,
+    parent_id: str = ""
+) -> Result:
+    """
+    List tenants with pagination and optional filtering.
+
+    name_contains: Case-insensitive substring of the tenant name
+    created_after: ISO 8601 time; only tenants created at or after it
+    created_before: ISO 8601 time; only tenants created before it
+    annotations: Only tenants having all of these annotation values
+    parent_id: Only the direct sub-tenants of this organization
+    """
     try:
-        page_size = 10
+        page_size = 0
         page_token = ""
         if pagination:
-            page_size = pagination.get("page_size", 10)
+            page_size = pagination.get("page_size", 0)
             page_token = pagination.get("page_token", "")
         
-        services = await resolve_tenant_services(tenant_id)
-        nodes, result = await services["node"].list(node_type_id or None, page_size, page_token)
+        tenants, result = await _tenant_service.list(
+            page_size, page_token, order_by,
+            status, slug_prefix, name_contains, created_after, created_before, annotations, parent_id
+        )
         return Success({
-            "nodes": [n.to_dict() for n in nodes],
+            "tenants": [t.to_dict() for t in tenants],
             "pagination": result.to_dict(),
         })
     except Exception as e:
@@ -338,91 +768,2993 @@ async def list_nodes(tenant_id: str, node_type_id: str = "", pagination: Dict[st
 
 
 # ============================================================================
-# Relationship Service Methods
+# User Service Methods
 # ============================================================================
 
 @method
-async def create_relationship(
-    tenant_id: str,
-    source_node_id: str,
-    target_node_id: str,
-    relationship_type: str,
-    data: str = "{}"
-) -> Result:
-    """Create a new relationship."""
+async def create_user(email: str, display_name: str) -> Result:
+    """Create a new user."""
     try:
-        services = await resolve_tenant_services(tenant_id)
-        rel = await services["relationship"].create(source_node_id, target_node_id, relationship_type, data)
-        return Success({"relationship": rel.to_dict()})
+        user = await _user_service.create(email, display_name)
+        return Success({"user": user.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def get_relationship(id: str, tenant_id: str) -> Result:
-    """Get a relationship by ID."""
+async def get_user(id: str) -> Result:
+    """Get a user by ID."""
     try:
-        services = await resolve_tenant_services(tenant_id)
-        rel = await services["relationship"].get_by_id(id)
-        return Success({"relationship": rel.to_dict()})
+        user = await _user_service.get_by_id(id)
+        return Success({"user": user.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def update_relationship(id: str, tenant_id: str, relationship_type: str = "", data: str = "") -> Result:
-    """Update an existing relationship."""
+async def update_user(id: str, email: str = "", display_name: str = "") -> Result:
+    """Update an existing user."""
     try:
-        services = await resolve_tenant_services(tenant_id)
-        rel = await services["relationship"].update(id, relationship_type, data)
-        return Success({"relationship": rel.to_dict()})
+        user = await _user_service.update(id, email, display_name)
+        return Success({"user": user.to_dict()})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def delete_relationship(id: str, tenant_id: str) -> Result:
-    """Delete a relationship."""
+async def delete_user(id: str) -> Result:
+    """Delete a user."""
+    try:
+        await _user_service.delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_users(pagination: Dict[str, Any] = None, order_by: str = "") -> Result:
+    """List users with pagination."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+        
+        users, result = await _user_service.list(page_size, page_token, order_by)
+        return Success({
+            "users": [u.to_dict() for u in users],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def add_user_to_tenant(tenant_id: str, user_id: str, role: str = "") -> Result:
+    """Add a user to a tenant."""
+    try:
+        tenant_user = await _user_service.add_to_tenant(tenant_id, user_id, role)
+        return Success({"tenant_user": tenant_user.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def remove_user_from_tenant(tenant_id: str, user_id: str) -> Result:
+    """Remove a user from a tenant."""
+    try:
+        await _user_service.remove_from_tenant(tenant_id, user_id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_tenant_users(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List users in a tenant."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+        
+        tenant_users, result = await _user_service.list_tenant_users(tenant_id, page_size, page_token)
+        return Success({
+            "tenant_users": [tu.to_dict() for tu in tenant_users],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# NodeType Service Methods
+# ============================================================================
+
+@method
+async def create_node_type(tenant_id: str, name: str, description: str = "", schema: str = "") -> Result:
+    """Create a new node type."""
     try:
         services = await resolve_tenant_services(tenant_id)
-        await services["relationship"].delete(id)
+        node_type = await services["node_type"].create(name, description, schema)
+        return Success({"node_type": node_type.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_node_type(id: str, tenant_id: str) -> Result:
+    """Get a node type by ID."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        node_type = await services["node_type"].get_by_id(id)
+        return Success({"node_type": node_type.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_node_type(id: str, tenant_id: str, name: str = "", description: str = "", schema: str = "") -> Result:
+    """Update an existing node type."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        node_type = await services["node_type"].update(id, name, description, schema)
+        return Success({"node_type": node_type.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_node_type(id: str, tenant_id: str) -> Result:
+    """Delete a node type."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["node_type"].delete(id)
         return Success({})
     except Exception as e:
         return _handle_error(e)
 
 
 @method
-async def list_relationships(
+async def list_node_types(
     tenant_id: str,
-    source_node_id: str = "",
-    target_node_id: str = "",
-    relationship_type: str = "",
-    pagination: Dict[str, Any] = None
+    pagination: Dict[str, Any] = None,
+    order_by: str = "",
+    read_session: str = ""
 ) -> Result:
-    """List relationships for a tenant with optional filtering."""
+    """
+    List node types for a tenant.
+
+    read_session: Token from begin_read_session; reads observe that session's snapshot
+    """
     try:
-        page_size = 10
+        page_size = 0
         page_token = ""
         if pagination:
-            page_size = pagination.get("page_size", 10)
+            page_size = pagination.get("page_size", 0)
             page_token = pagination.get("page_token", "")
         
+        services = await resolve_tenant_services(tenant_id, read_session)
+        node_types, result = await services["node_type"].list(page_size, page_token, order_by)
+        return Success({
+            "node_types": [nt.to_dict() for nt in node_types],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def create_unique_constraint(
+    tenant_id: str, node_type_id: str, path: str, case_insensitive: bool = False
+) -> Result:
+    """
+    Make a data path unique among the nodes of a node type; writes of a duplicate value fail with -32005.
+
+    path: Data path, e.g. "data.email"
+    case_insensitive: Compare values ignoring case
+    """
+    try:
         services = await resolve_tenant_services(tenant_id)
-        rels, result = await services["relationship"].list(
-            source_node_id or None,
-            target_node_id or None,
-            relationship_type or None,
-            page_size,
-            page_token
-        )
+        constraint = await services["unique_constraint"].create(node_type_id, path, case_insensitive)
+        return Success({"unique_constraint": constraint.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_unique_constraints(tenant_id: str, node_type_id: str = "") -> Result:
+    """List the unique constraints of a node type (default: of every node type)."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        constraints = await services["unique_constraint"].list(node_type_id)
+        return Success({"unique_constraints": [c.to_dict() for c in constraints]})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_unique_constraint(id: str, tenant_id: str) -> Result:
+    """Drop a unique constraint."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["unique_constraint"].delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Node Service Methods
+# ============================================================================
+
+@method
+async def create_node(
+    tenant_id: str,
+    node_type_id: str,
+    data: JsonData = "{}",
+    valid_from: str = "",
+    metadata: Dict[str, Any] = None,
+    id: str = ""
+) -> Result:
+    """
+    Create a new node.
+
+    valid_from: ISO 8601 time from which the data is valid (default: now; may be in the past)
+    metadata: System/integration bookkeeping kept apart from data, e.g. {"source": "crm"}
+    id: Client-generated UUID for the node; creating it again fails with -32005, so retries are safe
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        node = await services["node"].create(node_type_id, _data_param(data), valid_from, metadata, id)
+        return Success({"node": node.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def batch_create_nodes(
+    tenant_id: str,
+    items: List[Dict[str, Any]],
+    on_conflict: str = "error",
+    node_type_id: str = "",
+) -> Result:
+    """
+    Create many nodes, each on its own, with a policy for items whose node already exists.
+
+    items: Objects with node_type_id, data, id, aliases, key ("id" or an alias name
+        identifying an existing node), on_conflict, metadata and valid_from
+    on_conflict: "error" (default), "skip", "replace" or "merge" for items whose key matches a node
+    node_type_id: Node type of items that do not name one
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        results = await services["batch_create"].create_nodes(items, on_conflict, node_type_id)
+        counts = {}
+        for r in results:
+            counts[r.status] = counts.get(r.status, 0) + 1
+        return Success({"results": [r.to_dict() for r in results], "counts": counts})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_node(
+    id: str,
+    tenant_id: str,
+    valid_at: str = "",
+    recorded_at: str = "",
+    fields: List[str] = None,
+    if_none_match: str = "",
+    expand: List[str] = None,
+    read_session: str = ""
+) -> Result:
+    """
+    Get a node by ID, optionally as of a point in valid and transaction time.
+
+    valid_at: ISO 8601 time at which the returned data was valid (default: now)
+    recorded_at: ISO 8601 time of the knowledge to query, for seeing data before later corrections (default: now)
+    fields: Read mask of top-level fields to return, e.g. ["id", "node_type_id"] (default: all)
+    if_none_match: Etag from an earlier read; returns {"not_modified": true} instead of the node if unchanged
+    expand: Related entities to embed, e.g. ["relationships", "neighbors(type=OWNS,depth=2)"]
+    read_session: Token from begin_read_session; reads observe that session's snapshot
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id, read_session)
+        specs = _expand_param(services, expand, valid_at or recorded_at)
+        if valid_at or recorded_at:
+            node = await services["node"].get_as_of(id, valid_at, recorded_at)
+            return Success({"node": _apply_read_mask(node.to_dict(), fields)})
+        node = await services["node"].get_by_id(id)
+        if _not_modified(node.etag, if_none_match):
+            return Success({"not_modified": True, "etag": node.etag})
+        return Success({"node": (await _expanded(services, [node], fields, specs))[0]})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_node(
+    id: str,
+    tenant_id: str,
+    data: JsonData = "",
+    valid_from: str = "",
+    if_match: str = "",
+    patch: Union[List[Dict[str, Any]], Dict[str, Any]] = None,
+    metadata: Dict[str, Any] = None,
+    update_mask: List[str] = None
+) -> Result:
+    """
+    Update an existing node.
+
+    valid_from: ISO 8601 time from which the new data is valid (default: now; may be in the past)
+    if_match: Etag the node must still have for the update to apply (fails with -32004 otherwise)
+    patch: RFC 6902 JSON patch (array) or RFC 7386 merge patch (object) applied to the current data, instead of data
+    metadata: Merged into the node's metadata; a null value removes that key
+    update_mask: Paths to write, e.g. ["metadata.sync_cursor"]; others are left unchanged (default: whatever is given)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        if patch is not None:
+            if data:
+                raise ValueError("pass either data or patch, not both")
+            if update_mask:
+                raise ValueError("update_mask cannot be combined with patch")
+            node = await services["node"].patch(id, patch, valid_from, if_match, metadata)
+        else:
+            node = await services["node"].update(
+                id, _data_param(data), valid_from, if_match, metadata, update_mask
+            )
+        return Success({"node": node.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def correct_node(id: str, tenant_id: str, data: JsonData, valid_from: str, valid_to: str = "") -> Result:
+    """
+    Record a bi-temporal correction: data valid for [valid_from, valid_to).
+
+    valid_from: ISO 8601 start of the corrected valid-time interval
+    valid_to: ISO 8601 end of the interval (default: open-ended)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        version = await services["node"].correct(id, _data_param(data), valid_from, valid_to)
+        return Success({"node": version.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_node_history(id: str, tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List every recorded version of a node with its valid and transaction time bounds."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        versions, result = await services["node"].history(id, page_size, page_token)
         return Success({
-            "relationships": [r.to_dict() for r in rels],
+            "versions": [v.to_dict() for v in versions],
             "pagination": result.to_dict(),
         })
     except Exception as e:
         return _handle_error(e)
 
 
+@method
+async def increment_node_field(
+    id: str,
+    tenant_id: str,
+    field: str,
+    delta: float = 1,
+    valid_from: str = ""
+) -> Result:
+    """
+    Atomically add delta (default 1, may be negative) to a numeric data field.
+
+    field: Dotted data path, e.g. "data.stats.views"; a missing field starts from 0
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        node, value = await services["node"].increment_field(id, field, delta, valid_from)
+        return Success({"node": node.to_dict(), "value": value})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_node(id: str, tenant_id: str, force: bool = False) -> Result:
+    """
+    Delete a node, applying relationship type delete rules (returns every deleted node ID).
+
+    force: Delete the node even if it or a cascaded node is protected (admins only)
+    """
+    try:
+        if force:
+            _require_force_privileges()
+        services = await resolve_tenant_services(tenant_id)
+        deleted = await services["node"].delete(id, force)
+        return Success({"deleted_node_ids": deleted})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_node_protection(id: str, tenant_id: str) -> Result:
+    """Get whether a node is protected from deletion."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        protected = await services["node"].is_protected(id)
+        return Success({"id": id, "protected": protected})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def set_node_protection(id: str, tenant_id: str, protected: bool) -> Result:
+    """Protect a node from deletion without force, or remove its protection (removing it is for admins only)."""
+    try:
+        if not protected:
+            _require_force_privileges()
+        services = await resolve_tenant_services(tenant_id)
+        await services["node"].set_protected(id, protected)
+        return Success({"id": id, "protected": protected})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_node_aliases(id: str, tenant_id: str) -> Result:
+    """Get a node's aliases, e.g. {"email": "a@example.com", "legacy_id": "C-1042"}."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        aliases = await services["node_alias"].get(id)
+        return Success({"aliases": aliases})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def set_node_aliases(id: str, tenant_id: str, aliases: Dict[str, Any]) -> Result:
+    """
+    Set some of a node's aliases; returns all of its aliases.
+
+    aliases: {"name": "value"}; a null value removes that alias, omitted names are kept
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        aliases = await services["node_alias"].set(id, aliases)
+        return Success({"aliases": aliases})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def lookup_node_by_alias(
+    tenant_id: str,
+    name: str,
+    value: str,
+    fields: List[str] = None,
+    expand: List[str] = None,
+    read_session: str = ""
+) -> Result:
+    """
+    Get the node that has an alias, e.g. name "email" and value "a@example.com".
+
+    fields: Read mask of top-level fields to return (default: all)
+    expand: Related entities to embed, as for get_node
+    read_session: Token from begin_read_session; reads observe that session's snapshot
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id, read_session)
+        specs = _expand_param(services, expand, "")
+        node = await services["node_alias"].lookup(name, value)
+        return Success({"node": (await _expanded(services, [node], fields, specs))[0]})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_nodes(
+    tenant_id: str,
+    node_type_id: str = "",
+    pagination: Dict[str, Any] = None,
+    order_by: str = "",
+    valid_at: str = "",
+    recorded_at: str = "",
+    fields: List[str] = None,
+    expand: List[str] = None,
+    read_session: str = "",
+    metadata: Dict[str, Any] = None,
+    view: str = "",
+    view_params: Dict[str, Any] = None,
+    ranges: Dict[str, Any] = None
+) -> Result:
+    """
+    List nodes for a tenant with optional filtering.
+
+    metadata: Object the node metadata must contain, e.g. {"community": {"components": "<id>"}}
+    ranges: Bounds on data fields, e.g. {"due": {"gte": "2024-01-01"}}; dates compare as dates
+    view: Name of a view to list (see create_view), narrowed by node_type_id, metadata and ranges
+    view_params: Arguments for the view's parameters
+    valid_at: ISO 8601 time at which listed data was valid (switches to bi-temporal history)
+    recorded_at: ISO 8601 time of the knowledge to query (switches to bi-temporal history)
+    fields: Read mask of top-level fields to return; omitting data and data_object skips loading payloads
+    expand: Related entities to embed in each node, e.g. ["relationships(direction=out)", "neighbors"]
+    read_session: Token from begin_read_session; reads observe that session's snapshot
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+        
+        services = await resolve_tenant_services(tenant_id, read_session)
+        specs = _expand_param(services, expand, valid_at or recorded_at)
+        narrowing = {"node_type_id": node_type_id, "metadata": metadata, "ranges": ranges}
+        view_filter, view_projection, view_version = await _view_scope(services, view, view_params, narrowing)
+        if view_filter is None and ranges:
+            view_filter = narrowing
+        projection = parse_transform(view_projection or None)
+
+        async def load() -> Dict[str, Any]:
+            if valid_at or recorded_at:
+                if metadata or view or ranges:
+                    raise ValueError("metadata, ranges and view cannot be combined with valid_at or recorded_at")
+                nodes, result = await services["node"].list_as_of(
+                    node_type_id or None, valid_at, recorded_at, page_size, page_token, order_by
+                )
+            else:
+                filters = parse_node_filter(view_filter) if view_filter is not None else None
+                nodes, result = await services["node"].list(
+                    node_type_id or None, page_size, page_token, order_by,
+                    bool(projection) or _mask_needs_data(fields), metadata, filters,
+                )
+            return {
+                "nodes": await _expanded(services, _project(nodes, projection), fields, specs),
+                "pagination": result.to_dict(),
+            }
+
+        if read_session:
+            # Snapshot reads are not cached
+            return Success(await load())
+        return Success(await services["query_cache"].get_or_load("list_nodes", {
+            "node_type_id": node_type_id, "page_size": page_size, "page_token": page_token, "order_by": order_by,
+            "valid_at": valid_at, "recorded_at": recorded_at, "fields": fields, "expand": expand,
+            "metadata": metadata, "view": view, "view_params": view_params, "view_version": view_version,
+            "ranges": ranges,
+        }, load))
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def search_nodes(
+    tenant_id: str,
+    query: str,
+    node_type_id: str = "",
+    pagination: Dict[str, Any] = None,
+    fields: List[str] = None,
+    consistency_token: str = "",
+    view: str = "",
+    view_params: Dict[str, Any] = None
+) -> Result:
+    """
+    Full-text search of node data, best matches first.
+
+    query: Words and "quoted phrases" to match in the data values
+    fields: Read mask of top-level fields to return (default: all); each node also has its score
+    consistency_token: Token returned by a write; the results include that write
+    view: Name of a view to search within (see create_view)
+    view_params: Arguments for the view's parameters
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        view_filter, view_projection, view_version = await _view_scope(
+            services, view, view_params, {"node_type_id": node_type_id}
+        )
+        projection = parse_transform(view_projection or None)
+
+        async def load() -> Dict[str, Any]:
+            filters = parse_node_filter(view_filter) if view_filter is not None else None
+            results, result = await services["search"].search(
+                query, node_type_id, page_size, page_token, consistency_token, filters
+            )
+            return {
+                "nodes": [
+                    {**_apply_read_mask(node.to_dict(), fields), "score": score}
+                    for node, score in zip(_project([n for n, _ in results], projection), [s for _, s in results])
+                ],
+                "pagination": result.to_dict(),
+            }
+
+        if consistency_token:
+            # Cached results may have been read from the index before it included the write
+            return Success(await load())
+        return Success(await services["query_cache"].get_or_load("search_nodes", {
+            "query": query, "node_type_id": node_type_id, "page_size": page_size, "page_token": page_token,
+            "fields": fields, "view": view, "view_params": view_params, "view_version": view_version,
+        }, load))
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Saved Query Methods
+# ============================================================================
+
+def _check_saved_query_params(method_name: str, params: Optional[Dict[str, Any]]) -> None:
+    """Reject saved query parameters the query's method does not take."""
+    func = global_methods.get(method_name)
+    if func is None or not isinstance(params, dict):
+        return
+    accepted = set(inspect.signature(func).parameters)
+    unknown = sorted(set(params) - accepted)
+    if unknown:
+        raise ValueError(f"{method_name} does not take: {', '.join(unknown)}")
+
+
+@method
+async def create_saved_query(
+    tenant_id: str,
+    name: str,
+    method: str,
+    params: Dict[str, Any] = None,
+    parameters: Dict[str, Any] = None,
+    description: str = "",
+) -> Result:
+    """
+    Save a named query definition to run with run_saved_query.
+
+    method: Read method to run, e.g. "list_nodes", "search_nodes" or "get_subgraph"
+    params: Its parameters (except tenant_id); "$name" values are placeholders for run arguments
+    parameters: Every placeholder name and its default; a null default makes it required
+    """
+    try:
+        _check_saved_query_params(method, params)
+        services = await resolve_tenant_services(tenant_id)
+        query = await services["saved_query"].create(name, method, params, parameters, description)
+        return Success({"saved_query": query.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_saved_query(id: str, tenant_id: str) -> Result:
+    """Get a saved query by ID, with its run count and average run time."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        query = await services["saved_query"].get_by_id(id)
+        return Success({"saved_query": query.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_saved_query(
+    id: str,
+    tenant_id: str,
+    name: str = "",
+    method: str = "",
+    params: Dict[str, Any] = None,
+    parameters: Dict[str, Any] = None,
+    description: str = None,
+) -> Result:
+    """Update a saved query; omitted fields are kept."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        if params is not None or method:
+            current = await services["saved_query"].get_by_id(id)
+            _check_saved_query_params(method or current.method, params if params is not None else current.params)
+        query = await services["saved_query"].update(id, name, method, params, parameters, description)
+        return Success({"saved_query": query.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_saved_query(id: str, tenant_id: str) -> Result:
+    """Delete a saved query."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["saved_query"].delete(id)
+        return Success({"success": True})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_saved_queries(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List a tenant's saved queries by name."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        queries, result = await services["saved_query"].list(page_size, page_token)
+        return Success({
+            "saved_queries": [q.to_dict() for q in queries],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def run_saved_query(
+    tenant_id: str,
+    name: str,
+    params: Dict[str, Any] = None,
+    pagination: Dict[str, Any] = None,
+) -> Result:
+    """
+    Run a saved query by name; returns what its method returns.
+
+    params: Arguments for the saved query's placeholders
+    pagination: Page of the results, for methods that page (overrides the saved pagination)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        query, call_params = await services["saved_query"].resolve(name, params)
+        if pagination:
+            _check_saved_query_params(query.method, {"pagination": pagination})
+            call_params["pagination"] = pagination
+        started = time.monotonic()
+        # The method itself, as run_saved_query was already authorized and intercepted
+        result = await global_methods[query.method](tenant_id=tenant_id, **call_params)
+        await services["saved_query"].record_run(query.id, time.monotonic() - started)
+        return result
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# View Methods
+# ============================================================================
+
+@method
+async def create_view(
+    tenant_id: str,
+    name: str,
+    filter: Dict[str, Any] = None,
+    projection: Dict[str, Any] = None,
+    parameters: Dict[str, Any] = None,
+    description: str = "",
+) -> Result:
+    """
+    Define a named view to read with the view parameter of list_nodes, search_nodes and export_tenant.
+
+    filter: {"node_type_id", "data", "metadata", "created_after", ...}; "$name" values are placeholders
+    projection: {"include", "exclude", "redact", "hash_salt"} applied to the view's nodes
+    parameters: Every placeholder name and its default; a null default makes it required
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        view = await services["node_view"].create(name, filter, projection, parameters, description)
+        return Success({"view": view.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_view(id: str, tenant_id: str) -> Result:
+    """Get a view by ID."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        view = await services["node_view"].get_by_id(id)
+        return Success({"view": view.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_view(
+    id: str,
+    tenant_id: str,
+    name: str = "",
+    filter: Dict[str, Any] = None,
+    projection: Dict[str, Any] = None,
+    parameters: Dict[str, Any] = None,
+    description: str = None,
+) -> Result:
+    """Update a view; omitted fields are kept."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        view = await services["node_view"].update(id, name, filter, projection, parameters, description)
+        return Success({"view": view.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_view(id: str, tenant_id: str) -> Result:
+    """Delete a view."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["node_view"].delete(id)
+        return Success({"success": True})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_views(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List a tenant's views by name."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        views, result = await services["node_view"].list(page_size, page_token)
+        return Success({
+            "views": [v.to_dict() for v in views],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Read Session Methods
+# ============================================================================
+
+@method
+async def begin_read_session(tenant_id: str, ttl_seconds: int = 0) -> Result:
+    """
+    Pin a consistent point-in-time view of a tenant for subsequent reads.
+
+    ttl_seconds: Session lifetime, at most READ_SESSION_TTL_SECONDS (default: that maximum)
+    """
+    try:
+        tenant_db = await get_tenant_db(tenant_id)
+        session = await get_read_session_manager().begin(tenant_id, tenant_db, ttl_seconds)
+        return Success({"read_session": session.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def end_read_session(tenant_id: str, token: str) -> Result:
+    """Close a read session and release its snapshot."""
+    try:
+        await get_read_session_manager().end(token, tenant_id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Bulk Operation Methods
+# ============================================================================
+
+@method
+async def update_nodes_by_filter(
+    tenant_id: str,
+    filter: Dict[str, Any],
+    patch: Dict[str, Any],
+    max_affected: int,
+    dry_run: bool = False
+) -> Result:
+    """
+    Apply a JSON merge patch to every node matching a filter, as a background operation.
+
+    filter: {"node_type_id", "data" (object the node data must contain), "created_after", "created_before"}
+    patch: RFC 7386 JSON merge patch applied to each node's data (null removes a key)
+    max_affected: Refuse (without changing anything) if more nodes than this match
+    dry_run: Only count the matching nodes
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        matched, op = await services["bulk"].update_by_filter(filter, patch, max_affected, dry_run)
+        response: Dict[str, Any] = {"matched_count": matched}
+        if op:
+            response["operation"] = op.to_dict()
+        return Success(response)
+    except Exception as e:
+        return _handle_error(e)
+
+
+def _delete_preview_response(matched: int, sample: List[str], op: Any) -> Dict[str, Any]:
+    response: Dict[str, Any] = {"matched_count": matched, "sample_ids": sample}
+    if op.status == "awaiting_confirmation":
+        response["confirmation_token"] = op.id
+    else:
+        response["operation"] = op.to_dict()
+    return response
+
+
+@method
+async def delete_nodes_by_filter(
+    tenant_id: str,
+    filter: Dict[str, Any],
+    max_affected: int,
+    confirmation_token: str = ""
+) -> Result:
+    """
+    Preview, then delete, every node matching a filter as a background operation.
+
+    filter: {"node_type_id", "data" (object the node data must contain), "created_after", "created_before"}
+    max_affected: Refuse (without deleting anything) if more nodes than this match
+    confirmation_token: Token from a preview call with the same filter; omit to preview
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        matched, sample, op = await services["bulk"].delete_nodes_by_filter(filter, max_affected, confirmation_token)
+        return Success(_delete_preview_response(matched, sample, op))
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_relationships_by_filter(
+    tenant_id: str,
+    filter: Dict[str, Any],
+    max_affected: int,
+    confirmation_token: str = ""
+) -> Result:
+    """
+    Preview, then delete, every relationship matching a filter as a background operation.
+
+    filter: {"relationship_type", "source_node_id", "target_node_id", "data", "created_after", "created_before"}
+    max_affected: Refuse (without deleting anything) if more relationships than this match
+    confirmation_token: Token from a preview call with the same filter; omit to preview
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        matched, sample, op = await services["bulk"].delete_relationships_by_filter(
+            filter, max_affected, confirmation_token
+        )
+        return Success(_delete_preview_response(matched, sample, op))
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_operation(id: str, tenant_id: str) -> Result:
+    """Get a background operation and its progress by ID."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["operation"].get_by_id(id)
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_operations(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List background operations for a tenant, newest first."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        ops, result = await services["operation"].list(page_size, page_token)
+        return Success({
+            "operations": [o.to_dict() for o in ops],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Export Methods
+# ============================================================================
+
+@method
+async def export_tenant(
+    tenant_id: str,
+    filter: Dict[str, Any] = None,
+    include_relationships: bool = True,
+    pagination: Dict[str, Any] = None,
+    read_session: str = "",
+    transform: Dict[str, Any] = None,
+    view: str = "",
+    view_params: Dict[str, Any] = None
+) -> Result:
+    """
+    Export a page of a tenant's nodes, optionally only those matching a filter, with their outgoing relationships.
+
+    filter: {"node_type_id", "data", "created_after", "created_before", "updated_after", "updated_before"}
+    include_relationships: Also return the relationships whose source is an exported node
+    transform: {"include", "exclude", "redact", "hash_salt", "node_types", "relationships"} field
+        selection and redaction applied to every page
+    read_session: Token from begin_read_session; pages observe that session's snapshot
+    view: Name of a view to export (see create_view), narrowed by filter; its projection is the transform
+    view_params: Arguments for the view's parameters
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id, read_session)
+        view_filter, view_projection, _ = await _view_scope(services, view, view_params, filter or {})
+        if view_projection and transform:
+            raise ValueError("transform cannot be combined with a view that has a projection")
+        nodes, relationships, result, sync_cursor = await services["export"].export(
+            view_filter if view_filter is not None else filter, include_relationships, page_size, page_token,
+            transform or view_projection or None,
+        )
+        return Success({
+            "nodes": [n.to_dict() for n in nodes],
+            "relationships": [r.to_dict() for r in relationships],
+            "pagination": result.to_dict(),
+            "sync_cursor": sync_cursor,
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def export_graph(
+    tenant_id: str,
+    format: str = "graphml",
+    filter: Dict[str, Any] = None,
+    label_field: str = "",
+    read_session: str = "",
+    transform: Dict[str, Any] = None
+) -> Result:
+    """
+    Export a tenant's nodes, or those matching a filter, and the relationships between them as one document.
+
+    format: "graphml" (e.g. for Gephi) or "dot" (Graphviz)
+    filter: As for export_tenant; at most max_batch_size nodes may match
+    label_field: Top-level data field to label nodes with (default: the node ID)
+    transform: As for export_tenant
+    read_session: Token from begin_read_session; the export observes that session's snapshot
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id, read_session)
+        content, node_count, relationship_count = await services["export"].export_graph(
+            format, filter, label_field, transform
+        )
+        return Success({
+            "format": format,
+            "content_type": GRAPH_FORMATS[format],
+            "content": content,
+            "node_count": node_count,
+            "relationship_count": relationship_count,
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def export_tenant_changes(
+    tenant_id: str,
+    sync_cursor: str = "",
+    filter: Dict[str, Any] = None,
+    include_relationships: bool = True,
+    pagination: Dict[str, Any] = None,
+    transform: Dict[str, Any] = None
+) -> Result:
+    """
+    Export what changed since a sync cursor: written nodes and relationships, and tombstones of deleted ones.
+
+    sync_cursor: Cursor from export_tenant or a previous export_tenant_changes (not needed with a page_token)
+    filter: Same keys as export_tenant; applies to nodes and to the source nodes of relationships
+    transform: As for export_tenant
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        nodes, relationships, tombstones, result, next_cursor = await services["export"].changes(
+            sync_cursor, filter, include_relationships, page_size, page_token, transform
+        )
+        return Success({
+            "nodes": [n.to_dict() for n in nodes],
+            "relationships": [r.to_dict() for r in relationships],
+            "tombstones": [t.to_dict() for t in tombstones],
+            "pagination": result.to_dict(),
+            "sync_cursor": next_cursor,
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def begin_change_subscription(
+    tenant_id: str,
+    sync_cursor: str = "",
+    filter: Dict[str, Any] = None,
+    include_relationships: bool = True,
+    transform: Dict[str, Any] = None
+) -> Result:
+    """
+    Reserve a real-time stream of changes for browser clients (Server-Sent Events or WebSocket).
+
+    sync_cursor: Cursor to stream the changes after (default: changes from now on)
+    filter, include_relationships, transform: As for export_tenant_changes
+    Connect to /tenants/{tenant_id}/changes/{token}/events or /tenants/{tenant_id}/changes/{token}/ws.
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        subscription = await get_change_stream_manager().begin(
+            tenant_id, services["export"], sync_cursor, filter, include_relationships, transform
+        )
+        return Success({"change_subscription": subscription.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def end_change_subscription(tenant_id: str, token: str) -> Result:
+    """Forget a change subscription; streams already open continue until they disconnect."""
+    try:
+        get_change_stream_manager().end(token, tenant_id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def push_changes(tenant_id: str, changes: List[Dict[str, Any]], conflict_policy: str = "reject") -> Result:
+    """
+    Apply changes an offline client made locally, in order, with per-field conflict detection.
+
+    changes: Objects with entity, action, id, base_etag, data, base_data and, for creates,
+        node_type_id or source_node_id, target_node_id and relationship_type
+    conflict_policy: "reject" (default), "server_wins" or "client_wins" for fields both sides changed
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        results = await services["sync"].push(changes, conflict_policy)
+        return Success({
+            "results": [r.to_dict() for r in results],
+            "applied_count": sum(1 for r in results if r.status == "applied"),
+            "conflict_count": sum(1 for r in results if r.status == "conflict"),
+            "failed_count": sum(1 for r in results if r.status == "failed"),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Export Schedule Methods
+# ============================================================================
+
+def _require_export_schedule_service() -> ExportScheduleService:
+    if _export_schedule_service is None:
+        raise RuntimeError("export schedules are not configured")
+    return _export_schedule_service
+
+
+@method
+async def create_export_schedule(
+    tenant_id: str,
+    name: str,
+    cron: str,
+    destination: str,
+    format: str = "ndjson",
+    filter: Dict[str, Any] = None,
+    include_relationships: bool = True,
+    alert_url: str = "",
+    enabled: bool = True,
+    credential: str = "",
+    transform: Dict[str, Any] = None
+) -> Result:
+    """
+    Schedule a recurring export of a tenant's nodes to a destination.
+
+    cron: Five-field cron expression (or @daily etc.), in the tenant's time zone
+    destination: URL to write export files below, e.g. s3://bucket/prefix/ or sftp://host/path/
+    format: "ndjson" (default), "graphml" or "dot"
+    filter: Same keys as export_tenant
+    alert_url: Receives a POST describing each failed run
+    credential: Name of the destination credential to write with
+    transform: Field selection and redaction, as for export_tenant
+    """
+    try:
+        schedule = await _require_export_schedule_service().create(
+            tenant_id, name, cron, destination, format, filter, include_relationships, alert_url, enabled, credential,
+            transform
+        )
+        return Success({"export_schedule": schedule.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_export_schedule(id: str, tenant_id: str) -> Result:
+    """Get an export schedule by ID."""
+    try:
+        schedule = await _require_export_schedule_service().get(tenant_id, id)
+        return Success({"export_schedule": schedule.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_export_schedule(
+    id: str,
+    tenant_id: str,
+    name: str = "",
+    cron: str = "",
+    destination: str = "",
+    format: str = "",
+    filter: Dict[str, Any] = None,
+    include_relationships: Optional[bool] = None,
+    alert_url: Optional[str] = None,
+    enabled: Optional[bool] = None,
+    credential: Optional[str] = None,
+    transform: Dict[str, Any] = None
+) -> Result:
+    """Update an export schedule; omitted settings are kept and the next run is recomputed."""
+    try:
+        schedule = await _require_export_schedule_service().update(
+            tenant_id, id, name, cron, destination, format, filter, include_relationships, alert_url, enabled,
+            credential, transform
+        )
+        return Success({"export_schedule": schedule.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_export_schedule(id: str, tenant_id: str) -> Result:
+    """Delete an export schedule and its run history (files already written are kept)."""
+    try:
+        await _require_export_schedule_service().delete(tenant_id, id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_export_schedules(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List a tenant's export schedules."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        schedules, result = await _require_export_schedule_service().list(tenant_id, page_size, page_token)
+        return Success({
+            "export_schedules": [s.to_dict() for s in schedules],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_export_runs(id: str, tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List the runs of an export schedule, most recent first."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        runs, result = await _require_export_schedule_service().list_runs(tenant_id, id, page_size, page_token)
+        return Success({
+            "export_runs": [r.to_dict() for r in runs],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def run_export_schedule(id: str, tenant_id: str) -> Result:
+    """Run an export schedule now; returns the finished run (a failed run is not an error)."""
+    try:
+        run = await _require_export_schedule_service().run_now(tenant_id, id)
+        return Success({"export_run": run.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def set_destination_credential(tenant_id: str, name: str, kind: str, secret: Dict[str, Any]) -> Result:
+    """
+    Store a credential export schedules can write to destinations with, replacing one of the same name.
+
+    kind: Destination scheme the credential is for: "s3", "gcs", "azure" or "sftp"
+    secret: Keys depend on the kind, e.g. {"username", "private_key", "host_key"} for sftp; never returned
+    """
+    try:
+        credential = await _require_export_schedule_service().set_credential(tenant_id, name, kind, secret)
+        return Success({"destination_credential": credential.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_destination_credentials(tenant_id: str) -> Result:
+    """List a tenant's destination credentials, without their secrets."""
+    try:
+        credentials = await _require_export_schedule_service().list_credentials(tenant_id)
+        return Success({"destination_credentials": [c.to_dict() for c in credentials]})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_destination_credential(tenant_id: str, name: str) -> Result:
+    """Delete a destination credential that no export schedule uses."""
+    try:
+        await _require_export_schedule_service().delete_credential(tenant_id, name)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Attachment Methods
+# ============================================================================
+
+def _upload_path(tenant_id: str, attachment_id: str) -> str:
+    return f"/tenants/{tenant_id}/attachments/{attachment_id}/content"
+
+
+@method
+async def create_attachment_upload(
+    tenant_id: str,
+    node_id: str,
+    filename: str,
+    content_type: str = ""
+) -> Result:
+    """
+    Reserve an attachment on a node and get the URL to upload its content to.
+
+    Stream the file body with HTTP PUT to upload_url before expires_at.
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        svc = services["attachment"]
+        attachment = await svc.reserve(node_id, filename, content_type)
+        expires_at = attachment.created_at.timestamp() + svc.settings.upload_ttl_seconds
+        return Success({
+            "attachment": attachment.to_dict(),
+            "upload_url": _upload_path(tenant_id, attachment.id),
+            "expires_at": datetime.fromtimestamp(expires_at, timezone.utc).isoformat(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_attachment(id: str, tenant_id: str) -> Result:
+    """Get attachment metadata with a time-limited download URL."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        attachment, url, expires_at = await services["attachment"].download_url(id)
+        return Success({
+            "attachment": attachment.to_dict(),
+            "download_url": url,
+            "expires_at": expires_at.isoformat(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_attachments(tenant_id: str, node_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List the uploaded attachments of a node."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        attachments, result = await services["attachment"].list(node_id, page_size, page_token)
+        return Success({
+            "attachments": [a.to_dict() for a in attachments],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_attachment(id: str, tenant_id: str) -> Result:
+    """Delete an attachment and its stored content."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["attachment"].delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Relationship Service Methods
+# ============================================================================
+
+@method
+async def create_relationship(
+    tenant_id: str,
+    source_node_id: str,
+    target_node_id: str,
+    relationship_type: str,
+    data: JsonData = "{}",
+    valid_from: str = "",
+    valid_to: str = "",
+    id: str = "",
+    on_duplicate: str = ""
+) -> Result:
+    """
+    Create a new relationship.
+
+    valid_from: ISO 8601 time the relationship becomes valid (default: always valid before valid_to)
+    valid_to: ISO 8601 time the relationship expires (default: valid indefinitely)
+    id: Client-generated UUID for the relationship; creating it again fails with -32005
+    on_duplicate: When an identical (source, target, type) relationship exists: "allow", "reject"
+        (fail with -32005) or "return_existing" (default: the relationship type's rule)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        rel, created = await services["relationship"].create_or_get(
+            source_node_id, target_node_id, relationship_type, _data_param(data), valid_from, valid_to, id,
+            on_duplicate
+        )
+        return Success({"relationship": rel.to_dict(), "created": created})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_relationship(
+    id: str,
+    tenant_id: str,
+    fields: List[str] = None,
+    if_none_match: str = "",
+    read_session: str = ""
+) -> Result:
+    """
+    Get a relationship by ID.
+
+    fields: Read mask of top-level fields to return (default: all)
+    if_none_match: Etag from an earlier read; returns {"not_modified": true} instead of the relationship if unchanged
+    read_session: Token from begin_read_session; reads observe that session's snapshot
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id, read_session)
+        rel = await services["relationship"].get_by_id(id)
+        if _not_modified(rel.etag, if_none_match):
+            return Success({"not_modified": True, "etag": rel.etag})
+        return Success({"relationship": _apply_read_mask(rel.to_dict(), fields)})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_relationship(
+    id: str,
+    tenant_id: str,
+    relationship_type: str = "",
+    data: JsonData = "",
+    if_match: str = "",
+    valid_from: Optional[str] = None,
+    valid_to: Optional[str] = None
+) -> Result:
+    """
+    Update an existing relationship.
+
+    if_match: Etag the relationship must still have for the update to apply (fails with -32004 otherwise)
+    valid_from, valid_to: New validity bounds (ISO 8601); omit to keep, "" to clear
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        rel = await services["relationship"].update(
+            id, relationship_type, _data_param(data), if_match, valid_from, valid_to
+        )
+        return Success({"relationship": rel.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_relationship(id: str, tenant_id: str) -> Result:
+    """Delete a relationship."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["relationship"].delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def begin_relationship_import(tenant_id: str, batch_size: int = 0, format: str = "") -> Result:
+    """
+    Reserve a bulk relationship import and get the path to upload its rows to.
+
+    POST the rows to upload_url as newline-delimited JSON or (format "csv")
+    CSV; endpoints can be referenced by node ID, external ID or alias. Rows
+    are inserted in transactions of batch_size (default 500) and failed rows,
+    including unresolved references, are reported.
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["relationship_import"].begin(batch_size, format)
+        return Success({
+            "operation": op.to_dict(),
+            "upload_url": f"/tenants/{tenant_id}/imports/{op.id}/relationships",
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def begin_node_import(tenant_id: str, node_type_id: str, mapping: Dict[str, Any]) -> Result:
+    """
+    Reserve a CSV import of nodes of a type and get the path to upload the CSV to.
+
+    mapping: Where each node value comes from, e.g. {"fields": [{"column": "Email", "path": "email"}]}
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["node_import"].begin(node_type_id, mapping)
+        return Success({
+            "operation": op.to_dict(),
+            "upload_url": f"/tenants/{tenant_id}/imports/{op.id}/nodes",
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def pause_import(tenant_id: str, id: str) -> Result:
+    """
+    Pause a running node or relationship import before its next write.
+
+    The upload request stays open until the import is resumed.
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["operation"].pause(id, [NODE_IMPORT_KIND, RELATIONSHIP_IMPORT_KIND])
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def resume_import(tenant_id: str, id: str) -> Result:
+    """Resume a paused node or relationship import."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["operation"].resume(id, [NODE_IMPORT_KIND, RELATIONSHIP_IMPORT_KIND])
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def preview_node_import(
+    tenant_id: str,
+    node_type_id: str,
+    mapping: Dict[str, Any],
+    csv: str,
+    limit: int = 0
+) -> Result:
+    """
+    Map the first rows of a CSV sample like a node import, without writing anything.
+
+    csv: CSV text starting with the header
+    limit: Rows to map (default 20, max 1000)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        header, rows = await services["node_import"].preview(node_type_id, mapping, csv, limit)
+        return Success({
+            "columns": header,
+            "rows": rows,
+            "valid_count": sum(1 for r in rows if "node" in r),
+            "failed_count": sum(1 for r in rows if "error" in r),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_relationships(
+    tenant_id: str,
+    source_node_id: str = "",
+    target_node_id: str = "",
+    relationship_type: str = "",
+    pagination: Dict[str, Any] = None,
+    order_by: str = "",
+    fields: List[str] = None,
+    read_session: str = "",
+    valid_at: str = "",
+    dedupe: bool = False
+) -> Result:
+    """
+    List relationships for a tenant with optional filtering.
+
+    fields: Read mask of top-level fields to return (default: all)
+    read_session: Token from begin_read_session; reads observe that session's snapshot
+    valid_at: ISO 8601 time; only relationships valid then are listed (default: all)
+    dedupe: List parallel relationships (same type, source and target) once, with parallel_count
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+        
+        services = await resolve_tenant_services(tenant_id, read_session)
+        rels, result = await services["relationship"].list(
+            source_node_id or None,
+            target_node_id or None,
+            relationship_type or None,
+            page_size,
+            page_token,
+            order_by,
+            valid_at,
+            dedupe
+        )
+        return Success({
+            "relationships": [_apply_read_mask(r.to_dict(), fields) for r in rels],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# RelationshipType Service Methods
+# ============================================================================
+
+@method
+async def create_relationship_type(
+    tenant_id: str,
+    name: str,
+    description: str = "",
+    directionality: str = "directed",
+    allowed_source_node_type_ids: List[str] = None,
+    allowed_target_node_type_ids: List[str] = None,
+    on_source_delete: str = "delete_edge",
+    on_target_delete: str = "delete_edge",
+    derivation: Dict[str, Any] = None,
+    on_duplicate: str = "allow"
+) -> Result:
+    """
+    Register a new relationship type.
+
+    on_source_delete: When the source node is deleted: "delete_edge", "delete_node" (delete the target) or "restrict"
+    on_target_delete: When the target node is deleted: "delete_edge", "delete_node" (delete the source) or "restrict"
+    derivation: Makes the type derived: {"path": [{"relationship_type", "direction"}, ...], "materialized": bool}
+    on_duplicate: When creating a relationship identical to an existing one: "allow", "reject" or "return_existing"
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        rel_type = await services["relationship_type"].create(
+            name,
+            description,
+            directionality,
+            allowed_source_node_type_ids,
+            allowed_target_node_type_ids,
+            on_source_delete,
+            on_target_delete,
+            derivation,
+            on_duplicate
+        )
+        return Success({"relationship_type": rel_type.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_relationship_type(id: str, tenant_id: str) -> Result:
+    """Get a relationship type by ID."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        rel_type = await services["relationship_type"].get_by_id(id)
+        return Success({"relationship_type": rel_type.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_relationship_type(
+    id: str,
+    tenant_id: str,
+    name: str = "",
+    description: str = "",
+    directionality: str = "",
+    allowed_source_node_type_ids: List[str] = None,
+    allowed_target_node_type_ids: List[str] = None,
+    on_source_delete: str = "",
+    on_target_delete: str = "",
+    derivation: Dict[str, Any] = None,
+    on_duplicate: str = ""
+) -> Result:
+    """Update an existing relationship type (an empty derivation makes a derived type stored again)."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        rel_type = await services["relationship_type"].update(
+            id,
+            name,
+            description,
+            directionality,
+            allowed_source_node_type_ids,
+            allowed_target_node_type_ids,
+            on_source_delete,
+            on_target_delete,
+            derivation,
+            on_duplicate
+        )
+        return Success({"relationship_type": rel_type.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def refresh_derived_relationships(id: str, tenant_id: str) -> Result:
+    """Recompute the relationships of a materialized derived relationship type."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        rel_type, count = await services["relationship_type"].refresh_derived(id)
+        return Success({"relationship_type": rel_type.to_dict(), "relationship_count": count})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_relationship_type(id: str, tenant_id: str) -> Result:
+    """Delete a relationship type."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["relationship_type"].delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_relationship_types(tenant_id: str, pagination: Dict[str, Any] = None, order_by: str = "") -> Result:
+    """List relationship types for a tenant."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+        
+        services = await resolve_tenant_services(tenant_id)
+        rel_types, result = await services["relationship_type"].list(page_size, page_token, order_by)
+        return Success({
+            "relationship_types": [rt.to_dict() for rt in rel_types],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def discover_relationship_types(
+    tenant_id: str,
+    source_node_type_id: str = "",
+    target_node_type_id: str = ""
+) -> Result:
+    """List relationship types that can connect the given node types."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        options = await services["relationship_type"].discover(
+            source_node_type_id or None,
+            target_node_type_id or None
+        )
+        return Success({"options": options})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# WriteHook Service Methods
+# ============================================================================
+
+@method
+async def create_write_hook(
+    tenant_id: str,
+    name: str,
+    expression: str,
+    phase: str = "pre_write",
+    action: str = "validate",
+    node_type_id: str = "",
+    operations: List[str] = None,
+    timeout_ms: int = 100,
+    priority: int = 0,
+    enabled: bool = True
+) -> Result:
+    """Register a CEL write hook that runs before or after node writes."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        hook = await services["write_hook"].create(
+            name, expression, phase, action, node_type_id, operations, timeout_ms, priority, enabled
+        )
+        return Success({"write_hook": hook.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_write_hook(id: str, tenant_id: str) -> Result:
+    """Get a write hook by ID."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        hook = await services["write_hook"].get_by_id(id)
+        return Success({"write_hook": hook.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_write_hook(
+    id: str,
+    tenant_id: str,
+    name: str = "",
+    expression: str = "",
+    phase: str = "",
+    action: str = "",
+    node_type_id: Optional[str] = None,
+    operations: List[str] = None,
+    timeout_ms: Optional[int] = None,
+    priority: Optional[int] = None,
+    enabled: Optional[bool] = None
+) -> Result:
+    """Update an existing write hook."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        hook = await services["write_hook"].update(
+            id, name, expression, phase, action, node_type_id, operations, timeout_ms, priority, enabled
+        )
+        return Success({"write_hook": hook.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_write_hook(id: str, tenant_id: str) -> Result:
+    """Delete a write hook."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["write_hook"].delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_write_hooks(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List write hooks for a tenant in run order."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+        
+        services = await resolve_tenant_services(tenant_id)
+        hooks, result = await services["write_hook"].list(page_size, page_token)
+        return Success({
+            "write_hooks": [h.to_dict() for h in hooks],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Data Migration Methods
+# ============================================================================
+
+@method
+async def create_data_migration(
+    tenant_id: str,
+    node_type_id: str,
+    name: str = "",
+    rules: List[Dict[str, Any]] = None,
+    expression: str = "",
+    schema: str = ""
+) -> Result:
+    """
+    Register a migration of a node type's data to its next schema version.
+
+    Nodes are migrated when read; backfill_data_migrations migrates the stored ones.
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        migration, node_type = await services["data_migration"].create(
+            node_type_id, name, rules, expression, schema
+        )
+        return Success({"data_migration": migration.to_dict(), "node_type": node_type.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_data_migrations(tenant_id: str, node_type_id: str) -> Result:
+    """List a node type's data migrations, oldest schema version first."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        migrations = await services["data_migration"].list(node_type_id)
+        return Success({"data_migrations": [m.to_dict() for m in migrations]})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def backfill_data_migrations(tenant_id: str, node_type_id: str) -> Result:
+    """Migrate stored nodes of a type to its current schema version in the background."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["data_migration"].backfill(node_type_id)
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def create_validation_report(tenant_id: str) -> Result:
+    """
+    Validate every stored node against its node type's schema and validate hooks, and every
+    relationship against its type's endpoint constraints, in the background.
+
+    Poll get_validation_report with the returned operation's ID for progress and the report.
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["validation_report"].start()
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_validation_report(tenant_id: str, id: str) -> Result:
+    """
+    Get a validation report's operation; once completed, download_url is a
+    time-limited URL of the report (newline-delimited JSON, one line per non-conforming entity).
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op, url = await services["validation_report"].get(id)
+        return Success({"operation": op.to_dict(), "download_url": url or None})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def collect_orphaned_relationships(tenant_id: str, dry_run: bool = True) -> Result:
+    """
+    Find relationships whose source or target node no longer exists in the background,
+    deleting them unless dry_run.
+
+    Poll get_operation with the returned operation's ID; its result is the report.
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["orphan_gc"].start(dry_run)
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Directory Methods
+# ============================================================================
+
+@method
+async def create_directory_user(
+    tenant_id: str,
+    user_name: str,
+    display_name: str = "",
+    email: str = "",
+    external_id: str = "",
+    active: bool = True
+) -> Result:
+    """
+    Add a human user to a tenant's directory.
+
+    user_name: Matched against callers' X-User-ID header
+    external_id: The user's ID in the customer's identity provider (also matched against X-User-ID)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        user = await services["directory"].create_user(user_name, display_name, email, external_id, active)
+        return Success({"directory_user": user.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_directory_user(id: str, tenant_id: str) -> Result:
+    """Get a directory user, with their group names."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        user = await services["directory"].get_user(id)
+        return Success({"directory_user": user.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_directory_user(
+    id: str,
+    tenant_id: str,
+    user_name: str = "",
+    display_name: Optional[str] = None,
+    email: Optional[str] = None,
+    external_id: Optional[str] = None,
+    active: Optional[bool] = None
+) -> Result:
+    """Update a directory user; active=true re-enables a disabled user."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        user = await services["directory"].update_user(id, user_name, display_name, email, external_id, active)
+        return Success({"directory_user": user.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def disable_directory_user(id: str, tenant_id: str) -> Result:
+    """Disable a directory user; their calls to the tenant are denied."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        user = await services["directory"].disable_user(id)
+        return Success({"directory_user": user.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_directory_user(id: str, tenant_id: str) -> Result:
+    """Remove a user from a tenant's directory."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["directory"].delete_user(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_directory_users(
+    tenant_id: str,
+    pagination: Dict[str, Any] = None,
+    active: Optional[bool] = None,
+    user_name_prefix: str = "",
+    group_id: str = ""
+) -> Result:
+    """List directory users by user name, optionally only (in)active users or a group's members."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        users, result = await services["directory"].list_users(
+            page_size, page_token, active, user_name_prefix, group_id
+        )
+        return Success({
+            "directory_users": [u.to_dict() for u in users],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def create_directory_group(tenant_id: str, display_name: str, external_id: str = "") -> Result:
+    """Create a directory group."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        group = await services["directory"].create_group(display_name, external_id)
+        return Success({"directory_group": group.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_directory_group(id: str, tenant_id: str) -> Result:
+    """Get a directory group."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        group = await services["directory"].get_group(id)
+        return Success({"directory_group": group.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_directory_group(
+    id: str,
+    tenant_id: str,
+    display_name: str = "",
+    external_id: Optional[str] = None
+) -> Result:
+    """Rename a directory group or change its external ID."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        group = await services["directory"].update_group(id, display_name, external_id)
+        return Success({"directory_group": group.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_directory_group(id: str, tenant_id: str) -> Result:
+    """Delete a directory group; its members stay in the directory."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        await services["directory"].delete_group(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_directory_groups(tenant_id: str, pagination: Dict[str, Any] = None) -> Result:
+    """List directory groups by display name (list members with list_directory_users group_id)."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        services = await resolve_tenant_services(tenant_id)
+        groups, result = await services["directory"].list_groups(page_size, page_token)
+        return Success({
+            "directory_groups": [g.to_dict() for g in groups],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def add_directory_group_member(tenant_id: str, group_id: str, user_id: str) -> Result:
+    """Add a directory user to a group."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        group = await services["directory"].add_member(group_id, user_id)
+        return Success({"directory_group": group.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def remove_directory_group_member(tenant_id: str, group_id: str, user_id: str) -> Result:
+    """Remove a directory user from a group."""
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        group = await services["directory"].remove_member(group_id, user_id)
+        return Success({"directory_group": group.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Facet Methods
+# ============================================================================
+
+@method
+async def get_distinct_values(
+    tenant_id: str,
+    field: str,
+    entity: str = "node",
+    node_type_id: str = "",
+    relationship_type: str = "",
+    limit: int = 100
+) -> Result:
+    """
+    Get distinct values of a field with counts, for faceted filters.
+
+    entity: "node" or "relationship"
+    field: A column (node_type_id, relationship_type, source_node_id, target_node_id) or a data path such as data.status
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        if entity == "node":
+            values = await services["node"].distinct_values(field, node_type_id or None, limit)
+        elif entity == "relationship":
+            values = await services["relationship"].distinct_values(field, relationship_type or None, limit)
+        else:
+            raise ValueError("entity must be one of: node, relationship")
+        return Success({
+            "field": field,
+            "values": [v.to_dict() for v in values],
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_date_histogram(
+    tenant_id: str,
+    field: str = "created_at",
+    entity: str = "node",
+    interval: str = "day",
+    node_type_id: str = "",
+    relationship_type: str = "",
+    start: str = "",
+    end: str = "",
+    timezone: str = ""
+) -> Result:
+    """
+    Count nodes or relationships per day (hour, week, month, year) of a timestamp, in tenant-local time.
+
+    field: created_at or updated_at
+    timezone: IANA time zone to bucket in (default: the tenant's time zone)
+    start, end: ISO 8601 timestamps, or dates meaning local midnight, bounding the range
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        if not timezone:
+            timezone = (await _tenant_service.get_by_id(tenant_id)).timezone
+        if entity == "node":
+            buckets = await services["node"].date_histogram(
+                field, node_type_id or None, interval, timezone, start, end
+            )
+        elif entity == "relationship":
+            buckets = await services["relationship"].date_histogram(
+                field, relationship_type or None, interval, timezone, start, end
+            )
+        else:
+            raise ValueError("entity must be one of: node, relationship")
+        return Success({
+            "field": field,
+            "interval": interval,
+            "timezone": timezone,
+            "buckets": [b.to_dict() for b in buckets],
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Graph Traversal Methods
+# ============================================================================
+
+@method
+async def get_subgraph(
+    tenant_id: str,
+    seed_node_ids: List[str],
+    depth: int = 1,
+    relationship_types: List[str] = None,
+    node_type_ids: List[str] = None,
+    direction: str = "both",
+    max_nodes: int = 0,
+    valid_at: str = "",
+    fields: List[str] = None,
+    read_session: str = ""
+) -> Result:
+    """
+    Get the nodes reachable from seed nodes within depth and all relationships between them.
+
+    relationship_types: Relationship types to follow and return (default: all)
+    node_type_ids: Node types of neighbors to include (default: all; seeds are always included)
+    direction: "out", "in" or "both" (default)
+    max_nodes: Stop adding nodes at this many (default 1000, at most 10000); the result is then truncated
+    valid_at: ISO 8601 time the relationships must be valid at (default: now)
+    fields: Read mask applied to the returned nodes
+    read_session: Token from begin_read_session; the subgraph observes that session's snapshot
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id, read_session)
+        subgraph = await services["subgraph"].extract(
+            seed_node_ids, depth, relationship_types, node_type_ids, direction, max_nodes, valid_at
+        )
+        nodes = []
+        for node in subgraph.nodes:
+            entry = _apply_read_mask(node.to_dict(), fields)
+            entry["depth"] = subgraph.depths[node.id]
+            nodes.append(entry)
+        return Success({
+            "nodes": nodes,
+            "relationships": [r.to_dict() for r in subgraph.relationships],
+            "truncated": subgraph.truncated,
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_graph_view(
+    tenant_id: str,
+    seed_node_ids: List[str] = None,
+    seed_filter: Dict[str, Any] = None,
+    depth: int = 1,
+    relationship_types: List[str] = None,
+    node_type_ids: List[str] = None,
+    direction: str = "both",
+    max_nodes: int = 0,
+    valid_at: str = "",
+    label_paths: List[str] = None,
+    label_paths_by_type: Dict[str, List[str]] = None,
+    data_paths: List[str] = None,
+    layout: str = "",
+    format: str = "cytoscape",
+    read_session: str = ""
+) -> Result:
+    """
+    Get the subgraph around seed nodes as a payload for graph visualization libraries.
+
+    seed_node_ids / seed_filter: The seed nodes, by ID or as a bulk operation filter (exactly one)
+    depth, relationship_types, node_type_ids, direction, max_nodes, valid_at: As for get_subgraph
+    label_paths: Data paths tried in order for node labels, e.g. ["data.name", "data.title"]
+    label_paths_by_type: Label paths per node type name, overriding label_paths
+    data_paths: Data paths copied into each node's properties
+    layout: "circle", "grid" or "concentric" to include starting positions (default: none)
+    format: "cytoscape" (default) for Cytoscape.js elements, or "d3" for nodes and links
+    read_session: Token from begin_read_session; the view observes that session's snapshot
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id, read_session)
+        view = await services["graph_view"].view(
+            seed_node_ids, seed_filter, depth, relationship_types, node_type_ids, direction, max_nodes,
+            valid_at, label_paths, label_paths_by_type, data_paths, layout, format
+        )
+        return Success(view)
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Graph Statistics Methods
+# ============================================================================
+
+@method
+async def get_graph_stats(tenant_id: str, refresh: bool = False, max_age_seconds: int = 300) -> Result:
+    """
+    Get node/relationship counts by type, degree distribution, top-degree nodes and connected components.
+
+    refresh: Recompute now instead of returning the cached snapshot
+    max_age_seconds: Snapshots older than this are returned with stale=true and refreshed in the background
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        stats = await services["graph_stats"].get(refresh, max_age_seconds)
+        return Success({"stats": stats})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def compute_centrality(
+    tenant_id: str,
+    algorithm: str = "pagerank",
+    relationship_types: List[str] = None,
+    direction: str = "both"
+) -> Result:
+    """
+    Compute a centrality score for every node in the background, stored in node metadata as centrality.<algorithm>.
+
+    algorithm: "pagerank", "degree" or "betweenness"
+    relationship_types: Relationship types forming the graph (default: all)
+    direction: Direction followed by degree and betweenness: "out", "in" or "both" (default)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["centrality"].start(algorithm, relationship_types, direction)
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_central_nodes(
+    tenant_id: str,
+    algorithm: str = "pagerank",
+    limit: int = 10,
+    node_type_id: str = "",
+    fields: List[str] = None
+) -> Result:
+    """
+    List the nodes with the highest scores from the last compute_centrality run of an algorithm.
+
+    limit: Number of nodes (default 10, at most 1000)
+    node_type_id: Only nodes of this type
+    fields: Read mask applied to the returned nodes
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        top = await services["centrality"].top(algorithm, limit, node_type_id)
+        return Success({
+            "nodes": [{"node": _apply_read_mask(node.to_dict(), fields), "score": score} for node, score in top],
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def detect_communities(
+    tenant_id: str,
+    algorithm: str = "components",
+    relationship_types: List[str] = None
+) -> Result:
+    """
+    Assign every node a community ID in the background, stored in node metadata as community.<algorithm>.
+
+    algorithm: "components" (connected components) or "label_propagation"
+    relationship_types: Relationship types forming the graph (default: all)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        op = await services["community"].start(algorithm, relationship_types)
+        return Success({"operation": op.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_communities(
+    tenant_id: str,
+    algorithm: str = "components",
+    limit: int = 100,
+    min_size: int = 2
+) -> Result:
+    """
+    List the communities found by the last detect_communities run of an algorithm, largest first.
+
+    limit: Number of communities (default 100, at most 1000)
+    min_size: Smallest community size to list (default 2, leaving out isolated nodes)
+    """
+    try:
+        services = await resolve_tenant_services(tenant_id)
+        communities = await services["community"].list_communities(algorithm, limit, min_size)
+        return Success({
+            "communities": [{"community_id": id, "size": size} for id, size in communities],
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Authorization Policy Methods
+# ============================================================================
+
+def _require_authz_policy_service() -> AuthzPolicyService:
+    if _authz_policy_service is None:
+        raise RuntimeError("authorization policies are not configured")
+    return _authz_policy_service
+
+
+@method
+async def create_authz_policy(
+    name: str,
+    effect: str,
+    expression: str,
+    description: str = "",
+    methods: List[str] = None,
+    enabled: bool = True
+) -> Result:
+    """
+    Create a CEL authorization policy evaluated for every JSON-RPC call.
+
+    effect: "allow" or "deny"; a matching deny always wins
+    expression: CEL expression over subject, tenant, entity, operation, method and params
+    methods: Method name globs the policy applies to (empty applies to all)
+    """
+    try:
+        policy = await _require_authz_policy_service().create(
+            name, effect, expression, description, methods, enabled
+        )
+        return Success({"authz_policy": policy.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_authz_policy(id: str) -> Result:
+    """Get an authorization policy by ID."""
+    try:
+        policy = await _require_authz_policy_service().get_by_id(id)
+        return Success({"authz_policy": policy.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_authz_policy(
+    id: str,
+    name: str = "",
+    effect: str = "",
+    expression: str = "",
+    description: Optional[str] = None,
+    methods: List[str] = None,
+    enabled: Optional[bool] = None
+) -> Result:
+    """Update an existing authorization policy."""
+    try:
+        policy = await _require_authz_policy_service().update(
+            id, name, effect, expression, description, methods, enabled
+        )
+        return Success({"authz_policy": policy.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_authz_policy(id: str) -> Result:
+    """Delete an authorization policy."""
+    try:
+        await _require_authz_policy_service().delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_authz_policies(pagination: Dict[str, Any] = None) -> Result:
+    """List authorization policies."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+        
+        policies, result = await _require_authz_policy_service().list(page_size, page_token)
+        return Success({
+            "authz_policies": [p.to_dict() for p in policies],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Tenant Template Methods
+# ============================================================================
+
+def _require_template_service() -> TemplateService:
+    if _template_service is None:
+        raise RuntimeError("tenant templates are not configured")
+    return _template_service
+
+
+@method
+async def create_tenant_template(name: str, definition: Dict[str, Any], description: str = "") -> Result:
+    """
+    Create a tenant template for create_tenant's from_template.
+
+    definition: {"node_types", "relationship_types", "nodes", "relationships"}; seed nodes are
+        referenced by their "ref" and node types by name
+    """
+    try:
+        template = await _require_template_service().create(name, definition, description)
+        return Success({"tenant_template": template.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_tenant_template(id: str) -> Result:
+    """Get a tenant template by ID."""
+    try:
+        template = await _require_template_service().get_by_id(id)
+        return Success({"tenant_template": template.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def update_tenant_template(
+    id: str,
+    name: str = "",
+    description: Optional[str] = None,
+    definition: Dict[str, Any] = None
+) -> Result:
+    """Update a tenant template; tenants already created from it are not changed."""
+    try:
+        template = await _require_template_service().update(id, name, description, definition)
+        return Success({"tenant_template": template.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def delete_tenant_template(id: str) -> Result:
+    """Delete a tenant template."""
+    try:
+        await _require_template_service().delete(id)
+        return Success({})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_tenant_templates(pagination: Dict[str, Any] = None) -> Result:
+    """List tenant templates."""
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        templates, result = await _require_template_service().list(page_size, page_token)
+        return Success({
+            "tenant_templates": [t.to_dict() for t in templates],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Impersonation and Audit Methods
+# ============================================================================
+
+def _require_impersonation_service() -> ImpersonationService:
+    if _impersonation_service is None or _audit_service is None:
+        raise RuntimeError("impersonation is not configured")
+    return _impersonation_service
+
+
+@method
+async def start_impersonation(tenant_id: str, reason: str, user_id: str = "", ttl_seconds: int = 3600) -> Result:
+    """
+    Issue a token that lets the calling admin act within a tenant (send it as X-Impersonation-Token).
+
+    reason: Why the tenant is being accessed (recorded in the audit log)
+    user_id: Tenant member to act as, so authorization policies apply as for them (default: the admin)
+    ttl_seconds: Token lifetime, at most IMPERSONATION_MAX_TTL_SECONDS
+    """
+    try:
+        ctx = current_context()
+        token, grant = await _require_impersonation_service().start(
+            ctx.admin_subject_id(), tenant_id, reason, user_id, ttl_seconds, ctx.request_id
+        )
+        return Success({"token": token, "impersonation": grant.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def end_impersonation(id: str) -> Result:
+    """Revoke an impersonation token before it expires."""
+    try:
+        ctx = current_context()
+        grant = await _require_impersonation_service().end(id, ctx.admin_subject_id(), ctx.request_id)
+        return Success({"impersonation": grant.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_audit_events(
+    tenant_id: str = "",
+    impersonated_only: bool = False,
+    pagination: Dict[str, Any] = None
+) -> Result:
+    """
+    List audit log events, newest first (admins only).
+
+    impersonated_only: Only return calls made with an impersonation token
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        _require_impersonation_service().require_admin(current_context().admin_subject_id())
+        events, result = await _audit_service.list(page_size, page_token, tenant_id, impersonated_only)
+        return Success({
+            "audit_events": [e.to_dict() for e in events],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# API Key Methods
+# ============================================================================
+
+def _require_api_key_service() -> ApiKeyService:
+    if _api_key_service is None:
+        raise RuntimeError("api keys are not configured")
+    return _api_key_service
+
+
+@method
+async def create_api_key(
+    name: str,
+    scope: str,
+    tenant_ids: List[str] = None,
+    subject_id: str = "",
+    ttl_seconds: int = 0,
+) -> Result:
+    """
+    Issue an API key that runs as the caller (send it as Authorization: Bearer <key>).
+
+    scope: "read", "write", "export" or "admin"; the key may only call that scope's methods
+    tenant_ids: Tenants the key may access (default: any)
+    subject_id: Subject the key runs as (admins only; default: the caller)
+    ttl_seconds: Key lifetime (default: until revoked)
+    """
+    try:
+        ctx = current_context()
+        token, key = await _require_api_key_service().create(
+            ctx.subject_id, name, scope, tenant_ids, subject_id, ttl_seconds, ctx.admin_subject_id()
+        )
+        return Success({"key": token, "api_key": key.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def list_api_keys(subject_id: str = "", pagination: Dict[str, Any] = None) -> Result:
+    """
+    List the caller's API keys, newest first; keys themselves are never returned.
+
+    subject_id: List this subject's keys (admins only; admins without it list every key)
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        ctx = current_context()
+        keys, result = await _require_api_key_service().list(
+            ctx.subject_id, subject_id, page_size, page_token, ctx.admin_subject_id()
+        )
+        return Success({
+            "api_keys": [k.to_dict() for k in keys],
+            "pagination": result.to_dict(),
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def revoke_api_key(id: str) -> Result:
+    """Revoke an API key; calls with it are rejected within seconds."""
+    try:
+        ctx = current_context()
+        key = await _require_api_key_service().revoke(ctx.subject_id, id, ctx.admin_subject_id())
+        return Success({"api_key": key.to_dict()})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Admin Search Methods
+# ============================================================================
+
+def _require_admin_search_service() -> AdminSearchService:
+    if _admin_search_service is None:
+        raise RuntimeError("admin search is not configured")
+    _require_impersonation_service().require_admin(current_context().admin_subject_id())
+    return _admin_search_service
+
+
+@method
+async def admin_search_nodes(
+    value: str,
+    match: List[str] = None,
+    tenant_ids: List[str] = None,
+    max_results: int = 0
+) -> Result:
+    """
+    Find the nodes of any tenant matching a value, annotated with their tenant (admins only; audited).
+
+    value: Node ID (internal or external), alias value or text to search node data for
+    match: Any of "id", "alias" and "content" (default: all)
+    tenant_ids: Only search these tenants (default: every tenant)
+    max_results: Results to return, best first (default 50, at most 200)
+    """
+    try:
+        service = _require_admin_search_service()
+        ctx = current_context()
+        search = await service.search(ctx.subject_id, value, match, tenant_ids, max_results, ctx.request_id)
+        return Success({
+            "results": [r.to_dict() for r in search.results],
+            "tenants_searched": search.tenants_searched,
+            "errors": search.errors,
+            "truncated": search.truncated,
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Operator Statistics Methods
+# ============================================================================
+
+def _require_stats_service() -> StatsService:
+    if _stats_service is None:
+        raise RuntimeError("statistics are not configured")
+    _require_impersonation_service().require_admin(current_context().admin_subject_id())
+    return _stats_service
+
+
+@method
+async def get_system_stats(slow_query_limit: int = 10) -> Result:
+    """
+    Database sizes per tenant, cache hit ratio and slowest query classes across the cluster (admins only).
+
+    slow_query_limit: Number of slowest query classes to return (needs pg_stat_statements)
+    """
+    try:
+        stats = await _require_stats_service().system_stats(slow_query_limit)
+        return Success({"stats": stats})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_tenant_stats(tenant_id: str, slow_query_limit: int = 10) -> Result:
+    """
+    Size, row counts, index sizes, cache hit ratios and slowest query classes of a tenant database (admins only).
+
+    slow_query_limit: Number of slowest query classes to return (needs pg_stat_statements)
+    """
+    try:
+        stats = await _require_stats_service().tenant_stats(tenant_id, slow_query_limit)
+        return Success({"stats": stats})
+    except Exception as e:
+        return _handle_error(e)
+
+
+def _require_table_maintenance_service() -> TableMaintenanceService:
+    if _table_maintenance_service is None:
+        raise RuntimeError("table maintenance is not configured")
+    _require_impersonation_service().require_admin(current_context().admin_subject_id())
+    return _table_maintenance_service
+
+
+@method
+async def get_tenant_bloat_report(tenant_id: str) -> Result:
+    """Dead rows, estimated bloat and last vacuum and analyze of a tenant's tables, and what each needs (admins only)."""
+    try:
+        report = await _require_table_maintenance_service().report(tenant_id)
+        return Success({"report": report})
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def run_tenant_maintenance(tenant_id: str, tables: List[str] = None, analyze_only: bool = False) -> Result:
+    """
+    VACUUM (ANALYZE) a tenant's bloated tables and ANALYZE its stale partitioned tables (admins only).
+
+    tables: Maintain these tables regardless of thresholds (default: those that need it)
+    analyze_only: Only ANALYZE, refreshing planner statistics without vacuuming
+    """
+    try:
+        result = await _require_table_maintenance_service().run(tenant_id, tables, analyze_only)
+        return Success(result)
+    except Exception as e:
+        return _handle_error(e)
+
+
+def _require_explain_service() -> ExplainService:
+    if _explain_service is None:
+        raise RuntimeError("query explanations are not configured")
+    _require_impersonation_service().require_admin(current_context().admin_subject_id())
+    return _explain_service
+
+
+@method
+async def explain_query(tenant_id: str, method: str, params: Dict[str, Any] = None, analyze: bool = True) -> Result:
+    """
+    Run a read call and return the SQL it ran on the tenant database with EXPLAIN ANALYZE plans (admins only).
+
+    method: Read method to explain, e.g. "list_nodes", "search_nodes" or "get_subgraph"
+    params: Its parameters (except tenant_id)
+    analyze: Run each statement again for actual rows and timings (false: estimated plans only)
+    """
+    try:
+        service = _require_explain_service()
+        params = params or {}
+        if not isinstance(params, dict):
+            raise ValueError("params must be an object")
+        _check_saved_query_params(method, params)
+
+        async def run() -> Optional[Dict[str, Any]]:
+            result = await global_methods[method](tenant_id=tenant_id, **params)
+            if result_error_code(result) is None:
+                return None
+            error = getattr(result, "_value", result)
+            return {"code": error.code, "message": error.message}
+
+        explanation = await service.explain(tenant_id, method, run, analyze)
+        return Success({"explanation": explanation})
+    except Exception as e:
+        return _handle_error(e)
+
+
+# ============================================================================
+# Billing Methods
+# ============================================================================
+
+def _require_billing_service() -> BillingService:
+    if _billing_service is None:
+        raise RuntimeError("billing events are not configured")
+    _require_impersonation_service().require_admin(current_context().admin_subject_id())
+    return _billing_service
+
+
+@method
+async def list_billing_events(tenant_id: str = "", pagination: Dict[str, Any] = None) -> Result:
+    """
+    Usage events (api_calls, export_bytes, storage_bytes) in the order they were written (admins only).
+
+    tenant_id: Only this tenant's events (default: all tenants)
+    pagination: The next_page_token of the last page returns only events written since
+    """
+    try:
+        page_size = 0
+        page_token = ""
+        if pagination:
+            page_size = pagination.get("page_size", 0)
+            page_token = pagination.get("page_token", "")
+
+        events, next_page_token = await _require_billing_service().list_events(tenant_id, page_size, page_token)
+        return Success({
+            "events": [e.to_dict() for e in events],
+            "pagination": {"next_page_token": next_page_token},
+        })
+    except Exception as e:
+        return _handle_error(e)
+
+
+@method
+async def get_billing_rollup(tenant_id: str, month: str = "") -> Result:
+    """
+    A tenant's usage in a calendar month: API calls and export bytes in total and
+    per method, and database size at the start and end of the month (admins only).
+
+    month: YYYY-MM in UTC (default: the current month)
+    """
+    try:
+        rollup = await _require_billing_service().monthly_rollup(tenant_id, month)
+        return Success({"rollup": rollup})
+    except Exception as e:
+        return _handle_error(e)
+
+
 # ============================================================================
 # RPC Discovery Methods (OpenRPC Introspection)
 # ============================================================================
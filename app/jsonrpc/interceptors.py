@@ -0,0 +1,99 @@
+"""
+Interceptor chain for JSON-RPC methods.
+
+Interceptors wrap every registered JSON-RPC method and can inspect or
+short-circuit a call (authorization, logging, limits). Each interceptor is an
+async callable ``(call, call_next) -> Result``; it must either return a
+Result itself or ``await call_next(call)``.
+"""
+
+import functools
+import inspect
+from dataclasses import dataclass
+from typing import Any, Awaitable, Callable, Dict, List, Optional
+
+from jsonrpcserver import Result
+from jsonrpcserver.methods import global_methods
+
+from app.jsonrpc.context import RequestContext, current_context
+
+
+@dataclass
+class RpcCall:
+    """A single JSON-RPC method invocation."""
+    method: str
+    params: Dict[str, Any]
+    context: RequestContext
+
+
+CallNext = Callable[[RpcCall], Awaitable[Result]]
+Interceptor = Callable[[RpcCall, CallNext], Awaitable[Result]]
+
+_interceptors: List[Interceptor] = []
+_wrapped_cache: Dict[str, Callable] = {}
+
+
+def result_error_code(result: Result) -> Optional[int]:
+    """Return the error code of a failed Result, or None for a success."""
+    # Results are Either values wrapping an ErrorResult or SuccessResult
+    value = getattr(result, "_value", result)
+    code = getattr(value, "code", None)
+    return code if isinstance(code, int) else None
+
+
+def add_interceptor(interceptor: Interceptor) -> None:
+    """Append an interceptor; interceptors run in registration order."""
+    _interceptors.append(interceptor)
+    _wrapped_cache.clear()
+
+
+def remove_interceptor(interceptor: Interceptor) -> None:
+    """Remove a previously added interceptor."""
+    if interceptor in _interceptors:
+        _interceptors.remove(interceptor)
+        _wrapped_cache.clear()
+
+
+def clear_interceptors() -> None:
+    """Remove all interceptors (used by tests)."""
+    _interceptors.clear()
+    _wrapped_cache.clear()
+
+
+def _wrap(name: str, func: Callable, chain: Optional[List[Interceptor]] = None) -> Callable:
+    """Wrap a method so calls flow through the interceptor chain (default: the registered interceptors)."""
+    sig = inspect.signature(func)
+    interceptors = _interceptors if chain is None else chain
+
+    @functools.wraps(func)
+    async def wrapper(*args, **kwargs):
+        bound = sig.bind(*args, **kwargs)
+        call = RpcCall(method=name, params=dict(bound.arguments), context=current_context())
+
+        async def invoke(index: int, c: RpcCall) -> Result:
+            if index == len(interceptors):
+                return await func(**c.params)
+            return await interceptors[index](c, lambda nxt: invoke(index + 1, nxt))
+
+        return await invoke(0, call)
+
+    return wrapper
+
+
+def dispatch_methods(last: Optional[Interceptor] = None) -> Dict[str, Callable]:
+    """
+    Return the registered methods wrapped with the interceptor chain.
+
+    last: Interceptor run after the registered ones, right before the method
+        (e.g. a check that needs the caller the credential interceptors set)
+    """
+    if last is not None:
+        chain = _interceptors + [last]
+        return {name: _wrap(name, func, chain) for name, func in global_methods.items()}
+    if not _interceptors:
+        return global_methods
+    if len(_wrapped_cache) != len(global_methods):
+        _wrapped_cache.clear()
+        for name, func in global_methods.items():
+            _wrapped_cache[name] = _wrap(name, func)
+    return _wrapped_cache
@@ -0,0 +1,101 @@
+"""
+Shape limits for JSON documents in JSON-RPC calls.
+
+Deeply nested documents, objects with huge numbers of keys and very long
+strings are slow to store, index and query as JSONB, so documents past the
+configured limits are rejected before they reach a service. The limits apply
+to the values of ``data``, ``metadata``, ``base_data`` and ``patch``
+parameters, including inside arrays of items (such as batch create nodes or
+push_changes changes); a data string is checked as the JSON it holds.
+Violations fail with -32602 and name the offending parameter in
+``data.field_violations``.
+"""
+
+import json
+from dataclasses import dataclass
+from typing import Any, List, Optional, Tuple
+
+from jsonrpcserver import Error, Result
+
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+
+# Parameters (and item fields) holding tenant JSON documents
+DOCUMENT_KEYS = ("data", "metadata", "base_data", "patch")
+
+
+@dataclass
+class JsonLimits:
+    """Maximum shape of a JSON document; 0 disables a limit."""
+    # Nesting levels of objects and arrays ({"a": 1} is 1 deep)
+    max_depth: int = 32
+    # Object keys in the whole document, at every level
+    max_keys: int = 10000
+    # Characters in any one string value or key
+    max_string_length: int = 1024 * 1024
+
+
+def check_document(value: Any, limits: JsonLimits) -> Optional[str]:
+    """Return why a JSON value exceeds the limits, or None if it is within them."""
+    keys = 0
+    stack: List[Tuple[Any, int]] = [(value, 0)]
+    while stack:
+        item, depth = stack.pop()
+        if isinstance(item, str):
+            if limits.max_string_length and len(item) > limits.max_string_length:
+                return f"string longer than {limits.max_string_length} characters"
+            continue
+        if not isinstance(item, (dict, list)):
+            continue
+        depth += 1
+        if limits.max_depth and depth > limits.max_depth:
+            return f"nested deeper than {limits.max_depth} levels"
+        if isinstance(item, dict):
+            keys += len(item)
+            if limits.max_keys and keys > limits.max_keys:
+                return f"more than {limits.max_keys} object keys"
+            stack.extend((key, depth) for key in item)
+            stack.extend((child, depth) for child in item.values())
+        else:
+            stack.extend((child, depth) for child in item)
+    return None
+
+
+def _find_documents(params: Any) -> List[Tuple[str, Any]]:
+    """Return (parameter path, document) for every document parameter, e.g. ("nodes[2].data", {...})."""
+    found = []
+    stack: List[Tuple[str, Any]] = [("", params)]
+    while stack:
+        path, item = stack.pop()
+        if isinstance(item, dict):
+            for key, value in item.items():
+                child = f"{path}.{key}" if path else str(key)
+                if key in DOCUMENT_KEYS:
+                    found.append((child, value))
+                elif isinstance(value, (dict, list)):
+                    stack.append((child, value))
+        elif isinstance(item, list):
+            stack.extend((f"{path}[{i}]", value) for i, value in enumerate(item) if isinstance(value, (dict, list)))
+    return found
+
+
+def json_limits_interceptor(limits: JsonLimits) -> Interceptor:
+    """Create an interceptor that rejects calls with JSON documents past the limits."""
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        violations = []
+        for field, document in _find_documents(call.params):
+            if isinstance(document, str):
+                try:
+                    document = json.loads(document)
+                except ValueError:
+                    # Not JSON: the method reports it
+                    continue
+            problem = check_document(document, limits)
+            if problem:
+                violations.append({"field": field, "description": problem})
+        if violations:
+            message = "; ".join(f"{v['field']}: {v['description']}" for v in violations)
+            return Error(-32602, f"JSON document exceeds limits: {message}", {"field_violations": violations})
+        return await call_next(call)
+
+    return interceptor
@@ -0,0 +1,50 @@
+"""
+Read-only maintenance mode for JSON-RPC calls.
+
+Calls that would write to a tenant in maintenance are rejected with -32004
+(Precondition Failed) and the maintenance reason. Reads are the methods
+read-scoped API keys may call (see is_read_method in app/authz/engine.py);
+tenant administration, which writes to the control database rather than
+the tenant's data, stays available so maintenance can be ended.
+"""
+
+import fnmatch
+
+from jsonrpcserver import Error, Result
+
+from app.authz.engine import is_read_method
+from app.authz.impersonation import call_tenant_id
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+from app.repository import PreconditionFailedError
+from app.service.maintenance import TenantMaintenanceCache
+
+# Writes allowed during maintenance
+ALLOWED_METHODS = (
+    "*_tenant",
+    "set_tenant_*",
+    "*_impersonation",
+    "add_user_to_tenant",
+    "remove_user_from_tenant",
+)
+
+
+def is_write_method(method: str) -> bool:
+    """Whether a method writes to the tenant it targets."""
+    if is_read_method(method):
+        return False
+    return not any(fnmatch.fnmatchcase(method, p) for p in ALLOWED_METHODS)
+
+
+def maintenance_interceptor(cache: TenantMaintenanceCache) -> Interceptor:
+    """Create an interceptor that rejects writes to tenants in maintenance."""
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        tenant_id = call_tenant_id(call)
+        if tenant_id and is_write_method(call.method):
+            try:
+                await cache.check_writable(tenant_id)
+            except PreconditionFailedError as e:
+                return Error(-32004, str(e))
+        return await call_next(call)
+
+    return interceptor
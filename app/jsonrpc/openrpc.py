@@ -34,6 +34,11 @@ def get_type_schema(param_type: type, default_value: Any = None) -> Dict[str, An
             args = getattr(param_type, '__args__', [])
             # Filter out None type
             non_none_args = [arg for arg in args if arg is not type(None)]
+            if len(non_none_args) > 1:
+                schema = {"oneOf": [get_type_schema(arg) for arg in non_none_args]}
+                if default_value is not None:
+                    schema["default"] = default_value
+                return schema
             if non_none_args:
                 schema = get_type_schema(non_none_args[0], default_value)
                 if default_value is not None:
@@ -185,6 +190,15 @@ def generate_openrpc_spec() -> Dict[str, Any]:
                     },
                     {
                         "$ref": "#/components/errors/ValidationError"
+                    },
+                    {
+                        "$ref": "#/components/errors/PermissionDenied"
+                    },
+                    {
+                        "$ref": "#/components/errors/PreconditionFailed"
+                    },
+                    {
+                        "$ref": "#/components/errors/AlreadyExists"
                     }
                 ]
             })
@@ -273,6 +287,30 @@ def generate_openrpc_spec() -> Dict[str, Any]:
                         "type": "string",
                         "description": "Error details"
                     }
+                },
+                "PermissionDenied": {
+                    "code": -32003,
+                    "message": "Permission denied",
+                    "data": {
+                        "type": "string",
+                        "description": "Error details"
+                    }
+                },
+                "PreconditionFailed": {
+                    "code": -32004,
+                    "message": "Precondition failed",
+                    "data": {
+                        "type": "string",
+                        "description": "Error details"
+                    }
+                },
+                "AlreadyExists": {
+                    "code": -32005,
+                    "message": "Already exists",
+                    "data": {
+                        "type": "string",
+                        "description": "Error details"
+                    }
                 }
             }
         }
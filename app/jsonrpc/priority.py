@@ -0,0 +1,51 @@
+"""
+Priority classes of JSON-RPC calls (see app/db/priority.py).
+
+A call runs as ``interactive`` unless it asks for ``batch`` with the
+``X-Priority`` header, or its caller is mapped to batch priority: a client
+certificate mapping with ``"priority": "batch"`` (see
+app/authz/client_certs.py). A call can lower its caller's priority but not
+raise it. Batch calls draw on a share of each database pool, and at most
+BATCH_MAX_CONCURRENT_CALLS of them run at once per process; others wait
+their turn.
+"""
+
+import asyncio
+from typing import Optional
+
+from jsonrpcserver import Error, Result
+
+from app.db.priority import BATCH, PRIORITIES, lowest_priority, reset_priority, set_priority
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall
+from app.metrics import metrics
+
+# Header a call asks for a priority with
+PRIORITY_HEADER = "x-priority"
+
+
+def priority_interceptor(max_concurrent_batch: int = 0) -> Interceptor:
+    """Create an interceptor that runs calls at their priority (0 leaves batch calls unlimited).
+
+    Register it after the client certificate interceptor.
+    """
+    slots: Optional[asyncio.Semaphore] = asyncio.Semaphore(max_concurrent_batch) if max_concurrent_batch else None
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        requested = call.context.headers.get(PRIORITY_HEADER, "").strip().lower()
+        if requested and requested not in PRIORITIES:
+            return Error(-32602, f"{PRIORITY_HEADER} must be one of: {', '.join(PRIORITIES)}")
+        certificate = call.context.attributes.get("client_certificate") or {}
+        priority = lowest_priority(requested, certificate.get("priority", ""))
+        call.context.attributes["priority"] = priority
+        metrics.inc("rpc_calls_by_priority_total", labels={"priority": priority})
+
+        token = set_priority(priority)
+        try:
+            if priority == BATCH and slots:
+                async with slots:
+                    return await call_next(call)
+            return await call_next(call)
+        finally:
+            reset_priority(token)
+
+    return interceptor
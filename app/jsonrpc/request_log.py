@@ -0,0 +1,70 @@
+"""
+Request logging with sampling and a slow request log.
+
+Logs a random sample of JSON-RPC calls plus every call slower than a
+threshold, as one JSON line each on the ``flexdb.requests`` logger. Each
+line has the tenant, method, outcome, total latency and the time spent in
+each repository operation with its row count, so latency can be broken
+down after the fact ("what was slow at 14:03?").
+"""
+
+import json
+import logging
+import random
+import time
+from datetime import datetime, timezone
+
+from jsonrpcserver import Result
+
+from app.authz.impersonation import call_tenant_id
+from app.jsonrpc.interceptors import CallNext, Interceptor, RpcCall, result_error_code
+from app.repository.timings import start_collecting, stop_collecting
+
+request_logger = logging.getLogger("flexdb.requests")
+
+
+def request_log_interceptor(sample_rate: float, slow_threshold_ms: float) -> Interceptor:
+    """
+    Create an interceptor that logs sampled and slow calls.
+
+    Args:
+        sample_rate: Fraction of calls logged regardless of latency (0 disables sampling)
+        slow_threshold_ms: Calls at least this slow are always logged, as warnings (0 disables)
+
+    Register it first so its latency covers the other interceptors.
+    """
+    if not 0 <= sample_rate <= 1:
+        raise ValueError("sample_rate must be between 0 and 1")
+    if slow_threshold_ms < 0:
+        raise ValueError("slow_threshold_ms must be >= 0")
+
+    async def interceptor(call: RpcCall, call_next: CallNext) -> Result:
+        started = time.monotonic()
+        token = start_collecting()
+        result = None
+        try:
+            result = await call_next(call)
+            return result
+        finally:
+            timings = stop_collecting(token)
+            duration_ms = (time.monotonic() - started) * 1000
+            slow = slow_threshold_ms > 0 and duration_ms >= slow_threshold_ms
+            if slow or (sample_rate > 0 and random.random() < sample_rate):
+                entry = {
+                    "time": datetime.now(timezone.utc).isoformat(),
+                    "request_id": call.context.request_id,
+                    "tenant_id": call_tenant_id(call),
+                    "subject_id": call.context.subject_id,
+                    "principal": (call.context.attributes.get("principal") or {}).get("user_name", ""),
+                    "method": call.method,
+                    "error_code": result_error_code(result) if result is not None else -32603,
+                    "duration_ms": round(duration_ms, 2),
+                    "repository_ms": round(timings.total_seconds * 1000, 2),
+                    "rows": timings.total_rows,
+                    "repository": timings.to_dict(),
+                    "reason": "slow" if slow else "sampled",
+                }
+                level = logging.WARNING if slow else logging.INFO
+                request_logger.log(level, json.dumps(entry))
+
+    return interceptor
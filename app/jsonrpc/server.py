@@ -4,30 +4,131 @@ JSON-RPC server implementation using FastAPI.
 
 import json
 import logging
+from typing import Awaitable, Callable, Optional
+
 from fastapi import APIRouter, Request, Response, status
 from jsonrpcserver import async_dispatch
 
+from app.jsonrpc.context import RequestContext, set_request_context, reset_request_context
+from app.jsonrpc.interceptors import dispatch_methods
+from app.jsonrpc.v2 import dispatch_v2, v2_spec
+from app.repository.errors import PermissionDeniedError
+
 logger = logging.getLogger(__name__)
 
 router = APIRouter()
 
+# Largest JSON-RPC request body read and response body sent, in bytes (0 = unlimited; set by main.py)
+_max_receive_bytes = 0
+_max_send_bytes = 0
+# Checks each request (its context, path and body) before dispatch, e.g. signed requests (set by main.py)
+RequestVerifier = Callable[[RequestContext, str, bytes], Awaitable[None]]
+_request_verifier: Optional[RequestVerifier] = None
+
+
+class MessageTooLargeError(Exception):
+    """A JSON-RPC request or response exceeds the configured size limit."""
+
+
+def set_message_limits(max_receive_bytes: int, max_send_bytes: int) -> None:
+    """Set the JSON-RPC request and response size limits (0 = unlimited)."""
+    global _max_receive_bytes, _max_send_bytes
+    _max_receive_bytes = max_receive_bytes
+    _max_send_bytes = max_send_bytes
+
+
+def set_request_verifier(verifier: Optional[RequestVerifier]) -> None:
+    """Set the check run on every JSON-RPC request before dispatch (it raises PermissionDeniedError to reject)."""
+    global _request_verifier
+    _request_verifier = verifier
+
+
+async def verify_request(ctx: RequestContext, path: str, body: bytes) -> None:
+    """
+    Run the request verifier, if one is set.
+
+    Raises:
+        PermissionDeniedError: If the verifier rejects the request
+    """
+    if _request_verifier:
+        await _request_verifier(ctx, path, body)
+
+
+async def read_message(request: Request) -> bytes:
+    """
+    Read a JSON-RPC request body, stopping as soon as it exceeds the receive limit.
+
+    Raises:
+        MessageTooLargeError: If the body is larger than the receive limit
+    """
+    if not _max_receive_bytes:
+        return await request.body()
+    declared = request.headers.get("content-length", "")
+    if declared.isdigit() and int(declared) > _max_receive_bytes:
+        raise MessageTooLargeError(f"request of {declared} bytes exceeds the {_max_receive_bytes} byte limit")
+    body = bytearray()
+    async for chunk in request.stream():
+        body.extend(chunk)
+        if len(body) > _max_receive_bytes:
+            raise MessageTooLargeError(f"request exceeds the {_max_receive_bytes} byte limit")
+    return bytes(body)
+
+
+def _error_response(code: int, message: str, status_code: int, id: Optional[object] = None) -> Response:
+    return Response(
+        content=json.dumps({"jsonrpc": "2.0", "error": {"code": code, "message": message}, "id": id}),
+        media_type="application/json",
+        status_code=status_code,
+    )
+
+
+async def _dispatch_v1(body: str) -> str:
+    return await async_dispatch(body, methods=dispatch_methods())
+
 
 @router.post("/jsonrpc")
 async def handle_jsonrpc(request: Request) -> Response:
-    """Handle JSON-RPC requests."""
+    """Handle JSON-RPC requests (API v1)."""
+    return await _handle(request, _dispatch_v1)
+
+
+@router.post("/v2/jsonrpc")
+async def handle_jsonrpc_v2(request: Request) -> Response:
+    """Handle JSON-RPC requests (API v2, translated to the v1 methods)."""
+    return await _handle(request, dispatch_v2)
+
+
+async def _handle(request: Request, dispatch: Callable[[str], Awaitable[str]]) -> Response:
+    ctx = RequestContext.from_headers(
+        dict(request.headers),
+        request.client.host if request.client else "",
+    )
+    token = set_request_context(ctx)
     try:
-        body = await request.body()
+        body = await read_message(request)
+        await verify_request(ctx, request.url.path, body)
         body_str = body.decode('utf-8')
-        response = await async_dispatch(body_str)
+        response = await dispatch(body_str)
         
-        if response is None:
+        if not response:
             # Notification (no response needed)
             return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+        if _max_send_bytes and len(response.encode()) > _max_send_bytes:
+            return _error_response(
+                -32603,
+                f"response exceeds the {_max_send_bytes} byte limit; request fewer items or a read mask",
+                status.HTTP_500_INTERNAL_SERVER_ERROR,
+            )
         
         return Response(
             content=response,
             media_type="application/json",
         )
+    except MessageTooLargeError as e:
+        return _error_response(-32600, str(e), status.HTTP_413_REQUEST_ENTITY_TOO_LARGE)
+    except PermissionDeniedError as e:
+        return _error_response(-32003, str(e), status.HTTP_401_UNAUTHORIZED)
     except json.JSONDecodeError:
         error_response = {
             "jsonrpc": "2.0",
@@ -51,6 +152,8 @@ async def handle_jsonrpc(request: Request) -> Response:
             media_type="application/json",
             status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
         )
+    finally:
+        reset_request_context(token)
 
 
 @router.get("/openrpc.json")
@@ -83,3 +186,10 @@ async def get_openrpc_spec() -> Response:
             media_type="application/json",
             status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
         )
+
+
+@router.get("/v2/openrpc.json")
+async def get_openrpc_spec_v2() -> Response:
+    """Get the OpenRPC specification of JSON-RPC API v2."""
+    from app.jsonrpc.openrpc import generate_openrpc_spec
+    return Response(content=json.dumps(v2_spec(generate_openrpc_spec()), indent=2), media_type="application/json")
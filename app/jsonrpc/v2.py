@@ -0,0 +1,195 @@
+"""
+JSON-RPC API v2.
+
+The API is versioned by endpoint. v1 (/jsonrpc) is frozen: its methods,
+parameters and results only change in backward compatible ways. Breaking
+changes go into v2 (/v2/jsonrpc), which is served by the same methods
+through translation shims, so both versions are always served together and
+no method is implemented twice.
+
+v2 differs from v1 in:
+
+- Documents: node and relationship data are JSON objects in results (v1
+  returns a JSON-encoded ``data`` string next to the ``data_object``), and
+  ``data`` and ``base_data`` parameters must be objects.
+- Field masks: ``field_mask`` replaces the ``fields`` read mask. It is a
+  list of paths (or one comma-separated string, as in protobuf's JSON
+  FieldMask), and paths can select inside data, e.g. ``data.address.city``.
+
+Each request of a call (or batch) is translated to v1, dispatched, and its
+response translated back. Requests that are invalid in v2 fail with -32602
+without being dispatched.
+"""
+
+import copy
+import inspect
+import json
+from typing import Any, Dict, List, Optional, Tuple
+
+from jsonrpcserver import async_dispatch
+
+from app.jsonrpc.interceptors import dispatch_methods
+from app.jsonrpc.json_limits import DOCUMENT_KEYS
+
+API_VERSION = "2.0.0"
+
+# Parameters that hold a document, which v2 only accepts as a JSON object
+_OBJECT_PARAMS = ("data", "base_data")
+
+
+class V2ParamsError(ValueError):
+    """A request's parameters are not valid v2."""
+
+
+def parse_field_mask(mask: Any) -> List[List[str]]:
+    """Split a field mask into paths of keys."""
+    if isinstance(mask, str):
+        mask = [p for p in mask.split(",")]
+    if not isinstance(mask, list) or not all(isinstance(p, str) for p in mask):
+        raise V2ParamsError("field_mask must be a list of paths or a comma-separated string")
+    paths = [p.strip().split(".") for p in mask if p.strip()]
+    if any("" in path for path in paths):
+        raise V2ParamsError("field_mask paths must not have empty segments")
+    return paths
+
+
+def _accepts_fields(method: Any) -> bool:
+    func = dispatch_methods().get(method) if isinstance(method, str) else None
+    return func is not None and "fields" in inspect.signature(func).parameters
+
+
+def translate_request(request: Any) -> Tuple[Any, Optional[List[List[str]]]]:
+    """
+    Translate a v2 request object to v1; returns it and the data paths its
+    field mask selects (None when data is not masked).
+
+    Raises:
+        V2ParamsError: If the parameters are not valid v2
+    """
+    if not isinstance(request, dict) or not isinstance(request.get("params"), dict):
+        # Invalid requests are reported by the v1 dispatcher
+        return request, None
+    params = dict(request["params"])
+    if "fields" in params:
+        raise V2ParamsError("fields is replaced by field_mask in v2")
+    for key in _OBJECT_PARAMS:
+        if isinstance(params.get(key), str):
+            raise V2ParamsError(f"{key} must be a JSON object in v2")
+
+    data_paths = None
+    mask = params.pop("field_mask", None)
+    if mask:
+        paths = parse_field_mask(mask)
+        if not _accepts_fields(request.get("method")):
+            raise V2ParamsError(f"field_mask is not supported by {request.get('method')}")
+        fields = []
+        for path in paths:
+            # v1 returns the data object as data_object
+            name = "data_object" if path[0] == "data" else path[0]
+            if name not in fields:
+                fields.append(name)
+        params["fields"] = fields
+        selected = [path[1:] for path in paths if path[0] == "data"]
+        if selected and all(selected):
+            data_paths = selected
+    return {**request, "params": params}, data_paths
+
+
+def _prune(document: Any, paths: Optional[List[List[str]]]) -> Any:
+    """Keep only the given paths of a document."""
+    if paths is None or not isinstance(document, dict):
+        return document
+    pruned: Dict[str, Any] = {}
+    for path in paths:
+        value = document
+        for key in path:
+            if not isinstance(value, dict) or key not in value:
+                break
+            value = value[key]
+        else:
+            target = pruned
+            for key in path[:-1]:
+                target = target.setdefault(key, {})
+            target[path[-1]] = copy.deepcopy(value)
+    return pruned
+
+
+def translate_result(value: Any, data_paths: Optional[List[List[str]]] = None) -> Any:
+    """Translate a v1 result to v2: entities carry their data object as data."""
+    if isinstance(value, list):
+        return [translate_result(v, data_paths) for v in value]
+    if not isinstance(value, dict):
+        return value
+    entity = "data_object" in value
+    translated: Dict[str, Any] = {}
+    for key, item in value.items():
+        if entity and key in ("data", "data_object"):
+            if "data" not in translated:
+                translated["data"] = _prune(value["data_object"], data_paths)
+        elif key in DOCUMENT_KEYS:
+            translated[key] = item
+        else:
+            translated[key] = translate_result(item, data_paths)
+    return translated
+
+
+def _error(request_id: Any, message: str) -> Dict[str, Any]:
+    return {"jsonrpc": "2.0", "error": {"code": -32602, "message": message}, "id": request_id}
+
+
+async def dispatch_v2(body: str) -> str:
+    """
+    Dispatch a v2 JSON-RPC call (single request or batch); returns the
+    response, or an empty string when every request was a notification.
+
+    Raises:
+        json.JSONDecodeError: If the body is not JSON
+    """
+    payload = json.loads(body)
+    batch = isinstance(payload, list)
+    if batch and not payload:
+        return await async_dispatch(body, methods=dispatch_methods())
+
+    forward: List[Any] = []
+    responses: List[Dict[str, Any]] = []
+    masks: Dict[str, Optional[List[List[str]]]] = {}
+    for request in payload if batch else [payload]:
+        try:
+            translated, data_paths = translate_request(request)
+        except V2ParamsError as e:
+            if "id" in request:
+                responses.append(_error(request["id"], str(e)))
+            continue
+        if isinstance(request, dict) and "id" in request:
+            masks[json.dumps(request["id"])] = data_paths
+        forward.append(translated)
+
+    if forward:
+        out = await async_dispatch(json.dumps(forward if batch else forward[0]), methods=dispatch_methods())
+        dispatched = json.loads(out) if out else []
+        for response in dispatched if isinstance(dispatched, list) else [dispatched]:
+            if "result" in response:
+                data_paths = masks.get(json.dumps(response.get("id")))
+                response = {**response, "result": translate_result(response["result"], data_paths)}
+            responses.append(response)
+
+    if not responses:
+        return ""
+    return json.dumps(responses if batch else responses[0])
+
+
+def v2_spec(spec: Dict[str, Any]) -> Dict[str, Any]:
+    """Turn the (v1) OpenRPC document into the v2 one."""
+    spec = copy.deepcopy(spec)
+    spec["info"]["version"] = API_VERSION
+    for server in spec.get("servers", []):
+        server["url"] = server["url"].replace("/jsonrpc", "/v2/jsonrpc")
+    for method in spec["methods"]:
+        for param in method["params"]:
+            if param["name"] == "fields":
+                param["name"] = "field_mask"
+                param["schema"] = {"oneOf": [{"type": "array", "items": {"type": "string"}}, {"type": "string"}]}
+                param["description"] = "Paths to return, e.g. [\"id\", \"data.address.city\"] (default: all)"
+            elif param["name"] in _OBJECT_PARAMS:
+                param["schema"] = {"type": "object"}
+    return spec
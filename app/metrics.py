@@ -0,0 +1,162 @@
+"""
+In-process metrics registry.
+
+Collects counters and summary observations that are exposed in Prometheus
+text format on the /metrics endpoint. Sinks added with add_sink() also
+receive every increment and observation as it happens, to push them
+elsewhere (see app/metrics_statsd.py).
+"""
+
+import threading
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Protocol, Tuple
+
+LabelKey = Tuple[Tuple[str, str], ...]
+
+
+def _label_key(labels: Optional[Dict[str, str]]) -> LabelKey:
+    """Normalize a label dictionary into a hashable, ordered key."""
+    if not labels:
+        return ()
+    return tuple(sorted((k, str(v)) for k, v in labels.items()))
+
+
+def _format_labels(key: LabelKey) -> str:
+    """Render a label key in Prometheus exposition format."""
+    if not key:
+        return ""
+    parts = ",".join(f'{k}="{v}"' for k, v in key)
+    return "{" + parts + "}"
+
+
+@dataclass
+class Summary:
+    """Running summary of observed values."""
+    count: int = 0
+    total: float = 0.0
+    min: float = 0.0
+    max: float = 0.0
+
+    def observe(self, value: float) -> None:
+        """Record a single observation."""
+        if self.count == 0:
+            self.min = value
+            self.max = value
+        else:
+            self.min = min(self.min, value)
+            self.max = max(self.max, value)
+        self.count += 1
+        self.total += value
+
+    @property
+    def mean(self) -> float:
+        """Average of all observations."""
+        return self.total / self.count if self.count else 0.0
+
+
+class MetricsSink(Protocol):
+    """Receives counter increments and summary observations; must not block or raise."""
+
+    def count(self, name: str, value: float, labels: LabelKey) -> None: ...
+
+    def observe(self, name: str, value: float, labels: LabelKey) -> None: ...
+
+
+class MetricsRegistry:
+    """Thread-safe registry of counters and summaries."""
+
+    def __init__(self):
+        self._lock = threading.Lock()
+        self._counters: Dict[str, Dict[LabelKey, float]] = {}
+        self._summaries: Dict[str, Dict[LabelKey, Summary]] = {}
+        self._sinks: List[MetricsSink] = []
+
+    def add_sink(self, sink: MetricsSink) -> None:
+        """Forward every later increment and observation to a sink."""
+        with self._lock:
+            self._sinks = self._sinks + [sink]
+
+    def remove_sink(self, sink: MetricsSink) -> None:
+        """Stop forwarding to a sink."""
+        with self._lock:
+            self._sinks = [s for s in self._sinks if s is not sink]
+
+    def inc(self, name: str, value: float = 1.0, labels: Optional[Dict[str, str]] = None) -> None:
+        """Increment a counter."""
+        key = _label_key(labels)
+        with self._lock:
+            series = self._counters.setdefault(name, {})
+            series[key] = series.get(key, 0.0) + value
+            sinks = self._sinks
+        for sink in sinks:
+            sink.count(name, value, key)
+
+    def observe(self, name: str, value: float, labels: Optional[Dict[str, str]] = None) -> None:
+        """Record an observation in a summary."""
+        key = _label_key(labels)
+        with self._lock:
+            series = self._summaries.setdefault(name, {})
+            series.setdefault(key, Summary()).observe(value)
+            sinks = self._sinks
+        for sink in sinks:
+            sink.observe(name, value, key)
+
+    def counter_value(self, name: str, labels: Optional[Dict[str, str]] = None) -> float:
+        """Return the current value of a counter."""
+        with self._lock:
+            return self._counters.get(name, {}).get(_label_key(labels), 0.0)
+
+    def summary(self, name: str, labels: Optional[Dict[str, str]] = None) -> Summary:
+        """Return a copy of a summary."""
+        with self._lock:
+            s = self._summaries.get(name, {}).get(_label_key(labels))
+            return Summary(s.count, s.total, s.min, s.max) if s else Summary()
+
+    def snapshot(self) -> dict:
+        """Return all metrics as a JSON-serializable dictionary."""
+        with self._lock:
+            counters = {
+                name: [{"labels": dict(k), "value": v} for k, v in series.items()]
+                for name, series in self._counters.items()
+            }
+            summaries = {
+                name: [
+                    {
+                        "labels": dict(k),
+                        "count": s.count,
+                        "sum": s.total,
+                        "min": s.min,
+                        "max": s.max,
+                        "mean": s.mean,
+                    }
+                    for k, s in series.items()
+                ]
+                for name, series in self._summaries.items()
+            }
+        return {"counters": counters, "summaries": summaries}
+
+    def render_text(self) -> str:
+        """Render all metrics in Prometheus text exposition format."""
+        lines = []
+        with self._lock:
+            for name in sorted(self._counters):
+                lines.append(f"# TYPE {name} counter")
+                for key, value in self._counters[name].items():
+                    lines.append(f"{name}{_format_labels(key)} {value}")
+            for name in sorted(self._summaries):
+                lines.append(f"# TYPE {name} summary")
+                for key, s in self._summaries[name].items():
+                    labels = _format_labels(key)
+                    lines.append(f"{name}_count{labels} {s.count}")
+                    lines.append(f"{name}_sum{labels} {s.total}")
+        return "\n".join(lines) + "\n"
+
+    def reset(self) -> None:
+        """Clear all metrics (used by tests)."""
+        with self._lock:
+            self._counters.clear()
+            self._summaries.clear()
+
+
+# Global registry shared by the application
+metrics = MetricsRegistry()
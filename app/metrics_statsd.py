@@ -0,0 +1,137 @@
+"""
+StatsD and DogStatsD metrics sink.
+
+Hosts that cannot be scraped can have metrics pushed to a StatsD agent
+(such as the Datadog agent) over UDP instead: set METRICS_STATSD_ADDRESS
+and every counter increment and summary observation of the registry (see
+app/metrics.py) is also sent there. /metrics keeps serving Prometheus.
+
+Counters are sent as StatsD counts and summary observations as histograms
+(DogStatsD) or timers (plain StatsD). DogStatsD sends labels as tags; plain
+StatsD has no tags, so label values are appended to the metric name, e.g.
+``flexdb.rpc_calls_total.method.get_node``.
+
+Lines are buffered and sent in packets of up to MAX_PACKET_BYTES when the
+buffer fills and on every flush(). Sending never blocks: a packet the
+socket cannot take is dropped, like any UDP packet.
+"""
+
+import logging
+import re
+import socket
+import threading
+from typing import List, Optional, Tuple
+
+from app.metrics import LabelKey
+
+logger = logging.getLogger(__name__)
+
+STATSD_FORMATS = ("statsd", "dogstatsd")
+DEFAULT_PORT = 8125
+# Fits an unfragmented UDP packet on a 1500 byte MTU
+MAX_PACKET_BYTES = 1432
+
+_UNSAFE_NAME = re.compile(r"[^A-Za-z0-9_.-]")
+_UNSAFE_TAG = re.compile(r"[^A-Za-z0-9_./:-]")
+
+
+def parse_address(address: str) -> Tuple[str, int]:
+    """
+    Split a ``host[:port]`` address (``[v6]:port`` for IPv6 literals).
+
+    Raises:
+        ValueError: If the port is not a number
+    """
+    host, port = address.strip(), str(DEFAULT_PORT)
+    if host.startswith("["):
+        host, _, rest = host[1:].partition("]")
+        port = rest.lstrip(":") or port
+    elif host.count(":") == 1:
+        host, port = host.split(":")
+    if not host or not port.isdigit():
+        raise ValueError(f"invalid StatsD address {address!r} (expected host:port)")
+    return host, int(port)
+
+
+def _format_value(value: float) -> str:
+    return str(int(value)) if float(value).is_integer() else repr(float(value))
+
+
+class StatsdSink:
+    """Sends metrics to a StatsD or DogStatsD agent over UDP."""
+
+    def __init__(
+        self,
+        address: str,
+        format: str = "dogstatsd",
+        prefix: str = "flexdb",
+        tags: Optional[List[str]] = None,
+    ):
+        if format not in STATSD_FORMATS:
+            raise ValueError(f"StatsD format must be one of: {', '.join(STATSD_FORMATS)}")
+        host, port = parse_address(address)
+        family, _, _, _, self._target = socket.getaddrinfo(host, port, type=socket.SOCK_DGRAM)[0]
+        self._sock = socket.socket(family, socket.SOCK_DGRAM)
+        self._sock.setblocking(False)
+        self.format = format
+        self.prefix = prefix.rstrip(".") + "." if prefix else ""
+        # Constant tags added to every metric (DogStatsD only), e.g. ["env:prod"]
+        self.tags = [_UNSAFE_TAG.sub("_", t) for t in tags or [] if t]
+        self._lock = threading.Lock()
+        self._buffer: List[str] = []
+        self._buffered_bytes = 0
+        # Packets the socket refused; not a registry counter, which would feed back into the sink
+        self.dropped_packets = 0
+
+    def count(self, name: str, value: float, labels: LabelKey) -> None:
+        """Send a counter increment."""
+        self._add(self._line(name, value, "c", labels))
+
+    def observe(self, name: str, value: float, labels: LabelKey) -> None:
+        """Send a summary observation."""
+        self._add(self._line(name, value, "h" if self.format == "dogstatsd" else "ms", labels))
+
+    async def flush(self) -> None:
+        """Send the buffered lines."""
+        self._send(self._take())
+
+    def close(self) -> None:
+        """Send the buffered lines and close the socket."""
+        self._send(self._take())
+        self._sock.close()
+
+    def _line(self, name: str, value: float, kind: str, labels: LabelKey) -> str:
+        metric = self.prefix + _UNSAFE_NAME.sub("_", name)
+        if self.format == "statsd":
+            for key, label_value in labels:
+                metric += f".{_UNSAFE_NAME.sub('_', key)}.{_UNSAFE_NAME.sub('_', label_value).replace('.', '_')}"
+            return f"{metric}:{_format_value(value)}|{kind}"
+        tags = self.tags + [f"{_UNSAFE_TAG.sub('_', k)}:{_UNSAFE_TAG.sub('_', v)}" for k, v in labels]
+        line = f"{metric}:{_format_value(value)}|{kind}"
+        return line + "|#" + ",".join(tags) if tags else line
+
+    def _add(self, line: str) -> None:
+        full: List[str] = []
+        with self._lock:
+            if self._buffer and self._buffered_bytes + 1 + len(line) > MAX_PACKET_BYTES:
+                full = self._buffer
+                self._buffer, self._buffered_bytes = [], 0
+            self._buffer.append(line)
+            self._buffered_bytes += len(line) + (1 if self._buffered_bytes else 0)
+        self._send(full)
+
+    def _take(self) -> List[str]:
+        with self._lock:
+            lines = self._buffer
+            self._buffer, self._buffered_bytes = [], 0
+        return lines
+
+    def _send(self, lines: List[str]) -> None:
+        if not lines:
+            return
+        try:
+            self._sock.sendto("\n".join(lines).encode(), self._target)
+        except OSError as e:
+            self.dropped_packets += 1
+            if self.dropped_packets == 1 or self.dropped_packets % 1000 == 0:
+                logger.warning(f"Dropped {self.dropped_packets} StatsD packets so far: {e}")
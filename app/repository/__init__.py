@@ -9,6 +9,35 @@ from app.repository.models import (
     NodeType,
     Node,
     Relationship,
+    RelationshipType,
+    FacetValue,
+    WriteHook,
+    SavedQuery,
+    NodeView,
+    UniqueConstraint,
+    AuthzPolicy,
+    TenantTemplate,
+    ExportSchedule,
+    ExportRun,
+    DestinationCredential,
+    TenantKey,
+    DirectoryUser,
+    DirectoryGroup,
+    Tombstone,
+    BillingEvent,
+    NodeVersion,
+    DataMigration,
+    TenantFilter,
+    Attachment,
+    ImpersonationToken,
+    ApiKey,
+    DualWriteTarget,
+    AuditEvent,
+    Operation,
+    NodeFilter,
+    DataRange,
+    MetadataUpdate,
+    RelationshipFilter,
     ListOptions,
     ListResult,
 )
@@ -17,7 +46,53 @@ from app.repository.user_repo import UserRepository
 from app.repository.nodetype_repo import NodeTypeRepository
 from app.repository.node_repo import NodeRepository
 from app.repository.relationship_repo import RelationshipRepository
-from app.repository.errors import NotFoundError
+from app.repository.relationship_type_repo import RelationshipTypeRepository
+from app.repository.write_hook_repo import WriteHookRepository
+from app.repository.authz_policy_repo import AuthzPolicyRepository
+from app.repository.attachment_repo import AttachmentRepository
+from app.repository.graph_stats_repo import GraphStatsRepository
+from app.repository.db_stats_repo import DatabaseStatsRepository
+from app.repository.query_plan_repo import QueryPlanRepository
+from app.repository.table_maintenance_repo import TableMaintenanceRepository
+from app.repository.impersonation_repo import ImpersonationRepository
+from app.repository.api_key_repo import ApiKeyRepository
+from app.repository.request_nonce_repo import RequestNonceRepository
+from app.repository.audit_repo import AuditRepository
+from app.repository.operation_repo import OperationRepository
+from app.repository.data_migration_repo import DataMigrationRepository
+from app.repository.template_repo import TenantTemplateRepository
+from app.repository.export_schedule_repo import ExportScheduleRepository
+from app.repository.destination_credential_repo import DestinationCredentialRepository
+from app.repository.directory_repo import DirectoryRepository
+from app.repository.tombstone_repo import TombstoneRepository
+from app.repository.node_alias_repo import NodeAliasRepository
+from app.repository.node_protection_repo import NodeProtectionRepository
+from app.repository.unique_constraint_repo import UniqueConstraintRepository
+from app.repository.saved_query_repo import SavedQueryRepository
+from app.repository.node_view_repo import NodeViewRepository
+from app.repository.tenant_key_repo import TenantKeyRepository
+from app.repository.encrypted_data_repo import EncryptedDataRepository
+from app.repository.search_cursor_repo import SearchCursorRepository
+from app.repository.billing_repo import BillingEventRepository
+from app.repository.dual_write_repo import DualWriteTargetRepository
+from app.repository.shard_map_repo import ShardMapRepository
+from app.repository.memory_repo import (
+    MemoryStore,
+    MemoryNodeTypeRepository,
+    MemoryNodeRepository,
+    MemoryRelationshipRepository,
+    MemoryRelationshipTypeRepository,
+)
+from app.repository.errors import (
+    AlreadyExistsError,
+    DataResidencyError,
+    DuplicateRelationshipError,
+    FieldViolationError,
+    NotFoundError,
+    PreconditionFailedError,
+    PermissionDeniedError,
+    SlugTakenError,
+)
 
 __all__ = [
     "Tenant",
@@ -26,6 +101,35 @@ __all__ = [
     "NodeType",
     "Node",
     "Relationship",
+    "RelationshipType",
+    "FacetValue",
+    "WriteHook",
+    "SavedQuery",
+    "NodeView",
+    "UniqueConstraint",
+    "AuthzPolicy",
+    "TenantTemplate",
+    "ExportSchedule",
+    "ExportRun",
+    "DestinationCredential",
+    "TenantKey",
+    "DirectoryUser",
+    "DirectoryGroup",
+    "Tombstone",
+    "BillingEvent",
+    "NodeVersion",
+    "DataMigration",
+    "TenantFilter",
+    "Attachment",
+    "ImpersonationToken",
+    "ApiKey",
+    "DualWriteTarget",
+    "AuditEvent",
+    "Operation",
+    "NodeFilter",
+    "DataRange",
+    "MetadataUpdate",
+    "RelationshipFilter",
     "ListOptions",
     "ListResult",
     "TenantRepository",
@@ -33,5 +137,47 @@ __all__ = [
     "NodeTypeRepository",
     "NodeRepository",
     "RelationshipRepository",
+    "RelationshipTypeRepository",
+    "WriteHookRepository",
+    "AuthzPolicyRepository",
+    "AttachmentRepository",
+    "GraphStatsRepository",
+    "DatabaseStatsRepository",
+    "QueryPlanRepository",
+    "TableMaintenanceRepository",
+    "ImpersonationRepository",
+    "ApiKeyRepository",
+    "RequestNonceRepository",
+    "AuditRepository",
+    "OperationRepository",
+    "DataMigrationRepository",
+    "TenantTemplateRepository",
+    "ExportScheduleRepository",
+    "DestinationCredentialRepository",
+    "DirectoryRepository",
+    "TombstoneRepository",
+    "NodeAliasRepository",
+    "NodeProtectionRepository",
+    "UniqueConstraintRepository",
+    "SavedQueryRepository",
+    "NodeViewRepository",
+    "TenantKeyRepository",
+    "EncryptedDataRepository",
+    "SearchCursorRepository",
+    "BillingEventRepository",
+    "DualWriteTargetRepository",
+    "ShardMapRepository",
+    "MemoryStore",
+    "MemoryNodeTypeRepository",
+    "MemoryNodeRepository",
+    "MemoryRelationshipRepository",
+    "MemoryRelationshipTypeRepository",
+    "AlreadyExistsError",
+    "FieldViolationError",
     "NotFoundError",
+    "PreconditionFailedError",
+    "PermissionDeniedError",
+    "SlugTakenError",
+    "DataResidencyError",
+    "DuplicateRelationshipError",
 ]
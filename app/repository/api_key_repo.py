@@ -0,0 +1,133 @@
+"""
+API key repository implementation.
+"""
+
+import uuid
+from datetime import datetime
+from typing import List, Optional, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import ApiKey, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, name, subject_id, scope, tenant_ids, created_by, expires_at, revoked_at, created_at"
+
+
+class ApiKeyRepository:
+    """PostgreSQL API key repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, key: ApiKey, token_hash: str) -> ApiKey:
+        """Create a new API key stored under the hash of its bearer key."""
+        key.id = str(uuid.uuid4())
+        key.created_at = datetime.now()
+
+        query = f"""
+            INSERT INTO api_keys (id, token_hash, name, subject_id, scope, tenant_ids, created_by, expires_at, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                key.id, token_hash, key.name, key.subject_id, key.scope, key.tenant_ids,
+                key.created_by, key.expires_at, key.created_at
+            )
+
+        return self._row_to_key(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> ApiKey:
+        """Retrieve an API key by ID."""
+        query = f"SELECT {_COLUMNS} FROM api_keys WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"api key not found: {id}")
+
+        return self._row_to_key(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_token_hash(self, token_hash: str) -> Optional[ApiKey]:
+        """Retrieve the API key for a bearer key hash, or None if there is none."""
+        query = f"SELECT {_COLUMNS} FROM api_keys WHERE token_hash = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, token_hash)
+
+        return self._row_to_key(row) if row else None
+
+    @with_retry(idempotent=True)
+    async def list(self, subject_id: str, opts: ListOptions) -> Tuple[List[ApiKey], ListResult]:
+        """Retrieve API keys, newest first, with pagination (of one subject unless subject_id is empty)."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval(
+                "SELECT COUNT(*) FROM api_keys WHERE $1 = '' OR subject_id = $1", subject_id
+            )
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM api_keys
+                WHERE $1 = '' OR subject_id = $1
+                ORDER BY created_at DESC, id
+                LIMIT $2 OFFSET $3
+            """
+            rows = await conn.fetch(query, subject_id, page_size, offset)
+
+        keys = [self._row_to_key(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(keys)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return keys, result
+
+    @with_retry()
+    async def revoke(self, id: str) -> ApiKey:
+        """Revoke an API key (a no-op if it is already revoked)."""
+        query = f"""
+            UPDATE api_keys
+            SET revoked_at = COALESCE(revoked_at, NOW())
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"api key not found: {id}")
+
+        return self._row_to_key(row)
+
+    def _row_to_key(self, row: asyncpg.Record) -> ApiKey:
+        """Convert a database row to an ApiKey object."""
+        return ApiKey(
+            id=str(row["id"]),
+            name=row["name"],
+            subject_id=row["subject_id"],
+            scope=row["scope"],
+            tenant_ids=list(row["tenant_ids"] or []),
+            created_by=row["created_by"],
+            expires_at=row["expires_at"],
+            revoked_at=row["revoked_at"],
+            created_at=row["created_at"],
+        )
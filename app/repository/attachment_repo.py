@@ -0,0 +1,154 @@
+"""
+Attachment repository implementation.
+"""
+
+import uuid
+from datetime import datetime
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import Attachment, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = """
+    id, node_id, filename, content_type, size_bytes, sha256, storage_key,
+    status, created_at, updated_at
+"""
+
+
+class AttachmentRepository:
+    """PostgreSQL attachment metadata repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, attachment: Attachment) -> Attachment:
+        """Create attachment metadata (the ID is assigned by the caller if set)."""
+        attachment.id = attachment.id or str(uuid.uuid4())
+        attachment.created_at = datetime.now()
+        attachment.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO attachments (
+                id, node_id, filename, content_type, size_bytes, sha256, storage_key,
+                status, created_at, updated_at
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                attachment.id, attachment.node_id, attachment.filename, attachment.content_type,
+                attachment.size_bytes, attachment.sha256 or None, attachment.storage_key,
+                attachment.status, attachment.created_at, attachment.updated_at
+            )
+
+        return self._row_to_attachment(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> Attachment:
+        """Retrieve attachment metadata by ID."""
+        query = f"SELECT {_COLUMNS} FROM attachments WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"attachment not found: {id}")
+
+        return self._row_to_attachment(row)
+
+    @with_retry()
+    async def mark_ready(self, id: str, size_bytes: int, sha256: str) -> Attachment:
+        """Record uploaded content and mark the attachment ready."""
+        query = f"""
+            UPDATE attachments
+            SET size_bytes = $2, sha256 = $3, status = 'ready', updated_at = $4
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, size_bytes, sha256, datetime.now())
+
+        if not row:
+            raise NotFoundError(f"attachment not found: {id}")
+
+        return self._row_to_attachment(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete attachment metadata by ID."""
+        query = "DELETE FROM attachments WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"attachment not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list_by_node(self, node_id: str, opts: ListOptions) -> Tuple[List[Attachment], ListResult]:
+        """Retrieve a node's ready attachments with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval(
+                "SELECT COUNT(*) FROM attachments WHERE node_id = $1 AND status = 'ready'",
+                node_id
+            )
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM attachments
+                WHERE node_id = $1 AND status = 'ready'
+                ORDER BY created_at, id
+                LIMIT $2 OFFSET $3
+            """
+            rows = await conn.fetch(query, node_id, page_size, offset)
+
+        attachments = [self._row_to_attachment(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(attachments)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return attachments, result
+
+    @with_retry(idempotent=True)
+    async def list_storage_keys(self, node_id: str) -> List[str]:
+        """Retrieve the storage keys of every attachment on a node (any status)."""
+        query = "SELECT storage_key FROM attachments WHERE node_id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_id)
+
+        return [row["storage_key"] for row in rows]
+
+    def _row_to_attachment(self, row: asyncpg.Record) -> Attachment:
+        """Convert a database row to an Attachment object."""
+        return Attachment(
+            id=str(row["id"]),
+            node_id=str(row["node_id"]),
+            filename=row["filename"],
+            content_type=row["content_type"],
+            size_bytes=row["size_bytes"],
+            sha256=row["sha256"] or "",
+            storage_key=row["storage_key"],
+            status=row["status"],
+            created_at=row["created_at"],
+            updated_at=row["updated_at"],
+        )
@@ -0,0 +1,26 @@
+"""
+Attribution of writes to the caller.
+
+The authorization layer sets the acting user for the duration of a call;
+repositories record it alongside the history they write (node versions).
+Outside a call the actor is empty.
+"""
+
+import contextvars
+
+_actor: contextvars.ContextVar[str] = contextvars.ContextVar("actor", default="")
+
+
+def current_actor() -> str:
+    """Return the user the current call acts as (empty if unknown)."""
+    return _actor.get()
+
+
+def set_actor(actor: str) -> contextvars.Token:
+    """Set the acting user; returns a token for reset_actor."""
+    return _actor.set(actor)
+
+
+def reset_actor(token: contextvars.Token) -> None:
+    """Restore the previous acting user."""
+    _actor.reset(token)
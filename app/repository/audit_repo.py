@@ -0,0 +1,106 @@
+"""
+Audit log repository implementation.
+"""
+
+import json
+from typing import Any, List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import AuditEvent, ListOptions, ListResult
+from app.repository.retry import with_retry
+
+_COLUMNS = (
+    "id, occurred_at, request_id, actor_id, impersonated_by, impersonation_id, "
+    "tenant_id, method, outcome, error_code, details::text"
+)
+
+
+class AuditRepository:
+    """PostgreSQL audit log repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def record(self, event: AuditEvent) -> AuditEvent:
+        """Append an event to the audit log."""
+        query = f"""
+            INSERT INTO audit_events (
+                request_id, actor_id, impersonated_by, impersonation_id, tenant_id, method, outcome, error_code, details
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::jsonb)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                event.request_id, event.actor_id, event.impersonated_by, event.impersonation_id or None,
+                event.tenant_id, event.method, event.outcome, event.error_code, json.dumps(event.details)
+            )
+
+        return self._row_to_event(row)
+
+    @with_retry(idempotent=True)
+    async def list(
+        self,
+        opts: ListOptions,
+        tenant_id: str = "",
+        impersonated_only: bool = False,
+    ) -> Tuple[List[AuditEvent], ListResult]:
+        """Retrieve audit events, newest first, with optional filtering."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        conditions: List[str] = []
+        args: List[Any] = []
+        if tenant_id:
+            args.append(tenant_id)
+            conditions.append(f"tenant_id = ${len(args)}")
+        if impersonated_only:
+            conditions.append("impersonated_by <> ''")
+        where_clause = " WHERE " + " AND ".join(conditions) if conditions else ""
+        limit_param = len(args) + 1
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval(f"SELECT COUNT(*) FROM audit_events{where_clause}", *args)
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM audit_events{where_clause}
+                ORDER BY occurred_at DESC, id DESC
+                LIMIT ${limit_param} OFFSET ${limit_param + 1}
+            """
+            rows = await conn.fetch(query, *args, page_size, offset)
+
+        events = [self._row_to_event(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(events)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return events, result
+
+    def _row_to_event(self, row: asyncpg.Record) -> AuditEvent:
+        """Convert a database row to an AuditEvent object."""
+        return AuditEvent(
+            id=row["id"],
+            occurred_at=row["occurred_at"],
+            request_id=row["request_id"],
+            actor_id=row["actor_id"],
+            impersonated_by=row["impersonated_by"],
+            impersonation_id=str(row["impersonation_id"]) if row["impersonation_id"] else "",
+            tenant_id=row["tenant_id"],
+            method=row["method"],
+            outcome=row["outcome"],
+            error_code=row["error_code"],
+            details=json.loads(row["details"]) if row["details"] else {},
+        )
@@ -0,0 +1,149 @@
+"""
+AuthzPolicy repository implementation.
+"""
+
+import uuid
+from datetime import datetime
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import AuthzPolicy, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, name, description, effect, methods, expression, enabled, created_at, updated_at"
+
+
+class AuthzPolicyRepository:
+    """PostgreSQL authorization policy repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, policy: AuthzPolicy) -> AuthzPolicy:
+        """Create a new policy."""
+        policy.id = str(uuid.uuid4())
+        policy.created_at = datetime.now()
+        policy.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO authz_policies (id, name, description, effect, methods, expression, enabled, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                policy.id, policy.name, policy.description, policy.effect, policy.methods,
+                policy.expression, policy.enabled, policy.created_at, policy.updated_at
+            )
+
+        return self._row_to_policy(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> AuthzPolicy:
+        """Retrieve a policy by ID."""
+        query = f"SELECT {_COLUMNS} FROM authz_policies WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"authz_policy not found: {id}")
+
+        return self._row_to_policy(row)
+
+    @with_retry()
+    async def update(self, policy: AuthzPolicy) -> AuthzPolicy:
+        """Update an existing policy."""
+        policy.updated_at = datetime.now()
+
+        query = f"""
+            UPDATE authz_policies
+            SET name = $2, description = $3, effect = $4, methods = $5,
+                expression = $6, enabled = $7, updated_at = $8
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                policy.id, policy.name, policy.description, policy.effect, policy.methods,
+                policy.expression, policy.enabled, policy.updated_at
+            )
+
+        if not row:
+            raise NotFoundError(f"authz_policy not found: {policy.id}")
+
+        return self._row_to_policy(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete a policy by ID."""
+        query = "DELETE FROM authz_policies WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"authz_policy not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list(self, opts: ListOptions) -> Tuple[List[AuthzPolicy], ListResult]:
+        """Retrieve policies with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM authz_policies")
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM authz_policies
+                ORDER BY name
+                LIMIT $1 OFFSET $2
+            """
+            rows = await conn.fetch(query, page_size, offset)
+
+        policies = [self._row_to_policy(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(policies)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return policies, result
+
+    @with_retry(idempotent=True)
+    async def list_enabled(self) -> List[AuthzPolicy]:
+        """Retrieve all enabled policies."""
+        query = f"SELECT {_COLUMNS} FROM authz_policies WHERE enabled ORDER BY name"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        return [self._row_to_policy(row) for row in rows]
+
+    def _row_to_policy(self, row: asyncpg.Record) -> AuthzPolicy:
+        """Convert a database row to an AuthzPolicy object."""
+        return AuthzPolicy(
+            id=str(row["id"]),
+            name=row["name"],
+            description=row["description"] or "",
+            effect=row["effect"],
+            methods=list(row["methods"] or []),
+            expression=row["expression"],
+            enabled=row["enabled"],
+            created_at=row["created_at"],
+            updated_at=row["updated_at"],
+        )
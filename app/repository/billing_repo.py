@@ -0,0 +1,133 @@
+"""
+Billing event repository implementation.
+"""
+
+from datetime import datetime
+from typing import Dict, List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import BillingEvent
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, tenant_id, event_type, dimension, quantity, period_start, period_end, recorded_at"
+
+# Held while inserting, so event IDs are assigned in commit order and
+# readers paging by ID never skip an event that commits late
+_INSERT_LOCK = "SELECT pg_advisory_xact_lock(hashtext('billing_events'))"
+
+
+class BillingEventRepository:
+    """PostgreSQL billing event repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def record(self, events: List[BillingEvent]) -> None:
+        """Append events."""
+        if not events:
+            return
+        query = """
+            INSERT INTO billing_events (tenant_id, event_type, dimension, quantity, period_start, period_end)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        """
+        rows = [
+            (e.tenant_id, e.event_type, e.dimension, e.quantity, e.period_start, e.period_end)
+            for e in events
+        ]
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                await conn.execute(_INSERT_LOCK)
+                await conn.executemany(query, rows)
+
+    @with_retry()
+    async def record_storage(self, sizes: Dict[str, int], period_start: datetime, period_end: datetime) -> int:
+        """
+        Append a storage_bytes event for each tenant whose database size differs
+        from its recorded size; tenants no longer in sizes are recorded as 0 bytes.
+        Returns the number of events.
+        """
+        levels_query = """
+            SELECT tenant_id, SUM(quantity)
+            FROM billing_events
+            WHERE event_type = 'storage_bytes'
+            GROUP BY tenant_id
+        """
+        insert_query = """
+            INSERT INTO billing_events (tenant_id, event_type, quantity, period_start, period_end)
+            VALUES ($1, 'storage_bytes', $2, $3, $4)
+        """
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                # Read under the lock too, so concurrent servers do not record the same change twice
+                await conn.execute(_INSERT_LOCK)
+                levels = {str(row[0]): int(row[1]) for row in await conn.fetch(levels_query)}
+                rows = []
+                for tenant_id in set(sizes) | set(levels):
+                    delta = sizes.get(tenant_id, 0) - levels.get(tenant_id, 0)
+                    if delta:
+                        rows.append((tenant_id, delta, period_start, period_end))
+                await conn.executemany(insert_query, rows)
+
+        return len(rows)
+
+    @with_retry(idempotent=True)
+    async def list(self, after_id: int, tenant_id: str, limit: int) -> List[BillingEvent]:
+        """Retrieve up to limit events with IDs after after_id, oldest first, optionally of one tenant."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM billing_events
+            WHERE id > $1 AND ($2::uuid IS NULL OR tenant_id = $2)
+            ORDER BY id
+            LIMIT $3
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, after_id, tenant_id or None, limit)
+
+        return [self._row_to_event(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def totals(self, tenant_id: str, start: datetime, end: datetime) -> List[Tuple[str, str, int]]:
+        """Total quantity per event type and dimension of a tenant's events in periods starting in [start, end)."""
+        query = """
+            SELECT event_type, dimension, SUM(quantity)
+            FROM billing_events
+            WHERE tenant_id = $1 AND period_start >= $2 AND period_start < $3
+            GROUP BY event_type, dimension
+            ORDER BY event_type, dimension
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, tenant_id, start, end)
+
+        return [(row[0], row[1], int(row[2])) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def storage_level(self, tenant_id: str, before: datetime) -> int:
+        """A tenant's recorded database size (the sum of its storage_bytes changes) in periods starting before a time."""
+        query = """
+            SELECT COALESCE(SUM(quantity), 0)
+            FROM billing_events
+            WHERE tenant_id = $1 AND event_type = 'storage_bytes' AND period_start < $2
+        """
+
+        async with self.db.pool.acquire() as conn:
+            return int(await conn.fetchval(query, tenant_id, before))
+
+    def _row_to_event(self, row: asyncpg.Record) -> BillingEvent:
+        """Convert a database row to a BillingEvent object."""
+        return BillingEvent(
+            id=row[0],
+            tenant_id=str(row[1]),
+            event_type=row[2],
+            dimension=row[3],
+            quantity=row[4],
+            period_start=row[5],
+            period_end=row[6],
+            recorded_at=row[7],
+        )
@@ -0,0 +1,101 @@
+"""
+Transparent zstd compression for large JSON data at rest.
+
+Node and relationship documents larger than the configured threshold are
+stored zstd-compressed in a BYTEA column instead of the JSONB ``data``
+column. Compressed documents are not visible to JSON path queries on
+``data``; they are decompressed lazily when the entity's data is read.
+Documents of tenants with encryption enabled are always stored in the BYTEA
+column, encrypted after any compression (see encryption.py), so queries of
+their data paths are refused (see check_data_query).
+"""
+
+import logging
+from typing import Optional, Tuple
+
+import zstandard
+
+from app.metrics import metrics
+from app.repository.encryption import DataKey, decrypt, encrypt, is_encrypted
+
+logger = logging.getLogger(__name__)
+
+# Documents at or above this size (in bytes, UTF-8 encoded) are compressed.
+# A threshold of 0 disables compression.
+_threshold_bytes: int = 0
+_level: int = 3
+# Whether the warning that data queries skip compressed documents was logged
+_warned_compressed_queries = False
+
+
+def configure_compression(threshold_bytes: int, level: int = 3) -> None:
+    """Configure the compression threshold and zstd level."""
+    global _threshold_bytes, _level
+    if threshold_bytes < 0:
+        raise ValueError("compression threshold must be >= 0")
+    _threshold_bytes = threshold_bytes
+    _level = level
+
+
+def compression_threshold() -> int:
+    """Return the configured compression threshold in bytes."""
+    return _threshold_bytes
+
+
+def encode_data(data: str, entity: str, key: Optional[DataKey] = None) -> Tuple[str, Optional[bytes]]:
+    """
+    Prepare a JSON document for storage, encrypted with key when given.
+
+    Returns a tuple of (jsonb_value, compressed_blob). When the document is
+    compressed or encrypted the JSONB value is an empty object placeholder.
+    """
+    raw = data.encode("utf-8")
+    if _threshold_bytes <= 0 or len(raw) < _threshold_bytes:
+        return (data, None) if key is None else ("{}", encrypt(raw, key, compressed=False))
+
+    blob = zstandard.ZstdCompressor(level=_level).compress(raw)
+    if len(blob) >= len(raw):
+        # Incompressible document: store as plain JSONB
+        return (data, None) if key is None else ("{}", encrypt(raw, key, compressed=False))
+
+    labels = {"entity": entity}
+    metrics.inc("data_compression_documents_total", labels=labels)
+    metrics.inc("data_compression_bytes_in_total", len(raw), labels=labels)
+    metrics.inc("data_compression_bytes_out_total", len(blob), labels=labels)
+    metrics.observe("data_compression_ratio", len(raw) / len(blob), labels=labels)
+    return "{}", blob if key is None else encrypt(blob, key, compressed=True)
+
+
+def check_data_query(what: str, key: Optional[DataKey]) -> None:
+    """
+    Guard a query of the JSONB data column (filters, ordering, facets or text
+    search on data paths), where compressed and encrypted documents are an
+    empty placeholder.
+
+    Every document of a tenant with encryption is, so its data queries are
+    refused rather than silently matching nothing. Only large documents are
+    compressed, so with compression on a warning that such queries skip them
+    is logged once.
+
+    Raises:
+        ValueError: If the tenant's documents are encrypted (key is set)
+    """
+    global _warned_compressed_queries
+    if key is not None:
+        raise ValueError(f"{what} is not available: this tenant's documents are encrypted")
+    if _threshold_bytes > 0 and not _warned_compressed_queries:
+        _warned_compressed_queries = True
+        logger.warning(
+            f"Documents of {_threshold_bytes} bytes or more are stored compressed, and {what} skips them; "
+            "raise DATA_COMPRESSION_THRESHOLD (or set it to 0) if such documents must be queried"
+        )
+
+
+def decode_data(blob: bytes) -> str:
+    """Decrypt and/or decompress a stored document back to its JSON string."""
+    if is_encrypted(blob):
+        blob, compressed = decrypt(blob)
+        if not compressed:
+            return blob.decode("utf-8")
+    metrics.inc("data_decompression_documents_total")
+    return zstandard.ZstdDecompressor().decompress(blob).decode("utf-8")
@@ -0,0 +1,105 @@
+"""
+DataMigration repository implementation.
+"""
+
+import json
+import uuid
+from datetime import datetime
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import DataMigration
+from app.repository.errors import NotFoundError, PreconditionFailedError
+from app.repository.retry import with_retry
+
+_COLUMNS = """
+    id, node_type_id, from_version, name, rules::text, expression,
+    COALESCE(schema::text, ''), created_at
+"""
+
+
+class DataMigrationRepository:
+    """PostgreSQL data migration repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, migration: DataMigration) -> Tuple[DataMigration, int]:
+        """
+        Register a migration from the node type's current schema version and
+        bump the version (and schema, if given) in the same transaction.
+
+        Returns the migration and the node type's new schema version.
+
+        Raises:
+            PreconditionFailedError: If the node type is no longer at migration.from_version
+        """
+        migration.id = str(uuid.uuid4())
+        migration.created_at = datetime.now()
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                version = await conn.fetchval(
+                    "SELECT schema_version FROM node_types WHERE id = $1 FOR UPDATE",
+                    migration.node_type_id
+                )
+                if version is None:
+                    raise NotFoundError(f"node_type not found: {migration.node_type_id}")
+                if version != migration.from_version:
+                    raise PreconditionFailedError(
+                        f"node type is at schema version {version}, not {migration.from_version}"
+                    )
+
+                row = await conn.fetchrow(
+                    f"""
+                    INSERT INTO data_migrations (
+                        id, node_type_id, from_version, name, rules, expression, schema, created_at
+                    )
+                    VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb, $8)
+                    RETURNING {_COLUMNS}
+                    """,
+                    migration.id, migration.node_type_id, migration.from_version, migration.name,
+                    json.dumps(migration.rules), migration.expression, migration.schema or None,
+                    migration.created_at
+                )
+                await conn.execute(
+                    """
+                    UPDATE node_types
+                    SET schema_version = $2, schema = COALESCE($3::jsonb, schema), updated_at = NOW()
+                    WHERE id = $1
+                    """,
+                    migration.node_type_id, migration.to_version, migration.schema or None
+                )
+
+        return self._row_to_migration(row), migration.to_version
+
+    @with_retry(idempotent=True)
+    async def list_for_node_type(self, node_type_id: str) -> List[DataMigration]:
+        """Retrieve all migrations of a node type, oldest version first."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM data_migrations
+            WHERE node_type_id = $1
+            ORDER BY from_version
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_type_id)
+
+        return [self._row_to_migration(row) for row in rows]
+
+    def _row_to_migration(self, row: asyncpg.Record) -> DataMigration:
+        """Convert a database row to a DataMigration object."""
+        return DataMigration(
+            id=str(row[0]),
+            node_type_id=str(row[1]),
+            from_version=row[2],
+            name=row[3],
+            rules=json.loads(row[4]) if row[4] else [],
+            expression=row[5],
+            schema=row[6] or "",
+            created_at=row[7],
+        )
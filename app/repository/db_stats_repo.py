@@ -0,0 +1,158 @@
+"""
+Database statistics repository implementation.
+
+Reads PostgreSQL's statistics views for capacity and performance questions.
+Slow query classes come from the pg_stat_statements extension and are
+omitted when it is not installed.
+"""
+
+from typing import Any, Dict, List, Optional
+
+from app.db.database import Database
+from app.repository.retry import with_retry
+
+# Longest query text returned per slow query class
+MAX_QUERY_TEXT = 500
+
+
+class DatabaseStatsRepository:
+    """Reads size, row count, cache and query statistics of a database."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def get_database_stats(self) -> Dict[str, Any]:
+        """Size and buffer cache hit ratio of the connected database."""
+        query = """
+            SELECT current_database(), pg_database_size(current_database()),
+                   blks_hit, blks_read, xact_commit, xact_rollback, deadlocks
+            FROM pg_stat_database
+            WHERE datname = current_database()
+        """
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query)
+
+        return {
+            "database_name": row[0],
+            "size_bytes": row[1],
+            "cache_hit_ratio": _hit_ratio(row[2], row[3]),
+            "transactions_committed": row[4],
+            "transactions_rolled_back": row[5],
+            "deadlocks": row[6],
+        }
+
+    @with_retry(idempotent=True)
+    async def list_table_stats(self) -> List[Dict[str, Any]]:
+        """Row counts (estimated), sizes and cache hit ratios of user tables, largest first."""
+        query = """
+            SELECT t.relname, t.n_live_tup, t.n_dead_tup,
+                   pg_table_size(t.relid), pg_indexes_size(t.relid),
+                   io.heap_blks_hit, io.heap_blks_read, t.last_autovacuum, t.last_autoanalyze
+            FROM pg_stat_user_tables t
+            JOIN pg_statio_user_tables io ON io.relid = t.relid
+            ORDER BY pg_total_relation_size(t.relid) DESC, t.relname
+        """
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        return [
+            {
+                "table": row[0],
+                "row_count": row[1],
+                "dead_row_count": row[2],
+                "table_size_bytes": row[3],
+                "index_size_bytes": row[4],
+                "cache_hit_ratio": _hit_ratio(row[5], row[6]),
+                "last_autovacuum": row[7].isoformat() if row[7] else None,
+                "last_autoanalyze": row[8].isoformat() if row[8] else None,
+            }
+            for row in rows
+        ]
+
+    @with_retry(idempotent=True)
+    async def list_index_stats(self) -> List[Dict[str, Any]]:
+        """Sizes and scan counts of user indexes, largest first."""
+        query = """
+            SELECT relname, indexrelname, pg_relation_size(indexrelid), idx_scan
+            FROM pg_stat_user_indexes
+            ORDER BY pg_relation_size(indexrelid) DESC, indexrelname
+        """
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        return [
+            {"table": row[0], "index": row[1], "size_bytes": row[2], "scans": row[3]}
+            for row in rows
+        ]
+
+    @with_retry(idempotent=True)
+    async def list_database_sizes(self, database_names: List[str]) -> Dict[str, Dict[str, Any]]:
+        """Size and cache hit ratio of each named database in the cluster."""
+        query = """
+            SELECT d.datname, pg_database_size(d.datname), s.blks_hit, s.blks_read
+            FROM pg_database d
+            LEFT JOIN pg_stat_database s ON s.datid = d.oid
+            WHERE d.datname = ANY($1::text[])
+        """
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, database_names)
+
+        return {
+            row[0]: {"size_bytes": row[1], "cache_hit_ratio": _hit_ratio(row[2], row[3])}
+            for row in rows
+        }
+
+    @with_retry(idempotent=True)
+    async def get_cluster_cache_hit_ratio(self) -> Optional[float]:
+        """Buffer cache hit ratio across all databases in the cluster."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow("SELECT SUM(blks_hit), SUM(blks_read) FROM pg_stat_database")
+        return _hit_ratio(row[0], row[1])
+
+    @with_retry(idempotent=True)
+    async def list_slow_queries(self, limit: int, current_database_only: bool) -> Optional[List[Dict[str, Any]]]:
+        """
+        Query classes with the highest mean execution time, or None if
+        pg_stat_statements is not installed in the connected database.
+        """
+        where = "WHERE s.dbid = (SELECT oid FROM pg_database WHERE datname = current_database())" \
+            if current_database_only else ""
+        query = f"""
+            SELECT d.datname, s.queryid, LEFT(s.query, {MAX_QUERY_TEXT}), s.calls,
+                   s.mean_exec_time, s.max_exec_time, s.total_exec_time, s.rows
+            FROM pg_stat_statements s
+            LEFT JOIN pg_database d ON d.oid = s.dbid
+            {where}
+            ORDER BY s.mean_exec_time DESC
+            LIMIT $1
+        """
+        async with self.db.pool.acquire() as conn:
+            installed = await conn.fetchval(
+                "SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements'"
+            )
+            if not installed:
+                return None
+            rows = await conn.fetch(query, limit)
+
+        return [
+            {
+                "database_name": row[0],
+                "query_id": str(row[1]),
+                "query": row[2],
+                "calls": row[3],
+                "mean_ms": round(row[4], 3),
+                "max_ms": round(row[5], 3),
+                "total_ms": round(row[6], 3),
+                "rows": row[7],
+            }
+            for row in rows
+        ]
+
+
+def _hit_ratio(hits: Optional[int], reads: Optional[int]) -> Optional[float]:
+    """Fraction of block reads served from shared buffers (None before any reads)."""
+    total = (hits or 0) + (reads or 0)
+    if not total:
+        return None
+    return round(hits / total, 4)
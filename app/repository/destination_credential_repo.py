@@ -0,0 +1,98 @@
+"""
+Destination credential repository implementation.
+
+Secrets are stored sealed with the master key (see encryption.py) and bound
+to their tenant and name, so a sealed secret copied to another row cannot
+be opened.
+"""
+
+import json
+import uuid
+from datetime import datetime, timezone
+from typing import Any, Dict, List
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.encryption import open_secret, seal_secret
+from app.repository.errors import NotFoundError
+from app.repository.models import DestinationCredential
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, tenant_id, name, kind, created_at, updated_at"
+
+
+def _context(tenant_id: str, name: str) -> str:
+    return f"destination_credential:{tenant_id}:{name}"
+
+
+class DestinationCredentialRepository:
+    """PostgreSQL destination credential repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def put(self, tenant_id: str, name: str, kind: str, secret: Dict[str, Any]) -> DestinationCredential:
+        """Store a credential, replacing the tenant's credential of the same name."""
+        sealed = seal_secret(json.dumps(secret).encode(), _context(tenant_id, name))
+        now = datetime.now(timezone.utc)
+
+        query = f"""
+            INSERT INTO destination_credentials (id, tenant_id, name, kind, secret, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $6)
+            ON CONFLICT (tenant_id, name) DO UPDATE SET kind = $4, secret = $5, updated_at = $6
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, str(uuid.uuid4()), tenant_id, name, kind, sealed, now)
+
+        return self._row_to_credential(row)
+
+    @with_retry(idempotent=True)
+    async def get(self, tenant_id: str, name: str) -> DestinationCredential:
+        """Retrieve a tenant's credential by name, with its secret."""
+        query = f"SELECT {_COLUMNS}, secret FROM destination_credentials WHERE tenant_id = $1 AND name = $2"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, tenant_id, name)
+
+        if not row:
+            raise NotFoundError(f"destination credential not found: {name}")
+
+        credential = self._row_to_credential(row)
+        credential.secret = json.loads(open_secret(row["secret"], _context(tenant_id, name)))
+        return credential
+
+    @with_retry(idempotent=True)
+    async def list(self, tenant_id: str) -> List[DestinationCredential]:
+        """Retrieve a tenant's credentials, without their secrets."""
+        query = f"SELECT {_COLUMNS} FROM destination_credentials WHERE tenant_id = $1 ORDER BY name"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, tenant_id)
+
+        return [self._row_to_credential(row) for row in rows]
+
+    @with_retry()
+    async def delete(self, tenant_id: str, name: str) -> None:
+        """Delete a tenant's credential."""
+        query = "DELETE FROM destination_credentials WHERE tenant_id = $1 AND name = $2"
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, tenant_id, name)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"destination credential not found: {name}")
+
+    def _row_to_credential(self, row: asyncpg.Record) -> DestinationCredential:
+        """Convert a database row to a DestinationCredential object."""
+        return DestinationCredential(
+            id=str(row["id"]),
+            tenant_id=str(row["tenant_id"]),
+            name=row["name"],
+            kind=row["kind"],
+            created_at=row["created_at"],
+            updated_at=row["updated_at"],
+        )
@@ -0,0 +1,315 @@
+"""
+Directory (users and groups) repository implementation.
+"""
+
+import uuid
+from datetime import datetime
+from typing import List, Optional, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import DirectoryGroup, DirectoryUser, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_USER_COLUMNS = "id, user_name, external_id, display_name, email, active, created_at, updated_at"
+_GROUP_COLUMNS = """
+    id, display_name, external_id, created_at, updated_at,
+    (SELECT COUNT(*) FROM directory_group_members m WHERE m.group_id = directory_groups.id)
+"""
+
+
+def _offset(opts: ListOptions) -> int:
+    try:
+        return int(opts.page_token) if opts.page_token else 0
+    except ValueError:
+        return 0
+
+
+def _page(items: list, offset: int, total_count: int) -> ListResult:
+    result = ListResult(total_count=total_count)
+    if offset + len(items) < total_count:
+        result.next_page_token = str(offset + len(items))
+    return result
+
+
+class DirectoryRepository:
+    """PostgreSQL tenant directory repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create_user(self, user: DirectoryUser) -> DirectoryUser:
+        """Create a new directory user."""
+        user.id = str(uuid.uuid4())
+        user.created_at = datetime.now()
+        user.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO directory_users (
+                id, user_name, external_id, display_name, email, active, created_at, updated_at
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING {_USER_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                user.id, user.user_name, user.external_id or None, user.display_name, user.email,
+                user.active, user.created_at, user.updated_at
+            )
+
+        return self._row_to_user(row)
+
+    @with_retry(idempotent=True)
+    async def get_user(self, id: str) -> DirectoryUser:
+        """Retrieve a directory user, with their groups, by ID."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(f"SELECT {_USER_COLUMNS} FROM directory_users WHERE id = $1", id)
+            if not row:
+                raise NotFoundError(f"directory_user not found: {id}")
+            user = self._row_to_user(row)
+            user.groups = await self._group_names(conn, user.id)
+
+        return user
+
+    @with_retry(idempotent=True)
+    async def find_user(self, user_name: str = "", external_id: str = "") -> Optional[DirectoryUser]:
+        """Retrieve a directory user, with their groups, by user name or external ID; None if absent."""
+        query = f"""
+            SELECT {_USER_COLUMNS} FROM directory_users
+            WHERE ($1 <> '' AND user_name = $1) OR ($2 <> '' AND external_id = $2)
+            ORDER BY user_name = $1 DESC
+            LIMIT 1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, user_name, external_id)
+            if not row:
+                return None
+            user = self._row_to_user(row)
+            user.groups = await self._group_names(conn, user.id)
+
+        return user
+
+    @with_retry()
+    async def update_user(self, user: DirectoryUser) -> DirectoryUser:
+        """Update an existing directory user."""
+        user.updated_at = datetime.now()
+
+        query = f"""
+            UPDATE directory_users
+            SET user_name = $2, external_id = $3, display_name = $4, email = $5, active = $6, updated_at = $7
+            WHERE id = $1
+            RETURNING {_USER_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                user.id, user.user_name, user.external_id or None, user.display_name, user.email,
+                user.active, user.updated_at
+            )
+            if not row:
+                raise NotFoundError(f"directory_user not found: {user.id}")
+            updated = self._row_to_user(row)
+            updated.groups = await self._group_names(conn, updated.id)
+
+        return updated
+
+    @with_retry()
+    async def delete_user(self, id: str) -> None:
+        """Delete a directory user (and their group memberships) by ID."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM directory_users WHERE id = $1", id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"directory_user not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list_users(
+        self,
+        opts: ListOptions,
+        active: Optional[bool] = None,
+        user_name_prefix: str = "",
+        group_id: str = "",
+    ) -> Tuple[List[DirectoryUser], ListResult]:
+        """Retrieve directory users ordered by user name, optionally filtered."""
+        page_size = opts.effective_page_size()
+        offset = _offset(opts)
+        where = """
+            ($1::boolean IS NULL OR active = $1)
+            AND ($2 = '' OR starts_with(user_name, $2))
+            AND ($3::uuid IS NULL OR id IN (SELECT user_id FROM directory_group_members WHERE group_id = $3))
+        """
+        args = [active, user_name_prefix, group_id or None]
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval(f"SELECT COUNT(*) FROM directory_users WHERE {where}", *args)
+            rows = await conn.fetch(
+                f"""
+                SELECT {_USER_COLUMNS} FROM directory_users
+                WHERE {where}
+                ORDER BY user_name
+                LIMIT $4 OFFSET $5
+                """,
+                *args, page_size, offset
+            )
+
+        users = [self._row_to_user(row) for row in rows]
+        return users, _page(users, offset, total_count)
+
+    @with_retry()
+    async def create_group(self, group: DirectoryGroup) -> DirectoryGroup:
+        """Create a new directory group."""
+        group.id = str(uuid.uuid4())
+        group.created_at = datetime.now()
+        group.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO directory_groups (id, display_name, external_id, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING {_GROUP_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query, group.id, group.display_name, group.external_id or None, group.created_at, group.updated_at
+            )
+
+        return self._row_to_group(row)
+
+    @with_retry(idempotent=True)
+    async def get_group(self, id: str) -> DirectoryGroup:
+        """Retrieve a directory group by ID."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(f"SELECT {_GROUP_COLUMNS} FROM directory_groups WHERE id = $1", id)
+
+        if not row:
+            raise NotFoundError(f"directory_group not found: {id}")
+
+        return self._row_to_group(row)
+
+    @with_retry(idempotent=True)
+    async def find_group(self, display_name: str = "", external_id: str = "") -> Optional[DirectoryGroup]:
+        """Retrieve a directory group by display name or external ID; None if absent."""
+        query = f"""
+            SELECT {_GROUP_COLUMNS} FROM directory_groups
+            WHERE ($1 <> '' AND display_name = $1) OR ($2 <> '' AND external_id = $2)
+            LIMIT 1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, display_name, external_id)
+
+        return self._row_to_group(row) if row else None
+
+    @with_retry()
+    async def update_group(self, group: DirectoryGroup) -> DirectoryGroup:
+        """Update an existing directory group."""
+        group.updated_at = datetime.now()
+
+        query = f"""
+            UPDATE directory_groups
+            SET display_name = $2, external_id = $3, updated_at = $4
+            WHERE id = $1
+            RETURNING {_GROUP_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, group.id, group.display_name, group.external_id or None, group.updated_at)
+
+        if not row:
+            raise NotFoundError(f"directory_group not found: {group.id}")
+
+        return self._row_to_group(row)
+
+    @with_retry()
+    async def delete_group(self, id: str) -> None:
+        """Delete a directory group by ID."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM directory_groups WHERE id = $1", id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"directory_group not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list_groups(self, opts: ListOptions) -> Tuple[List[DirectoryGroup], ListResult]:
+        """Retrieve directory groups ordered by display name."""
+        page_size = opts.effective_page_size()
+        offset = _offset(opts)
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM directory_groups")
+            rows = await conn.fetch(
+                f"SELECT {_GROUP_COLUMNS} FROM directory_groups ORDER BY display_name LIMIT $1 OFFSET $2",
+                page_size, offset
+            )
+
+        groups = [self._row_to_group(row) for row in rows]
+        return groups, _page(groups, offset, total_count)
+
+    @with_retry(idempotent=True)
+    async def add_member(self, group_id: str, user_id: str) -> None:
+        """Add a user to a group (adding an existing member is a no-op)."""
+        async with self.db.pool.acquire() as conn:
+            try:
+                await conn.execute(
+                    """
+                    INSERT INTO directory_group_members (group_id, user_id) VALUES ($1, $2)
+                    ON CONFLICT DO NOTHING
+                    """,
+                    group_id, user_id
+                )
+            except asyncpg.ForeignKeyViolationError:
+                raise NotFoundError(f"directory_group or directory_user not found: {group_id}, {user_id}")
+
+    @with_retry(idempotent=True)
+    async def remove_member(self, group_id: str, user_id: str) -> None:
+        """Remove a user from a group."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(
+                "DELETE FROM directory_group_members WHERE group_id = $1 AND user_id = $2", group_id, user_id
+            )
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"directory_user {user_id} is not a member of directory_group {group_id}")
+
+    async def _group_names(self, conn: asyncpg.Connection, user_id: str) -> List[str]:
+        rows = await conn.fetch(
+            """
+            SELECT g.display_name FROM directory_groups g
+            JOIN directory_group_members m ON m.group_id = g.id
+            WHERE m.user_id = $1
+            ORDER BY g.display_name
+            """,
+            user_id
+        )
+        return [row[0] for row in rows]
+
+    def _row_to_user(self, row: asyncpg.Record) -> DirectoryUser:
+        """Convert a database row to a DirectoryUser object."""
+        return DirectoryUser(
+            id=str(row[0]),
+            user_name=row[1],
+            external_id=row[2] or "",
+            display_name=row[3],
+            email=row[4],
+            active=row[5],
+            created_at=row[6],
+            updated_at=row[7],
+        )
+
+    def _row_to_group(self, row: asyncpg.Record) -> DirectoryGroup:
+        """Convert a database row to a DirectoryGroup object."""
+        return DirectoryGroup(
+            id=str(row[0]),
+            display_name=row[1],
+            external_id=row[2] or "",
+            created_at=row[3],
+            updated_at=row[4],
+            member_count=row[5],
+        )
@@ -0,0 +1,311 @@
+"""
+Dual writes to a second storage backend.
+
+During a backend migration, such as moving a tenant to a database with
+another table layout or to another implementation of the core repositories,
+the dual-write repositories wrap the core repositories (node types, nodes,
+relationship types and relationships) of the current backend, the primary,
+and of the new one, the secondary. The primary serves all reads. Writes go
+to the primary first, and its result or error is what the caller gets.
+Successful writes are then mirrored to the secondary with the IDs the primary
+assigned. An entity's new state is mirrored rather than the request replayed,
+so an update still applies when the secondary's timestamps differ.
+
+Mirroring never fails a write. A failed mirror is logged and counted
+(dual_write_failures_total) and leaves the secondary behind until sync()
+repairs it. Node history (record_correction) is not mirrored. Neither are
+writes that bypass the core repositories, such as data migrations applied
+in the database; sync() catches those up too.
+"""
+
+import copy
+import logging
+from typing import Any, Awaitable, Dict, List, Optional, Tuple
+
+from app.metrics import metrics
+from app.repository.errors import NotFoundError
+from app.repository.models import MetadataUpdate, Node, NodeType, Relationship, RelationshipType
+
+logger = logging.getLogger(__name__)
+
+ENTITIES = ("node_types", "relationship_types", "nodes", "relationships")
+
+
+def copy_node(node: Node) -> Node:
+    """A copy of a node's stored state, to write to another backend."""
+    return Node(
+        id=node.id,
+        node_type_id=node.node_type_id,
+        data=node.data,
+        metadata=copy.deepcopy(node.metadata),
+        schema_version=node.schema_version,
+    )
+
+
+def copy_relationship(rel: Relationship) -> Relationship:
+    """A copy of a relationship's stored state, to write to another backend."""
+    return Relationship(
+        id=rel.id,
+        source_node_id=rel.source_node_id,
+        target_node_id=rel.target_node_id,
+        relationship_type=rel.relationship_type,
+        data=rel.data,
+        valid_from=rel.valid_from,
+        valid_to=rel.valid_to,
+    )
+
+
+async def put_node(repo: Any, node: Node) -> None:
+    """Write a node's state to a backend, creating the node if the backend lacks it."""
+    try:
+        await repo.update(copy_node(node), metadata=MetadataUpdate(set=copy.deepcopy(node.metadata), replace=True))
+    except NotFoundError:
+        await repo.create(copy_node(node))
+
+
+async def put_relationship(repo: Any, rel: Relationship) -> None:
+    """Write a relationship's state to a backend, creating it if the backend lacks it."""
+    try:
+        await repo.update(copy_relationship(rel))
+    except NotFoundError:
+        await repo.create(copy_relationship(rel))
+
+
+async def put_node_type(repo: Any, node_type: NodeType) -> None:
+    """Write a node type to a backend, creating it if the backend lacks it."""
+    state = NodeType(id=node_type.id, name=node_type.name, description=node_type.description, schema=node_type.schema)
+    try:
+        await repo.update(state)
+    except NotFoundError:
+        await repo.create(state)
+
+
+async def put_relationship_type(repo: Any, rel_type: RelationshipType) -> None:
+    """Write a relationship type to a backend, creating it if the backend lacks it."""
+    try:
+        await repo.update(copy.deepcopy(rel_type))
+    except NotFoundError:
+        await repo.create(copy.deepcopy(rel_type))
+
+
+_PUT = {
+    "node_types": put_node_type,
+    "relationship_types": put_relationship_type,
+    "nodes": put_node,
+    "relationships": put_relationship,
+}
+
+
+async def sync(primary: Any, secondary: Any, entity: str, ids: List[str]) -> List[Tuple[str, str]]:
+    """
+    Bring entities of one kind (see ENTITIES) in the secondary backend to their
+    current state in the primary: entities the primary has are written, the
+    others deleted. primary and secondary are repositories of that kind.
+
+    Returns the (ID, error) of the entities that could not be synced.
+    """
+    if entity == "nodes":
+        current: Dict[str, Any] = {n.id: n for n in await primary.get_many(ids)}
+    else:
+        current = {}
+        for id in ids:
+            try:
+                current[id] = await primary.get_by_id(id)
+            except NotFoundError:
+                pass
+
+    failed = []
+    for id in ids:
+        try:
+            if id in current:
+                await _PUT[entity](secondary, current[id])
+            else:
+                await _delete(secondary, id)
+        except Exception as e:
+            failed.append((id, str(e)))
+    return failed
+
+
+async def _delete(repo: Any, id: str) -> None:
+    try:
+        await repo.delete(id)
+    except NotFoundError:
+        pass
+
+
+class _DualWriteRepository:
+    """Serves reads from the primary and mirrors writes to the secondary."""
+    entity = ""
+
+    def __init__(self, primary: Any, secondary: Any, tenant_id: str = ""):
+        self.primary = primary
+        self.secondary = secondary
+        self.tenant_id = tenant_id
+
+    def __getattr__(self, name: str) -> Any:
+        # Reads, and anything else not mirrored, go to the primary
+        return getattr(self.primary, name)
+
+    async def _mirror(self, write: Awaitable[Any], id: str = "") -> None:
+        try:
+            await write
+        except Exception as e:
+            metrics.inc("dual_write_failures_total", labels={"entity": self.entity})
+            logger.warning(
+                f"Mirroring a write of {self.entity} {id} of tenant {self.tenant_id} "
+                f"to the secondary backend failed: {e}"
+            )
+
+    async def _mirror_delete(self, id: str) -> None:
+        await self._mirror(_delete(self.secondary, id), id)
+
+
+class DualWriteNodeTypeRepository(_DualWriteRepository):
+    """Node type repository writing to two backends."""
+    entity = "node_types"
+
+    async def create(self, node_type: NodeType) -> NodeType:
+        created = await self.primary.create(node_type)
+        await self._mirror(put_node_type(self.secondary, created), created.id)
+        return created
+
+    async def update(self, node_type: NodeType) -> NodeType:
+        updated = await self.primary.update(node_type)
+        await self._mirror(put_node_type(self.secondary, updated), updated.id)
+        return updated
+
+    async def delete(self, id: str) -> None:
+        await self.primary.delete(id)
+        await self._mirror_delete(id)
+
+
+class DualWriteRelationshipTypeRepository(_DualWriteRepository):
+    """Relationship type repository writing to two backends."""
+    entity = "relationship_types"
+
+    async def create(self, rel_type: RelationshipType) -> RelationshipType:
+        created = await self.primary.create(rel_type)
+        await self._mirror(put_relationship_type(self.secondary, created), created.id)
+        return created
+
+    async def update(self, rel_type: RelationshipType) -> RelationshipType:
+        updated = await self.primary.update(rel_type)
+        await self._mirror(put_relationship_type(self.secondary, updated), updated.id)
+        return updated
+
+    async def delete(self, id: str) -> None:
+        await self.primary.delete(id)
+        await self._mirror_delete(id)
+
+
+class DualWriteNodeRepository(_DualWriteRepository):
+    """Node repository writing to two backends."""
+    entity = "nodes"
+
+    async def create(self, node: Node, valid_from: Optional[Any] = None) -> Node:
+        created = await self.primary.create(node, valid_from)
+        await self._mirror(self.secondary.create(copy_node(created)), created.id)
+        return created
+
+    async def update(
+        self,
+        node: Node,
+        valid_from: Optional[Any] = None,
+        expected_updated_at: Optional[Any] = None,
+        metadata: Optional[MetadataUpdate] = None
+    ) -> Node:
+        updated = await self.primary.update(node, valid_from, expected_updated_at, metadata)
+        await self._mirror(put_node(self.secondary, updated), updated.id)
+        return updated
+
+    async def update_metadata(
+        self,
+        id: str,
+        metadata: MetadataUpdate,
+        expected_updated_at: Optional[Any] = None
+    ) -> Node:
+        updated = await self.primary.update_metadata(id, metadata, expected_updated_at)
+        await self._mirror(put_node(self.secondary, updated), id)
+        return updated
+
+    async def set_metadata_values(self, key: str, name: str, values: List[Tuple[str, Any]]) -> int:
+        count = await self.primary.set_metadata_values(key, name, values)
+        await self._mirror(self.secondary.set_metadata_values(key, name, values), f"metadata {key}.{name}")
+        return count
+
+    async def increment_field(
+        self,
+        id: str,
+        path: List[str],
+        delta: float,
+        valid_from: Optional[Any] = None,
+    ) -> Tuple[Node, float]:
+        node, value = await self.primary.increment_field(id, path, delta, valid_from)
+        await self._mirror(put_node(self.secondary, node), id)
+        return node, value
+
+    async def store_migrated_data(self, node: Node, from_version: int) -> bool:
+        stored = await self.primary.store_migrated_data(node, from_version)
+        if stored:
+            await self._mirror(self.secondary.store_migrated_data(copy_node(node), from_version), node.id)
+        return stored
+
+    async def delete(self, id: str) -> None:
+        await self.primary.delete(id)
+        await self._mirror_delete(id)
+
+    async def delete_many(self, ids: List[str]) -> None:
+        await self.primary.delete_many(ids)
+        # Deleted one by one, as delete_many deletes nothing when one of them is missing
+        for id in ids:
+            await self._mirror_delete(id)
+
+
+class DualWriteRelationshipRepository(_DualWriteRepository):
+    """Relationship repository writing to two backends."""
+    entity = "relationships"
+
+    async def create(self, rel: Relationship) -> Relationship:
+        created = await self.primary.create(rel)
+        await self._mirror(self.secondary.create(copy_relationship(created)), created.id)
+        return created
+
+    async def create_unless_exists(self, rel: Relationship, undirected: bool = False) -> Tuple[Relationship, bool]:
+        result, created = await self.primary.create_unless_exists(rel, undirected)
+        if created:
+            await self._mirror(self.secondary.create(copy_relationship(result)), result.id)
+        return result, created
+
+    async def create_many(self, rels: List[Relationship]) -> None:
+        await self.primary.create_many(rels)
+        # create_many assigned the IDs to rels
+        for rel in rels:
+            await self._mirror(self.secondary.create(copy_relationship(rel)), rel.id)
+
+    async def update(self, rel: Relationship, expected_updated_at: Optional[Any] = None) -> Relationship:
+        updated = await self.primary.update(rel, expected_updated_at)
+        await self._mirror(put_relationship(self.secondary, updated), updated.id)
+        return updated
+
+    async def delete(self, id: str) -> None:
+        await self.primary.delete(id)
+        await self._mirror_delete(id)
+
+    async def delete_many(self, ids: List[str]) -> int:
+        count = await self.primary.delete_many(ids)
+        await self._mirror(self.secondary.delete_many(ids))
+        return count
+
+    async def delete_orphans(self, ids: List[str]) -> int:
+        count = await self.primary.delete_orphans(ids)
+        await self._mirror(self.secondary.delete_orphans(ids))
+        return count
+
+    async def refresh_derived(self, rel_type: RelationshipType) -> int:
+        count = await self.primary.refresh_derived(rel_type)
+        await self._mirror(self.secondary.refresh_derived(rel_type), rel_type.id)
+        return count
+
+    async def clear_derived(self, name: str) -> None:
+        await self.primary.clear_derived(name)
+        await self._mirror(self.secondary.clear_derived(name), name)
@@ -0,0 +1,68 @@
+"""
+Dual-write target repository implementation.
+"""
+
+from datetime import datetime
+from typing import Optional
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.errors import AlreadyExistsError
+from app.repository.models import DualWriteTarget
+from app.repository.retry import with_retry
+
+
+class DualWriteTargetRepository:
+    """PostgreSQL repository of the databases tenants mirror their writes to (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def get(self, tenant_id: str) -> Optional[DualWriteTarget]:
+        """Return the database a tenant's writes are mirrored to, or None if it is not in dual-write mode."""
+        query = "SELECT tenant_id, database_name, started_at FROM tenant_dual_writes WHERE tenant_id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, tenant_id)
+
+        return self._row_to_target(row) if row else None
+
+    @with_retry()
+    async def create(self, target: DualWriteTarget) -> DualWriteTarget:
+        """
+        Record that a tenant's writes are mirrored to a database.
+
+        Raises:
+            AlreadyExistsError: If the tenant is in dual-write mode already
+        """
+        target.started_at = datetime.now()
+        query = """
+            INSERT INTO tenant_dual_writes (tenant_id, database_name, started_at)
+            VALUES ($1, $2, $3)
+            RETURNING tenant_id, database_name, started_at
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(query, target.tenant_id, target.database_name, target.started_at)
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(f"tenant is in dual-write mode already: {target.tenant_id}") from e
+
+        return self._row_to_target(row)
+
+    @with_retry(idempotent=True)
+    async def delete(self, tenant_id: str) -> bool:
+        """End a tenant's dual-write mode; returns whether it was in it."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM tenant_dual_writes WHERE tenant_id = $1", tenant_id)
+
+        return result != "DELETE 0"
+
+    def _row_to_target(self, row: asyncpg.Record) -> DualWriteTarget:
+        return DualWriteTarget(
+            tenant_id=str(row["tenant_id"]),
+            database_name=row["database_name"],
+            started_at=row["started_at"],
+        )
@@ -0,0 +1,80 @@
+"""
+Encrypted document repository implementation.
+
+Used by key rotation to find the documents of a tenant database not yet
+encrypted with a data key and to re-encrypt them in place.
+"""
+
+from typing import Any, List, Optional, Tuple
+
+from app.db.database import Database
+from app.repository.compression import decode_data, encode_data
+from app.repository.encryption import DataKey
+from app.repository.retry import with_retry
+
+# Tables holding documents, with their key column and the entity name used in metrics
+DOCUMENT_TABLES = {
+    "nodes": ("id", "node"),
+    "node_versions": ("version_id", "node"),
+    "relationships": ("id", "relationship"),
+}
+
+# Rows whose document is not encrypted with the key whose prefix is $1
+_STALE = "(data_compressed IS NULL OR substring(data_compressed FROM 1 FOR {n}) <> $1)"
+
+
+class EncryptedDataRepository:
+    """PostgreSQL encrypted document repository (tenant database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def count_stale(self, key: DataKey) -> int:
+        """Count the documents in every document table not encrypted with key."""
+        stale = _STALE.format(n=len(key.prefix))
+        query = " + ".join(f"(SELECT COUNT(*) FROM {table} WHERE {stale})" for table in DOCUMENT_TABLES)
+
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval(f"SELECT {query}", key.prefix)
+
+    @with_retry(idempotent=True)
+    async def list_stale(
+        self, table: str, key: DataKey, after: Optional[Any], limit: int
+    ) -> List[Tuple[Any, str, Optional[bytes]]]:
+        """Retrieve up to limit (id, data, data_compressed) rows of a table not encrypted with key, by ID after after."""
+        id_column, _ = DOCUMENT_TABLES[table]
+        query = f"""
+            SELECT {id_column}, data::text, data_compressed
+            FROM {table}
+            WHERE {_STALE.format(n=len(key.prefix))} AND ($2::text IS NULL OR {id_column} > $2::text::{_id_type(table)})
+            ORDER BY {id_column}
+            LIMIT $3
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, key.prefix, None if after is None else str(after), limit)
+
+        return [(row[0], row[1], row[2]) for row in rows]
+
+    @with_retry()
+    async def reencrypt(self, table: str, key: DataKey, id: Any, data: str, blob: Optional[bytes]) -> bool:
+        """
+        Store a document encrypted with key, unless it changed since it was read
+        as (data, blob); returns whether it was stored.
+        """
+        id_column, entity = DOCUMENT_TABLES[table]
+        plaintext = decode_data(blob) if blob is not None else data
+        _, encrypted = encode_data(plaintext, entity, key)
+        query = f"""
+            UPDATE {table} SET data = '{{}}'::jsonb, data_compressed = $2
+            WHERE {id_column} = $1 AND data::text = $3 AND data_compressed IS NOT DISTINCT FROM $4
+        """
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, id, encrypted, data, blob)
+        return result.split()[-1] == "1"
+
+
+def _id_type(table: str) -> str:
+    return "bigint" if DOCUMENT_TABLES[table][0] == "version_id" else "uuid"
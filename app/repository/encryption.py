@@ -0,0 +1,138 @@
+"""
+Per-tenant encryption of JSON data at rest.
+
+Each tenant with encryption enabled has its own data keys, created by key
+rotation and stored in the control database wrapped (AES-256-GCM
+encrypted) with the server's master key (``ENCRYPTION_MASTER_KEY``). Node,
+node version and relationship documents of such tenants are stored
+encrypted in the ``data_compressed`` BYTEA column, like compressed
+documents, so they are likewise not visible to JSON path queries on
+``data``.
+
+An encrypted document is ``ENCRYPTED_MAGIC``, the 16-byte data key ID, a
+flags byte, a 12-byte nonce and the AES-256-GCM ciphertext of the
+(optionally zstd-compressed) document. The key ID prefix lets reads find
+the key and lets key rotation find documents still under older keys.
+
+Small secrets, such as tenants' export destination credentials, are sealed
+with the master key directly, bound to what they belong to.
+"""
+
+import base64
+import os
+import uuid
+from dataclasses import dataclass
+from typing import Dict, Tuple
+
+from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+from app.metrics import metrics
+
+ENCRYPTED_MAGIC = b"FXE1"
+KEY_BYTES = 32
+NONCE_BYTES = 12
+# Flags byte: the plaintext is zstd-compressed
+FLAG_COMPRESSED = 0x01
+
+_master_key: bytes = b""
+# Unwrapped data keys of every tenant loaded by this process, by key ID
+_data_keys: Dict[str, bytes] = {}
+
+
+@dataclass
+class DataKey:
+    """An unwrapped tenant data key."""
+    id: str
+    key: bytes
+
+    @property
+    def prefix(self) -> bytes:
+        """Leading bytes of every document encrypted with this key."""
+        return ENCRYPTED_MAGIC + uuid.UUID(self.id).bytes
+
+
+def configure_encryption(master_key: str) -> None:
+    """
+    Configure the master key (base64 of 32 bytes); an empty key disables tenant encryption.
+
+    Raises:
+        ValueError: If the key is not 32 base64-encoded bytes
+    """
+    global _master_key
+    if not master_key:
+        _master_key = b""
+        return
+    try:
+        key = base64.b64decode(master_key, validate=True)
+    except ValueError:
+        raise ValueError("ENCRYPTION_MASTER_KEY must be base64")
+    if len(key) != KEY_BYTES:
+        raise ValueError(f"ENCRYPTION_MASTER_KEY must encode {KEY_BYTES} bytes")
+    _master_key = key
+
+
+def encryption_enabled() -> bool:
+    """Whether a master key is configured."""
+    return bool(_master_key)
+
+
+def new_wrapped_key(tenant_id: str) -> bytes:
+    """Generate a data key for a tenant and return it wrapped with the master key."""
+    if not _master_key:
+        raise ValueError("tenant encryption is not configured (ENCRYPTION_MASTER_KEY)")
+    nonce = os.urandom(NONCE_BYTES)
+    return nonce + AESGCM(_master_key).encrypt(nonce, AESGCM.generate_key(bit_length=256), tenant_id.encode())
+
+
+def unwrap_key(key_id: str, tenant_id: str, wrapped: bytes) -> DataKey:
+    """Unwrap a tenant data key and register it for decrypting documents."""
+    if not _master_key:
+        raise ValueError("tenant encryption is not configured (ENCRYPTION_MASTER_KEY)")
+    key = AESGCM(_master_key).decrypt(wrapped[:NONCE_BYTES], wrapped[NONCE_BYTES:], tenant_id.encode())
+    _data_keys[key_id] = key
+    return DataKey(id=key_id, key=key)
+
+
+def seal_secret(plaintext: bytes, context: str) -> bytes:
+    """Encrypt a secret with the master key; it can only be opened with the same context."""
+    if not _master_key:
+        raise ValueError("secrets cannot be stored without ENCRYPTION_MASTER_KEY")
+    nonce = os.urandom(NONCE_BYTES)
+    return nonce + AESGCM(_master_key).encrypt(nonce, plaintext, context.encode())
+
+
+def open_secret(sealed: bytes, context: str) -> bytes:
+    """Decrypt a secret sealed with seal_secret."""
+    if not _master_key:
+        raise ValueError("secrets cannot be read without ENCRYPTION_MASTER_KEY")
+    return AESGCM(_master_key).decrypt(sealed[:NONCE_BYTES], sealed[NONCE_BYTES:], context.encode())
+
+
+def is_encrypted(blob: bytes) -> bool:
+    """Whether a stored blob is an encrypted document."""
+    return blob[:len(ENCRYPTED_MAGIC)] == ENCRYPTED_MAGIC
+
+
+def encrypt(plaintext: bytes, key: DataKey, compressed: bool) -> bytes:
+    """Encrypt a document with a data key."""
+    nonce = os.urandom(NONCE_BYTES)
+    header = key.prefix + bytes([FLAG_COMPRESSED if compressed else 0])
+    metrics.inc("data_encryption_documents_total")
+    return header + nonce + AESGCM(key.key).encrypt(nonce, plaintext, header)
+
+
+def decrypt(blob: bytes) -> Tuple[bytes, bool]:
+    """
+    Decrypt a document; returns the plaintext and whether it is compressed.
+
+    Raises:
+        RuntimeError: If the document's data key is not loaded
+    """
+    header_len = len(ENCRYPTED_MAGIC) + 17
+    key_id = str(uuid.UUID(bytes=blob[len(ENCRYPTED_MAGIC):header_len - 1]))
+    key = _data_keys.get(key_id)
+    if key is None:
+        raise RuntimeError(f"data key {key_id} is not loaded")
+    header, nonce = blob[:header_len], blob[header_len:header_len + NONCE_BYTES]
+    plaintext = AESGCM(key).decrypt(nonce, blob[header_len + NONCE_BYTES:], header)
+    return plaintext, bool(header[-1] & FLAG_COMPRESSED)
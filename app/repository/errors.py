@@ -2,7 +2,60 @@
 Repository errors module.
 """
 
+from typing import List, Optional, Tuple
+
 
 class NotFoundError(Exception):
     """Raised when a resource is not found."""
     pass
+
+
+class PermissionDeniedError(Exception):
+    """Raised when the caller is not allowed to perform a call."""
+    pass
+
+
+class DataResidencyError(PermissionDeniedError):
+    """Raised when a tenant's data would be read or written outside its region."""
+
+    def __init__(self, message: str, tenant_region: str = "", database_region: str = ""):
+        super().__init__(message)
+        self.tenant_region = tenant_region
+        self.database_region = database_region
+
+
+class PreconditionFailedError(Exception):
+    """Raised when a conditional write's If-Match etag no longer matches."""
+    pass
+
+
+class AlreadyExistsError(Exception):
+    """Raised when creating a resource with a client-supplied ID that is already taken."""
+    pass
+
+
+class SlugTakenError(AlreadyExistsError):
+    """Raised when a tenant slug is already used by another tenant, optionally with available alternatives."""
+
+    def __init__(self, slug: str, suggestions: Optional[List[str]] = None):
+        super().__init__(f"tenant slug already exists: {slug}")
+        self.slug = slug
+        self.suggestions = suggestions or []
+
+
+class DuplicateRelationshipError(AlreadyExistsError):
+    """Raised when creating a relationship whose type rejects duplicates and an identical one exists."""
+
+    def __init__(self, rel_type: str, source_node_id: str, target_node_id: str, existing_id: str):
+        super().__init__(
+            f"relationship {rel_type} from {source_node_id} to {target_node_id} already exists: {existing_id}"
+        )
+        self.existing_id = existing_id
+
+
+class FieldViolationError(ValueError):
+    """Raised when parameters are invalid, naming each offending field and what is wrong with it."""
+
+    def __init__(self, message: str, violations: List[Tuple[str, str]]):
+        super().__init__(message)
+        self.violations = violations
@@ -0,0 +1,330 @@
+"""
+Export schedule repository implementation.
+"""
+
+import json
+import uuid
+from datetime import datetime, timezone
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import ExportRun, ExportSchedule, ListOptions, ListResult
+from app.repository.errors import AlreadyExistsError, NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = """id, tenant_id, name, cron, format, destination, filter::text, include_relationships, alert_url,
+    credential, transform::text, enabled, next_run_at, consecutive_failures, created_at, updated_at"""
+
+_RUN_COLUMNS = """id, schedule_id, status, location, node_count, relationship_count, size_bytes, error,
+    started_at, finished_at"""
+
+
+class ExportScheduleRepository:
+    """PostgreSQL export schedule and run history repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, schedule: ExportSchedule) -> ExportSchedule:
+        """
+        Create a new schedule.
+
+        Raises:
+            AlreadyExistsError: If the tenant already has a schedule with the name
+        """
+        schedule.id = str(uuid.uuid4())
+        schedule.created_at = datetime.now(timezone.utc)
+        schedule.updated_at = schedule.created_at
+
+        query = f"""
+            INSERT INTO export_schedules (
+                id, tenant_id, name, cron, format, destination, filter, include_relationships, alert_url,
+                credential, transform, enabled, next_run_at, created_at, updated_at
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8, $9, $10, $11::jsonb, $12, $13, $14, $15)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(
+                    query,
+                    schedule.id, schedule.tenant_id, schedule.name, schedule.cron, schedule.format,
+                    schedule.destination, json.dumps(schedule.filter), schedule.include_relationships,
+                    schedule.alert_url, schedule.credential, json.dumps(schedule.transform), schedule.enabled,
+                    schedule.next_run_at, schedule.created_at, schedule.updated_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(f"export schedule already exists: {schedule.name}") from e
+
+        return self._row_to_schedule(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> ExportSchedule:
+        """Retrieve a schedule by ID."""
+        query = f"SELECT {_COLUMNS} FROM export_schedules WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"export schedule not found: {id}")
+
+        return self._row_to_schedule(row)
+
+    @with_retry()
+    async def update(self, schedule: ExportSchedule) -> ExportSchedule:
+        """
+        Update a schedule's definition and next run.
+
+        Raises:
+            AlreadyExistsError: If the tenant already has another schedule with the name
+        """
+        schedule.updated_at = datetime.now(timezone.utc)
+
+        query = f"""
+            UPDATE export_schedules
+            SET name = $2, cron = $3, format = $4, destination = $5, filter = $6::jsonb,
+                include_relationships = $7, alert_url = $8, credential = $9, transform = $10::jsonb, enabled = $11,
+                next_run_at = $12, updated_at = $13
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(
+                    query,
+                    schedule.id, schedule.name, schedule.cron, schedule.format, schedule.destination,
+                    json.dumps(schedule.filter), schedule.include_relationships, schedule.alert_url,
+                    schedule.credential, json.dumps(schedule.transform), schedule.enabled, schedule.next_run_at,
+                    schedule.updated_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(f"export schedule already exists: {schedule.name}") from e
+
+        if not row:
+            raise NotFoundError(f"export schedule not found: {schedule.id}")
+
+        return self._row_to_schedule(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete a schedule and its run history."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM export_schedules WHERE id = $1", id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"export schedule not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list(self, tenant_id: str, opts: ListOptions) -> Tuple[List[ExportSchedule], ListResult]:
+        """Retrieve a tenant's schedules with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM export_schedules WHERE tenant_id = $1", tenant_id)
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM export_schedules
+                WHERE tenant_id = $1
+                ORDER BY name
+                LIMIT $2 OFFSET $3
+            """
+            rows = await conn.fetch(query, tenant_id, page_size, offset)
+
+        schedules = [self._row_to_schedule(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(schedules)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return schedules, result
+
+    @with_retry(idempotent=True)
+    async def names_using_credential(self, tenant_id: str, credential: str) -> List[str]:
+        """Return the names of a tenant's schedules that write with a credential."""
+        query = "SELECT name FROM export_schedules WHERE tenant_id = $1 AND credential = $2 ORDER BY name"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, tenant_id, credential)
+
+        return [row["name"] for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_due(self, now: datetime, limit: int) -> List[ExportSchedule]:
+        """Retrieve up to limit enabled schedules whose next run is due, most overdue first."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM export_schedules
+            WHERE enabled AND next_run_at <= $1
+            ORDER BY next_run_at
+            LIMIT $2
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, now, limit)
+
+        return [self._row_to_schedule(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def claim(self, id: str, due_at: datetime, next_run_at: datetime) -> bool:
+        """
+        Move a due schedule's next run on, unless another server already did;
+        returns whether this call claimed the due run.
+        """
+        query = """
+            UPDATE export_schedules SET next_run_at = $3
+            WHERE id = $1 AND enabled AND next_run_at = $2
+        """
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, id, due_at, next_run_at)
+
+        return result == "UPDATE 1"
+
+    @with_retry()
+    async def start_run(self, schedule_id: str) -> ExportRun:
+        """Record that a run of a schedule started."""
+        query = f"""
+            INSERT INTO export_runs (id, schedule_id, status, started_at)
+            VALUES ($1, $2, 'running', NOW())
+            RETURNING {_RUN_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, str(uuid.uuid4()), schedule_id)
+
+        return self._row_to_run(row)
+
+    @with_retry()
+    async def finish_run(self, run: ExportRun, keep_runs: int) -> ExportRun:
+        """
+        Record how a run ended, count the schedule's consecutive failures and
+        prune its history to the keep_runs most recent runs.
+        """
+        query = f"""
+            UPDATE export_runs
+            SET status = $2, location = $3, node_count = $4, relationship_count = $5, size_bytes = $6,
+                error = $7, finished_at = NOW()
+            WHERE id = $1
+            RETURNING {_RUN_COLUMNS}
+        """
+        failures = """
+            UPDATE export_schedules
+            SET consecutive_failures = CASE WHEN $2 = 'failed' THEN consecutive_failures + 1 ELSE 0 END
+            WHERE id = $1
+        """
+        prune = """
+            DELETE FROM export_runs
+            WHERE schedule_id = $1 AND id NOT IN (
+                SELECT id FROM export_runs WHERE schedule_id = $1 ORDER BY started_at DESC LIMIT $2
+            )
+        """
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                row = await conn.fetchrow(
+                    query,
+                    run.id, run.status, run.location, run.node_count, run.relationship_count, run.size_bytes,
+                    run.error
+                )
+                if not row:
+                    raise NotFoundError(f"export run not found: {run.id}")
+                await conn.execute(failures, run.schedule_id, run.status)
+                await conn.execute(prune, run.schedule_id, keep_runs)
+
+        return self._row_to_run(row)
+
+    @with_retry(idempotent=True)
+    async def list_runs(self, schedule_id: str, opts: ListOptions) -> Tuple[List[ExportRun], ListResult]:
+        """Retrieve a schedule's runs, most recent first, with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM export_runs WHERE schedule_id = $1", schedule_id)
+
+            query = f"""
+                SELECT {_RUN_COLUMNS}
+                FROM export_runs
+                WHERE schedule_id = $1
+                ORDER BY started_at DESC, id
+                LIMIT $2 OFFSET $3
+            """
+            rows = await conn.fetch(query, schedule_id, page_size, offset)
+
+        runs = [self._row_to_run(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(runs)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return runs, result
+
+    @with_retry(idempotent=True)
+    async def fail_abandoned_runs(self, started_before: datetime) -> int:
+        """Mark runs still running since before a time (their server stopped) failed; returns how many."""
+        query = """
+            UPDATE export_runs SET status = 'failed', error = 'abandoned: the server running it stopped',
+                finished_at = NOW()
+            WHERE status = 'running' AND started_at < $1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, started_before)
+
+        return int(result.split()[-1])
+
+    def _row_to_schedule(self, row: asyncpg.Record) -> ExportSchedule:
+        """Convert a database row to an ExportSchedule object."""
+        return ExportSchedule(
+            id=str(row["id"]),
+            tenant_id=str(row["tenant_id"]),
+            name=row["name"],
+            cron=row["cron"],
+            format=row["format"],
+            destination=row["destination"],
+            filter=json.loads(row["filter"]) if row["filter"] else {},
+            include_relationships=row["include_relationships"],
+            alert_url=row["alert_url"],
+            credential=row["credential"],
+            transform=json.loads(row["transform"]) if row["transform"] else {},
+            enabled=row["enabled"],
+            next_run_at=row["next_run_at"],
+            consecutive_failures=row["consecutive_failures"],
+            created_at=row["created_at"],
+            updated_at=row["updated_at"],
+        )
+
+    def _row_to_run(self, row: asyncpg.Record) -> ExportRun:
+        """Convert a database row to an ExportRun object."""
+        return ExportRun(
+            id=str(row["id"]),
+            schedule_id=str(row["schedule_id"]),
+            status=row["status"],
+            location=row["location"],
+            node_count=row["node_count"],
+            relationship_count=row["relationship_count"],
+            size_bytes=row["size_bytes"],
+            error=row["error"],
+            started_at=row["started_at"],
+            finished_at=row["finished_at"],
+        )
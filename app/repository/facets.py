@@ -0,0 +1,112 @@
+"""
+Distinct value (facet) queries shared by tenant repositories.
+"""
+
+import json
+from datetime import datetime
+from typing import Any, List, Optional, Sequence
+
+from app.db.database import Database
+from app.repository.models import FacetValue
+from app.repository.ordering import json_path_expression
+
+MAX_FACET_VALUES = 1000
+
+
+async def fetch_distinct_values(
+    db: Database,
+    table: str,
+    field: str,
+    columns: Sequence[str],
+    where: str,
+    args: List[Any],
+    limit: int,
+) -> List[FacetValue]:
+    """
+    Count rows per distinct value of a column or ``data.<path>`` expression.
+
+    Args:
+        db: Tenant database
+        table: Table to aggregate
+        field: Whitelisted column name or JSON data path (``data.a.b``)
+        columns: Columns that may be faceted
+        where: SQL predicate (without WHERE) using $1..$n placeholders
+        args: Values for the predicate placeholders
+        limit: Maximum number of distinct values to return
+
+    Values are ordered by descending count, then by value.
+    """
+    is_json = False
+    if field in columns:
+        expression = field
+    elif field.startswith("data."):
+        expression = f"({json_path_expression('data', field[len('data.'):])})"
+        is_json = True
+    else:
+        raise ValueError(f"cannot compute distinct values for field: {field}")
+
+    limit = max(1, min(limit or 100, MAX_FACET_VALUES))
+    value_sql = f"{expression}::text" if is_json else expression
+    query = f"""
+        SELECT {value_sql} AS value, COUNT(*) AS count
+        FROM {table}
+        WHERE {where or 'TRUE'} AND {expression} IS NOT NULL
+        GROUP BY {expression}
+        ORDER BY count DESC, value
+        LIMIT ${len(args) + 1}
+    """
+
+    async with db.pool.acquire() as conn:
+        rows = await conn.fetch(query, *args, limit)
+
+    values = []
+    for row in rows:
+        value = json.loads(row["value"]) if is_json else str(row["value"])
+        values.append(FacetValue(value=value, count=row["count"]))
+    return values
+
+
+async def fetch_date_histogram(
+    db: Database,
+    table: str,
+    column: str,
+    columns: Sequence[str],
+    where: str,
+    args: List[Any],
+    interval: str,
+    timezone: str,
+    start: Optional[datetime],
+    end: Optional[datetime],
+) -> List[FacetValue]:
+    """
+    Count rows per calendar interval of a timestamp column, in a time zone.
+
+    Args:
+        column: Whitelisted timestamp column
+        interval: date_trunc unit (hour, day, week, month or year)
+        timezone: IANA time zone the intervals are local to
+        start, end: Only rows with start <= column < end (either may be None)
+
+    Values are the local start of each interval (``YYYY-MM-DD``, or
+    ``YYYY-MM-DDTHH:00`` for hours), oldest first; empty intervals are omitted.
+    """
+    if column not in columns:
+        raise ValueError(f"cannot bucket by field: {column} (allowed: {', '.join(columns)})")
+
+    n = len(args)
+    label = "YYYY-MM-DD\"T\"HH24:00" if interval == "hour" else "YYYY-MM-DD"
+    query = f"""
+        SELECT to_char(date_trunc(${n + 1}, {column} AT TIME ZONE ${n + 2}), '{label}') AS value, COUNT(*) AS count
+        FROM {table}
+        WHERE {where or 'TRUE'}
+          AND (${n + 3}::timestamptz IS NULL OR {column} >= ${n + 3})
+          AND (${n + 4}::timestamptz IS NULL OR {column} < ${n + 4})
+        GROUP BY 1
+        ORDER BY 1
+        LIMIT {MAX_FACET_VALUES}
+    """
+
+    async with db.pool.acquire() as conn:
+        rows = await conn.fetch(query, *args, interval, timezone, start, end)
+
+    return [FacetValue(value=row["value"], count=row["count"]) for row in rows]
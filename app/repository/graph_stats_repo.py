@@ -0,0 +1,191 @@
+"""
+Graph statistics repository implementation.
+"""
+
+import json
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.db.database import Database
+from app.repository.retry import with_retry
+
+TOP_DEGREE_NODES = 10
+# Edges are streamed in batches when counting connected components
+EDGE_BATCH_SIZE = 10000
+
+_DEGREES_CTE = """
+    WITH degrees AS (
+        SELECT n.id, n.node_type_id,
+               COALESCE(o.c, 0) AS out_degree,
+               COALESCE(i.c, 0) AS in_degree,
+               COALESCE(o.c, 0) + COALESCE(i.c, 0) AS degree
+        FROM nodes n
+        LEFT JOIN (SELECT source_node_id AS id, COUNT(*) AS c FROM relationships GROUP BY 1) o ON o.id = n.id
+        LEFT JOIN (SELECT target_node_id AS id, COUNT(*) AS c FROM relationships GROUP BY 1) i ON i.id = n.id
+    )
+"""
+
+
+class GraphStatsRepository:
+    """Computes and caches graph statistics for a tenant database."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def get_snapshot(self) -> Optional[Tuple[Dict[str, Any], datetime]]:
+        """Return the cached statistics and when they were computed, if any."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow("SELECT stats::text, computed_at FROM graph_stats WHERE id = 1")
+        if not row:
+            return None
+        return json.loads(row[0]), row[1]
+
+    @with_retry()
+    async def save_snapshot(self, stats: Dict[str, Any]) -> datetime:
+        """Store statistics as the cached snapshot."""
+        query = """
+            INSERT INTO graph_stats (id, stats, computed_at) VALUES (1, $1::jsonb, NOW())
+            ON CONFLICT (id) DO UPDATE SET stats = EXCLUDED.stats, computed_at = EXCLUDED.computed_at
+            RETURNING computed_at
+        """
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval(query, json.dumps(stats))
+
+    @with_retry(idempotent=True)
+    async def compute(self) -> Dict[str, Any]:
+        """Compute graph statistics from a consistent snapshot of the tenant database."""
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction(isolation="repeatable_read", readonly=True):
+                node_counts = await conn.fetch("""
+                    SELECT nt.id, nt.name, COUNT(n.id) AS count
+                    FROM node_types nt
+                    LEFT JOIN nodes n ON n.node_type_id = nt.id
+                    GROUP BY nt.id, nt.name
+                    ORDER BY count DESC, nt.name
+                """)
+                edge_counts = await conn.fetch("""
+                    SELECT relationship_type, COUNT(*) AS count
+                    FROM relationships
+                    GROUP BY relationship_type
+                    ORDER BY count DESC, relationship_type
+                """)
+                distribution = await conn.fetch(_DEGREES_CTE + """
+                    SELECT CASE WHEN degree = 0 THEN 0 ELSE FLOOR(LOG(2, degree))::int + 1 END AS bucket,
+                           COUNT(*) AS count, MIN(degree) AS min_degree, MAX(degree) AS max_degree
+                    FROM degrees
+                    GROUP BY bucket
+                    ORDER BY bucket
+                """)
+                top = await conn.fetch(_DEGREES_CTE + """
+                    SELECT id, node_type_id, degree, in_degree, out_degree
+                    FROM degrees
+                    WHERE degree > 0
+                    ORDER BY degree DESC, id
+                    LIMIT $1
+                """, TOP_DEGREE_NODES)
+                summary = await conn.fetchrow(_DEGREES_CTE + """
+                    SELECT COUNT(*) AS nodes, COALESCE(AVG(degree), 0) AS avg_degree,
+                           COALESCE(MAX(degree), 0) AS max_degree,
+                           COUNT(*) FILTER (WHERE degree = 0) AS isolated
+                    FROM degrees
+                """)
+                edge_total = await conn.fetchval("SELECT COUNT(*) FROM relationships")
+                components, largest = await self._connected_components(conn)
+
+        return {
+            "node_count": summary["nodes"],
+            "relationship_count": edge_total,
+            "nodes_by_type": [
+                {"node_type_id": str(r["id"]), "name": r["name"], "count": r["count"]} for r in node_counts
+            ],
+            "relationships_by_type": [
+                {"relationship_type": r["relationship_type"], "count": r["count"]} for r in edge_counts
+            ],
+            "degree": {
+                "average": float(summary["avg_degree"]),
+                "max": summary["max_degree"],
+                "isolated_nodes": summary["isolated"],
+                # Buckets are powers of two: [0], [1], [2-3], [4-7], ...
+                "distribution": [
+                    {"min_degree": r["min_degree"], "max_degree": r["max_degree"], "count": r["count"]}
+                    for r in distribution
+                ],
+            },
+            "top_degree_nodes": [
+                {
+                    "node_id": str(r["id"]),
+                    "node_type_id": str(r["node_type_id"]),
+                    "degree": r["degree"],
+                    "in_degree": r["in_degree"],
+                    "out_degree": r["out_degree"],
+                }
+                for r in top
+            ],
+            "connected_components": {"count": components, "largest_size": largest},
+        }
+
+    @with_retry(idempotent=True)
+    async def load_graph(self, rel_types: List[str]) -> Tuple[List[str], List[Tuple[int, int]]]:
+        """
+        Load the node IDs and the (source, target) index pairs of the
+        relationships of any of rel_types (all types if empty) from a
+        consistent snapshot, for in-memory graph algorithms.
+        """
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction(isolation="repeatable_read", readonly=True):
+                node_ids: List[str] = []
+                index: Dict[str, int] = {}
+                async for row in conn.cursor("SELECT id::text FROM nodes ORDER BY id", prefetch=EDGE_BATCH_SIZE):
+                    index[row[0]] = len(node_ids)
+                    node_ids.append(row[0])
+
+                edges: List[Tuple[int, int]] = []
+                async for row in conn.cursor(
+                    """
+                    SELECT source_node_id::text, target_node_id::text FROM relationships
+                    WHERE cardinality($1::text[]) = 0 OR relationship_type = ANY($1::text[])
+                    """,
+                    rel_types,
+                    prefetch=EDGE_BATCH_SIZE,
+                ):
+                    if row[0] in index and row[1] in index:
+                        edges.append((index[row[0]], index[row[1]]))
+
+        return node_ids, edges
+
+    async def _connected_components(self, conn) -> Tuple[int, int]:
+        """Count weakly connected components with union-find over streamed edges."""
+        parent: Dict[str, str] = {}
+        size: Dict[str, int] = {}
+
+        def find(x: str) -> str:
+            root = x
+            while parent[root] != root:
+                root = parent[root]
+            while parent[x] != root:
+                parent[x], x = root, parent[x]
+            return root
+
+        async for row in conn.cursor("SELECT id::text FROM nodes", prefetch=EDGE_BATCH_SIZE):
+            parent[row[0]] = row[0]
+            size[row[0]] = 1
+
+        components = len(parent)
+        async for row in conn.cursor(
+            "SELECT source_node_id::text, target_node_id::text FROM relationships",
+            prefetch=EDGE_BATCH_SIZE,
+        ):
+            if row[0] not in parent or row[1] not in parent:
+                continue
+            a, b = find(row[0]), find(row[1])
+            if a == b:
+                continue
+            if size[a] < size[b]:
+                a, b = b, a
+            parent[b] = a
+            size[a] += size[b]
+            components -= 1
+
+        largest = max((size[r] for r in parent if parent[r] == r), default=0)
+        return components, largest
@@ -0,0 +1,98 @@
+"""
+Impersonation token repository implementation.
+"""
+
+import uuid
+from datetime import datetime
+from typing import Optional
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import ImpersonationToken
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, admin_user_id, tenant_id, user_id, reason, expires_at, revoked_at, created_at"
+
+
+class ImpersonationRepository:
+    """PostgreSQL impersonation token repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, token: ImpersonationToken, token_hash: str) -> ImpersonationToken:
+        """Create a new impersonation grant stored under the hash of its bearer token."""
+        token.id = str(uuid.uuid4())
+        token.created_at = datetime.now()
+
+        query = f"""
+            INSERT INTO impersonation_tokens (id, token_hash, admin_user_id, tenant_id, user_id, reason, expires_at, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                token.id, token_hash, token.admin_user_id, token.tenant_id, token.user_id,
+                token.reason, token.expires_at, token.created_at
+            )
+
+        return self._row_to_token(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> ImpersonationToken:
+        """Retrieve an impersonation grant by ID."""
+        query = f"SELECT {_COLUMNS} FROM impersonation_tokens WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"impersonation not found: {id}")
+
+        return self._row_to_token(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_token_hash(self, token_hash: str) -> Optional[ImpersonationToken]:
+        """Retrieve the grant for a bearer token hash, or None if there is none."""
+        query = f"SELECT {_COLUMNS} FROM impersonation_tokens WHERE token_hash = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, token_hash)
+
+        return self._row_to_token(row) if row else None
+
+    @with_retry()
+    async def revoke(self, id: str) -> ImpersonationToken:
+        """Revoke a grant (a no-op if it is already revoked)."""
+        query = f"""
+            UPDATE impersonation_tokens
+            SET revoked_at = COALESCE(revoked_at, NOW())
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"impersonation not found: {id}")
+
+        return self._row_to_token(row)
+
+    def _row_to_token(self, row: asyncpg.Record) -> ImpersonationToken:
+        """Convert a database row to an ImpersonationToken object."""
+        return ImpersonationToken(
+            id=str(row["id"]),
+            admin_user_id=row["admin_user_id"],
+            tenant_id=str(row["tenant_id"]),
+            user_id=row["user_id"],
+            reason=row["reason"],
+            expires_at=row["expires_at"],
+            revoked_at=row["revoked_at"],
+            created_at=row["created_at"],
+        )
@@ -0,0 +1,1037 @@
+"""
+In-memory repository implementations.
+
+The memory repositories implement the core tenant repositories (node types,
+nodes, relationships and relationship types) over Python dictionaries, so
+the services can run without PostgreSQL: in unit tests, or when flex-db is
+embedded as a library. The repositories of one tenant share a MemoryStore,
+which holds the data and a lock; every method runs under the lock, so the
+store is safe to use from several threads and event loops at once.
+
+They behave like the PostgreSQL repositories: list pagination uses the same
+offset page tokens and order_by syntax (ties broken by ID, so pages neither
+skip nor repeat entries while the data is unchanged), filters use JSON
+containment, optimistic concurrency raises PreconditionFailedError, and
+deleting a node type or node cascades to its nodes and relationships. As
+with the node history table, the IDs of deleted nodes cannot be reused.
+
+Not supported: node history (bi-temporal versions and corrections), change
+feeds, derived relationship types and encrypted or compressed storage;
+those methods raise NotImplementedError.
+"""
+
+import copy
+import json
+import threading
+import uuid
+from datetime import datetime, timedelta, timezone
+from zoneinfo import ZoneInfo
+from typing import Any, Callable, Dict, Iterable, List, Optional, Sequence, Set, Tuple, TypeVar
+
+from app.repository.errors import AlreadyExistsError, NotFoundError, PreconditionFailedError
+from app.repository.facets import MAX_FACET_VALUES
+from app.repository.models import (
+    DataRange,
+    FacetValue,
+    ListOptions,
+    ListResult,
+    MetadataUpdate,
+    Node,
+    NodeFilter,
+    NodeType,
+    Relationship,
+    RelationshipFilter,
+    RelationshipType,
+)
+from app.repository.node_repo import (
+    DATE_COLUMNS,
+    FACET_COLUMNS as NODE_FACET_COLUMNS,
+    SORTABLE_COLUMNS as NODE_SORTABLE_COLUMNS,
+)
+from app.repository.nodetype_repo import SORTABLE_COLUMNS as NODE_TYPE_SORTABLE_COLUMNS
+from app.repository.ordering import parse_order_by
+from app.repository.relationship_repo import (
+    FACET_COLUMNS as RELATIONSHIP_FACET_COLUMNS,
+    SORTABLE_COLUMNS as RELATIONSHIP_SORTABLE_COLUMNS,
+)
+from app.repository.relationship_type_repo import SORTABLE_COLUMNS as RELATIONSHIP_TYPE_SORTABLE_COLUMNS
+
+T = TypeVar("T")
+
+_MISSING = object()
+
+
+class MemoryStore:
+    """The data of one tenant, shared by its memory repositories."""
+
+    def __init__(self):
+        self.lock = threading.RLock()
+        self.node_types: Dict[str, NodeType] = {}
+        self.nodes: Dict[str, Node] = {}
+        # IDs of deleted nodes, which cannot be reused
+        self.deleted_node_ids: Set[str] = set()
+        self.relationships: Dict[str, Relationship] = {}
+        self.relationship_types: Dict[str, RelationshipType] = {}
+        self._last_time = datetime.min
+
+    def now(self) -> datetime:
+        """The current time, strictly increasing so every write gets a distinct updated_at (and etag)."""
+        with self.lock:
+            now = datetime.now()
+            if now <= self._last_time:
+                now = self._last_time + timedelta(microseconds=1)
+            self._last_time = now
+            return now
+
+
+def json_contains(value: Any, contained: Any) -> bool:
+    """JSON containment as PostgreSQL's jsonb @> operator evaluates it."""
+    if isinstance(contained, dict):
+        return isinstance(value, dict) and all(
+            k in value and json_contains(value[k], v) for k, v in contained.items()
+        )
+    if isinstance(contained, list):
+        return isinstance(value, list) and all(
+            any(json_contains(item, c) for item in value) for c in contained
+        )
+    if isinstance(value, list):
+        # A scalar is contained in an array holding it
+        return any(_json_equal(item, contained) for item in value)
+    return _json_equal(value, contained)
+
+
+def _json_equal(a: Any, b: Any) -> bool:
+    # Python treats True == 1; JSON does not
+    return isinstance(a, bool) == isinstance(b, bool) and a == b
+
+
+def _data(entity: Any) -> Any:
+    try:
+        return json.loads(entity.data or "{}")
+    except ValueError:
+        return {}
+
+
+def _path_value(value: Any, path: Sequence[str]) -> Any:
+    for segment in path:
+        if isinstance(value, dict):
+            value = value.get(segment, _MISSING)
+        elif isinstance(value, list) and segment.lstrip("-").isdigit():
+            index = int(segment)
+            value = value[index] if -len(value) <= index < len(value) else _MISSING
+        else:
+            return _MISSING
+        if value is _MISSING:
+            return _MISSING
+    return value
+
+
+def _json_sort_key(value: Any) -> Tuple:
+    """Order JSON values as jsonb does: null < strings < numbers < booleans < arrays < objects."""
+    if value is None:
+        return (0,)
+    if isinstance(value, str):
+        return (1, value)
+    if isinstance(value, bool):
+        return (3, value)
+    if isinstance(value, (int, float)):
+        return (2, value)
+    if isinstance(value, list):
+        return (4, len(value), json.dumps(value, sort_keys=True))
+    return (5, len(value), json.dumps(value, sort_keys=True))
+
+
+def _field_getter(name: str) -> Callable[[Any], Any]:
+    """Read a sortable column or ``data.<path>`` of an entity; _MISSING when absent (SQL NULL)."""
+    if name.startswith("data."):
+        path = name[len("data."):].split(".")
+        return lambda entity: _path_value(_data(entity), path)
+    return lambda entity: getattr(entity, name)
+
+
+def _sorted(
+    items: Iterable[T],
+    order_by: str,
+    columns: Sequence[str],
+    json_column: Optional[str] = "data",
+    default: Tuple[str, bool] = ("created_at", True),
+) -> List[T]:
+    """
+    Sort entities as build_order_by orders rows: by the order_by fields
+    (ascending with nulls first, descending with nulls last), by default
+    without them, then by ID.
+    """
+    # Validates the fields exactly as the PostgreSQL repositories do
+    parse_order_by(order_by, columns, json_column)
+    fields: List[Tuple[str, bool]] = []
+    for part in (order_by or "").split(","):
+        tokens = part.split()
+        if tokens:
+            fields.append((tokens[0], len(tokens) == 2 and tokens[1].lower() == "desc"))
+    if not fields:
+        fields = [default]
+
+    result = sorted(items, key=lambda entity: entity.id)
+    # Stable sorts from the least significant field up
+    for name, descending in reversed(fields):
+        getter = _field_getter(name)
+        is_json = name.startswith("data.")
+
+        def key(entity: Any, getter=getter, is_json=is_json) -> Tuple:
+            value = getter(entity)
+            if value is _MISSING:
+                return (0,)
+            return (1, _json_sort_key(value) if is_json else value)
+
+        result.sort(key=key, reverse=descending)
+    return result
+
+
+def _page(items: List[T], opts: ListOptions) -> Tuple[List[T], ListResult]:
+    """Cut one page out of sorted entities, with the offset page tokens of the PostgreSQL repositories."""
+    offset = 0
+    if opts.page_token:
+        try:
+            offset = max(int(opts.page_token), 0)
+        except ValueError:
+            offset = 0
+    page = items[offset:offset + opts.effective_page_size()]
+
+    result = ListResult(total_count=len(items))
+    next_offset = offset + len(page)
+    if next_offset < len(items):
+        result.next_page_token = str(next_offset)
+    return page, result
+
+
+def _distinct_values(
+    items: Iterable[Any], field: str, columns: Sequence[str], limit: int
+) -> List[FacetValue]:
+    """Count entities per distinct value of a column or data path, as fetch_distinct_values does."""
+    if field in columns:
+        column = _field_getter(field)
+        getter = lambda entity: str(column(entity))
+    elif field.startswith("data."):
+        parse_order_by(field, (), "data")
+        getter = _field_getter(field)
+    else:
+        raise ValueError(f"cannot compute distinct values for field: {field}")
+
+    counts: Dict[str, Tuple[Any, int]] = {}
+    for entity in items:
+        value = getter(entity)
+        if value is _MISSING or value is None:
+            continue
+        key = json.dumps(value, sort_keys=True)
+        counts[key] = (value, counts.get(key, (value, 0))[1] + 1)
+
+    limit = max(1, min(limit or 100, MAX_FACET_VALUES))
+    ordered = sorted(counts.items(), key=lambda item: (-item[1][1], item[0]))
+    return [FacetValue(value=value, count=count) for _, (value, count) in ordered[:limit]]
+
+
+def _date_histogram(
+    items: Iterable[Any],
+    column: str,
+    interval: str,
+    timezone: str,
+    start: Optional[datetime],
+    end: Optional[datetime],
+) -> List[FacetValue]:
+    """Count entities per local calendar interval of a timestamp, as fetch_date_histogram does."""
+    if column not in DATE_COLUMNS:
+        raise ValueError(f"cannot bucket by field: {column} (allowed: {', '.join(DATE_COLUMNS)})")
+
+    zone = ZoneInfo(timezone)
+    counts: Dict[str, int] = {}
+    for entity in items:
+        # Naive times are in the server's local time zone, as PostgreSQL reads them
+        at = getattr(entity, column).astimezone(zone)
+        if (start and at < start) or (end and at >= end):
+            continue
+        if interval == "hour":
+            label = at.strftime("%Y-%m-%dT%H:00")
+        else:
+            day = at.date()
+            if interval == "week":
+                day -= timedelta(days=day.weekday())
+            elif interval == "month":
+                day = day.replace(day=1)
+            elif interval == "year":
+                day = day.replace(month=1, day=1)
+            label = day.isoformat()
+        counts[label] = counts.get(label, 0) + 1
+    return [FacetValue(value=label, count=counts[label]) for label in sorted(counts)[:MAX_FACET_VALUES]]
+
+
+def _valid_at(rel: Relationship, at: Optional[datetime]) -> bool:
+    at = at or datetime.now()
+    return (rel.valid_from is None or rel.valid_from <= at) and (rel.valid_to is None or rel.valid_to > at)
+
+
+class MemoryNodeTypeRepository:
+    """In-memory node type repository."""
+
+    def __init__(self, store: Optional[MemoryStore] = None):
+        self.store = store or MemoryStore()
+
+    async def create(self, node_type: NodeType) -> NodeType:
+        """Create a new node type; a node_type.id set by the caller is used instead of a generated one."""
+        with self.store.lock:
+            if any(t.name == node_type.name for t in self.store.node_types.values()):
+                raise AlreadyExistsError(f"node_type already exists: {node_type.name}")
+            node_type.id = node_type.id or str(uuid.uuid4())
+            if node_type.id in self.store.node_types:
+                raise AlreadyExistsError(f"node_type already exists: {node_type.id}")
+            node_type.created_at = node_type.updated_at = self.store.now()
+            node_type.schema_version = 1
+            self.store.node_types[node_type.id] = copy.deepcopy(node_type)
+            return copy.deepcopy(node_type)
+
+    async def get_by_id(self, id: str) -> NodeType:
+        """Retrieve a node type by ID."""
+        with self.store.lock:
+            node_type = self.store.node_types.get(id)
+            if node_type is None:
+                raise NotFoundError(f"node_type not found: {id}")
+            return copy.deepcopy(node_type)
+
+    async def update(self, node_type: NodeType) -> NodeType:
+        """Update an existing node type."""
+        with self.store.lock:
+            stored = self.store.node_types.get(node_type.id)
+            if stored is None:
+                raise NotFoundError(f"node_type not found: {node_type.id}")
+            if any(t.name == node_type.name and t.id != node_type.id for t in self.store.node_types.values()):
+                raise AlreadyExistsError(f"node_type already exists: {node_type.name}")
+            stored.name = node_type.name
+            stored.description = node_type.description
+            stored.schema = node_type.schema
+            stored.updated_at = self.store.now()
+            return copy.deepcopy(stored)
+
+    async def delete(self, id: str) -> None:
+        """Delete a node type by ID, with its nodes and their relationships."""
+        with self.store.lock:
+            if self.store.node_types.pop(id, None) is None:
+                raise NotFoundError(f"node_type not found: {id}")
+            ids = [n.id for n in self.store.nodes.values() if n.node_type_id == id]
+            _remove_nodes(self.store, ids)
+
+    async def list(self, opts: ListOptions) -> Tuple[List[NodeType], ListResult]:
+        """Retrieve node types with pagination."""
+        with self.store.lock:
+            ordered = _sorted(self.store.node_types.values(), opts.order_by, NODE_TYPE_SORTABLE_COLUMNS, None)
+            page, result = _page(ordered, opts)
+            return [copy.deepcopy(t) for t in page], result
+
+
+def _remove_nodes(store: MemoryStore, ids: Iterable[str]) -> None:
+    """Remove nodes and the relationships touching them; the caller holds the lock."""
+    removed = set()
+    for id in ids:
+        if store.nodes.pop(id, None) is not None:
+            removed.add(id)
+    store.deleted_node_ids |= removed
+    for rel_id in [
+        r.id for r in store.relationships.values()
+        if r.source_node_id in removed or r.target_node_id in removed
+    ]:
+        del store.relationships[rel_id]
+
+
+def _node_matches(node: Node, filters: NodeFilter) -> bool:
+    if filters.node_type_id and node.node_type_id != filters.node_type_id:
+        return False
+    if filters.data_contains and not json_contains(_data(node), filters.data_contains):
+        return False
+    if filters.metadata_contains and not json_contains(node.metadata, filters.metadata_contains):
+        return False
+    if filters.created_after and node.created_at < filters.created_after:
+        return False
+    if filters.created_before and node.created_at >= filters.created_before:
+        return False
+    if filters.updated_after and node.updated_at < filters.updated_after:
+        return False
+    if filters.updated_before and node.updated_at >= filters.updated_before:
+        return False
+    data = _data(node) if filters.data_ranges else None
+    return all(_in_range(data, r) for r in filters.data_ranges or [])
+
+
+def _in_range(data: Any, data_range: DataRange) -> bool:
+    """Evaluate a data range like data_range_condition in node_repo.py."""
+    value = data
+    for segment in data_range.path:
+        value = value.get(segment) if isinstance(value, dict) else None
+    bound = data_range.value
+    if data_range.kind == "number":
+        if isinstance(value, bool) or not isinstance(value, (int, float)):
+            return False
+        bound = float(bound)
+    elif not isinstance(value, str):
+        return False
+    elif data_range.kind == "timestamp":
+        try:
+            value = datetime.fromisoformat(value.replace("Z", "+00:00"))
+        except ValueError:
+            return False
+        value = value if value.tzinfo else value.replace(tzinfo=timezone.utc)
+    return _COMPARISONS[data_range.op](value, bound)
+
+
+_COMPARISONS: Dict[str, Callable[[Any, Any], bool]] = {
+    "gt": lambda a, b: a > b, "gte": lambda a, b: a >= b, "lt": lambda a, b: a < b, "lte": lambda a, b: a <= b,
+}
+
+
+class MemoryNodeRepository:
+    """In-memory node repository."""
+
+    def __init__(self, store: Optional[MemoryStore] = None):
+        self.store = store or MemoryStore()
+
+    def _copy(self, node: Node, include_data: bool = True) -> Node:
+        """A copy of a stored node for callers, with the current schema version of its type."""
+        node_type = self.store.node_types.get(node.node_type_id)
+        return Node(
+            id=node.id,
+            node_type_id=node.node_type_id,
+            data=node.data if include_data else "{}",
+            created_at=node.created_at,
+            updated_at=node.updated_at,
+            metadata=copy.deepcopy(node.metadata),
+            schema_version=node.schema_version,
+            latest_schema_version=node_type.schema_version if node_type else 0,
+        )
+
+    def _get(self, id: str, expected_updated_at: Optional[datetime] = None) -> Node:
+        node = self.store.nodes.get(id)
+        if node is None:
+            raise NotFoundError(f"node not found: {id}")
+        if expected_updated_at and node.updated_at != expected_updated_at:
+            raise PreconditionFailedError(f"node was modified concurrently: {id}")
+        return node
+
+    async def create(self, node: Node, valid_from: Optional[datetime] = None) -> Node:
+        """
+        Create a new node; a node.id set by the caller is used instead of a generated one.
+
+        Raises:
+            AlreadyExistsError: If a node with that ID exists or existed
+        """
+        with self.store.lock:
+            node.id = node.id or str(uuid.uuid4())
+            if node.id in self.store.nodes or node.id in self.store.deleted_node_ids:
+                raise AlreadyExistsError(f"node already exists: {node.id}")
+            if node.node_type_id not in self.store.node_types:
+                raise NotFoundError(f"node_type not found: {node.node_type_id}")
+            node.created_at = node.updated_at = self.store.now()
+            node.data = node.data or "{}"
+            stored = self._copy(node)
+            self.store.nodes[node.id] = stored
+            return self._copy(stored)
+
+    async def get_by_id(self, id: str) -> Node:
+        """Retrieve a node by ID."""
+        with self.store.lock:
+            return self._copy(self._get(id))
+
+    async def get_many(self, ids: List[str]) -> List[Node]:
+        """Retrieve the nodes with the given IDs that exist."""
+        with self.store.lock:
+            return [self._copy(self.store.nodes[id]) for id in dict.fromkeys(ids) if id in self.store.nodes]
+
+    async def get_node_type_ids(self, ids: List[str]) -> Dict[str, str]:
+        """Map the IDs of the given nodes that exist to their node type IDs."""
+        with self.store.lock:
+            return {id: self.store.nodes[id].node_type_id for id in ids if id in self.store.nodes}
+
+    async def update(
+        self,
+        node: Node,
+        valid_from: Optional[datetime] = None,
+        expected_updated_at: Optional[datetime] = None,
+        metadata: Optional[MetadataUpdate] = None
+    ) -> Node:
+        """Update an existing node's data, optionally only if unchanged since expected_updated_at."""
+        with self.store.lock:
+            stored = self._get(node.id, expected_updated_at)
+            stored.data = node.data or "{}"
+            stored.schema_version = node.schema_version
+            if metadata is not None:
+                stored.metadata = _apply_metadata(stored.metadata, metadata)
+            stored.updated_at = self.store.now()
+            updated = self._copy(stored)
+            node.updated_at = updated.updated_at
+            return updated
+
+    async def update_metadata(
+        self,
+        id: str,
+        metadata: MetadataUpdate,
+        expected_updated_at: Optional[datetime] = None
+    ) -> Node:
+        """Update only a node's metadata; its data and etag are unchanged."""
+        with self.store.lock:
+            stored = self._get(id, expected_updated_at)
+            stored.metadata = _apply_metadata(stored.metadata, metadata)
+            return self._copy(stored)
+
+    async def set_metadata_values(self, key: str, name: str, values: List[Tuple[str, Any]]) -> int:
+        """Set metadata[key][name] on each of the nodes; returns how many nodes still existed."""
+        with self.store.lock:
+            stored = 0
+            for id, value in values:
+                node = self.store.nodes.get(id)
+                if node is None:
+                    continue
+                section = node.metadata.get(key)
+                if not isinstance(section, dict):
+                    section = node.metadata[key] = {}
+                section[name] = copy.deepcopy(value)
+                stored += 1
+            return stored
+
+    async def list_top_by_metadata_score(
+        self, key: str, name: str, limit: int, node_type_id: str = ""
+    ) -> List[Tuple[Node, float]]:
+        """Retrieve the nodes with the highest numeric metadata[key][name], highest first."""
+        with self.store.lock:
+            scored = []
+            for node in self.store.nodes.values():
+                if node_type_id and node.node_type_id != node_type_id:
+                    continue
+                score = _path_value(node.metadata, [key, name])
+                if isinstance(score, (int, float)) and not isinstance(score, bool):
+                    scored.append((node, float(score)))
+            scored.sort(key=lambda item: (-item[1], item[0].id))
+            return [(self._copy(node), score) for node, score in scored[:limit]]
+
+    async def count_by_metadata_value(
+        self, key: str, name: str, limit: int, min_count: int = 1
+    ) -> List[Tuple[str, int]]:
+        """Count nodes per distinct metadata[key][name], largest first."""
+        with self.store.lock:
+            counts: Dict[str, int] = {}
+            for node in self.store.nodes.values():
+                value = _path_value(node.metadata, [key, name])
+                if value is _MISSING or value is None:
+                    continue
+                value = value if isinstance(value, str) else json.dumps(value)
+                counts[value] = counts.get(value, 0) + 1
+            ordered = sorted(
+                ((value, count) for value, count in counts.items() if count >= min_count),
+                key=lambda item: (-item[1], item[0]),
+            )
+            return ordered[:limit]
+
+    async def increment_field(
+        self,
+        id: str,
+        path: List[str],
+        delta: float,
+        valid_from: Optional[datetime] = None,
+    ) -> Tuple[Node, float]:
+        """
+        Atomically add delta to the number at path in a node's data (a missing
+        field counts as 0 and missing parent objects are created).
+
+        Raises:
+            ValueError: If the field, or an object on its path, has another type
+        """
+        with self.store.lock:
+            stored = self._get(id)
+            data = _data(stored)
+            container = data
+            for i, token in enumerate(path[:-1]):
+                container = container.setdefault(token, {})
+                if not isinstance(container, dict):
+                    raise ValueError(f"data.{'.'.join(path[:i + 1])} is not an object")
+            current = container.get(path[-1], 0)
+            if isinstance(current, bool) or not isinstance(current, (int, float)):
+                raise ValueError(f"data.{'.'.join(path)} is not a number")
+            value = current + delta
+            container[path[-1]] = value
+
+            stored.data = json.dumps(data)
+            stored.updated_at = self.store.now()
+            return self._copy(stored), value
+
+    async def delete(self, id: str) -> None:
+        """Delete a node by ID, with its relationships."""
+        with self.store.lock:
+            self._get(id)
+            _remove_nodes(self.store, [id])
+
+    async def delete_many(self, ids: List[str]) -> None:
+        """Delete several nodes atomically, with their relationships."""
+        with self.store.lock:
+            missing = [id for id in ids if id not in self.store.nodes]
+            if missing:
+                raise NotFoundError(f"node not found: {missing[0]}")
+            _remove_nodes(self.store, ids)
+
+    async def list(
+        self,
+        node_type_id: Optional[str],
+        opts: ListOptions,
+        include_data: bool = True,
+        metadata_contains: Optional[Dict[str, Any]] = None,
+        filters: Optional[NodeFilter] = None,
+    ) -> Tuple[List[Node], ListResult]:
+        """Retrieve nodes with pagination and optional filtering."""
+        filters = filters or NodeFilter(node_type_id=node_type_id or "", metadata_contains=metadata_contains)
+        with self.store.lock:
+            matching = [n for n in self.store.nodes.values() if _node_matches(n, filters)]
+            page, result = _page(_sorted(matching, opts.order_by, NODE_SORTABLE_COLUMNS), opts)
+            return [self._copy(n, include_data) for n in page], result
+
+    async def count_matching(self, filters: NodeFilter) -> int:
+        """Count nodes matching a bulk operation filter."""
+        with self.store.lock:
+            return sum(1 for n in self.store.nodes.values() if _node_matches(n, filters))
+
+    async def list_ids_matching(self, filters: NodeFilter, limit: int) -> List[str]:
+        """Retrieve the IDs of up to limit nodes matching a bulk operation filter, oldest first."""
+        with self.store.lock:
+            matching = [n for n in self.store.nodes.values() if _node_matches(n, filters)]
+            matching.sort(key=lambda n: (n.created_at, n.id))
+            return [n.id for n in matching[:limit]]
+
+    async def list_matching(
+        self, filters: NodeFilter, after_id: str, limit: int, changed_since: str = ""
+    ) -> List[Node]:
+        """Retrieve up to limit nodes matching a filter, with data, ordered by ID after after_id."""
+        if changed_since:
+            raise NotImplementedError("the memory node repository has no change feed")
+        with self.store.lock:
+            matching = sorted(
+                (n for n in self.store.nodes.values() if n.id > after_id and _node_matches(n, filters)),
+                key=lambda n: n.id,
+            )
+            return [self._copy(n) for n in matching[:limit]]
+
+    async def count_below_schema_version(self, node_type_id: str, schema_version: int) -> int:
+        """Count nodes of a type whose data is shaped for an older schema version."""
+        with self.store.lock:
+            return sum(
+                1 for n in self.store.nodes.values()
+                if n.node_type_id == node_type_id and n.schema_version < schema_version
+            )
+
+    async def list_below_schema_version(self, node_type_id: str, schema_version: int, limit: int) -> List[Node]:
+        """Retrieve up to limit nodes of a type whose data is shaped for an older schema version."""
+        with self.store.lock:
+            matching = sorted(
+                (n for n in self.store.nodes.values()
+                 if n.node_type_id == node_type_id and n.schema_version < schema_version),
+                key=lambda n: n.id,
+            )
+            return [self._copy(n) for n in matching[:limit]]
+
+    async def store_migrated_data(self, node: Node, from_version: int) -> bool:
+        """Store data migrated to node.schema_version, unless the node was written since it was read."""
+        with self.store.lock:
+            stored = self.store.nodes.get(node.id)
+            if stored is None or stored.schema_version != from_version or stored.updated_at != node.updated_at:
+                return False
+            stored.data = node.data or "{}"
+            stored.schema_version = node.schema_version
+            return True
+
+    async def distinct_values(self, field: str, node_type_id: Optional[str], limit: int) -> List[FacetValue]:
+        """Count nodes per distinct value of a column or JSON data path."""
+        with self.store.lock:
+            nodes = [n for n in self.store.nodes.values() if not node_type_id or n.node_type_id == node_type_id]
+            return _distinct_values(nodes, field, NODE_FACET_COLUMNS, limit)
+
+    async def date_histogram(
+        self,
+        field: str,
+        node_type_id: Optional[str],
+        interval: str,
+        timezone: str,
+        start: Optional[datetime],
+        end: Optional[datetime],
+    ) -> List[FacetValue]:
+        """Count nodes per local calendar interval of a timestamp column."""
+        with self.store.lock:
+            nodes = [n for n in self.store.nodes.values() if not node_type_id or n.node_type_id == node_type_id]
+            return _date_histogram(nodes, field, interval, timezone, start, end)
+
+    async def record_correction(self, *args, **kwargs):
+        raise NotImplementedError("the memory node repository keeps no node history")
+
+    async def get_as_of(self, *args, **kwargs):
+        raise NotImplementedError("the memory node repository keeps no node history")
+
+    async def list_as_of(self, *args, **kwargs):
+        raise NotImplementedError("the memory node repository keeps no node history")
+
+    async def list_versions(self, *args, **kwargs):
+        raise NotImplementedError("the memory node repository keeps no node history")
+
+
+def _apply_metadata(current: Dict[str, Any], update: MetadataUpdate) -> Dict[str, Any]:
+    if update.replace:
+        return copy.deepcopy(update.set)
+    merged = {**current, **copy.deepcopy(update.set)}
+    for key in update.remove:
+        merged.pop(key, None)
+    return merged
+
+
+def _relationship_matches(rel: Relationship, filters: RelationshipFilter) -> bool:
+    if filters.relationship_type and rel.relationship_type != filters.relationship_type:
+        return False
+    if filters.source_node_id and rel.source_node_id != filters.source_node_id:
+        return False
+    if filters.target_node_id and rel.target_node_id != filters.target_node_id:
+        return False
+    if filters.data_contains and not json_contains(_data(rel), filters.data_contains):
+        return False
+    if filters.created_after and rel.created_at < filters.created_after:
+        return False
+    if filters.created_before and rel.created_at >= filters.created_before:
+        return False
+    return True
+
+
+def _endpoints(rel: Relationship) -> Relationship:
+    """The endpoints and type (without data) of a relationship."""
+    return Relationship(
+        id=rel.id,
+        source_node_id=rel.source_node_id,
+        target_node_id=rel.target_node_id,
+        relationship_type=rel.relationship_type,
+    )
+
+
+class MemoryRelationshipRepository:
+    """In-memory relationship repository."""
+
+    def __init__(self, store: Optional[MemoryStore] = None):
+        self.store = store or MemoryStore()
+
+    def _get(self, id: str) -> Relationship:
+        rel = self.store.relationships.get(id)
+        if rel is None:
+            raise NotFoundError(f"relationship not found: {id}")
+        return rel
+
+    def _check_endpoints(self, rel: Relationship) -> None:
+        for node_id in (rel.source_node_id, rel.target_node_id):
+            if node_id not in self.store.nodes:
+                raise NotFoundError(f"node not found: {node_id}")
+
+    def _oldest_first(self, rels: Iterable[Relationship]) -> List[Relationship]:
+        return sorted(rels, key=lambda r: (r.created_at, r.id))
+
+    async def create(self, rel: Relationship) -> Relationship:
+        """
+        Create a new relationship; a rel.id set by the caller is used instead of a generated one.
+
+        Raises:
+            AlreadyExistsError: If a relationship with that ID exists
+        """
+        with self.store.lock:
+            rel.id = rel.id or str(uuid.uuid4())
+            if rel.id in self.store.relationships:
+                raise AlreadyExistsError(f"relationship already exists: {rel.id}")
+            self._check_endpoints(rel)
+            rel.created_at = rel.updated_at = self.store.now()
+            rel.data = rel.data or "{}"
+            self.store.relationships[rel.id] = copy.deepcopy(rel)
+            return copy.deepcopy(rel)
+
+    async def create_many(self, rels: List[Relationship]) -> None:
+        """Create several relationships atomically."""
+        with self.store.lock:
+            for rel in rels:
+                self._check_endpoints(rel)
+            now = self.store.now()
+            for rel in rels:
+                rel.id = str(uuid.uuid4())
+                rel.created_at = rel.updated_at = now
+                rel.data = rel.data or "{}"
+                self.store.relationships[rel.id] = copy.deepcopy(rel)
+
+    async def get_by_id(self, id: str) -> Relationship:
+        """Retrieve a relationship by ID."""
+        with self.store.lock:
+            return copy.deepcopy(self._get(id))
+
+    async def update(self, rel: Relationship, expected_updated_at: Optional[datetime] = None) -> Relationship:
+        """Update an existing relationship, optionally only if unchanged since expected_updated_at."""
+        with self.store.lock:
+            stored = self._get(rel.id)
+            if expected_updated_at and stored.updated_at != expected_updated_at:
+                raise PreconditionFailedError(f"relationship was modified concurrently: {rel.id}")
+            stored.relationship_type = rel.relationship_type
+            stored.data = rel.data or "{}"
+            stored.valid_from = rel.valid_from
+            stored.valid_to = rel.valid_to
+            stored.updated_at = rel.updated_at = self.store.now()
+            return copy.deepcopy(stored)
+
+    async def delete(self, id: str) -> None:
+        """Delete a relationship by ID."""
+        with self.store.lock:
+            self._get(id)
+            del self.store.relationships[id]
+
+    async def delete_many(self, ids: List[str]) -> int:
+        """Delete relationships by ID; returns how many existed."""
+        with self.store.lock:
+            return sum(1 for id in set(ids) if self.store.relationships.pop(id, None) is not None)
+
+    async def list(
+        self,
+        source_node_id: Optional[str],
+        target_node_id: Optional[str],
+        rel_type: Optional[str],
+        opts: ListOptions,
+        derived: Optional[RelationshipType] = None,
+        valid_at: Optional[datetime] = None,
+        dedupe: bool = False,
+    ) -> Tuple[List[Relationship], ListResult]:
+        """Retrieve relationships with pagination and optional filtering."""
+        if derived:
+            raise NotImplementedError("the memory relationship repository has no derived relationship types")
+        filters = RelationshipFilter(
+            relationship_type=rel_type or "", source_node_id=source_node_id or "", target_node_id=target_node_id or ""
+        )
+        with self.store.lock:
+            matching = [
+                copy.deepcopy(r) for r in self.store.relationships.values()
+                if _relationship_matches(r, filters) and (not valid_at or _valid_at(r, valid_at))
+            ]
+            if dedupe:
+                # Keep the oldest of each set of parallel relationships, counting the set
+                pairs: Dict[Tuple[str, str, str], Relationship] = {}
+                for rel in sorted(matching, key=lambda r: (r.created_at, r.id)):
+                    key = (rel.source_node_id, rel.target_node_id, rel.relationship_type)
+                    pairs.setdefault(key, rel).parallel_count += 1
+                matching = list(pairs.values())
+            page, result = _page(_sorted(matching, opts.order_by, RELATIONSHIP_SORTABLE_COLUMNS), opts)
+            return page, result
+
+    async def distinct_values(self, field: str, rel_type: Optional[str], limit: int) -> List[FacetValue]:
+        """Count relationships per distinct value of a column or JSON data path."""
+        with self.store.lock:
+            rels = [r for r in self.store.relationships.values() if not rel_type or r.relationship_type == rel_type]
+            return _distinct_values(rels, field, RELATIONSHIP_FACET_COLUMNS, limit)
+
+    async def date_histogram(
+        self,
+        field: str,
+        rel_type: Optional[str],
+        interval: str,
+        timezone: str,
+        start: Optional[datetime],
+        end: Optional[datetime],
+    ) -> List[FacetValue]:
+        """Count relationships per local calendar interval of a timestamp column."""
+        with self.store.lock:
+            rels = [r for r in self.store.relationships.values() if not rel_type or r.relationship_type == rel_type]
+            return _date_histogram(rels, field, interval, timezone, start, end)
+
+    async def count_matching(self, filters: RelationshipFilter) -> int:
+        """Count relationships matching a bulk operation filter."""
+        with self.store.lock:
+            return sum(1 for r in self.store.relationships.values() if _relationship_matches(r, filters))
+
+    async def list_ids_matching(self, filters: RelationshipFilter, limit: int) -> List[str]:
+        """Retrieve the IDs of up to limit relationships matching a bulk operation filter, oldest first."""
+        with self.store.lock:
+            matching = self._oldest_first(
+                r for r in self.store.relationships.values() if _relationship_matches(r, filters)
+            )
+            return [r.id for r in matching[:limit]]
+
+    async def count_by_type(self, rel_type: str) -> int:
+        """Count stored relationships of a type."""
+        with self.store.lock:
+            return sum(1 for r in self.store.relationships.values() if r.relationship_type == rel_type)
+
+    async def list_for_nodes(
+        self,
+        node_ids: List[str],
+        direction: str,
+        rel_type: Optional[str],
+        limit_per_node: int,
+        derived: Optional[RelationshipType] = None,
+        valid_at: Optional[datetime] = None,
+    ) -> List[Tuple[str, Relationship]]:
+        """Retrieve up to limit_per_node relationships of each node, oldest first, as (node_id, relationship) pairs."""
+        if derived:
+            raise NotImplementedError("the memory relationship repository has no derived relationship types")
+        with self.store.lock:
+            candidates = self._oldest_first(
+                r for r in self.store.relationships.values()
+                if (not rel_type or r.relationship_type == rel_type) and _valid_at(r, valid_at)
+            )
+            pairs = []
+            for node_id in sorted(set(node_ids)):
+                touching = [
+                    r for r in candidates
+                    if (direction in ("out", "both") and r.source_node_id == node_id)
+                    or (direction in ("in", "both") and r.target_node_id == node_id)
+                ]
+                pairs += [(node_id, copy.deepcopy(r)) for r in touching[:limit_per_node]]
+            return pairs
+
+    async def list_from_sources(self, node_ids: List[str]) -> List[Relationship]:
+        """Retrieve the relationships (with data) whose source is any of the nodes."""
+        ids = set(node_ids)
+        with self.store.lock:
+            matching = sorted(
+                (r for r in self.store.relationships.values() if r.source_node_id in ids),
+                key=lambda r: (r.source_node_id, r.created_at, r.id),
+            )
+            return [copy.deepcopy(r) for r in matching]
+
+    async def list_to_targets(self, node_ids: List[str]) -> List[Relationship]:
+        """Retrieve the relationships (with data) whose target is any of the nodes."""
+        ids = set(node_ids)
+        with self.store.lock:
+            matching = sorted(
+                (r for r in self.store.relationships.values() if r.target_node_id in ids),
+                key=lambda r: (r.target_node_id, r.created_at, r.id),
+            )
+            return [copy.deepcopy(r) for r in matching]
+
+    async def list_touching(self, node_ids: List[str]) -> List[Relationship]:
+        """Retrieve the endpoints and types (without data) of relationships touching any of the nodes."""
+        ids = set(node_ids)
+        with self.store.lock:
+            return [
+                _endpoints(r) for r in self.store.relationships.values()
+                if r.source_node_id in ids or r.target_node_id in ids
+            ]
+
+    async def list_adjacent(
+        self,
+        node_ids: List[str],
+        direction: str,
+        rel_types: List[str],
+        valid_at: Optional[datetime],
+        limit: int,
+    ) -> List[Relationship]:
+        """
+        Retrieve the endpoints and types (without data) of up to limit
+        relationships of any of the nodes, in the given direction and of any of
+        rel_types (all types if empty), valid at valid_at (default: now).
+        """
+        ids = set(node_ids)
+        with self.store.lock:
+            matching = self._oldest_first(
+                r for r in self.store.relationships.values()
+                if ((direction in ("out", "both") and r.source_node_id in ids)
+                    or (direction in ("in", "both") and r.target_node_id in ids))
+                and (not rel_types or r.relationship_type in rel_types)
+                and _valid_at(r, valid_at)
+            )
+            return [_endpoints(r) for r in matching[:limit]]
+
+    async def list_between(
+        self, node_ids: List[str], rel_types: List[str], valid_at: Optional[datetime]
+    ) -> List[Relationship]:
+        """Retrieve the relationships (with data) whose source and target are both among the nodes."""
+        ids = set(node_ids)
+        with self.store.lock:
+            matching = self._oldest_first(
+                r for r in self.store.relationships.values()
+                if r.source_node_id in ids and r.target_node_id in ids
+                and (not rel_types or r.relationship_type in rel_types)
+                and _valid_at(r, valid_at)
+            )
+            return [copy.deepcopy(r) for r in matching]
+
+    async def list_changed(self, *args, **kwargs):
+        raise NotImplementedError("the memory relationship repository has no change feed")
+
+    async def refresh_derived(self, rel_type: RelationshipType) -> int:
+        raise NotImplementedError("the memory relationship repository has no derived relationship types")
+
+    async def clear_derived(self, name: str) -> None:
+        """Remove the stored snapshot of a derived type (there never is one)."""
+
+
+class MemoryRelationshipTypeRepository:
+    """In-memory relationship type repository."""
+
+    def __init__(self, store: Optional[MemoryStore] = None):
+        self.store = store or MemoryStore()
+
+    def _get(self, id: str) -> RelationshipType:
+        rel_type = self.store.relationship_types.get(id)
+        if rel_type is None:
+            raise NotFoundError(f"relationship_type not found: {id}")
+        return rel_type
+
+    def _check_name(self, rel_type: RelationshipType) -> None:
+        if any(t.name == rel_type.name and t.id != rel_type.id for t in self.store.relationship_types.values()):
+            raise AlreadyExistsError(f"relationship_type already exists: {rel_type.name}")
+
+    async def create(self, rel_type: RelationshipType) -> RelationshipType:
+        """Create a new relationship type; a rel_type.id set by the caller is used instead of a generated one."""
+        with self.store.lock:
+            rel_type.id = rel_type.id or str(uuid.uuid4())
+            if rel_type.id in self.store.relationship_types:
+                raise AlreadyExistsError(f"relationship_type already exists: {rel_type.id}")
+            self._check_name(rel_type)
+            rel_type.created_at = rel_type.updated_at = self.store.now()
+            self.store.relationship_types[rel_type.id] = copy.deepcopy(rel_type)
+            return copy.deepcopy(rel_type)
+
+    async def get_by_id(self, id: str) -> RelationshipType:
+        """Retrieve a relationship type by ID."""
+        with self.store.lock:
+            return copy.deepcopy(self._get(id))
+
+    async def get_by_name(self, name: str) -> Optional[RelationshipType]:
+        """Retrieve a relationship type by name, or None if it is not registered."""
+        with self.store.lock:
+            for rel_type in self.store.relationship_types.values():
+                if rel_type.name == name:
+                    return copy.deepcopy(rel_type)
+            return None
+
+    async def update(self, rel_type: RelationshipType) -> RelationshipType:
+        """Update an existing relationship type."""
+        with self.store.lock:
+            stored = self._get(rel_type.id)
+            self._check_name(rel_type)
+            rel_type.created_at = stored.created_at
+            rel_type.updated_at = self.store.now()
+            self.store.relationship_types[rel_type.id] = copy.deepcopy(rel_type)
+            return copy.deepcopy(rel_type)
+
+    async def delete(self, id: str) -> None:
+        """Delete a relationship type by ID."""
+        with self.store.lock:
+            self._get(id)
+            del self.store.relationship_types[id]
+
+    async def list(self, opts: ListOptions) -> Tuple[List[RelationshipType], ListResult]:
+        """Retrieve relationship types with pagination."""
+        with self.store.lock:
+            ordered = _sorted(
+                self.store.relationship_types.values(), opts.order_by, RELATIONSHIP_TYPE_SORTABLE_COLUMNS, None,
+                default=("name", False),
+            )
+            page, result = _page(ordered, opts)
+            return [copy.deepcopy(t) for t in page], result
+
+    async def get_by_names(self, names: List[str]) -> List[RelationshipType]:
+        """Retrieve the registered relationship types among the given names."""
+        wanted = set(names)
+        with self.store.lock:
+            return [copy.deepcopy(t) for t in self.store.relationship_types.values() if t.name in wanted]
+
+    async def list_all(self) -> List[RelationshipType]:
+        """Retrieve every registered relationship type, by name."""
+        with self.store.lock:
+            return [copy.deepcopy(t) for t in sorted(self.store.relationship_types.values(), key=lambda t: t.name)]
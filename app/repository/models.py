@@ -2,9 +2,49 @@
 Repository models module.
 """
 
+import hashlib
+import json
 from dataclasses import dataclass, field
 from datetime import datetime
-from typing import Optional
+from typing import Any, Dict, List, Optional
+
+from app.repository.compression import decode_data
+
+
+def parse_data(data: str) -> Optional[Any]:
+    """Decode stored JSON data for typed responses (None if it is not valid JSON)."""
+    try:
+        return json.loads(data)
+    except (TypeError, ValueError):
+        return None
+
+
+def entity_etag(id: str, updated_at: datetime) -> str:
+    """Compute the etag of an entity version (changes whenever it is written)."""
+    digest = hashlib.sha256(f"{id}:{updated_at.isoformat()}".encode()).hexdigest()
+    return f'"{digest[:16]}"'
+
+
+class LazyDataMixin:
+    """
+    Defers decompression of ``compressed_data`` into ``data``.
+
+    When an entity is loaded with a compressed document, the ``data``
+    attribute is removed in ``__post_init__`` and resolved on first access.
+    """
+
+    def __post_init__(self):
+        if self.compressed_data is not None:
+            del self.data
+
+    def __getattr__(self, name: str):
+        if name == "data":
+            blob = self.__dict__.get("compressed_data")
+            if blob is not None:
+                self.data = decode_data(blob)
+                self.compressed_data = None
+                return self.data
+        raise AttributeError(f"{type(self).__name__!r} object has no attribute {name!r}")
 
 
 @dataclass
@@ -16,6 +56,28 @@ class Tenant:
     status: str = "active"
     created_at: datetime = field(default_factory=datetime.now)
     updated_at: datetime = field(default_factory=datetime.now)
+    # Set while status is "pending_deletion": the tenant is purged after this time
+    delete_after: Optional[datetime] = None
+    # Overrides of the server's default limits, e.g. {"max_page_size": 500}
+    limits: Dict[str, Any] = field(default_factory=dict)
+    # Free-form operational metadata, e.g. {"tier": "gold", "oncall": "team-a"}
+    annotations: Dict[str, str] = field(default_factory=dict)
+    # Organization this tenant is a sub-tenant of (empty for top-level tenants)
+    parent_id: str = ""
+    # Feature flags set on this tenant, e.g. {"exports": true}; unset flags are inherited
+    features: Dict[str, bool] = field(default_factory=dict)
+    # Set while the tenant is read-only for maintenance, with the reason given
+    maintenance_started_at: Optional[datetime] = None
+    maintenance_reason: str = ""
+    # Debug logging ("requests", "queries") switched on for this tenant until debug_until
+    debug_scopes: List[str] = field(default_factory=list)
+    debug_until: Optional[datetime] = None
+    # IANA time zone that date histograms bucket by (timestamps are stored in UTC)
+    timezone: str = "UTC"
+    # Data residency region the tenant's database is stored in (empty: the default server)
+    region: str = ""
+    # Deleting a protected tenant takes force (and an administrator)
+    protected: bool = False
 
     def to_dict(self) -> dict:
         """Convert to dictionary."""
@@ -24,8 +86,22 @@ class Tenant:
             "slug": self.slug,
             "name": self.name,
             "status": self.status,
+            "parent_id": self.parent_id or None,
+            "timezone": self.timezone,
+            "region": self.region or None,
+            "protected": self.protected,
             "created_at": self.created_at.isoformat(),
             "updated_at": self.updated_at.isoformat(),
+            "delete_after": self.delete_after.isoformat() if self.delete_after else None,
+            "annotations": self.annotations,
+            "maintenance": {
+                "reason": self.maintenance_reason,
+                "started_at": self.maintenance_started_at.isoformat(),
+            } if self.maintenance_started_at else None,
+            "debug": {
+                "scopes": self.debug_scopes,
+                "until": self.debug_until.isoformat(),
+            } if self.debug_until else None,
         }
 
 
@@ -77,6 +153,8 @@ class NodeType:
     schema: str = ""  # JSON string
     created_at: datetime = field(default_factory=datetime.now)
     updated_at: datetime = field(default_factory=datetime.now)
+    # Version of the data shape; bumped by each registered data migration
+    schema_version: int = 1
 
     def to_dict(self) -> dict:
         """Convert to dictionary."""
@@ -86,20 +164,29 @@ class NodeType:
             "name": self.name,
             "description": self.description,
             "schema": self.schema,
+            "schema_version": self.schema_version,
             "created_at": self.created_at.isoformat(),
             "updated_at": self.updated_at.isoformat(),
         }
 
 
 @dataclass
-class Node:
+class Node(LazyDataMixin):
     """Node entity."""
     id: str = ""
     tenant_id: str = ""
     node_type_id: str = ""
-    data: str = "{}"  # JSON string
+    data: str = field(default_factory=lambda: "{}")  # JSON string
     created_at: datetime = field(default_factory=datetime.now)
     updated_at: datetime = field(default_factory=datetime.now)
+    # zstd-compressed data as loaded from storage; decompressed on first access
+    compressed_data: Optional[bytes] = field(default=None, repr=False, compare=False)
+    # System/integration bookkeeping, separate from user data and not versioned
+    metadata: Dict[str, Any] = field(default_factory=dict)
+    # Node type schema version the data is shaped for
+    schema_version: int = 1
+    # Node type's current schema version when the node was read (data_migrations apply up to it)
+    latest_schema_version: int = field(default=0, repr=False, compare=False)
 
     def to_dict(self) -> dict:
         """Convert to dictionary."""
@@ -108,42 +195,844 @@ class Node:
             "tenant_id": self.tenant_id,
             "node_type_id": self.node_type_id,
             "data": self.data,
+            "data_object": parse_data(self.data),
+            "metadata": self.metadata,
+            "schema_version": self.schema_version,
             "created_at": self.created_at.isoformat(),
             "updated_at": self.updated_at.isoformat(),
+            "etag": self.etag,
+        }
+
+    @property
+    def etag(self) -> str:
+        return entity_etag(self.id, self.updated_at)
+
+
+@dataclass
+class DataMigration:
+    """Transformation of a node type's data from from_version to from_version + 1."""
+    id: str = ""
+    node_type_id: str = ""
+    from_version: int = 1
+    name: str = ""
+    # JSON path remapping rules, applied before the expression
+    rules: List[Dict[str, Any]] = field(default_factory=list)
+    # CEL expression returning the new data (empty = rules only)
+    expression: str = ""
+    # Node type schema at to_version (empty = unchanged)
+    schema: str = ""
+    created_at: datetime = field(default_factory=datetime.now)
+
+    @property
+    def to_version(self) -> int:
+        return self.from_version + 1
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "node_type_id": self.node_type_id,
+            "from_version": self.from_version,
+            "to_version": self.to_version,
+            "name": self.name,
+            "rules": self.rules,
+            "expression": self.expression,
+            "schema": self.schema,
+            "created_at": self.created_at.isoformat(),
         }
 
 
 @dataclass
-class Relationship:
+class NodeVersion(LazyDataMixin):
+    """A bi-temporal version of a node's data."""
+    version_id: int = 0
+    node_id: str = ""
+    node_type_id: str = ""
+    data: str = field(default_factory=lambda: "{}")  # JSON string
+    # Valid time: when the data was true in the real world (valid_to None = open-ended)
+    valid_from: datetime = field(default_factory=datetime.now)
+    valid_to: Optional[datetime] = None
+    # Transaction time: when the data was recorded (recorded_to None = current)
+    recorded_from: datetime = field(default_factory=datetime.now)
+    recorded_to: Optional[datetime] = None
+    # Directory user name (or X-User-ID) of the caller that recorded the version
+    recorded_by: str = ""
+    # zstd-compressed data as loaded from storage; decompressed on first access
+    compressed_data: Optional[bytes] = field(default=None, repr=False, compare=False)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary (node-shaped, plus temporal bounds)."""
+        return {
+            "id": self.node_id,
+            "version_id": self.version_id,
+            "node_type_id": self.node_type_id,
+            "data": self.data,
+            "data_object": parse_data(self.data),
+            "valid_from": self.valid_from.isoformat(),
+            "valid_to": self.valid_to.isoformat() if self.valid_to else None,
+            "recorded_from": self.recorded_from.isoformat(),
+            "recorded_to": self.recorded_to.isoformat() if self.recorded_to else None,
+            "recorded_by": self.recorded_by,
+        }
+
+
+@dataclass
+class Attachment:
+    """Binary attachment on a node; content lives in object storage."""
+    id: str = ""
+    node_id: str = ""
+    filename: str = ""
+    content_type: str = "application/octet-stream"
+    size_bytes: int = 0
+    sha256: str = ""
+    storage_key: str = ""
+    status: str = "pending"  # "pending" until content is uploaded, then "ready"
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "node_id": self.node_id,
+            "filename": self.filename,
+            "content_type": self.content_type,
+            "size_bytes": self.size_bytes,
+            "sha256": self.sha256,
+            "status": self.status,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class Operation:
+    """Long-running background operation with progress counters."""
+    id: str = ""
+    kind: str = ""
+    # "awaiting_confirmation" (previewed), "running", "paused" (imports only), "completed" or "failed"
+    status: str = "running"
+    params: Dict[str, Any] = field(default_factory=dict)
+    total_count: int = 0
+    processed_count: int = 0
+    affected_count: int = 0
+    failed_count: int = 0
+    errors: List[Dict[str, str]] = field(default_factory=list)  # first failures, as {"id", "error"}
+    error: str = ""  # why the operation itself failed
+    result: Dict[str, Any] = field(default_factory=dict)  # summary recorded when it finishes
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+    completed_at: Optional[datetime] = None
+
+    @property
+    def done(self) -> bool:
+        return self.status in ("completed", "failed")
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "kind": self.kind,
+            "status": self.status,
+            "done": self.done,
+            "params": self.params,
+            "total_count": self.total_count,
+            "processed_count": self.processed_count,
+            "affected_count": self.affected_count,
+            "failed_count": self.failed_count,
+            "errors": list(self.errors),
+            "error": self.error,
+            "result": self.result,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+            "completed_at": self.completed_at.isoformat() if self.completed_at else None,
+        }
+
+
+@dataclass
+class MetadataUpdate:
+    """Changes to node metadata: keys to set and keys to remove, or a full replacement."""
+    set: Dict[str, Any] = field(default_factory=dict)
+    remove: List[str] = field(default_factory=list)
+    # Replace the metadata with set instead of merging
+    replace: bool = False
+
+
+@dataclass
+class DataRange:
+    """Compares a node data field with a bound."""
+    # Data path segments, e.g. ["stats", "views"]
+    path: List[str]
+    # One of "gt", "gte", "lt", "lte"
+    op: str
+    # "number", "timestamp" or "text": how the field and bound compare
+    kind: str
+    value: Any
+
+
+@dataclass
+class NodeFilter:
+    """Selects nodes for bulk operations and exports (empty fields are ignored)."""
+    node_type_id: str = ""
+    # JSON object the node data must contain (PostgreSQL @> containment)
+    data_contains: Optional[Dict[str, Any]] = None
+    created_after: Optional[datetime] = None
+    created_before: Optional[datetime] = None
+    updated_after: Optional[datetime] = None
+    updated_before: Optional[datetime] = None
+    # JSON object the node metadata must contain
+    metadata_contains: Optional[Dict[str, Any]] = None
+    # Comparisons the node data must satisfy, e.g. data.due >= 2024-01-01
+    data_ranges: Optional[List[DataRange]] = None
+
+    def queries_data(self) -> bool:
+        """Whether the filter matches on the node data."""
+        return bool(self.data_contains or self.data_ranges)
+
+
+@dataclass
+class RelationshipFilter:
+    """Selects relationships for bulk operations (empty fields are ignored)."""
+    relationship_type: str = ""
+    source_node_id: str = ""
+    target_node_id: str = ""
+    # JSON object the relationship data must contain (PostgreSQL @> containment)
+    data_contains: Optional[Dict[str, Any]] = None
+    created_after: Optional[datetime] = None
+    created_before: Optional[datetime] = None
+
+    def queries_data(self) -> bool:
+        """Whether the filter matches on the relationship data."""
+        return bool(self.data_contains)
+
+
+@dataclass
+class Relationship(LazyDataMixin):
     """Relationship between nodes."""
     id: str = ""
     tenant_id: str = ""
     source_node_id: str = ""
     target_node_id: str = ""
     relationship_type: str = ""
-    data: str = "{}"  # JSON string
+    data: str = field(default_factory=lambda: "{}")  # JSON string
     created_at: datetime = field(default_factory=datetime.now)
     updated_at: datetime = field(default_factory=datetime.now)
+    # Validity interval; None bounds are open (always valid before/after)
+    valid_from: Optional[datetime] = None
+    valid_to: Optional[datetime] = None
+    # zstd-compressed data as loaded from storage; decompressed on first access
+    compressed_data: Optional[bytes] = field(default=None, repr=False, compare=False)
+    # In deduplicated listings: the parallel relationships (same type and nodes) this one stands for
+    parallel_count: int = field(default=0, compare=False)
 
     def to_dict(self) -> dict:
         """Convert to dictionary."""
-        return {
+        d = {
             "id": self.id,
             "tenant_id": self.tenant_id,
             "source_node_id": self.source_node_id,
             "target_node_id": self.target_node_id,
             "relationship_type": self.relationship_type,
             "data": self.data,
+            "data_object": parse_data(self.data),
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+            "valid_from": self.valid_from.isoformat() if self.valid_from else None,
+            "valid_to": self.valid_to.isoformat() if self.valid_to else None,
+            "etag": self.etag,
+        }
+        if self.parallel_count:
+            d["parallel_count"] = self.parallel_count
+        return d
+
+    @property
+    def etag(self) -> str:
+        return entity_etag(self.id, self.updated_at)
+
+
+@dataclass
+class RelationshipType:
+    """Registered relationship type with directionality and endpoint constraints."""
+    id: str = ""
+    tenant_id: str = ""
+    name: str = ""
+    description: str = ""
+    directionality: str = "directed"  # "directed" or "undirected"
+    allowed_source_node_type_ids: List[str] = field(default_factory=list)  # empty = any
+    allowed_target_node_type_ids: List[str] = field(default_factory=list)  # empty = any
+    # What happens when an endpoint is deleted: "delete_edge", "delete_node" (the other end) or "restrict"
+    on_source_delete: str = "delete_edge"
+    on_target_delete: str = "delete_edge"
+    # What creating a relationship identical to an existing one does: "allow", "reject" or "return_existing"
+    on_duplicate: str = "allow"
+    # Set for derived types: {"path": [{"relationship_type", "direction"}, ...], "materialized": bool}
+    derivation: Optional[Dict[str, Any]] = None
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    @property
+    def derived(self) -> bool:
+        """Whether relationships of the type are computed rather than stored."""
+        return self.derivation is not None
+
+    def allows(self, source_node_type_id: str, target_node_type_id: str) -> bool:
+        """Check whether a connection between the given node types is permitted."""
+        def _match(src: str, tgt: str) -> bool:
+            src_ok = not self.allowed_source_node_type_ids or src in self.allowed_source_node_type_ids
+            tgt_ok = not self.allowed_target_node_type_ids or tgt in self.allowed_target_node_type_ids
+            return src_ok and tgt_ok
+
+        if _match(source_node_type_id, target_node_type_id):
+            return True
+        # Undirected types may be connected in either orientation
+        return self.directionality == "undirected" and _match(target_node_type_id, source_node_type_id)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "tenant_id": self.tenant_id,
+            "name": self.name,
+            "description": self.description,
+            "directionality": self.directionality,
+            "allowed_source_node_type_ids": list(self.allowed_source_node_type_ids),
+            "allowed_target_node_type_ids": list(self.allowed_target_node_type_ids),
+            "on_source_delete": self.on_source_delete,
+            "on_target_delete": self.on_target_delete,
+            "on_duplicate": self.on_duplicate,
+            "derivation": self.derivation,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class WriteHook:
+    """Tenant-defined CEL script run before or after node writes."""
+    id: str = ""
+    tenant_id: str = ""
+    name: str = ""
+    node_type_id: str = ""  # empty = applies to all node types
+    phase: str = "pre_write"  # "pre_write" or "post_write"
+    action: str = "validate"  # "validate" or "enrich"
+    operations: List[str] = field(default_factory=lambda: ["create", "update"])
+    expression: str = ""
+    timeout_ms: int = 100
+    priority: int = 0  # lower runs first
+    enabled: bool = True
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "tenant_id": self.tenant_id,
+            "name": self.name,
+            "node_type_id": self.node_type_id,
+            "phase": self.phase,
+            "action": self.action,
+            "operations": list(self.operations),
+            "expression": self.expression,
+            "timeout_ms": self.timeout_ms,
+            "priority": self.priority,
+            "enabled": self.enabled,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class SavedQuery:
+    """A tenant's named query definition, run by name with parameters."""
+    id: str = ""
+    name: str = ""
+    description: str = ""
+    method: str = ""  # read method run, e.g. "list_nodes"
+    params: Dict[str, Any] = field(default_factory=dict)  # its parameters; "$name" values are substituted
+    parameters: Dict[str, Any] = field(default_factory=dict)  # declared parameters and defaults (None = required)
+    run_count: int = 0
+    total_run_ms: float = 0.0
+    last_run_at: Optional[datetime] = None
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "name": self.name,
+            "description": self.description,
+            "method": self.method,
+            "params": self.params,
+            "parameters": self.parameters,
+            "run_count": self.run_count,
+            "avg_run_ms": round(self.total_run_ms / self.run_count, 2) if self.run_count else None,
+            "last_run_at": self.last_run_at.isoformat() if self.last_run_at else None,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class NodeView:
+    """A tenant's named node filter and projection, read like a collection."""
+    id: str = ""
+    name: str = ""
+    description: str = ""
+    filter: Dict[str, Any] = field(default_factory=dict)  # bulk operation filter; "$name" values are substituted
+    projection: Dict[str, Any] = field(default_factory=dict)  # export transform applied to the nodes read
+    parameters: Dict[str, Any] = field(default_factory=dict)  # declared parameters and defaults (None = required)
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "name": self.name,
+            "description": self.description,
+            "filter": self.filter,
+            "projection": self.projection,
+            "parameters": self.parameters,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class UniqueConstraint:
+    """Uniqueness of a data path among the nodes of a node type."""
+    id: str = ""
+    node_type_id: str = ""
+    path: List[str] = field(default_factory=list)  # keys inside data, e.g. ["contact", "email"]
+    case_insensitive: bool = False
+    status: str = "building"  # "building" or "active"
+    created_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "node_type_id": self.node_type_id,
+            "path": "data." + ".".join(self.path),
+            "case_insensitive": self.case_insensitive,
+            "status": self.status,
+            "created_at": self.created_at.isoformat(),
+        }
+
+
+@dataclass
+class AuthzPolicy:
+    """CEL authorization policy evaluated for JSON-RPC calls."""
+    id: str = ""
+    name: str = ""
+    description: str = ""
+    effect: str = "allow"  # "allow" or "deny"
+    methods: List[str] = field(default_factory=list)  # glob patterns; empty = all
+    expression: str = ""
+    enabled: bool = True
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "name": self.name,
+            "description": self.description,
+            "effect": self.effect,
+            "methods": list(self.methods),
+            "expression": self.expression,
+            "enabled": self.enabled,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class DirectoryUser:
+    """A human user in a tenant's directory."""
+    id: str = ""
+    user_name: str = ""
+    external_id: str = ""
+    display_name: str = ""
+    email: str = ""
+    active: bool = True
+    # Display names of the user's groups (filled by get and resolve)
+    groups: List[str] = field(default_factory=list)
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "user_name": self.user_name,
+            "external_id": self.external_id,
+            "display_name": self.display_name,
+            "email": self.email,
+            "active": self.active,
+            "groups": list(self.groups),
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class DirectoryGroup:
+    """A group of directory users."""
+    id: str = ""
+    display_name: str = ""
+    external_id: str = ""
+    member_count: int = 0
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "display_name": self.display_name,
+            "external_id": self.external_id,
+            "member_count": self.member_count,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class Tombstone:
+    """Record of a deleted node or relationship, returned by incremental exports."""
+    entity_type: str = ""
+    entity_id: str = ""
+    deleted_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "entity_type": self.entity_type,
+            "id": self.entity_id,
+            "deleted_at": self.deleted_at.isoformat(),
+        }
+
+
+@dataclass
+class BillingEvent:
+    """Usage of a tenant over a period, for billing."""
+    id: int = 0
+    tenant_id: str = ""
+    event_type: str = ""  # "api_calls", "export_bytes" or "storage_bytes"
+    dimension: str = ""  # JSON-RPC method for api_calls and export_bytes
+    quantity: int = 0
+    period_start: datetime = field(default_factory=datetime.now)
+    period_end: datetime = field(default_factory=datetime.now)
+    recorded_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "tenant_id": self.tenant_id,
+            "event_type": self.event_type,
+            "dimension": self.dimension,
+            "quantity": self.quantity,
+            "period_start": self.period_start.isoformat(),
+            "period_end": self.period_end.isoformat(),
+            "recorded_at": self.recorded_at.isoformat(),
+        }
+
+
+@dataclass
+class TenantTemplate:
+    """Node types, relationship types and seed data applied when creating a tenant."""
+    id: str = ""
+    name: str = ""
+    description: str = ""
+    # {"node_types": [...], "relationship_types": [...], "nodes": [...], "relationships": [...]}
+    definition: Dict[str, Any] = field(default_factory=dict)
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "name": self.name,
+            "description": self.description,
+            "definition": self.definition,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class ExportSchedule:
+    """A recurring export of a tenant's data to a destination."""
+    id: str = ""
+    tenant_id: str = ""
+    name: str = ""
+    cron: str = ""  # evaluated in the tenant's time zone
+    format: str = "ndjson"  # "ndjson", "graphml" or "dot"
+    destination: str = ""  # URL, e.g. s3://bucket/prefix/
+    filter: Dict[str, Any] = field(default_factory=dict)  # bulk operation filter keys
+    include_relationships: bool = True
+    alert_url: str = ""  # receives a POST when a run fails
+    credential: str = ""  # name of the tenant's destination credential, if any
+    transform: Dict[str, Any] = field(default_factory=dict)  # field selection and redaction (export_transforms.py)
+    enabled: bool = True
+    next_run_at: Optional[datetime] = None
+    consecutive_failures: int = 0
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "tenant_id": self.tenant_id,
+            "name": self.name,
+            "cron": self.cron,
+            "format": self.format,
+            "destination": self.destination,
+            "filter": self.filter,
+            "include_relationships": self.include_relationships,
+            "alert_url": self.alert_url,
+            "credential": self.credential,
+            "transform": self.transform,
+            "enabled": self.enabled,
+            "next_run_at": self.next_run_at.isoformat() if self.next_run_at else None,
+            "consecutive_failures": self.consecutive_failures,
+            "created_at": self.created_at.isoformat(),
+            "updated_at": self.updated_at.isoformat(),
+        }
+
+
+@dataclass
+class DestinationCredential:
+    """A tenant's credential for writing to export destinations (the secret is never returned)."""
+    id: str = ""
+    tenant_id: str = ""
+    name: str = ""
+    kind: str = ""  # destination scheme: "s3", "gcs", "azure" or "sftp"
+    secret: Dict[str, Any] = field(default_factory=dict, repr=False)
+    created_at: datetime = field(default_factory=datetime.now)
+    updated_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "tenant_id": self.tenant_id,
+            "name": self.name,
+            "kind": self.kind,
             "created_at": self.created_at.isoformat(),
             "updated_at": self.updated_at.isoformat(),
         }
 
 
+@dataclass
+class ExportRun:
+    """One run of an export schedule."""
+    id: str = ""
+    schedule_id: str = ""
+    status: str = "running"  # "running", "succeeded" or "failed"
+    location: str = ""  # URL of the written export
+    node_count: int = 0
+    relationship_count: int = 0
+    size_bytes: int = 0
+    error: str = ""
+    started_at: datetime = field(default_factory=datetime.now)
+    finished_at: Optional[datetime] = None
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "schedule_id": self.schedule_id,
+            "status": self.status,
+            "location": self.location,
+            "node_count": self.node_count,
+            "relationship_count": self.relationship_count,
+            "size_bytes": self.size_bytes,
+            "error": self.error,
+            "started_at": self.started_at.isoformat(),
+            "finished_at": self.finished_at.isoformat() if self.finished_at else None,
+        }
+
+
+@dataclass
+class TenantKey:
+    """A tenant's data encryption key version (the key itself is only stored wrapped)."""
+    id: str = ""
+    tenant_id: str = ""
+    version: int = 0
+    # "pending", "active", "retiring" or "retired"
+    status: str = "pending"
+    wrapped_key: bytes = b""
+    operation_id: str = ""  # rotation operation (in the tenant database) that introduced the key
+    created_at: datetime = field(default_factory=datetime.now)
+    activated_at: Optional[datetime] = None
+    retired_at: Optional[datetime] = None
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "tenant_id": self.tenant_id,
+            "version": self.version,
+            "status": self.status,
+            "operation_id": self.operation_id or None,
+            "created_at": self.created_at.isoformat(),
+            "activated_at": self.activated_at.isoformat() if self.activated_at else None,
+            "retired_at": self.retired_at.isoformat() if self.retired_at else None,
+        }
+
+
+@dataclass
+class ImpersonationToken:
+    """Grant for an admin to act within a tenant (the bearer token is only returned once)."""
+    id: str = ""
+    admin_user_id: str = ""
+    tenant_id: str = ""
+    user_id: str = ""  # tenant user acted as; empty = the admin within the tenant
+    reason: str = ""
+    expires_at: datetime = field(default_factory=datetime.now)
+    revoked_at: Optional[datetime] = None
+    created_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "admin_user_id": self.admin_user_id,
+            "tenant_id": self.tenant_id,
+            "user_id": self.user_id,
+            "reason": self.reason,
+            "expires_at": self.expires_at.isoformat(),
+            "revoked_at": self.revoked_at.isoformat() if self.revoked_at else None,
+            "created_at": self.created_at.isoformat(),
+        }
+
+
+@dataclass
+class ApiKey:
+    """Scoped bearer credential that runs as a subject (the key itself is only returned once)."""
+    id: str = ""
+    name: str = ""
+    subject_id: str = ""
+    scope: str = ""  # "read", "write", "export" or "admin"
+    tenant_ids: List[str] = field(default_factory=list)  # tenants the key may access; empty = any
+    created_by: str = ""
+    expires_at: Optional[datetime] = None
+    revoked_at: Optional[datetime] = None
+    created_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "name": self.name,
+            "subject_id": self.subject_id,
+            "scope": self.scope,
+            "tenant_ids": list(self.tenant_ids),
+            "created_by": self.created_by,
+            "expires_at": self.expires_at.isoformat() if self.expires_at else None,
+            "revoked_at": self.revoked_at.isoformat() if self.revoked_at else None,
+            "created_at": self.created_at.isoformat(),
+        }
+
+
+@dataclass
+class DualWriteTarget:
+    """Second database a tenant's writes are mirrored to during a backend migration."""
+    tenant_id: str = ""
+    database_name: str = ""
+    started_at: datetime = field(default_factory=datetime.now)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "tenant_id": self.tenant_id,
+            "database_name": self.database_name,
+            "started_at": self.started_at.isoformat(),
+        }
+
+
+@dataclass
+class AuditEvent:
+    """Audit log entry for a JSON-RPC call."""
+    id: int = 0
+    occurred_at: datetime = field(default_factory=datetime.now)
+    request_id: str = ""
+    actor_id: str = ""
+    impersonated_by: str = ""  # admin user ID when the call was impersonated
+    impersonation_id: str = ""
+    tenant_id: str = ""
+    method: str = ""
+    outcome: str = "ok"  # "ok" or "error"
+    error_code: Optional[int] = None
+    # What the call accessed, e.g. {"value": ..., "matches": [...]} for admin searches
+    details: Dict[str, Any] = field(default_factory=dict)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "id": self.id,
+            "occurred_at": self.occurred_at.isoformat(),
+            "request_id": self.request_id,
+            "actor_id": self.actor_id,
+            "impersonated": bool(self.impersonated_by),
+            "impersonated_by": self.impersonated_by,
+            "impersonation_id": self.impersonation_id,
+            "tenant_id": self.tenant_id,
+            "method": self.method,
+            "outcome": self.outcome,
+            "error_code": self.error_code,
+            "details": self.details,
+        }
+
+
+@dataclass
+class FacetValue:
+    """Distinct value of a field with its occurrence count."""
+    value: Any = None
+    count: int = 0
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {"value": self.value, "count": self.count}
+
+
+@dataclass
+class TenantFilter:
+    """Filters for listing tenants (empty fields are ignored)."""
+    status: str = ""
+    slug_prefix: str = ""
+    # Case-insensitive substring of the tenant name
+    name_contains: str = ""
+    created_after: Optional[datetime] = None
+    created_before: Optional[datetime] = None
+    # Annotations the tenant must have, with these exact values
+    annotations: Dict[str, str] = field(default_factory=dict)
+    # Direct sub-tenants of this tenant
+    parent_id: str = ""
+
+
 @dataclass
 class ListOptions:
     """Common pagination options."""
     page_size: int = 10
     page_token: str = ""
+    # Comma-separated sort fields, e.g. "data.priority desc, created_at asc"
+    order_by: str = ""
+    # Page size used when page_size is 0, and the largest page returned
+    default_page_size: int = 10
+    max_page_size: int = 100
+
+    def effective_page_size(self) -> int:
+        """Return the page size to use, applying the default and maximum."""
+        return max(1, min(self.page_size or self.default_page_size, self.max_page_size))
 
 
 @dataclass
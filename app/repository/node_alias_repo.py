@@ -0,0 +1,100 @@
+"""
+Node alias repository implementation.
+"""
+
+from typing import Dict, List, Optional, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+
+class NodeAliasRepository:
+    """PostgreSQL node alias repository (tenant database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def list(self, node_id: str) -> Dict[str, str]:
+        """Retrieve a node's aliases by name."""
+        query = "SELECT name, value FROM node_aliases WHERE node_id = $1 ORDER BY name"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_id)
+
+        return {row["name"]: row["value"] for row in rows}
+
+    @with_retry()
+    async def set(self, node_id: str, aliases: Dict[str, Optional[str]]) -> Dict[str, str]:
+        """
+        Set or (with a None value) remove some of a node's aliases; returns all of its aliases.
+
+        Raises:
+            NotFoundError: If the node does not exist
+            ValueError: If an alias value is already used by another node
+        """
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                if not await conn.fetchval("SELECT 1 FROM nodes WHERE id = $1 FOR SHARE", node_id):
+                    raise NotFoundError(f"node not found: {node_id}")
+                for name, value in aliases.items():
+                    if value is None:
+                        await conn.execute(
+                            "DELETE FROM node_aliases WHERE node_id = $1 AND name = $2", node_id, name
+                        )
+                        continue
+                    try:
+                        await conn.execute(
+                            """
+                            INSERT INTO node_aliases (node_id, name, value) VALUES ($1, $2, $3)
+                            ON CONFLICT (node_id, name) DO UPDATE SET value = EXCLUDED.value, created_at = NOW()
+                            """,
+                            node_id, name, value
+                        )
+                    except asyncpg.UniqueViolationError:
+                        raise ValueError(f"alias {name}={value!r} is already used by another node")
+                rows = await conn.fetch(
+                    "SELECT name, value FROM node_aliases WHERE node_id = $1 ORDER BY name", node_id
+                )
+
+        return {row["name"]: row["value"] for row in rows}
+
+    @with_retry(idempotent=True)
+    async def find(self, name: str, value: str) -> Optional[str]:
+        """Return the ID of the node with an alias, or None."""
+        query = "SELECT node_id FROM node_aliases WHERE name = $1 AND value = $2"
+
+        async with self.db.pool.acquire() as conn:
+            node_id = await conn.fetchval(query, name, value)
+
+        return str(node_id) if node_id else None
+
+    @with_retry(idempotent=True)
+    async def find_by_value(self, value: str, limit: int) -> List[Tuple[str, str]]:
+        """Return up to limit (alias name, node ID) pairs of the aliases with a value, whatever their name."""
+        query = "SELECT name, node_id FROM node_aliases WHERE value = $1 ORDER BY name LIMIT $2"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, value, limit)
+
+        return [(row["name"], str(row["node_id"])) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def find_many(self, aliases: List[Tuple[str, str]]) -> Dict[Tuple[str, str], str]:
+        """Return the IDs of the nodes with some aliases by (name, value); aliases no node has are left out."""
+        if not aliases:
+            return {}
+        query = """
+            SELECT a.name, a.value, a.node_id
+            FROM node_aliases a
+            JOIN unnest($1::text[], $2::text[]) AS wanted(name, value)
+                ON a.name = wanted.name AND a.value = wanted.value
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, [name for name, _ in aliases], [value for _, value in aliases])
+
+        return {(row["name"], row["value"]): str(row["node_id"]) for row in rows}
@@ -0,0 +1,50 @@
+"""
+Node protection repository implementation.
+"""
+
+from typing import List
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+
+class NodeProtectionRepository:
+    """PostgreSQL repository of delete-protected nodes (tenant database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def protect(self, node_id: str) -> None:
+        """
+        Protect a node from deletion (a no-op if it is protected already).
+
+        Raises:
+            NotFoundError: If the node does not exist
+        """
+        query = "INSERT INTO node_protections (node_id) VALUES ($1) ON CONFLICT (node_id) DO NOTHING"
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                await conn.execute(query, node_id)
+            except asyncpg.ForeignKeyViolationError as e:
+                raise NotFoundError(f"node not found: {node_id}") from e
+
+    @with_retry(idempotent=True)
+    async def unprotect(self, node_id: str) -> None:
+        """Remove a node's delete protection (a no-op if it has none)."""
+        async with self.db.pool.acquire() as conn:
+            await conn.execute("DELETE FROM node_protections WHERE node_id = $1", node_id)
+
+    @with_retry(idempotent=True)
+    async def list_protected(self, node_ids: List[str]) -> List[str]:
+        """Return which of the nodes are protected."""
+        query = "SELECT node_id FROM node_protections WHERE node_id = ANY($1::uuid[]) ORDER BY node_id"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_ids)
+
+        return [str(row["node_id"]) for row in rows]
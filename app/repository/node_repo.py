@@ -5,24 +5,75 @@ Node repository implementation.
 import json
 import uuid
 from datetime import datetime
-from typing import List, Optional, Tuple
+from typing import Any, Dict, List, Optional, Tuple
 
 import asyncpg
 
 from app.db.database import Database
-from app.repository.models import Node, ListOptions, ListResult
-from app.repository.errors import NotFoundError
+from app.repository.models import DataRange, Node, NodeVersion, NodeFilter, MetadataUpdate, FacetValue, ListOptions, ListResult
+from app.repository.attribution import current_actor
+from app.repository.errors import AlreadyExistsError, NotFoundError, PreconditionFailedError
+from app.repository.ordering import build_order_by, json_path_expression, orders_by_json
+from app.repository.encryption import DataKey
+from app.repository.compression import check_data_query, decode_data, encode_data
+from app.repository.facets import fetch_date_histogram, fetch_distinct_values
+from app.repository.retry import with_retry
+from app.repository.unique_constraint_repo import UNIQUE_INDEX_PREFIX
+
+SORTABLE_COLUMNS = ("node_type_id", "created_at", "updated_at")
+FACET_COLUMNS = ("node_type_id",)
+DATE_COLUMNS = ("created_at", "updated_at")
+VERSION_SORTABLE_COLUMNS = ("node_type_id", "valid_from", "valid_to", "recorded_from")
+
+_VERSION_COLUMNS = """
+    version_id, node_id, node_type_id, data::text, valid_from, valid_to,
+    recorded_from, recorded_to, data_compressed, recorded_by
+"""
+
+# Versions known at $2 (transaction time) that were valid at $1 (valid time)
+_AS_OF_CONDITION = """
+    valid_from <= $1 AND (valid_to IS NULL OR valid_to > $1)
+    AND recorded_from <= $2 AND (recorded_to IS NULL OR recorded_to > $2)
+"""
+
+
+# Whether a node type (or the node type of a node) has unique constraints
+_HAS_UNIQUE_CONSTRAINTS = """
+    SELECT EXISTS (
+        SELECT 1 FROM unique_constraints
+        WHERE node_type_id = COALESCE($1::uuid, (SELECT node_type_id FROM nodes WHERE id = $2::uuid))
+    )
+"""
+
+
+def _data_conflict(e: asyncpg.UniqueViolationError) -> Optional[AlreadyExistsError]:
+    """Return the error for a violated unique constraint on node data (None for other unique violations)."""
+    if not (e.constraint_name or "").startswith(UNIQUE_INDEX_PREFIX):
+        return None
+    # The detail names the indexed expression and the duplicate value
+    return AlreadyExistsError(f"another node has the same value: {e.detail or e.constraint_name}")
 
 
 class NodeRepository:
     """PostgreSQL node repository."""
 
-    def __init__(self, db: Database):
+    def __init__(self, db: Database, data_key: Optional[DataKey] = None):
         self.db = db
+        # The tenant's active data key when its documents are stored encrypted
+        self.data_key = data_key
+
+    @with_retry()
+    async def create(self, node: Node, valid_from: Optional[datetime] = None) -> Node:
+        """
+        Create a new node, valid from valid_from (default: now).
+
+        A node.id set by the caller is used instead of a generated one.
 
-    async def create(self, node: Node) -> Node:
-        """Create a new node."""
-        node.id = str(uuid.uuid4())
+        Raises:
+            AlreadyExistsError: If a node with that ID exists or existed
+        """
+        client_id = bool(node.id)
+        node.id = node.id or str(uuid.uuid4())
         node.created_at = datetime.now()
         node.updated_at = datetime.now()
 
@@ -30,24 +81,41 @@ class NodeRepository:
             node.data = "{}"
 
         query = """
-            INSERT INTO nodes (id, node_type_id, data, created_at, updated_at)
-            VALUES ($1, $2, $3::jsonb, $4, $5)
-            RETURNING id, node_type_id, data::text, created_at, updated_at
+            INSERT INTO nodes (id, node_type_id, data, created_at, updated_at, data_compressed, metadata, schema_version)
+            VALUES ($1, $2, $3::jsonb, $4, $5, $6, $7::jsonb, $8)
+            RETURNING id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
         """
 
         async with self.db.pool.acquire() as conn:
-            row = await conn.fetchrow(
-                query,
-                node.id, node.node_type_id, node.data,
-                node.created_at, node.updated_at
-            )
+            async with conn.transaction():
+                # The history of a deleted node is kept, so its ID cannot be reused
+                if client_id and await conn.fetchval(
+                    "SELECT EXISTS (SELECT 1 FROM node_versions WHERE node_id = $1)", node.id
+                ):
+                    raise AlreadyExistsError(f"node already exists: {node.id}")
+                data_value, compressed = await self._encode(conn, node.data, node_type_id=node.node_type_id)
+                try:
+                    row = await conn.fetchrow(
+                        query,
+                        node.id, node.node_type_id, data_value,
+                        node.created_at, node.updated_at, compressed, json.dumps(node.metadata),
+                        node.schema_version
+                    )
+                except asyncpg.UniqueViolationError as e:
+                    raise _data_conflict(e) or AlreadyExistsError(f"node already exists: {node.id}") from e
+                await self._record_version(
+                    conn, node.id, node.node_type_id, data_value, compressed, valid_from, None
+                )
 
         return self._row_to_node(row)
 
+    @with_retry(idempotent=True)
     async def get_by_id(self, id: str) -> Node:
         """Retrieve a node by ID."""
         query = """
-            SELECT id, node_type_id, data::text, created_at, updated_at 
+            SELECT id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
             FROM nodes 
             WHERE id = $1
         """
@@ -60,44 +128,355 @@ class NodeRepository:
 
         return self._row_to_node(row)
 
-    async def update(self, node: Node) -> Node:
-        """Update an existing node."""
+    @with_retry(idempotent=True)
+    async def get_many(self, ids: List[str]) -> List[Node]:
+        """Retrieve the nodes with the given IDs that exist, in no particular order."""
+        query = """
+            SELECT id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
+            FROM nodes
+            WHERE id = ANY($1::uuid[])
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, ids)
+
+        return [self._row_to_node(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def get_node_type_ids(self, ids: List[str]) -> Dict[str, str]:
+        """Map the IDs of the given nodes that exist to their node type IDs."""
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch("SELECT id, node_type_id FROM nodes WHERE id = ANY($1::uuid[])", ids)
+
+        return {str(row[0]): str(row[1]) for row in rows}
+
+    @with_retry()
+    async def update(
+        self,
+        node: Node,
+        valid_from: Optional[datetime] = None,
+        expected_updated_at: Optional[datetime] = None,
+        metadata: Optional[MetadataUpdate] = None
+    ) -> Node:
+        """Update an existing node, with the new data valid from valid_from (default: now).
+
+        With expected_updated_at the update only applies if the node has not
+        been written since (raises PreconditionFailedError otherwise).
+        Metadata is left unchanged unless a metadata update is given.
+        """
         node.updated_at = datetime.now()
 
         if not node.data:
             node.data = "{}"
 
-        query = """
+        query = f"""
             UPDATE nodes 
-            SET data = $2::jsonb, updated_at = $3
-            WHERE id = $1
-            RETURNING id, node_type_id, data::text, created_at, updated_at
+            SET data = $2::jsonb, updated_at = $3, data_compressed = $4, metadata = {_metadata_expression(6)},
+                schema_version = $10
+            WHERE id = $1 AND ($5::timestamptz IS NULL OR updated_at = $5)
+            RETURNING id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
         """
 
         async with self.db.pool.acquire() as conn:
-            row = await conn.fetchrow(
-                query,
-                node.id, node.data, node.updated_at
-            )
+            async with conn.transaction():
+                data_value, compressed = await self._encode(conn, node.data, node_id=node.id)
+                try:
+                    row = await conn.fetchrow(
+                        query,
+                        node.id, data_value, node.updated_at, compressed, expected_updated_at,
+                        *_metadata_args(metadata), node.schema_version
+                    )
+                except asyncpg.UniqueViolationError as e:
+                    raise _data_conflict(e) or e
+                if not row:
+                    if expected_updated_at and await conn.fetchval("SELECT 1 FROM nodes WHERE id = $1", node.id):
+                        raise PreconditionFailedError(f"node was modified concurrently: {node.id}")
+                    raise NotFoundError(f"node not found: {node.id}")
+                await self._record_version(
+                    conn, node.id, str(row[1]), data_value, compressed, valid_from, None
+                )
 
-        if not row:
-            raise NotFoundError(f"node not found: {node.id}")
+        return self._row_to_node(row)
+
+    @with_retry()
+    async def update_metadata(
+        self,
+        id: str,
+        metadata: MetadataUpdate,
+        expected_updated_at: Optional[datetime] = None
+    ) -> Node:
+        """Update only a node's metadata; its data, versions and etag are unchanged."""
+        query = f"""
+            UPDATE nodes SET metadata = {_metadata_expression(3)}
+            WHERE id = $1 AND ($2::timestamptz IS NULL OR updated_at = $2)
+            RETURNING id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, expected_updated_at, *_metadata_args(metadata))
+            if not row:
+                if expected_updated_at and await conn.fetchval("SELECT 1 FROM nodes WHERE id = $1", id):
+                    raise PreconditionFailedError(f"node was modified concurrently: {id}")
+                raise NotFoundError(f"node not found: {id}")
 
         return self._row_to_node(row)
 
+    @with_retry()
+    async def set_metadata_values(self, key: str, name: str, values: List[Tuple[str, Any]]) -> int:
+        """
+        Set metadata[key][name] to a JSON value on each of the nodes, keeping
+        their other metadata; returns how many nodes still existed.
+        """
+        query = """
+            UPDATE nodes n
+            SET metadata = n.metadata || jsonb_build_object(
+                $1::text, COALESCE(n.metadata -> $1::text, '{}'::jsonb) || jsonb_build_object($2::text, s.value::jsonb)
+            )
+            FROM unnest($3::uuid[], $4::text[]) AS s(id, value)
+            WHERE n.id = s.id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(
+                query, key, name, [id for id, _ in values], [json.dumps(value) for _, value in values]
+            )
+        return int(result.split()[-1])
+
+    @with_retry(idempotent=True)
+    async def list_top_by_metadata_score(
+        self, key: str, name: str, limit: int, node_type_id: str = ""
+    ) -> List[Tuple[Node, float]]:
+        """Retrieve up to limit nodes with the highest metadata[key][name] scores, highest first."""
+        query = """
+            SELECT id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id),
+                   (metadata -> $1::text ->> $2::text)::float8 AS score
+            FROM nodes
+            WHERE jsonb_typeof(metadata -> $1::text -> $2::text) = 'number'
+              AND ($4::text = '' OR node_type_id::text = $4::text)
+            ORDER BY score DESC, id
+            LIMIT $3
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, key, name, limit, node_type_id)
+
+        return [(self._row_to_node(row), row["score"]) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def count_by_metadata_value(
+        self, key: str, name: str, limit: int, min_count: int = 1
+    ) -> List[Tuple[str, int]]:
+        """Count nodes per distinct metadata[key][name] string value, largest first (up to limit values)."""
+        query = """
+            SELECT metadata -> $1::text ->> $2::text AS value, COUNT(*) AS count
+            FROM nodes
+            WHERE metadata -> $1::text ? $2::text
+            GROUP BY 1
+            HAVING COUNT(*) >= $4
+            ORDER BY count DESC, value
+            LIMIT $3
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, key, name, limit, min_count)
+
+        return [(row["value"], row["count"]) for row in rows]
+
+    @with_retry()
+    async def increment_field(
+        self,
+        id: str,
+        path: List[str],
+        delta: float,
+        valid_from: Optional[datetime] = None,
+    ) -> Tuple[Node, float]:
+        """
+        Atomically add delta to the number at path in a node's data (a
+        missing field counts as 0 and missing parent objects are created).
+
+        The node row is locked for the read-modify-write, so concurrent
+        increments are never lost. Returns the node and the new value.
+
+        Raises:
+            ValueError: If the field, or an object on its path, has another type
+        """
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                row = await conn.fetchrow(
+                    "SELECT node_type_id, data::text, data_compressed FROM nodes WHERE id = $1 FOR UPDATE", id
+                )
+                if not row:
+                    raise NotFoundError(f"node not found: {id}")
+                data = json.loads(decode_data(row[2]) if row[2] is not None else row[1])
+
+                container = data
+                for i, token in enumerate(path[:-1]):
+                    container = container.setdefault(token, {})
+                    if not isinstance(container, dict):
+                        raise ValueError(f"data.{'.'.join(path[:i + 1])} is not an object")
+                current = container.get(path[-1], 0)
+                if isinstance(current, bool) or not isinstance(current, (int, float)):
+                    raise ValueError(f"data.{'.'.join(path)} is not a number")
+                value = current + delta
+                container[path[-1]] = value
+
+                data_value, compressed = await self._encode(conn, json.dumps(data), node_type_id=str(row[0]))
+                try:
+                    row = await conn.fetchrow(
+                        """
+                        UPDATE nodes SET data = $2::jsonb, data_compressed = $3, updated_at = $4
+                        WHERE id = $1
+                        RETURNING id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text,
+                               schema_version, (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
+                        """,
+                        id, data_value, compressed, datetime.now()
+                    )
+                except asyncpg.UniqueViolationError as e:
+                    raise _data_conflict(e) or e
+                await self._record_version(conn, id, str(row[1]), data_value, compressed, valid_from, None)
+
+        return self._row_to_node(row), value
+
+    @with_retry()
     async def delete(self, id: str) -> None:
-        """Delete a node by ID."""
-        query = "DELETE FROM nodes WHERE id = $1"
+        """Delete a node by ID. Its history is kept, with validity ending now."""
+        query = "DELETE FROM nodes WHERE id = $1 RETURNING node_type_id"
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                node_type_id = await conn.fetchval(query, id)
+                if node_type_id is None:
+                    raise NotFoundError(f"node not found: {id}")
+                await self._record_version(conn, id, str(node_type_id), None, None, None, None)
+
+    @with_retry()
+    async def delete_many(self, ids: List[str]) -> None:
+        """Delete several nodes atomically. Their history is kept, with validity ending now."""
+        query = "DELETE FROM nodes WHERE id = ANY($1::uuid[]) RETURNING id, node_type_id"
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                rows = await conn.fetch(query, ids)
+                deleted = {str(row[0]) for row in rows}
+                missing = [id for id in ids if id not in deleted]
+                if missing:
+                    raise NotFoundError(f"node not found: {missing[0]}")
+                for row in rows:
+                    await self._record_version(conn, str(row[0]), str(row[1]), None, None, None, None)
+
+    @with_retry()
+    async def record_correction(
+        self,
+        node_id: str,
+        node_type_id: str,
+        data: str,
+        valid_from: datetime,
+        valid_to: Optional[datetime],
+    ) -> NodeVersion:
+        """
+        Record corrected data for a valid-time interval without touching
+        other intervals. Earlier knowledge remains queryable by recorded time.
+        """
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                data_value, compressed = await self._encode(conn, data or "{}", node_type_id=node_type_id)
+                version_id = await self._record_version(
+                    conn, node_id, node_type_id, data_value, compressed, valid_from, valid_to
+                )
+                # Keep the current row in step when the correction covers now
+                try:
+                    await conn.execute(
+                        """
+                        UPDATE nodes SET data = $2::jsonb, data_compressed = $3, updated_at = NOW()
+                        WHERE id = $1 AND $4 <= NOW() AND ($5::timestamptz IS NULL OR $5 > NOW())
+                        """,
+                        node_id, data_value, compressed, valid_from, valid_to
+                    )
+                except asyncpg.UniqueViolationError as e:
+                    raise _data_conflict(e) or e
+                row = await conn.fetchrow(
+                    f"SELECT {_VERSION_COLUMNS} FROM node_versions WHERE version_id = $1",
+                    version_id
+                )
+
+        return self._row_to_version(row)
+
+    @with_retry(idempotent=True)
+    async def get_as_of(self, id: str, valid_at: datetime, recorded_at: datetime) -> NodeVersion:
+        """Retrieve a node as valid at valid_at, according to what was recorded at recorded_at."""
+        query = f"""
+            SELECT {_VERSION_COLUMNS}
+            FROM node_versions
+            WHERE node_id = $3 AND {_AS_OF_CONDITION}
+        """
 
         async with self.db.pool.acquire() as conn:
-            result = await conn.execute(query, id)
+            row = await conn.fetchrow(query, valid_at, recorded_at, id)
 
-        if result == "DELETE 0":
+        if not row:
             raise NotFoundError(f"node not found: {id}")
 
-    async def list(self, node_type_id: Optional[str], opts: ListOptions) -> Tuple[List[Node], ListResult]:
-        """Retrieve nodes with pagination and optional filtering."""
-        page_size = max(1, min(opts.page_size or 10, 100))
+        return self._row_to_version(row)
+
+    @with_retry(idempotent=True)
+    async def list_as_of(
+        self,
+        node_type_id: Optional[str],
+        valid_at: datetime,
+        recorded_at: datetime,
+        opts: ListOptions,
+    ) -> Tuple[List[NodeVersion], ListResult]:
+        """Retrieve nodes as valid at valid_at, according to what was recorded at recorded_at."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        self._check_data_query(order_by=opts.order_by)
+        order_clause = build_order_by(
+            opts.order_by, VERSION_SORTABLE_COLUMNS, json_column="data",
+            default="valid_from DESC", tiebreaker="node_id"
+        )
+        where = _AS_OF_CONDITION
+        args: list = [valid_at, recorded_at]
+        if node_type_id:
+            where += " AND node_type_id = $3"
+            args.append(node_type_id)
+        limit_param = len(args) + 1
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval(
+                f"SELECT COUNT(*) FROM node_versions WHERE {where}", *args
+            )
+            query = f"""
+                SELECT {_VERSION_COLUMNS}
+                FROM node_versions
+                WHERE {where}
+                {order_clause}
+                LIMIT ${limit_param} OFFSET ${limit_param + 1}
+            """
+            rows = await conn.fetch(query, *args, page_size, offset)
+
+        versions = [self._row_to_version(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(versions)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return versions, result
+
+    @with_retry(idempotent=True)
+    async def list_versions(self, id: str, opts: ListOptions) -> Tuple[List[NodeVersion], ListResult]:
+        """Retrieve the full bi-temporal history of a node, oldest knowledge first."""
+        page_size = opts.effective_page_size()
         offset = 0
         if opts.page_token:
             try:
@@ -106,31 +485,151 @@ class NodeRepository:
                 offset = 0
 
         async with self.db.pool.acquire() as conn:
-            # Build count query
-            if node_type_id:
-                total_count = await conn.fetchval(
-                    "SELECT COUNT(*) FROM nodes WHERE node_type_id = $1",
-                    node_type_id
+            total_count = await conn.fetchval(
+                "SELECT COUNT(*) FROM node_versions WHERE node_id = $1", id
+            )
+            query = f"""
+                SELECT {_VERSION_COLUMNS}
+                FROM node_versions
+                WHERE node_id = $1
+                ORDER BY recorded_from, valid_from, version_id
+                LIMIT $2 OFFSET $3
+            """
+            rows = await conn.fetch(query, id, page_size, offset)
+
+        versions = [self._row_to_version(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(versions)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return versions, result
+
+    async def _encode(
+        self, conn: asyncpg.Connection, data: str, node_type_id: str = "", node_id: str = ""
+    ) -> Tuple[str, Optional[bytes]]:
+        """
+        Prepare a node document for storage like encode_data, except that the
+        documents of node types with unique constraints stay plain JSONB,
+        which the constraints' indexes read.
+
+        Raises:
+            ValueError: If the document would be encrypted
+        """
+        data_value, compressed = encode_data(data, "node", self.data_key)
+        if compressed is None or not await conn.fetchval(_HAS_UNIQUE_CONSTRAINTS, node_type_id or None, node_id or None):
+            return data_value, compressed
+        if self.data_key is not None:
+            raise ValueError("documents of node types with unique constraints cannot be encrypted")
+        return data, None
+
+    async def _record_version(
+        self,
+        conn: asyncpg.Connection,
+        node_id: str,
+        node_type_id: str,
+        data_value: Optional[str],
+        compressed: Optional[bytes],
+        valid_from: Optional[datetime],
+        valid_to: Optional[datetime],
+    ) -> Optional[int]:
+        """
+        Record the node's data for [valid_from, valid_to) at the current
+        transaction time (data_value None records that the node ceases to exist).
+
+        Current versions overlapping the interval are closed; the parts of
+        them outside the interval are re-recorded unchanged.
+        """
+        now = await conn.fetchval("SELECT NOW()")
+        valid_from = valid_from or now
+
+        overlapping = await conn.fetch(
+            """
+            UPDATE node_versions SET recorded_to = NOW()
+            WHERE node_id = $1 AND recorded_to IS NULL
+              AND (valid_to IS NULL OR valid_to > $2)
+              AND ($3::timestamptz IS NULL OR valid_from < $3)
+            RETURNING node_type_id, data::text, data_compressed, valid_from, valid_to
+            """,
+            node_id, valid_from, valid_to
+        )
+
+        insert = """
+            INSERT INTO node_versions (
+                node_id, node_type_id, data, data_compressed, valid_from, valid_to, recorded_from, recorded_by
+            )
+            VALUES ($1, $2, $3::jsonb, $4, $5, $6, NOW(), $7)
+            RETURNING version_id
+        """
+        actor = current_actor()
+        for v in overlapping:
+            if v["valid_from"] < valid_from:
+                await conn.execute(
+                    insert, node_id, v["node_type_id"], v["data"], v["data_compressed"],
+                    v["valid_from"], valid_from, actor
                 )
-                query = """
-                    SELECT id, node_type_id, data::text, created_at, updated_at 
-                    FROM nodes 
-                    WHERE node_type_id = $1
-                    ORDER BY created_at DESC 
-                    LIMIT $2 OFFSET $3
-                """
-                rows = await conn.fetch(query, node_type_id, page_size, offset)
-            else:
-                total_count = await conn.fetchval(
-                    "SELECT COUNT(*) FROM nodes"
+            if valid_to is not None and (v["valid_to"] is None or v["valid_to"] > valid_to):
+                await conn.execute(
+                    insert, node_id, v["node_type_id"], v["data"], v["data_compressed"],
+                    valid_to, v["valid_to"], actor
                 )
-                query = """
-                    SELECT id, node_type_id, data::text, created_at, updated_at 
-                    FROM nodes 
-                    ORDER BY created_at DESC 
-                    LIMIT $1 OFFSET $2
-                """
-                rows = await conn.fetch(query, page_size, offset)
+
+        if data_value is None:
+            return None
+        return await conn.fetchval(
+            insert, node_id, node_type_id, data_value, compressed, valid_from, valid_to, actor
+        )
+
+    @with_retry(idempotent=True)
+    async def list(
+        self,
+        node_type_id: Optional[str],
+        opts: ListOptions,
+        include_data: bool = True,
+        metadata_contains: Optional[Dict[str, Any]] = None,
+        filters: Optional[NodeFilter] = None,
+    ) -> Tuple[List[Node], ListResult]:
+        """Retrieve nodes with pagination and optional filtering.
+
+        With include_data False the data column is not read, so list screens
+        that only need identifiers avoid transferring large payloads.
+        metadata_contains selects nodes whose metadata contains that object.
+        filters, a whole node filter (such as a view's), replaces both.
+        """
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        self._check_data_query(filters, opts.order_by)
+        order_clause = build_order_by(opts.order_by, SORTABLE_COLUMNS, json_column="data")
+        data_columns = (
+            "data::text, created_at, updated_at, data_compressed, metadata::text, schema_version, "
+            "(SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)"
+        )
+        if not include_data:
+            data_columns = (
+                "NULL, created_at, updated_at, NULL, metadata::text, schema_version, "
+                "(SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)"
+            )
+
+        where_clause, args = node_filter_clause(
+            filters or NodeFilter(node_type_id=node_type_id or "", metadata_contains=metadata_contains)
+        )
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval(f"SELECT COUNT(*) FROM nodes{where_clause}", *args)
+            query = f"""
+                SELECT id, node_type_id, {data_columns}
+                FROM nodes{where_clause}
+                {order_clause}
+                LIMIT ${len(args) + 1} OFFSET ${len(args) + 2}
+            """
+            rows = await conn.fetch(query, *args, page_size, offset)
 
         nodes = [self._row_to_node(row) for row in rows]
 
@@ -141,6 +640,202 @@ class NodeRepository:
 
         return nodes, result
 
+    @with_retry(idempotent=True)
+    async def count_matching(self, filters: NodeFilter) -> int:
+        """Count nodes matching a bulk operation filter."""
+        self._check_data_query(filters)
+        where_clause, args = node_filter_clause(filters)
+
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval(f"SELECT COUNT(*) FROM nodes{where_clause}", *args)
+
+    @with_retry(idempotent=True)
+    async def list_ids_matching(self, filters: NodeFilter, limit: int) -> List[str]:
+        """Retrieve the IDs of up to limit nodes matching a bulk operation filter."""
+        self._check_data_query(filters)
+        where_clause, args = node_filter_clause(filters)
+        query = f"SELECT id FROM nodes{where_clause} ORDER BY created_at, id LIMIT ${len(args) + 1}"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, *args, limit)
+
+        return [str(row[0]) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_matching(
+        self, filters: NodeFilter, after_id: str, limit: int, changed_since: str = ""
+    ) -> List[Node]:
+        """
+        Retrieve up to limit nodes matching a filter, with data, ordered by ID after after_id.
+
+        With changed_since (a transaction ID horizon from TombstoneRepository.sync_position),
+        only nodes written by transactions at or after it are retrieved.
+        """
+        self._check_data_query(filters)
+        where_clause, args = node_filter_clause(filters)
+        if after_id:
+            args.append(after_id)
+            where_clause += f"{' AND' if where_clause else ' WHERE'} id > ${len(args)}"
+        if changed_since:
+            args.append(changed_since)
+            where_clause += f"{' AND' if where_clause else ' WHERE'} change_xid >= ${len(args)}::text::xid8"
+        query = f"""
+            SELECT id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
+            FROM nodes{where_clause}
+            ORDER BY id
+            LIMIT ${len(args) + 1}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, *args, limit)
+
+        return [self._row_to_node(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def count_below_schema_version(self, node_type_id: str, schema_version: int) -> int:
+        """Count nodes of a type whose data is shaped for an older schema version."""
+        query = "SELECT COUNT(*) FROM nodes WHERE node_type_id = $1 AND schema_version < $2"
+
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval(query, node_type_id, schema_version)
+
+    @with_retry(idempotent=True)
+    async def list_below_schema_version(self, node_type_id: str, schema_version: int, limit: int) -> List[Node]:
+        """Retrieve up to limit nodes of a type whose data is shaped for an older schema version."""
+        query = """
+            SELECT id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id)
+            FROM nodes
+            WHERE node_type_id = $1 AND schema_version < $2
+            ORDER BY id
+            LIMIT $3
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_type_id, schema_version, limit)
+
+        return [self._row_to_node(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def store_migrated_data(self, node: Node, from_version: int) -> bool:
+        """
+        Store data migrated to node.schema_version, unless the node was written
+        since it was read (returns False then). Migrating only reshapes the
+        data, so updated_at, the etag and the recorded history are unchanged.
+        """
+        query = """
+            UPDATE nodes SET data = $2::jsonb, data_compressed = $3, schema_version = $4
+            WHERE id = $1 AND schema_version = $5 AND updated_at = $6
+        """
+
+        async with self.db.pool.acquire() as conn:
+            data_value, compressed = await self._encode(conn, node.data or "{}", node_id=node.id)
+            try:
+                result = await conn.execute(
+                    query, node.id, data_value, compressed, node.schema_version, from_version, node.updated_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise _data_conflict(e) or e
+
+        return result != "UPDATE 0"
+
+    @with_retry(idempotent=True)
+    async def search_text(
+        self,
+        query: str,
+        node_type_id: Optional[str],
+        offset: int,
+        limit: int,
+        filters: Optional[NodeFilter] = None,
+    ) -> Tuple[List[Tuple[Node, float]], int]:
+        """
+        Full-text search of node data (words and phrases, websearch syntax), best
+        matches first; returns a page of (node, score) and the total number of
+        matches. Compressed documents are not searched, and tenants with
+        encryption cannot search. filters, a whole node filter, replaces node_type_id.
+        """
+        check_data_query("full-text search", self.data_key)
+        self._check_data_query(filters)
+        where = "to_tsvector('simple', data::text) @@ q"
+        filter_clause, filter_args = node_filter_clause(
+            filters or NodeFilter(node_type_id=node_type_id or ""), first_arg=2
+        )
+        if filter_clause:
+            where += " AND " + filter_clause[len(" WHERE "):]
+        args: List[Any] = [query, *filter_args]
+        count_query = f"SELECT COUNT(*) FROM nodes, websearch_to_tsquery('simple', $1) q WHERE {where}"
+        list_query = f"""
+            SELECT id, node_type_id, data::text, created_at, updated_at, data_compressed, metadata::text, schema_version,
+                   (SELECT t.schema_version FROM node_types t WHERE t.id = nodes.node_type_id),
+                   ts_rank(to_tsvector('simple', data::text), q) AS score
+            FROM nodes, websearch_to_tsquery('simple', $1) q
+            WHERE {where}
+            ORDER BY score DESC, id
+            LIMIT ${len(args) + 1} OFFSET ${len(args) + 2}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            total = await conn.fetchval(count_query, *args)
+            rows = await conn.fetch(list_query, *args, limit, offset)
+
+        return [(self._row_to_node(row), float(row["score"])) for row in rows], total
+
+    @with_retry(idempotent=True)
+    async def distinct_values(
+        self,
+        field: str,
+        node_type_id: Optional[str],
+        limit: int
+    ) -> List[FacetValue]:
+        """Count nodes per distinct value of a column or JSON data path."""
+        if field.startswith("data."):
+            check_data_query("distinct values of data paths", self.data_key)
+        where, args = "", []
+        if node_type_id:
+            where, args = "node_type_id = $1", [node_type_id]
+        return await fetch_distinct_values(self.db, "nodes", field, FACET_COLUMNS, where, args, limit)
+
+    @with_retry(idempotent=True)
+    async def date_histogram(
+        self,
+        field: str,
+        node_type_id: Optional[str],
+        interval: str,
+        timezone: str,
+        start: Optional[datetime],
+        end: Optional[datetime],
+    ) -> List[FacetValue]:
+        """Count nodes per local calendar interval of a timestamp column."""
+        where, args = "", []
+        if node_type_id:
+            where, args = "node_type_id = $1", [node_type_id]
+        return await fetch_date_histogram(
+            self.db, "nodes", field, DATE_COLUMNS, where, args, interval, timezone, start, end
+        )
+
+    def _check_data_query(self, filters: Optional[NodeFilter] = None, order_by: str = "") -> None:
+        """Refuse filters and ordering on data paths when documents are encrypted (see check_data_query)."""
+        if filters is not None and filters.queries_data():
+            check_data_query("filtering on data", self.data_key)
+        if orders_by_json(order_by, "data"):
+            check_data_query("ordering by data paths", self.data_key)
+
+    def _row_to_version(self, row: asyncpg.Record) -> NodeVersion:
+        """Convert a node_versions row to a NodeVersion object."""
+        return NodeVersion(
+            version_id=row[0],
+            node_id=str(row[1]),
+            node_type_id=str(row[2]),
+            data=row[3] or "{}",
+            valid_from=row[4],
+            valid_to=row[5],
+            recorded_from=row[6],
+            recorded_to=row[7],
+            compressed_data=row[8],
+            recorded_by=row[9],
+        )
+
     def _row_to_node(self, row: asyncpg.Record) -> Node:
         """Convert a database row to a Node object."""
         return Node(
@@ -150,4 +845,80 @@ class NodeRepository:
             data=row[2] or "{}",
             created_at=row[3],
             updated_at=row[4],
+            compressed_data=row[5],
+            metadata=json.loads(row[6]) if row[6] else {},
+            schema_version=row[7],
+            latest_schema_version=row[8],
         )
+
+
+def _metadata_expression(first: int) -> str:
+    """
+    SQL for the new metadata, taking the four _metadata_args from parameter
+    first on: whether to change it, whether to replace it, keys to set, keys to remove.
+    """
+    changed, replace, values, removed = (f"${first + i}" for i in range(4))
+    return f"""
+        CASE WHEN NOT {changed}::boolean THEN metadata
+             WHEN {replace}::boolean THEN {values}::jsonb
+             ELSE (metadata || {values}::jsonb) - {removed}::text[] END
+    """
+
+
+def _metadata_args(metadata: Optional[MetadataUpdate]) -> List[Any]:
+    """Arguments of _metadata_expression."""
+    if metadata is None:
+        return [False, False, "{}", []]
+    return [True, metadata.replace, json.dumps(metadata.set), metadata.remove]
+
+
+def node_filter_clause(filters: NodeFilter, first_arg: int = 1) -> Tuple[str, List[Any]]:
+    """Build a WHERE clause and its arguments (numbered from first_arg) for a node filter."""
+    conditions: List[str] = []
+    args: List[Any] = []
+
+    def add(condition: str, value: Any) -> None:
+        args.append(value)
+        conditions.append(condition.format(f"${len(args) + first_arg - 1}"))
+
+    if filters.node_type_id:
+        add("node_type_id = {}", filters.node_type_id)
+    if filters.data_contains:
+        add("data @> {}::jsonb", json.dumps(filters.data_contains))
+    if filters.metadata_contains:
+        add("metadata @> {}::jsonb", json.dumps(filters.metadata_contains))
+    if filters.created_after:
+        add("created_at >= {}", filters.created_after)
+    if filters.created_before:
+        add("created_at < {}", filters.created_before)
+    if filters.updated_after:
+        add("updated_at >= {}", filters.updated_after)
+    if filters.updated_before:
+        add("updated_at < {}", filters.updated_before)
+    for data_range in filters.data_ranges or []:
+        add(data_range_condition(data_range), data_range.value)
+
+    if not conditions:
+        return "", []
+    return " WHERE " + " AND ".join(conditions), args
+
+
+_RANGE_OPERATORS = {"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+
+
+def data_range_condition(data_range: DataRange) -> str:
+    """
+    Render a data range as a condition with a {} placeholder for its bound.
+
+    Fields of another JSON type than the comparison's never match, and
+    timestamps compare by time (see data_timestamp in migration 026).
+    """
+    # Braces are doubled: the condition is a format string
+    field = json_path_expression("data", ".".join(data_range.path)).replace("{", "{{").replace("}", "}}")
+    op = _RANGE_OPERATORS[data_range.op]
+    if data_range.kind == "number":
+        return f"(CASE WHEN jsonb_typeof({field}) = 'number' THEN ({field})::numeric END) {op} {{}}::text::numeric"
+    text = f"(CASE WHEN jsonb_typeof({field}) = 'string' THEN {field} #>> '{{{{}}}}' END)"
+    if data_range.kind == "timestamp":
+        return f"data_timestamp({text}) {op} {{}}::timestamptz"
+    return f"{text} {op} {{}}::text"
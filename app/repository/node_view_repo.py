@@ -0,0 +1,157 @@
+"""
+Node view repository implementation.
+"""
+
+import json
+import uuid
+from datetime import datetime, timezone
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import NodeView, ListOptions, ListResult
+from app.repository.errors import AlreadyExistsError, NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, name, description, filter::text, projection::text, parameters::text, created_at, updated_at"
+
+
+class NodeViewRepository:
+    """PostgreSQL node view repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, view: NodeView) -> NodeView:
+        """
+        Create a new view.
+
+        Raises:
+            AlreadyExistsError: If a view has the name
+        """
+        view.id = str(uuid.uuid4())
+        view.created_at = datetime.now(timezone.utc)
+        view.updated_at = view.created_at
+
+        query = f"""
+            INSERT INTO node_views (id, name, description, filter, projection, parameters, created_at, updated_at)
+            VALUES ($1, $2, $3, $4::jsonb, $5::jsonb, $6::jsonb, $7, $8)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(
+                    query,
+                    view.id, view.name, view.description, json.dumps(view.filter), json.dumps(view.projection),
+                    json.dumps(view.parameters), view.created_at, view.updated_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(f"view already exists: {view.name}") from e
+
+        return self._row_to_view(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> NodeView:
+        """Retrieve a view by ID."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(f"SELECT {_COLUMNS} FROM node_views WHERE id = $1", id)
+
+        if not row:
+            raise NotFoundError(f"view not found: {id}")
+
+        return self._row_to_view(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_name(self, name: str) -> NodeView:
+        """Retrieve a view by name."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(f"SELECT {_COLUMNS} FROM node_views WHERE name = $1", name)
+
+        if not row:
+            raise NotFoundError(f"view not found: {name}")
+
+        return self._row_to_view(row)
+
+    @with_retry()
+    async def update(self, view: NodeView) -> NodeView:
+        """
+        Update a view's definition.
+
+        Raises:
+            AlreadyExistsError: If another view has the name
+        """
+        view.updated_at = datetime.now(timezone.utc)
+
+        query = f"""
+            UPDATE node_views
+            SET name = $2, description = $3, filter = $4::jsonb, projection = $5::jsonb, parameters = $6::jsonb,
+                updated_at = $7
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(
+                    query,
+                    view.id, view.name, view.description, json.dumps(view.filter), json.dumps(view.projection),
+                    json.dumps(view.parameters), view.updated_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(f"view already exists: {view.name}") from e
+
+        if not row:
+            raise NotFoundError(f"view not found: {view.id}")
+
+        return self._row_to_view(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete a view by ID."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM node_views WHERE id = $1", id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"view not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list(self, opts: ListOptions) -> Tuple[List[NodeView], ListResult]:
+        """Retrieve views by name with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM node_views")
+            rows = await conn.fetch(
+                f"SELECT {_COLUMNS} FROM node_views ORDER BY name LIMIT $1 OFFSET $2", page_size, offset
+            )
+
+        views = [self._row_to_view(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(views)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return views, result
+
+    def _row_to_view(self, row: asyncpg.Record) -> NodeView:
+        """Convert a database row to a NodeView object."""
+        return NodeView(
+            id=str(row[0]),
+            name=row[1],
+            description=row[2] or "",
+            filter=json.loads(row[3]),
+            projection=json.loads(row[4]),
+            parameters=json.loads(row[5]),
+            created_at=row[6],
+            updated_at=row[7],
+        )
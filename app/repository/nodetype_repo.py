@@ -11,6 +11,10 @@ import asyncpg
 from app.db.database import Database
 from app.repository.models import NodeType, ListOptions, ListResult
 from app.repository.errors import NotFoundError
+from app.repository.ordering import build_order_by
+from app.repository.retry import with_retry
+
+SORTABLE_COLUMNS = ("name", "created_at", "updated_at")
 
 
 class NodeTypeRepository:
@@ -19,9 +23,10 @@ class NodeTypeRepository:
     def __init__(self, db: Database):
         self.db = db
 
+    @with_retry()
     async def create(self, node_type: NodeType) -> NodeType:
-        """Create a new node type."""
-        node_type.id = str(uuid.uuid4())
+        """Create a new node type; a node_type.id set by the caller is used instead of a generated one."""
+        node_type.id = node_type.id or str(uuid.uuid4())
         node_type.created_at = datetime.now()
         node_type.updated_at = datetime.now()
 
@@ -33,7 +38,7 @@ class NodeTypeRepository:
         query = """
             INSERT INTO node_types (id, name, description, schema, created_at, updated_at)
             VALUES ($1, $2, $3, $4::jsonb, $5, $6)
-            RETURNING id, name, description, COALESCE(schema::text, ''), created_at, updated_at
+            RETURNING id, name, description, COALESCE(schema::text, ''), created_at, updated_at, schema_version
         """
 
         async with self.db.pool.acquire() as conn:
@@ -46,10 +51,11 @@ class NodeTypeRepository:
 
         return self._row_to_node_type(row)
 
+    @with_retry(idempotent=True)
     async def get_by_id(self, id: str) -> NodeType:
         """Retrieve a node type by ID."""
         query = """
-            SELECT id, name, description, COALESCE(schema::text, ''), created_at, updated_at 
+            SELECT id, name, description, COALESCE(schema::text, ''), created_at, updated_at, schema_version 
             FROM node_types 
             WHERE id = $1
         """
@@ -62,6 +68,7 @@ class NodeTypeRepository:
 
         return self._row_to_node_type(row)
 
+    @with_retry()
     async def update(self, node_type: NodeType) -> NodeType:
         """Update an existing node type."""
         node_type.updated_at = datetime.now()
@@ -75,7 +82,7 @@ class NodeTypeRepository:
             UPDATE node_types 
             SET name = $2, description = $3, schema = $4::jsonb, updated_at = $5
             WHERE id = $1
-            RETURNING id, name, description, COALESCE(schema::text, ''), created_at, updated_at
+            RETURNING id, name, description, COALESCE(schema::text, ''), created_at, updated_at, schema_version
         """
 
         async with self.db.pool.acquire() as conn:
@@ -91,6 +98,7 @@ class NodeTypeRepository:
 
         return self._row_to_node_type(row)
 
+    @with_retry()
     async def delete(self, id: str) -> None:
         """Delete a node type by ID."""
         query = "DELETE FROM node_types WHERE id = $1"
@@ -101,9 +109,10 @@ class NodeTypeRepository:
         if result == "DELETE 0":
             raise NotFoundError(f"node_type not found: {id}")
 
+    @with_retry(idempotent=True)
     async def list(self, opts: ListOptions) -> Tuple[List[NodeType], ListResult]:
         """Retrieve node types with pagination."""
-        page_size = max(1, min(opts.page_size or 10, 100))
+        page_size = opts.effective_page_size()
         offset = 0
         if opts.page_token:
             try:
@@ -111,15 +120,17 @@ class NodeTypeRepository:
             except ValueError:
                 offset = 0
 
+        order_clause = build_order_by(opts.order_by, SORTABLE_COLUMNS)
+
         async with self.db.pool.acquire() as conn:
             total_count = await conn.fetchval(
                 "SELECT COUNT(*) FROM node_types"
             )
 
-            query = """
-                SELECT id, name, description, COALESCE(schema::text, ''), created_at, updated_at 
+            query = f"""
+                SELECT id, name, description, COALESCE(schema::text, ''), created_at, updated_at, schema_version 
                 FROM node_types 
-                ORDER BY created_at DESC 
+                {order_clause}
                 LIMIT $1 OFFSET $2
             """
             rows = await conn.fetch(query, page_size, offset)
@@ -143,4 +154,5 @@ class NodeTypeRepository:
             schema=row[3] or "",
             created_at=row[4],
             updated_at=row[5],
+            schema_version=row[6],
         )
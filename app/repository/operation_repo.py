@@ -0,0 +1,190 @@
+"""
+Operation repository implementation.
+"""
+
+import json
+import uuid
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import Operation, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = """
+    id, kind, status, params::text, total_count, processed_count, affected_count,
+    failed_count, errors::text, error, created_at, updated_at, completed_at, result::text
+"""
+
+
+class OperationRepository:
+    """PostgreSQL long-running operation repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, op: Operation) -> Operation:
+        """Create a running operation."""
+        op.id = str(uuid.uuid4())
+        op.created_at = datetime.now()
+        op.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO operations (id, kind, status, params, total_count, created_at, updated_at)
+            VALUES ($1, $2, $3, $4::jsonb, $5, $6, $7)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                op.id, op.kind, op.status, json.dumps(op.params), op.total_count,
+                op.created_at, op.updated_at
+            )
+
+        return self._row_to_operation(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> Operation:
+        """Retrieve an operation by ID."""
+        query = f"SELECT {_COLUMNS} FROM operations WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"operation not found: {id}")
+
+        return self._row_to_operation(row)
+
+    @with_retry()
+    async def confirm(self, id: str, kind: str, total_count: int, not_before: datetime) -> Optional[Operation]:
+        """
+        Start an operation awaiting confirmation, if it was prepared after not_before.
+
+        Returns None if there is no such operation (unknown, expired or already confirmed).
+        """
+        query = f"""
+            UPDATE operations
+            SET status = 'running', total_count = $3, updated_at = NOW()
+            WHERE id = $1 AND kind = $2 AND status = 'awaiting_confirmation' AND created_at >= $4
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, kind, total_count, not_before)
+
+        return self._row_to_operation(row) if row else None
+
+    @with_retry()
+    async def set_status(self, id: str, kinds: List[str], from_status: str, to_status: str) -> Optional[Operation]:
+        """
+        Move an operation of one of kinds from one status to another, such as running to paused.
+
+        Returns None if there is no such operation in from_status.
+        """
+        query = f"""
+            UPDATE operations
+            SET status = $4, updated_at = NOW()
+            WHERE id = $1 AND kind = ANY($2) AND status = $3
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, kinds, from_status, to_status)
+
+        return self._row_to_operation(row) if row else None
+
+    @with_retry(idempotent=True)
+    async def update_progress(
+        self,
+        id: str,
+        processed_count: int,
+        affected_count: int,
+        failed_count: int,
+        errors: List[Dict[str, str]],
+    ) -> None:
+        """Record the progress of a running operation."""
+        query = """
+            UPDATE operations
+            SET processed_count = $2, affected_count = $3, failed_count = $4,
+                errors = $5::jsonb, updated_at = NOW()
+            WHERE id = $1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            await conn.execute(query, id, processed_count, affected_count, failed_count, json.dumps(errors))
+
+    @with_retry()
+    async def finish(
+        self, id: str, status: str, error: str = "", result: Optional[Dict[str, Any]] = None
+    ) -> Operation:
+        """Mark an operation completed or failed, with the summary it reports."""
+        query = f"""
+            UPDATE operations
+            SET status = $2, error = $3, result = $4::jsonb, updated_at = NOW(), completed_at = NOW()
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, status, error or None, json.dumps(result or {}))
+
+        if not row:
+            raise NotFoundError(f"operation not found: {id}")
+
+        return self._row_to_operation(row)
+
+    @with_retry(idempotent=True)
+    async def list(self, opts: ListOptions) -> Tuple[List[Operation], ListResult]:
+        """Retrieve operations, newest first, with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM operations")
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM operations
+                ORDER BY created_at DESC
+                LIMIT $1 OFFSET $2
+            """
+            rows = await conn.fetch(query, page_size, offset)
+
+        operations = [self._row_to_operation(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(operations)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return operations, result
+
+    def _row_to_operation(self, row: asyncpg.Record) -> Operation:
+        """Convert a database row to an Operation object."""
+        return Operation(
+            id=str(row[0]),
+            kind=row[1],
+            status=row[2],
+            params=json.loads(row[3]),
+            total_count=row[4],
+            processed_count=row[5],
+            affected_count=row[6],
+            failed_count=row[7],
+            errors=json.loads(row[8]),
+            error=row[9] or "",
+            created_at=row[10],
+            updated_at=row[11],
+            completed_at=row[12],
+            result=json.loads(row[13]) if row[13] else {},
+        )
@@ -0,0 +1,120 @@
+"""
+Multi-field ORDER BY support for list queries.
+
+Parses client-supplied sort specifications such as
+``"data.priority desc, created_at asc"`` into a safe SQL ORDER BY clause.
+
+Only whitelisted columns are accepted. JSON data paths are validated and
+rendered as literal ``data #> '{a,b}'`` expressions (never as bind
+parameters) so PostgreSQL can match them against expression indexes like
+``CREATE INDEX ON nodes ((data #> '{priority}'))``.
+"""
+
+import re
+from dataclasses import dataclass
+from typing import List, Optional, Sequence
+
+_SEGMENT_RE = re.compile(r"^[A-Za-z0-9_\-]+$")
+MAX_SORT_FIELDS = 5
+
+
+@dataclass
+class SortField:
+    """A single parsed sort field."""
+    expression: str
+    descending: bool = False
+
+    def to_sql(self) -> str:
+        direction = "DESC NULLS LAST" if self.descending else "ASC NULLS FIRST"
+        return f"{self.expression} {direction}"
+
+
+def parse_order_by(
+    order_by: str,
+    columns: Sequence[str],
+    json_column: Optional[str] = None,
+) -> List[SortField]:
+    """
+    Parse an order_by specification into sort fields.
+
+    Args:
+        order_by: Comma-separated list of ``field [asc|desc]`` entries
+        columns: Column names that may be sorted on
+        json_column: JSONB column whose paths may be sorted on (e.g. "data")
+
+    Raises:
+        ValueError: If a field or direction is not allowed
+    """
+    fields: List[SortField] = []
+    if not order_by or not order_by.strip():
+        return fields
+
+    for part in order_by.split(","):
+        tokens = part.split()
+        if not tokens:
+            continue
+        if len(tokens) > 2:
+            raise ValueError(f"invalid order_by clause: {part.strip()}")
+
+        name = tokens[0]
+        direction = tokens[1].lower() if len(tokens) == 2 else "asc"
+        if direction not in ("asc", "desc"):
+            raise ValueError(f"invalid sort direction: {tokens[1]}")
+
+        fields.append(SortField(_field_expression(name, columns, json_column), direction == "desc"))
+
+    if len(fields) > MAX_SORT_FIELDS:
+        raise ValueError(f"order_by supports at most {MAX_SORT_FIELDS} fields")
+    return fields
+
+
+def build_order_by(
+    order_by: str,
+    columns: Sequence[str],
+    json_column: Optional[str] = None,
+    default: str = "created_at DESC",
+    tiebreaker: str = "id",
+) -> str:
+    """
+    Build an ORDER BY clause for a list query.
+
+    Falls back to ``default`` when no order is requested, and always appends
+    ``tiebreaker`` so offset pagination is stable across pages.
+    """
+    fields = parse_order_by(order_by, columns, json_column)
+    parts = [f.to_sql() for f in fields] if fields else [default]
+    if tiebreaker and not any(f.expression == tiebreaker for f in fields):
+        parts.append(tiebreaker)
+    return " ORDER BY " + ", ".join(parts)
+
+
+def orders_by_json(order_by: str, json_column: str) -> bool:
+    """Whether an order_by specification sorts on paths of the JSONB column."""
+    return any(
+        part.split() and part.split()[0].startswith(json_column + ".") for part in (order_by or "").split(",")
+    )
+
+
+def _field_expression(name: str, columns: Sequence[str], json_column: Optional[str]) -> str:
+    """Translate a field name into a safe SQL expression."""
+    if name in columns:
+        return name
+
+    if json_column and name.startswith(json_column + "."):
+        return json_path_expression(json_column, name[len(json_column) + 1:])
+
+    raise ValueError(f"cannot order by field: {name}")
+
+
+def json_path_expression(json_column: str, path: str) -> str:
+    """
+    Render a dotted JSON path (e.g. "address.city") as a literal jsonb
+    path expression on the given column.
+
+    Raises:
+        ValueError: If any path segment contains unsafe characters
+    """
+    segments = path.split(".")
+    if not path or not all(_SEGMENT_RE.match(seg) for seg in segments):
+        raise ValueError(f"invalid data path: {json_column}.{path}")
+    return f"{json_column} #> '{{{','.join(segments)}}}'"
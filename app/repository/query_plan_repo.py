@@ -0,0 +1,45 @@
+"""
+Query plan repository implementation.
+"""
+
+import json
+from typing import Any, Dict, Sequence
+
+from app.db.database import Database
+from app.repository.retry import with_retry
+
+# Longest an explained statement may run
+EXPLAIN_TIMEOUT_MS = 30000
+
+
+class QueryPlanRepository:
+    """Explains statements on a database."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def database_name(self) -> str:
+        """Name of the connected database."""
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval("SELECT current_database()")
+
+    @with_retry(idempotent=True)
+    async def explain(self, query: str, args: Sequence[Any], analyze: bool = True) -> Dict[str, Any]:
+        """
+        Return the plan of a statement (PostgreSQL's EXPLAIN FORMAT JSON); with
+        analyze, the statement runs and the plan has actual rows, times and buffers.
+
+        The statement runs in a read-only transaction that is rolled back, so
+        statements that write fail instead of writing.
+        """
+        options = "ANALYZE, BUFFERS, FORMAT JSON" if analyze else "FORMAT JSON"
+        async with self.db.pool.acquire() as conn:
+            tr = conn.transaction(readonly=True)
+            await tr.start()
+            try:
+                await conn.execute(f"SET LOCAL statement_timeout = {EXPLAIN_TIMEOUT_MS}")
+                plan = await conn.fetchval(f"EXPLAIN ({options}) {query}", *args)
+            finally:
+                await tr.rollback()
+        return (json.loads(plan) if isinstance(plan, str) else plan)[0]
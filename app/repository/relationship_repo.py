@@ -2,51 +2,156 @@
 Relationship repository implementation.
 """
 
+import json
 import uuid
 from datetime import datetime
-from typing import List, Optional, Tuple
+from typing import Any, List, Optional, Tuple
 
 import asyncpg
 
 from app.db.database import Database
-from app.repository.models import Relationship, ListOptions, ListResult
-from app.repository.errors import NotFoundError
+from app.repository.models import (
+    Relationship, RelationshipFilter, RelationshipType, FacetValue, ListOptions, ListResult, NodeFilter,
+)
+from app.repository.node_repo import node_filter_clause
+from app.repository.errors import AlreadyExistsError, NotFoundError, PreconditionFailedError
+from app.repository.ordering import build_order_by, orders_by_json
+from app.repository.encryption import DataKey
+from app.repository.compression import check_data_query, encode_data
+from app.repository.facets import fetch_date_histogram, fetch_distinct_values
+from app.repository.retry import with_retry
+
+SORTABLE_COLUMNS = (
+    "relationship_type", "source_node_id", "target_node_id", "created_at", "updated_at",
+)
+FACET_COLUMNS = ("relationship_type", "source_node_id", "target_node_id")
+DATE_COLUMNS = ("created_at", "updated_at")
+_COLUMNS = """
+    id, source_node_id, target_node_id, relationship_type, data::text, created_at, updated_at, data_compressed,
+    valid_from, valid_to
+"""
+# Relationships valid at ${n} (NULL = now): the time expansions and derivations follow them at
+_VALID_AT = "(r.valid_from IS NULL OR r.valid_from <= COALESCE(${n}::timestamptz, NOW())) " \
+    "AND (r.valid_to IS NULL OR r.valid_to > COALESCE(${n}::timestamptz, NOW()))"
+# Endpoint columns of a derivation step: (node walked from, node walked to)
+_STEP_ENDS = {"out": ("source_node_id", "target_node_id"), "in": ("target_node_id", "source_node_id")}
 
 
 class RelationshipRepository:
     """PostgreSQL relationship repository."""
 
-    def __init__(self, db: Database):
+    def __init__(self, db: Database, data_key: Optional[DataKey] = None):
         self.db = db
+        # The tenant's active data key when its documents are stored encrypted
+        self.data_key = data_key
 
+    @with_retry()
     async def create(self, rel: Relationship) -> Relationship:
-        """Create a new relationship."""
-        rel.id = str(uuid.uuid4())
+        """
+        Create a new relationship; a rel.id set by the caller is used instead of a generated one.
+
+        Raises:
+            AlreadyExistsError: If a relationship with that ID exists
+        """
+        async with self.db.pool.acquire() as conn:
+            row = await self._insert(conn, rel)
+
+        return self._row_to_relationship(row)
+
+    @with_retry()
+    async def create_unless_exists(self, rel: Relationship, undirected: bool = False) -> Tuple[Relationship, bool]:
+        """
+        Create a new relationship unless one with the same source, target and
+        type exists (in either orientation when undirected). Returns the
+        created or the oldest existing relationship, and whether it was created.
+
+        Concurrent creates of the same relationship are serialized, so only one of them creates it.
+
+        Raises:
+            AlreadyExistsError: If a relationship with rel.id exists
+        """
+        source_id, target_id = rel.source_node_id, rel.target_node_id
+        ends = sorted((source_id, target_id)) if undirected else [source_id, target_id]
+        reverse = "OR (source_node_id = $2 AND target_node_id = $1)" if undirected else ""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationships
+            WHERE relationship_type = $3 AND ((source_node_id = $1 AND target_node_id = $2) {reverse})
+            ORDER BY created_at, id
+            LIMIT 1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                await conn.execute(
+                    "SELECT pg_advisory_xact_lock(hashtext($1))", f"{rel.relationship_type}\n{ends[0]}\n{ends[1]}"
+                )
+                row = await conn.fetchrow(query, source_id, target_id, rel.relationship_type)
+                if row:
+                    return self._row_to_relationship(row), False
+                row = await self._insert(conn, rel)
+
+        return self._row_to_relationship(row), True
+
+    async def _insert(self, conn: asyncpg.Connection, rel: Relationship) -> asyncpg.Record:
+        rel.id = rel.id or str(uuid.uuid4())
         rel.created_at = datetime.now()
         rel.updated_at = datetime.now()
 
         if not rel.data:
             rel.data = "{}"
+        data_value, compressed = encode_data(rel.data, "relationship", self.data_key)
 
-        query = """
-            INSERT INTO relationships (id, source_node_id, target_node_id, relationship_type, data, created_at, updated_at)
-            VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7)
-            RETURNING id, source_node_id, target_node_id, relationship_type, data::text, created_at, updated_at
+        query = f"""
+            INSERT INTO relationships (
+                id, source_node_id, target_node_id, relationship_type, data, created_at, updated_at, data_compressed,
+                valid_from, valid_to
+            )
+            VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7, $8, $9, $10)
+            RETURNING {_COLUMNS}
         """
 
-        async with self.db.pool.acquire() as conn:
-            row = await conn.fetchrow(
+        try:
+            return await conn.fetchrow(
                 query,
                 rel.id, rel.source_node_id, rel.target_node_id,
-                rel.relationship_type, rel.data, rel.created_at, rel.updated_at
+                rel.relationship_type, data_value, rel.created_at, rel.updated_at, compressed,
+                rel.valid_from, rel.valid_to
             )
+        except asyncpg.UniqueViolationError as e:
+            raise AlreadyExistsError(f"relationship already exists: {rel.id}") from e
+
+    @with_retry()
+    async def create_many(self, rels: List[Relationship]) -> None:
+        """Create several relationships in one transaction."""
+        now = datetime.now()
+        rows = []
+        for rel in rels:
+            rel.id = str(uuid.uuid4())
+            rel.created_at = rel.updated_at = now
+            data_value, compressed = encode_data(rel.data or "{}", "relationship", self.data_key)
+            rows.append((
+                rel.id, rel.source_node_id, rel.target_node_id, rel.relationship_type,
+                data_value, now, now, compressed, rel.valid_from, rel.valid_to,
+            ))
 
-        return self._row_to_relationship(row)
+        query = """
+            INSERT INTO relationships (
+                id, source_node_id, target_node_id, relationship_type, data, created_at, updated_at, data_compressed,
+                valid_from, valid_to
+            )
+            VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7, $8, $9, $10)
+        """
 
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                await conn.executemany(query, rows)
+
+    @with_retry(idempotent=True)
     async def get_by_id(self, id: str) -> Relationship:
         """Retrieve a relationship by ID."""
-        query = """
-            SELECT id, source_node_id, target_node_id, relationship_type, data::text, created_at, updated_at 
+        query = f"""
+            SELECT {_COLUMNS}
             FROM relationships 
             WHERE id = $1
         """
@@ -59,31 +164,39 @@ class RelationshipRepository:
 
         return self._row_to_relationship(row)
 
-    async def update(self, rel: Relationship) -> Relationship:
-        """Update an existing relationship."""
+    @with_retry()
+    async def update(self, rel: Relationship, expected_updated_at: Optional[datetime] = None) -> Relationship:
+        """Update an existing relationship, optionally only if unchanged since expected_updated_at."""
         rel.updated_at = datetime.now()
 
         if not rel.data:
             rel.data = "{}"
+        data_value, compressed = encode_data(rel.data, "relationship", self.data_key)
 
-        query = """
+        query = f"""
             UPDATE relationships 
-            SET relationship_type = $2, data = $3::jsonb, updated_at = $4
-            WHERE id = $1
-            RETURNING id, source_node_id, target_node_id, relationship_type, data::text, created_at, updated_at
+            SET relationship_type = $2, data = $3::jsonb, updated_at = $4, data_compressed = $5,
+                valid_from = $7, valid_to = $8
+            WHERE id = $1 AND ($6::timestamptz IS NULL OR updated_at = $6)
+            RETURNING {_COLUMNS}
         """
 
         async with self.db.pool.acquire() as conn:
             row = await conn.fetchrow(
                 query,
-                rel.id, rel.relationship_type, rel.data, rel.updated_at
+                rel.id, rel.relationship_type, data_value, rel.updated_at, compressed, expected_updated_at,
+                rel.valid_from, rel.valid_to
             )
+            if not row and expected_updated_at:
+                if await conn.fetchval("SELECT 1 FROM relationships WHERE id = $1", rel.id):
+                    raise PreconditionFailedError(f"relationship was modified concurrently: {rel.id}")
 
         if not row:
             raise NotFoundError(f"relationship not found: {rel.id}")
 
         return self._row_to_relationship(row)
 
+    @with_retry()
     async def delete(self, id: str) -> None:
         """Delete a relationship by ID."""
         query = "DELETE FROM relationships WHERE id = $1"
@@ -94,15 +207,26 @@ class RelationshipRepository:
         if result == "DELETE 0":
             raise NotFoundError(f"relationship not found: {id}")
 
+    @with_retry(idempotent=True)
     async def list(
         self,
         source_node_id: Optional[str],
         target_node_id: Optional[str],
         rel_type: Optional[str],
-        opts: ListOptions
+        opts: ListOptions,
+        derived: Optional[RelationshipType] = None,
+        valid_at: Optional[datetime] = None,
+        dedupe: bool = False,
     ) -> Tuple[List[Relationship], ListResult]:
-        """Retrieve relationships with pagination and optional filtering."""
-        page_size = max(1, min(opts.page_size or 10, 100))
+        """Retrieve relationships with pagination and optional filtering.
+
+        With derived, lists the computed relationships of that derived type.
+        With valid_at, only relationships valid at that time are listed.
+        With dedupe, parallel relationships (same type, source and target) are
+        collapsed into the oldest of them, with parallel_count set; ordering,
+        paging and the total count apply to the collapsed list.
+        """
+        page_size = opts.effective_page_size()
         offset = 0
         if opts.page_token:
             try:
@@ -110,35 +234,65 @@ class RelationshipRepository:
             except ValueError:
                 offset = 0
 
-        # Build dynamic query with filters
-        count_query = "SELECT COUNT(*) FROM relationships WHERE 1=1"
-        list_query = """
-            SELECT id, source_node_id, target_node_id, relationship_type, data::text, created_at, updated_at 
-            FROM relationships 
-            WHERE 1=1
-        """
         args = []
-        arg_idx = 1
+        source = derived_source(derived, args) + " relationships" if derived else "relationships"
+        arg_idx = len(args) + 1
+
+        # Build dynamic query with filters
+        where = "1=1"
 
         if source_node_id:
-            count_query += f" AND source_node_id = ${arg_idx}"
-            list_query += f" AND source_node_id = ${arg_idx}"
+            where += f" AND source_node_id = ${arg_idx}"
             args.append(source_node_id)
             arg_idx += 1
 
         if target_node_id:
-            count_query += f" AND target_node_id = ${arg_idx}"
-            list_query += f" AND target_node_id = ${arg_idx}"
+            where += f" AND target_node_id = ${arg_idx}"
             args.append(target_node_id)
             arg_idx += 1
 
         if rel_type:
-            count_query += f" AND relationship_type = ${arg_idx}"
-            list_query += f" AND relationship_type = ${arg_idx}"
+            where += f" AND relationship_type = ${arg_idx}"
             args.append(rel_type)
             arg_idx += 1
 
-        list_query += f" ORDER BY created_at DESC LIMIT ${arg_idx} OFFSET ${arg_idx + 1}"
+        if valid_at:
+            where += f" AND {_VALID_AT.replace('r.', '').format(n=arg_idx)}"
+            args.append(valid_at)
+            arg_idx += 1
+
+        if dedupe:
+            count_query = f"""
+                SELECT COUNT(*) FROM (
+                    SELECT DISTINCT source_node_id, target_node_id, relationship_type
+                    FROM {source}
+                    WHERE {where}
+                ) pairs
+            """
+            list_query = f"""
+                SELECT {_COLUMNS}, parallel_count
+                FROM (
+                    SELECT *,
+                        COUNT(*) OVER pair AS parallel_count,
+                        ROW_NUMBER() OVER (pair ORDER BY created_at, id) AS pair_rank
+                    FROM {source}
+                    WHERE {where}
+                    WINDOW pair AS (PARTITION BY source_node_id, target_node_id, relationship_type)
+                ) relationships
+                WHERE pair_rank = 1
+            """
+        else:
+            count_query = f"SELECT COUNT(*) FROM {source} WHERE {where}"
+            list_query = f"""
+                SELECT {_COLUMNS}
+                FROM {source}
+                WHERE {where}
+            """
+
+        if orders_by_json(opts.order_by, "data"):
+            check_data_query("ordering by data paths", self.data_key)
+        list_query += build_order_by(opts.order_by, SORTABLE_COLUMNS, json_column="data")
+        list_query += f" LIMIT ${arg_idx} OFFSET ${arg_idx + 1}"
         list_args = args + [page_size, offset]
 
         async with self.db.pool.acquire() as conn:
@@ -146,6 +300,9 @@ class RelationshipRepository:
             rows = await conn.fetch(list_query, *list_args)
 
         relationships = [self._row_to_relationship(row) for row in rows]
+        if dedupe:
+            for rel, row in zip(relationships, rows):
+                rel.parallel_count = row["parallel_count"]
 
         result = ListResult(total_count=total_count)
         next_offset = offset + len(relationships)
@@ -154,6 +311,345 @@ class RelationshipRepository:
 
         return relationships, result
 
+    @with_retry(idempotent=True)
+    async def distinct_values(
+        self,
+        field: str,
+        rel_type: Optional[str],
+        limit: int
+    ) -> List[FacetValue]:
+        """Count relationships per distinct value of a column or JSON data path."""
+        if field.startswith("data."):
+            check_data_query("distinct values of data paths", self.data_key)
+        where, args = "", []
+        if rel_type:
+            where, args = "relationship_type = $1", [rel_type]
+        return await fetch_distinct_values(self.db, "relationships", field, FACET_COLUMNS, where, args, limit)
+
+    @with_retry(idempotent=True)
+    async def date_histogram(
+        self,
+        field: str,
+        rel_type: Optional[str],
+        interval: str,
+        timezone: str,
+        start: Optional[datetime],
+        end: Optional[datetime],
+    ) -> List[FacetValue]:
+        """Count relationships per local calendar interval of a timestamp column."""
+        where, args = "", []
+        if rel_type:
+            where, args = "relationship_type = $1", [rel_type]
+        return await fetch_date_histogram(
+            self.db, "relationships", field, DATE_COLUMNS, where, args, interval, timezone, start, end
+        )
+
+    @with_retry(idempotent=True)
+    async def count_matching(self, filters: RelationshipFilter) -> int:
+        """Count relationships matching a bulk operation filter."""
+        if filters.queries_data():
+            check_data_query("filtering on data", self.data_key)
+        where_clause, args = _filter_clause(filters)
+
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval(f"SELECT COUNT(*) FROM relationships{where_clause}", *args)
+
+    @with_retry(idempotent=True)
+    async def list_ids_matching(self, filters: RelationshipFilter, limit: int) -> List[str]:
+        """Retrieve the IDs of up to limit relationships matching a bulk operation filter."""
+        if filters.queries_data():
+            check_data_query("filtering on data", self.data_key)
+        where_clause, args = _filter_clause(filters)
+        query = f"SELECT id FROM relationships{where_clause} ORDER BY created_at, id LIMIT ${len(args) + 1}"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, *args, limit)
+
+        return [str(row[0]) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def find_orphans(
+        self, after: str, limit: int
+    ) -> Tuple[int, str, List[Tuple[Relationship, List[str]]]]:
+        """
+        Check the next limit relationships by ID after an ID ("" to start) for
+        endpoints that no longer exist; returns how many were checked, the last
+        ID checked, and the orphans with their missing endpoints ("source", "target").
+        """
+        query = f"""
+            WITH batch AS (
+                SELECT {_COLUMNS} FROM relationships
+                WHERE $1::uuid IS NULL OR id > $1::uuid
+                ORDER BY id
+                LIMIT $2
+            )
+            SELECT b.*, s.id IS NULL AS source_missing, t.id IS NULL AS target_missing
+            FROM batch b
+            LEFT JOIN nodes s ON s.id = b.source_node_id
+            LEFT JOIN nodes t ON t.id = b.target_node_id
+            ORDER BY b.id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, after or None, limit)
+
+        orphans = []
+        for row in rows:
+            missing = [end for end, gone in (("source", row[10]), ("target", row[11])) if gone]
+            if missing:
+                orphans.append((self._row_to_relationship(row), missing))
+        return len(rows), str(rows[-1][0]) if rows else after, orphans
+
+    @with_retry()
+    async def delete_orphans(self, ids: List[str]) -> int:
+        """Delete those of the relationships by ID whose source or target node still does not exist."""
+        query = """
+            DELETE FROM relationships r
+            WHERE r.id = ANY($1::uuid[])
+              AND (NOT EXISTS (SELECT 1 FROM nodes WHERE id = r.source_node_id)
+                   OR NOT EXISTS (SELECT 1 FROM nodes WHERE id = r.target_node_id))
+            RETURNING r.id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, ids)
+        return len(rows)
+
+    @with_retry()
+    async def delete_many(self, ids: List[str]) -> int:
+        """Delete relationships by ID; returns how many existed."""
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch("DELETE FROM relationships WHERE id = ANY($1::uuid[]) RETURNING id", ids)
+        return len(rows)
+
+    @with_retry(idempotent=True)
+    async def list_for_nodes(
+        self,
+        node_ids: List[str],
+        direction: str,
+        rel_type: Optional[str],
+        limit_per_node: int,
+        derived: Optional[RelationshipType] = None,
+        valid_at: Optional[datetime] = None,
+    ) -> List[Tuple[str, Relationship]]:
+        """
+        Retrieve up to limit_per_node relationships of each node, oldest first.
+
+        direction is "out" (node is the source), "in" (node is the target) or
+        "both". Returns (node_id, relationship) pairs; a relationship between
+        two of the nodes appears once for each. With derived, the computed
+        relationships of that derived type are retrieved. Only relationships
+        valid at valid_at (default: now) are followed.
+        """
+        join_conditions = {
+            "out": "r.source_node_id = n.node_id",
+            "in": "r.target_node_id = n.node_id",
+            "both": "(r.source_node_id = n.node_id OR r.target_node_id = n.node_id)",
+        }
+        args: List[Any] = [node_ids, limit_per_node, valid_at]
+        type_condition = ""
+        if rel_type:
+            args.append(rel_type)
+            type_condition = f"AND r.relationship_type = ${len(args)}"
+        source = derived_source(derived, args) if derived else "relationships"
+        query = f"""
+            SELECT node_id, id, source_node_id, target_node_id, relationship_type, data, created_at, updated_at,
+                   data_compressed, valid_from, valid_to
+            FROM (
+                SELECT n.node_id, r.id, r.source_node_id, r.target_node_id, r.relationship_type, r.data::text AS data,
+                       r.created_at, r.updated_at, r.data_compressed, r.valid_from, r.valid_to,
+                       ROW_NUMBER() OVER (PARTITION BY n.node_id ORDER BY r.created_at, r.id) AS rn
+                FROM unnest($1::uuid[]) AS n(node_id)
+                JOIN {source} r ON {join_conditions[direction]} {type_condition} AND {_VALID_AT.format(n=3)}
+            ) ranked
+            WHERE rn <= $2
+            ORDER BY node_id, rn
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, *args)
+
+        return [(str(row[0]), self._row_to_relationship(row[1:])) for row in rows]
+
+    @with_retry()
+    async def refresh_derived(self, rel_type: RelationshipType) -> int:
+        """Replace the stored snapshot of a materialized derived type; returns its relationship count."""
+        args: List[Any] = []
+        computed = _derived_select(rel_type.name, rel_type.derivation["path"], args)
+
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                await conn.execute("DELETE FROM derived_relationships WHERE relationship_type = $1", rel_type.name)
+                result = await conn.execute(
+                    f"""
+                    INSERT INTO derived_relationships (
+                        id, relationship_type, source_node_id, target_node_id, data, created_at, updated_at
+                    )
+                    SELECT id, relationship_type, source_node_id, target_node_id, data, created_at, updated_at
+                    FROM ({computed}) d
+                    """,
+                    *args
+                )
+        return int(result.split()[-1])
+
+    @with_retry()
+    async def clear_derived(self, name: str) -> None:
+        """Remove the stored snapshot of a derived type."""
+        async with self.db.pool.acquire() as conn:
+            await conn.execute("DELETE FROM derived_relationships WHERE relationship_type = $1", name)
+
+    @with_retry(idempotent=True)
+    async def count_by_type(self, rel_type: str) -> int:
+        """Count stored relationships of a type."""
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval("SELECT COUNT(*) FROM relationships WHERE relationship_type = $1", rel_type)
+
+    @with_retry(idempotent=True)
+    async def list_from_sources(self, node_ids: List[str]) -> List[Relationship]:
+        """Retrieve the relationships (with data) whose source is any of the nodes."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationships
+            WHERE source_node_id = ANY($1::uuid[])
+            ORDER BY source_node_id, created_at, id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_ids)
+
+        return [self._row_to_relationship(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_to_targets(self, node_ids: List[str]) -> List[Relationship]:
+        """Retrieve the relationships (with data) whose target is any of the nodes."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationships
+            WHERE target_node_id = ANY($1::uuid[])
+            ORDER BY target_node_id, created_at, id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_ids)
+
+        return [self._row_to_relationship(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_changed(
+        self, sources: NodeFilter, changed_since: str, after_id: str, limit: int
+    ) -> List[Relationship]:
+        """
+        Retrieve up to limit relationships, ordered by ID after after_id, written by
+        transactions at or after changed_since and whose source node matches sources.
+        """
+        if sources.queries_data():
+            check_data_query("filtering on data", self.data_key)
+        source_clause, args = node_filter_clause(sources)
+        args += [changed_since, after_id or None, limit]
+        n = len(args)
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationships
+            WHERE change_xid >= ${n - 2}::text::xid8
+              AND (${n - 1}::uuid IS NULL OR id > ${n - 1})
+              {f"AND source_node_id IN (SELECT id FROM nodes{source_clause})" if source_clause else ""}
+            ORDER BY id
+            LIMIT ${n}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, *args)
+
+        return [self._row_to_relationship(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_touching(self, node_ids: List[str]) -> List[Relationship]:
+        """Retrieve the endpoints and types (without data) of relationships touching any of the nodes."""
+        query = """
+            SELECT id, source_node_id, target_node_id, relationship_type
+            FROM relationships
+            WHERE source_node_id = ANY($1::uuid[]) OR target_node_id = ANY($1::uuid[])
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_ids)
+
+        return [
+            Relationship(
+                id=str(row[0]),
+                source_node_id=str(row[1]),
+                target_node_id=str(row[2]),
+                relationship_type=row[3],
+            )
+            for row in rows
+        ]
+
+    @with_retry(idempotent=True)
+    async def list_adjacent(
+        self,
+        node_ids: List[str],
+        direction: str,
+        rel_types: List[str],
+        valid_at: Optional[datetime],
+        limit: int,
+    ) -> List[Relationship]:
+        """
+        Retrieve the endpoints and types (without data) of up to limit
+        relationships of any of the nodes, in the given direction ("out", "in"
+        or "both") and of any of rel_types (all types if empty), valid at
+        valid_at (default: now).
+        """
+        conditions = {
+            "out": "r.source_node_id = ANY($1::uuid[])",
+            "in": "r.target_node_id = ANY($1::uuid[])",
+            "both": "(r.source_node_id = ANY($1::uuid[]) OR r.target_node_id = ANY($1::uuid[]))",
+        }
+        query = f"""
+            SELECT r.id, r.source_node_id, r.target_node_id, r.relationship_type
+            FROM relationships r
+            WHERE {conditions[direction]}
+              AND (cardinality($2::text[]) = 0 OR r.relationship_type = ANY($2::text[]))
+              AND {_VALID_AT.format(n=3)}
+            ORDER BY r.created_at, r.id
+            LIMIT $4
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_ids, rel_types, valid_at, limit)
+
+        return [
+            Relationship(
+                id=str(row[0]),
+                source_node_id=str(row[1]),
+                target_node_id=str(row[2]),
+                relationship_type=row[3],
+            )
+            for row in rows
+        ]
+
+    @with_retry(idempotent=True)
+    async def list_between(
+        self, node_ids: List[str], rel_types: List[str], valid_at: Optional[datetime]
+    ) -> List[Relationship]:
+        """
+        Retrieve the relationships (with data) whose source and target are both
+        among the nodes, of any of rel_types (all types if empty), valid at
+        valid_at (default: now).
+        """
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationships r
+            WHERE r.source_node_id = ANY($1::uuid[]) AND r.target_node_id = ANY($1::uuid[])
+              AND (cardinality($2::text[]) = 0 OR r.relationship_type = ANY($2::text[]))
+              AND {_VALID_AT.format(n=3)}
+            ORDER BY r.created_at, r.id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_ids, rel_types, valid_at)
+
+        return [self._row_to_relationship(row) for row in rows]
+
     def _row_to_relationship(self, row: asyncpg.Record) -> Relationship:
         """Convert a database row to a Relationship object."""
         return Relationship(
@@ -165,4 +661,92 @@ class RelationshipRepository:
             data=row[4] or "{}",
             created_at=row[5],
             updated_at=row[6],
+            compressed_data=row[7],
+            valid_from=row[8],
+            valid_to=row[9],
         )
+
+
+def derived_source(rel_type: RelationshipType, args: List[Any]) -> str:
+    """
+    Build a subquery with the relationships of a derived type, with the same
+    columns as the relationships table; its arguments are appended to args.
+
+    Materialized types read the last refreshed snapshot, others are computed
+    from the relationships along the derivation path.
+    """
+    if rel_type.derivation.get("materialized"):
+        args.append(rel_type.name)
+        return f"""(
+            SELECT id, source_node_id, target_node_id, relationship_type, data, created_at, updated_at,
+                   NULL::bytea AS data_compressed, NULL::timestamptz AS valid_from, NULL::timestamptz AS valid_to
+            FROM derived_relationships
+            WHERE relationship_type = ${len(args)}
+        )"""
+    return f"({_derived_select(rel_type.name, rel_type.derivation['path'], args)})"
+
+
+def _derived_select(name: str, path: List[dict], args: List[Any]) -> str:
+    """
+    Select one relationship per distinct (start, end) pair of nodes joined
+    by the path of currently valid relationships, excluding paths that
+    return to their start. Its ID is stable, its data counts the connecting
+    paths and its timestamps are those of the first path completed and of
+    the latest path change.
+    """
+    args.append(name)
+    name_param = f"${len(args)}::text"
+    joins, conditions = [], []
+    for i, step in enumerate(path):
+        start, _ = _STEP_ENDS[step["direction"]]
+        args.append(step["relationship_type"])
+        conditions.append(f"s{i}.relationship_type = ${len(args)}")
+        conditions.append(_VALID_AT.replace("r.", f"s{i}.").replace("COALESCE(${n}::timestamptz, NOW())", "NOW()"))
+        if i == 0:
+            joins.append("relationships s0")
+        else:
+            previous_end = _STEP_ENDS[path[i - 1]["direction"]][1]
+            joins.append(f"JOIN relationships s{i} ON s{i}.{start} = s{i - 1}.{previous_end}")
+
+    source = f"s0.{_STEP_ENDS[path[0]['direction']][0]}"
+    target = f"s{len(path) - 1}.{_STEP_ENDS[path[-1]['direction']][1]}"
+    created = ", ".join(f"s{i}.created_at" for i in range(len(path)))
+    updated = ", ".join(f"s{i}.updated_at" for i in range(len(path)))
+    return f"""
+        SELECT md5({name_param} || ':' || {source} || ':' || {target})::uuid AS id,
+               {source} AS source_node_id, {target} AS target_node_id,
+               {name_param} AS relationship_type,
+               jsonb_build_object('path_count', COUNT(*)) AS data,
+               MIN(GREATEST({created})) AS created_at, MAX(GREATEST({updated})) AS updated_at,
+               NULL::bytea AS data_compressed, NULL::timestamptz AS valid_from, NULL::timestamptz AS valid_to
+        FROM {" ".join(joins)}
+        WHERE {" AND ".join(conditions)} AND {source} <> {target}
+        GROUP BY {source}, {target}
+    """
+
+
+def _filter_clause(filters: RelationshipFilter) -> Tuple[str, List[Any]]:
+    """Build a WHERE clause and its arguments for a relationship filter."""
+    conditions: List[str] = []
+    args: List[Any] = []
+
+    def add(condition: str, value: Any) -> None:
+        args.append(value)
+        conditions.append(condition.format(f"${len(args)}"))
+
+    if filters.relationship_type:
+        add("relationship_type = {}", filters.relationship_type)
+    if filters.source_node_id:
+        add("source_node_id = {}", filters.source_node_id)
+    if filters.target_node_id:
+        add("target_node_id = {}", filters.target_node_id)
+    if filters.data_contains:
+        add("data @> {}::jsonb", json.dumps(filters.data_contains))
+    if filters.created_after:
+        add("created_at >= {}", filters.created_after)
+    if filters.created_before:
+        add("created_at < {}", filters.created_before)
+
+    if not conditions:
+        return "", []
+    return " WHERE " + " AND ".join(conditions), args
@@ -0,0 +1,212 @@
+"""
+RelationshipType repository implementation.
+"""
+
+import json
+import uuid
+from datetime import datetime
+from typing import List, Optional, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import RelationshipType, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.ordering import build_order_by
+from app.repository.retry import with_retry
+
+SORTABLE_COLUMNS = ("name", "directionality", "created_at", "updated_at")
+
+_COLUMNS = """
+    id, name, description, directionality,
+    allowed_source_node_type_ids, allowed_target_node_type_ids,
+    on_source_delete, on_target_delete, on_duplicate, derivation::text, created_at, updated_at
+"""
+
+
+class RelationshipTypeRepository:
+    """PostgreSQL relationship type repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, rel_type: RelationshipType) -> RelationshipType:
+        """Create a new relationship type; a rel_type.id set by the caller is used instead of a generated one."""
+        rel_type.id = rel_type.id or str(uuid.uuid4())
+        rel_type.created_at = datetime.now()
+        rel_type.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO relationship_types (
+                id, name, description, directionality,
+                allowed_source_node_type_ids, allowed_target_node_type_ids,
+                on_source_delete, on_target_delete, derivation, created_at, updated_at, on_duplicate
+            )
+            VALUES ($1, $2, $3, $4, $5::uuid[], $6::uuid[], $7, $8, $9::jsonb, $10, $11, $12)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                rel_type.id, rel_type.name, rel_type.description, rel_type.directionality,
+                rel_type.allowed_source_node_type_ids, rel_type.allowed_target_node_type_ids,
+                rel_type.on_source_delete, rel_type.on_target_delete, _derivation_arg(rel_type),
+                rel_type.created_at, rel_type.updated_at, rel_type.on_duplicate
+            )
+
+        return self._row_to_relationship_type(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> RelationshipType:
+        """Retrieve a relationship type by ID."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationship_types
+            WHERE id = $1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"relationship_type not found: {id}")
+
+        return self._row_to_relationship_type(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_name(self, name: str) -> Optional[RelationshipType]:
+        """Retrieve a relationship type by name, or None if it is not registered."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationship_types
+            WHERE name = $1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, name)
+
+        return self._row_to_relationship_type(row) if row else None
+
+    @with_retry()
+    async def update(self, rel_type: RelationshipType) -> RelationshipType:
+        """Update an existing relationship type."""
+        rel_type.updated_at = datetime.now()
+
+        query = f"""
+            UPDATE relationship_types
+            SET name = $2, description = $3, directionality = $4,
+                allowed_source_node_type_ids = $5::uuid[],
+                allowed_target_node_type_ids = $6::uuid[],
+                on_source_delete = $7, on_target_delete = $8,
+                derivation = $10::jsonb, on_duplicate = $11, updated_at = $9
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                rel_type.id, rel_type.name, rel_type.description, rel_type.directionality,
+                rel_type.allowed_source_node_type_ids, rel_type.allowed_target_node_type_ids,
+                rel_type.on_source_delete, rel_type.on_target_delete,
+                rel_type.updated_at, _derivation_arg(rel_type), rel_type.on_duplicate
+            )
+
+        if not row:
+            raise NotFoundError(f"relationship_type not found: {rel_type.id}")
+
+        return self._row_to_relationship_type(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete a relationship type by ID."""
+        query = "DELETE FROM relationship_types WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"relationship_type not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list(self, opts: ListOptions) -> Tuple[List[RelationshipType], ListResult]:
+        """Retrieve relationship types with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        order_clause = build_order_by(opts.order_by, SORTABLE_COLUMNS, default="name")
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval(
+                "SELECT COUNT(*) FROM relationship_types"
+            )
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM relationship_types
+                {order_clause}
+                LIMIT $1 OFFSET $2
+            """
+            rows = await conn.fetch(query, page_size, offset)
+
+        rel_types = [self._row_to_relationship_type(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(rel_types)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return rel_types, result
+
+    @with_retry(idempotent=True)
+    async def get_by_names(self, names: List[str]) -> List[RelationshipType]:
+        """Retrieve the registered relationship types among the given names."""
+        query = f"SELECT {_COLUMNS} FROM relationship_types WHERE name = ANY($1::text[])"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, names)
+
+        return [self._row_to_relationship_type(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_all(self) -> List[RelationshipType]:
+        """Retrieve every registered relationship type (used for discovery)."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM relationship_types
+            ORDER BY name
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        return [self._row_to_relationship_type(row) for row in rows]
+
+    def _row_to_relationship_type(self, row: asyncpg.Record) -> RelationshipType:
+        """Convert a database row to a RelationshipType object."""
+        return RelationshipType(
+            id=str(row["id"]),
+            tenant_id="",  # Not stored in tenant database (each tenant has own DB)
+            name=row["name"],
+            description=row["description"] or "",
+            directionality=row["directionality"],
+            allowed_source_node_type_ids=[str(v) for v in row["allowed_source_node_type_ids"] or []],
+            allowed_target_node_type_ids=[str(v) for v in row["allowed_target_node_type_ids"] or []],
+            on_source_delete=row["on_source_delete"],
+            on_target_delete=row["on_target_delete"],
+            on_duplicate=row["on_duplicate"],
+            derivation=json.loads(row["derivation"]) if row["derivation"] else None,
+            created_at=row["created_at"],
+            updated_at=row["updated_at"],
+        )
+
+
+def _derivation_arg(rel_type: RelationshipType) -> Optional[str]:
+    return json.dumps(rel_type.derivation) if rel_type.derivation is not None else None
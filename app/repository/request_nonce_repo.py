@@ -0,0 +1,39 @@
+"""
+Request nonce repository implementation.
+
+Signed requests carry a nonce; claiming it once per signing key is what
+rejects replays of a captured request (see app/authz/request_signing.py).
+"""
+
+from datetime import datetime
+
+from app.db.database import Database
+from app.repository.retry import with_retry
+
+
+class RequestNonceRepository:
+    """PostgreSQL request nonce repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def claim(self, key_id: str, nonce: str, expires_at: datetime) -> bool:
+        """Record a nonce of a signing key; returns False if it was already used and has not expired."""
+        query = """
+            INSERT INTO request_nonces (key_id, nonce, expires_at)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (key_id, nonce) DO UPDATE SET expires_at = EXCLUDED.expires_at
+            WHERE request_nonces.expires_at < NOW()
+            RETURNING 1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval(query, key_id, nonce, expires_at) is not None
+
+    @with_retry(idempotent=True)
+    async def purge_expired(self) -> int:
+        """Delete nonces whose requests can no longer be replayed; returns how many were deleted."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM request_nonces WHERE expires_at < NOW()")
+        return int(result.split()[-1])
@@ -0,0 +1,135 @@
+"""
+Retries of repository calls on transient PostgreSQL errors.
+
+Transient errors come in two kinds:
+
+- Errors after which the server guarantees nothing was committed
+  (serialization failures, deadlocks, refused or not-yet-ready
+  connections). Any call can safely be retried.
+- Errors that lose the connection mid-call (resets, server shutdown during
+  a failover). A write may or may not have committed, so only calls marked
+  idempotent are retried; others surface the error.
+
+Retries use exponential backoff with full jitter.
+"""
+
+import asyncio
+import functools
+import logging
+import random
+import time
+from dataclasses import dataclass
+from typing import Any, Awaitable, Callable, Optional, TypeVar
+
+import asyncpg
+
+from app.metrics import metrics
+from app.repository.timings import record as record_timing
+
+logger = logging.getLogger(__name__)
+
+T = TypeVar("T")
+
+# SQLSTATEs after which the transaction is known to have been rolled back
+_ROLLED_BACK_SQLSTATES = {
+    "40001",  # serialization_failure
+    "40P01",  # deadlock_detected
+    "53300",  # too_many_connections
+    "57P03",  # cannot_connect_now
+}
+# SQLSTATEs that end the session, possibly after a commit
+_CONNECTION_LOST_SQLSTATES = {
+    "57P01",  # admin_shutdown
+    "57P02",  # crash_shutdown
+    "08000", "08003", "08006",  # connection exceptions
+}
+
+
+@dataclass
+class RetryPolicy:
+    """How often and how long to retry transient errors."""
+    # Total attempts including the first (1 disables retries)
+    max_attempts: int = 3
+    base_delay_seconds: float = 0.05
+    max_delay_seconds: float = 1.0
+
+    def delay(self, attempt: int) -> float:
+        """Jittered backoff before retry number attempt (1-based)."""
+        cap = min(self.max_delay_seconds, self.base_delay_seconds * (2 ** (attempt - 1)))
+        return random.uniform(0, cap)
+
+
+_policy = RetryPolicy()
+
+
+def configure_retry_policy(policy: RetryPolicy) -> None:
+    """Set the retry policy used by all repositories."""
+    global _policy
+    if policy.max_attempts < 1:
+        raise ValueError("max_attempts must be >= 1")
+    _policy = policy
+
+
+def transient_kind(err: BaseException) -> Optional[str]:
+    """Classify an error as "rolled_back", "connection_lost" or None (not transient)."""
+    sqlstate = getattr(err, "sqlstate", None)
+    if sqlstate in _ROLLED_BACK_SQLSTATES:
+        return "rolled_back"
+    if sqlstate in _CONNECTION_LOST_SQLSTATES:
+        return "connection_lost"
+    if isinstance(err, ConnectionRefusedError):
+        return "rolled_back"
+    if isinstance(err, (
+        asyncpg.ConnectionDoesNotExistError,
+        ConnectionResetError,
+        ConnectionAbortedError,
+        BrokenPipeError,
+    )):
+        return "connection_lost"
+    return None
+
+
+async def call_with_retry(
+    fn: Callable[[], Awaitable[T]],
+    idempotent: bool,
+    operation: str = "",
+    policy: Optional[RetryPolicy] = None,
+) -> T:
+    """Run fn, retrying transient errors according to the policy."""
+    policy = policy or _policy
+    attempt = 1
+    while True:
+        try:
+            return await fn()
+        except Exception as e:
+            kind = transient_kind(e)
+            retryable = kind == "rolled_back" or (kind == "connection_lost" and idempotent)
+            if not retryable or attempt >= policy.max_attempts:
+                raise
+            metrics.inc("db_retries_total", labels={"operation": operation, "kind": kind})
+            logger.warning(f"Retrying {operation or 'database call'} after transient error ({kind}): {e}")
+            await asyncio.sleep(policy.delay(attempt))
+            attempt += 1
+
+
+def with_retry(idempotent: bool = False) -> Callable[[Callable[..., Awaitable[T]]], Callable[..., Awaitable[T]]]:
+    """
+    Decorate a repository method to retry transient database errors and
+    record its timing for the request log (see app.repository.timings).
+
+    Mark reads and writes that can safely run twice as idempotent, so they
+    are also retried when the connection drops mid-call.
+    """
+    def decorator(fn: Callable[..., Awaitable[T]]) -> Callable[..., Awaitable[T]]:
+        @functools.wraps(fn)
+        async def wrapper(self: Any, *args: Any, **kwargs: Any) -> T:
+            operation = f"{type(self).__name__}.{fn.__name__}"
+            started = time.monotonic()
+            result = None
+            try:
+                result = await call_with_retry(lambda: fn(self, *args, **kwargs), idempotent, operation)
+                return result
+            finally:
+                record_timing(operation, time.monotonic() - started, result)
+        return wrapper
+    return decorator
@@ -0,0 +1,173 @@
+"""
+Saved query repository implementation.
+"""
+
+import json
+import uuid
+from datetime import datetime, timezone
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import SavedQuery, ListOptions, ListResult
+from app.repository.errors import AlreadyExistsError, NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = """id, name, description, method, params::text, parameters::text, run_count, total_run_ms,
+    last_run_at, created_at, updated_at"""
+
+
+class SavedQueryRepository:
+    """PostgreSQL saved query repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, query: SavedQuery) -> SavedQuery:
+        """
+        Create a new saved query.
+
+        Raises:
+            AlreadyExistsError: If a saved query has the name
+        """
+        query.id = str(uuid.uuid4())
+        query.created_at = datetime.now(timezone.utc)
+        query.updated_at = query.created_at
+
+        sql = f"""
+            INSERT INTO saved_queries (id, name, description, method, params, parameters, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5::jsonb, $6::jsonb, $7, $8)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(
+                    sql,
+                    query.id, query.name, query.description, query.method, json.dumps(query.params),
+                    json.dumps(query.parameters), query.created_at, query.updated_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(f"saved query already exists: {query.name}") from e
+
+        return self._row_to_saved_query(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> SavedQuery:
+        """Retrieve a saved query by ID."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(f"SELECT {_COLUMNS} FROM saved_queries WHERE id = $1", id)
+
+        if not row:
+            raise NotFoundError(f"saved query not found: {id}")
+
+        return self._row_to_saved_query(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_name(self, name: str) -> SavedQuery:
+        """Retrieve a saved query by name."""
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(f"SELECT {_COLUMNS} FROM saved_queries WHERE name = $1", name)
+
+        if not row:
+            raise NotFoundError(f"saved query not found: {name}")
+
+        return self._row_to_saved_query(row)
+
+    @with_retry()
+    async def update(self, query: SavedQuery) -> SavedQuery:
+        """
+        Update a saved query's definition.
+
+        Raises:
+            AlreadyExistsError: If another saved query has the name
+        """
+        query.updated_at = datetime.now(timezone.utc)
+
+        sql = f"""
+            UPDATE saved_queries
+            SET name = $2, description = $3, method = $4, params = $5::jsonb, parameters = $6::jsonb,
+                updated_at = $7
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(
+                    sql,
+                    query.id, query.name, query.description, query.method, json.dumps(query.params),
+                    json.dumps(query.parameters), query.updated_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(f"saved query already exists: {query.name}") from e
+
+        if not row:
+            raise NotFoundError(f"saved query not found: {query.id}")
+
+        return self._row_to_saved_query(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete a saved query by ID."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM saved_queries WHERE id = $1", id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"saved query not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list(self, opts: ListOptions) -> Tuple[List[SavedQuery], ListResult]:
+        """Retrieve saved queries by name with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM saved_queries")
+            rows = await conn.fetch(
+                f"SELECT {_COLUMNS} FROM saved_queries ORDER BY name LIMIT $1 OFFSET $2", page_size, offset
+            )
+
+        queries = [self._row_to_saved_query(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(queries)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return queries, result
+
+    @with_retry(idempotent=True)
+    async def record_run(self, id: str, run_ms: float) -> None:
+        """Count a run of a saved query and its duration."""
+        sql = """
+            UPDATE saved_queries
+            SET run_count = run_count + 1, total_run_ms = total_run_ms + $2, last_run_at = NOW()
+            WHERE id = $1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            await conn.execute(sql, id, run_ms)
+
+    def _row_to_saved_query(self, row: asyncpg.Record) -> SavedQuery:
+        """Convert a database row to a SavedQuery object."""
+        return SavedQuery(
+            id=str(row[0]),
+            name=row[1],
+            description=row[2] or "",
+            method=row[3],
+            params=json.loads(row[4]),
+            parameters=json.loads(row[5]),
+            run_count=row[6],
+            total_run_ms=row[7],
+            last_run_at=row[8],
+            created_at=row[9],
+            updated_at=row[10],
+        )
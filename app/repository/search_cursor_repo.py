@@ -0,0 +1,39 @@
+"""
+Search indexer cursor repository implementation.
+"""
+
+from typing import Optional
+
+from app.db.database import Database
+from app.repository.retry import with_retry
+
+
+class SearchCursorRepository:
+    """PostgreSQL repository of the search indexer's per-tenant sync cursors (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def get(self, tenant_id: str) -> Optional[str]:
+        """Return the sync cursor up to which a tenant is indexed, or None if it has not been indexed."""
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval("SELECT sync_cursor FROM search_index_cursors WHERE tenant_id = $1", tenant_id)
+
+    @with_retry(idempotent=True)
+    async def set(self, tenant_id: str, sync_cursor: str) -> None:
+        """Record that a tenant is indexed up to a sync cursor."""
+        query = """
+            INSERT INTO search_index_cursors (tenant_id, sync_cursor, updated_at)
+            VALUES ($1, $2, NOW())
+            ON CONFLICT (tenant_id) DO UPDATE SET sync_cursor = $2, updated_at = NOW()
+        """
+
+        async with self.db.pool.acquire() as conn:
+            await conn.execute(query, tenant_id, sync_cursor)
+
+    @with_retry(idempotent=True)
+    async def delete(self, tenant_id: str) -> None:
+        """Forget a tenant's cursor, so it is indexed again from scratch."""
+        async with self.db.pool.acquire() as conn:
+            await conn.execute("DELETE FROM search_index_cursors WHERE tenant_id = $1", tenant_id)
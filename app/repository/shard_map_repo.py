@@ -0,0 +1,39 @@
+"""
+Shard map repository implementation.
+"""
+
+from typing import Dict, List, Tuple
+
+from app.db.database import Database
+from app.repository.retry import with_retry
+
+
+class ShardMapRepository:
+    """PostgreSQL repository of the shards tenant databases live on (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def count_by_shard(self) -> Dict[str, int]:
+        """Return the number of active tenant databases on each shard."""
+        query = "SELECT shard, COUNT(*) AS tenants FROM tenant_databases WHERE status = 'active' GROUP BY shard"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        return {row["shard"]: row["tenants"] for row in rows}
+
+    @with_retry(idempotent=True)
+    async def list_placements(self) -> List[Tuple[str, str, str]]:
+        """Return the (tenant ID, region, shard) of each active tenant database, by tenant ID."""
+        query = """
+            SELECT tenant_id, region, shard FROM tenant_databases
+            WHERE status = 'active'
+            ORDER BY tenant_id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        return [(str(row["tenant_id"]), row["region"], row["shard"]) for row in rows]
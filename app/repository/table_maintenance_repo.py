@@ -0,0 +1,84 @@
+"""
+Table maintenance repository implementation.
+
+Reports dead tuples and estimated bloat of a database's tables from
+PostgreSQL's statistics views, and runs VACUUM and ANALYZE on them. Heavy
+delete workloads leave dead tuples faster than autovacuum's default
+thresholds reclaim them on large tables, and autovacuum never analyzes
+partitioned tables themselves (only their partitions), so their planner
+statistics go stale.
+"""
+
+from typing import Any, Dict, List
+
+from app.db.database import Database
+from app.repository.retry import with_retry
+
+
+def quote_table(name: str) -> str:
+    """Quote a table name as an SQL identifier."""
+    return '"' + name.replace('"', '""') + '"'
+
+
+class TableMaintenanceRepository:
+    """Reports table bloat and vacuums and analyzes tables of a database."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def list_bloat(self) -> List[Dict[str, Any]]:
+        """
+        Dead tuples, estimated bloat and last vacuum and analyze times of the
+        tables in the current schema, most dead tuples first.
+
+        Bloat is estimated as the dead tuples' share of the table size.
+        """
+        query = """
+            SELECT c.relname, c.relkind = 'p', COALESCE(s.n_live_tup, 0), COALESCE(s.n_dead_tup, 0),
+                   pg_table_size(c.oid), COALESCE(s.n_mod_since_analyze, 0),
+                   GREATEST(s.last_vacuum, s.last_autovacuum), GREATEST(s.last_analyze, s.last_autoanalyze),
+                   c.relispartition
+            FROM pg_class c
+            JOIN pg_namespace n ON n.oid = c.relnamespace
+            LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+            WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p')
+            ORDER BY COALESCE(s.n_dead_tup, 0) DESC, c.relname
+        """
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        tables = []
+        for row in rows:
+            live, dead, size = row[2], row[3], row[4]
+            dead_ratio = dead / (live + dead) if live + dead else 0.0
+            tables.append({
+                "table": row[0],
+                "partitioned": row[1],
+                "partition": row[8],
+                "row_count": live,
+                "dead_row_count": dead,
+                "dead_row_ratio": round(dead_ratio, 4),
+                "table_size_bytes": size,
+                "estimated_bloat_bytes": int(size * dead_ratio),
+                "modified_since_analyze": row[5],
+                "last_vacuum": row[6].isoformat() if row[6] else None,
+                "last_analyze": row[7].isoformat() if row[7] else None,
+            })
+        return tables
+
+    @with_retry(idempotent=True)
+    async def vacuum(self, table: str, analyze: bool = True) -> None:
+        """
+        VACUUM a table (and ANALYZE it), reclaiming its dead tuples for reuse.
+
+        Plain VACUUM does not block reads or writes; it does not shrink the table's files.
+        """
+        async with self.db.pool.acquire() as conn:
+            await conn.execute(f"VACUUM {'(ANALYZE) ' if analyze else ''}{quote_table(table)}")
+
+    @with_retry(idempotent=True)
+    async def analyze(self, table: str) -> None:
+        """ANALYZE a table, refreshing its planner statistics (of a partitioned table: across its partitions)."""
+        async with self.db.pool.acquire() as conn:
+            await conn.execute(f"ANALYZE {quote_table(table)}")
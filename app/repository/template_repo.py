@@ -0,0 +1,146 @@
+"""
+Tenant template repository implementation.
+"""
+
+import json
+import uuid
+from datetime import datetime
+from typing import List, Optional, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import TenantTemplate, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, name, description, definition::text, created_at, updated_at"
+
+
+class TenantTemplateRepository:
+    """PostgreSQL tenant template repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, template: TenantTemplate) -> TenantTemplate:
+        """Create a new template."""
+        template.id = str(uuid.uuid4())
+        template.created_at = datetime.now()
+        template.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO tenant_templates (id, name, description, definition, created_at, updated_at)
+            VALUES ($1, $2, $3, $4::jsonb, $5, $6)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                template.id, template.name, template.description, json.dumps(template.definition),
+                template.created_at, template.updated_at
+            )
+
+        return self._row_to_template(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> TenantTemplate:
+        """Retrieve a template by ID."""
+        query = f"SELECT {_COLUMNS} FROM tenant_templates WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"tenant_template not found: {id}")
+
+        return self._row_to_template(row)
+
+    @with_retry(idempotent=True)
+    async def find_by_name(self, name: str) -> Optional[TenantTemplate]:
+        """Retrieve a template by name, or None."""
+        query = f"SELECT {_COLUMNS} FROM tenant_templates WHERE name = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, name)
+
+        return self._row_to_template(row) if row else None
+
+    @with_retry()
+    async def update(self, template: TenantTemplate) -> TenantTemplate:
+        """Update an existing template."""
+        template.updated_at = datetime.now()
+
+        query = f"""
+            UPDATE tenant_templates
+            SET name = $2, description = $3, definition = $4::jsonb, updated_at = $5
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                template.id, template.name, template.description, json.dumps(template.definition),
+                template.updated_at
+            )
+
+        if not row:
+            raise NotFoundError(f"tenant_template not found: {template.id}")
+
+        return self._row_to_template(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete a template by ID."""
+        query = "DELETE FROM tenant_templates WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"tenant_template not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list(self, opts: ListOptions) -> Tuple[List[TenantTemplate], ListResult]:
+        """Retrieve templates with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM tenant_templates")
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM tenant_templates
+                ORDER BY name
+                LIMIT $1 OFFSET $2
+            """
+            rows = await conn.fetch(query, page_size, offset)
+
+        templates = [self._row_to_template(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(templates)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return templates, result
+
+    def _row_to_template(self, row: asyncpg.Record) -> TenantTemplate:
+        """Convert a database row to a TenantTemplate object."""
+        return TenantTemplate(
+            id=str(row["id"]),
+            name=row["name"],
+            description=row["description"] or "",
+            definition=json.loads(row["definition"]),
+            created_at=row["created_at"],
+            updated_at=row["updated_at"],
+        )
@@ -0,0 +1,103 @@
+"""
+Tenant data key repository implementation.
+"""
+
+import uuid
+from typing import List
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import TenantKey
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = "id, tenant_id, version, status, wrapped_key, operation_id, created_at, activated_at, retired_at"
+
+
+class TenantKeyRepository:
+    """PostgreSQL tenant data key repository (control database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, tenant_id: str, wrapped_key: bytes) -> TenantKey:
+        """Create a pending key as the tenant's next key version."""
+        query = f"""
+            INSERT INTO tenant_keys (id, tenant_id, version, status, wrapped_key)
+            SELECT $1, $2, COALESCE(MAX(version), 0) + 1, 'pending', $3
+            FROM tenant_keys WHERE tenant_id = $2
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, str(uuid.uuid4()), tenant_id, wrapped_key)
+
+        return self._row_to_key(row)
+
+    @with_retry(idempotent=True)
+    async def list(self, tenant_id: str) -> List[TenantKey]:
+        """Retrieve a tenant's keys, newest version first."""
+        query = f"SELECT {_COLUMNS} FROM tenant_keys WHERE tenant_id = $1 ORDER BY version DESC"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, tenant_id)
+
+        return [self._row_to_key(row) for row in rows]
+
+    @with_retry()
+    async def set_operation(self, id: str, operation_id: str) -> None:
+        """Record the rotation operation that introduced a key."""
+        async with self.db.pool.acquire() as conn:
+            await conn.execute("UPDATE tenant_keys SET operation_id = $2 WHERE id = $1", id, operation_id)
+
+    @with_retry()
+    async def activate(self, id: str) -> TenantKey:
+        """Make a pending key the tenant's active key; the previously active key starts retiring."""
+        async with self.db.pool.acquire() as conn:
+            async with conn.transaction():
+                tenant_id = await conn.fetchval(
+                    "SELECT tenant_id FROM tenant_keys WHERE id = $1 AND status = 'pending' FOR UPDATE", id
+                )
+                if tenant_id is None:
+                    raise NotFoundError(f"pending tenant_key not found: {id}")
+                await conn.execute(
+                    "UPDATE tenant_keys SET status = 'retiring' WHERE tenant_id = $1 AND status = 'active'", tenant_id
+                )
+                row = await conn.fetchrow(
+                    f"""
+                    UPDATE tenant_keys SET status = 'active', activated_at = NOW()
+                    WHERE id = $1
+                    RETURNING {_COLUMNS}
+                    """,
+                    id
+                )
+
+        return self._row_to_key(row)
+
+    @with_retry()
+    async def retire_before(self, tenant_id: str, version: int) -> int:
+        """Retire a tenant's inactive keys older than a version; returns how many were retired."""
+        query = """
+            UPDATE tenant_keys SET status = 'retired', retired_at = NOW()
+            WHERE tenant_id = $1 AND version < $2 AND status IN ('pending', 'retiring')
+        """
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, tenant_id, version)
+        return int(result.split()[-1])
+
+    def _row_to_key(self, row: asyncpg.Record) -> TenantKey:
+        """Convert a database row to a TenantKey object."""
+        return TenantKey(
+            id=str(row["id"]),
+            tenant_id=str(row["tenant_id"]),
+            version=row["version"],
+            status=row["status"],
+            wrapped_key=bytes(row["wrapped_key"]),
+            operation_id=str(row["operation_id"]) if row["operation_id"] else "",
+            created_at=row["created_at"],
+            activated_at=row["activated_at"],
+            retired_at=row["retired_at"],
+        )
@@ -2,15 +2,28 @@
 Tenant repository implementation.
 """
 
+import json
 import uuid
 from datetime import datetime
-from typing import List, Tuple
+from typing import Any, Dict, List, Optional, Tuple
 
 import asyncpg
 
 from app.db.database import Database
-from app.repository.models import Tenant, ListOptions, ListResult
-from app.repository.errors import NotFoundError
+from app.repository.models import Tenant, TenantFilter, ListOptions, ListResult
+from app.repository.errors import NotFoundError, SlugTakenError
+from app.repository.ordering import build_order_by
+from app.repository.retry import with_retry
+
+SORTABLE_COLUMNS = ("slug", "name", "status", "created_at", "updated_at")
+_COLUMNS = (
+    "id, slug, name, status, created_at, updated_at, delete_after, limits::text, annotations::text, "
+    "parent_id, features::text, maintenance_started_at, maintenance_reason, timezone, debug_scopes, debug_until, "
+    "region, protected"
+)
+_TENANT_COLUMNS = ", ".join(f"t.{c.strip()}" for c in _COLUMNS.split(","))
+# Deepest tenant hierarchy walked (organization, reseller, customer, ...)
+MAX_HIERARCHY_DEPTH = 10
 
 
 class TenantRepository:
@@ -19,6 +32,7 @@ class TenantRepository:
     def __init__(self, db: Database):
         self.db = db
 
+    @with_retry()
     async def create(self, tenant: Tenant) -> Tenant:
         """Create a new tenant."""
         tenant.id = str(uuid.uuid4())
@@ -27,24 +41,29 @@ class TenantRepository:
         if not tenant.status:
             tenant.status = "active"
 
-        query = """
-            INSERT INTO tenants (id, slug, name, status, created_at, updated_at)
-            VALUES ($1, $2, $3, $4, $5, $6)
-            RETURNING id, slug, name, status, created_at, updated_at
+        query = f"""
+            INSERT INTO tenants (id, slug, name, status, created_at, updated_at, annotations, parent_id, timezone, region)
+            VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8, $9, $10)
+            RETURNING {_COLUMNS}
         """
 
         async with self.db.pool.acquire() as conn:
-            row = await conn.fetchrow(
-                query,
-                tenant.id, tenant.slug, tenant.name, tenant.status,
-                tenant.created_at, tenant.updated_at
-            )
+            try:
+                row = await conn.fetchrow(
+                    query,
+                    tenant.id, tenant.slug, tenant.name, tenant.status,
+                    tenant.created_at, tenant.updated_at, json.dumps(tenant.annotations), tenant.parent_id or None,
+                    tenant.timezone or "UTC", tenant.region
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise SlugTakenError(tenant.slug) from e
 
         return self._row_to_tenant(row)
 
+    @with_retry(idempotent=True)
     async def get_by_id(self, id: str) -> Tenant:
         """Retrieve a tenant by ID."""
-        query = "SELECT id, slug, name, status, created_at, updated_at FROM tenants WHERE id = $1"
+        query = f"SELECT {_COLUMNS} FROM tenants WHERE id = $1"
 
         async with self.db.pool.acquire() as conn:
             row = await conn.fetchrow(query, id)
@@ -54,28 +73,44 @@ class TenantRepository:
 
         return self._row_to_tenant(row)
 
+    @with_retry()
     async def update(self, tenant: Tenant) -> Tenant:
         """Update an existing tenant."""
         tenant.updated_at = datetime.now()
 
-        query = """
+        query = f"""
             UPDATE tenants 
-            SET slug = $2, name = $3, status = $4, updated_at = $5
+            SET slug = $2, name = $3, status = $4, updated_at = $5, annotations = $6::jsonb, timezone = $7
             WHERE id = $1
-            RETURNING id, slug, name, status, created_at, updated_at
+            RETURNING {_COLUMNS}
         """
 
         async with self.db.pool.acquire() as conn:
-            row = await conn.fetchrow(
-                query,
-                tenant.id, tenant.slug, tenant.name, tenant.status, tenant.updated_at
-            )
+            try:
+                row = await conn.fetchrow(
+                    query,
+                    tenant.id, tenant.slug, tenant.name, tenant.status, tenant.updated_at,
+                    json.dumps(tenant.annotations), tenant.timezone or "UTC"
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise SlugTakenError(tenant.slug) from e
 
         if not row:
             raise NotFoundError(f"tenant not found: {tenant.id}")
 
         return self._row_to_tenant(row)
 
+    @with_retry(idempotent=True)
+    async def taken_slugs(self, slugs: List[str]) -> List[str]:
+        """Return which of some slugs are used by tenants."""
+        query = "SELECT slug FROM tenants WHERE slug = ANY($1::text[])"
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, slugs)
+
+        return [row["slug"] for row in rows]
+
+    @with_retry()
     async def delete(self, id: str) -> None:
         """Delete a tenant by ID."""
         query = "DELETE FROM tenants WHERE id = $1"
@@ -87,9 +122,238 @@ class TenantRepository:
         if result == "DELETE 0":
             raise NotFoundError(f"tenant not found: {id}")
 
-    async def list(self, opts: ListOptions) -> Tuple[List[Tenant], ListResult]:
-        """Retrieve tenants with pagination."""
-        page_size = max(1, min(opts.page_size or 10, 100))
+    @with_retry(idempotent=True)
+    async def set_limits(self, id: str, limits: Dict[str, Any]) -> Tenant:
+        """Replace a tenant's limit overrides."""
+        query = f"""
+            UPDATE tenants
+            SET limits = $2::jsonb, updated_at = NOW()
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, json.dumps(limits))
+
+        if not row:
+            raise NotFoundError(f"tenant not found: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry(idempotent=True)
+    async def set_debug(self, id: str, scopes: List[str], until: Optional[datetime]) -> Tenant:
+        """Switch a tenant's debug logging scopes on until a time, or off with no scopes."""
+        query = f"""
+            UPDATE tenants
+            SET debug_scopes = $2, debug_until = $3, updated_at = NOW()
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, scopes, until if scopes else None)
+
+        if not row:
+            raise NotFoundError(f"tenant not found: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry(idempotent=True)
+    async def set_maintenance(self, id: str, enabled: bool, reason: str) -> Tenant:
+        """Start (keeping the original start time if already started) or end a tenant's maintenance."""
+        query = f"""
+            UPDATE tenants
+            SET maintenance_started_at = CASE WHEN $2 THEN COALESCE(maintenance_started_at, NOW()) END,
+                maintenance_reason = CASE WHEN $2 THEN $3 ELSE '' END,
+                updated_at = NOW()
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, enabled, reason)
+
+        if not row:
+            raise NotFoundError(f"tenant not found: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry(idempotent=True)
+    async def set_protected(self, id: str, protected: bool) -> Tenant:
+        """Protect a tenant from deletion, or remove its protection."""
+        query = f"""
+            UPDATE tenants
+            SET protected = $2, updated_at = NOW()
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, protected)
+
+        if not row:
+            raise NotFoundError(f"tenant not found: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry(idempotent=True)
+    async def set_features(self, id: str, features: Dict[str, bool]) -> Tenant:
+        """Replace a tenant's own feature flags."""
+        query = f"""
+            UPDATE tenants
+            SET features = $2::jsonb, updated_at = NOW()
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, json.dumps(features))
+
+        if not row:
+            raise NotFoundError(f"tenant not found: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry(idempotent=True)
+    async def set_parent(self, id: str, parent_id: str) -> Tenant:
+        """Move a tenant under another tenant (empty parent_id makes it top-level)."""
+        query = f"""
+            UPDATE tenants
+            SET parent_id = $2, updated_at = NOW()
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, parent_id or None)
+
+        if not row:
+            raise NotFoundError(f"tenant not found: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry(idempotent=True)
+    async def list_ancestors(self, id: str) -> List[Tenant]:
+        """Retrieve a tenant's ancestors, its parent first."""
+        query = f"""
+            WITH RECURSIVE chain AS (
+                SELECT parent_id, 1 AS depth FROM tenants WHERE id = $1
+                UNION ALL
+                SELECT t.parent_id, c.depth + 1
+                FROM tenants t JOIN chain c ON t.id = c.parent_id
+                WHERE c.depth < $2
+            )
+            SELECT {_TENANT_COLUMNS}
+            FROM chain c JOIN tenants t ON t.id = c.parent_id
+            ORDER BY c.depth
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, id, MAX_HIERARCHY_DEPTH)
+
+        return [self._row_to_tenant(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_descendants(self, id: str) -> List[Tenant]:
+        """Retrieve every tenant below a tenant, parents before their sub-tenants."""
+        query = f"""
+            WITH RECURSIVE tree AS (
+                SELECT id, 1 AS depth FROM tenants WHERE parent_id = $1
+                UNION ALL
+                SELECT t.id, tree.depth + 1
+                FROM tenants t JOIN tree ON t.parent_id = tree.id
+                WHERE tree.depth < $2
+            )
+            SELECT {_TENANT_COLUMNS}
+            FROM tree JOIN tenants t ON t.id = tree.id
+            ORDER BY tree.depth, t.slug
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, id, MAX_HIERARCHY_DEPTH)
+
+        return [self._row_to_tenant(row) for row in rows]
+
+    @with_retry()
+    async def schedule_deletion(self, id: str, delete_after: datetime) -> Tenant:
+        """Mark an active tenant pending deletion, to be purged after delete_after."""
+        query = f"""
+            UPDATE tenants
+            SET status = 'pending_deletion', delete_after = $2, updated_at = NOW()
+            WHERE id = $1 AND status <> 'pending_deletion'
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id, delete_after)
+            if not row:
+                exists = await conn.fetchval("SELECT 1 FROM tenants WHERE id = $1", id)
+                if not exists:
+                    raise NotFoundError(f"tenant not found: {id}")
+                raise ValueError(f"tenant is already pending deletion: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry()
+    async def cancel_deletion(self, id: str) -> Tenant:
+        """Restore a tenant that is pending deletion to active."""
+        query = f"""
+            UPDATE tenants
+            SET status = 'active', delete_after = NULL, updated_at = NOW()
+            WHERE id = $1 AND status = 'pending_deletion'
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+            if not row:
+                exists = await conn.fetchval("SELECT 1 FROM tenants WHERE id = $1", id)
+                if not exists:
+                    raise NotFoundError(f"tenant not found: {id}")
+                raise ValueError(f"tenant is not pending deletion: {id}")
+
+        return self._row_to_tenant(row)
+
+    @with_retry(idempotent=True)
+    async def list_due_for_purge(self, now: datetime, limit: int = 100) -> List[Tenant]:
+        """Retrieve tenants pending deletion whose grace period ended before now."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM tenants
+            WHERE status = 'pending_deletion' AND delete_after <= $1
+            ORDER BY delete_after
+            LIMIT $2
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, now, limit)
+
+        return [self._row_to_tenant(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list_databases(self) -> List[Tuple[str, str, str]]:
+        """Retrieve (tenant ID, slug, database name) of every tenant with an active database."""
+        query = """
+            SELECT t.id, t.slug, d.database_name
+            FROM tenants t
+            JOIN tenant_databases d ON d.tenant_id = t.id
+            WHERE d.status = 'active'
+            ORDER BY t.slug
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query)
+
+        return [(str(row[0]), row[1], row[2]) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def list(
+        self,
+        opts: ListOptions,
+        filters: Optional[TenantFilter] = None,
+    ) -> Tuple[List[Tenant], ListResult]:
+        """Retrieve tenants with pagination and optional filtering."""
+        page_size = opts.effective_page_size()
         offset = 0
         if opts.page_token:
             try:
@@ -97,18 +361,22 @@ class TenantRepository:
             except ValueError:
                 offset = 0
 
+        order_clause = build_order_by(opts.order_by, SORTABLE_COLUMNS)
+        where_clause, args = _filter_clause(filters)
+        limit_param = len(args) + 1
+
         async with self.db.pool.acquire() as conn:
             # Get total count
-            total_count = await conn.fetchval("SELECT COUNT(*) FROM tenants")
+            total_count = await conn.fetchval(f"SELECT COUNT(*) FROM tenants{where_clause}", *args)
 
             # Get tenants
-            query = """
-                SELECT id, slug, name, status, created_at, updated_at 
-                FROM tenants 
-                ORDER BY created_at DESC 
-                LIMIT $1 OFFSET $2
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM tenants{where_clause}
+                {order_clause}
+                LIMIT ${limit_param} OFFSET ${limit_param + 1}
             """
-            rows = await conn.fetch(query, page_size, offset)
+            rows = await conn.fetch(query, *args, page_size, offset)
 
         tenants = [self._row_to_tenant(row) for row in rows]
 
@@ -128,4 +396,53 @@ class TenantRepository:
             status=row["status"],
             created_at=row["created_at"],
             updated_at=row["updated_at"],
+            delete_after=row["delete_after"],
+            limits=json.loads(row["limits"]),
+            annotations=json.loads(row["annotations"]),
+            parent_id=str(row["parent_id"]) if row["parent_id"] else "",
+            features=json.loads(row["features"]),
+            maintenance_started_at=row["maintenance_started_at"],
+            maintenance_reason=row["maintenance_reason"],
+            timezone=row["timezone"],
+            debug_scopes=list(row["debug_scopes"] or []),
+            debug_until=row["debug_until"],
+            region=row["region"],
+            protected=row["protected"],
         )
+
+
+def _escape_like(value: str) -> str:
+    """Escape LIKE wildcards so user input matches literally."""
+    return value.replace("\\", "\\\\").replace("%", "\\%").replace("_", "\\_")
+
+
+def _filter_clause(filters: Optional[TenantFilter]) -> Tuple[str, List[Any]]:
+    """Build a WHERE clause and its arguments for tenant filters."""
+    if not filters:
+        return "", []
+
+    conditions: List[str] = []
+    args: List[Any] = []
+
+    def add(condition: str, value: Any) -> None:
+        args.append(value)
+        conditions.append(condition.format(f"${len(args)}"))
+
+    if filters.status:
+        add("status = {}", filters.status)
+    if filters.slug_prefix:
+        add("slug LIKE {}", _escape_like(filters.slug_prefix) + "%")
+    if filters.name_contains:
+        add("name ILIKE {}", "%" + _escape_like(filters.name_contains) + "%")
+    if filters.created_after:
+        add("created_at >= {}", filters.created_after)
+    if filters.created_before:
+        add("created_at < {}", filters.created_before)
+    if filters.annotations:
+        add("annotations @> {}::jsonb", json.dumps(filters.annotations))
+    if filters.parent_id:
+        add("parent_id = {}", filters.parent_id)
+
+    if not conditions:
+        return "", []
+    return " WHERE " + " AND ".join(conditions), args
@@ -0,0 +1,85 @@
+"""
+Per-request collection of repository call timings.
+
+A collector is installed for the duration of a request (by the request
+log interceptor); every repository call made while it is active records
+its duration and the number of rows it returned. Outside a request nothing
+is recorded.
+"""
+
+import contextvars
+from dataclasses import dataclass, field
+from typing import Any, Dict, Optional
+
+
+@dataclass
+class OperationTiming:
+    """Aggregated timings of one repository operation within a request."""
+    calls: int = 0
+    total_seconds: float = 0.0
+    rows: int = 0
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {"calls": self.calls, "total_ms": round(self.total_seconds * 1000, 2), "rows": self.rows}
+
+
+@dataclass
+class RepositoryTimings:
+    """Repository timings of one request, keyed by "Repository.method"."""
+    operations: Dict[str, OperationTiming] = field(default_factory=dict)
+
+    def record(self, operation: str, seconds: float, rows: int) -> None:
+        """Record one repository call."""
+        timing = self.operations.setdefault(operation, OperationTiming())
+        timing.calls += 1
+        timing.total_seconds += seconds
+        timing.rows += rows
+
+    @property
+    def total_seconds(self) -> float:
+        return sum(t.total_seconds for t in self.operations.values())
+
+    @property
+    def total_rows(self) -> int:
+        return sum(t.rows for t in self.operations.values())
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary, slowest operation first."""
+        ordered = sorted(self.operations.items(), key=lambda item: item[1].total_seconds, reverse=True)
+        return {name: timing.to_dict() for name, timing in ordered}
+
+
+_current: contextvars.ContextVar[Optional[RepositoryTimings]] = contextvars.ContextVar(
+    "repository_timings", default=None
+)
+
+
+def start_collecting() -> contextvars.Token:
+    """Install a fresh collector; returns a token for stop_collecting."""
+    return _current.set(RepositoryTimings())
+
+
+def stop_collecting(token: contextvars.Token) -> RepositoryTimings:
+    """Remove the collector installed by start_collecting and return what it recorded."""
+    timings = _current.get()
+    _current.reset(token)
+    return timings or RepositoryTimings()
+
+
+def record(operation: str, seconds: float, result: Any) -> None:
+    """Record a repository call if a collector is active."""
+    timings = _current.get()
+    if timings is not None:
+        timings.record(operation, seconds, result_rows(result))
+
+
+def result_rows(result: Any) -> int:
+    """Number of rows a repository result carries (entity lists, list results, single entities)."""
+    if isinstance(result, tuple) and result and isinstance(result[0], list):
+        return len(result[0])
+    if isinstance(result, (list, dict)):
+        return len(result)
+    if result is None or isinstance(result, (bool, int, float, str)):
+        return 0
+    return 1
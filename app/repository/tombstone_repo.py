@@ -0,0 +1,80 @@
+"""
+Export tombstone repository implementation.
+
+Tombstones are written by database triggers when nodes and relationships
+are deleted (see migration 017), so every delete path, including cascades,
+is recorded.
+"""
+
+from datetime import datetime
+from typing import List
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import Tombstone
+from app.repository.retry import with_retry
+
+
+class TombstoneRepository:
+    """PostgreSQL export tombstone repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry(idempotent=True)
+    async def sync_position(self) -> str:
+        """
+        Return the current transaction ID horizon: every transaction before it
+        has finished, so changes at or after it include everything not yet visible.
+        """
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval("SELECT pg_snapshot_xmin(pg_current_snapshot())::text")
+
+    @with_retry(idempotent=True)
+    async def commit_position(self) -> str:
+        """
+        Return the first transaction ID not yet assigned: every transaction
+        that has committed, including the caller's own writes, is before it.
+        """
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval("SELECT pg_snapshot_xmax(pg_current_snapshot())::text")
+
+    @with_retry(idempotent=True)
+    async def changed_since(self, changed_since: str) -> bool:
+        """Whether any node or relationship was written or deleted at or after a transaction ID horizon."""
+        query = """
+            SELECT EXISTS (SELECT 1 FROM nodes WHERE change_xid >= $1::text::xid8)
+                OR EXISTS (SELECT 1 FROM relationships WHERE change_xid >= $1::text::xid8)
+                OR EXISTS (SELECT 1 FROM export_tombstones WHERE change_xid >= $1::text::xid8)
+        """
+
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval(query, changed_since)
+
+    @with_retry(idempotent=True)
+    async def list_since(self, changed_since: str, after_id: str, limit: int) -> List[Tombstone]:
+        """Retrieve up to limit tombstones recorded at or after changed_since, ordered by ID after after_id."""
+        query = """
+            SELECT entity_type, entity_id, deleted_at
+            FROM export_tombstones
+            WHERE change_xid >= $1::text::xid8 AND ($2::uuid IS NULL OR entity_id > $2)
+            ORDER BY entity_id
+            LIMIT $3
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, changed_since, after_id or None, limit)
+
+        return [self._row_to_tombstone(row) for row in rows]
+
+    @with_retry()
+    async def prune(self, deleted_before: datetime) -> int:
+        """Delete tombstones recorded before a time; returns how many were removed."""
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute("DELETE FROM export_tombstones WHERE deleted_at < $1", deleted_before)
+        return int(result.split()[-1])
+
+    def _row_to_tombstone(self, row: asyncpg.Record) -> Tombstone:
+        """Convert a database row to a Tombstone object."""
+        return Tombstone(entity_type=row[0], entity_id=str(row[1]), deleted_at=row[2])
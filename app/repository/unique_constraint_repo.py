@@ -0,0 +1,222 @@
+"""
+Unique constraint repository implementation.
+
+Each constraint is a partial unique expression index on nodes, e.g.
+
+    CREATE UNIQUE INDEX uq_nodes_<id> ON nodes (lower(data #>> '{contact,email}'))
+    WHERE node_type_id = '<node type ID>'
+
+built concurrently so writes continue meanwhile. Nodes without the path
+(a NULL value) are not constrained.
+"""
+
+import uuid
+from datetime import datetime
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.compression import decode_data
+from app.repository.errors import AlreadyExistsError, NotFoundError
+from app.repository.models import UniqueConstraint
+from app.repository.ordering import json_path_expression
+from app.repository.retry import with_retry
+
+UNIQUE_INDEX_PREFIX = "uq_nodes_"
+# Compressed documents rewritten per query when a constraint is created
+_DECODE_BATCH_SIZE = 100
+
+_COLUMNS = "id, node_type_id, path, case_insensitive, status, created_at"
+
+
+def index_name(constraint_id: str) -> str:
+    """Name of the index enforcing a constraint."""
+    return UNIQUE_INDEX_PREFIX + uuid.UUID(constraint_id).hex
+
+
+def _value_expression(constraint: UniqueConstraint) -> str:
+    """Render the constrained value as a literal SQL expression (as text, lowercased if case-insensitive)."""
+    # Validates the path segments, which are rendered literally
+    json_path_expression("data", ".".join(constraint.path))
+    expression = f"(data #>> '{{{','.join(constraint.path)}}}')"
+    return f"lower{expression}" if constraint.case_insensitive else expression
+
+
+def _predicate(constraint: UniqueConstraint) -> str:
+    return f"node_type_id = '{uuid.UUID(constraint.node_type_id)}'"
+
+
+class UniqueConstraintRepository:
+    """PostgreSQL unique constraint repository (tenant database)."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, constraint: UniqueConstraint) -> UniqueConstraint:
+        """
+        Record a new constraint, still building; node documents of its type are stored plain from then on.
+
+        Raises:
+            AlreadyExistsError: If the node type already has a constraint on the path
+        """
+        constraint.id = str(uuid.uuid4())
+        constraint.created_at = datetime.now()
+        constraint.status = "building"
+
+        query = f"""
+            INSERT INTO unique_constraints (id, node_type_id, path, case_insensitive, status, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                row = await conn.fetchrow(
+                    query,
+                    constraint.id, constraint.node_type_id, constraint.path, constraint.case_insensitive,
+                    constraint.status, constraint.created_at
+                )
+            except asyncpg.UniqueViolationError as e:
+                raise AlreadyExistsError(
+                    f"node type already has a unique constraint on data.{'.'.join(constraint.path)}"
+                ) from e
+
+        return self._row_to_constraint(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> UniqueConstraint:
+        """Retrieve a constraint by ID."""
+        query = f"SELECT {_COLUMNS} FROM unique_constraints WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"unique constraint not found: {id}")
+
+        return self._row_to_constraint(row)
+
+    @with_retry(idempotent=True)
+    async def list(self, node_type_id: str = "") -> List[UniqueConstraint]:
+        """Retrieve the constraints of a node type (or of every node type)."""
+        query = f"""
+            SELECT {_COLUMNS} FROM unique_constraints
+            WHERE $1::uuid IS NULL OR node_type_id = $1::uuid
+            ORDER BY created_at, id
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_type_id or None)
+
+        return [self._row_to_constraint(row) for row in rows]
+
+    @with_retry(idempotent=True)
+    async def exists(self) -> bool:
+        """Whether any node type has unique constraints."""
+        async with self.db.pool.acquire() as conn:
+            return await conn.fetchval("SELECT EXISTS (SELECT 1 FROM unique_constraints)")
+
+    @with_retry(idempotent=True)
+    async def find_duplicates(self, constraint: UniqueConstraint, limit: int) -> List[Tuple[str, int]]:
+        """Return up to limit values the constraint would reject, with how many nodes have each."""
+        expression = _value_expression(constraint)
+        query = f"""
+            SELECT {expression} AS value, COUNT(*) FROM nodes
+            WHERE {_predicate(constraint)} AND {expression} IS NOT NULL
+            GROUP BY 1 HAVING COUNT(*) > 1
+            ORDER BY 2 DESC, 1
+            LIMIT $1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, limit)
+
+        return [(row[0], row[1]) for row in rows]
+
+    @with_retry()
+    async def store_plain(self, node_type_id: str) -> int:
+        """
+        Rewrite the compressed documents of a node type's nodes as plain
+        JSONB, which unique indexes can read; returns how many were rewritten.
+
+        Raises:
+            AlreadyExistsError: If a rewritten document violates a built constraint
+        """
+        select = """
+            SELECT id, data_compressed FROM nodes
+            WHERE node_type_id = $1 AND data_compressed IS NOT NULL
+            ORDER BY id LIMIT $2
+        """
+        # Unless written meanwhile (a write stores plain documents once the constraint exists)
+        update = "UPDATE nodes SET data = $2::jsonb, data_compressed = NULL WHERE id = $1 AND data_compressed = $3"
+        rewritten = 0
+
+        async with self.db.pool.acquire() as conn:
+            while True:
+                rows = await conn.fetch(select, node_type_id, _DECODE_BATCH_SIZE)
+                if not rows:
+                    return rewritten
+                for row in rows:
+                    try:
+                        await conn.execute(update, row[0], decode_data(row[1]), row[1])
+                    except asyncpg.UniqueViolationError as e:
+                        raise AlreadyExistsError(f"node {row[0]} violates the unique constraint: {e.detail}") from e
+                    rewritten += 1
+
+    async def build_index(self, constraint: UniqueConstraint) -> None:
+        """
+        Build the index enforcing a constraint without blocking writes.
+
+        Raises:
+            AlreadyExistsError: If nodes violate the constraint (the index is dropped again)
+        """
+        name = index_name(constraint.id)
+        query = f"""
+            CREATE UNIQUE INDEX CONCURRENTLY {name}
+            ON nodes ({_value_expression(constraint)})
+            WHERE {_predicate(constraint)}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            try:
+                await conn.execute(query)
+            except Exception as e:
+                # A failed concurrent build leaves an invalid index behind
+                await conn.execute(f"DROP INDEX CONCURRENTLY IF EXISTS {name}")
+                if isinstance(e, asyncpg.UniqueViolationError):
+                    raise AlreadyExistsError(f"nodes violate the unique constraint: {e.detail}") from e
+                raise
+
+    @with_retry()
+    async def activate(self, id: str) -> UniqueConstraint:
+        """Mark a constraint's index built."""
+        query = f"UPDATE unique_constraints SET status = 'active' WHERE id = $1 RETURNING {_COLUMNS}"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"unique constraint not found: {id}")
+
+        return self._row_to_constraint(row)
+
+    async def delete(self, id: str) -> None:
+        """Drop a constraint and its index."""
+        async with self.db.pool.acquire() as conn:
+            await conn.execute(f"DROP INDEX CONCURRENTLY IF EXISTS {index_name(id)}")
+            result = await conn.execute("DELETE FROM unique_constraints WHERE id = $1", id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"unique constraint not found: {id}")
+
+    def _row_to_constraint(self, row: asyncpg.Record) -> UniqueConstraint:
+        return UniqueConstraint(
+            id=str(row["id"]),
+            node_type_id=str(row["node_type_id"]),
+            path=list(row["path"]),
+            case_insensitive=row["case_insensitive"],
+            status=row["status"],
+            created_at=row["created_at"],
+        )
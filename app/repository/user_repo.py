@@ -4,13 +4,18 @@ User repository implementation.
 
 import uuid
 from datetime import datetime
-from typing import List, Tuple
+from typing import List, Optional, Tuple
 
 import asyncpg
 
 from app.db.database import Database
 from app.repository.models import User, TenantUser, ListOptions, ListResult
 from app.repository.errors import NotFoundError
+from app.repository.ordering import build_order_by
+from app.repository.retry import with_retry
+from app.repository.tenant_repo import MAX_HIERARCHY_DEPTH
+
+SORTABLE_COLUMNS = ("email", "display_name", "created_at", "updated_at")
 
 
 class UserRepository:
@@ -19,6 +24,7 @@ class UserRepository:
     def __init__(self, db: Database):
         self.db = db
 
+    @with_retry()
     async def create(self, user: User) -> User:
         """Create a new user."""
         user.id = str(uuid.uuid4())
@@ -39,6 +45,7 @@ class UserRepository:
 
         return self._row_to_user(row)
 
+    @with_retry(idempotent=True)
     async def get_by_id(self, id: str) -> User:
         """Retrieve a user by ID."""
         query = "SELECT id, email, display_name, created_at, updated_at FROM users WHERE id = $1"
@@ -51,6 +58,7 @@ class UserRepository:
 
         return self._row_to_user(row)
 
+    @with_retry()
     async def update(self, user: User) -> User:
         """Update an existing user."""
         user.updated_at = datetime.now()
@@ -73,6 +81,7 @@ class UserRepository:
 
         return self._row_to_user(row)
 
+    @with_retry()
     async def delete(self, id: str) -> None:
         """Delete a user by ID."""
         query = "DELETE FROM users WHERE id = $1"
@@ -83,9 +92,10 @@ class UserRepository:
         if result == "DELETE 0":
             raise NotFoundError(f"user not found: {id}")
 
+    @with_retry(idempotent=True)
     async def list(self, opts: ListOptions) -> Tuple[List[User], ListResult]:
         """Retrieve users with pagination."""
-        page_size = max(1, min(opts.page_size or 10, 100))
+        page_size = opts.effective_page_size()
         offset = 0
         if opts.page_token:
             try:
@@ -93,13 +103,15 @@ class UserRepository:
             except ValueError:
                 offset = 0
 
+        order_clause = build_order_by(opts.order_by, SORTABLE_COLUMNS)
+
         async with self.db.pool.acquire() as conn:
             total_count = await conn.fetchval("SELECT COUNT(*) FROM users")
 
-            query = """
+            query = f"""
                 SELECT id, email, display_name, created_at, updated_at 
                 FROM users 
-                ORDER BY created_at DESC 
+                {order_clause}
                 LIMIT $1 OFFSET $2
             """
             rows = await conn.fetch(query, page_size, offset)
@@ -113,6 +125,7 @@ class UserRepository:
 
         return users, result
 
+    @with_retry()
     async def add_to_tenant(self, tenant_user: TenantUser) -> TenantUser:
         """Add a user to a tenant."""
         if not tenant_user.role:
@@ -135,6 +148,7 @@ class UserRepository:
 
         return self._row_to_tenant_user(row)
 
+    @with_retry()
     async def remove_from_tenant(self, tenant_id: str, user_id: str) -> None:
         """Remove a user from a tenant."""
         query = "DELETE FROM tenant_users WHERE tenant_id = $1 AND user_id = $2"
@@ -145,9 +159,50 @@ class UserRepository:
         if result == "DELETE 0":
             raise NotFoundError(f"tenant_user not found: tenant_id={tenant_id}, user_id={user_id}")
 
+    @with_retry(idempotent=True)
+    async def get_tenant_user(self, tenant_id: str, user_id: str) -> Optional[TenantUser]:
+        """Retrieve a user's tenant membership, or None if they are not a member."""
+        query = """
+            SELECT tenant_id, user_id, role, status
+            FROM tenant_users
+            WHERE tenant_id = $1 AND user_id = $2
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, tenant_id, user_id)
+
+        return self._row_to_tenant_user(row) if row else None
+
+    @with_retry(idempotent=True)
+    async def get_inherited_tenant_user(self, tenant_id: str, user_id: str) -> Optional[TenantUser]:
+        """
+        Retrieve a user's membership of a tenant or, failing that, of its
+        nearest ancestor tenant that has one; None if there is none.
+        """
+        query = """
+            WITH RECURSIVE chain AS (
+                SELECT id, parent_id, 0 AS depth FROM tenants WHERE id = $1
+                UNION ALL
+                SELECT t.id, t.parent_id, c.depth + 1
+                FROM tenants t JOIN chain c ON t.id = c.parent_id
+                WHERE c.depth < $3
+            )
+            SELECT tu.tenant_id, tu.user_id, tu.role, tu.status
+            FROM chain c JOIN tenant_users tu ON tu.tenant_id = c.id
+            WHERE tu.user_id = $2
+            ORDER BY c.depth
+            LIMIT 1
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, tenant_id, user_id, MAX_HIERARCHY_DEPTH)
+
+        return self._row_to_tenant_user(row) if row else None
+
+    @with_retry(idempotent=True)
     async def list_tenant_users(self, tenant_id: str, opts: ListOptions) -> Tuple[List[TenantUser], ListResult]:
         """List users in a tenant."""
-        page_size = max(1, min(opts.page_size or 10, 100))
+        page_size = opts.effective_page_size()
         offset = 0
         if opts.page_token:
             try:
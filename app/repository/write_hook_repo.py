@@ -0,0 +1,169 @@
+"""
+WriteHook repository implementation.
+"""
+
+import uuid
+from datetime import datetime
+from typing import List, Tuple
+
+import asyncpg
+
+from app.db.database import Database
+from app.repository.models import WriteHook, ListOptions, ListResult
+from app.repository.errors import NotFoundError
+from app.repository.retry import with_retry
+
+_COLUMNS = """
+    id, name, node_type_id, phase, action, operations, expression,
+    timeout_ms, priority, enabled, created_at, updated_at
+"""
+
+
+class WriteHookRepository:
+    """PostgreSQL write hook repository."""
+
+    def __init__(self, db: Database):
+        self.db = db
+
+    @with_retry()
+    async def create(self, hook: WriteHook) -> WriteHook:
+        """Create a new write hook."""
+        hook.id = str(uuid.uuid4())
+        hook.created_at = datetime.now()
+        hook.updated_at = datetime.now()
+
+        query = f"""
+            INSERT INTO write_hooks (
+                id, name, node_type_id, phase, action, operations, expression,
+                timeout_ms, priority, enabled, created_at, updated_at
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                hook.id, hook.name, hook.node_type_id or None, hook.phase, hook.action,
+                hook.operations, hook.expression, hook.timeout_ms, hook.priority,
+                hook.enabled, hook.created_at, hook.updated_at
+            )
+
+        return self._row_to_write_hook(row)
+
+    @with_retry(idempotent=True)
+    async def get_by_id(self, id: str) -> WriteHook:
+        """Retrieve a write hook by ID."""
+        query = f"SELECT {_COLUMNS} FROM write_hooks WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(query, id)
+
+        if not row:
+            raise NotFoundError(f"write_hook not found: {id}")
+
+        return self._row_to_write_hook(row)
+
+    @with_retry()
+    async def update(self, hook: WriteHook) -> WriteHook:
+        """Update an existing write hook."""
+        hook.updated_at = datetime.now()
+
+        query = f"""
+            UPDATE write_hooks
+            SET name = $2, node_type_id = $3, phase = $4, action = $5, operations = $6,
+                expression = $7, timeout_ms = $8, priority = $9, enabled = $10, updated_at = $11
+            WHERE id = $1
+            RETURNING {_COLUMNS}
+        """
+
+        async with self.db.pool.acquire() as conn:
+            row = await conn.fetchrow(
+                query,
+                hook.id, hook.name, hook.node_type_id or None, hook.phase, hook.action,
+                hook.operations, hook.expression, hook.timeout_ms, hook.priority,
+                hook.enabled, hook.updated_at
+            )
+
+        if not row:
+            raise NotFoundError(f"write_hook not found: {hook.id}")
+
+        return self._row_to_write_hook(row)
+
+    @with_retry()
+    async def delete(self, id: str) -> None:
+        """Delete a write hook by ID."""
+        query = "DELETE FROM write_hooks WHERE id = $1"
+
+        async with self.db.pool.acquire() as conn:
+            result = await conn.execute(query, id)
+
+        if result == "DELETE 0":
+            raise NotFoundError(f"write_hook not found: {id}")
+
+    @with_retry(idempotent=True)
+    async def list(self, opts: ListOptions) -> Tuple[List[WriteHook], ListResult]:
+        """Retrieve write hooks with pagination."""
+        page_size = opts.effective_page_size()
+        offset = 0
+        if opts.page_token:
+            try:
+                offset = int(opts.page_token)
+            except ValueError:
+                offset = 0
+
+        async with self.db.pool.acquire() as conn:
+            total_count = await conn.fetchval("SELECT COUNT(*) FROM write_hooks")
+
+            query = f"""
+                SELECT {_COLUMNS}
+                FROM write_hooks
+                ORDER BY priority, name
+                LIMIT $1 OFFSET $2
+            """
+            rows = await conn.fetch(query, page_size, offset)
+
+        hooks = [self._row_to_write_hook(row) for row in rows]
+
+        result = ListResult(total_count=total_count)
+        next_offset = offset + len(hooks)
+        if next_offset < total_count:
+            result.next_page_token = str(next_offset)
+
+        return hooks, result
+
+    @with_retry(idempotent=True)
+    async def list_active(self, node_type_id: str, phase: str, operation: str) -> List[WriteHook]:
+        """Retrieve enabled hooks applicable to a node type, phase and operation, in run order."""
+        query = f"""
+            SELECT {_COLUMNS}
+            FROM write_hooks
+            WHERE enabled
+              AND phase = $2
+              AND $3 = ANY(operations)
+              AND (node_type_id IS NULL OR node_type_id = $1)
+            ORDER BY priority, name
+        """
+
+        async with self.db.pool.acquire() as conn:
+            rows = await conn.fetch(query, node_type_id, phase, operation)
+
+        return [self._row_to_write_hook(row) for row in rows]
+
+    def _row_to_write_hook(self, row: asyncpg.Record) -> WriteHook:
+        """Convert a database row to a WriteHook object."""
+        return WriteHook(
+            id=str(row["id"]),
+            tenant_id="",  # Not stored in tenant database (each tenant has own DB)
+            name=row["name"],
+            node_type_id=str(row["node_type_id"]) if row["node_type_id"] else "",
+            phase=row["phase"],
+            action=row["action"],
+            operations=list(row["operations"] or []),
+            expression=row["expression"],
+            timeout_ms=row["timeout_ms"],
+            priority=row["priority"],
+            enabled=row["enabled"],
+            created_at=row["created_at"],
+            updated_at=row["updated_at"],
+        )
@@ -0,0 +1,17 @@
+"""
+Sandboxed expression evaluation module.
+"""
+
+from app.scripting.cel import (
+    CompiledExpression,
+    ExpressionError,
+    compile_expression,
+    evaluate,
+)
+
+__all__ = [
+    "CompiledExpression",
+    "ExpressionError",
+    "compile_expression",
+    "evaluate",
+]
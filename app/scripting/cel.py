@@ -0,0 +1,107 @@
+"""
+CEL (Common Expression Language) evaluation with timeouts and limits.
+
+CEL is non-Turing-complete and side-effect free, which makes it suitable for
+tenant-supplied business rules: expressions can only read the activation
+variables they are given and return a value.
+"""
+
+import asyncio
+import functools
+from dataclasses import dataclass
+from typing import Any, Dict
+
+import celpy
+from celpy import celtypes
+
+# Resource limits applied to every expression
+MAX_EXPRESSION_LENGTH = 4096
+DEFAULT_TIMEOUT_MS = 100
+MAX_TIMEOUT_MS = 1000
+
+
+class ExpressionError(ValueError):
+    """Raised when an expression fails to compile, evaluate, or finish in time."""
+    pass
+
+
+@dataclass
+class CompiledExpression:
+    """A parsed CEL program ready for evaluation."""
+    source: str
+    program: Any
+
+
+@functools.lru_cache(maxsize=1024)
+def compile_expression(source: str) -> CompiledExpression:
+    """Compile a CEL expression (cached by source text)."""
+    if not source or not source.strip():
+        raise ExpressionError("expression is required")
+    if len(source) > MAX_EXPRESSION_LENGTH:
+        raise ExpressionError(f"expression exceeds {MAX_EXPRESSION_LENGTH} characters")
+
+    env = celpy.Environment()
+    try:
+        ast = env.compile(source)
+        program = env.program(ast)
+    except celpy.CELParseError as e:
+        raise ExpressionError(f"invalid expression: {e}") from e
+    return CompiledExpression(source=source, program=program)
+
+
+def _evaluate_sync(compiled: CompiledExpression, activation: Dict[str, Any]) -> Any:
+    cel_activation = {name: celpy.json_to_cel(value) for name, value in activation.items()}
+    try:
+        result = compiled.program.evaluate(cel_activation)
+    except celpy.CELEvalError as e:
+        raise ExpressionError(f"expression evaluation failed: {e}") from e
+    if isinstance(result, celpy.CELEvalError):
+        raise ExpressionError(f"expression evaluation failed: {result}")
+    return cel_to_python(result)
+
+
+async def evaluate(
+    compiled: CompiledExpression,
+    activation: Dict[str, Any],
+    timeout_ms: int = DEFAULT_TIMEOUT_MS,
+) -> Any:
+    """
+    Evaluate a compiled expression off the event loop with a timeout.
+
+    Args:
+        compiled: Program returned by compile_expression
+        activation: JSON-compatible variables visible to the expression
+        timeout_ms: Evaluation deadline, capped at MAX_TIMEOUT_MS
+
+    Returns:
+        The expression result converted to plain Python/JSON types
+    """
+    timeout_ms = max(1, min(timeout_ms or DEFAULT_TIMEOUT_MS, MAX_TIMEOUT_MS))
+    try:
+        return await asyncio.wait_for(
+            asyncio.to_thread(_evaluate_sync, compiled, activation),
+            timeout=timeout_ms / 1000,
+        )
+    except asyncio.TimeoutError as e:
+        raise ExpressionError(f"expression timed out after {timeout_ms}ms") from e
+
+
+def cel_to_python(value: Any) -> Any:
+    """Convert CEL runtime values to plain JSON-compatible Python values."""
+    if value is None or isinstance(value, celtypes.NullType):
+        return None
+    if isinstance(value, celtypes.BoolType):
+        return bool(value)
+    if isinstance(value, (celtypes.IntType, celtypes.UintType)):
+        return int(value)
+    if isinstance(value, celtypes.DoubleType):
+        return float(value)
+    if isinstance(value, celtypes.StringType):
+        return str(value)
+    if isinstance(value, dict):
+        return {str(cel_to_python(k)): cel_to_python(v) for k, v in value.items()}
+    if isinstance(value, (list, tuple)):
+        return [cel_to_python(v) for v in value]
+    if isinstance(value, (celtypes.TimestampType, celtypes.DurationType)):
+        return str(value)
+    return value
@@ -0,0 +1,21 @@
+"""
+External search index module for full-text node search.
+"""
+
+from app.search.search_index import (
+    SearchIndex,
+    OpenSearchIndex,
+    MemorySearchIndex,
+    SearchHit,
+    configure_search_index,
+    get_search_index,
+)
+
+__all__ = [
+    "SearchIndex",
+    "OpenSearchIndex",
+    "MemorySearchIndex",
+    "SearchHit",
+    "configure_search_index",
+    "get_search_index",
+]
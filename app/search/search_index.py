@@ -0,0 +1,224 @@
+"""
+External full-text search indexes.
+
+With a search index configured, node writes are mirrored into one index per
+tenant by the search indexer (app/service/search_service.py), which follows
+each tenant's incremental export stream, and search_nodes queries the index
+instead of PostgreSQL full-text search. The index only ranks: matching IDs
+are read back from the tenant database, so results are never staler than
+the database, only possibly incomplete until the indexer catches up.
+
+Each indexed node is its node type ID, its update time and ``content``: the
+string, number and boolean values of its data, at every depth, as one text
+field. Field names are not indexed, so tenants' arbitrary documents do not
+grow the index mapping.
+"""
+
+import json
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository.models import Node
+
+# Search index requests time out after this long (seconds)
+REQUEST_TIMEOUT = 30.0
+
+_MAPPINGS = {
+    "dynamic": False,
+    "properties": {
+        "node_type_id": {"type": "keyword"},
+        "content": {"type": "text"},
+        "updated_at": {"type": "date"},
+    },
+}
+
+
+@dataclass
+class SearchHit:
+    """A node matching a search, with its relevance score."""
+    node_id: str
+    score: float
+
+
+def node_content(data: Any) -> str:
+    """Return the scalar values of a node's data, at every depth, one per line."""
+    values: List[str] = []
+    stack = [data]
+    while stack:
+        item = stack.pop()
+        if isinstance(item, dict):
+            stack.extend(reversed(list(item.values())))
+        elif isinstance(item, list):
+            stack.extend(reversed(item))
+        elif isinstance(item, bool):
+            values.append("true" if item else "false")
+        elif isinstance(item, (str, int, float)):
+            values.append(str(item))
+    return "\n".join(values)
+
+
+def node_document(node: Node) -> Dict[str, Any]:
+    """Return the indexed document of a node."""
+    return {
+        "node_type_id": node.node_type_id,
+        "content": node_content(json.loads(node.data or "{}")),
+        "updated_at": node.updated_at.isoformat(),
+    }
+
+
+class SearchIndex:
+    """Interface for search index backends."""
+
+    async def upsert_nodes(self, tenant_id: str, nodes: List[Node]) -> None:
+        """Index nodes, replacing earlier versions of them."""
+        raise NotImplementedError
+
+    async def delete_nodes(self, tenant_id: str, node_ids: List[str]) -> None:
+        """Remove nodes from the index; removing a node that is not indexed is not an error."""
+        raise NotImplementedError
+
+    async def delete_tenant(self, tenant_id: str) -> None:
+        """Remove a tenant's whole index."""
+        raise NotImplementedError
+
+    async def search(
+        self, tenant_id: str, query: str, node_type_id: str, offset: int, limit: int
+    ) -> Tuple[List[SearchHit], int]:
+        """Return a page of the nodes matching a query, best first, and the total number of matches."""
+        raise NotImplementedError
+
+
+class OpenSearchIndex(SearchIndex):
+    """OpenSearch (or Elasticsearch) index per tenant, over the REST API."""
+
+    def __init__(self, url: str, index_prefix: str = "flexdb-nodes-", username: str = "", password: str = ""):
+        import httpx
+
+        self.index_prefix = index_prefix
+        self.client = httpx.AsyncClient(
+            base_url=url.rstrip("/"),
+            auth=(username, password) if username else None,
+            timeout=REQUEST_TIMEOUT,
+        )
+        # Tenants whose index is known to exist
+        self._created: set = set()
+
+    def _index(self, tenant_id: str) -> str:
+        return f"{self.index_prefix}{tenant_id}".lower()
+
+    async def _ensure_index(self, tenant_id: str) -> None:
+        if tenant_id in self._created:
+            return
+        response = await self.client.put(f"/{self._index(tenant_id)}", json={"mappings": _MAPPINGS})
+        if response.status_code >= 400 and "resource_already_exists_exception" not in response.text:
+            raise RuntimeError(f"creating search index failed ({response.status_code}): {response.text[:500]}")
+        self._created.add(tenant_id)
+
+    async def _bulk(self, lines: List[Dict[str, Any]]) -> None:
+        body = "".join(json.dumps(line, separators=(",", ":")) + "\n" for line in lines)
+        response = await self.client.post(
+            "/_bulk", content=body.encode(), headers={"Content-Type": "application/x-ndjson"}
+        )
+        response.raise_for_status()
+        result = response.json()
+        if result.get("errors"):
+            for item in result.get("items", []):
+                action, outcome = next(iter(item.items()))
+                # Deleting a node that was never indexed is fine
+                if outcome.get("error") and not (action == "delete" and outcome.get("status") == 404):
+                    raise RuntimeError(f"search index {action} of {outcome.get('_id')} failed: {outcome['error']}")
+
+    async def upsert_nodes(self, tenant_id: str, nodes: List[Node]) -> None:
+        if not nodes:
+            return
+        await self._ensure_index(tenant_id)
+        index = self._index(tenant_id)
+        lines: List[Dict[str, Any]] = []
+        for node in nodes:
+            lines.append({"index": {"_index": index, "_id": node.id}})
+            lines.append(node_document(node))
+        await self._bulk(lines)
+
+    async def delete_nodes(self, tenant_id: str, node_ids: List[str]) -> None:
+        if not node_ids:
+            return
+        index = self._index(tenant_id)
+        await self._bulk([{"delete": {"_index": index, "_id": node_id}} for node_id in node_ids])
+
+    async def delete_tenant(self, tenant_id: str) -> None:
+        response = await self.client.delete(f"/{self._index(tenant_id)}")
+        if response.status_code >= 400 and response.status_code != 404:
+            response.raise_for_status()
+        self._created.discard(tenant_id)
+
+    async def search(
+        self, tenant_id: str, query: str, node_type_id: str, offset: int, limit: int
+    ) -> Tuple[List[SearchHit], int]:
+        request: Dict[str, Any] = {
+            "query": {
+                "bool": {
+                    "must": {"simple_query_string": {"query": query, "fields": ["content"], "default_operator": "and"}},
+                    "filter": [{"term": {"node_type_id": node_type_id}}] if node_type_id else [],
+                },
+            },
+            "from": offset,
+            "size": limit,
+            "_source": False,
+            "track_total_hits": True,
+        }
+        response = await self.client.post(f"/{self._index(tenant_id)}/_search", json=request)
+        if response.status_code == 404:
+            # Nothing indexed for the tenant yet
+            return [], 0
+        response.raise_for_status()
+        hits = response.json()["hits"]
+        return [SearchHit(node_id=h["_id"], score=h["_score"] or 0.0) for h in hits["hits"]], hits["total"]["value"]
+
+    async def close(self) -> None:
+        await self.client.aclose()
+
+
+class MemorySearchIndex(SearchIndex):
+    """In-memory search index for tests; a node matches when its content contains every query word."""
+
+    def __init__(self):
+        self.documents: Dict[str, Dict[str, Dict[str, Any]]] = {}
+
+    async def upsert_nodes(self, tenant_id: str, nodes: List[Node]) -> None:
+        for node in nodes:
+            self.documents.setdefault(tenant_id, {})[node.id] = node_document(node)
+
+    async def delete_nodes(self, tenant_id: str, node_ids: List[str]) -> None:
+        for node_id in node_ids:
+            self.documents.get(tenant_id, {}).pop(node_id, None)
+
+    async def delete_tenant(self, tenant_id: str) -> None:
+        self.documents.pop(tenant_id, None)
+
+    async def search(
+        self, tenant_id: str, query: str, node_type_id: str, offset: int, limit: int
+    ) -> Tuple[List[SearchHit], int]:
+        words = query.lower().split()
+        hits = []
+        for node_id, document in self.documents.get(tenant_id, {}).items():
+            if node_type_id and document["node_type_id"] != node_type_id:
+                continue
+            content = document["content"].lower()
+            if all(word in content for word in words):
+                hits.append(SearchHit(node_id=node_id, score=float(sum(content.count(w) for w in words))))
+        hits.sort(key=lambda h: (-h.score, h.node_id))
+        return hits[offset:offset + limit], len(hits)
+
+
+_search_index: Optional[SearchIndex] = None
+
+
+def configure_search_index(index: Optional[SearchIndex]) -> None:
+    """Set the search index (None routes searches to PostgreSQL)."""
+    global _search_index
+    _search_index = index
+
+
+def get_search_index() -> Optional[SearchIndex]:
+    """Return the configured search index, if any."""
+    return _search_index
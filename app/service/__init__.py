@@ -3,15 +3,114 @@ Service module initialization.
 """
 
 from app.service.tenant_service import TenantService
+from app.service.template_service import TemplateService
 from app.service.user_service import UserService
 from app.service.nodetype_service import NodeTypeService
 from app.service.node_service import NodeService
 from app.service.relationship_service import RelationshipService
+from app.service.relationship_type_service import RelationshipTypeService
+from app.service.write_hook_service import WriteHookService
+from app.service.authz_policy_service import AuthzPolicyService
+from app.service.attachment_service import AttachmentService
+from app.service.graph_stats_service import GraphStatsService
+from app.service.audit_service import AuditService
+from app.service.impersonation_service import ImpersonationService
+from app.service.api_key_service import ApiKeyService
+from app.service.onboarding_service import OnboardingService
+from app.service.dual_write_service import DualWriteService
+from app.service.shard_service import ShardService
+from app.service.operation_service import OperationService
+from app.service.bulk_service import BulkService
+from app.service.limits import TenantLimits, TenantLimitsCache
+from app.service.maintenance import TenantMaintenanceCache
+from app.service.tenant_debug import TenantDebugCache
+from app.service.expansion import ExpansionService
+from app.service.stats_service import StatsService
+from app.service.table_maintenance import TableMaintenanceScheduler, TableMaintenanceService
+from app.service.explain_service import ExplainService
+from app.service.data_migrations import DataMigrationService
+from app.service.relationship_import import RelationshipImportService
+from app.service.node_import import NodeImportService
+from app.service.unique_constraint_service import UniqueConstraintService
+from app.service.directory_service import DirectoryService
+from app.service.export_service import ExportService
+from app.service.tenant_key_service import TenantKeyService
+from app.service.node_alias_service import NodeAliasService
+from app.service.subgraph_service import SubgraphService
+from app.service.graph_view_service import GraphViewService
+from app.service.sync_service import SyncService
+from app.service.batch_create import BatchCreateService
+from app.service.saved_query_service import SavedQueryService
+from app.service.node_view_service import NodeViewService
+from app.service.centrality_service import CentralityService
+from app.service.community_service import CommunityService
+from app.service.search_service import SearchService, SearchIndexer
+from app.service.query_cache import QueryCache, QueryCacheService
+from app.service.import_throttle import ImportPacer, ImportThrottle
+from app.service.billing_service import BillingService, UsageRecorder
+from app.service.comparison_service import TenantComparisonService
+from app.service.export_schedule_service import ExportScheduleService
+from app.service.validation_report import ValidationReportService
+from app.service.admin_search_service import AdminSearchService
+from app.service.orphan_gc_service import OrphanCollectionService, OrphanCollector
 
 __all__ = [
     "TenantService",
+    "TemplateService",
     "UserService",
     "NodeTypeService",
     "NodeService",
     "RelationshipService",
+    "RelationshipTypeService",
+    "WriteHookService",
+    "AuthzPolicyService",
+    "AttachmentService",
+    "GraphStatsService",
+    "AuditService",
+    "ImpersonationService",
+    "ApiKeyService",
+    "OnboardingService",
+    "DualWriteService",
+    "ShardService",
+    "OperationService",
+    "BulkService",
+    "TenantLimits",
+    "TenantLimitsCache",
+    "TenantMaintenanceCache",
+    "TenantDebugCache",
+    "ExpansionService",
+    "StatsService",
+    "TableMaintenanceService",
+    "TableMaintenanceScheduler",
+    "ExplainService",
+    "DataMigrationService",
+    "RelationshipImportService",
+    "NodeImportService",
+    "UniqueConstraintService",
+    "DirectoryService",
+    "ExportService",
+    "TenantKeyService",
+    "NodeAliasService",
+    "SubgraphService",
+    "GraphViewService",
+    "SyncService",
+    "BatchCreateService",
+    "SavedQueryService",
+    "NodeViewService",
+    "CentralityService",
+    "CommunityService",
+    "SearchService",
+    "SearchIndexer",
+    "QueryCache",
+    "QueryCacheService",
+    "ImportPacer",
+    "ImportThrottle",
+    "BillingService",
+    "UsageRecorder",
+    "TenantComparisonService",
+    "ExportScheduleService",
+    "ValidationReportService",
+    "AdminSearchService",
+    "OrphanCollectionService",
+    "OrphanCollector",
 ]
@@ -0,0 +1,220 @@
+"""
+Cross-tenant search for administrators.
+
+Support staff often only have a value from a customer, such as an email
+address or an ID from a screenshot, and not the tenant it belongs to. An
+admin search looks the value up in every tenant (or the given ones) as:
+
+- ``id``: a node ID, as a UUID or as an opaque external ID (see
+  app/jsonrpc/external_ids.py)
+- ``alias``: the value of any node alias (see node_alias_service.py)
+- ``content``: a full-text search of node data (see search_service.py)
+
+Each result is annotated with its tenant and how it matched. Tenants are
+searched a few at a time; a tenant that cannot be searched is reported in
+the errors rather than failing the search.
+
+Every search is recorded in the audit log with the value searched for and
+the records returned, as one event without a tenant and one event per tenant
+whose records were returned, so each tenant's audit trail shows the access.
+A search whose access cannot be recorded fails instead of returning results.
+"""
+
+import asyncio
+import logging
+import uuid
+from dataclasses import dataclass, field
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from app.repository import AuditEvent, Node, Tenant, TenantRepository
+from app.service.audit_service import AuditService
+
+logger = logging.getLogger(__name__)
+
+MATCH_KINDS = ("id", "alias", "content")
+DEFAULT_MAX_RESULTS = 50
+MAX_RESULTS = 200
+MAX_VALUE_LENGTH = 512
+# Tenants searched at once, and how long one tenant may take
+SEARCH_CONCURRENCY = 8
+TENANT_TIMEOUT_SECONDS = 10.0
+AUDIT_METHOD = "admin_search_nodes"
+
+
+@dataclass
+class AdminSearchResult:
+    """A node found by an admin search, with its tenant and how it matched."""
+    tenant: Tenant
+    node: Node
+    match: str  # "id", "alias" or "content"
+    alias_name: str = ""
+    score: Optional[float] = None
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        result = {
+            "tenant": {
+                "id": self.tenant.id,
+                "slug": self.tenant.slug,
+                "name": self.tenant.name,
+                "status": self.tenant.status,
+            },
+            "match": self.match,
+            "node": self.node.to_dict(),
+        }
+        if self.alias_name:
+            result["alias_name"] = self.alias_name
+        if self.score is not None:
+            result["score"] = self.score
+        return result
+
+
+@dataclass
+class AdminSearch:
+    """The results of an admin search."""
+    results: List[AdminSearchResult] = field(default_factory=list)
+    tenants_searched: int = 0
+    # Tenants that could not be searched: [{"tenant_id", "error"}]
+    errors: List[Dict[str, str]] = field(default_factory=list)
+    truncated: bool = False
+
+
+class AdminSearchService:
+    """Cross-tenant admin search business logic service."""
+
+    def __init__(
+        self,
+        tenant_repo: TenantRepository,
+        tenant_services: Callable[[str], Awaitable[Dict[str, Any]]],
+        audit: AuditService,
+        decode_id: Optional[Callable[[str], Optional[uuid.UUID]]] = None,
+    ):
+        self.tenant_repo = tenant_repo
+        self.tenant_services = tenant_services
+        self.audit = audit
+        self.decode_id = decode_id
+
+    async def search(
+        self,
+        admin_id: str,
+        value: str,
+        match: Optional[List[str]] = None,
+        tenant_ids: Optional[List[str]] = None,
+        max_results: int = 0,
+        request_id: str = "",
+    ) -> AdminSearch:
+        """
+        Find the nodes of any tenant matching a value by ID, alias or content, and audit the access.
+
+        Raises:
+            ValueError: If the value, match kinds or max_results are invalid
+            NotFoundError: If one of tenant_ids does not exist
+        """
+        if not isinstance(value, str) or not value.strip():
+            raise ValueError("value is required")
+        if len(value) > MAX_VALUE_LENGTH:
+            raise ValueError(f"value must be at most {MAX_VALUE_LENGTH} characters")
+        match = list(match or MATCH_KINDS)
+        unknown = [m for m in match if m not in MATCH_KINDS]
+        if unknown:
+            raise ValueError(f"match must be some of: {', '.join(MATCH_KINDS)}")
+        max_results = max_results or DEFAULT_MAX_RESULTS
+        if not 1 <= max_results <= MAX_RESULTS:
+            raise ValueError(f"max_results must be between 1 and {MAX_RESULTS}")
+
+        databases = {tenant_id for tenant_id, _, _ in await self.tenant_repo.list_databases()}
+        if tenant_ids:
+            for tenant_id in tenant_ids:
+                await self.tenant_repo.get_by_id(tenant_id)
+            searched = [t for t in dict.fromkeys(tenant_ids) if t in databases]
+        else:
+            searched = sorted(databases)
+
+        node_id = self._node_id(value.strip())
+        semaphore = asyncio.Semaphore(SEARCH_CONCURRENCY)
+
+        async def search_tenant(tenant_id: str) -> Tuple[str, List[AdminSearchResult], str]:
+            async with semaphore:
+                try:
+                    results = await asyncio.wait_for(
+                        self._search_tenant(tenant_id, value, node_id, match, max_results), TENANT_TIMEOUT_SECONDS
+                    )
+                    return tenant_id, results, ""
+                except asyncio.TimeoutError:
+                    return tenant_id, [], f"timed out after {TENANT_TIMEOUT_SECONDS:g}s"
+                except Exception as e:
+                    logger.error(f"Admin search of tenant {tenant_id} failed: {e}")
+                    return tenant_id, [], str(e) or type(e).__name__
+
+        search = AdminSearch(tenants_searched=len(searched))
+        for tenant_id, results, error in await asyncio.gather(*(search_tenant(t) for t in searched)):
+            if error:
+                search.errors.append({"tenant_id": tenant_id, "error": error})
+            search.results += results
+        # Exact matches first, then by score
+        rank = {kind: i for i, kind in enumerate(MATCH_KINDS)}
+        search.results.sort(key=lambda r: (rank[r.match], -(r.score or 0.0), r.tenant.slug, r.node.id))
+        if len(search.results) > max_results:
+            search.results, search.truncated = search.results[:max_results], True
+
+        await self._audit(admin_id, request_id, value, match, search)
+        return search
+
+    def _node_id(self, value: str) -> str:
+        """The node ID a value may be, or "" if it is neither a UUID nor an external ID."""
+        try:
+            return str(uuid.UUID(value))
+        except ValueError:
+            pass
+        decoded = self.decode_id(value) if self.decode_id else None
+        return str(decoded) if decoded else ""
+
+    async def _search_tenant(
+        self, tenant_id: str, value: str, node_id: str, match: List[str], limit: int
+    ) -> List[AdminSearchResult]:
+        tenant = await self.tenant_repo.get_by_id(tenant_id)
+        services = await self.tenant_services(tenant_id)
+        node_repo = services["node"].repo
+        results: List[AdminSearchResult] = []
+        found = set()
+
+        def add(node: Node, kind: str, alias_name: str = "", score: Optional[float] = None) -> None:
+            if node.id not in found:
+                found.add(node.id)
+                results.append(AdminSearchResult(tenant, node, kind, alias_name, score))
+
+        if "id" in match and node_id:
+            for node in await node_repo.get_many([node_id]):
+                add(node, "id")
+        if "alias" in match:
+            aliases = await services["node_alias"].repo.find_by_value(value, limit)
+            nodes = {n.id: n for n in await node_repo.get_many([id for _, id in aliases])}
+            for name, id in aliases:
+                if id in nodes:
+                    add(nodes[id], "alias", alias_name=name)
+        if "content" in match:
+            hits, _ = await services["search"].search(value, "", limit, "")
+            for node, score in hits:
+                add(node, "content", score=score)
+        return results
+
+    async def _audit(self, admin_id: str, request_id: str, value: str, match: List[str], search: AdminSearch) -> None:
+        by_tenant: Dict[str, List[str]] = {}
+        for result in search.results:
+            by_tenant.setdefault(result.tenant.id, []).append(result.node.id)
+        events = [AuditEvent(
+            request_id=request_id, actor_id=admin_id, method=AUDIT_METHOD,
+            details={
+                "value": value,
+                "match": match,
+                "tenants_searched": search.tenants_searched,
+                "result_count": len(search.results),
+            },
+        )]
+        for tenant_id, node_ids in by_tenant.items():
+            events.append(AuditEvent(
+                request_id=request_id, actor_id=admin_id, tenant_id=tenant_id, method=AUDIT_METHOD,
+                details={"value": value, "node_ids": node_ids},
+            ))
+        for event in events:
+            await self.audit.record(event)
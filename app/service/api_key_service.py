@@ -0,0 +1,122 @@
+"""
+Scoped API key service implementation.
+
+An API key is a bearer credential that runs as a subject (see
+app/authz/api_keys.py for how calls carrying one are checked). Its scope
+limits the methods it can call, so pipelines get only what they need:
+
+- ``read``: reads and exports
+- ``export``: exports and their schedules and operations only
+- ``write``: everything except administration
+- ``admin``: everything (only for administrators)
+
+Callers create, list and revoke their own keys. Administrators can also
+manage other subjects' keys and issue admin keys, but only when a credential
+identified them (admin_id, see RequestContext.admin_subject_id): the
+X-User-ID header alone would let anyone mint an admin key.
+"""
+
+import secrets
+from datetime import datetime, timedelta, timezone
+from typing import Callable, List, Optional, Tuple
+
+from app.repository import ApiKey, ApiKeyRepository, ListOptions, ListResult, PermissionDeniedError
+from app.service.impersonation_service import hash_token
+
+TOKEN_PREFIX = "fdb_"
+SCOPES = ("read", "write", "export", "admin")
+
+
+class ApiKeyService:
+    """Issues, lists, revokes and resolves scoped API keys."""
+
+    def __init__(self, repo: ApiKeyRepository, is_admin: Callable[[str], bool]):
+        self.repo = repo
+        self.is_admin = is_admin
+
+    def _caller_is_admin(self, caller_id: str, admin_id: str) -> bool:
+        return bool(admin_id) and admin_id == caller_id and self.is_admin(admin_id)
+
+    async def create(
+        self,
+        caller_id: str,
+        name: str,
+        scope: str,
+        tenant_ids: Optional[List[str]] = None,
+        subject_id: str = "",
+        ttl_seconds: int = 0,
+        admin_id: str = "",
+    ) -> Tuple[str, ApiKey]:
+        """
+        Issue an API key running as subject_id (default: the caller).
+
+        admin_id is the caller when a credential identified them, else "".
+        Returns the bearer key (shown only once) and the stored key.
+
+        Raises:
+            PermissionDeniedError: If a non-administrator issues a key for someone else
+            ValueError: If the key is invalid
+        """
+        if not caller_id:
+            raise PermissionDeniedError("api keys can only be created by an identified caller")
+        subject_id = subject_id or caller_id
+        caller_is_admin = self._caller_is_admin(caller_id, admin_id)
+        if subject_id != caller_id and not caller_is_admin:
+            raise PermissionDeniedError("only administrators can create api keys for other subjects")
+        if not name or len(name) > 255:
+            raise ValueError("name must be between 1 and 255 characters")
+        if scope not in SCOPES:
+            raise ValueError(f"scope must be one of: {', '.join(SCOPES)}")
+        if scope == "admin" and not (caller_is_admin and self.is_admin(subject_id)):
+            raise ValueError("scope admin is only for administrators identified by a credential")
+        tenant_ids = tenant_ids or []
+        if not isinstance(tenant_ids, list) or not all(isinstance(t, str) and t for t in tenant_ids):
+            raise ValueError("tenant_ids must be a list of tenant IDs")
+        if ttl_seconds < 0:
+            raise ValueError("ttl_seconds must not be negative")
+
+        token = TOKEN_PREFIX + secrets.token_urlsafe(32)
+        key = ApiKey(
+            name=name,
+            subject_id=subject_id,
+            scope=scope,
+            tenant_ids=tenant_ids,
+            created_by=caller_id,
+            expires_at=datetime.now(timezone.utc) + timedelta(seconds=ttl_seconds) if ttl_seconds else None,
+        )
+        return token, await self.repo.create(key, hash_token(token))
+
+    async def list(
+        self, caller_id: str, subject_id: str, page_size: int, page_token: str, admin_id: str = ""
+    ) -> Tuple[List[ApiKey], ListResult]:
+        """Retrieve the caller's API keys, newest first (administrators: any subject's, or all)."""
+        if not self._caller_is_admin(caller_id, admin_id):
+            if subject_id and subject_id != caller_id:
+                raise PermissionDeniedError("only administrators can list other subjects' api keys")
+            subject_id = caller_id
+        return await self.repo.list(subject_id, ListOptions(page_size=page_size, page_token=page_token))
+
+    async def revoke(self, caller_id: str, id: str, admin_id: str = "") -> ApiKey:
+        """Revoke an API key of the caller (administrators: of anyone)."""
+        if not id:
+            raise ValueError("id is required")
+        key = await self.repo.get_by_id(id)
+        if key.subject_id != caller_id and not self._caller_is_admin(caller_id, admin_id):
+            raise PermissionDeniedError("only administrators can revoke other subjects' api keys")
+        return await self.repo.revoke(id)
+
+    async def resolve(self, token: str, now: Optional[datetime] = None) -> ApiKey:
+        """
+        Look up the active API key for a bearer key.
+
+        Raises:
+            PermissionDeniedError: If the key is unknown, revoked or expired
+        """
+        key = await self.repo.get_by_token_hash(hash_token(token)) if token.startswith(TOKEN_PREFIX) else None
+        if not key:
+            raise PermissionDeniedError("invalid api key")
+        if key.revoked_at is not None:
+            raise PermissionDeniedError("api key has been revoked")
+        if key.expires_at is not None and key.expires_at <= (now or datetime.now(timezone.utc)):
+            raise PermissionDeniedError("api key has expired")
+        return key
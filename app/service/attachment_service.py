@@ -0,0 +1,146 @@
+"""
+Attachment service implementation.
+
+Uploads happen in two steps so that authorization stays in the JSON-RPC
+layer: ``reserve`` creates a pending attachment and returns its ID, and the
+client then streams the content to the HTTP upload endpoint for that ID
+within the upload TTL. Downloads are served from object storage through
+presigned URLs.
+"""
+
+import logging
+import uuid
+from datetime import datetime, timedelta, timezone
+from typing import AsyncIterator, List, Optional, Tuple
+
+from app.repository import (
+    Attachment,
+    AttachmentRepository,
+    NodeRepository,
+    ListResult,
+)
+from app.repository.errors import NotFoundError
+from app.storage import AttachmentSettings, ObjectStore, attachment_settings, get_object_store
+from app.service.limits import TenantLimits
+
+logger = logging.getLogger(__name__)
+
+MAX_FILENAME_LENGTH = 255
+
+
+class AttachmentService:
+    """Attachment business logic service."""
+
+    def __init__(
+        self,
+        repo: AttachmentRepository,
+        node_repo: NodeRepository,
+        key_prefix: str = "",
+        store: Optional[ObjectStore] = None,
+        settings: Optional[AttachmentSettings] = None,
+        limits: Optional[TenantLimits] = None,
+    ):
+        self.repo = repo
+        self.limits = limits or TenantLimits()
+        self.node_repo = node_repo
+        # Namespaces storage keys per tenant (typically the tenant ID)
+        self.key_prefix = key_prefix
+        self._store = store
+        self._settings = settings
+
+    @property
+    def store(self) -> ObjectStore:
+        return self._store or get_object_store()
+
+    @property
+    def settings(self) -> AttachmentSettings:
+        return self._settings or attachment_settings()
+
+    async def reserve(self, node_id: str, filename: str, content_type: str = "") -> Attachment:
+        """Reserve a pending attachment on a node; its content is uploaded separately."""
+        if not node_id:
+            raise ValueError("node_id is required")
+        if not filename:
+            raise ValueError("filename is required")
+        if len(filename) > MAX_FILENAME_LENGTH or "/" in filename or "\\" in filename:
+            raise ValueError(f"filename must be at most {MAX_FILENAME_LENGTH} characters without path separators")
+
+        # Fail early if storage is not configured
+        self.store
+        await self.node_repo.get_by_id(node_id)
+
+        attachment = Attachment(
+            node_id=node_id,
+            filename=filename,
+            content_type=content_type or "application/octet-stream",
+        )
+        attachment.id = str(uuid.uuid4())
+        attachment.storage_key = "/".join(p for p in (self.key_prefix, node_id, attachment.id) if p)
+        return await self.repo.create(attachment)
+
+    async def upload(self, id: str, chunks: AsyncIterator[bytes]) -> Attachment:
+        """
+        Stream content for a pending attachment into object storage.
+
+        Raises:
+            ValueError: If the upload expired, was already completed, or is too large
+        """
+        attachment = await self.get_by_id(id, include_pending=True)
+        if attachment.status != "pending":
+            raise ValueError(f"attachment {id} has already been uploaded")
+        ttl = timedelta(seconds=self.settings.upload_ttl_seconds)
+        if datetime.now(timezone.utc) - _aware(attachment.created_at) > ttl:
+            raise ValueError(f"upload for attachment {id} has expired")
+
+        stored = await self.store.put_stream(
+            attachment.storage_key, chunks, attachment.content_type, self.settings.max_bytes
+        )
+        try:
+            return await self.repo.mark_ready(id, stored.size_bytes, stored.sha256)
+        except Exception:
+            await self._delete_object(attachment.storage_key)
+            raise
+
+    async def get_by_id(self, id: str, include_pending: bool = False) -> Attachment:
+        """Retrieve attachment metadata by ID."""
+        if not id:
+            raise ValueError("id is required")
+        attachment = await self.repo.get_by_id(id)
+        if attachment.status != "ready" and not include_pending:
+            raise NotFoundError(f"attachment not found: {id}")
+        return attachment
+
+    async def download_url(self, id: str) -> Tuple[Attachment, str, datetime]:
+        """Return attachment metadata with a presigned download URL and its expiry time."""
+        attachment = await self.get_by_id(id)
+        expires = self.settings.url_expiry_seconds
+        url = await self.store.presigned_get_url(attachment.storage_key, expires, attachment.filename)
+        return attachment, url, datetime.now(timezone.utc) + timedelta(seconds=expires)
+
+    async def list(self, node_id: str, page_size: int, page_token: str) -> Tuple[List[Attachment], ListResult]:
+        """Retrieve a node's attachments with pagination."""
+        if not node_id:
+            raise ValueError("node_id is required")
+        opts = self.limits.list_options(page_size, page_token)
+        return await self.repo.list_by_node(node_id, opts)
+
+    async def delete(self, id: str) -> None:
+        """Delete an attachment and its stored content."""
+        attachment = await self.get_by_id(id, include_pending=True)
+        await self.repo.delete(id)
+        await self._delete_object(attachment.storage_key)
+
+    async def delete_for_node(self, node_id: str) -> None:
+        """Delete the stored content of every attachment on a node (metadata cascades with the node)."""
+        for key in await self.repo.list_storage_keys(node_id):
+            await self._delete_object(key)
+
+    async def _delete_object(self, key: str) -> None:
+        try:
+            await self.store.delete(key)
+        except Exception as e:
+            logger.warning(f"Failed to delete attachment object {key}: {e}")
+
+
+def _aware(ts: datetime) -> datetime:
+    return ts if ts.tzinfo else ts.replace(tzinfo=timezone.utc)
@@ -0,0 +1,31 @@
+"""
+Audit log service implementation.
+"""
+
+from typing import List, Tuple
+
+from app.repository import AuditEvent, AuditRepository, ListOptions, ListResult
+
+
+class AuditService:
+    """Audit log business logic service."""
+
+    def __init__(self, repo: AuditRepository):
+        self.repo = repo
+
+    async def record(self, event: AuditEvent) -> AuditEvent:
+        """Append an event to the audit log."""
+        if not event.method:
+            raise ValueError("method is required")
+        return await self.repo.record(event)
+
+    async def list(
+        self,
+        page_size: int,
+        page_token: str,
+        tenant_id: str = "",
+        impersonated_only: bool = False,
+    ) -> Tuple[List[AuditEvent], ListResult]:
+        """Retrieve audit events, newest first."""
+        opts = ListOptions(page_size=page_size, page_token=page_token)
+        return await self.repo.list(opts, tenant_id, impersonated_only)
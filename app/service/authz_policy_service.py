@@ -0,0 +1,113 @@
+"""
+AuthzPolicy service implementation.
+"""
+
+from typing import Callable, List, Optional, Tuple
+
+from app.repository import (
+    AuthzPolicy,
+    AuthzPolicyRepository,
+    ListOptions,
+    ListResult,
+)
+from app.scripting import compile_expression
+
+EFFECTS = ("allow", "deny")
+
+
+def validate_policy(name: str, effect: str, expression: str) -> None:
+    """Validate a policy definition and compile its expression."""
+    if not name:
+        raise ValueError("name is required")
+    if effect not in EFFECTS:
+        raise ValueError(f"effect must be one of: {', '.join(EFFECTS)}")
+    compile_expression(expression)
+
+
+class AuthzPolicyService:
+    """AuthzPolicy business logic service."""
+
+    def __init__(self, repo: AuthzPolicyRepository, on_change: Optional[Callable[[], None]] = None):
+        self.repo = repo
+        # Called after every change so the policy engine reloads immediately
+        self.on_change = on_change
+
+    async def create(
+        self,
+        name: str,
+        effect: str,
+        expression: str,
+        description: str = "",
+        methods: Optional[List[str]] = None,
+        enabled: bool = True,
+    ) -> AuthzPolicy:
+        """Create a new authorization policy."""
+        validate_policy(name, effect, expression)
+
+        policy = AuthzPolicy(
+            name=name,
+            description=description,
+            effect=effect,
+            methods=list(methods or []),
+            expression=expression,
+            enabled=enabled,
+        )
+        policy = await self.repo.create(policy)
+        self._changed()
+        return policy
+
+    async def get_by_id(self, id: str) -> AuthzPolicy:
+        """Retrieve a policy by ID."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_by_id(id)
+
+    async def update(
+        self,
+        id: str,
+        name: str = "",
+        effect: str = "",
+        expression: str = "",
+        description: Optional[str] = None,
+        methods: Optional[List[str]] = None,
+        enabled: Optional[bool] = None,
+    ) -> AuthzPolicy:
+        """Update an existing policy."""
+        if not id:
+            raise ValueError("id is required")
+
+        policy = await self.repo.get_by_id(id)
+
+        if name:
+            policy.name = name
+        if effect:
+            policy.effect = effect
+        if expression:
+            policy.expression = expression
+        if description is not None:
+            policy.description = description
+        if methods is not None:
+            policy.methods = list(methods)
+        if enabled is not None:
+            policy.enabled = enabled
+
+        validate_policy(policy.name, policy.effect, policy.expression)
+        policy = await self.repo.update(policy)
+        self._changed()
+        return policy
+
+    async def delete(self, id: str) -> None:
+        """Delete a policy."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete(id)
+        self._changed()
+
+    async def list(self, page_size: int, page_token: str) -> Tuple[List[AuthzPolicy], ListResult]:
+        """Retrieve policies with pagination."""
+        opts = ListOptions(page_size=page_size, page_token=page_token)
+        return await self.repo.list(opts)
+
+    def _changed(self) -> None:
+        if self.on_change:
+            self.on_change()
@@ -0,0 +1,201 @@
+"""
+Batch node creation with conflict policies.
+
+``batch_create_nodes`` creates many nodes in one call, each on its own, so
+one bad or duplicate item does not abort the others. An item can name a key
+that identifies the node it stands for across repeated imports:
+
+- ``id``: the item's client-generated node ID (the external ID)
+- an alias name: the value the item gives that alias (see node_alias_service.py)
+
+When a node with the key already exists, the conflict policy (for the batch,
+or per item) decides what happens:
+
+- ``error``: the item is reported as a conflict and nothing is written
+- ``skip``: the existing node is left as it is
+- ``replace``: the existing node's data is replaced by the item's
+- ``merge``: the item's data is merge-patched into the existing node's
+  (RFC 7386: top-level fields are set, null removes a field)
+
+So importers can sync a source in one idempotent pass: running the same batch
+again replaces or merges into the nodes the first run created.
+"""
+
+import json
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import AlreadyExistsError, Node, NotFoundError
+from app.service.limits import TenantLimits
+from app.service.node_alias_service import NodeAliasService
+from app.service.node_service import NodeService
+
+CONFLICT_POLICIES = ("error", "skip", "replace", "merge")
+# Times an item is matched again when a node with its key appears while it is created
+CREATE_ATTEMPTS = 2
+
+
+@dataclass
+class ItemResult:
+    """Outcome of one batch item."""
+    index: int
+    id: str = ""
+    # "created", "replaced", "merged", "unchanged" (replace with the same data),
+    # "skipped", "conflict" (error policy) or "failed"
+    status: str = "created"
+    etag: str = ""
+    error: str = ""
+
+    def to_dict(self) -> Dict[str, Any]:
+        result = {"index": self.index, "id": self.id, "status": self.status}
+        if self.etag:
+            result["etag"] = self.etag
+        if self.error:
+            result["error"] = self.error
+        return result
+
+
+def _data_object(data: Any) -> Dict[str, Any]:
+    if data is None:
+        return {}
+    if isinstance(data, str):
+        data = json.loads(data or "{}")
+    if not isinstance(data, dict):
+        raise ValueError("data must be a JSON object")
+    return data
+
+
+class BatchCreateService:
+    """Batch node creation business logic service."""
+
+    def __init__(
+        self,
+        node_service: NodeService,
+        alias_service: NodeAliasService,
+        limits: Optional[TenantLimits] = None,
+    ):
+        self.node_service = node_service
+        self.alias_service = alias_service
+        self.limits = limits or TenantLimits()
+
+    async def create_nodes(
+        self, items: List[Dict[str, Any]], on_conflict: str = "error", node_type_id: str = ""
+    ) -> List[ItemResult]:
+        """
+        Create nodes, each on its own; returns one result per item.
+
+        Each item is an object with:
+            node_type_id: Node type (default: the batch's node_type_id)
+            data: Object of the node's data
+            id: Client-generated node ID
+            aliases: Aliases to set, as {"name": "value"}
+            key: "id" or the alias name that identifies an existing node (default: "id" if id is given)
+            on_conflict: Conflict policy for this item (default: the batch's)
+            metadata, valid_from: As for create_node
+
+        Raises:
+            ValueError: If the batch or the conflict policy is invalid
+        """
+        _check_policy(on_conflict, "on_conflict")
+        if not isinstance(items, list) or not items:
+            raise ValueError("items must be a non-empty array")
+        if len(items) > self.limits.max_batch_size:
+            raise ValueError(f"at most {self.limits.max_batch_size} nodes can be created at once (max_batch_size)")
+
+        results = []
+        for index, item in enumerate(items):
+            result = ItemResult(index)
+            try:
+                if not isinstance(item, dict):
+                    raise ValueError("item must be an object")
+                await self._create(item, on_conflict, node_type_id, result)
+            except Exception as e:
+                result.status = "failed"
+                result.error = str(e)
+            results.append(result)
+        return results
+
+    async def _create(self, item: Dict[str, Any], on_conflict: str, node_type_id: str, result: ItemResult) -> None:
+        policy = item.get("on_conflict") or on_conflict
+        _check_policy(policy, "on_conflict")
+        node_type_id = item.get("node_type_id") or node_type_id
+        data = _data_object(item.get("data"))
+        aliases = item.get("aliases") or {}
+        if not isinstance(aliases, dict):
+            raise ValueError("aliases must be an object")
+        result.id = item.get("id", "")
+        key, key_value = _key(item, aliases)
+
+        for attempt in range(CREATE_ATTEMPTS):
+            existing = await self._match(key, key_value, result.id)
+            if existing is None:
+                try:
+                    node = await self.node_service.create(
+                        node_type_id, json.dumps(data), item.get("valid_from", ""), item.get("metadata"), result.id
+                    )
+                except AlreadyExistsError:
+                    # Created meanwhile (e.g. by a retried batch): apply the policy to it
+                    if key == "id" and attempt + 1 < CREATE_ATTEMPTS:
+                        continue
+                    raise
+                result.status = "created"
+                break
+            if node_type_id and existing.node_type_id != node_type_id:
+                raise ValueError(f"node {existing.id} with {key} {key_value!r} is of another node type")
+            node = await self._resolve(existing, data, policy, key, key_value, result)
+            if node is None:
+                return
+            break
+
+        if aliases:
+            await self.alias_service.set(node.id, aliases)
+        result.id, result.etag = node.id, node.etag
+
+    async def _match(self, key: str, key_value: str, id: str) -> Optional[Node]:
+        if not key:
+            return None
+        try:
+            if key == "id":
+                return await self.node_service.get_by_id(id)
+            return await self.alias_service.lookup(key, key_value)
+        except NotFoundError:
+            return None
+
+    async def _resolve(
+        self, existing: Node, data: Dict[str, Any], policy: str, key: str, key_value: str, result: ItemResult
+    ) -> Optional[Node]:
+        result.id, result.etag = existing.id, existing.etag
+        if policy == "error":
+            result.status = "conflict"
+            result.error = f"node with {key} {key_value!r} already exists: {existing.id}"
+            return None
+        if policy == "skip":
+            result.status = "skipped"
+            return None
+        if policy == "replace":
+            if _data_object(existing.data) == data:
+                result.status = "unchanged"
+                return existing
+            result.status = "replaced"
+            return await self.node_service.update(existing.id, json.dumps(data), if_match=existing.etag)
+        result.status = "merged"
+        return await self.node_service.patch(existing.id, data)
+
+
+def _check_policy(policy: str, name: str) -> None:
+    if policy not in CONFLICT_POLICIES:
+        raise ValueError(f"{name} must be one of: {', '.join(CONFLICT_POLICIES)}")
+
+
+def _key(item: Dict[str, Any], aliases: Dict[str, Any]) -> Tuple[str, str]:
+    key = item.get("key") or ("id" if item.get("id") else "")
+    if not key:
+        return "", ""
+    if key == "id":
+        if not item.get("id"):
+            raise ValueError("id is required when key is id")
+        return key, item["id"]
+    value = aliases.get(key)
+    if not isinstance(value, str) or not value:
+        raise ValueError(f"aliases.{key} is required when key is {key}")
+    return key, value
@@ -0,0 +1,169 @@
+"""
+Billing usage events.
+
+Usage is recorded as events in the control database for the billing system
+to consume, instead of it scraping metrics:
+
+- api_calls: successful JSON-RPC calls against a tenant, per method. Calls
+  are counted in memory (see app/jsonrpc/billing.py) and written as one
+  event per tenant and method each flush, so the period of an event is the
+  flush interval it was counted in.
+- export_bytes: size of the JSON results of export methods, per method,
+  counted and flushed the same way.
+- storage_bytes: changes in the size of tenant databases, sampled
+  periodically. The sum of a tenant's storage_bytes events is its size at
+  the last sample, and drops to 0 when the tenant is purged.
+
+Events are read in ID order with list_events, or summed per month with
+monthly_rollup.
+"""
+
+import re
+import uuid
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import BillingEvent, BillingEventRepository, DatabaseStatsRepository, TenantRepository
+from app.service.limits import TenantLimits
+
+_MONTH = re.compile(r"^(\d{4})-(\d{2})$")
+
+
+class UsageRecorder:
+    """Counts usage in memory until the next flush."""
+
+    def __init__(self):
+        self._counts: Dict[Tuple[str, str, str], int] = {}
+        self._since = datetime.now(timezone.utc)
+
+    def record(self, tenant_id: str, event_type: str, dimension: str, quantity: int = 1) -> None:
+        """Add usage of a tenant."""
+        key = (tenant_id, event_type, dimension)
+        self._counts[key] = self._counts.get(key, 0) + quantity
+
+    def drain(self) -> Tuple[Dict[Tuple[str, str, str], int], datetime, datetime]:
+        """Return the usage counted since the last drain and the period it covers, and start counting anew."""
+        counts, since = self._counts, self._since
+        self._counts, self._since = {}, datetime.now(timezone.utc)
+        return counts, since, self._since
+
+    def restore(self, counts: Dict[Tuple[str, str, str], int], since: datetime) -> None:
+        """Put drained usage back (when writing it failed), so it is written with the next flush."""
+        for (tenant_id, event_type, dimension), quantity in counts.items():
+            self.record(tenant_id, event_type, dimension, quantity)
+        self._since = min(self._since, since)
+
+
+def _month_bounds(month: str) -> Tuple[datetime, datetime]:
+    match = _MONTH.match(month)
+    if not match or not 1 <= int(match.group(2)) <= 12:
+        raise ValueError("month must be YYYY-MM")
+    year, number = int(match.group(1)), int(match.group(2))
+    start = datetime(year, number, 1, tzinfo=timezone.utc)
+    end = datetime(year + number // 12, number % 12 + 1, 1, tzinfo=timezone.utc)
+    return start, end
+
+
+class BillingService:
+    """Writes usage events and answers billing queries."""
+
+    def __init__(
+        self,
+        billing_repo: BillingEventRepository,
+        tenant_repo: TenantRepository,
+        control_stats_repo: DatabaseStatsRepository,
+        recorder: Optional[UsageRecorder] = None,
+    ):
+        self.billing_repo = billing_repo
+        self.tenant_repo = tenant_repo
+        self.control_stats_repo = control_stats_repo
+        self.recorder = recorder or UsageRecorder()
+
+    async def flush(self) -> int:
+        """Write the API call and export usage counted since the last flush; returns the number of events."""
+        counts, period_start, period_end = self.recorder.drain()
+        events = [
+            BillingEvent(
+                tenant_id=tenant_id,
+                event_type=event_type,
+                dimension=dimension,
+                quantity=quantity,
+                period_start=period_start,
+                period_end=period_end,
+            )
+            for (tenant_id, event_type, dimension), quantity in counts.items()
+        ]
+        try:
+            await self.billing_repo.record(events)
+        except Exception:
+            self.recorder.restore(counts, period_start)
+            raise
+        return len(events)
+
+    async def record_storage(self) -> int:
+        """Sample tenant database sizes and write their changes; returns the number of events."""
+        started = datetime.now(timezone.utc)
+        databases = await self.tenant_repo.list_databases()
+        sizes = await self.control_stats_repo.list_database_sizes([name for _, _, name in databases])
+        tenant_sizes = {
+            tenant_id: sizes[name]["size_bytes"] or 0
+            for tenant_id, _, name in databases
+            if name in sizes
+        }
+        return await self.billing_repo.record_storage(tenant_sizes, started, datetime.now(timezone.utc))
+
+    async def list_events(
+        self, tenant_id: str, page_size: int, page_token: str
+    ) -> Tuple[List[BillingEvent], str]:
+        """
+        Return a page of events in ID order, optionally of one tenant, and the token of the next page.
+
+        The token of the last page is returned as well: passing it later returns
+        only events written since, so consumers can poll with it.
+        """
+        if tenant_id:
+            _check_tenant_id(tenant_id)
+        after_id = 0
+        if page_token:
+            try:
+                after_id = int(page_token)
+            except ValueError:
+                raise ValueError("invalid page_token")
+        limit = TenantLimits().list_options(page_size, "").effective_page_size()
+        events = await self.billing_repo.list(after_id, tenant_id, limit)
+        return events, str(events[-1].id if events else after_id)
+
+    async def monthly_rollup(self, tenant_id: str, month: str = "") -> Dict[str, Any]:
+        """
+        A tenant's usage in a calendar month (UTC, default: the current month):
+        API calls and export bytes in total and per method, and database size
+        at the start and end of the month.
+        """
+        _check_tenant_id(tenant_id)
+        if not month:
+            month = datetime.now(timezone.utc).strftime("%Y-%m")
+        start, end = _month_bounds(month)
+
+        rollup: Dict[str, Any] = {
+            "tenant_id": tenant_id,
+            "month": month,
+            "period_start": start.isoformat(),
+            "period_end": end.isoformat(),
+        }
+        totals = await self.billing_repo.totals(tenant_id, start, end)
+        for event_type in ("api_calls", "export_bytes"):
+            by_method = {dimension: quantity for t, dimension, quantity in totals if t == event_type}
+            rollup[event_type] = {"total": sum(by_method.values()), "by_method": by_method}
+        start_level = await self.billing_repo.storage_level(tenant_id, start)
+        change = sum(quantity for t, _, quantity in totals if t == "storage_bytes")
+        rollup["storage_bytes"] = {"start": start_level, "end": start_level + change, "change": change}
+        return rollup
+
+
+def _check_tenant_id(tenant_id: str) -> None:
+    if not tenant_id:
+        raise ValueError("tenant_id is required")
+    try:
+        uuid.UUID(tenant_id)
+    except ValueError:
+        raise ValueError("tenant_id must be a UUID")
@@ -0,0 +1,342 @@
+"""
+Bulk node and relationship operations selected by filter.
+
+Bulk operations run server-side as background operations, so mass
+corrections do not have to stream every node to the client and back.
+Each node is written through NodeService, so write hooks, history and
+etags behave exactly as for single-node writes.
+
+Bulk deletes are two-step: a call without a confirmation token only
+previews the match and returns a token, and a second call with that token
+(and the same filter) runs the delete.
+"""
+
+import json
+import re
+import uuid
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from app.repository import (
+    DataRange,
+    NodeFilter,
+    NodeRepository,
+    NotFoundError,
+    Operation,
+    PreconditionFailedError,
+    RelationshipFilter,
+    RelationshipRepository,
+)
+from app.repository.ordering import json_path_expression
+from app.service.limits import TenantLimits
+from app.service.node_service import NodeService
+from app.service.operation_service import OperationProgress, OperationService
+from app.service.patching import apply_merge_patch
+from app.service.timestamps import parse_timestamp
+
+FILTER_KEYS = (
+    "node_type_id", "data", "metadata", "created_after", "created_before", "updated_after", "updated_before",
+    "ranges",
+)
+RELATIONSHIP_FILTER_KEYS = (
+    "relationship_type", "source_node_id", "target_node_id", "data", "created_after", "created_before",
+)
+RANGE_OPERATORS = ("gt", "gte", "lt", "lte")
+# Range bounds that compare as timestamps: ISO 8601 dates, optionally with a time
+DATE_PATTERN = re.compile(r"^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}.*)?$")
+# Attempts per node when a concurrent write changes it mid-update
+UPDATE_ATTEMPTS = 3
+# IDs returned by a delete preview
+PREVIEW_SAMPLE_SIZE = 10
+# Relationships removed per statement by a bulk delete
+DELETE_BATCH_SIZE = 500
+
+
+def parse_node_filter(filter: Optional[Dict[str, Any]]) -> NodeFilter:
+    """
+    Parse a bulk operation filter.
+
+    Keys: node_type_id, data (object the node data must contain), metadata
+    (object the node metadata must contain), created_after, created_before,
+    updated_after and updated_before (ISO 8601), and ranges (see parse_data_ranges).
+    """
+    filter = _check_filter(filter, FILTER_KEYS)
+    metadata = filter.get("metadata")
+    if metadata is not None and not isinstance(metadata, dict):
+        raise ValueError("filter.metadata must be a JSON object")
+    return NodeFilter(
+        node_type_id=filter.get("node_type_id") or "",
+        data_contains=filter.get("data") or None,
+        metadata_contains=metadata or None,
+        created_after=parse_timestamp(filter.get("created_after") or "", "filter.created_after"),
+        created_before=parse_timestamp(filter.get("created_before") or "", "filter.created_before"),
+        updated_after=parse_timestamp(filter.get("updated_after") or "", "filter.updated_after"),
+        updated_before=parse_timestamp(filter.get("updated_before") or "", "filter.updated_before"),
+        data_ranges=parse_data_ranges(filter.get("ranges")) or None,
+    )
+
+
+def parse_data_ranges(ranges: Any) -> List[DataRange]:
+    """
+    Parse range filters on data fields, e.g. {"due": {"gte": "2024-01-01"}, "stats.views": {"gt": 10}}.
+
+    Bounds compare by their type: numbers with numeric fields, ISO 8601
+    dates and timestamps by time with date fields, other strings as text.
+    Fields declared typed in the node type schema (see field_types.py) are
+    stored in a form that compares correctly.
+    """
+    if not ranges:
+        return []
+    if not isinstance(ranges, dict):
+        raise ValueError("filter.ranges must be an object of data fields and bounds")
+    parsed: List[DataRange] = []
+    for path, bounds in ranges.items():
+        json_path_expression("data", path)
+        if not isinstance(bounds, dict) or not bounds:
+            raise ValueError(f"filter.ranges.{path} must be an object of bounds, e.g. {{\"gte\": 1}}")
+        for op, value in bounds.items():
+            if op not in RANGE_OPERATORS:
+                raise ValueError(f"filter.ranges.{path}: unknown bound {op} (allowed: {', '.join(RANGE_OPERATORS)})")
+            parsed.append(_data_range(path, op, value))
+    return parsed
+
+
+def _data_range(path: str, op: str, value: Any) -> DataRange:
+    if isinstance(value, (int, float)) and not isinstance(value, bool):
+        return DataRange(path.split("."), op, "number", str(value))
+    if not isinstance(value, str):
+        raise ValueError(f"filter.ranges.{path}.{op} must be a number or string")
+    if DATE_PATTERN.match(value):
+        return DataRange(path.split("."), op, "timestamp", parse_timestamp(value, f"filter.ranges.{path}.{op}"))
+    return DataRange(path.split("."), op, "text", value)
+
+
+def parse_relationship_filter(filter: Optional[Dict[str, Any]]) -> RelationshipFilter:
+    """
+    Parse a bulk relationship operation filter.
+
+    Keys: relationship_type, source_node_id, target_node_id, data (object
+    the relationship data must contain), created_after and created_before.
+    """
+    filter = _check_filter(filter, RELATIONSHIP_FILTER_KEYS)
+    return RelationshipFilter(
+        relationship_type=filter.get("relationship_type") or "",
+        source_node_id=filter.get("source_node_id") or "",
+        target_node_id=filter.get("target_node_id") or "",
+        data_contains=filter.get("data") or None,
+        created_after=parse_timestamp(filter.get("created_after") or "", "filter.created_after"),
+        created_before=parse_timestamp(filter.get("created_before") or "", "filter.created_before"),
+    )
+
+
+def _check_filter(filter: Optional[Dict[str, Any]], allowed: Tuple[str, ...]) -> Dict[str, Any]:
+    filter = filter or {}
+    unknown = [k for k in filter if k not in allowed]
+    if unknown:
+        raise ValueError(f"unknown filter keys: {', '.join(unknown)} (allowed: {', '.join(allowed)})")
+    data = filter.get("data")
+    if data is not None and not isinstance(data, dict):
+        raise ValueError("filter.data must be a JSON object")
+    return filter
+
+
+
+
+class BulkService:
+    """Bulk node and relationship operation business logic service."""
+
+    def __init__(
+        self,
+        node_repo: NodeRepository,
+        node_service: NodeService,
+        operation_service: OperationService,
+        relationship_repo: Optional[RelationshipRepository] = None,
+        limits: Optional[TenantLimits] = None,
+    ):
+        self.node_repo = node_repo
+        self.node_service = node_service
+        self.operation_service = operation_service
+        self.relationship_repo = relationship_repo
+        self.limits = limits or TenantLimits()
+
+    async def update_by_filter(
+        self,
+        filter: Optional[Dict[str, Any]],
+        patch: Dict[str, Any],
+        max_affected: int,
+        dry_run: bool = False,
+    ) -> Tuple[int, Optional[Operation]]:
+        """
+        Apply a JSON merge patch to the data of every node matching filter.
+
+        Returns the number of matching nodes and, unless dry_run, the
+        background operation applying the patch.
+
+        Raises:
+            ValueError: If more than max_affected nodes match
+        """
+        if not isinstance(patch, dict) or not patch:
+            raise ValueError("patch must be a non-empty JSON object (RFC 7386 merge patch)")
+        node_filter, matched = await self._match(filter, max_affected)
+        if dry_run:
+            return matched, None
+
+        ids = await self.node_repo.list_ids_matching(node_filter, max_affected)
+
+        async def work(progress: OperationProgress) -> None:
+            for node_id in ids:
+                try:
+                    progress.succeeded(await self._patch_node(node_id, patch))
+                except NotFoundError:
+                    # Deleted since the operation started
+                    progress.succeeded(affected=False)
+                except Exception as e:
+                    progress.failed(node_id, e)
+                await progress.checkpoint()
+
+        params = {"filter": filter or {}, "patch": patch, "max_affected": max_affected}
+        op = await self.operation_service.start("update_nodes_by_filter", params, len(ids), work)
+        return matched, op
+
+    async def delete_nodes_by_filter(
+        self,
+        filter: Optional[Dict[str, Any]],
+        max_affected: int,
+        confirmation_token: str = "",
+    ) -> Tuple[int, List[str], Operation]:
+        """
+        Preview or run the deletion of every node matching filter.
+
+        Without confirmation_token, nothing is deleted: returns the match
+        count, a sample of matching IDs and an operation awaiting
+        confirmation whose ID is the token. With the token, runs the
+        delete (including relationship-type cascades) in the background.
+
+        Raises:
+            ValueError: If more than max_affected nodes match, or the token
+                is invalid, expired or was issued for a different filter
+        """
+        node_filter, matched = await self._match(filter, max_affected)
+
+        async def work(ids: List[str], progress: OperationProgress) -> None:
+            for node_id in ids:
+                try:
+                    deleted = await self.node_service.delete(node_id)
+                    progress.succeeded()
+                    # Cascaded deletes count as affected too
+                    progress.op.affected_count += len(deleted) - 1
+                except NotFoundError:
+                    # Deleted since the preview (possibly by a cascade)
+                    progress.succeeded(affected=False)
+                except Exception as e:
+                    progress.failed(node_id, e)
+                await progress.checkpoint()
+
+        return await self._delete_by_filter(
+            "delete_nodes_by_filter", filter, max_affected, confirmation_token, matched,
+            lambda limit: self.node_repo.list_ids_matching(node_filter, limit), work,
+        )
+
+    async def delete_relationships_by_filter(
+        self,
+        filter: Optional[Dict[str, Any]],
+        max_affected: int,
+        confirmation_token: str = "",
+    ) -> Tuple[int, List[str], Operation]:
+        """
+        Preview or run the deletion of every relationship matching filter.
+
+        Works like delete_nodes_by_filter.
+
+        Raises:
+            ValueError: If more than max_affected relationships match, or the
+                token is invalid, expired or was issued for a different filter
+        """
+        if not self.relationship_repo:
+            raise ValueError("relationship bulk operations are not configured")
+        self._check_max_affected(max_affected)
+        rel_filter = parse_relationship_filter(filter)
+        matched = await self.relationship_repo.count_matching(rel_filter)
+        if matched > max_affected:
+            raise ValueError(f"filter matches {matched} relationships, more than max_affected ({max_affected})")
+
+        async def work(ids: List[str], progress: OperationProgress) -> None:
+            for start in range(0, len(ids), DELETE_BATCH_SIZE):
+                batch = ids[start:start + DELETE_BATCH_SIZE]
+                try:
+                    deleted = await self.relationship_repo.delete_many(batch)
+                except Exception as e:
+                    for rel_id in batch:
+                        progress.failed(rel_id, e)
+                else:
+                    # IDs already gone were deleted since the preview
+                    progress.op.processed_count += len(batch)
+                    progress.op.affected_count += deleted
+                await progress.checkpoint(force=True)
+
+        return await self._delete_by_filter(
+            "delete_relationships_by_filter", filter, max_affected, confirmation_token, matched,
+            lambda limit: self.relationship_repo.list_ids_matching(rel_filter, limit), work,
+        )
+
+    async def _delete_by_filter(
+        self,
+        kind: str,
+        filter: Optional[Dict[str, Any]],
+        max_affected: int,
+        confirmation_token: str,
+        matched: int,
+        list_ids: Callable[[int], Awaitable[List[str]]],
+        work: Callable[[List[str], OperationProgress], Awaitable[None]],
+    ) -> Tuple[int, List[str], Operation]:
+        params = {"filter": filter or {}, "max_affected": max_affected}
+        if not confirmation_token:
+            sample = await list_ids(PREVIEW_SAMPLE_SIZE)
+            op = await self.operation_service.prepare(kind, dict(params, matched_count=matched), matched)
+            return matched, sample, op
+
+        try:
+            uuid.UUID(confirmation_token)
+            prepared = await self.operation_service.get_by_id(confirmation_token)
+        except (ValueError, NotFoundError):
+            prepared = None
+        if (
+            not prepared
+            or prepared.kind != kind
+            or prepared.params.get("filter") != params["filter"]
+            or prepared.params.get("max_affected") != max_affected
+        ):
+            raise ValueError("confirmation token does not match this filter; preview again")
+
+        ids = await list_ids(max_affected)
+        op = await self.operation_service.confirm(
+            confirmation_token, kind, len(ids), lambda progress: work(ids, progress)
+        )
+        return matched, ids[:PREVIEW_SAMPLE_SIZE], op
+
+    async def _match(self, filter: Optional[Dict[str, Any]], max_affected: int) -> Tuple[NodeFilter, int]:
+        self._check_max_affected(max_affected)
+        node_filter = parse_node_filter(filter)
+        matched = await self.node_repo.count_matching(node_filter)
+        if matched > max_affected:
+            raise ValueError(f"filter matches {matched} nodes, more than max_affected ({max_affected})")
+        return node_filter, matched
+
+    async def _patch_node(self, node_id: str, patch: Dict[str, Any]) -> bool:
+        """Patch one node; returns False if the patch left its data unchanged."""
+        for attempt in range(UPDATE_ATTEMPTS):
+            node = await self.node_service.get_by_id(node_id)
+            current = json.loads(node.data)
+            patched = apply_merge_patch(current, patch)
+            if patched == current:
+                return False
+            try:
+                await self.node_service.update(node_id, json.dumps(patched), if_match=node.etag)
+                return True
+            except PreconditionFailedError:
+                if attempt == UPDATE_ATTEMPTS - 1:
+                    raise
+        return False
+
+    def _check_max_affected(self, max_affected: int) -> None:
+        if max_affected <= 0 or max_affected > self.limits.max_batch_size:
+            raise ValueError(f"max_affected must be between 1 and {self.limits.max_batch_size}")
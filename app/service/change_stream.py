@@ -0,0 +1,224 @@
+"""
+Real-time change subscriptions for browser clients.
+
+A subscription is reserved with the begin_change_subscription JSON-RPC
+method (which is subject to authorization policies) and then streamed over
+Server-Sent Events or a WebSocket (see app/api/routers/changes.py). The
+stream polls export_tenant_changes on the server, so it delivers exactly
+what a client paging through export_tenant_changes would: written nodes,
+written relationships and tombstones, with the same sync cursors, filter and
+transform.
+
+Every batch carries the sync cursor to resume from. A change may be
+delivered twice (around a reconnect, or when a batch spans pages), never
+skipped, so clients should apply changes as upserts.
+
+Subscriptions live in the server process that reserved them, like read
+sessions; clients behind a load balancer need sticky routing to connect.
+"""
+
+import asyncio
+import secrets
+import time
+from contextlib import asynccontextmanager
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from typing import Any, AsyncIterator, Dict, List, Optional
+
+from app.repository import Node, Relationship, Tombstone
+from app.repository.errors import NotFoundError
+from app.service.bulk_service import parse_node_filter
+from app.service.export_service import ExportService
+from app.service.export_transforms import parse_transform
+
+DEFAULT_POLL_SECONDS = 1.0
+DEFAULT_MAX_STREAMS = 100
+# How long a reserved subscription can be connected (and reconnected) to
+DEFAULT_SUBSCRIPTION_TTL_SECONDS = 3600
+TOKEN_PREFIX = "cs_"
+
+
+class TooManyStreamsError(Exception):
+    """Raised when the server already serves its maximum of change streams."""
+
+
+@dataclass
+class ChangeBatch:
+    """Changes read in one poll (or one page of a poll) of a subscription."""
+    nodes: List[Node]
+    relationships: List[Relationship]
+    tombstones: List[Tombstone]
+    # Cursor from which a reconnecting client receives everything after this batch
+    sync_cursor: str
+
+    @property
+    def empty(self) -> bool:
+        return not (self.nodes or self.relationships or self.tombstones)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary (the shape of an export_tenant_changes result)."""
+        return {
+            "nodes": [n.to_dict() for n in self.nodes],
+            "relationships": [r.to_dict() for r in self.relationships],
+            "tombstones": [t.to_dict() for t in self.tombstones],
+            "sync_cursor": self.sync_cursor,
+        }
+
+
+@dataclass
+class ChangeSubscription:
+    """A reserved change subscription."""
+    token: str
+    tenant_id: str
+    sync_cursor: str
+    filter: Optional[Dict[str, Any]]
+    include_relationships: bool
+    transform: Optional[Dict[str, Any]]
+    expires_at: datetime
+    # Monotonic deadline used for expiry checks
+    deadline: float = field(default=0.0, repr=False)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "token": self.token,
+            "tenant_id": self.tenant_id,
+            "sync_cursor": self.sync_cursor,
+            "expires_at": self.expires_at.isoformat(),
+        }
+
+
+async def watch_changes(
+    export: ExportService,
+    sync_cursor: str,
+    filter: Optional[Dict[str, Any]],
+    include_relationships: bool,
+    transform: Optional[Dict[str, Any]] = None,
+    poll_seconds: float = DEFAULT_POLL_SECONDS,
+    page_size: int = 0,
+) -> AsyncIterator[ChangeBatch]:
+    """
+    Yield the changes since a sync cursor, forever.
+
+    Each poll reads every page of changes; a batch is yielded per page, and
+    one empty batch when nothing changed, so callers can send heartbeats.
+    A batch that is not the last page of its poll carries the poll's
+    starting cursor, as resuming mid-poll must read the poll again.
+
+    Raises:
+        ValueError: If the cursor, filter or transform is invalid
+        PreconditionFailedError: If the cursor has expired
+    """
+    while True:
+        page_token = ""
+        while True:
+            nodes, relationships, tombstones, result, next_cursor = await export.changes(
+                sync_cursor, filter, include_relationships, page_size, page_token, transform
+            )
+            page_token = result.next_page_token
+            yield ChangeBatch(nodes, relationships, tombstones, sync_cursor if page_token else next_cursor)
+            if not page_token:
+                break
+        sync_cursor = next_cursor
+        await asyncio.sleep(poll_seconds)
+
+
+class ChangeStreamManager:
+    """Reserves change subscriptions and bounds the streams serving them."""
+
+    def __init__(
+        self,
+        poll_seconds: float = DEFAULT_POLL_SECONDS,
+        max_streams: int = DEFAULT_MAX_STREAMS,
+        ttl_seconds: int = DEFAULT_SUBSCRIPTION_TTL_SECONDS,
+    ):
+        self.poll_seconds = poll_seconds
+        self.max_streams = max_streams
+        self.ttl_seconds = ttl_seconds
+        self._subscriptions: Dict[str, ChangeSubscription] = {}
+        self._streams = 0
+
+    @property
+    def open_streams(self) -> int:
+        return self._streams
+
+    async def begin(
+        self,
+        tenant_id: str,
+        export: ExportService,
+        sync_cursor: str,
+        filter: Optional[Dict[str, Any]],
+        include_relationships: bool,
+        transform: Optional[Dict[str, Any]] = None,
+    ) -> ChangeSubscription:
+        """
+        Reserve a subscription to the changes after a sync cursor (default: from now).
+
+        Raises:
+            ValueError: If the filter or transform is invalid
+        """
+        parse_node_filter(filter)
+        parse_transform(transform)
+        self.expire()
+        if not sync_cursor:
+            sync_cursor = await export.current_cursor()
+
+        now = datetime.now(timezone.utc)
+        subscription = ChangeSubscription(
+            token=TOKEN_PREFIX + secrets.token_urlsafe(24),
+            tenant_id=tenant_id,
+            sync_cursor=sync_cursor,
+            filter=filter,
+            include_relationships=include_relationships,
+            transform=transform,
+            expires_at=datetime.fromtimestamp(now.timestamp() + self.ttl_seconds, timezone.utc),
+            deadline=time.monotonic() + self.ttl_seconds,
+        )
+        self._subscriptions[subscription.token] = subscription
+        return subscription
+
+    def get(self, token: str, tenant_id: str) -> ChangeSubscription:
+        """
+        Resolve a reserved subscription of a tenant.
+
+        Raises:
+            NotFoundError: If the subscription is unknown, expired or belongs to another tenant
+        """
+        subscription = self._subscriptions.get(token)
+        if not subscription or subscription.tenant_id != tenant_id or time.monotonic() >= subscription.deadline:
+            raise NotFoundError("change subscription not found or expired; begin a new one")
+        return subscription
+
+    def end(self, token: str, tenant_id: str) -> None:
+        """Forget a subscription; open streams of it continue until they disconnect."""
+        self._subscriptions.pop(self.get(token, tenant_id).token, None)
+
+    def expire(self) -> int:
+        """Forget subscriptions past their deadline; returns how many were forgotten."""
+        now = time.monotonic()
+        expired = [t for t, s in self._subscriptions.items() if now >= s.deadline]
+        for token in expired:
+            del self._subscriptions[token]
+        return len(expired)
+
+    def check_capacity(self) -> None:
+        """Raise TooManyStreamsError if max_streams streams are already open."""
+        if self._streams >= self.max_streams:
+            raise TooManyStreamsError(f"too many open change streams (at most {self.max_streams}); retry later")
+
+    @asynccontextmanager
+    async def stream(self, token: str, tenant_id: str) -> AsyncIterator[ChangeSubscription]:
+        """
+        Hold one of the server's stream slots while serving a subscription.
+
+        Raises:
+            NotFoundError: If the subscription is unknown or expired
+            TooManyStreamsError: If max_streams streams are already open
+        """
+        subscription = self.get(token, tenant_id)
+        self.check_capacity()
+        self._streams += 1
+        try:
+            yield subscription
+        finally:
+            self._streams -= 1
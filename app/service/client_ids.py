@@ -0,0 +1,20 @@
+"""
+Client-generated entity IDs.
+
+Clients may choose the UUID of a node or relationship they create, so a
+create can be retried safely (a retry of a create that went through fails
+with AlreadyExistsError instead of creating a duplicate) and offline
+clients can reference entities before syncing them.
+"""
+
+import uuid
+
+
+def parse_client_id(id: str) -> str:
+    """Return a client-supplied ID in canonical form ("" when none was given)."""
+    if not id:
+        return ""
+    try:
+        return str(uuid.UUID(id))
+    except (ValueError, TypeError, AttributeError) as e:
+        raise ValueError(f"id must be a UUID: {id!r}") from e
@@ -0,0 +1,140 @@
+"""
+Community detection.
+
+A community job loads the tenant graph (optionally only some relationship
+types) into memory, assigns every node a community ID and stores it in the
+node's metadata under ``community.<algorithm>``, so nodes of a community can
+be listed with list_nodes(metadata={"community": {"<algorithm>": "<id>"}}).
+Relationship direction is ignored. A community ID is the ID of one of its
+nodes. Algorithms:
+
+- ``components``: connected components; the ID is the component's smallest node ID
+- ``label_propagation``: each node repeatedly takes the most common community
+  of its neighbors (ties to the smallest ID) until nothing changes or after
+  LABEL_PROPAGATION_MAX_ITERATIONS rounds; finds densely connected groups
+  within a component
+
+As with centrality, nodes created after the job have no community until it
+runs again.
+"""
+
+import asyncio
+from collections import Counter
+from typing import List, Optional, Tuple
+
+from app.repository import GraphStatsRepository, NodeFilter, NodeRepository, Operation
+from app.service.limits import TenantLimits
+from app.service.operation_service import OperationProgress, OperationService
+
+COMMUNITY_KIND = "detect_communities"
+ALGORITHMS = ("components", "label_propagation")
+# Metadata key holding each node's community ID by algorithm
+METADATA_KEY = "community"
+
+LABEL_PROPAGATION_MAX_ITERATIONS = 20
+# Community IDs written per statement
+COMMUNITY_BATCH_SIZE = 1000
+MAX_COMMUNITIES = 1000
+
+
+def connected_components(node_ids: List[str], edges: List[Tuple[int, int]]) -> List[str]:
+    """Weakly connected components by union-find; each node gets its component's smallest node ID."""
+    parent = list(range(len(node_ids)))
+
+    def find(i: int) -> int:
+        while parent[i] != i:
+            parent[i] = parent[parent[i]]
+            i = parent[i]
+        return i
+
+    for source, target in edges:
+        a, b = find(source), find(target)
+        if a != b:
+            # Keep the smallest node ID as the root
+            if node_ids[a] < node_ids[b]:
+                parent[b] = a
+            else:
+                parent[a] = b
+    return [node_ids[find(i)] for i in range(len(node_ids))]
+
+
+def label_propagation(node_ids: List[str], edges: List[Tuple[int, int]]) -> List[str]:
+    """Label propagation updating nodes in place, in node order; deterministic for the same graph."""
+    n = len(node_ids)
+    neighbors: List[List[int]] = [[] for _ in range(n)]
+    for source, target in edges:
+        if source != target:
+            neighbors[source].append(target)
+            neighbors[target].append(source)
+
+    labels = list(node_ids)
+    for _ in range(LABEL_PROPAGATION_MAX_ITERATIONS):
+        changed = False
+        for i in range(n):
+            if not neighbors[i]:
+                continue
+            counts = Counter(labels[j] for j in neighbors[i])
+            best = max(counts.values())
+            # Keep the current label when it is among the most common
+            if counts.get(labels[i]) != best:
+                labels[i] = min(label for label, count in counts.items() if count == best)
+                changed = True
+        if not changed:
+            break
+    return labels
+
+
+class CommunityService:
+    """Community detection business logic service."""
+
+    def __init__(
+        self,
+        node_repo: NodeRepository,
+        graph_repo: GraphStatsRepository,
+        operation_service: OperationService,
+        limits: Optional[TenantLimits] = None,
+    ):
+        self.node_repo = node_repo
+        self.graph_repo = graph_repo
+        self.operation_service = operation_service
+        self.limits = limits or TenantLimits()
+
+    async def start(self, algorithm: str, relationship_types: Optional[List[str]] = None) -> Operation:
+        """
+        Assign every node a community in the background and store it in node
+        metadata; returns the running operation.
+
+        Raises:
+            ValueError: If the algorithm is unknown
+        """
+        if algorithm not in ALGORITHMS:
+            raise ValueError(f"algorithm must be one of: {', '.join(ALGORITHMS)}")
+        rel_types = list(relationship_types or [])
+
+        async def work(progress: OperationProgress) -> None:
+            node_ids, edges = await self.graph_repo.load_graph(rel_types)
+            detect = connected_components if algorithm == "components" else label_propagation
+            # CPU-bound; keep the event loop serving requests
+            labels = await asyncio.get_running_loop().run_in_executor(None, detect, node_ids, edges)
+
+            for i in range(0, len(node_ids), COMMUNITY_BATCH_SIZE):
+                batch = list(zip(node_ids[i:i + COMMUNITY_BATCH_SIZE], labels[i:i + COMMUNITY_BATCH_SIZE]))
+                stored = await self.node_repo.set_metadata_values(METADATA_KEY, algorithm, batch)
+                for j in range(len(batch)):
+                    # Nodes deleted since the graph was loaded need no community
+                    progress.succeeded(j < stored)
+                await progress.checkpoint(force=True)
+
+        params = {"algorithm": algorithm, "relationship_types": rel_types}
+        total = await self.node_repo.count_matching(NodeFilter())
+        return await self.operation_service.start(COMMUNITY_KIND, params, total, work)
+
+    async def list_communities(self, algorithm: str, limit: int = 100, min_size: int = 2) -> List[Tuple[str, int]]:
+        """Return (community ID, node count) of the stored communities of an algorithm, largest first."""
+        if algorithm not in ALGORITHMS:
+            raise ValueError(f"algorithm must be one of: {', '.join(ALGORITHMS)}")
+        if not 1 <= limit <= MAX_COMMUNITIES:
+            raise ValueError(f"limit must be between 1 and {MAX_COMMUNITIES}")
+        if min_size < 1:
+            raise ValueError("min_size must be at least 1")
+        return await self.node_repo.count_by_metadata_value(METADATA_KEY, algorithm, limit, min_size)
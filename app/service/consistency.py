@@ -0,0 +1,30 @@
+"""
+Read-after-write consistency tokens.
+
+Successful writes to a tenant return a consistency token (see
+app/jsonrpc/consistency.py): the tenant's first unassigned transaction ID
+once the write has committed, so the write's transaction is before it.
+Reads that can be served from a copy lagging behind the tenant database
+accept the token and only use the copy once it includes every transaction
+before the token, falling back to the database otherwise.
+
+Tokens are opaque to clients. They are only meaningful for the tenant that
+issued them; a token of another tenant can only make a read fall back.
+"""
+
+
+def parse_token(token: str) -> int:
+    """
+    Return the transaction ID a consistency token waits for.
+
+    Raises:
+        ValueError: If the token is invalid
+    """
+    if not token.isdigit():
+        raise ValueError("invalid consistency_token")
+    return int(token)
+
+
+def covers(position: str, token: str) -> bool:
+    """Whether a copy that includes every transaction before position observes the writes behind a token."""
+    return int(position) >= parse_token(token)
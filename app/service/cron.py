@@ -0,0 +1,152 @@
+"""
+Cron expressions for scheduled jobs.
+
+Five fields, minute hour day-of-month month day-of-week, each ``*``, a
+number, a range ``a-b``, a step ``*/n`` or ``a-b/n``, or a comma-separated
+list of those; months and weekdays may also be named (``jan``, ``mon``).
+Sunday is 0 or 7. As in cron, when both day fields are restricted a day
+matching either is included. ``@hourly``, ``@daily``, ``@weekly``,
+``@monthly`` and ``@yearly`` are shorthands.
+
+Times are evaluated in a time zone, so ``0 2 * * *`` runs at 02:00 local
+time whatever the UTC offset; a local time skipped by a daylight saving
+change does not run that day.
+"""
+
+from dataclasses import dataclass
+from datetime import datetime, timedelta, timezone
+from typing import FrozenSet, List, Tuple
+from zoneinfo import ZoneInfo
+
+_MACROS = {
+    "@hourly": "0 * * * *",
+    "@daily": "0 0 * * *",
+    "@midnight": "0 0 * * *",
+    "@weekly": "0 0 * * 0",
+    "@monthly": "0 0 1 * *",
+    "@yearly": "0 0 1 1 *",
+    "@annually": "0 0 1 1 *",
+}
+
+_MONTHS = ["jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"]
+_WEEKDAYS = ["sun", "mon", "tue", "wed", "thu", "fri", "sat"]
+
+# (name, lowest, highest, names starting at lowest)
+_FIELDS: List[Tuple[str, int, int, List[str]]] = [
+    ("minute", 0, 59, []),
+    ("hour", 0, 23, []),
+    ("day of month", 1, 31, []),
+    ("month", 1, 12, _MONTHS),
+    ("day of week", 0, 7, _WEEKDAYS),
+]
+
+# A schedule that matches no time within this many days never matches (e.g. "0 0 30 2 *")
+_SEARCH_DAYS = 366 * 4 + 1
+
+
+def _value(text: str, field: str, low: int, high: int, names: List[str]) -> int:
+    if text.lower() in names:
+        return names.index(text.lower()) + low
+    if not text.isdigit() or not low <= int(text) <= high:
+        raise ValueError(f"cron {field} must be between {low} and {high}: {text!r}")
+    return int(text)
+
+
+def _parse_field(text: str, field: str, low: int, high: int, names: List[str]) -> FrozenSet[int]:
+    values = set()
+    for part in text.split(","):
+        span, _, step_text = part.partition("/")
+        step = 1
+        if step_text:
+            if not step_text.isdigit() or int(step_text) == 0:
+                raise ValueError(f"cron {field} step must be a positive number: {part!r}")
+            step = int(step_text)
+        if span == "*":
+            first, last = low, high
+        elif "-" in span:
+            start, _, end = span.partition("-")
+            first, last = _value(start, field, low, high, names), _value(end, field, low, high, names)
+            if first > last:
+                raise ValueError(f"cron {field} range must not be reversed: {part!r}")
+        else:
+            first = _value(span, field, low, high, names)
+            # "5/15" means from 5 to the end in steps of 15
+            last = high if step_text else first
+        values.update(range(first, last + 1, step))
+    return frozenset(values)
+
+
+@dataclass(frozen=True)
+class CronSchedule:
+    """A parsed cron expression."""
+    expression: str
+    minutes: FrozenSet[int]
+    hours: FrozenSet[int]
+    days: FrozenSet[int]
+    months: FrozenSet[int]
+    # 0 is Sunday
+    weekdays: FrozenSet[int]
+    any_day: bool
+    any_weekday: bool
+
+    def _day_matches(self, day: datetime) -> bool:
+        if day.month not in self.months:
+            return False
+        in_month = day.day in self.days
+        in_week = (day.isoweekday() % 7) in self.weekdays
+        if self.any_day or self.any_weekday:
+            return in_month and in_week
+        return in_month or in_week
+
+    def next_after(self, after: datetime, tz: str = "UTC") -> datetime:
+        """
+        Return the first matching time strictly after a time, in UTC.
+
+        Raises:
+            ValueError: If the expression never matches
+        """
+        zone = ZoneInfo(tz)
+        local = after.astimezone(zone).replace(second=0, microsecond=0, tzinfo=None) + timedelta(minutes=1)
+        day = local.replace(hour=0, minute=0)
+        for _ in range(_SEARCH_DAYS):
+            if self._day_matches(day):
+                for hour in sorted(self.hours):
+                    for minute in sorted(self.minutes):
+                        candidate = day.replace(hour=hour, minute=minute)
+                        if candidate < local:
+                            continue
+                        aware = candidate.replace(tzinfo=zone)
+                        # Skipped by a daylight saving change
+                        if aware.astimezone(timezone.utc).astimezone(zone).replace(tzinfo=None) != candidate:
+                            continue
+                        return aware.astimezone(timezone.utc)
+            day += timedelta(days=1)
+        raise ValueError(f"cron expression never matches: {self.expression}")
+
+
+def parse_cron(expression: str) -> CronSchedule:
+    """
+    Parse a cron expression.
+
+    Raises:
+        ValueError: If the expression is malformed or never matches
+    """
+    if not isinstance(expression, str) or not expression.strip():
+        raise ValueError("cron is required")
+    text = _MACROS.get(expression.strip().lower(), expression)
+    parts = text.split()
+    if len(parts) != len(_FIELDS):
+        raise ValueError("cron must have 5 fields: minute hour day-of-month month day-of-week")
+    fields = [_parse_field(part, *spec) for part, spec in zip(parts, _FIELDS)]
+    schedule = CronSchedule(
+        expression=expression.strip(),
+        minutes=fields[0],
+        hours=fields[1],
+        days=fields[2],
+        months=fields[3],
+        weekdays=frozenset(d % 7 for d in fields[4]),
+        any_day=parts[2] == "*",
+        any_weekday=parts[4] == "*",
+    )
+    schedule.next_after(datetime(2000, 1, 1, tzinfo=timezone.utc))
+    return schedule
@@ -0,0 +1,58 @@
+"""
+Streamed CSV parsing for bulk imports.
+"""
+
+import csv
+from typing import AsyncIterator, List, Tuple
+
+MAX_RECORD_BYTES = 1024 * 1024
+
+
+async def csv_records(chunks: AsyncIterator[bytes], delimiter: str) -> AsyncIterator[Tuple[int, List[str]]]:
+    """
+    Split a CSV byte stream (UTF-8, optionally with a byte order mark) into
+    records numbered by the line they start on; blank lines are skipped.
+    Quoted values may span lines.
+    """
+    buffer = b""
+    record = ""
+    start = 0
+    line_number = 0
+    first = True
+    async for chunk in chunks:
+        buffer += chunk
+        *lines, buffer = buffer.split(b"\n")
+        for raw in lines:
+            line_number += 1
+            if first:
+                raw = raw.removeprefix(b"\xef\xbb\xbf")
+                first = False
+            try:
+                line = raw.decode("utf-8")
+            except UnicodeDecodeError:
+                raise ValueError(f"line {line_number} is not valid UTF-8") from None
+            if not record:
+                start = line_number
+            record += line + "\n"
+            if len(record) > MAX_RECORD_BYTES:
+                raise ValueError(f"record at line {start} exceeds {MAX_RECORD_BYTES} bytes")
+            # Quotes are escaped by doubling, so a record ends on a line leaving an even count
+            if record.count('"') % 2 == 0:
+                if record.strip():
+                    yield start, next(csv.reader([record.rstrip("\r\n")], delimiter=delimiter))
+                record = ""
+        if len(buffer) > MAX_RECORD_BYTES:
+            raise ValueError(f"line {line_number + 1} exceeds {MAX_RECORD_BYTES} bytes")
+    if buffer.strip() or record:
+        if not record:
+            start = line_number + 1
+        if first:
+            buffer = buffer.removeprefix(b"\xef\xbb\xbf")
+        try:
+            record += buffer.decode("utf-8")
+        except UnicodeDecodeError:
+            raise ValueError(f"line {line_number + 1} is not valid UTF-8") from None
+        if record.count('"') % 2:
+            raise ValueError(f"unterminated quoted value in record at line {start}")
+        if record.strip():
+            yield start, next(csv.reader([record.rstrip("\r\n")], delimiter=delimiter))
@@ -0,0 +1,276 @@
+"""
+Node data migrations (schema evolution).
+
+Each node type has a schema version, and each node records the version its
+data is shaped for. Registering a data migration transforms a node type's
+data from its current schema version to the next one and bumps the
+version. Migrations are applied:
+
+- lazily: nodes read at an older version are migrated in memory before
+  they are returned, and stored migrated the next time they are written;
+- eagerly: a backfill operation migrates the stored nodes in the background.
+
+A migration has JSON path remapping rules, a CEL expression, or both (the
+rules run first). Rule paths are JSON pointers; a missing source is
+skipped and missing intermediate objects are created:
+
+    {"op": "rename", "from": "/fullName", "to": "/name/full"}
+    {"op": "copy", "from": "/email", "to": "/contact/email"}
+    {"op": "set", "path": "/status", "value": "active"}
+    {"op": "default", "path": "/tags", "value": []}
+    {"op": "remove", "path": "/legacy"}
+
+The expression sees the data as ``data`` and must return the new data object.
+"""
+
+import copy
+import json
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import (
+    DataMigration,
+    DataMigrationRepository,
+    Node,
+    NodeRepository,
+    NodeType,
+    NodeTypeRepository,
+    Operation,
+)
+from app.scripting import ExpressionError, compile_expression, evaluate
+from app.service.field_types import field_types
+from app.service.operation_service import OperationProgress, OperationService
+from app.service.patching import parse_pointer
+
+RULE_OPS = ("rename", "copy", "set", "default", "remove")
+MAX_RULES = 100
+# Nodes migrated per batch by a backfill
+BACKFILL_BATCH_SIZE = 500
+
+
+def check_rules(rules: Any) -> List[Dict[str, Any]]:
+    """
+    Validate data migration rules.
+
+    Raises:
+        ValueError: If rules is not a list of well-formed rules
+    """
+    if rules is None:
+        return []
+    if not isinstance(rules, list):
+        raise ValueError("rules must be an array")
+    if len(rules) > MAX_RULES:
+        raise ValueError(f"a migration can have at most {MAX_RULES} rules")
+    for i, rule in enumerate(rules):
+        if not isinstance(rule, dict) or rule.get("op") not in RULE_OPS:
+            raise ValueError(f"rules[{i}]: op must be one of {', '.join(RULE_OPS)}")
+        keys = ("from", "to") if rule["op"] in ("rename", "copy") else ("path",)
+        for key in keys:
+            if not parse_pointer(rule.get(key, "")):
+                raise ValueError(f"rules[{i}]: {key} must be a non-empty JSON pointer")
+        if rule["op"] in ("set", "default") and "value" not in rule:
+            raise ValueError(f"rules[{i}]: {rule['op']} requires value")
+    return rules
+
+
+def apply_rules(data: Dict[str, Any], rules: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """Apply JSON path remapping rules to node data and return the result (data is not modified)."""
+    result = copy.deepcopy(data)
+    for rule in rules:
+        op = rule["op"]
+        if op in ("rename", "copy"):
+            found, value = _lookup(result, parse_pointer(rule["from"]))
+            if not found:
+                continue
+            if op == "rename":
+                _delete(result, parse_pointer(rule["from"]))
+            _store(result, parse_pointer(rule["to"]), copy.deepcopy(value))
+        elif op == "remove":
+            _delete(result, parse_pointer(rule["path"]))
+        else:
+            path = parse_pointer(rule["path"])
+            if op == "set" or not _lookup(result, path)[0]:
+                _store(result, path, copy.deepcopy(rule["value"]))
+    return result
+
+
+def _lookup(doc: Any, path: List[str]) -> Tuple[bool, Any]:
+    for token in path:
+        if not isinstance(doc, dict) or token not in doc:
+            return False, None
+        doc = doc[token]
+    return True, doc
+
+
+def _store(doc: Dict[str, Any], path: List[str], value: Any) -> None:
+    for token in path[:-1]:
+        if not isinstance(doc.get(token), dict):
+            doc[token] = {}
+        doc = doc[token]
+    doc[path[-1]] = value
+
+
+def _delete(doc: Dict[str, Any], path: List[str]) -> None:
+    found, parent = _lookup(doc, path[:-1])
+    if found and isinstance(parent, dict):
+        parent.pop(path[-1], None)
+
+
+class DataMigrationService:
+    """Data migration business logic service."""
+
+    def __init__(
+        self,
+        repo: DataMigrationRepository,
+        node_type_repo: NodeTypeRepository,
+        node_repo: NodeRepository,
+        operation_service: Optional[OperationService] = None,
+    ):
+        self.repo = repo
+        self.node_type_repo = node_type_repo
+        self.node_repo = node_repo
+        self.operation_service = operation_service
+        # Migrations per node type, loaded once per service instance (i.e. per request)
+        self._migrations: Dict[str, List[DataMigration]] = {}
+
+    async def create(
+        self,
+        node_type_id: str,
+        name: str = "",
+        rules: Optional[List[Dict[str, Any]]] = None,
+        expression: str = "",
+        schema: str = "",
+    ) -> Tuple[DataMigration, NodeType]:
+        """
+        Register a migration from the node type's current schema version to
+        the next; returns it and the updated node type.
+
+        schema, if given, replaces the node type's schema.
+
+        Raises:
+            ValueError: If the rules, expression or schema are invalid
+            PreconditionFailedError: If another migration was registered concurrently
+        """
+        if not node_type_id:
+            raise ValueError("node_type_id is required")
+        rules = check_rules(rules)
+        if not rules and not expression:
+            raise ValueError("rules or expression is required")
+        if expression:
+            compile_expression(expression)
+        if schema:
+            try:
+                json.loads(schema)
+            except json.JSONDecodeError as e:
+                raise ValueError(f"schema must be valid JSON: {e}") from e
+            field_types(schema)
+
+        node_type = await self.node_type_repo.get_by_id(node_type_id)
+        migration = DataMigration(
+            node_type_id=node_type_id,
+            from_version=node_type.schema_version,
+            name=name,
+            rules=rules,
+            expression=expression,
+            schema=schema,
+        )
+        migration, _ = await self.repo.create(migration)
+        self._migrations.pop(node_type_id, None)
+        return migration, await self.node_type_repo.get_by_id(node_type_id)
+
+    async def list(self, node_type_id: str) -> List[DataMigration]:
+        """Retrieve a node type's migrations, oldest version first."""
+        if not node_type_id:
+            raise ValueError("node_type_id is required")
+        await self.node_type_repo.get_by_id(node_type_id)
+        return await self.repo.list_for_node_type(node_type_id)
+
+    async def migrate(self, nodes: List[Node]) -> List[Node]:
+        """
+        Migrate the data of nodes read at an older schema version in memory,
+        up to the version their node type had when they were read.
+
+        Nodes read without data are left alone. Returns nodes.
+
+        Raises:
+            ValueError: If a migration fails for a node
+        """
+        for node in nodes:
+            if node.schema_version >= node.latest_schema_version or node.data is None:
+                continue
+            await self._migrate_node(node, node.latest_schema_version)
+        return nodes
+
+    async def backfill(self, node_type_id: str) -> Operation:
+        """
+        Migrate every stored node of a type to its current schema version in
+        the background; returns the running operation.
+
+        Nodes written while the backfill runs are skipped, as writes already
+        store migrated data.
+        """
+        if not self.operation_service:
+            raise ValueError("backfill is not available")
+        if not node_type_id:
+            raise ValueError("node_type_id is required")
+        node_type = await self.node_type_repo.get_by_id(node_type_id)
+        version = node_type.schema_version
+        total = await self.node_repo.count_below_schema_version(node_type_id, version)
+
+        async def work(progress: OperationProgress) -> None:
+            # Nodes that failed or changed concurrently are not retried
+            skipped = set()
+            while True:
+                batch = [
+                    node for node in await self.node_repo.list_below_schema_version(
+                        node_type_id, version, BACKFILL_BATCH_SIZE + len(skipped)
+                    )
+                    if node.id not in skipped
+                ]
+                if not batch:
+                    return
+                for node in batch:
+                    from_version = node.schema_version
+                    try:
+                        await self._migrate_node(node, version)
+                        stored = await self.node_repo.store_migrated_data(node, from_version)
+                        if not stored:
+                            skipped.add(node.id)
+                        progress.succeeded(stored)
+                    except Exception as e:
+                        skipped.add(node.id)
+                        progress.failed(node.id, e)
+                    await progress.checkpoint()
+
+        params = {"node_type_id": node_type_id, "schema_version": version}
+        return await self.operation_service.start("data_migration_backfill", params, total, work)
+
+    async def _migrate_node(self, node: Node, to_version: int) -> None:
+        migrations = self._migrations.get(node.node_type_id)
+        if migrations is None or (migrations and migrations[-1].to_version < to_version):
+            migrations = await self.repo.list_for_node_type(node.node_type_id)
+            self._migrations[node.node_type_id] = migrations
+
+        data = json.loads(node.data) if node.data else {}
+        for migration in migrations:
+            if node.schema_version <= migration.from_version < to_version:
+                data = await _apply(migration, data, node.id)
+        node.data = json.dumps(data)
+        node.schema_version = to_version
+
+
+async def _apply(migration: DataMigration, data: Dict[str, Any], node_id: str) -> Dict[str, Any]:
+    data = apply_rules(data, migration.rules)
+    if not migration.expression:
+        return data
+    try:
+        result = await evaluate(compile_expression(migration.expression), {"data": data})
+    except ExpressionError as e:
+        raise ValueError(
+            f"data migration to schema version {migration.to_version} failed for node {node_id}: {e}"
+        ) from e
+    if not isinstance(result, dict):
+        raise ValueError(
+            f"data migration to schema version {migration.to_version} must return an object, "
+            f"got {type(result).__name__}"
+        )
+    return result
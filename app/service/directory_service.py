@@ -0,0 +1,197 @@
+"""
+Tenant user directory (SCIM-style users and groups).
+
+The directory records the humans acting within a tenant. Callers identify
+themselves with the ``X-User-ID`` header; when it matches a directory
+user's ``user_name`` (or ``external_id``), the authorization layer:
+
+- denies every call of a disabled (inactive) user,
+- exposes the user and their group names to policies as
+  ``subject.principal`` and ``subject.groups``,
+- records the user name as ``recorded_by`` on the node versions the call writes.
+"""
+
+from typing import List, Optional, Tuple
+
+from app.repository import DirectoryGroup, DirectoryRepository, DirectoryUser, ListResult
+from app.service.limits import TenantLimits
+
+MAX_NAME_LENGTH = 256
+
+
+def _check_name(value: str, field: str) -> None:
+    if not value:
+        raise ValueError(f"{field} is required")
+    if len(value) > MAX_NAME_LENGTH or value != value.strip():
+        raise ValueError(f"{field} must be at most {MAX_NAME_LENGTH} characters without surrounding whitespace")
+
+
+def _check_email(email: str) -> None:
+    if email and ("@" not in email or len(email) > MAX_NAME_LENGTH):
+        raise ValueError("email must be an email address")
+
+
+class DirectoryService:
+    """Tenant directory business logic service."""
+
+    def __init__(self, repo: DirectoryRepository, limits: Optional[TenantLimits] = None):
+        self.repo = repo
+        self.limits = limits or TenantLimits()
+
+    async def create_user(
+        self,
+        user_name: str,
+        display_name: str = "",
+        email: str = "",
+        external_id: str = "",
+        active: bool = True,
+    ) -> DirectoryUser:
+        """Add a user to the directory."""
+        _check_name(user_name, "user_name")
+        _check_email(email)
+        await self._check_user_unique(user_name, external_id)
+
+        user = DirectoryUser(
+            user_name=user_name,
+            external_id=external_id,
+            display_name=display_name,
+            email=email,
+            active=active,
+        )
+        return await self.repo.create_user(user)
+
+    async def get_user(self, id: str) -> DirectoryUser:
+        """Retrieve a directory user, with their group names."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_user(id)
+
+    async def update_user(
+        self,
+        id: str,
+        user_name: str = "",
+        display_name: Optional[str] = None,
+        email: Optional[str] = None,
+        external_id: Optional[str] = None,
+        active: Optional[bool] = None,
+    ) -> DirectoryUser:
+        """Update a directory user; omitted fields are unchanged."""
+        if not id:
+            raise ValueError("id is required")
+
+        user = await self.repo.get_user(id)
+        if user_name and user_name != user.user_name:
+            _check_name(user_name, "user_name")
+            await self._check_user_unique(user_name, "")
+            user.user_name = user_name
+        if external_id is not None and external_id != user.external_id:
+            await self._check_user_unique("", external_id)
+            user.external_id = external_id
+        if display_name is not None:
+            user.display_name = display_name
+        if email is not None:
+            _check_email(email)
+            user.email = email
+        if active is not None:
+            user.active = active
+
+        return await self.repo.update_user(user)
+
+    async def disable_user(self, id: str) -> DirectoryUser:
+        """Deactivate a user; their calls are denied until they are re-activated with update_user."""
+        return await self.update_user(id, active=False)
+
+    async def delete_user(self, id: str) -> None:
+        """Remove a user (and their group memberships) from the directory."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete_user(id)
+
+    async def list_users(
+        self,
+        page_size: int,
+        page_token: str,
+        active: Optional[bool] = None,
+        user_name_prefix: str = "",
+        group_id: str = "",
+    ) -> Tuple[List[DirectoryUser], ListResult]:
+        """Retrieve directory users by user name, optionally only active/inactive ones or a group's members."""
+        opts = self.limits.list_options(page_size, page_token)
+        return await self.repo.list_users(opts, active, user_name_prefix, group_id)
+
+    async def resolve(self, subject_id: str) -> Optional[DirectoryUser]:
+        """Return the directory user a caller's X-User-ID names (by user_name or external_id), if any."""
+        if not subject_id:
+            return None
+        return await self.repo.find_user(user_name=subject_id, external_id=subject_id)
+
+    async def create_group(self, display_name: str, external_id: str = "") -> DirectoryGroup:
+        """Create a group."""
+        _check_name(display_name, "display_name")
+        await self._check_group_unique(display_name, external_id)
+        return await self.repo.create_group(DirectoryGroup(display_name=display_name, external_id=external_id))
+
+    async def get_group(self, id: str) -> DirectoryGroup:
+        """Retrieve a group."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_group(id)
+
+    async def update_group(self, id: str, display_name: str = "", external_id: Optional[str] = None) -> DirectoryGroup:
+        """Rename a group or change its external ID."""
+        if not id:
+            raise ValueError("id is required")
+
+        group = await self.repo.get_group(id)
+        if display_name and display_name != group.display_name:
+            _check_name(display_name, "display_name")
+            await self._check_group_unique(display_name, "")
+            group.display_name = display_name
+        if external_id is not None and external_id != group.external_id:
+            await self._check_group_unique("", external_id)
+            group.external_id = external_id
+
+        return await self.repo.update_group(group)
+
+    async def delete_group(self, id: str) -> None:
+        """Delete a group; its members stay in the directory."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete_group(id)
+
+    async def list_groups(self, page_size: int, page_token: str) -> Tuple[List[DirectoryGroup], ListResult]:
+        """Retrieve groups by display name."""
+        opts = self.limits.list_options(page_size, page_token)
+        return await self.repo.list_groups(opts)
+
+    async def add_member(self, group_id: str, user_id: str) -> DirectoryGroup:
+        """Add a user to a group; returns the group."""
+        if not group_id:
+            raise ValueError("group_id is required")
+        if not user_id:
+            raise ValueError("user_id is required")
+        await self.repo.add_member(group_id, user_id)
+        return await self.repo.get_group(group_id)
+
+    async def remove_member(self, group_id: str, user_id: str) -> DirectoryGroup:
+        """Remove a user from a group; returns the group."""
+        if not group_id:
+            raise ValueError("group_id is required")
+        if not user_id:
+            raise ValueError("user_id is required")
+        await self.repo.remove_member(group_id, user_id)
+        return await self.repo.get_group(group_id)
+
+    async def _check_user_unique(self, user_name: str, external_id: str) -> None:
+        existing = await self.repo.find_user(user_name=user_name, external_id=external_id)
+        if existing:
+            if user_name and existing.user_name == user_name:
+                raise ValueError(f"directory_user already exists: {user_name}")
+            raise ValueError(f"external_id is already used by directory_user {existing.user_name}")
+
+    async def _check_group_unique(self, display_name: str, external_id: str) -> None:
+        existing = await self.repo.find_group(display_name=display_name, external_id=external_id)
+        if existing:
+            if display_name and existing.display_name == display_name:
+                raise ValueError(f"directory_group already exists: {display_name}")
+            raise ValueError(f"external_id is already used by directory_group {existing.display_name}")
@@ -0,0 +1,296 @@
+"""
+Dual-write mode for zero-downtime backend migrations.
+
+To move a tenant to a new storage backend, such as a database with a
+partitioned table layout, an admin starts dual-write mode with a second
+database of the cluster. From then on, the tenant's node type, node,
+relationship type and relationship writes are mirrored to that database
+(see app/repository/dual_write.py). Reads still come from the tenant's own
+database, the primary. verify() compares both a page at a time. With
+repair, it copies the primary's current state of the differing entities to
+the secondary, which is also how data written before dual writes started is
+backfilled.
+
+Server instances pick up the start and stop of dual-write mode within
+DUAL_WRITE_CACHE_SECONDS, so writes made meanwhile are only caught up by a
+verification with repair.
+"""
+
+import asyncio
+import json
+import logging
+import re
+import time
+from dataclasses import dataclass
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from app.db.database import Database
+from app.db.tenant_db_manager import TenantDatabaseManager
+from app.metrics import metrics
+from app.repository import AlreadyExistsError, DualWriteTarget, DualWriteTargetRepository, ListResult, NotFoundError
+from app.repository.dual_write import sync
+from app.service.comparison_service import compare_page, decode_page_token, encode_page_token
+from app.service.template_service import list_all
+
+logger = logging.getLogger(__name__)
+
+DUAL_WRITE_CACHE_SECONDS = 30.0
+
+_DATABASE_NAME = re.compile(r"^[a-z_][a-z0-9_]{0,62}$")
+_NODE_TYPE_FIELDS = ("name", "description", "schema")
+_RELATIONSHIP_TYPE_FIELDS = (
+    "name", "description", "directionality", "allowed_source_node_type_ids", "allowed_target_node_type_ids",
+    "on_source_delete", "on_target_delete", "on_duplicate", "derivation",
+)
+
+
+@dataclass
+class _Secondary:
+    database_name: str
+    database: Optional[Database]
+    checked_at: float
+
+
+def _empty_diff() -> Dict[str, List[Dict[str, Any]]]:
+    return {"missing": [], "extra": [], "changed": []}
+
+
+def _type_value(entity: Any, name: str) -> Any:
+    value = getattr(entity, name)
+    if name == "schema":
+        # Compare schemas, not their serialization
+        try:
+            return json.loads(value) if value else None
+        except ValueError:
+            return value
+    return value
+
+
+def compare_types(
+    primary: List[Any], secondary: List[Any], fields: Tuple[str, ...]
+) -> Tuple[Dict[str, List[Dict[str, Any]]], int]:
+    """Compare the node types or relationship types of both backends by ID; returns the differences and IDs compared."""
+    base = {t.id: t for t in primary}
+    other = {t.id: t for t in secondary}
+    ids = sorted(set(base) | set(other))
+    diff = _empty_diff()
+    for id in ids:
+        if id not in other:
+            diff["missing"].append(base[id].to_dict())
+        elif id not in base:
+            diff["extra"].append(other[id].to_dict())
+        else:
+            changed = [f for f in fields if _type_value(base[id], f) != _type_value(other[id], f)]
+            if changed:
+                diff["changed"].append({
+                    "id": id, "fields": changed, "primary": base[id].to_dict(), "secondary": other[id].to_dict(),
+                })
+    return diff, len(ids)
+
+
+def _repositories(services: Dict[str, Any]) -> Dict[str, Any]:
+    return {
+        "node_types": services["node_type"].repo,
+        "relationship_types": services["relationship_type"].repo,
+        "nodes": services["export"].node_repo,
+        "relationships": services["export"].relationship_repo,
+    }
+
+
+class DualWriteService:
+    """Mirrors tenants' writes to a second database and verifies it."""
+
+    def __init__(
+        self,
+        repo: DualWriteTargetRepository,
+        db_manager: TenantDatabaseManager,
+        tenant_services: Callable[[str], Awaitable[Dict[str, Any]]],
+        database_services: Callable[[str, Database], Awaitable[Dict[str, Any]]],
+        ttl_seconds: float = DUAL_WRITE_CACHE_SECONDS,
+    ):
+        self.repo = repo
+        self.db_manager = db_manager
+        self.tenant_services = tenant_services
+        # Builds a tenant's services on another database
+        self.database_services = database_services
+        self.ttl_seconds = ttl_seconds
+        self._secondaries: Dict[str, _Secondary] = {}
+        self._locks: Dict[str, asyncio.Lock] = {}
+
+    async def start(self, tenant_id: str, database_name: str) -> DualWriteTarget:
+        """
+        Start mirroring a tenant's writes to a database of the cluster, which
+        is created on the tenant's region server and migrated if needed.
+
+        Raises:
+            ValueError: If the database name is invalid or belongs to a tenant
+            AlreadyExistsError: If the tenant is in dual-write mode already
+            NotFoundError: If the tenant does not exist
+        """
+        if not _DATABASE_NAME.match(database_name or ""):
+            raise ValueError("database_name must be a PostgreSQL name of lowercase letters, digits and _")
+        if await self.repo.get(tenant_id):
+            raise AlreadyExistsError(f"tenant is in dual-write mode already: {tenant_id}")
+
+        database = await self.db_manager.open_secondary_database(tenant_id, database_name)
+        try:
+            target = await self.repo.create(DualWriteTarget(tenant_id=tenant_id, database_name=database_name))
+        except Exception:
+            await database.close()
+            raise
+        async with self._lock(tenant_id):
+            await self._replace(tenant_id, _Secondary(database_name, database, time.monotonic()))
+        logger.info(f"Started dual writes of tenant {tenant_id} to database {database_name}")
+        return target
+
+    async def get(self, tenant_id: str) -> DualWriteTarget:
+        """
+        Raises:
+            NotFoundError: If the tenant is not in dual-write mode
+        """
+        target = await self.repo.get(tenant_id)
+        if not target:
+            raise NotFoundError(f"tenant is not in dual-write mode: {tenant_id}")
+        return target
+
+    async def stop(self, tenant_id: str) -> None:
+        """
+        Stop mirroring a tenant's writes. The second database is kept.
+
+        Raises:
+            NotFoundError: If the tenant is not in dual-write mode
+        """
+        if not await self.repo.delete(tenant_id):
+            raise NotFoundError(f"tenant is not in dual-write mode: {tenant_id}")
+        async with self._lock(tenant_id):
+            await self._replace(tenant_id, _Secondary("", None, time.monotonic()))
+        logger.info(f"Stopped dual writes of tenant {tenant_id}")
+
+    async def secondary_database(self, tenant_id: str) -> Optional[Database]:
+        """
+        The database a tenant's writes are mirrored to, or None when it is not
+        in dual-write mode. A database that cannot be opened is logged and
+        counted, not raised, so it does not fail the tenant's writes.
+        """
+        cached = self._secondaries.get(tenant_id)
+        if cached and time.monotonic() - cached.checked_at < self.ttl_seconds:
+            return cached.database
+        try:
+            return await self._load(tenant_id)
+        except Exception as e:
+            metrics.inc("dual_write_failures_total", labels={"entity": "database"})
+            logger.error(f"Opening the dual-write database of tenant {tenant_id} failed, writes are not mirrored: {e}")
+            if cached:
+                cached.checked_at = time.monotonic()
+                return cached.database
+            self._secondaries[tenant_id] = _Secondary("", None, time.monotonic())
+            return None
+
+    async def verify(
+        self,
+        tenant_id: str,
+        repair: bool,
+        include_relationships: bool,
+        page_size: int,
+        page_token: str,
+    ) -> Tuple[Dict[str, Dict[str, List[Dict[str, Any]]]], int, int, List[Dict[str, str]], ListResult]:
+        """
+        Compare a page of a tenant's primary and dual-write databases: node
+        types and relationship types on the first page, then nodes and
+        relationships in ID order. Entities are missing (only in the primary),
+        extra (only in the secondary) or changed; timestamps are not compared.
+        With repair, differing entities are brought to the primary's current state.
+
+        Returns the differences per entity kind, the number of entities compared,
+        the number repaired, the entities that failed to repair and the pagination.
+
+        Raises:
+            NotFoundError: If the tenant is not in dual-write mode
+            ValueError: If the page token is invalid
+        """
+        target = await self.get(tenant_id)
+        entity, after = decode_page_token(page_token) if page_token else ("nodes", "")
+        if entity == "relationships" and not include_relationships:
+            raise ValueError("invalid page_token")
+        database = await self._load(tenant_id)
+        if database is None:
+            raise RuntimeError(f"the dual-write database {target.database_name} is not open; see the server log")
+
+        primary = await self.tenant_services(tenant_id)
+        secondary = await self.database_services(tenant_id, database)
+        primary_repos, secondary_repos = _repositories(primary), _repositories(secondary)
+        limit = primary["export"].limits.list_options(page_size, page_token).effective_page_size()
+
+        differences = {kind: _empty_diff() for kind in ("node_types", "relationship_types", "nodes", "relationships")}
+        compared = 0
+        if not page_token:
+            differences["node_types"], count = compare_types(
+                await list_all(primary["node_type"]), await list_all(secondary["node_type"]), _NODE_TYPE_FIELDS
+            )
+            compared += count
+            differences["relationship_types"], count = compare_types(
+                await primary_repos["relationship_types"].list_all(),
+                await secondary_repos["relationship_types"].list_all(),
+                _RELATIONSHIP_TYPE_FIELDS,
+            )
+            compared += count
+        diff, count, bound = await compare_page(primary["export"], secondary["export"], entity, after, limit)
+        differences[entity] = {
+            "missing": diff["removed"],
+            "extra": diff["added"],
+            "changed": [
+                {"id": c["id"], "fields": c["fields"], "primary": c["before"], "secondary": c["after"]}
+                for c in diff["changed"]
+            ],
+        }
+        compared += count
+
+        repaired = 0
+        failed: List[Dict[str, str]] = []
+        if repair:
+            for kind, diff in differences.items():
+                ids = [e["id"] for entries in diff.values() for e in entries]
+                if not ids:
+                    continue
+                errors = await sync(primary_repos[kind], secondary_repos[kind], kind, ids)
+                repaired += len(ids) - len(errors)
+                failed += [{"entity": kind, "id": id, "error": error} for id, error in errors]
+
+        result = ListResult()
+        if bound is not None:
+            result.next_page_token = encode_page_token(entity, bound)
+        elif entity == "nodes" and include_relationships:
+            result.next_page_token = encode_page_token("relationships", "")
+        return differences, compared, repaired, failed, result
+
+    async def close_all(self) -> None:
+        """Close the dual-write databases' pools."""
+        for secondary in self._secondaries.values():
+            if secondary.database:
+                await secondary.database.close()
+        self._secondaries.clear()
+
+    def _lock(self, tenant_id: str) -> asyncio.Lock:
+        return self._locks.setdefault(tenant_id, asyncio.Lock())
+
+    async def _load(self, tenant_id: str) -> Optional[Database]:
+        """Look up a tenant's dual-write database, opening or closing its pool as it changed."""
+        async with self._lock(tenant_id):
+            cached = self._secondaries.get(tenant_id)
+            if cached and time.monotonic() - cached.checked_at < self.ttl_seconds:
+                # Looked up by another request meanwhile
+                return cached.database
+            target = await self.repo.get(tenant_id)
+            name = target.database_name if target else ""
+            if cached and cached.database_name == name and (cached.database or not name):
+                cached.checked_at = time.monotonic()
+                return cached.database
+            database = await self.db_manager.open_secondary_database(tenant_id, name) if name else None
+            await self._replace(tenant_id, _Secondary(name, database, time.monotonic()))
+            return database
+
+    async def _replace(self, tenant_id: str, secondary: _Secondary) -> None:
+        previous = self._secondaries.get(tenant_id)
+        self._secondaries[tenant_id] = secondary
+        if previous and previous.database and previous.database is not secondary.database:
+            await previous.database.close()
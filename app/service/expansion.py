@@ -0,0 +1,215 @@
+"""
+Embedding of related entities in node responses.
+
+Expansions let a client fetch nodes together with their relationships or
+neighboring nodes in one call instead of one call per node. Each level of
+an expansion is loaded with a single query for all nodes of the page.
+
+Expansion expressions:
+
+    relationships                      relationships touching the node
+    relationships(type=OWNS,direction=out)
+    neighbors                          nodes on the other end of those relationships
+    neighbors(type=OWNS,depth=2,limit=50)
+
+Options: type (relationship type), direction (out, in or both; default
+both), limit (entries per node; default 25), valid_at (ISO 8601 time the
+relationships must be valid at; default now) and, for neighbors, depth
+(default 1). Types may be derived relationship types.
+"""
+
+import re
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from app.repository import (
+    Node,
+    NodeRepository,
+    Relationship,
+    RelationshipRepository,
+    RelationshipType,
+    RelationshipTypeRepository,
+)
+from app.service.data_migrations import DataMigrationService
+from app.service.limits import TenantLimits
+from app.service.timestamps import parse_timestamp
+
+EXPANSIONS = ("relationships", "neighbors")
+DIRECTIONS = ("out", "in", "both")
+DEFAULT_EXPAND_LIMIT = 25
+MAX_EXPAND_LIMIT = 100
+# Hard cap on neighbor depth, below the tenant's max_traversal_depth
+MAX_EXPAND_DEPTH = 3
+MAX_EXPANSIONS = 4
+
+_EXPRESSION = re.compile(r"^\s*(\w+)\s*(?:\((.*)\))?\s*$")
+
+
+@dataclass
+class ExpandSpec:
+    """A parsed expansion expression."""
+    name: str
+    rel_type: str = ""
+    direction: str = "both"
+    depth: int = 1
+    limit: int = DEFAULT_EXPAND_LIMIT
+    # Follow relationships valid at this time (None = now)
+    valid_at: Optional[datetime] = None
+
+    @property
+    def key(self) -> str:
+        """Key under which the expansion appears in the response."""
+        return self.name if not self.rel_type else f"{self.name}:{self.rel_type}"
+
+
+def parse_expand(expressions: Optional[List[str]], max_depth: int = MAX_EXPAND_DEPTH) -> List[ExpandSpec]:
+    """
+    Parse expansion expressions.
+
+    Raises:
+        ValueError: If an expression is malformed or exceeds a bound
+    """
+    if not expressions:
+        return []
+    if not isinstance(expressions, list) or len(expressions) > MAX_EXPANSIONS:
+        raise ValueError(f"expand must be an array of at most {MAX_EXPANSIONS} expressions")
+
+    specs = []
+    for expression in expressions:
+        match = _EXPRESSION.match(expression) if isinstance(expression, str) else None
+        if not match or match.group(1) not in EXPANSIONS:
+            raise ValueError(f"invalid expand expression {expression!r} (allowed: {', '.join(EXPANSIONS)})")
+        spec = ExpandSpec(name=match.group(1))
+        for option in filter(None, (o.strip() for o in (match.group(2) or "").split(","))):
+            key, sep, value = (part.strip() for part in option.partition("="))
+            if not sep or not value:
+                raise ValueError(f"invalid expand option {option!r} in {expression!r}")
+            _set_option(spec, key, value, expression, max_depth)
+        specs.append(spec)
+
+    keys = [s.key for s in specs]
+    if len(set(keys)) != len(keys):
+        raise ValueError("expand expressions must not repeat the same expansion and type")
+    return specs
+
+
+def _set_option(spec: ExpandSpec, key: str, value: str, expression: str, max_depth: int) -> None:
+    if key == "type":
+        spec.rel_type = value
+    elif key == "direction":
+        if value not in DIRECTIONS:
+            raise ValueError(f"direction must be one of {', '.join(DIRECTIONS)} in {expression!r}")
+        spec.direction = value
+    elif key in ("depth", "limit"):
+        bound = max_depth if key == "depth" else MAX_EXPAND_LIMIT
+        if key == "depth" and spec.name != "neighbors":
+            raise ValueError(f"depth only applies to neighbors in {expression!r}")
+        if not value.isdigit() or not 1 <= int(value) <= bound:
+            raise ValueError(f"{key} must be between 1 and {bound} in {expression!r}")
+        setattr(spec, key, int(value))
+    elif key == "valid_at":
+        spec.valid_at = parse_timestamp(value, f"valid_at in {expression!r}")
+    else:
+        raise ValueError(f"unknown expand option {key!r} in {expression!r}")
+
+
+class ExpansionService:
+    """Loads related entities for expansion expressions."""
+
+    def __init__(
+        self,
+        node_repo: NodeRepository,
+        relationship_repo: RelationshipRepository,
+        limits: Optional[TenantLimits] = None,
+        data_migrations: Optional[DataMigrationService] = None,
+        rel_type_repo: Optional[RelationshipTypeRepository] = None,
+    ):
+        self.node_repo = node_repo
+        self.relationship_repo = relationship_repo
+        self.limits = limits or TenantLimits()
+        self.data_migrations = data_migrations
+        # Needed to expand derived relationship types
+        self.rel_type_repo = rel_type_repo
+
+    def parse(self, expressions: Optional[List[str]]) -> List[ExpandSpec]:
+        """Parse expansion expressions within the tenant's traversal depth."""
+        return parse_expand(expressions, min(MAX_EXPAND_DEPTH, self.limits.max_traversal_depth))
+
+    async def expand(self, nodes: List[Node], specs: List[ExpandSpec]) -> Dict[str, Dict[str, Any]]:
+        """Return, per node ID, the expanded entities keyed by expansion."""
+        expanded: Dict[str, Dict[str, Any]] = {node.id: {} for node in nodes}
+        if not nodes:
+            return expanded
+
+        for spec in specs:
+            if spec.name == "relationships":
+                results = await self._relationships(list(expanded), spec)
+            else:
+                results = await self._neighbors(list(expanded), spec)
+            for node_id, entries in results.items():
+                expanded[node_id][spec.key] = entries
+        return expanded
+
+    async def _derived_type(self, spec: ExpandSpec) -> Optional[RelationshipType]:
+        if not spec.rel_type or not self.rel_type_repo:
+            return None
+        registered = await self.rel_type_repo.get_by_name(spec.rel_type)
+        return registered if registered and registered.derived else None
+
+    async def _relationships(self, node_ids: List[str], spec: ExpandSpec) -> Dict[str, List[dict]]:
+        pairs = await self.relationship_repo.list_for_nodes(
+            node_ids, spec.direction, spec.rel_type or None, spec.limit, await self._derived_type(spec), spec.valid_at
+        )
+        results: Dict[str, List[dict]] = {node_id: [] for node_id in node_ids}
+        for node_id, rel in pairs:
+            results[node_id].append(rel.to_dict())
+        return results
+
+    async def _neighbors(self, root_ids: List[str], spec: ExpandSpec) -> Dict[str, List[dict]]:
+        found: Dict[str, List[Tuple[str, Relationship, int]]] = {root: [] for root in root_ids}
+        reached: Dict[str, Set[str]] = {root: {root} for root in root_ids}
+        frontier: Dict[str, List[str]] = {root: [root] for root in root_ids}
+        derived = await self._derived_type(spec)
+
+        for depth in range(1, spec.depth + 1):
+            frontier_ids = list({node_id for ids in frontier.values() for node_id in ids})
+            if not frontier_ids:
+                break
+            by_node: Dict[str, List[Relationship]] = {}
+            for node_id, rel in await self.relationship_repo.list_for_nodes(
+                frontier_ids, spec.direction, spec.rel_type or None, spec.limit, derived, spec.valid_at
+            ):
+                by_node.setdefault(node_id, []).append(rel)
+
+            next_frontier: Dict[str, List[str]] = {}
+            for root, ids in frontier.items():
+                for node_id in ids:
+                    for rel in by_node.get(node_id, []):
+                        other = rel.target_node_id if rel.source_node_id == node_id else rel.source_node_id
+                        if other in reached[root] or len(found[root]) >= spec.limit:
+                            continue
+                        reached[root].add(other)
+                        found[root].append((other, rel, depth))
+                        next_frontier.setdefault(root, []).append(other)
+            frontier = next_frontier
+
+        neighbor_ids = list({other for entries in found.values() for other, _, _ in entries})
+        neighbors = {n.id: n for n in await self.node_repo.get_many(neighbor_ids)} if neighbor_ids else {}
+        if self.data_migrations:
+            await self.data_migrations.migrate(list(neighbors.values()))
+
+        results: Dict[str, List[dict]] = {}
+        for root, entries in found.items():
+            results[root] = [
+                {
+                    "node": neighbors[other].to_dict(),
+                    "relationship_id": rel.id,
+                    "relationship_type": rel.relationship_type,
+                    "direction": "out" if rel.target_node_id == other else "in",
+                    "depth": depth,
+                }
+                for other, rel, depth in entries
+                if other in neighbors
+            ]
+        return results
@@ -0,0 +1,104 @@
+"""
+Query plan explanations for administrators.
+
+To debug a slow tenant query, an administrator sends the read call itself
+(list_nodes, search_nodes, get_subgraph, ...) to ``explain_query``. The
+call runs with its statements captured (see app/db/tracing.py) and with
+query caching bypassed, then each statement it ran on the tenant database
+is explained: EXPLAIN ANALYZE runs it again in a read-only transaction that
+is rolled back, so the plan shows actual row counts, times and buffer use.
+Nobody has to reconstruct the generated SQL by hand.
+"""
+
+import json
+import re
+from typing import Any, Awaitable, Callable, Dict, List, Optional
+
+from app.db.tenant_db_manager import TenantDatabaseManager
+from app.db.tracing import CapturedQuery, start_capture, stop_capture
+from app.repository import QueryPlanRepository, TenantRepository
+
+# Read methods whose queries can be explained
+EXPLAIN_METHODS = (
+    "list_nodes", "search_nodes", "list_relationships", "lookup_node_by_alias",
+    "get_subgraph", "get_graph_view", "get_distinct_values", "get_date_histogram",
+)
+# Statements explained per call (the rest are listed without plans)
+MAX_EXPLAINED_STATEMENTS = 20
+# Characters of a statement's parameters returned
+MAX_ARGS_CHARS = 2048
+_READ_STATEMENT = re.compile(r"^\s*(SELECT|WITH)\b", re.IGNORECASE)
+
+
+class ExplainService:
+    """Runs tenant read calls and explains the statements they generate."""
+
+    def __init__(self, tenant_repo: TenantRepository, tenant_db_manager: TenantDatabaseManager):
+        self.tenant_repo = tenant_repo
+        self.tenant_db_manager = tenant_db_manager
+
+    async def explain(
+        self,
+        tenant_id: str,
+        method: str,
+        run: Callable[[], Awaitable[Optional[Dict[str, Any]]]],
+        analyze: bool = True,
+    ) -> Dict[str, Any]:
+        """
+        Run a read call and return the statements it ran on the tenant database with their plans.
+
+        run performs the call and returns its error ({"code", "message"}), or None if it succeeded.
+
+        Raises:
+            ValueError: If the method cannot be explained
+        """
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        if method not in EXPLAIN_METHODS:
+            raise ValueError(f"method must be one of: {', '.join(EXPLAIN_METHODS)}")
+        await self.tenant_repo.get_by_id(tenant_id)
+        repo = QueryPlanRepository(await self.tenant_db_manager.get_tenant_db(tenant_id))
+        database = await repo.database_name()
+
+        token = start_capture()
+        try:
+            error = await run()
+        finally:
+            captured = stop_capture(token)
+
+        statements: List[Dict[str, Any]] = []
+        for query in captured:
+            if query.database != database:
+                continue
+            entry = _statement(query)
+            if not _READ_STATEMENT.match(query.query):
+                entry["plan"] = None
+            elif len([s for s in statements if s.get("plan")]) >= MAX_EXPLAINED_STATEMENTS:
+                entry["plan"] = None
+                entry["error"] = f"only the first {MAX_EXPLAINED_STATEMENTS} statements are explained"
+            else:
+                try:
+                    entry["plan"] = await repo.explain(query.query, query.args, analyze)
+                except Exception as e:
+                    entry["plan"] = None
+                    entry["error"] = str(e)
+            statements.append(entry)
+
+        return {
+            "method": method,
+            "analyze": analyze,
+            "error": error,
+            "statement_count": len(statements),
+            "total_duration_ms": round(sum(s["duration_ms"] for s in statements), 2),
+            "statements": statements,
+        }
+
+
+def _statement(query: CapturedQuery) -> Dict[str, Any]:
+    args = json.dumps(list(query.args), default=str)
+    return {
+        "sql": " ".join(query.query.split()),
+        "args": args if len(args) <= MAX_ARGS_CHARS else args[:MAX_ARGS_CHARS] + "...",
+        "duration_ms": query.duration_ms,
+        "error": query.error,
+    }
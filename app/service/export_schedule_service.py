@@ -0,0 +1,397 @@
+"""
+Scheduled recurring exports.
+
+A tenant's export schedule writes an export of its data, or of the nodes
+matching a filter, to a destination (see app/storage/export_destinations.py)
+on a cron schedule evaluated in the tenant's time zone:
+
+    {"name": "nightly", "cron": "0 2 * * *", "format": "ndjson",
+     "destination": "s3://acme-exports/flexdb/", "filter": {"node_type_id": "..."},
+     "alert_url": "https://hooks.example.com/flexdb"}
+
+Formats:
+
+- ``ndjson``: one JSON object per line, ``{"node": {...}}`` for each node
+  followed, per page, by ``{"relationship": {...}}`` for each outgoing
+  relationship of the page's nodes
+- ``graphml`` and ``dot``: the graph documents of export_graph (at most
+  max_batch_size nodes)
+
+A schedule's transform (see export_transforms.py) selects and redacts the
+data fields it writes, e.g. for exports delivered to third parties.
+
+A schedule may name one of the tenant's destination credentials, such as a
+GCS service account key or SFTP login, to write with. Credentials are
+stored sealed with the server's master key and their secrets are never
+returned.
+
+Each run writes a new file named after the schedule and the run's start time
+and is recorded in the schedule's run history. A failed run is retried at
+the next scheduled time, and its schedule's alert_url receives a POST
+describing it. Written bytes are billed as export_bytes of
+"export_schedule". Schedules are claimed before they run, so with several
+servers each due run happens once; runs missed while no server was running
+are not caught up.
+"""
+
+import json
+import logging
+import re
+from datetime import datetime, timedelta, timezone
+from typing import Any, AsyncIterator, Awaitable, Callable, Dict, List, Optional, Tuple
+from urllib.parse import urlparse
+
+from app.jobs.side_effects import SideEffectQueue
+from app.repository import (
+    DestinationCredential,
+    DestinationCredentialRepository,
+    ExportRun,
+    ExportSchedule,
+    ExportScheduleRepository,
+    ListOptions,
+    ListResult,
+    NotFoundError,
+    TenantRepository,
+)
+from app.service.billing_service import UsageRecorder
+from app.service.bulk_service import parse_node_filter
+from app.service.cron import parse_cron
+from app.service.export_transforms import parse_transform
+from app.service.graph_formats import GRAPH_FORMATS
+from app.storage.export_destinations import (
+    DESTINATION_SECRET_KEYS,
+    ExportDestination,
+    destination_scheme,
+    open_destination,
+)
+
+logger = logging.getLogger(__name__)
+
+CONTENT_TYPES = {"ndjson": "application/x-ndjson", **GRAPH_FORMATS}
+EXPORT_FORMATS = tuple(CONTENT_TYPES)
+
+MAX_SCHEDULES_PER_TENANT = 20
+MAX_CREDENTIALS_PER_TENANT = 20
+# Runs kept in each schedule's history
+MAX_RUN_HISTORY = 100
+# Largest file a run may write
+MAX_EXPORT_BYTES = 50 * 1024 * 1024 * 1024
+# Nodes read per export page (capped by the tenant's max page size)
+EXPORT_PAGE_SIZE = 1000
+# Schedules run per scheduler pass; the rest wait for the next pass
+MAX_DUE_PER_PASS = 10
+# A run still marked running after this long was abandoned by a stopped server
+ABANDONED_RUN_AGE = timedelta(hours=24)
+ALERT_TIMEOUT_SECONDS = 10.0
+
+_NAME = re.compile(r"^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$")
+
+
+async def post_alert(url: str, payload: Dict[str, Any]) -> None:
+    """POST an alert as JSON."""
+    import httpx
+
+    async with httpx.AsyncClient(timeout=ALERT_TIMEOUT_SECONDS) as client:
+        response = await client.post(url, json=payload)
+        response.raise_for_status()
+
+
+def _check_alert_url(url: str) -> str:
+    if url and (urlparse(url).scheme not in ("http", "https") or not urlparse(url).netloc):
+        raise ValueError("alert_url must be an http or https URL")
+    return url
+
+
+class ExportScheduleService:
+    """Export schedule business logic service."""
+
+    def __init__(
+        self,
+        repo: ExportScheduleRepository,
+        tenant_repo: TenantRepository,
+        tenant_services: Callable[[str], Awaitable[Dict[str, Any]]],
+        credential_repo: Optional[DestinationCredentialRepository] = None,
+        destinations: Callable[[str, Optional[Dict[str, Any]]], ExportDestination] = open_destination,
+        alert: Callable[[str, Dict[str, Any]], Awaitable[None]] = post_alert,
+        usage: Optional[UsageRecorder] = None,
+        side_effects: Optional[SideEffectQueue] = None,
+    ):
+        self.repo = repo
+        self.tenant_repo = tenant_repo
+        self.tenant_services = tenant_services
+        self.credential_repo = credential_repo
+        self.destinations = destinations
+        self.alert = alert
+        self.usage = usage
+        # Alerts are posted detached when set, so run_export_schedule does not wait for a slow webhook
+        self.side_effects = side_effects
+
+    async def create(
+        self,
+        tenant_id: str,
+        name: str,
+        cron: str,
+        destination: str,
+        format: str = "ndjson",
+        filter: Optional[Dict[str, Any]] = None,
+        include_relationships: bool = True,
+        alert_url: str = "",
+        enabled: bool = True,
+        credential: str = "",
+        transform: Optional[Dict[str, Any]] = None,
+    ) -> ExportSchedule:
+        """
+        Create an export schedule.
+
+        Raises:
+            ValueError: If a setting is invalid or the tenant has MAX_SCHEDULES_PER_TENANT schedules
+            AlreadyExistsError: If the tenant already has a schedule with the name
+        """
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        tenant = await self.tenant_repo.get_by_id(tenant_id)
+        _, existing = await self.repo.list(tenant_id, ListOptions(page_size=1))
+        if existing.total_count >= MAX_SCHEDULES_PER_TENANT:
+            raise ValueError(f"a tenant can have at most {MAX_SCHEDULES_PER_TENANT} export schedules")
+
+        schedule = ExportSchedule(
+            tenant_id=tenant_id, name=name, cron=cron, format=format, destination=destination,
+            filter=filter or {}, include_relationships=bool(include_relationships), alert_url=alert_url or "",
+            credential=credential or "", transform=transform or {}, enabled=bool(enabled),
+        )
+        await self._check(schedule)
+        schedule.next_run_at = self._next_run(schedule, tenant.timezone)
+        return await self.repo.create(schedule)
+
+    async def get(self, tenant_id: str, id: str) -> ExportSchedule:
+        """Retrieve one of a tenant's schedules."""
+        if not id:
+            raise ValueError("id is required")
+        schedule = await self.repo.get_by_id(id)
+        # Schedules of other tenants are not disclosed
+        if schedule.tenant_id != tenant_id:
+            raise NotFoundError(f"export schedule not found: {id}")
+        return schedule
+
+    async def update(
+        self,
+        tenant_id: str,
+        id: str,
+        name: str = "",
+        cron: str = "",
+        destination: str = "",
+        format: str = "",
+        filter: Optional[Dict[str, Any]] = None,
+        include_relationships: Optional[bool] = None,
+        alert_url: Optional[str] = None,
+        enabled: Optional[bool] = None,
+        credential: Optional[str] = None,
+        transform: Optional[Dict[str, Any]] = None,
+    ) -> ExportSchedule:
+        """Update a schedule; settings left empty (or None) are kept. The next run is recomputed."""
+        schedule = await self.get(tenant_id, id)
+        schedule.name = name or schedule.name
+        schedule.cron = cron or schedule.cron
+        schedule.destination = destination or schedule.destination
+        schedule.format = format or schedule.format
+        if filter is not None:
+            schedule.filter = filter
+        if include_relationships is not None:
+            schedule.include_relationships = bool(include_relationships)
+        if alert_url is not None:
+            schedule.alert_url = alert_url
+        if enabled is not None:
+            schedule.enabled = bool(enabled)
+        if credential is not None:
+            schedule.credential = credential
+        if transform is not None:
+            schedule.transform = transform
+        await self._check(schedule)
+        tenant = await self.tenant_repo.get_by_id(tenant_id)
+        schedule.next_run_at = self._next_run(schedule, tenant.timezone)
+        return await self.repo.update(schedule)
+
+    async def delete(self, tenant_id: str, id: str) -> None:
+        """Delete a schedule and its run history; files already written are kept."""
+        await self.get(tenant_id, id)
+        await self.repo.delete(id)
+
+    async def list(self, tenant_id: str, page_size: int, page_token: str) -> Tuple[List[ExportSchedule], ListResult]:
+        """Retrieve a tenant's schedules with pagination."""
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        return await self.repo.list(tenant_id, ListOptions(page_size=page_size, page_token=page_token))
+
+    async def list_runs(
+        self, tenant_id: str, id: str, page_size: int, page_token: str
+    ) -> Tuple[List[ExportRun], ListResult]:
+        """Retrieve a schedule's run history, most recent first."""
+        await self.get(tenant_id, id)
+        return await self.repo.list_runs(id, ListOptions(page_size=page_size, page_token=page_token))
+
+    async def run_now(self, tenant_id: str, id: str) -> ExportRun:
+        """Run a schedule immediately (also when disabled); its next scheduled run is unchanged."""
+        return await self._run(await self.get(tenant_id, id))
+
+    async def run_due(self) -> int:
+        """Run the schedules that are due (the scheduler's periodic job); returns how many ran."""
+        now = datetime.now(timezone.utc)
+        abandoned = await self.repo.fail_abandoned_runs(now - ABANDONED_RUN_AGE)
+        if abandoned:
+            logger.warning(f"Marked {abandoned} abandoned export runs failed")
+
+        count = 0
+        for schedule in await self.repo.list_due(now, MAX_DUE_PER_PASS):
+            try:
+                tenant = await self.tenant_repo.get_by_id(schedule.tenant_id)
+                next_run_at = parse_cron(schedule.cron).next_after(now, tenant.timezone)
+                # Another server claimed it first
+                if not await self.repo.claim(schedule.id, schedule.next_run_at, next_run_at):
+                    continue
+            except Exception as e:
+                logger.error(f"Scheduling export {schedule.name} of tenant {schedule.tenant_id} failed: {e}")
+                continue
+            await self._run(schedule)
+            count += 1
+        return count
+
+    async def set_credential(
+        self, tenant_id: str, name: str, kind: str, secret: Dict[str, Any]
+    ) -> DestinationCredential:
+        """
+        Store a destination credential for a scheme (kind), replacing the tenant's credential of the same name.
+
+        Raises:
+            ValueError: If the secret has unknown or missing keys, or no master key is configured
+        """
+        if not isinstance(name, str) or not _NAME.match(name):
+            raise ValueError("name must be 1-100 letters, digits, '.', '_' or '-', starting with a letter or digit")
+        if kind not in DESTINATION_SECRET_KEYS:
+            raise ValueError(f"kind must be one of: {', '.join(DESTINATION_SECRET_KEYS)}")
+        if not isinstance(secret, dict) or not secret:
+            raise ValueError("secret must be a non-empty object")
+        unknown = sorted(k for k in secret if k not in DESTINATION_SECRET_KEYS[kind])
+        if unknown:
+            raise ValueError(
+                f"secret has unknown keys for {kind}: {', '.join(unknown)} "
+                f"(allowed: {', '.join(DESTINATION_SECRET_KEYS[kind])})"
+            )
+        for key, value in secret.items():
+            # A GCS service account key is the JSON key file's object
+            if not (isinstance(value, str) or (key == "service_account" and isinstance(value, dict))):
+                raise ValueError(f"secret.{key} must be a string")
+        await self.tenant_repo.get_by_id(tenant_id)
+        existing = await self._credentials().list(tenant_id)
+        if len(existing) >= MAX_CREDENTIALS_PER_TENANT and name not in {c.name for c in existing}:
+            raise ValueError(f"a tenant can have at most {MAX_CREDENTIALS_PER_TENANT} destination credentials")
+        return await self._credentials().put(tenant_id, name, kind, secret)
+
+    async def list_credentials(self, tenant_id: str) -> List[DestinationCredential]:
+        """Retrieve a tenant's destination credentials (without their secrets)."""
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        return await self._credentials().list(tenant_id)
+
+    async def delete_credential(self, tenant_id: str, name: str) -> None:
+        """
+        Delete a destination credential.
+
+        Raises:
+            ValueError: If export schedules write with it
+        """
+        users = await self.repo.names_using_credential(tenant_id, name)
+        if users:
+            raise ValueError(f"destination credential {name} is used by export schedules: {', '.join(users)}")
+        await self._credentials().delete(tenant_id, name)
+
+    def _credentials(self) -> DestinationCredentialRepository:
+        if self.credential_repo is None:
+            raise ValueError("destination credentials are not configured")
+        return self.credential_repo
+
+    async def _secret(self, schedule: ExportSchedule) -> Optional[Dict[str, Any]]:
+        """Return the secret of a schedule's credential, checking it is for the destination's scheme."""
+        if not schedule.credential:
+            return None
+        credential = await self._credentials().get(schedule.tenant_id, schedule.credential)
+        scheme = destination_scheme(schedule.destination)
+        if credential.kind != scheme:
+            raise ValueError(f"credential {credential.name} is for {credential.kind}, not {scheme} destinations")
+        return credential.secret
+
+    async def _check(self, schedule: ExportSchedule) -> None:
+        if not isinstance(schedule.name, str) or not _NAME.match(schedule.name):
+            raise ValueError("name must be 1-100 letters, digits, '.', '_' or '-', starting with a letter or digit")
+        parse_cron(schedule.cron)
+        if schedule.format not in EXPORT_FORMATS:
+            raise ValueError(f"format must be one of: {', '.join(EXPORT_FORMATS)}")
+        self.destinations(schedule.destination, await self._secret(schedule))
+        if not isinstance(schedule.filter, dict):
+            raise ValueError("filter must be an object")
+        parse_node_filter(schedule.filter)
+        parse_transform(schedule.transform)
+        _check_alert_url(schedule.alert_url)
+
+    def _next_run(self, schedule: ExportSchedule, tz: str) -> Optional[datetime]:
+        if not schedule.enabled:
+            return None
+        return parse_cron(schedule.cron).next_after(datetime.now(timezone.utc), tz)
+
+    async def _run(self, schedule: ExportSchedule) -> ExportRun:
+        run = await self.repo.start_run(schedule.id)
+        started = run.started_at.astimezone(timezone.utc)
+        filename = f"{schedule.name}-{started:%Y%m%dT%H%M%SZ}.{schedule.format}"
+        try:
+            destination = self.destinations(schedule.destination, await self._secret(schedule))
+            export = (await self.tenant_services(schedule.tenant_id))["export"]
+            run.location, stored = await destination.write(
+                filename, self._render(export, schedule, run), CONTENT_TYPES[schedule.format], MAX_EXPORT_BYTES
+            )
+            run.size_bytes = stored.size_bytes
+            run.status = "succeeded"
+            if self.usage:
+                self.usage.record(schedule.tenant_id, "export_bytes", "export_schedule", stored.size_bytes)
+        except Exception as e:
+            logger.error(f"Export {schedule.name} of tenant {schedule.tenant_id} failed: {e}")
+            run.status, run.error = "failed", str(e) or type(e).__name__
+        run = await self.repo.finish_run(run, MAX_RUN_HISTORY)
+
+        if run.status == "failed" and schedule.alert_url:
+            payload = {
+                "event": "export_run_failed",
+                "tenant_id": schedule.tenant_id,
+                "schedule": schedule.to_dict(),
+                "run": run.to_dict(),
+                "consecutive_failures": schedule.consecutive_failures + 1,
+            }
+            if self.side_effects:
+                url = schedule.alert_url
+                self.side_effects.submit("export-alert", lambda: self.alert(url, payload))
+            else:
+                try:
+                    await self.alert(schedule.alert_url, payload)
+                except Exception as e:
+                    logger.error(f"Alerting {schedule.alert_url} of failed export {schedule.name} failed: {e}")
+        return run
+
+    async def _render(self, export: Any, schedule: ExportSchedule, run: ExportRun) -> AsyncIterator[bytes]:
+        """Yield the export file, counting what it contains in the run."""
+        if schedule.format in GRAPH_FORMATS:
+            document, run.node_count, run.relationship_count = await export.export_graph(
+                schedule.format, schedule.filter, transform=schedule.transform
+            )
+            yield document.encode()
+            return
+
+        page_token = ""
+        while True:
+            nodes, relationships, result, _ = await export.export(
+                schedule.filter, schedule.include_relationships, EXPORT_PAGE_SIZE, page_token, schedule.transform
+            )
+            lines = [{"node": n.to_dict()} for n in nodes] + [{"relationship": r.to_dict()} for r in relationships]
+            run.node_count += len(nodes)
+            run.relationship_count += len(relationships)
+            yield "".join(json.dumps(line, separators=(",", ":")) + "\n" for line in lines).encode()
+            page_token = result.next_page_token
+            if not page_token:
+                return
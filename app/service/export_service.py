@@ -0,0 +1,303 @@
+"""
+Tenant data export.
+
+Exports page through the nodes matching a filter (the bulk operation
+filter keys, e.g. one node type updated since a date) in ID order, together
+with the outgoing relationships of each page's nodes. Pages are keyed by
+the last exported node ID, so nodes created while an export is in progress
+cannot shift later pages.
+
+Every export also returns a sync cursor. Passing it to changes() returns
+only what changed since the export started: written nodes, written
+relationships and tombstones of deleted ones. Cursors are transaction ID
+horizons rather than timestamps, so a change is never skipped because its
+transaction committed late; a change may instead be returned twice, so
+consumers should apply changes as upserts. Tombstones are kept for
+TOMBSTONE_RETENTION, after which older cursors expire.
+
+Graph exports render the matching nodes and the relationships between them
+as one GraphML or Graphviz DOT document, for tools such as Gephi.
+
+Every kind of export takes an optional transform (see export_transforms.py)
+selecting and redacting the data fields that leave the server.
+"""
+
+import base64
+import json
+import uuid
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import (
+    ListResult,
+    Node,
+    NodeFilter,
+    NodeRepository,
+    PreconditionFailedError,
+    Relationship,
+    RelationshipRepository,
+    Tombstone,
+    TombstoneRepository,
+)
+from app.service.bulk_service import parse_node_filter
+from app.service.export_transforms import ExportTransform, parse_transform
+from app.service.graph_formats import GRAPH_FORMATS, render_graph
+from app.service.limits import TenantLimits
+
+# How long deletions are remembered, and so how long a sync cursor stays usable
+TOMBSTONE_RETENTION = timedelta(days=30)
+
+# Order in which incremental exports return changes
+CHANGE_PHASES = ("nodes", "relationships", "tombstones")
+
+# Nodes read per query while building a graph export
+GRAPH_PAGE_SIZE = 1000
+
+
+def _encode(state: Dict[str, Any]) -> str:
+    return base64.urlsafe_b64encode(json.dumps(state, separators=(",", ":")).encode()).decode().rstrip("=")
+
+
+def _decode(token: str, name: str) -> Dict[str, Any]:
+    try:
+        state = json.loads(base64.urlsafe_b64decode(token + "=" * (-len(token) % 4)))
+    except ValueError:
+        raise ValueError(f"invalid {name}")
+    if not isinstance(state, dict):
+        raise ValueError(f"invalid {name}")
+    return state
+
+
+def _check_cursor(cursor: Any, name: str) -> Dict[str, str]:
+    """Validate a decoded sync cursor {"xmin", "issued_at"}."""
+    if (
+        not isinstance(cursor, dict)
+        or not str(cursor.get("xmin", "")).isdigit()
+        or not isinstance(cursor.get("issued_at"), str)
+    ):
+        raise ValueError(f"invalid {name}")
+    try:
+        issued_at = datetime.fromisoformat(cursor["issued_at"])
+    except ValueError:
+        raise ValueError(f"invalid {name}")
+    if issued_at < datetime.now(timezone.utc) - TOMBSTONE_RETENTION:
+        raise PreconditionFailedError(
+            f"{name} is older than {TOMBSTONE_RETENTION.days} days; run a full export_tenant for a new one"
+        )
+    return {"xmin": str(cursor["xmin"]), "issued_at": cursor["issued_at"]}
+
+
+def cursor_position(sync_cursor: str) -> str:
+    """
+    Return the transaction ID horizon of a sync cursor: changes of every
+    transaction before it are included in the export that issued the cursor.
+
+    Raises:
+        ValueError: If the cursor is invalid
+    """
+    cursor = _decode(sync_cursor, "sync_cursor")
+    if not str(cursor.get("xmin", "")).isdigit():
+        raise ValueError("invalid sync_cursor")
+    return str(cursor["xmin"])
+
+
+def _transformed(transform: Optional[ExportTransform], nodes: List[Node], relationships: List[Relationship]) -> None:
+    if transform:
+        for node in nodes:
+            transform.node(node)
+        for relationship in relationships:
+            transform.relationship(relationship)
+
+
+def _check_after(after: Any) -> str:
+    if not after:
+        return ""
+    try:
+        uuid.UUID(str(after))
+    except ValueError:
+        raise ValueError("invalid page_token")
+    return str(after)
+
+
+class ExportService:
+    """Tenant export business logic service."""
+
+    def __init__(
+        self,
+        node_repo: NodeRepository,
+        relationship_repo: RelationshipRepository,
+        tombstone_repo: TombstoneRepository,
+        limits: Optional[TenantLimits] = None,
+    ):
+        self.node_repo = node_repo
+        self.relationship_repo = relationship_repo
+        self.tombstone_repo = tombstone_repo
+        self.limits = limits or TenantLimits()
+
+    async def export(
+        self,
+        filter: Optional[Dict[str, Any]],
+        include_relationships: bool,
+        page_size: int,
+        page_token: str,
+        transform: Optional[Dict[str, Any]] = None,
+    ) -> Tuple[List[Node], List[Relationship], ListResult, str]:
+        """
+        Export one page of the nodes matching a filter and, optionally, their outgoing relationships.
+
+        Returns the nodes, relationships, pagination and the sync cursor of
+        the whole export (taken when its first page was read).
+
+        Raises:
+            ValueError: If the filter, transform or page token is invalid
+        """
+        filters = parse_node_filter(filter)
+        transformer = parse_transform(transform)
+        if page_token:
+            state = _decode(page_token, "page_token")
+            cursor = _check_cursor(state.get("cursor"), "page_token")
+            after = _check_after(state.get("after"))
+        else:
+            cursor, after = await self._new_cursor(), ""
+        limit = self.limits.list_options(page_size, page_token).effective_page_size()
+
+        nodes = await self.node_repo.list_matching(filters, after, limit + 1)
+        result = ListResult(total_count=await self.node_repo.count_matching(filters))
+        if len(nodes) > limit:
+            nodes = nodes[:limit]
+            result.next_page_token = _encode({"cursor": cursor, "after": nodes[-1].id})
+
+        relationships: List[Relationship] = []
+        if include_relationships and nodes:
+            relationships = await self.relationship_repo.list_from_sources([n.id for n in nodes])
+        _transformed(transformer, nodes, relationships)
+        return nodes, relationships, result, _encode(cursor)
+
+    async def changes(
+        self,
+        sync_cursor: str,
+        filter: Optional[Dict[str, Any]],
+        include_relationships: bool,
+        page_size: int,
+        page_token: str,
+        transform: Optional[Dict[str, Any]] = None,
+    ) -> Tuple[List[Node], List[Relationship], List[Tombstone], ListResult, str]:
+        """
+        Return one page of the changes since a sync cursor: nodes matching the
+        filter, relationships from matching nodes and tombstones, in that order.
+
+        Returns the changes, pagination and the sync cursor for the next call
+        (taken when the first page was read).
+
+        Raises:
+            ValueError: If the cursor, filter, transform or page token is invalid
+            PreconditionFailedError: If the cursor has expired
+        """
+        filters = parse_node_filter(filter)
+        transformer = parse_transform(transform)
+        if page_token:
+            state = _decode(page_token, "page_token")
+            since = _check_cursor(state.get("since"), "page_token")
+            next_cursor = _check_cursor(state.get("next"), "page_token")
+            phase = state.get("phase")
+            after = _check_after(state.get("after"))
+            if phase not in CHANGE_PHASES:
+                raise ValueError("invalid page_token")
+        else:
+            if not sync_cursor:
+                raise ValueError("sync_cursor is required")
+            since = _check_cursor(_decode(sync_cursor, "sync_cursor"), "sync_cursor")
+            await self.tombstone_repo.prune(datetime.now(timezone.utc) - TOMBSTONE_RETENTION)
+            next_cursor = await self._new_cursor()
+            phase, after = CHANGE_PHASES[0], ""
+
+        phases = [p for p in CHANGE_PHASES if include_relationships or p != "relationships"]
+        remaining = self.limits.list_options(page_size, page_token).effective_page_size()
+        nodes: List[Node] = []
+        relationships: List[Relationship] = []
+        tombstones: List[Tombstone] = []
+        result = ListResult()
+
+        while phase and remaining > 0:
+            if phase == "nodes":
+                items: List[Any] = await self.node_repo.list_matching(filters, after, remaining + 1, since["xmin"])
+                nodes += items[:remaining]
+            elif phase == "relationships":
+                items = await self.relationship_repo.list_changed(filters, since["xmin"], after, remaining + 1)
+                relationships += items[:remaining]
+            else:
+                items = await self.tombstone_repo.list_since(since["xmin"], after, remaining + 1)
+                tombstones += items[:remaining]
+
+            if len(items) > remaining:
+                last = items[remaining - 1]
+                after = last.entity_id if phase == "tombstones" else last.id
+                break
+            remaining -= len(items)
+            following = phases.index(phase) + 1 if phase in phases else len(phases)
+            phase = phases[following] if following < len(phases) else None
+            after = ""
+
+        _transformed(transformer, nodes, relationships)
+        if phase:
+            result.next_page_token = _encode({"since": since, "next": next_cursor, "phase": phase, "after": after})
+        return nodes, relationships, tombstones, result, _encode(next_cursor)
+
+    async def export_graph(
+        self,
+        format: str,
+        filter: Optional[Dict[str, Any]],
+        label_field: str = "",
+        transform: Optional[Dict[str, Any]] = None,
+    ) -> Tuple[str, int, int]:
+        """
+        Render the nodes matching a filter and the relationships between them
+        as a GraphML or DOT document; returns it with the node and
+        relationship counts.
+
+        Raises:
+            ValueError: If the format, filter or transform is invalid, or more than max_batch_size nodes match
+        """
+        if format not in GRAPH_FORMATS:
+            raise ValueError(f"format must be one of: {', '.join(GRAPH_FORMATS)}")
+        filters = parse_node_filter(filter)
+        transformer = parse_transform(transform)
+        matched = await self.node_repo.count_matching(filters)
+        if matched > self.limits.max_batch_size:
+            raise ValueError(
+                f"{matched} nodes match, more than max_batch_size ({self.limits.max_batch_size}); narrow the filter"
+            )
+
+        nodes: List[Node] = []
+        after = ""
+        while True:
+            page = await self.node_repo.list_matching(filters, after, GRAPH_PAGE_SIZE)
+            nodes += page
+            if len(page) < GRAPH_PAGE_SIZE:
+                break
+            after = page[-1].id
+
+        # Only relationships within the exported subgraph
+        ids = {n.id for n in nodes}
+        relationships: List[Relationship] = []
+        for i in range(0, len(nodes), GRAPH_PAGE_SIZE):
+            sources = [n.id for n in nodes[i:i + GRAPH_PAGE_SIZE]]
+            relationships += [r for r in await self.relationship_repo.list_from_sources(sources) if r.target_node_id in ids]
+        _transformed(transformer, nodes, relationships)
+        return render_graph(format, nodes, relationships, label_field), len(nodes), len(relationships)
+
+    async def scan(self, entity: str, after_id: str, limit: int) -> List[Any]:
+        """Retrieve up to limit of all nodes or relationships (entity "nodes" or "relationships"), ordered by ID after after_id."""
+        if entity == "nodes":
+            return await self.node_repo.list_matching(NodeFilter(), after_id, limit)
+        # Every transaction ID is at or after 0
+        return await self.relationship_repo.list_changed(NodeFilter(), "0", after_id, limit)
+
+    async def current_cursor(self) -> str:
+        """Take a sync cursor from which changes() returns what changes from now on."""
+        return _encode(await self._new_cursor())
+
+    async def _new_cursor(self) -> Dict[str, str]:
+        """Take a sync cursor at the current transaction horizon."""
+        issued_at = datetime.now(timezone.utc).isoformat()
+        return {"xmin": await self.tombstone_repo.sync_position(), "issued_at": issued_at}
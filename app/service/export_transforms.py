@@ -0,0 +1,220 @@
+"""
+Field selection and redaction for exports.
+
+An export transform rewrites each exported node's data and metadata, and
+each relationship's data, before it leaves the server, so exports meant
+for third parties need no post-processing:
+
+    {"include": ["data.name", "data.address.city", "data.email"],
+     "exclude": ["metadata"],
+     "redact": [{"path": "data.email", "with": "hash"}],
+     "hash_salt": "per-recipient secret",
+     "node_types": {"<node_type_id>": {"exclude": ["data.ssn"]}},
+     "relationships": {"exclude": ["data.notes"]}}
+
+Rules apply in order: ``include`` keeps only the listed paths (default:
+everything), ``exclude`` drops paths, and ``redact`` replaces the values at
+paths with:
+
+- ``mask``: the string ``[REDACTED]``
+- ``hash``: ``sha256:`` and the hex HMAC-SHA256 of the JSON value, keyed with
+  ``hash_salt`` (required), so values stay joinable across exports without
+  being guessable from a list of candidates
+- ``value``: the rule's ``value``
+
+Node paths start with ``data.`` or ``metadata.`` (``metadata`` alone is the
+whole metadata object); relationship paths start with ``data.``. A path
+through an array applies to each of its objects. Paths a record does not
+have are skipped. Rules under ``node_types`` replace the top-level rules for
+nodes of that type; ``relationships`` rules apply to relationship data.
+"""
+
+import copy
+import hashlib
+import hmac
+import json
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional
+
+from app.repository import Node, Relationship
+from app.repository.models import parse_data
+
+REDACTIONS = ("mask", "hash", "value")
+MASK = "[REDACTED]"
+# Paths per rule list, and node types with their own rules
+MAX_TRANSFORM_PATHS = 100
+MAX_TRANSFORM_NODE_TYPES = 50
+MIN_HASH_SALT_LENGTH = 16
+
+_RULE_KEYS = ("include", "exclude", "redact")
+_TRANSFORM_KEYS = _RULE_KEYS + ("hash_salt", "node_types", "relationships")
+
+
+@dataclass
+class _Redaction:
+    path: List[str]
+    method: str
+    value: Any = None
+
+
+@dataclass
+class _Rules:
+    """Field rules for one kind of record; paths are key lists starting with "data" or "metadata"."""
+    include: Optional[List[List[str]]] = None
+    exclude: List[List[str]] = field(default_factory=list)
+    redact: List[_Redaction] = field(default_factory=list)
+
+
+def _parse_path(path: Any, name: str, roots: tuple) -> List[str]:
+    keys = path.split(".") if isinstance(path, str) else []
+    whole = keys == ["metadata"] and "metadata" in roots
+    if not keys or keys[0] not in roots or "" in keys or (len(keys) < 2 and not whole):
+        allowed = " or ".join(f"{r}.<field>" for r in roots)
+        raise ValueError(f"{name} entries must be paths such as {allowed} (got {path!r})")
+    return keys
+
+
+def _parse_paths(paths: Any, name: str, roots: tuple) -> List[List[str]]:
+    if not isinstance(paths, list) or len(paths) > MAX_TRANSFORM_PATHS:
+        raise ValueError(f"{name} must be an array of at most {MAX_TRANSFORM_PATHS} paths")
+    return [_parse_path(p, name, roots) for p in paths]
+
+
+def _parse_rules(rules: Any, name: str, roots: tuple, hash_salt: str) -> _Rules:
+    if not isinstance(rules, dict):
+        raise ValueError(f"{name} must be an object")
+    unknown = sorted(k for k in rules if k not in _RULE_KEYS)
+    if unknown:
+        raise ValueError(f"unknown keys in {name}: {', '.join(unknown)} (allowed: {', '.join(_RULE_KEYS)})")
+    parsed = _Rules()
+    if rules.get("include") is not None:
+        parsed.include = _parse_paths(rules["include"], f"{name}.include", roots)
+    if rules.get("exclude") is not None:
+        parsed.exclude = _parse_paths(rules["exclude"], f"{name}.exclude", roots)
+
+    redact = rules.get("redact") or []
+    if not isinstance(redact, list) or len(redact) > MAX_TRANSFORM_PATHS:
+        raise ValueError(f"{name}.redact must be an array of at most {MAX_TRANSFORM_PATHS} rules")
+    for rule in redact:
+        if not isinstance(rule, dict):
+            raise ValueError(f'{name}.redact entries must be objects such as {{"path": "data.email", "with": "mask"}}')
+        method = rule.get("with", "mask")
+        if method not in REDACTIONS:
+            raise ValueError(f"{name}.redact with must be one of: {', '.join(REDACTIONS)}")
+        if method == "hash" and len(hash_salt) < MIN_HASH_SALT_LENGTH:
+            raise ValueError(f"hash redaction requires a hash_salt of at least {MIN_HASH_SALT_LENGTH} characters")
+        if method == "value" and "value" not in rule:
+            raise ValueError(f"{name}.redact rules with value need a value")
+        parsed.redact.append(_Redaction(_parse_path(rule.get("path"), f"{name}.redact", roots), method, rule.get("value")))
+    return parsed
+
+
+def _select(value: Any, paths: List[List[str]]) -> Any:
+    """Keep only the paths (relative to value) in value."""
+    if any(not p for p in paths):
+        return copy.deepcopy(value)
+    if isinstance(value, list):
+        return [_select(v, paths) for v in value if isinstance(v, dict)]
+    if not isinstance(value, dict):
+        return None
+    selected = {}
+    for key in dict.fromkeys(p[0] for p in paths):
+        if key in value:
+            child = _select(value[key], [p[1:] for p in paths if p[0] == key])
+            if child is not None:
+                selected[key] = child
+    return selected
+
+
+def _visit(value: Any, keys: List[str], apply) -> None:
+    """Call apply(parent, key) for each object holding the last key of the path."""
+    if isinstance(value, list):
+        for v in value:
+            _visit(v, keys, apply)
+    elif isinstance(value, dict) and keys[0] in value:
+        if len(keys) == 1:
+            apply(value, keys[0])
+        else:
+            _visit(value[keys[0]], keys[1:], apply)
+
+
+class ExportTransform:
+    """A parsed export transform."""
+
+    def __init__(
+        self,
+        nodes: Optional[_Rules] = None,
+        node_types: Optional[Dict[str, _Rules]] = None,
+        relationships: Optional[_Rules] = None,
+        hash_salt: str = "",
+    ):
+        self.nodes = nodes or _Rules()
+        self.node_types = node_types or {}
+        self.relationships = relationships or _Rules()
+        self.hash_salt = hash_salt
+
+    def node(self, node: Node) -> Node:
+        """Transform a node's data and metadata in place; returns it."""
+        rules = self.node_types.get(node.node_type_id, self.nodes)
+        record = self._apply(rules, {"data": parse_data(node.data), "metadata": node.metadata})
+        node.data = json.dumps(record.get("data", {}))
+        node.compressed_data = None
+        node.metadata = record.get("metadata") or {}
+        return node
+
+    def relationship(self, relationship: Relationship) -> Relationship:
+        """Transform a relationship's data in place; returns it."""
+        record = self._apply(self.relationships, {"data": parse_data(relationship.data)})
+        relationship.data = json.dumps(record.get("data", {}))
+        relationship.compressed_data = None
+        return relationship
+
+    def _apply(self, rules: _Rules, record: Dict[str, Any]) -> Dict[str, Any]:
+        record = _select(record, rules.include) if rules.include is not None else copy.deepcopy(record)
+        for path in rules.exclude:
+            _visit(record, path, lambda parent, key: parent.pop(key))
+        for redaction in rules.redact:
+            _visit(record, redaction.path, lambda parent, key, r=redaction: self._redact(parent, key, r))
+        return record
+
+    def _redact(self, parent: Dict[str, Any], key: str, redaction: _Redaction) -> None:
+        if parent[key] is None:
+            return
+        if redaction.method == "mask":
+            parent[key] = MASK
+        elif redaction.method == "hash":
+            value = json.dumps(parent[key], sort_keys=True, separators=(",", ":")).encode()
+            parent[key] = "sha256:" + hmac.new(self.hash_salt.encode(), value, hashlib.sha256).hexdigest()
+        else:
+            parent[key] = copy.deepcopy(redaction.value)
+
+
+def parse_transform(transform: Optional[Dict[str, Any]]) -> Optional[ExportTransform]:
+    """
+    Parse an export transform; None (or {}) for no transform.
+
+    Raises:
+        ValueError: If the transform is invalid
+    """
+    if not transform:
+        return None
+    if not isinstance(transform, dict):
+        raise ValueError("transform must be an object")
+    unknown = sorted(k for k in transform if k not in _TRANSFORM_KEYS)
+    if unknown:
+        raise ValueError(f"unknown keys in transform: {', '.join(unknown)} (allowed: {', '.join(_TRANSFORM_KEYS)})")
+    hash_salt = transform.get("hash_salt") or ""
+    if not isinstance(hash_salt, str):
+        raise ValueError("transform.hash_salt must be a string")
+
+    node_roots = ("data", "metadata")
+    nodes = _parse_rules({k: transform[k] for k in _RULE_KEYS if k in transform}, "transform", node_roots, hash_salt)
+    node_types = transform.get("node_types") or {}
+    if not isinstance(node_types, dict) or len(node_types) > MAX_TRANSFORM_NODE_TYPES:
+        raise ValueError(f"transform.node_types must be an object of at most {MAX_TRANSFORM_NODE_TYPES} node type IDs")
+    return ExportTransform(
+        nodes,
+        {id: _parse_rules(rules, f"transform.node_types.{id}", node_roots, hash_salt) for id, rules in node_types.items()},
+        _parse_rules(transform.get("relationships") or {}, "transform.relationships", ("data",), hash_salt),
+        hash_salt,
+    )
@@ -0,0 +1,223 @@
+"""
+Field typing hints: typed data fields declared in node type schemas.
+
+Node data is free-form JSON, so a date stored as "3/1/2024" or a number
+stored as "42" sorts and compares as text. A node type's schema can declare
+the type of its data fields with JSON Schema keywords in ``properties``
+(nested objects are followed through their own ``properties``):
+
+    {"properties": {
+        "due": {"type": "string", "format": "date"},
+        "closed_at": {"type": "string", "format": "date-time"},
+        "amount": {"type": "number"},
+        "seats": {"type": "integer"},
+        "status": {"enum": ["open", "won", "lost"]},
+        "owner_id": {"x-reference": {"node_type_id": "<id>", "relationship_type": "owned_by"}}}}
+
+Writes of those fields are coerced to a canonical form and rejected if they
+cannot be: numbers sent as strings become numbers, dates become YYYY-MM-DD
+and timestamps UTC ISO 8601 (so their text order is their time order), enum
+values must be listed, and references must be IDs of existing nodes (of the
+given node type, if any). Null values are left alone.
+
+A reference with a relationship_type is also kept as a relationship from
+the node to the referenced node, so clients write only the field: NodeService
+creates the relationship when the field is set, replaces it when the field
+changes and deletes it when the field is removed, and clears the field when
+the referenced node is deleted. Those relationships carry the field's path
+in their data as {"reference_field": "data.owner_id"}.
+"""
+
+import json
+import math
+import re
+import uuid
+from dataclasses import dataclass, field
+from datetime import date, datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple
+
+REFERENCE_KEYWORD = "x-reference"
+# Relationship data key naming the reference field a relationship is kept for
+REFERENCE_FIELD_KEY = "reference_field"
+_INTEGER_PATTERN = re.compile(r"^[+-]?\d+$")
+
+
+@dataclass
+class FieldType:
+    """The declared type of a data field."""
+    kind: str
+    # Allowed values of an enum
+    values: List[Any] = field(default_factory=list)
+    # Node type a reference must point to ("" for any)
+    node_type_id: str = ""
+    # Relationship type a reference is kept as ("" for none)
+    relationship_type: str = ""
+
+
+def field_types(schema: str) -> Dict[str, FieldType]:
+    """
+    Return the typed data fields a node type schema declares, by dotted path.
+
+    Schemas that are not JSON objects declare none.
+
+    Raises:
+        ValueError: If a reference declaration is malformed
+    """
+    if not schema or not schema.strip():
+        return {}
+    try:
+        parsed = json.loads(schema)
+    except ValueError:
+        return {}
+    types: Dict[str, FieldType] = {}
+    _collect(parsed, [], types)
+    return types
+
+
+def _collect(schema: Any, path: List[str], types: Dict[str, FieldType]) -> None:
+    if not isinstance(schema, dict) or not isinstance(schema.get("properties"), dict):
+        return
+    for name, prop in schema["properties"].items():
+        if not isinstance(prop, dict):
+            continue
+        field_path = [*path, name]
+        declared = _field_type(prop, ".".join(field_path))
+        if declared:
+            types[".".join(field_path)] = declared
+        else:
+            _collect(prop, field_path, types)
+
+
+def _field_type(prop: Dict[str, Any], name: str) -> Optional[FieldType]:
+    if REFERENCE_KEYWORD in prop:
+        ref = prop[REFERENCE_KEYWORD]
+        if ref is True:
+            return FieldType("reference")
+        if not isinstance(ref, dict) or not all(
+            isinstance(ref.get(key, ""), str) for key in ("node_type_id", "relationship_type")
+        ):
+            raise ValueError(f"{name}: {REFERENCE_KEYWORD} must be true or an object of node_type_id and relationship_type")
+        unknown = sorted(set(ref) - {"node_type_id", "relationship_type"})
+        if unknown:
+            raise ValueError(f"{name}: unknown {REFERENCE_KEYWORD} keys: {', '.join(unknown)}")
+        return FieldType(
+            "reference", node_type_id=ref.get("node_type_id", ""), relationship_type=ref.get("relationship_type", "")
+        )
+    if isinstance(prop.get("enum"), list):
+        return FieldType("enum", values=prop["enum"])
+    if prop.get("format") in ("date", "date-time"):
+        return FieldType(prop["format"])
+    if prop.get("type") in ("number", "integer"):
+        return FieldType(prop["type"])
+    return None
+
+
+def coerce_value(value: Any, declared: FieldType, name: str) -> Any:
+    """
+    Return a field value in its declared type's canonical form.
+
+    References are only checked to be node IDs here; NodeService checks the nodes exist.
+
+    Raises:
+        ValueError: If the value cannot be coerced
+    """
+    if value is None:
+        return None
+    kind = declared.kind
+    if kind in ("number", "integer"):
+        return _coerce_number(value, kind == "integer", name)
+    if kind == "date":
+        if isinstance(value, str):
+            try:
+                return date.fromisoformat(value).isoformat()
+            except ValueError:
+                pass
+        raise ValueError(f"{name} must be a date (YYYY-MM-DD): {value!r}")
+    if kind == "date-time":
+        if isinstance(value, str):
+            try:
+                ts = datetime.fromisoformat(value.replace("Z", "+00:00"))
+            except ValueError:
+                pass
+            else:
+                ts = ts if ts.tzinfo else ts.replace(tzinfo=timezone.utc)
+                return ts.astimezone(timezone.utc).isoformat().replace("+00:00", "Z")
+        raise ValueError(f"{name} must be an ISO 8601 timestamp: {value!r}")
+    if kind == "enum":
+        if value not in declared.values:
+            raise ValueError(f"{name} must be one of: {', '.join(json.dumps(v) for v in declared.values)}")
+        return value
+    if kind == "reference":
+        if isinstance(value, str):
+            try:
+                return str(uuid.UUID(value))
+            except ValueError:
+                pass
+        raise ValueError(f"{name} must be a node ID: {value!r}")
+    return value
+
+
+def _coerce_number(value: Any, integer: bool, name: str) -> Any:
+    number = value
+    if isinstance(value, str):
+        text = value.strip()
+        try:
+            number = int(text) if _INTEGER_PATTERN.match(text) else float(text)
+        except ValueError:
+            number = None
+    if isinstance(number, bool) or not isinstance(number, (int, float)) or (
+        isinstance(number, float) and not math.isfinite(number)
+    ):
+        raise ValueError(f"{name} must be {'an integer' if integer else 'a number'}: {value!r}")
+    if integer:
+        if isinstance(number, float) and not number.is_integer():
+            raise ValueError(f"{name} must be an integer: {value!r}")
+        return int(number)
+    return number
+
+
+def coerce_data(data: Any, types: Dict[str, FieldType]) -> Tuple[Any, List[Tuple[str, str, FieldType]]]:
+    """
+    Coerce the typed fields of node data in place.
+
+    Returns the data and the references it holds as (field, node ID, type).
+
+    Raises:
+        ValueError: If a field cannot be coerced
+    """
+    references: List[Tuple[str, str, FieldType]] = []
+    if not isinstance(data, dict):
+        return data, references
+    for path, declared in types.items():
+        *parents, key = path.split(".")
+        container: Any = data
+        for segment in parents:
+            container = container.get(segment) if isinstance(container, dict) else None
+        if not isinstance(container, dict) or key not in container:
+            continue
+        container[key] = coerce_value(container[key], declared, f"data.{path}")
+        if declared.kind == "reference" and container[key] is not None:
+            references.append((f"data.{path}", container[key], declared))
+    return data, references
+
+
+def coerce_json(schema: str, data: str) -> Tuple[str, List[Tuple[str, str, FieldType]]]:
+    """
+    Coerce the typed fields of JSON node data; returns it (unchanged if
+    nothing was coerced) and the references it holds.
+
+    Raises:
+        ValueError: If a field cannot be coerced
+    """
+    types = field_types(schema)
+    if not types or not data:
+        return data, []
+    try:
+        parsed = json.loads(data)
+    except ValueError:
+        return data, []
+    before = json.dumps(parsed, sort_keys=True)
+    parsed, references = coerce_data(parsed, types)
+    if json.dumps(parsed, sort_keys=True) == before:
+        return data, references
+    return json.dumps(parsed), references
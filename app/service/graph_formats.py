@@ -0,0 +1,144 @@
+"""
+GraphML and Graphviz DOT serialization of exported graphs.
+
+Both formats describe a directed graph: nodes keyed by node ID and one edge
+per relationship, from source to target. GraphML carries the node type, the
+timestamps and every top-level data field as a typed "data.<field>"
+attribute (so Gephi shows them as columns); nested values are written as
+JSON text. DOT only carries labels, since Graphviz renders rather than
+analyzes.
+"""
+
+import json
+from typing import Any, Dict, List, Tuple
+from xml.sax.saxutils import escape, quoteattr
+
+from app.repository import Node, Relationship
+from app.repository.models import parse_data
+
+GRAPH_FORMATS = {
+    "graphml": "application/graphml+xml",
+    "dot": "text/vnd.graphviz",
+}
+
+
+def render_graph(format: str, nodes: List[Node], relationships: List[Relationship], label_field: str = "") -> str:
+    """
+    Serialize nodes and the relationships between them.
+
+    label_field names a top-level data field to label nodes with (default: the node ID).
+    """
+    if format == "graphml":
+        return _graphml(nodes, relationships, label_field)
+    if format == "dot":
+        return _dot(nodes, relationships, label_field)
+    raise ValueError(f"format must be one of: {', '.join(GRAPH_FORMATS)}")
+
+
+def _fields(data: str) -> Dict[str, Any]:
+    parsed = parse_data(data)
+    return parsed if isinstance(parsed, dict) else {}
+
+
+def _label(node: Node, fields: Dict[str, Any], label_field: str) -> str:
+    value = fields.get(label_field) if label_field else None
+    if value is None or isinstance(value, (dict, list)):
+        return node.id
+    return str(value)
+
+
+def _attr_type(values: List[Any]) -> str:
+    """The narrowest GraphML attribute type holding every value."""
+    if all(isinstance(v, bool) for v in values):
+        return "boolean"
+    if all(isinstance(v, int) and not isinstance(v, bool) for v in values):
+        return "long"
+    if all(isinstance(v, (int, float)) and not isinstance(v, bool) for v in values):
+        return "double"
+    return "string"
+
+
+def _attr_value(value: Any, attr_type: str) -> str:
+    if attr_type == "boolean":
+        return "true" if value else "false"
+    if attr_type in ("long", "double") or isinstance(value, str):
+        return str(value)
+    return json.dumps(value, separators=(",", ":"))
+
+
+def _keys(prefix: str, items: List[Dict[str, Any]]) -> List[Tuple[str, str, str]]:
+    """(key id, attribute name, attribute type) of every data field in items, in first-seen order."""
+    values: Dict[str, List[Any]] = {}
+    for fields in items:
+        for name, value in fields.items():
+            if value is not None:
+                values.setdefault(name, []).append(value)
+    return [(f"{prefix}{i}", name, _attr_type(vals)) for i, (name, vals) in enumerate(values.items())]
+
+
+def _graphml(nodes: List[Node], relationships: List[Relationship], label_field: str) -> str:
+    node_fields = [_fields(n.data) for n in nodes]
+    rel_fields = [_fields(r.data) for r in relationships]
+    node_keys = _keys("nd", node_fields)
+    rel_keys = _keys("ed", rel_fields)
+
+    lines = [
+        '<?xml version="1.0" encoding="UTF-8"?>',
+        '<graphml xmlns="http://graphml.graphdrawing.org/xmlns">',
+        '  <key id="label" for="node" attr.name="label" attr.type="string"/>',
+        '  <key id="node_type_id" for="node" attr.name="node_type_id" attr.type="string"/>',
+        '  <key id="created_at" for="node" attr.name="created_at" attr.type="string"/>',
+        '  <key id="updated_at" for="node" attr.name="updated_at" attr.type="string"/>',
+        '  <key id="relationship_type" for="edge" attr.name="relationship_type" attr.type="string"/>',
+    ]
+    for scope, keys in (("node", node_keys), ("edge", rel_keys)):
+        for key, name, attr_type in keys:
+            lines.append(f'  <key id="{key}" for="{scope}" attr.name={quoteattr("data." + name)} attr.type="{attr_type}"/>')
+    lines.append('  <graph id="G" edgedefault="directed">')
+
+    for node, fields in zip(nodes, node_fields):
+        lines.append(f"    <node id={quoteattr(node.id)}>")
+        lines.append(f'      <data key="label">{escape(_label(node, fields, label_field))}</data>')
+        lines.append(f'      <data key="node_type_id">{escape(node.node_type_id)}</data>')
+        lines.append(f'      <data key="created_at">{node.created_at.isoformat()}</data>')
+        lines.append(f'      <data key="updated_at">{node.updated_at.isoformat()}</data>')
+        lines += _data_elements(node_keys, fields, "      ")
+        lines.append("    </node>")
+
+    for rel, fields in zip(relationships, rel_fields):
+        lines.append(
+            f"    <edge id={quoteattr(rel.id)} source={quoteattr(rel.source_node_id)} "
+            f"target={quoteattr(rel.target_node_id)}>"
+        )
+        lines.append(f'      <data key="relationship_type">{escape(rel.relationship_type)}</data>')
+        lines += _data_elements(rel_keys, fields, "      ")
+        lines.append("    </edge>")
+
+    lines += ["  </graph>", "</graphml>", ""]
+    return "\n".join(lines)
+
+
+def _data_elements(keys: List[Tuple[str, str, str]], fields: Dict[str, Any], indent: str) -> List[str]:
+    return [
+        f'{indent}<data key="{key}">{escape(_attr_value(fields[name], attr_type))}</data>'
+        for key, name, attr_type in keys
+        if fields.get(name) is not None
+    ]
+
+
+def _dot_id(value: str) -> str:
+    return '"' + value.replace("\\", "\\\\").replace('"', '\\"').replace("\n", "\\n") + '"'
+
+
+def _dot(nodes: List[Node], relationships: List[Relationship], label_field: str) -> str:
+    lines = ["digraph tenant {"]
+    for node in nodes:
+        label = _label(node, _fields(node.data), label_field)
+        lines.append(f"  {_dot_id(node.id)} [label={_dot_id(label)}, node_type_id={_dot_id(node.node_type_id)}];")
+    for rel in relationships:
+        lines.append(
+            f"  {_dot_id(rel.source_node_id)} -> {_dot_id(rel.target_node_id)} "
+            f"[label={_dot_id(rel.relationship_type)}, id={_dot_id(rel.id)}];"
+        )
+    lines += ["}", ""]
+    return "\n".join(lines)
@@ -0,0 +1,85 @@
+"""
+Graph statistics service implementation.
+
+Statistics are expensive on large graphs, so they are cached in the tenant
+database. A missing snapshot is computed inline; a stale one is returned
+immediately while a background task recomputes it.
+"""
+
+import asyncio
+import logging
+import time
+from datetime import datetime, timezone
+from typing import Any, Dict, Set
+
+from app.metrics import metrics
+from app.repository import GraphStatsRepository
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_MAX_AGE_SECONDS = 300
+
+# Tenant databases with a background refresh in flight (keyed by pool identity)
+_refreshing: Set[int] = set()
+# Running refresh tasks, referenced so they are not garbage collected mid-run
+_tasks: Set[asyncio.Task] = set()
+
+
+class GraphStatsService:
+    """Graph statistics business logic service."""
+
+    def __init__(self, repo: GraphStatsRepository):
+        self.repo = repo
+
+    async def get(self, refresh: bool = False, max_age_seconds: int = DEFAULT_MAX_AGE_SECONDS) -> Dict[str, Any]:
+        """
+        Return graph statistics with ``computed_at`` and ``stale`` markers.
+
+        Args:
+            refresh: Recompute now instead of using the cached snapshot
+            max_age_seconds: Age after which the snapshot is refreshed in the background
+        """
+        if max_age_seconds < 0:
+            raise ValueError("max_age_seconds must be >= 0")
+
+        snapshot = None if refresh else await self.repo.get_snapshot()
+        if snapshot is None:
+            stats, computed_at = await self.refresh()
+            return _result(stats, computed_at, stale=False)
+
+        stats, computed_at = snapshot
+        age = (datetime.now(timezone.utc) - computed_at).total_seconds()
+        stale = age > max_age_seconds
+        if stale:
+            self._refresh_in_background()
+        return _result(stats, computed_at, stale)
+
+    async def refresh(self):
+        """Recompute statistics and store them as the cached snapshot."""
+        started = time.monotonic()
+        stats = await self.repo.compute()
+        computed_at = await self.repo.save_snapshot(stats)
+        metrics.observe("graph_stats_compute_seconds", time.monotonic() - started)
+        return stats, computed_at
+
+    def _refresh_in_background(self) -> None:
+        key = id(self.repo.db)
+        if key in _refreshing:
+            return
+        _refreshing.add(key)
+
+        async def run():
+            try:
+                await self.refresh()
+            except Exception as e:
+                logger.error(f"Background graph statistics refresh failed: {e}")
+            finally:
+                _refreshing.discard(key)
+
+        task = asyncio.get_running_loop().create_task(run())
+        _tasks.add(task)
+        task.add_done_callback(_tasks.discard)
+
+
+def _result(stats: Dict[str, Any], computed_at: datetime, stale: bool) -> Dict[str, Any]:
+    return {**stats, "computed_at": computed_at.isoformat(), "stale": stale}
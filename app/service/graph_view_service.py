@@ -0,0 +1,218 @@
+"""
+Graph views for visualization.
+
+A graph view is the subgraph around seed nodes (see subgraph_service.py)
+shaped for graph visualization libraries, so a UI can render it without
+reshaping raw nodes and relationships:
+
+- ``cytoscape``: Cytoscape.js elements, ``{"elements": {"nodes": [{"data",
+  "position"}], "edges": [{"data"}]}}``
+- ``d3``: ``{"nodes": [{..., "x", "y"}], "links": [{"source", "target", ...}]}``
+  for d3-force
+
+Seeds are given by ID or as a bulk operation filter. Each node carries a
+display label: the first of its label paths (per node type, or the default
+ones) with a scalar value, else its node type name, else its ID. Selected
+data paths are copied into each node's ``properties``. Edges are labeled
+with their relationship type.
+
+Positions are only computed when a layout is requested: ``circle``, ``grid``
+or ``concentric`` (rings by distance from the seeds). They are starting
+points in abstract units, ``LAYOUT_SPACING`` apart.
+"""
+
+import math
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import Node, NodeRepository, NodeTypeRepository, NotFoundError
+from app.repository.models import parse_data
+from app.service.bulk_service import parse_node_filter
+from app.service.subgraph_service import MAX_SEED_NODES, Subgraph, SubgraphService
+
+VIEW_FORMATS = ("cytoscape", "d3")
+LAYOUTS = ("circle", "grid", "concentric")
+LAYOUT_SPACING = 100.0
+# Label and data paths per view
+MAX_VIEW_PATHS = 20
+
+
+def _parse_paths(paths: Any, name: str) -> List[List[str]]:
+    """Parse data paths such as "data.contact.name" into their keys."""
+    if paths is None:
+        return []
+    if not isinstance(paths, list) or len(paths) > MAX_VIEW_PATHS:
+        raise ValueError(f"{name} must be an array of at most {MAX_VIEW_PATHS} data paths")
+    parsed = []
+    for path in paths:
+        if not isinstance(path, str) or not path.startswith("data.") or "" in path[len("data."):].split("."):
+            raise ValueError(f"{name} entries must be data paths, e.g. data.name (got {path!r})")
+        parsed.append(path[len("data."):].split("."))
+    return parsed
+
+
+def _lookup(data: Any, keys: List[str]) -> Any:
+    for key in keys:
+        if not isinstance(data, dict):
+            return None
+        data = data.get(key)
+    return data
+
+
+def _positions(order: List[str], depths: Dict[str, int], layout: str) -> Dict[str, Tuple[float, float]]:
+    """Positions of the nodes (in display order) for a layout."""
+    positions: Dict[str, Tuple[float, float]] = {}
+    if layout == "grid":
+        columns = max(1, math.ceil(math.sqrt(len(order))))
+        for i, id in enumerate(order):
+            positions[id] = ((i % columns) * LAYOUT_SPACING, (i // columns) * LAYOUT_SPACING)
+        return positions
+
+    rings: Dict[int, List[str]] = {}
+    for id in order:
+        rings.setdefault(depths[id] if layout == "concentric" else 0, []).append(id)
+    radius = 0.0
+    for depth in sorted(rings):
+        ring = rings[depth]
+        if len(ring) == 1 and depth == 0 and layout == "concentric":
+            positions[ring[0]] = (0.0, 0.0)
+            continue
+        # Far enough out that neighbors on the ring are LAYOUT_SPACING apart
+        radius = max(radius + LAYOUT_SPACING, len(ring) * LAYOUT_SPACING / (2 * math.pi))
+        for i, id in enumerate(ring):
+            angle = 2 * math.pi * i / len(ring)
+            positions[id] = (round(radius * math.cos(angle), 2), round(radius * math.sin(angle), 2))
+    return positions
+
+
+class GraphViewService:
+    """Graph visualization view business logic service."""
+
+    def __init__(self, subgraph: SubgraphService, node_repo: NodeRepository, node_type_repo: NodeTypeRepository):
+        self.subgraph = subgraph
+        self.node_repo = node_repo
+        self.node_type_repo = node_type_repo
+
+    async def view(
+        self,
+        seed_node_ids: Optional[List[str]] = None,
+        seed_filter: Optional[Dict[str, Any]] = None,
+        depth: int = 1,
+        relationship_types: Optional[List[str]] = None,
+        node_type_ids: Optional[List[str]] = None,
+        direction: str = "both",
+        max_nodes: int = 0,
+        valid_at: str = "",
+        label_paths: Optional[List[str]] = None,
+        label_paths_by_type: Optional[Dict[str, List[str]]] = None,
+        data_paths: Optional[List[str]] = None,
+        layout: str = "",
+        format: str = "cytoscape",
+    ) -> Dict[str, Any]:
+        """
+        Build the view of the subgraph around seed nodes, given by ID or by a filter.
+
+        label_paths_by_type maps node type names (or IDs) to the label paths
+        of their nodes; other nodes use label_paths.
+
+        Raises:
+            ValueError: If a parameter is invalid, or seeds are given both or neither way
+            NotFoundError: If a seed node does not exist
+        """
+        if format not in VIEW_FORMATS:
+            raise ValueError(f"format must be one of: {', '.join(VIEW_FORMATS)}")
+        if layout and layout not in LAYOUTS:
+            raise ValueError(f"layout must be one of: {', '.join(LAYOUTS)}")
+        default_labels = _parse_paths(label_paths, "label_paths")
+        if label_paths_by_type is not None and not isinstance(label_paths_by_type, dict):
+            raise ValueError("label_paths_by_type must be an object of node type names to data paths")
+        type_labels = {
+            name: _parse_paths(paths, f"label_paths_by_type.{name}")
+            for name, paths in (label_paths_by_type or {}).items()
+        }
+        properties = _parse_paths(data_paths, "data_paths")
+
+        seeds, seeds_truncated = await self._seeds(seed_node_ids, seed_filter)
+        subgraph = await self.subgraph.extract(
+            seeds, depth, relationship_types, node_type_ids, direction, max_nodes, valid_at
+        )
+        subgraph.truncated = subgraph.truncated or seeds_truncated
+
+        type_names = await self._type_names({n.node_type_id for n in subgraph.nodes})
+        nodes = []
+        for node in subgraph.nodes:
+            data = parse_data(node.data)
+            type_name = type_names.get(node.node_type_id, "")
+            paths = type_labels.get(type_name, type_labels.get(node.node_type_id, default_labels))
+            nodes.append({
+                "id": node.id,
+                "label": self._label(node, data, paths, type_name),
+                "type": type_name,
+                "node_type_id": node.node_type_id,
+                "depth": subgraph.depths[node.id],
+                "seed": subgraph.depths[node.id] == 0,
+                "properties": {"data." + ".".join(keys): _lookup(data, keys) for keys in properties},
+            })
+        # Seeds first, then by distance and label, so layouts are stable across loads
+        nodes.sort(key=lambda n: (n["depth"], n["label"], n["id"]))
+        positions = _positions([n["id"] for n in nodes], subgraph.depths, layout) if layout else {}
+        return self._render(format, nodes, subgraph, positions)
+
+    async def _seeds(
+        self, seed_node_ids: Optional[List[str]], seed_filter: Optional[Dict[str, Any]]
+    ) -> Tuple[List[str], bool]:
+        """Return the seed node IDs and whether a filter matched more than MAX_SEED_NODES."""
+        if bool(seed_node_ids) == bool(seed_filter):
+            raise ValueError("exactly one of seed_node_ids and seed_filter is required")
+        if seed_node_ids:
+            return seed_node_ids, False
+        matches = await self.node_repo.list_matching(parse_node_filter(seed_filter), "", MAX_SEED_NODES + 1)
+        if not matches:
+            raise NotFoundError("no nodes match seed_filter")
+        return [n.id for n in matches[:MAX_SEED_NODES]], len(matches) > MAX_SEED_NODES
+
+    async def _type_names(self, node_type_ids: set) -> Dict[str, str]:
+        names = {}
+        for id in node_type_ids:
+            try:
+                names[id] = (await self.node_type_repo.get_by_id(id)).name
+            except NotFoundError:
+                continue
+        return names
+
+    def _label(self, node: Node, data: Any, paths: List[List[str]], type_name: str) -> str:
+        for keys in paths:
+            value = _lookup(data, keys)
+            if value is not None and not isinstance(value, (dict, list)):
+                return str(value)
+        return type_name or node.id
+
+    def _render(
+        self, format: str, nodes: List[Dict[str, Any]], subgraph: Subgraph, positions: Dict[str, Tuple[float, float]]
+    ) -> Dict[str, Any]:
+        edges = [
+            {
+                "id": r.id,
+                "source": r.source_node_id,
+                "target": r.target_node_id,
+                "label": r.relationship_type,
+                "type": r.relationship_type,
+            }
+            for r in subgraph.relationships
+        ]
+        if format == "d3":
+            for node in nodes:
+                if node["id"] in positions:
+                    node["x"], node["y"] = positions[node["id"]]
+            return {"nodes": nodes, "links": edges, "truncated": subgraph.truncated}
+
+        elements = []
+        for node in nodes:
+            element: Dict[str, Any] = {"data": node}
+            if node["id"] in positions:
+                x, y = positions[node["id"]]
+                element["position"] = {"x": x, "y": y}
+            elements.append(element)
+        return {
+            "elements": {"nodes": elements, "edges": [{"data": edge} for edge in edges]},
+            "truncated": subgraph.truncated,
+        }
@@ -0,0 +1,135 @@
+"""
+Admin impersonation service implementation.
+"""
+
+import hashlib
+import secrets
+from datetime import datetime, timedelta, timezone
+from typing import Iterable, Optional, Tuple
+
+from app.repository import (
+    AuditEvent,
+    ImpersonationRepository,
+    ImpersonationToken,
+    PermissionDeniedError,
+    TenantRepository,
+    UserRepository,
+)
+from app.service.audit_service import AuditService
+
+TOKEN_PREFIX = "imp_"
+DEFAULT_TTL_SECONDS = 3600
+
+
+def hash_token(token: str) -> str:
+    """Hash a bearer token for storage and lookup."""
+    return hashlib.sha256(token.encode()).hexdigest()
+
+
+class ImpersonationService:
+    """Issues and resolves tokens that let admins act within a tenant."""
+
+    def __init__(
+        self,
+        repo: ImpersonationRepository,
+        tenant_repo: TenantRepository,
+        user_repo: UserRepository,
+        audit_service: AuditService,
+        admin_user_ids: Iterable[str] = (),
+        max_ttl_seconds: int = DEFAULT_TTL_SECONDS,
+    ):
+        self.repo = repo
+        self.tenant_repo = tenant_repo
+        self.user_repo = user_repo
+        self.audit_service = audit_service
+        self.admin_user_ids = frozenset(a for a in admin_user_ids if a)
+        self.max_ttl_seconds = max_ttl_seconds
+
+    def is_admin(self, subject_id: str) -> bool:
+        """Whether the subject is a configured administrator."""
+        return bool(subject_id) and subject_id in self.admin_user_ids
+
+    def require_admin(self, subject_id: str) -> None:
+        """Raise PermissionDeniedError unless the subject is an administrator."""
+        if not self.is_admin(subject_id):
+            raise PermissionDeniedError("administrator privileges required")
+
+    async def start(
+        self,
+        admin_id: str,
+        tenant_id: str,
+        reason: str,
+        user_id: str = "",
+        ttl_seconds: int = DEFAULT_TTL_SECONDS,
+        request_id: str = "",
+    ) -> Tuple[str, ImpersonationToken]:
+        """
+        Issue an impersonation token for a tenant, optionally acting as one of its users.
+
+        Returns the bearer token (shown only once) and the stored grant.
+        """
+        self.require_admin(admin_id)
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        if not reason:
+            raise ValueError("reason is required")
+        if ttl_seconds <= 0 or ttl_seconds > self.max_ttl_seconds:
+            raise ValueError(f"ttl_seconds must be between 1 and {self.max_ttl_seconds}")
+
+        tenant = await self.tenant_repo.get_by_id(tenant_id)
+        if tenant.status == "pending_deletion":
+            raise ValueError(f"tenant is pending deletion: {tenant_id}")
+        if user_id:
+            member = await self.user_repo.get_tenant_user(tenant_id, user_id)
+            if not member:
+                raise ValueError(f"user {user_id} is not a member of tenant {tenant_id}")
+
+        token = TOKEN_PREFIX + secrets.token_urlsafe(32)
+        grant = ImpersonationToken(
+            admin_user_id=admin_id,
+            tenant_id=tenant_id,
+            user_id=user_id,
+            reason=reason,
+            expires_at=datetime.now(timezone.utc) + timedelta(seconds=ttl_seconds),
+        )
+        grant = await self.repo.create(grant, hash_token(token))
+        await self._audit(grant, "start_impersonation", request_id)
+        return token, grant
+
+    async def end(self, id: str, admin_id: str, request_id: str = "") -> ImpersonationToken:
+        """Revoke an impersonation token before it expires."""
+        self.require_admin(admin_id)
+        if not id:
+            raise ValueError("id is required")
+        grant = await self.repo.revoke(id)
+        await self._audit(grant, "end_impersonation", request_id)
+        return grant
+
+    async def resolve(self, token: str, now: Optional[datetime] = None) -> ImpersonationToken:
+        """
+        Look up the active grant for a bearer token.
+
+        Raises:
+            PermissionDeniedError: If the token is unknown, revoked or expired,
+                or its admin is no longer an administrator
+        """
+        grant = await self.repo.get_by_token_hash(hash_token(token)) if token.startswith(TOKEN_PREFIX) else None
+        if not grant:
+            raise PermissionDeniedError("invalid impersonation token")
+        if grant.revoked_at is not None:
+            raise PermissionDeniedError("impersonation token has been revoked")
+        if grant.expires_at <= (now or datetime.now(timezone.utc)):
+            raise PermissionDeniedError("impersonation token has expired")
+        if not self.is_admin(grant.admin_user_id):
+            raise PermissionDeniedError("impersonation token was issued by a former administrator")
+        return grant
+
+    async def _audit(self, grant: ImpersonationToken, method: str, request_id: str) -> None:
+        await self.audit_service.record(AuditEvent(
+            request_id=request_id,
+            actor_id=grant.admin_user_id,
+            impersonated_by=grant.admin_user_id,
+            impersonation_id=grant.id,
+            tenant_id=grant.tenant_id,
+            method=method,
+        ))
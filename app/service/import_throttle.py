@@ -0,0 +1,171 @@
+"""
+Concurrency limits and adaptive backpressure for bulk imports.
+
+Node and relationship imports (node_import.py, relationship_import.py) run
+in the request that uploads them, so a few large uploads could otherwise
+take every database connection and slow down interactive calls. Imports
+are throttled in three ways:
+
+- Concurrency: at most IMPORT_MAX_CONCURRENT imports run per server
+  process, and at most the tenant's max_concurrent_imports limit per
+  tenant. An upload beyond either waits, without reading its body, for up
+  to IMPORT_QUEUE_TIMEOUT_SECONDS and is then rejected; the import stays
+  reserved and the upload can be retried.
+- Backpressure: each import times its writes. While they take more than
+  LATENCY_FACTOR times as long as its fastest writes, the database is
+  presumed busy and the import waits between writes, doubling the wait up
+  to IMPORT_MAX_DELAY_MS, and halving it again once latency recovers.
+- Pause: ``pause_import`` holds a running import before its next write
+  until ``resume_import``. A paused import keeps its upload request open
+  and its concurrency slot.
+"""
+
+import asyncio
+import logging
+import time
+from contextlib import asynccontextmanager
+from typing import AsyncIterator, Awaitable, Callable, Dict, Optional
+
+from app.metrics import metrics
+from app.repository.errors import PreconditionFailedError
+
+logger = logging.getLogger(__name__)
+
+# Server defaults (IMPORT_MAX_CONCURRENT, IMPORT_MAX_DELAY_MS, IMPORT_QUEUE_TIMEOUT_SECONDS)
+DEFAULT_MAX_CONCURRENT = 4
+DEFAULT_MAX_DELAY_SECONDS = 1.0
+DEFAULT_QUEUE_TIMEOUT_SECONDS = 300.0
+# Writes are slowed while their average latency exceeds the fastest by this factor
+LATENCY_FACTOR = 2.0
+# Weight of the latest write in the average latency
+LATENCY_WEIGHT = 0.2
+# How quickly the fastest latency follows slower writes, so a lasting shift is not mistaken for load
+BASELINE_DRIFT = 0.01
+# Writes timed before backpressure applies
+WARMUP_WRITES = 5
+# Shortest wait between writes; a shorter one is dropped
+MIN_DELAY_SECONDS = 0.01
+# How often a running import checks whether it was paused
+PAUSE_POLL_SECONDS = 1.0
+
+
+class ImportPacer:
+    """Paces the writes of one import and holds them while it is paused."""
+
+    def __init__(
+        self,
+        kind: str,
+        max_delay_seconds: float,
+        is_paused: Callable[[], Awaitable[bool]],
+        clock: Callable[[], float] = time.monotonic,
+        sleep: Callable[[float], Awaitable[None]] = asyncio.sleep,
+    ):
+        self.kind = kind
+        self.max_delay_seconds = max_delay_seconds
+        self.is_paused = is_paused
+        self.clock = clock
+        self.sleep = sleep
+        self.delay = 0.0
+        self.average: Optional[float] = None
+        self.baseline: Optional[float] = None
+        self.writes = 0
+        self._checked = clock()
+
+    @asynccontextmanager
+    async def write(self, items: int = 1) -> AsyncIterator[None]:
+        """Wait until the import may write, then time the write of items."""
+        await self.wait()
+        started = self.clock()
+        try:
+            yield
+        finally:
+            self.observe((self.clock() - started) / max(items, 1))
+
+    async def wait(self) -> None:
+        """Wait out the current delay and, if the import is paused, until it is resumed."""
+        if self.clock() - self._checked >= PAUSE_POLL_SECONDS:
+            self._checked = self.clock()
+            if await self.is_paused():
+                logger.info(f"Import ({self.kind}) paused")
+                started = self.clock()
+                while await self.is_paused():
+                    await self.sleep(PAUSE_POLL_SECONDS)
+                self._checked = self.clock()
+                metrics.inc("import_paused_seconds_total", self._checked - started, {"kind": self.kind})
+                logger.info(f"Import ({self.kind}) resumed")
+        if self.delay:
+            metrics.inc("import_throttled_seconds_total", self.delay, {"kind": self.kind})
+            await self.sleep(self.delay)
+
+    def observe(self, seconds: float) -> None:
+        """Record the latency of one written item and adjust the delay between writes."""
+        metrics.observe("import_write_seconds", seconds, {"kind": self.kind})
+        self.writes += 1
+        if self.average is None or self.baseline is None:
+            self.average = self.baseline = seconds
+        else:
+            self.average += (seconds - self.average) * LATENCY_WEIGHT
+            self.baseline = min(seconds, self.baseline + (seconds - self.baseline) * BASELINE_DRIFT)
+        if self.max_delay_seconds <= 0 or self.writes < WARMUP_WRITES:
+            return
+        if self.average > self.baseline * LATENCY_FACTOR:
+            self.delay = min(self.max_delay_seconds, max(MIN_DELAY_SECONDS, self.delay * 2))
+        else:
+            self.delay = self.delay / 2 if self.delay / 2 >= MIN_DELAY_SECONDS else 0.0
+
+
+class ImportThrottle:
+    """Limits the imports running in this process, per server and per tenant."""
+
+    def __init__(
+        self,
+        max_concurrent: int = DEFAULT_MAX_CONCURRENT,
+        max_delay_seconds: float = DEFAULT_MAX_DELAY_SECONDS,
+        queue_timeout_seconds: float = DEFAULT_QUEUE_TIMEOUT_SECONDS,
+    ):
+        """max_delay_seconds of 0 disables backpressure."""
+        self.max_concurrent = max_concurrent
+        self.max_delay_seconds = max_delay_seconds
+        self.queue_timeout_seconds = queue_timeout_seconds
+        self._running: Dict[str, int] = {}
+        self._changed = asyncio.Condition()
+
+    def running(self, tenant_id: str = "") -> int:
+        """Imports running in this process, of one tenant or of all."""
+        return self._running.get(tenant_id, 0) if tenant_id else sum(self._running.values())
+
+    @asynccontextmanager
+    async def admit(self, tenant_id: str, max_per_tenant: int) -> AsyncIterator[None]:
+        """
+        Hold an import slot of the server and of the tenant, waiting for one to free up.
+
+        Raises:
+            PreconditionFailedError: If no slot frees up within the queue timeout
+        """
+        def free() -> bool:
+            return self.running() < self.max_concurrent and self.running(tenant_id) < max_per_tenant
+
+        started = time.monotonic()
+        async with self._changed:
+            try:
+                await asyncio.wait_for(self._changed.wait_for(free), self.queue_timeout_seconds)
+            except asyncio.TimeoutError:
+                metrics.inc("import_rejections_total")
+                raise PreconditionFailedError(
+                    f"too many imports are running; retry the upload later "
+                    f"(at most {max_per_tenant} per tenant and {self.max_concurrent} per server)"
+                ) from None
+            self._running[tenant_id] = self._running.get(tenant_id, 0) + 1
+        metrics.observe("import_queue_seconds", time.monotonic() - started)
+        try:
+            yield
+        finally:
+            async with self._changed:
+                self._running[tenant_id] -= 1
+                if not self._running[tenant_id]:
+                    del self._running[tenant_id]
+                self._changed.notify_all()
+
+    def pacer(self, kind: str, is_paused: Callable[[], Awaitable[bool]]) -> ImportPacer:
+        """A pacer for the writes of one import."""
+        return ImportPacer(kind, self.max_delay_seconds, is_paused)
@@ -0,0 +1,144 @@
+"""
+Per-tenant request limits.
+
+Each tenant may override the server defaults below, and sub-tenants
+inherit their organization's overrides unless they override the same
+limit themselves; the effective limits are discoverable with
+get_tenant_limits. Limits are cached per process for
+a short time, so changes made on another server take effect after at most
+LIMITS_CACHE_SECONDS.
+"""
+
+import time
+from dataclasses import asdict, dataclass, fields
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import ListOptions, Tenant, TenantRepository
+
+# Upper bounds an administrator may raise each limit to
+LIMIT_CEILINGS = {
+    "default_page_size": 1000,
+    "max_page_size": 1000,
+    "max_traversal_depth": 1000,
+    "max_batch_size": 1000000,
+    "max_concurrent_imports": 16,
+}
+LIMITS_CACHE_SECONDS = 30.0
+# Longest query_cache_seconds an administrator may set
+QUERY_CACHE_CEILING_SECONDS = 3600
+
+
+@dataclass
+class TenantLimits:
+    """Effective limits for one tenant."""
+    # Page size used when a list call does not pass one
+    default_page_size: int = 10
+    max_page_size: int = 100
+    # Levels of relationships followed by cascading deletes
+    max_traversal_depth: int = 100
+    # Items one bulk operation may affect
+    max_batch_size: int = 100000
+    # Node and relationship imports running at once per server process (more wait for a slot)
+    max_concurrent_imports: int = 2
+    # Seconds list_nodes and search_nodes results may be served from the query cache (0 disables)
+    query_cache_seconds: int = 0
+
+    @classmethod
+    def from_overrides(cls, overrides: Optional[Dict[str, Any]]) -> "TenantLimits":
+        """Apply a tenant's stored overrides to the server defaults."""
+        limits = cls(**(overrides or {}))
+        limits.validate()
+        return limits
+
+    def validate(self) -> None:
+        """Raise ValueError unless every limit is within its ceiling and consistent."""
+        for name, ceiling in LIMIT_CEILINGS.items():
+            value = getattr(self, name)
+            if not isinstance(value, int) or isinstance(value, bool) or value < 1 or value > ceiling:
+                raise ValueError(f"{name} must be an integer between 1 and {ceiling}")
+        if self.default_page_size > self.max_page_size:
+            raise ValueError("default_page_size must not exceed max_page_size")
+        cache_seconds = self.query_cache_seconds
+        if not isinstance(cache_seconds, int) or isinstance(cache_seconds, bool) \
+                or not 0 <= cache_seconds <= QUERY_CACHE_CEILING_SECONDS:
+            raise ValueError(f"query_cache_seconds must be an integer between 0 and {QUERY_CACHE_CEILING_SECONDS}")
+
+    def list_options(self, page_size: int, page_token: str, order_by: str = "") -> ListOptions:
+        """Build list options that apply these page size limits."""
+        return ListOptions(
+            page_size=page_size,
+            page_token=page_token,
+            order_by=order_by,
+            default_page_size=self.default_page_size,
+            max_page_size=self.max_page_size,
+        )
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return asdict(self)
+
+
+LIMIT_NAMES = tuple(f.name for f in fields(TenantLimits))
+
+
+def inherit_limits(chain: List[Dict[str, Any]]) -> TenantLimits:
+    """
+    Apply overrides from the top-level organization down to the tenant
+    (the tenant's own overrides last).
+    """
+    merged: Dict[str, Any] = {}
+    for overrides in chain:
+        merged.update(overrides or {})
+    limits = TenantLimits(**merged)
+    # A lower inherited page size ceiling also caps the default page size
+    limits.default_page_size = min(limits.default_page_size, limits.max_page_size)
+    limits.validate()
+    return limits
+
+
+async def effective_limits(tenant_repo: TenantRepository, tenant: Tenant) -> TenantLimits:
+    """Return a tenant's limits with its own and its ancestors' overrides applied."""
+    ancestors = await tenant_repo.list_ancestors(tenant.id) if tenant.parent_id else []
+    return inherit_limits([a.limits for a in reversed(ancestors)] + [tenant.limits])
+
+
+def merge_overrides(current: Dict[str, Any], changes: Dict[str, Any]) -> Dict[str, Any]:
+    """Merge limit changes into stored overrides; a null value restores the default."""
+    unknown = [k for k in changes if k not in LIMIT_NAMES]
+    if unknown:
+        raise ValueError(f"unknown limits: {', '.join(unknown)} (allowed: {', '.join(LIMIT_NAMES)})")
+    merged = dict(current)
+    for name, value in changes.items():
+        if value is None:
+            merged.pop(name, None)
+        else:
+            merged[name] = value
+    TenantLimits.from_overrides(merged)
+    return merged
+
+
+class TenantLimitsCache:
+    """Caches each tenant's effective limits for LIMITS_CACHE_SECONDS."""
+
+    def __init__(self, tenant_repo: TenantRepository, ttl_seconds: float = LIMITS_CACHE_SECONDS):
+        self.tenant_repo = tenant_repo
+        self.ttl_seconds = ttl_seconds
+        self._entries: Dict[str, Tuple[float, TenantLimits]] = {}
+
+    async def get(self, tenant_id: str) -> TenantLimits:
+        """Return a tenant's effective limits."""
+        entry = self._entries.get(tenant_id)
+        if entry and time.monotonic() - entry[0] < self.ttl_seconds:
+            return entry[1]
+        tenant = await self.tenant_repo.get_by_id(tenant_id)
+        limits = await effective_limits(self.tenant_repo, tenant)
+        self._entries[tenant_id] = (time.monotonic(), limits)
+        return limits
+
+    def invalidate(self, tenant_id: str) -> None:
+        """Drop a tenant's cached limits after they change."""
+        self._entries.pop(tenant_id, None)
+
+    def clear(self) -> None:
+        """Drop all cached limits, e.g. after an organization's limits change."""
+        self._entries.clear()
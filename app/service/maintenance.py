@@ -0,0 +1,52 @@
+"""
+Tenant read-only maintenance mode.
+
+While a tenant is in maintenance (during migrations and restores), calls
+that write to it are rejected with PreconditionFailedError and the reason
+given when maintenance started; reads keep working. Every server caches
+each tenant's state for MAINTENANCE_CACHE_SECONDS, so writes can still
+land on other servers for that long after maintenance starts.
+"""
+
+import time
+from typing import Dict, Tuple
+
+from app.repository import NotFoundError, PreconditionFailedError, TenantRepository
+
+MAINTENANCE_CACHE_SECONDS = 5.0
+
+
+class TenantMaintenanceCache:
+    """Caches whether each tenant is in maintenance, and why."""
+
+    def __init__(self, tenant_repo: TenantRepository, ttl_seconds: float = MAINTENANCE_CACHE_SECONDS):
+        self.tenant_repo = tenant_repo
+        self.ttl_seconds = ttl_seconds
+        self._entries: Dict[str, Tuple[float, str]] = {}
+
+    async def reason(self, tenant_id: str) -> str:
+        """Return why a tenant is read-only, or "" when it accepts writes."""
+        entry = self._entries.get(tenant_id)
+        if entry and time.monotonic() - entry[0] < self.ttl_seconds:
+            return entry[1]
+        try:
+            tenant = await self.tenant_repo.get_by_id(tenant_id)
+        except NotFoundError:
+            # The call itself reports the unknown tenant
+            return ""
+        reason = tenant.maintenance_reason if tenant.maintenance_started_at else ""
+        self._entries[tenant_id] = (time.monotonic(), reason)
+        return reason
+
+    async def check_writable(self, tenant_id: str) -> None:
+        """
+        Raises:
+            PreconditionFailedError: If the tenant is in maintenance
+        """
+        reason = await self.reason(tenant_id)
+        if reason:
+            raise PreconditionFailedError(f"tenant {tenant_id} is read-only for maintenance: {reason}")
+
+    def invalidate(self, tenant_id: str) -> None:
+        """Drop a tenant's cached state after it changes."""
+        self._entries.pop(tenant_id, None)
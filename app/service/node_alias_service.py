@@ -0,0 +1,89 @@
+"""
+Node aliases: named secondary identifiers such as an email address, a slug
+or the ID in a legacy system, so external systems can reference nodes by
+their own identifiers. An alias (name and value) identifies at most one node
+in a tenant, and a node has at most one value per alias name.
+"""
+
+import re
+from typing import Dict, Optional
+
+from app.repository import FieldViolationError, Node, NodeAliasRepository, NotFoundError
+from app.service.node_service import NodeService
+
+MAX_ALIASES = 16
+MAX_ALIAS_VALUE_LENGTH = 512
+ALIAS_NAME_PATTERN = re.compile(r"^[a-z][a-z0-9_]{0,62}$")
+
+
+def _name_problem(name: str) -> Optional[str]:
+    if not isinstance(name, str) or not ALIAS_NAME_PATTERN.match(name):
+        return f"invalid alias name {name!r}: use up to 63 lowercase letters, digits or '_', starting with a letter"
+    return None
+
+
+def _check_name(name: str) -> None:
+    problem = _name_problem(name)
+    if problem:
+        raise FieldViolationError(problem, [("name", problem)])
+
+
+class NodeAliasService:
+    """Node alias business logic service."""
+
+    def __init__(self, repo: NodeAliasRepository, node_service: NodeService):
+        self.repo = repo
+        self.node_service = node_service
+
+    async def get(self, node_id: str) -> Dict[str, str]:
+        """Return a node's aliases by name."""
+        if not node_id:
+            raise ValueError("id is required")
+        await self.node_service.repo.get_by_id(node_id)
+        return await self.repo.list(node_id)
+
+    async def set(self, node_id: str, aliases: Dict[str, Optional[str]]) -> Dict[str, str]:
+        """
+        Set some of a node's aliases; a None value removes that alias and
+        omitted names are kept. Returns all of the node's aliases.
+
+        Raises:
+            ValueError: If an alias is invalid, already used by another node, or the node would have too many
+        """
+        if not node_id:
+            raise ValueError("id is required")
+        if not isinstance(aliases, dict) or not aliases:
+            raise ValueError("aliases must be a non-empty object of string values")
+        # Report every invalid alias at once
+        violations = []
+        for name, value in aliases.items():
+            problem = _name_problem(name)
+            if not problem and value is not None and (
+                not isinstance(value, str) or not value or len(value) > MAX_ALIAS_VALUE_LENGTH
+            ):
+                problem = f"alias {name!r} must be a string of 1 to {MAX_ALIAS_VALUE_LENGTH} characters or null"
+            if problem:
+                violations.append((f"aliases.{name}", problem))
+        if violations:
+            raise FieldViolationError("; ".join(problem for _, problem in violations), violations)
+
+        current = await self.repo.list(node_id)
+        merged = {**current, **aliases}
+        if len([v for v in merged.values() if v is not None]) > MAX_ALIASES:
+            raise ValueError(f"a node can have at most {MAX_ALIASES} aliases")
+        return await self.repo.set(node_id, aliases)
+
+    async def lookup(self, name: str, value: str) -> Node:
+        """
+        Return the node with an alias.
+
+        Raises:
+            NotFoundError: If no node has the alias
+        """
+        _check_name(name)
+        if not value:
+            raise ValueError("value is required")
+        node_id = await self.repo.find(name, value)
+        if node_id is None:
+            raise NotFoundError(f"node not found: {name}={value!r}")
+        return await self.node_service.get_by_id(node_id)
@@ -0,0 +1,384 @@
+"""
+Streaming CSV node import with field mapping.
+
+A client reserves an import of one node type with the
+``begin_node_import`` JSON-RPC method (which is subject to authorization
+policies), giving a mapping from CSV columns to node fields, then streams
+the CSV in the body of one HTTP request to the import's upload path. The
+first record is the header. ``preview_node_import`` maps a sample of the
+CSV the same way without writing anything.
+
+The mapping lists the values of each node:
+
+    {
+      "fields": [
+        {"column": "Email", "path": "contact.email"},
+        {"column": "Age", "path": "age", "type": "integer"},
+        {"column": "Customer ID", "field": "id"},
+        {"value": "crm", "path": "source"}
+      ],
+      "delimiter": ","
+    }
+
+Each entry reads a ``column`` of the header (or a static ``value``) into a
+``path`` of the node data (dot-separated) or a node ``field``: ``id`` (a
+client-generated node ID), ``valid_from``, or ``metadata.<key>``. Cells are
+converted to ``type``: string (default), integer, number, boolean, json or
+timestamp. Empty cells are left out unless the entry has a ``default``;
+entries with ``required`` reject rows where the cell is empty.
+
+Rows are created one at a time like ``create_node``, so write hooks run.
+A row that cannot be mapped or created is reported with its line number
+and the import continues with the next row. Imports are limited and paced
+so they do not slow down other calls (see import_throttle.py).
+"""
+
+import json
+import uuid
+from dataclasses import dataclass, field
+from typing import Any, AsyncIterator, Dict, List, Optional, Tuple
+
+from app.repository import NodeTypeRepository, Operation
+from app.service.csv_records import csv_records
+from app.service.import_throttle import ImportThrottle
+from app.service.limits import TenantLimits
+from app.service.node_service import NodeService
+from app.service.operation_service import OperationProgress, OperationService
+from app.service.relationship_import import MAX_REPORTED_FAILURES, RowFailure
+from app.service.timestamps import parse_timestamp
+
+IMPORT_KIND = "import_nodes"
+TYPES = ("string", "integer", "number", "boolean", "json", "timestamp")
+NODE_FIELDS = ("id", "valid_from")
+# Rows mapped by preview_node_import by default, and at most
+DEFAULT_PREVIEW_ROWS = 20
+MAX_PREVIEW_ROWS = 1000
+
+_TRUE = ("true", "t", "yes", "y", "1")
+_FALSE = ("false", "f", "no", "n", "0")
+
+
+@dataclass
+class FieldMapping:
+    """Where one value of each row comes from and where it goes."""
+    column: str = ""
+    value: Any = None
+    path: List[str] = field(default_factory=list)
+    node_field: str = ""
+    type: str = "string"
+    default: Any = None
+    required: bool = False
+
+
+@dataclass
+class ImportMapping:
+    """A validated mapping spec."""
+    fields: List[FieldMapping]
+    delimiter: str = ","
+
+    def columns(self) -> List[str]:
+        """The header columns the mapping reads."""
+        return [f.column for f in self.fields if f.column]
+
+
+def check_mapping(spec: Any) -> ImportMapping:
+    """
+    Validate a mapping spec.
+
+    Raises:
+        ValueError: If the spec is invalid
+    """
+    if not isinstance(spec, dict) or not isinstance(spec.get("fields"), list) or not spec["fields"]:
+        raise ValueError("mapping.fields must be a non-empty list")
+    unknown = [k for k in spec if k not in ("fields", "delimiter")]
+    if unknown:
+        raise ValueError(f"unknown mapping keys: {', '.join(unknown)}")
+    delimiter = spec.get("delimiter", ",")
+    if not isinstance(delimiter, str) or len(delimiter) != 1 or delimiter in ('"', "\n", "\r"):
+        raise ValueError("mapping.delimiter must be one character other than a quote or newline")
+
+    fields = []
+    for i, entry in enumerate(spec["fields"]):
+        name = f"mapping.fields[{i}]"
+        if not isinstance(entry, dict):
+            raise ValueError(f"{name} must be an object")
+        unknown = [k for k in entry if k not in ("column", "value", "path", "field", "type", "default", "required")]
+        if unknown:
+            raise ValueError(f"{name} has unknown keys: {', '.join(unknown)}")
+        if ("column" in entry) == ("value" in entry):
+            raise ValueError(f"{name} needs a column or a value, but not both")
+        if "column" in entry and (not isinstance(entry["column"], str) or not entry["column"]):
+            raise ValueError(f"{name}.column must be a non-empty string")
+        if ("path" in entry) == ("field" in entry):
+            raise ValueError(f"{name} needs a path or a field, but not both")
+
+        mapping = FieldMapping(
+            column=entry.get("column", ""),
+            value=entry.get("value"),
+            type=entry.get("type", "string"),
+            default=entry.get("default"),
+            required=bool(entry.get("required", False)),
+        )
+        if mapping.type not in TYPES:
+            raise ValueError(f"{name}.type must be one of: {', '.join(TYPES)}")
+        if "path" in entry:
+            if not isinstance(entry["path"], str) or "" in entry["path"].split("."):
+                raise ValueError(f"{name}.path must be a dot-separated data path")
+            mapping.path = entry["path"].split(".")
+        else:
+            target = entry["field"]
+            if not isinstance(target, str) or not (
+                target in NODE_FIELDS or (target.startswith("metadata.") and len(target) > len("metadata."))
+            ):
+                raise ValueError(f"{name}.field must be one of: {', '.join(NODE_FIELDS)}, metadata.<key>")
+            mapping.node_field = target
+        fields.append(mapping)
+
+    targets = [f.node_field or ".".join(f.path) for f in fields]
+    duplicates = sorted({t for t in targets if targets.count(t) > 1})
+    if duplicates:
+        raise ValueError(f"mapping sets {', '.join(duplicates)} more than once")
+    return ImportMapping(fields=fields, delimiter=delimiter)
+
+
+def _convert(text: str, type_name: str) -> Any:
+    """Convert a cell to a type."""
+    if type_name == "string":
+        return text
+    value = text.strip()
+    if type_name == "integer":
+        try:
+            return int(value)
+        except ValueError:
+            raise ValueError(f"{text!r} is not an integer") from None
+    if type_name == "number":
+        try:
+            number = float(value)
+        except ValueError:
+            raise ValueError(f"{text!r} is not a number") from None
+        return int(number) if number.is_integer() and "." not in value and "e" not in value.lower() else number
+    if type_name == "boolean":
+        if value.lower() in _TRUE:
+            return True
+        if value.lower() in _FALSE:
+            return False
+        raise ValueError(f"{text!r} is not a boolean")
+    if type_name == "json":
+        try:
+            return json.loads(value)
+        except ValueError:
+            raise ValueError(f"{text!r} is not valid JSON") from None
+    return parse_timestamp(value, "value").isoformat()
+
+
+def _set_path(data: Dict[str, Any], path: List[str], value: Any) -> None:
+    target = data
+    for key in path[:-1]:
+        target = target.setdefault(key, {})
+        if not isinstance(target, dict):
+            raise ValueError(f"{'.'.join(path)} conflicts with another mapped value")
+    if isinstance(target.get(path[-1]), dict):
+        raise ValueError(f"{'.'.join(path)} conflicts with another mapped value")
+    target[path[-1]] = value
+
+
+@dataclass
+class MappedRow:
+    """The node a CSV record maps to."""
+    data: Dict[str, Any]
+    metadata: Dict[str, Any]
+    id: str = ""
+    valid_from: str = ""
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {"id": self.id, "data": self.data, "metadata": self.metadata, "valid_from": self.valid_from}
+
+
+def map_record(mapping: ImportMapping, header: Dict[str, int], record: List[str]) -> MappedRow:
+    """
+    Map a CSV record to node values.
+
+    Raises:
+        ValueError: If a value is missing or cannot be converted
+    """
+    if len(record) > len(header):
+        raise ValueError(f"record has {len(record)} values but the header has {len(header)} columns")
+    row = MappedRow(data={}, metadata={})
+    for f in mapping.fields:
+        name = f.column or f.node_field or ".".join(f.path)
+        if f.column:
+            index = header[f.column]
+            cell = record[index] if index < len(record) else ""
+            if cell == "" or (f.type != "string" and not cell.strip()):
+                if f.required:
+                    raise ValueError(f"{f.column} is required")
+                if f.default is None:
+                    continue
+                value = f.default
+            else:
+                try:
+                    value = _convert(cell, f.type)
+                except ValueError as e:
+                    raise ValueError(f"{name}: {e}") from None
+        else:
+            value = f.value
+
+        if f.path:
+            _set_path(row.data, f.path, value)
+        elif f.node_field == "id":
+            try:
+                row.id = str(uuid.UUID(str(value)))
+            except ValueError:
+                raise ValueError(f"{name}: {value!r} is not a node ID") from None
+        elif f.node_field == "valid_from":
+            row.valid_from = str(value)
+        else:
+            row.metadata[f.node_field[len("metadata."):]] = value
+    return row
+
+
+def read_header(mapping: ImportMapping, record: List[str]) -> Dict[str, int]:
+    """
+    Index the header record by column name.
+
+    Raises:
+        ValueError: If a mapped column is missing or a column name repeats
+    """
+    header = {name.strip(): i for i, name in enumerate(record)}
+    if len(header) != len(record):
+        raise ValueError("CSV header has duplicate column names")
+    missing = [c for c in mapping.columns() if c not in header]
+    if missing:
+        raise ValueError(f"CSV header lacks mapped columns: {', '.join(missing)}")
+    return header
+
+
+class NodeImportService:
+    """Streaming CSV node import business logic service."""
+
+    def __init__(
+        self,
+        node_service: NodeService,
+        node_type_repo: NodeTypeRepository,
+        operation_service: OperationService,
+        limits: Optional[TenantLimits] = None,
+        throttle: Optional[ImportThrottle] = None,
+        tenant_id: str = "",
+    ):
+        self.node_service = node_service
+        self.node_type_repo = node_type_repo
+        self.operation_service = operation_service
+        self.limits = limits or TenantLimits()
+        self.throttle = throttle or ImportThrottle()
+        self.tenant_id = tenant_id
+
+    async def begin(self, node_type_id: str, mapping: Any) -> Operation:
+        """
+        Reserve an import of nodes of a type; its operation ID identifies the upload.
+
+        The CSV must be uploaded within the operation confirmation window.
+
+        Raises:
+            ValueError: If the mapping is invalid
+            NotFoundError: If the node type does not exist
+        """
+        if not node_type_id:
+            raise ValueError("node_type_id is required")
+        check_mapping(mapping)
+        await self.node_type_repo.get_by_id(node_type_id)
+        return await self.operation_service.prepare(
+            IMPORT_KIND, {"node_type_id": node_type_id, "mapping": mapping}, 0
+        )
+
+    async def preview(
+        self, node_type_id: str, mapping: Any, csv_text: str, limit: int = 0
+    ) -> Tuple[List[str], List[Dict[str, Any]]]:
+        """
+        Map the first rows of a CSV sample without writing anything; returns
+        the header and, per row, the node it maps to (with write hooks
+        applied) or why it would fail.
+
+        Raises:
+            ValueError: If the mapping, header or CSV is invalid
+            NotFoundError: If the node type does not exist
+        """
+        if not node_type_id:
+            raise ValueError("node_type_id is required")
+        limit = limit or DEFAULT_PREVIEW_ROWS
+        if not 1 <= limit <= MAX_PREVIEW_ROWS:
+            raise ValueError(f"limit must be between 1 and {MAX_PREVIEW_ROWS}")
+        spec = check_mapping(mapping)
+        await self.node_type_repo.get_by_id(node_type_id)
+
+        async def sample() -> AsyncIterator[bytes]:
+            yield csv_text.encode()
+
+        header_record: List[str] = []
+        header: Dict[str, int] = {}
+        rows: List[Dict[str, Any]] = []
+        async for line_number, record in csv_records(sample(), spec.delimiter):
+            if not header:
+                header_record = [c.strip() for c in record]
+                header = read_header(spec, record)
+                continue
+            try:
+                row = map_record(spec, header, record)
+                if self.node_service.hook_service:
+                    row.data = json.loads(await self.node_service.hook_service.run_pre_write(
+                        "create", node_type_id, json.dumps(row.data)
+                    ))
+                rows.append({"line": line_number, "node": row.to_dict()})
+            except ValueError as e:
+                rows.append({"line": line_number, "error": str(e)})
+            if len(rows) >= limit:
+                break
+        if not header:
+            raise ValueError("CSV has no header")
+        return header_record, rows
+
+    async def ingest(self, id: str, chunks: AsyncIterator[bytes]) -> Tuple[Operation, List[RowFailure]]:
+        """
+        Import the CSV rows of a reserved import.
+
+        Returns the finished operation and the failed rows (up to
+        MAX_REPORTED_FAILURES). A fatal error, such as a header without the
+        mapped columns or exceeding max_batch_size rows, fails the
+        operation; rows created before it stay imported.
+
+        Raises:
+            ValueError: If the import is unknown, expired or already uploaded
+            PreconditionFailedError: If no import slot frees up in time
+        """
+        if not id:
+            raise ValueError("id is required")
+        failures: List[RowFailure] = []
+
+        async def work(progress: OperationProgress) -> None:
+            pacer = self.throttle.pacer(IMPORT_KIND, lambda: self.operation_service.is_paused(progress.op.id))
+            node_type_id = progress.op.params["node_type_id"]
+            spec = check_mapping(progress.op.params["mapping"])
+            header: Dict[str, int] = {}
+            async for line_number, record in csv_records(chunks, spec.delimiter):
+                if not header:
+                    header = read_header(spec, record)
+                    continue
+                if progress.op.processed_count >= self.limits.max_batch_size:
+                    raise ValueError(f"import exceeds {self.limits.max_batch_size} rows (max_batch_size)")
+                try:
+                    row = map_record(spec, header, record)
+                    async with pacer.write():
+                        await self.node_service.create(
+                            node_type_id, json.dumps(row.data), row.valid_from, row.metadata, row.id
+                        )
+                    progress.succeeded()
+                except Exception as e:
+                    progress.failed(f"line {line_number}", e)
+                    if len(failures) < MAX_REPORTED_FAILURES:
+                        failures.append(RowFailure(line_number, str(e)))
+                await progress.checkpoint()
+            if not header:
+                raise ValueError("CSV has no header")
+
+        async with self.throttle.admit(self.tenant_id, self.limits.max_concurrent_imports):
+            return await self.operation_service.run(id, IMPORT_KIND, work), failures
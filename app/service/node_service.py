@@ -2,63 +2,641 @@
 Node service implementation.
 """
 
-from typing import List, Optional, Tuple
+import json
+import logging
+import math
+import re
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple
 
-from app.repository import Node, NodeRepository, NodeTypeRepository, ListOptions, ListResult
+from app.repository import (
+    Node,
+    NodeVersion,
+    NodeProtectionRepository,
+    NodeRepository,
+    NodeTypeRepository,
+    RelationshipRepository,
+    RelationshipTypeRepository,
+    FacetValue,
+    ListResult,
+    MetadataUpdate,
+    NodeFilter,
+    NotFoundError,
+    PreconditionFailedError,
+    Relationship,
+)
+from app.service.attachment_service import AttachmentService
+from app.service.client_ids import parse_client_id
+from app.service.data_migrations import DataMigrationService
+from app.service.field_types import REFERENCE_FIELD_KEY, coerce_json, field_types
+from app.service.limits import TenantLimits
+from app.service.patching import apply_patch
+from app.service.preconditions import check_if_match
+from app.service.timestamps import check_date_interval, check_timezone, parse_local_timestamp, parse_timestamp
+from app.service.write_hook_service import WriteHookService
+
+logger = logging.getLogger(__name__)
+
+# Upper bound on nodes removed by one cascading delete
+MAX_CASCADE_NODES = 10000
+# Attempts to apply a patch when concurrent writes keep changing the node
+PATCH_ATTEMPTS = 3
+# Upper bound on the JSON-encoded size of metadata sent in one write
+MAX_METADATA_BYTES = 65536
+# Segment of a dotted data field path, as accepted by order_by
+FIELD_SEGMENT = re.compile(r"^[A-Za-z0-9_\-]+$")
 
 
 class NodeService:
     """Node business logic service."""
 
-    def __init__(self, repo: NodeRepository, node_type_repo: NodeTypeRepository):
+    def __init__(
+        self,
+        repo: NodeRepository,
+        node_type_repo: NodeTypeRepository,
+        hook_service: Optional[WriteHookService] = None,
+        attachment_service: Optional[AttachmentService] = None,
+        relationship_repo: Optional[RelationshipRepository] = None,
+        rel_type_repo: Optional[RelationshipTypeRepository] = None,
+        limits: Optional[TenantLimits] = None,
+        data_migrations: Optional[DataMigrationService] = None,
+        protection_repo: Optional[NodeProtectionRepository] = None,
+    ):
         self.repo = repo
+        self.limits = limits or TenantLimits()
+        # Migrates node data read at an older schema version
+        self.data_migrations = data_migrations
         self.node_type_repo = node_type_repo
+        self.hook_service = hook_service
+        self.attachment_service = attachment_service
+        # Needed to apply per relationship type delete rules
+        self.relationship_repo = relationship_repo
+        self.rel_type_repo = rel_type_repo
+        # Nodes protected from deletion without force
+        self.protection_repo = protection_repo
+
+    async def create(
+        self,
+        node_type_id: str,
+        data: str,
+        valid_from: str = "",
+        metadata: Optional[Dict[str, Any]] = None,
+        id: str = "",
+    ) -> Node:
+        """
+        Create a new node, valid from valid_from (ISO 8601, default: now).
+
+        id is an optional client-generated UUID for the node.
 
-    async def create(self, node_type_id: str, data: str) -> Node:
-        """Create a new node."""
+        Raises:
+            AlreadyExistsError: If a node with that id exists or existed
+        """
         if not node_type_id:
             raise ValueError("node_type_id is required")
+        id = parse_client_id(id)
+        effective = _parse_effective_time(valid_from)
+        _check_metadata(metadata)
 
         # Validate that the node type exists (repository is already scoped to tenant database)
         node_type = await self.node_type_repo.get_by_id(node_type_id)
 
+        if self.hook_service:
+            data = await self.hook_service.run_pre_write("create", node_type_id, data)
+        data = await self._coerce_fields(node_type.schema, data)
+
         node = Node(
+            id=id,
             tenant_id="",  # Not stored in tenant database
             node_type_id=node_type_id,
             data=data,
+            metadata={k: v for k, v in (metadata or {}).items() if v is not None},
+            schema_version=node_type.schema_version,
         )
-        return await self.repo.create(node)
+        node = await self.repo.create(node, effective)
+        node = await self._run_post_write("create", node, None, effective)
+        await self._sync_references(node, node_type.schema)
+        return node
 
     async def get_by_id(self, id: str) -> Node:
-        """Retrieve a node by ID."""
+        """Retrieve a node by ID, with its data migrated to the node type's schema version."""
+        if not id:
+            raise ValueError("id is required")
+        node = await self.repo.get_by_id(id)
+        if self.data_migrations:
+            await self.data_migrations.migrate([node])
+        return node
+
+    async def update(
+        self,
+        id: str,
+        data: str,
+        valid_from: str = "",
+        if_match: str = "",
+        metadata: Optional[Dict[str, Any]] = None,
+        update_mask: Optional[List[str]] = None,
+    ) -> Node:
+        """
+        Update an existing node, with the new data valid from valid_from (ISO 8601, default: now).
+
+        With if_match the update only applies if the node's current etag matches.
+        Metadata is merged (a None value removes the key) unless update_mask
+        selects what to write; see _resolve_update_mask. Metadata-only updates
+        skip write hooks and leave the data, its versions and the etag unchanged.
+
+        Raises:
+            PreconditionFailedError: If the node changed since if_match was read
+        """
+        if not id:
+            raise ValueError("id is required")
+        effective = _parse_effective_time(valid_from)
+        write_data, metadata_update = _resolve_update_mask(data, metadata, update_mask)
+
+        node = await self.get_by_id(id)
+        expected_updated_at = check_if_match(node.etag, node.updated_at, if_match, f"node {id}")
+        if metadata_update and not write_data:
+            return await self.repo.update_metadata(id, metadata_update, expected_updated_at)
+        previous = node.data
+
+        if write_data:
+            if self.hook_service:
+                data = await self.hook_service.run_pre_write(
+                    "update", node.node_type_id, data, previous, node.id
+                )
+            node_type = await self.node_type_repo.get_by_id(node.node_type_id)
+            node.data = await self._coerce_fields(node_type.schema, data)
+        # Data read at an older schema version was migrated on read, so it is stored migrated
+        node.schema_version = max(node.schema_version, node.latest_schema_version)
+
+        node = await self.repo.update(node, effective, expected_updated_at, metadata_update)
+        if write_data:
+            node = await self._run_post_write("update", node, previous, effective)
+            await self._sync_references(node, node_type.schema)
+        return node
+
+    async def patch(
+        self,
+        id: str,
+        patch: Any,
+        valid_from: str = "",
+        if_match: str = "",
+        metadata: Optional[Dict[str, Any]] = None,
+    ) -> Node:
+        """
+        Apply a JSON patch (RFC 6902 array) or merge patch (RFC 7386 object) to a node's data.
+
+        The patch is applied to the current data and written only if the
+        node is unchanged meanwhile; without if_match, a concurrent write
+        makes the patch re-apply to the newer data instead of overwriting it.
+
+        Raises:
+            ValueError: If the patch is malformed or cannot be applied
+            PreconditionFailedError: If the node changed since if_match was read
+        """
         if not id:
             raise ValueError("id is required")
-        return await self.repo.get_by_id(id)
 
-    async def update(self, id: str, data: str) -> Node:
-        """Update an existing node."""
+        attempts = 0
+        while True:
+            node = await self.get_by_id(id)
+            check_if_match(node.etag, node.updated_at, if_match, f"node {id}")
+            patched = apply_patch(json.loads(node.data or "{}"), patch)
+            try:
+                return await self.update(id, json.dumps(patched), valid_from, node.etag, metadata)
+            except PreconditionFailedError:
+                attempts += 1
+                if if_match or attempts >= PATCH_ATTEMPTS:
+                    raise
+
+    async def increment_field(
+        self, id: str, field: str, delta: Any = 1, valid_from: str = ""
+    ) -> Tuple[Node, Any]:
+        """
+        Atomically add delta to a numeric data field (e.g. "data.stats.views"),
+        without a read-modify-write round trip or etag check in the client.
+
+        A missing field starts from 0. Write hooks do not run; each increment
+        is recorded in the node's history like any other write.
+        Returns the node and the field's new value.
+
+        Raises:
+            ValueError: If the field path or delta is invalid, or the field is not a number
+        """
         if not id:
             raise ValueError("id is required")
+        if not field.startswith("data."):
+            raise ValueError("field must be a data path such as data.views")
+        path = field[len("data."):].split(".")
+        if not all(FIELD_SEGMENT.match(segment) for segment in path):
+            raise ValueError(f"invalid data path: {field}")
+        if isinstance(delta, bool) or not isinstance(delta, (int, float)) or not math.isfinite(delta):
+            raise ValueError("delta must be a finite number")
+        effective = _parse_effective_time(valid_from)
+
+        if self.data_migrations:
+            # Store data read at an older schema version migrated, so the path applies to the current shape
+            node = await self.repo.get_by_id(id)
+            from_version = node.schema_version
+            await self.data_migrations.migrate([node])
+            if node.schema_version != from_version:
+                await self.repo.store_migrated_data(node, from_version)
+
+        return await self.repo.increment_field(id, path, delta, effective)
+
+    async def delete(self, id: str, force: bool = False) -> List[str]:
+        """
+        Delete a node and the stored content of its attachments, applying
+        the delete rules of registered relationship types.
+
+        Returns the IDs of every deleted node (the node first, then cascades).
+
+        Raises:
+            ValueError: If a "restrict" relationship blocks the delete
+            PreconditionFailedError: If the node or a cascaded node is protected and force is not given
+        """
+        if not id:
+            raise ValueError("id is required")
+
+        ids = await self._plan_delete(id)
+        if self.protection_repo and not force:
+            protected = await self.protection_repo.list_protected(ids)
+            if protected:
+                which = "" if protected == [id] else f" (cascading to protected node {protected[0]})"
+                raise PreconditionFailedError(f"node {id} is protected from deletion{which}; delete it with force")
+        referencing = []
+        if self.relationship_repo:
+            deleted = set(ids)
+            referencing = [
+                r for r in await self.relationship_repo.list_to_targets(ids)
+                if r.source_node_id not in deleted and _reference_field(r)
+            ]
+        if self.attachment_service:
+            for node_id in ids:
+                await self.attachment_service.delete_for_node(node_id)
+        if len(ids) == 1:
+            await self.repo.delete(id)
+        else:
+            await self.repo.delete_many(ids)
+        await self._clear_references(referencing)
+        return ids
+
+    async def is_protected(self, id: str) -> bool:
+        """Whether a node is protected from deletion."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.get_by_id(id)
+        return bool(self.protection_repo and await self.protection_repo.list_protected([id]))
+
+    async def set_protected(self, id: str, protected: bool) -> None:
+        """
+        Protect a node from deletion, or remove its protection.
+
+        Raises:
+            NotFoundError: If the node does not exist
+        """
+        if not id:
+            raise ValueError("id is required")
+        if not self.protection_repo:
+            raise ValueError("node protection is not configured")
+        if protected:
+            await self.protection_repo.protect(id)
+        else:
+            await self.repo.get_by_id(id)
+            await self.protection_repo.unprotect(id)
+
+    async def _plan_delete(self, id: str) -> List[str]:
+        """Resolve the nodes removed by deleting a node, following "delete_node" rules."""
+        if not self.relationship_repo or not self.rel_type_repo:
+            return [id]
+
+        await self.repo.get_by_id(id)
+        deleting = [id]
+        seen = {id}
+        relationships = {}
+        rules = {}
+        frontier = [id]
+        depth = 0
+
+        while frontier:
+            if depth >= self.limits.max_traversal_depth:
+                raise ValueError(
+                    f"delete would cascade deeper than {self.limits.max_traversal_depth} levels"
+                )
+            depth += 1
+            touching = await self.relationship_repo.list_touching(frontier)
+            new_types = list({r.relationship_type for r in touching} - rules.keys())
+            registered = {t.name: t for t in await self.rel_type_repo.get_by_names(new_types)} if new_types else {}
+            rules.update({name: registered.get(name) for name in new_types})
+
+            in_frontier = set(frontier)
+            frontier = []
+            for rel in touching:
+                relationships[rel.id] = rel
+                rule = rules.get(rel.relationship_type)
+                if not rule:
+                    continue
+                for deleted, other, action in (
+                    (rel.source_node_id, rel.target_node_id, rule.on_source_delete),
+                    (rel.target_node_id, rel.source_node_id, rule.on_target_delete),
+                ):
+                    if action == "delete_node" and deleted in in_frontier and other not in seen:
+                        seen.add(other)
+                        deleting.append(other)
+                        frontier.append(other)
+
+            if len(deleting) > MAX_CASCADE_NODES:
+                raise ValueError(f"delete would cascade to more than {MAX_CASCADE_NODES} nodes")
+
+        # Restrictions only apply to relationships that would outlive one of their endpoints
+        for rel in relationships.values():
+            rule = rules.get(rel.relationship_type)
+            if not rule:
+                continue
+            if rel.source_node_id in seen and rel.target_node_id in seen:
+                continue
+            if rel.source_node_id in seen and rule.on_source_delete == "restrict":
+                raise ValueError(
+                    f"cannot delete node {rel.source_node_id}: {rel.relationship_type} relationship {rel.id} restricts deletion"
+                )
+            if rel.target_node_id in seen and rule.on_target_delete == "restrict":
+                raise ValueError(
+                    f"cannot delete node {rel.target_node_id}: {rel.relationship_type} relationship {rel.id} restricts deletion"
+                )
+
+        return deleting
+
+    async def correct(self, id: str, data: str, valid_from: str, valid_to: str = "") -> NodeVersion:
+        """
+        Record corrected data for a past, present or future valid-time interval.
+
+        Data outside [valid_from, valid_to) is unaffected; the superseded
+        versions stay queryable by recorded time.
+        """
+        if not id:
+            raise ValueError("id is required")
+        start = parse_timestamp(valid_from, "valid_from")
+        if start is None:
+            raise ValueError("valid_from is required")
+        end = parse_timestamp(valid_to, "valid_to")
+        if end is not None and end <= start:
+            raise ValueError("valid_to must be after valid_from")
 
         node = await self.repo.get_by_id(id)
+        if self.hook_service:
+            data = await self.hook_service.run_pre_write(
+                "update", node.node_type_id, data, node.data, node.id
+            )
+        node_type = await self.node_type_repo.get_by_id(node.node_type_id)
+        data = await self._coerce_fields(node_type.schema, data)
+        return await self.repo.record_correction(id, node.node_type_id, data, start, end)
 
-        if data:
-            node.data = data
+    async def get_as_of(self, id: str, valid_at: str = "", recorded_at: str = "") -> NodeVersion:
+        """Retrieve a node as valid at valid_at, as recorded at recorded_at (both default to now)."""
+        if not id:
+            raise ValueError("id is required")
+        valid, recorded = _as_of_times(valid_at, recorded_at)
+        return await self.repo.get_as_of(id, valid, recorded)
 
-        return await self.repo.update(node)
+    async def list_as_of(
+        self,
+        node_type_id: Optional[str],
+        valid_at: str,
+        recorded_at: str,
+        page_size: int,
+        page_token: str,
+        order_by: str = ""
+    ) -> Tuple[List[NodeVersion], ListResult]:
+        """Retrieve nodes as valid at valid_at, as recorded at recorded_at."""
+        valid, recorded = _as_of_times(valid_at, recorded_at)
+        opts = self.limits.list_options(page_size, page_token, order_by)
+        return await self.repo.list_as_of(node_type_id, valid, recorded, opts)
 
-    async def delete(self, id: str) -> None:
-        """Delete a node."""
+    async def history(self, id: str, page_size: int, page_token: str) -> Tuple[List[NodeVersion], ListResult]:
+        """Retrieve every recorded version of a node."""
         if not id:
             raise ValueError("id is required")
-        await self.repo.delete(id)
+        opts = self.limits.list_options(page_size, page_token)
+        return await self.repo.list_versions(id, opts)
 
     async def list(
         self,
         node_type_id: Optional[str],
         page_size: int,
-        page_token: str
+        page_token: str,
+        order_by: str = "",
+        include_data: bool = True,
+        metadata: Optional[Dict[str, Any]] = None,
+        filters: Optional[NodeFilter] = None,
     ) -> Tuple[List[Node], ListResult]:
-        """Retrieve nodes with pagination and optional filtering."""
-        opts = ListOptions(page_size=page_size, page_token=page_token)
-        return await self.repo.list(node_type_id, opts)
+        """
+        Retrieve nodes with pagination and optional filtering (metadata: object the
+        metadata must contain; filters: a whole node filter, such as a view's, replacing both).
+        """
+        if metadata is not None and not isinstance(metadata, dict):
+            raise ValueError("metadata filter must be a JSON object")
+        opts = self.limits.list_options(page_size, page_token, order_by)
+        nodes, result = await self.repo.list(node_type_id, opts, include_data, metadata or None, filters)
+        if self.data_migrations and include_data:
+            await self.data_migrations.migrate(nodes)
+        return nodes, result
+
+    async def distinct_values(
+        self,
+        field: str,
+        node_type_id: Optional[str],
+        limit: int
+    ) -> List[FacetValue]:
+        """Count nodes per distinct value of a field (for faceted filters)."""
+        if not field:
+            raise ValueError("field is required")
+        return await self.repo.distinct_values(field, node_type_id, limit)
+
+    async def date_histogram(
+        self,
+        field: str,
+        node_type_id: Optional[str],
+        interval: str = "day",
+        time_zone: str = "UTC",
+        start: str = "",
+        end: str = "",
+    ) -> List[FacetValue]:
+        """
+        Count nodes per calendar interval (e.g. per day) of created_at or
+        updated_at, with intervals local to time_zone so that daily counts
+        match the tenant's days. start and end (ISO 8601 timestamps, or dates
+        meaning local midnight) bound the counted range.
+        """
+        if not field:
+            raise ValueError("field is required")
+        check_date_interval(interval)
+        check_timezone(time_zone)
+        start_ts = parse_local_timestamp(start, "start", time_zone)
+        end_ts = parse_local_timestamp(end, "end", time_zone)
+        return await self.repo.date_histogram(field, node_type_id, interval, time_zone, start_ts, end_ts)
+
+    async def _coerce_fields(self, schema: str, data: str) -> str:
+        """
+        Coerce the data fields the node type schema types (see field_types.py)
+        and check that its references point to existing nodes.
+
+        Raises:
+            ValueError: If a field cannot be coerced or references a missing node
+        """
+        data, references = coerce_json(schema, data)
+        for name, node_id, declared in references:
+            try:
+                target = await self.repo.get_by_id(node_id)
+            except NotFoundError:
+                raise ValueError(f"{name} references a missing node: {node_id}") from None
+            if declared.node_type_id and target.node_type_id != declared.node_type_id:
+                raise ValueError(f"{name} must reference a node of type {declared.node_type_id}")
+        return data
+
+    async def _sync_references(self, node: Node, schema: str) -> None:
+        """
+        Keep the relationships of a node's reference fields that have a
+        relationship type (see field_types.py) in line with its data.
+        """
+        managed = {
+            f"data.{path}": declared for path, declared in field_types(schema).items()
+            if declared.kind == "reference" and declared.relationship_type
+        }
+        if not managed or not self.relationship_repo:
+            return
+        try:
+            _, references = coerce_json(schema, node.data)
+        except ValueError:
+            # Post-write hooks enriched the field with something else; it is not a reference then
+            references = []
+        wanted = {
+            (name, node_id, declared.relationship_type)
+            for name, node_id, declared in references if name in managed
+        }
+        existing = {}
+        for rel in await self.relationship_repo.list_from_sources([node.id]):
+            name = _reference_field(rel)
+            if name:
+                existing[(name, rel.target_node_id, rel.relationship_type)] = rel
+        for key, rel in existing.items():
+            if key not in wanted:
+                await self.relationship_repo.delete(rel.id)
+        for name, node_id, relationship_type in wanted - existing.keys():
+            await self.relationship_repo.create(Relationship(
+                source_node_id=node.id,
+                target_node_id=node_id,
+                relationship_type=relationship_type,
+                data=json.dumps({REFERENCE_FIELD_KEY: name}),
+            ))
+
+    async def _clear_references(self, relationships: List[Relationship]) -> None:
+        """Remove the reference fields of deleted nodes' referencing relationships from their sources."""
+        for rel in relationships:
+            *parents, key = _reference_field(rel)[len("data."):].split(".")
+            patch: Dict[str, Any] = {key: None}
+            for segment in reversed(parents):
+                patch = {segment: patch}
+            try:
+                await self.patch(rel.source_node_id, patch)
+            except (NotFoundError, ValueError) as e:
+                logger.warning(
+                    "could not clear %s of node %s: %s", _reference_field(rel), rel.source_node_id, e
+                )
+
+    async def _run_post_write(
+        self,
+        operation: str,
+        node: Node,
+        previous: Optional[str],
+        valid_from: Optional[datetime] = None,
+    ) -> Node:
+        """Run post-write hooks and persist any enrichment they produce."""
+        if not self.hook_service:
+            return node
+        enriched = await self.hook_service.run_post_write(
+            operation, node.id, node.node_type_id, node.data, previous
+        )
+        if enriched is None:
+            return node
+        node.data = enriched
+        return await self.repo.update(node, valid_from)
+
+
+def _reference_field(rel: Relationship) -> str:
+    """The reference field a relationship is kept for, or "" if it was written directly."""
+    try:
+        data = json.loads(rel.data or "{}")
+    except ValueError:
+        return ""
+    name = data.get(REFERENCE_FIELD_KEY) if isinstance(data, dict) else None
+    return name if isinstance(name, str) and name.startswith("data.") else ""
+
+
+def _check_metadata(metadata: Optional[Dict[str, Any]]) -> None:
+    if metadata is None:
+        return
+    if not isinstance(metadata, dict):
+        raise ValueError("metadata must be a JSON object")
+    if len(json.dumps(metadata).encode()) > MAX_METADATA_BYTES:
+        raise ValueError(f"metadata must be at most {MAX_METADATA_BYTES} bytes")
+
+
+def _resolve_update_mask(
+    data: str,
+    metadata: Optional[Dict[str, Any]],
+    update_mask: Optional[List[str]],
+) -> Tuple[bool, Optional[MetadataUpdate]]:
+    """
+    Decide which parts of a node an update writes: whether the data, and how the metadata.
+
+    Without an update mask, non-empty data is written and metadata is merged.
+    With one, only the listed paths are written, even if other parts are given:
+    "data" replaces the data, "metadata" replaces all metadata and
+    "metadata.<key>" sets that key (or removes it if metadata lacks it).
+    """
+    _check_metadata(metadata)
+    metadata = metadata or {}
+    if not update_mask:
+        if not metadata:
+            return bool(data), None
+        return bool(data), MetadataUpdate(
+            set={k: v for k, v in metadata.items() if v is not None},
+            remove=[k for k, v in metadata.items() if v is None],
+        )
+
+    write_data = False
+    update: Optional[MetadataUpdate] = None
+    for path in update_mask:
+        if path == "data":
+            if not data:
+                raise ValueError("update_mask includes data but no data was given")
+            write_data = True
+        elif path == "metadata" or path.startswith("metadata.") and len(path) > len("metadata."):
+            if update is not None and (update.replace or path == "metadata"):
+                raise ValueError("update_mask cannot combine metadata with metadata.<key> paths")
+            if path == "metadata":
+                update = MetadataUpdate(set={k: v for k, v in metadata.items() if v is not None}, replace=True)
+                continue
+            update = update or MetadataUpdate()
+            key = path[len("metadata."):]
+            if metadata.get(key) is None:
+                update.remove.append(key)
+            else:
+                update.set[key] = metadata[key]
+        else:
+            raise ValueError(f"unknown update_mask path {path!r} (allowed: data, metadata, metadata.<key>)")
+    return write_data, update
+
+
+def _parse_effective_time(valid_from: str) -> Optional[datetime]:
+    """Parse the valid-from time of a regular write, which may be backdated but not future-dated."""
+    effective = parse_timestamp(valid_from, "valid_from")
+    if effective is not None and effective > datetime.now(timezone.utc):
+        raise ValueError("valid_from cannot be in the future; use correct_node for future-dated changes")
+    return effective
+
+
+def _as_of_times(valid_at: str, recorded_at: str) -> Tuple[datetime, datetime]:
+    now = datetime.now(timezone.utc)
+    return (
+        parse_timestamp(valid_at, "valid_at") or now,
+        parse_timestamp(recorded_at, "recorded_at") or now,
+    )
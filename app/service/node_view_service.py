@@ -0,0 +1,182 @@
+"""
+Node views: named, parameterized slices of a tenant's nodes.
+
+A view is a node filter (the bulk operation filter keys, see
+bulk_service.parse_node_filter) and an optional projection (an export
+transform, see export_transforms.py), stored under a name. ``list_nodes``,
+``search_nodes`` and ``export_tenant`` read a view like a read-only
+collection by passing ``view``, so common slices such as "active customers"
+are defined once instead of in every client:
+
+    {"name": "active-customers",
+     "filter": {"node_type_id": "<id>", "data": {"status": "active", "region": "$region"}},
+     "projection": {"include": ["data.name", "data.email"]},
+     "parameters": {"region": "eu"}}
+
+Filter values of the form ``"$name"`` are placeholders for the call's
+``view_params``, declared in ``parameters`` with their defaults like saved
+query placeholders. A call's own filters narrow the view further; they
+cannot contradict it.
+"""
+
+import re
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import ListResult, NodeFilter, NodeView, NodeViewRepository
+from app.service.bulk_service import FILTER_KEYS, parse_node_filter
+from app.service.export_transforms import parse_transform
+from app.service.limits import TenantLimits
+from app.service.saved_query_service import PARAMETER_PATTERN, placeholders, substitute
+
+NAME_PATTERN = re.compile(r"^[a-z][a-z0-9_.-]{0,127}$")
+# Filter keys whose values are objects of conditions on separate fields, so narrowing merges them
+_CONTAINMENT_KEYS = ("data", "metadata", "ranges")
+
+
+@dataclass
+class ResolvedView:
+    """A view with its parameters substituted."""
+    view: NodeView
+    filter: Dict[str, Any] = field(default_factory=dict)
+    projection: Dict[str, Any] = field(default_factory=dict)
+
+    def node_filter(self) -> NodeFilter:
+        return parse_node_filter(self.filter)
+
+
+def combine_filters(view_filter: Dict[str, Any], narrowing: Dict[str, Any]) -> Dict[str, Any]:
+    """
+    Narrow a view's filter with a call's filter keys (None values are ignored).
+
+    Raises:
+        ValueError: If a key contradicts the view
+    """
+    combined = dict(view_filter)
+    for key, value in narrowing.items():
+        if value is None or value == {} or value == "":
+            continue
+        if key not in combined:
+            combined[key] = value
+        elif key in _CONTAINMENT_KEYS and isinstance(value, dict) and isinstance(combined[key], dict):
+            clashes = [k for k in value if k in combined[key] and combined[key][k] != value[k]]
+            if clashes:
+                raise ValueError(f"{key}.{clashes[0]} contradicts the view's filter")
+            combined[key] = {**combined[key], **value}
+        elif combined[key] != value:
+            raise ValueError(f"{key} contradicts the view's filter")
+    return combined
+
+
+class NodeViewService:
+    """Node view business logic service."""
+
+    def __init__(self, repo: NodeViewRepository, limits: Optional[TenantLimits] = None):
+        self.repo = repo
+        self.limits = limits or TenantLimits()
+
+    async def create(
+        self,
+        name: str,
+        filter: Optional[Dict[str, Any]] = None,
+        projection: Optional[Dict[str, Any]] = None,
+        parameters: Optional[Dict[str, Any]] = None,
+        description: str = "",
+    ) -> NodeView:
+        """
+        Define a view.
+
+        Raises:
+            ValueError: If the definition is invalid
+            AlreadyExistsError: If a view has the name
+        """
+        view = NodeView(
+            name=name, description=description or "", filter=filter or {},
+            projection=projection or {}, parameters=parameters or {},
+        )
+        _validate(view)
+        return await self.repo.create(view)
+
+    async def get_by_id(self, id: str) -> NodeView:
+        """Retrieve a view by ID."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_by_id(id)
+
+    async def update(
+        self,
+        id: str,
+        name: str = "",
+        filter: Optional[Dict[str, Any]] = None,
+        projection: Optional[Dict[str, Any]] = None,
+        parameters: Optional[Dict[str, Any]] = None,
+        description: Optional[str] = None,
+    ) -> NodeView:
+        """Update a view; omitted fields are kept."""
+        view = await self.get_by_id(id)
+        if name:
+            view.name = name
+        if filter is not None:
+            view.filter = filter
+        if projection is not None:
+            view.projection = projection
+        if parameters is not None:
+            view.parameters = parameters
+        if description is not None:
+            view.description = description
+        _validate(view)
+        return await self.repo.update(view)
+
+    async def delete(self, id: str) -> None:
+        """Delete a view."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete(id)
+
+    async def list(self, page_size: int, page_token: str) -> Tuple[List[NodeView], ListResult]:
+        """Retrieve views by name."""
+        return await self.repo.list(self.limits.list_options(page_size, page_token))
+
+    async def resolve(self, name: str, args: Optional[Dict[str, Any]] = None) -> ResolvedView:
+        """
+        Return a view with the call's parameters substituted into its filter.
+
+        Raises:
+            NotFoundError: If no view has the name
+            ValueError: If an argument is unknown or a required one is missing
+        """
+        args = args or {}
+        if not isinstance(args, dict):
+            raise ValueError("view_params must be an object")
+        view = await self.repo.get_by_name(name)
+        unknown = sorted(set(args) - set(view.parameters))
+        if unknown:
+            raise ValueError(f"unknown parameters of view {name}: {', '.join(unknown)}")
+        values = {**{k: v for k, v in view.parameters.items() if v is not None}, **args}
+        missing = sorted(set(view.parameters) - set(values))
+        if missing:
+            raise ValueError(f"missing parameters of view {name}: {', '.join(missing)}")
+        return ResolvedView(view, substitute(view.filter, values), view.projection)
+
+
+def _validate(view: NodeView) -> None:
+    if not NAME_PATTERN.match(view.name or ""):
+        raise ValueError("name must be up to 128 lowercase letters, digits, '_', '.' or '-', starting with a letter")
+    if not isinstance(view.filter, dict):
+        raise ValueError("filter must be an object")
+    unknown = [k for k in view.filter if k not in FILTER_KEYS]
+    if unknown:
+        raise ValueError(f"unknown filter keys: {', '.join(unknown)} (allowed: {', '.join(FILTER_KEYS)})")
+    if not isinstance(view.parameters, dict):
+        raise ValueError("parameters must be an object of parameter names and defaults")
+    invalid = sorted(p for p in view.parameters if not PARAMETER_PATTERN.match(p))
+    if invalid:
+        raise ValueError(f"invalid parameter names: {', '.join(invalid)}")
+    undeclared = sorted(placeholders(view.filter) - set(view.parameters))
+    if undeclared:
+        raise ValueError(f"placeholders must be declared in parameters: {', '.join(undeclared)}")
+    if not placeholders(view.filter):
+        parse_node_filter(view.filter)
+    if not isinstance(view.projection, dict):
+        raise ValueError("projection must be an object")
+    parse_transform(view.projection or None)
@@ -2,21 +2,26 @@
 NodeType service implementation.
 """
 
-from typing import List, Tuple
+from typing import List, Optional, Tuple
 
-from app.repository import NodeType, NodeTypeRepository, ListOptions, ListResult
+from app.repository import NodeType, NodeTypeRepository, ListResult
+from app.service.field_types import field_types
+from app.service.limits import TenantLimits
 
 
 class NodeTypeService:
     """NodeType business logic service."""
 
-    def __init__(self, repo: NodeTypeRepository):
+    def __init__(self, repo: NodeTypeRepository, limits: Optional[TenantLimits] = None):
         self.repo = repo
+        self.limits = limits or TenantLimits()
 
     async def create(self, name: str, description: str, schema: str) -> NodeType:
         """Create a new node type."""
         if not name:
             raise ValueError("name is required")
+        # Rejects malformed field type declarations
+        field_types(schema)
 
         node_type = NodeType(
             tenant_id="",  # Not stored in tenant database
@@ -44,6 +49,7 @@ class NodeTypeService:
         if description:
             node_type.description = description
         if schema:
+            field_types(schema)
             node_type.schema = schema
 
         return await self.repo.update(node_type)
@@ -54,7 +60,7 @@ class NodeTypeService:
             raise ValueError("id is required")
         await self.repo.delete(id)
 
-    async def list(self, page_size: int, page_token: str) -> Tuple[List[NodeType], ListResult]:
+    async def list(self, page_size: int, page_token: str, order_by: str = "") -> Tuple[List[NodeType], ListResult]:
         """Retrieve node types with pagination."""
-        opts = ListOptions(page_size=page_size, page_token=page_token)
+        opts = self.limits.list_options(page_size, page_token, order_by)
         return await self.repo.list(opts)
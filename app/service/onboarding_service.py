@@ -0,0 +1,175 @@
+"""
+Tenant onboarding.
+
+provision() sets up a ready-to-use tenant in one call instead of a chain of
+calls that can stop halfway: it creates the tenant (from a tenant template,
+if one is named), issues an admin API key limited to the tenant and
+registers the tenant's write hooks. It is a compensated workflow: when a step fails, the
+steps before it are undone (the key is revoked, and the tenant is removed
+together with its database and hooks) and the step's error is raised, so a
+failed call leaves nothing behind and can be retried as is.
+"""
+
+import logging
+from dataclasses import dataclass, field
+from typing import Any, Awaitable, Callable, Dict, List, Optional
+
+from app.repository import ApiKey, PermissionDeniedError, Tenant, WriteHook
+from app.scripting.cel import DEFAULT_TIMEOUT_MS
+from app.service.api_key_service import SCOPES, ApiKeyService
+from app.service.template_service import list_all
+from app.service.tenant_service import TenantService
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_API_KEY_SCOPE = "admin"
+API_KEY_KEYS = ("name", "scope", "ttl_seconds")
+WRITE_HOOK_KEYS = (
+    "name", "expression", "phase", "action", "node_type", "operations", "timeout_ms", "priority", "enabled",
+)
+MAX_WRITE_HOOKS = 50
+
+
+@dataclass
+class ProvisionedTenant:
+    """Everything provision() set up."""
+    tenant: Tenant
+    # Bearer key, returned only here
+    key: str
+    api_key: ApiKey
+    write_hooks: List[WriteHook] = field(default_factory=list)
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        return {
+            "tenant": self.tenant.to_dict(),
+            "key": self.key,
+            "api_key": self.api_key.to_dict(),
+            "write_hooks": [h.to_dict() for h in self.write_hooks],
+        }
+
+
+def _check_options(options: Optional[Dict[str, Any]], keys: tuple, path: str) -> Dict[str, Any]:
+    if options is None:
+        return {}
+    if not isinstance(options, dict):
+        raise ValueError(f"{path} must be an object")
+    unknown = sorted(set(options) - set(keys))
+    if unknown:
+        raise ValueError(f"{path} has unknown keys: {', '.join(unknown)}")
+    return options
+
+
+class OnboardingService:
+    """Provisions tenants as one compensated workflow."""
+
+    def __init__(
+        self,
+        tenant_service: TenantService,
+        api_key_service: ApiKeyService,
+        tenant_services: Callable[[str], Awaitable[Dict[str, Any]]],
+    ):
+        self.tenant_service = tenant_service
+        self.api_key_service = api_key_service
+        # Resolves a tenant's tenant-scoped services, used to register hooks
+        self.tenant_services = tenant_services
+
+    async def provision(
+        self,
+        caller_id: str,
+        slug: str,
+        name: str,
+        annotations: Optional[Dict[str, str]] = None,
+        from_template: str = "",
+        parent_id: str = "",
+        timezone: str = "",
+        api_key: Optional[Dict[str, Any]] = None,
+        write_hooks: Optional[List[Dict[str, Any]]] = None,
+        region: str = "",
+    ) -> ProvisionedTenant:
+        """
+        Create a tenant with an API key and write hooks, or nothing at all.
+
+        caller_id: The caller when a credential identified them (see
+            RequestContext.admin_subject_id), as the key is issued to them
+        api_key: {"name", "scope", "ttl_seconds"} of the key, which is limited
+            to the new tenant (default: scope admin)
+        write_hooks: create_write_hook settings, with "node_type" naming a
+            node type (e.g. one of the template's) instead of node_type_id
+
+        Raises:
+            PermissionDeniedError: If no credential identified the caller
+            ValueError: If an option is invalid; hook errors name the hook, e.g. write_hooks[1]
+            SlugTakenError: If another tenant has the slug
+        """
+        if not caller_id:
+            raise PermissionDeniedError("tenants can only be provisioned by a caller identified by a credential")
+        key_options = _check_options(api_key, API_KEY_KEYS, "api_key")
+        scope = key_options.get("scope") or DEFAULT_API_KEY_SCOPE
+        if scope not in SCOPES:
+            raise ValueError(f"api_key.scope must be one of: {', '.join(SCOPES)}")
+        ttl_seconds = key_options.get("ttl_seconds") or 0
+        if not isinstance(ttl_seconds, int) or ttl_seconds < 0:
+            raise ValueError("api_key.ttl_seconds must be a non-negative integer")
+        if write_hooks is None:
+            write_hooks = []
+        if not isinstance(write_hooks, list) or len(write_hooks) > MAX_WRITE_HOOKS:
+            raise ValueError(f"write_hooks must be a list of at most {MAX_WRITE_HOOKS} hooks")
+        hooks = [_check_options(h, WRITE_HOOK_KEYS, f"write_hooks[{i}]") for i, h in enumerate(write_hooks)]
+
+        tenant = await self.tenant_service.create(
+            slug, name, annotations, from_template, parent_id, timezone, region=region
+        )
+        key: Optional[ApiKey] = None
+        try:
+            token, key = await self.api_key_service.create(
+                caller_id, key_options.get("name") or f"{tenant.slug} onboarding", scope, [tenant.id],
+                ttl_seconds=ttl_seconds, admin_id=caller_id,
+            )
+            created = await self._create_hooks(tenant.id, hooks)
+        except Exception as e:
+            logger.error(f"Provisioning tenant {tenant.id} ({tenant.slug}) failed, undoing it: {e}")
+            await self._undo(caller_id, tenant, key)
+            raise
+        return ProvisionedTenant(tenant=tenant, key=token, api_key=key, write_hooks=created)
+
+    async def _create_hooks(self, tenant_id: str, hooks: List[Dict[str, Any]]) -> List[WriteHook]:
+        if not hooks:
+            return []
+        services = await self.tenant_services(tenant_id)
+        node_types = {}
+        if any(h.get("node_type") for h in hooks):
+            node_types = {t.name: t.id for t in await list_all(services["node_type"])}
+
+        created = []
+        for i, hook in enumerate(hooks):
+            node_type = hook.get("node_type") or ""
+            if node_type and node_type not in node_types:
+                raise ValueError(f"write_hooks[{i}].node_type: no node type named {node_type!r}")
+            try:
+                created.append(await services["write_hook"].create(
+                    hook.get("name", ""),
+                    hook.get("expression", ""),
+                    hook.get("phase", "pre_write"),
+                    hook.get("action", "validate"),
+                    node_types.get(node_type, ""),
+                    hook.get("operations"),
+                    hook.get("timeout_ms", DEFAULT_TIMEOUT_MS),
+                    hook.get("priority", 0),
+                    hook.get("enabled", True),
+                ))
+            except ValueError as e:
+                raise ValueError(f"write_hooks[{i}]: {e}") from e
+        return created
+
+    async def _undo(self, caller_id: str, tenant: Tenant, key: Optional[ApiKey]) -> None:
+        """Undo the steps of a failed provisioning; failures are logged, as the step's error is what matters."""
+        if key:
+            try:
+                await self.api_key_service.revoke(caller_id, key.id, caller_id)
+            except Exception as e:
+                logger.error(f"Revoking api key {key.id} of failed tenant {tenant.id} failed: {e}")
+        try:
+            await self.tenant_service.discard(tenant)
+        except Exception as e:
+            logger.error(f"Removing failed tenant {tenant.id} failed; delete it with delete_tenant: {e}")
@@ -0,0 +1,203 @@
+"""
+Long-running operation service implementation.
+
+Operations run as background tasks in the server process and record their
+progress in the tenant database, so clients can poll ``get_operation``.
+An operation interrupted by a server restart stays "running"; clients
+should treat one whose ``updated_at`` stops advancing as abandoned.
+Operations run at batch priority (see app/db/priority.py).
+"""
+
+import asyncio
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Set, Tuple
+
+from app.db.priority import BATCH, reset_priority, set_priority
+from app.repository import Operation, OperationRepository, ListResult
+from app.repository.errors import PreconditionFailedError
+from app.service.limits import TenantLimits
+
+logger = logging.getLogger(__name__)
+
+# Failures kept on an operation record (the count is always exact)
+MAX_RECORDED_ERRORS = 20
+# Progress is written to the database every this many processed items
+PROGRESS_INTERVAL = 100
+
+# Previewed destructive operations must be confirmed within this time
+CONFIRMATION_TTL_SECONDS = 900
+
+# Running tasks, referenced so they are not garbage collected mid-run
+_tasks: Set[asyncio.Task] = set()
+
+
+class OperationProgress:
+    """Tracks and periodically records the progress of a running operation."""
+
+    def __init__(self, repo: OperationRepository, op: Operation):
+        self.repo = repo
+        self.op = op
+
+    def succeeded(self, affected: bool = True) -> None:
+        """Count an item processed successfully (affected=False if it needed no change)."""
+        self.op.processed_count += 1
+        if affected:
+            self.op.affected_count += 1
+
+    def failed(self, item_id: str, err: Exception) -> None:
+        """Count an item that failed."""
+        self.op.processed_count += 1
+        self.op.failed_count += 1
+        if len(self.op.errors) < MAX_RECORDED_ERRORS:
+            self.op.errors.append({"id": item_id, "error": str(err)})
+
+    def report(self, result: Dict[str, Any]) -> None:
+        """Set the summary recorded on the operation when it finishes."""
+        self.op.result = result
+
+    async def checkpoint(self, force: bool = False) -> None:
+        """Write progress to the database (every PROGRESS_INTERVAL items unless forced)."""
+        if force or self.op.processed_count % PROGRESS_INTERVAL == 0:
+            await self.repo.update_progress(
+                self.op.id, self.op.processed_count, self.op.affected_count,
+                self.op.failed_count, self.op.errors
+            )
+
+
+Work = Callable[[OperationProgress], Awaitable[None]]
+
+
+class OperationService:
+    """Operation business logic service."""
+
+    def __init__(self, repo: OperationRepository, limits: Optional[TenantLimits] = None):
+        self.repo = repo
+        self.limits = limits or TenantLimits()
+
+    async def start(self, kind: str, params: Dict[str, Any], total_count: int, work: Work) -> Operation:
+        """Record a new operation and run work in the background; returns the running operation."""
+        op = await self.repo.create(Operation(kind=kind, params=params, total_count=total_count))
+        self._run(op, work)
+        return op
+
+    async def prepare(self, kind: str, params: Dict[str, Any], total_count: int) -> Operation:
+        """
+        Record a previewed operation that only runs once confirmed.
+
+        The operation ID is the confirmation token passed to confirm.
+        """
+        return await self.repo.create(Operation(
+            kind=kind, status="awaiting_confirmation", params=params, total_count=total_count
+        ))
+
+    async def confirm(self, id: str, kind: str, total_count: int, work: Work) -> Operation:
+        """
+        Run a prepared operation in the background.
+
+        Raises:
+            ValueError: If the token is unknown, expired or already used
+        """
+        not_before = datetime.now(timezone.utc) - timedelta(seconds=CONFIRMATION_TTL_SECONDS)
+        op = await self.repo.confirm(id, kind, total_count, not_before)
+        if not op:
+            raise ValueError("confirmation token is invalid, expired or already used; preview again")
+        self._run(op, work)
+        return op
+
+    async def run(self, id: str, kind: str, work: Work) -> Operation:
+        """
+        Run a prepared operation in the current task, for work fed by the
+        request itself (such as an upload); returns the finished operation,
+        which is "failed" with the error if work raised.
+
+        Raises:
+            ValueError: If the token is unknown, expired or already used
+        """
+        not_before = datetime.now(timezone.utc) - timedelta(seconds=CONFIRMATION_TTL_SECONDS)
+        op = await self.repo.confirm(id, kind, 0, not_before)
+        if not op:
+            raise ValueError(f"{kind} {id} is unknown, expired or already started")
+        progress = OperationProgress(self.repo, op)
+        token = set_priority(BATCH)
+        try:
+            await work(progress)
+        except Exception as e:
+            logger.error(f"Operation {op.id} ({kind}) failed: {e}")
+            await progress.checkpoint(force=True)
+            return await self.repo.finish(op.id, "failed", str(e), op.result)
+        finally:
+            reset_priority(token)
+        await progress.checkpoint(force=True)
+        return await self.repo.finish(op.id, "completed", result=op.result)
+
+    async def pause(self, id: str, kinds: List[str]) -> Operation:
+        """
+        Pause a running operation of one of kinds; its work holds before the next item until resumed.
+
+        Raises:
+            NotFoundError: If the operation does not exist
+            PreconditionFailedError: If it is not a running operation of one of kinds
+        """
+        return await self._set_status(id, kinds, "running", "paused", "paused")
+
+    async def resume(self, id: str, kinds: List[str]) -> Operation:
+        """
+        Resume a paused operation of one of kinds.
+
+        Raises:
+            NotFoundError: If the operation does not exist
+            PreconditionFailedError: If it is not a paused operation of one of kinds
+        """
+        return await self._set_status(id, kinds, "paused", "running", "resumed")
+
+    async def is_paused(self, id: str) -> bool:
+        """Whether an operation is paused."""
+        return (await self.repo.get_by_id(id)).status == "paused"
+
+    async def _set_status(
+        self, id: str, kinds: List[str], from_status: str, to_status: str, action: str
+    ) -> Operation:
+        if not id:
+            raise ValueError("id is required")
+        op = await self.repo.set_status(id, kinds, from_status, to_status)
+        if op:
+            return op
+        current = await self.repo.get_by_id(id)
+        if current.kind not in kinds:
+            raise PreconditionFailedError(f"{current.kind} operations cannot be {action}")
+        raise PreconditionFailedError(f"operation {id} is {current.status}, not {from_status}")
+
+    def _run(self, op: Operation, work: Work) -> None:
+        progress = OperationProgress(self.repo, op)
+        kind = op.kind
+
+        async def run():
+            # The task has its own copy of the context, so this does not leak to the caller
+            set_priority(BATCH)
+            try:
+                await work(progress)
+                await progress.checkpoint(force=True)
+                await self.repo.finish(op.id, "completed", result=op.result)
+            except Exception as e:
+                logger.error(f"Operation {op.id} ({kind}) failed: {e}")
+                try:
+                    await progress.checkpoint(force=True)
+                    await self.repo.finish(op.id, "failed", str(e), op.result)
+                except Exception as record_err:
+                    logger.error(f"Failed to record failure of operation {op.id}: {record_err}")
+
+        task = asyncio.get_running_loop().create_task(run())
+        _tasks.add(task)
+        task.add_done_callback(_tasks.discard)
+
+    async def get_by_id(self, id: str) -> Operation:
+        """Retrieve an operation by ID."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_by_id(id)
+
+    async def list(self, page_size: int, page_token: str) -> Tuple[List[Operation], ListResult]:
+        """Retrieve operations, newest first."""
+        opts = self.limits.list_options(page_size, page_token)
+        return await self.repo.list(opts)
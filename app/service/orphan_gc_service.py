@@ -0,0 +1,121 @@
+"""
+Collection of orphaned relationships.
+
+Relationships reference their endpoint nodes with cascading foreign keys,
+but tenants created before integrity checks were enforced (or whose tables
+were restored without them) can hold relationships whose source or target
+node no longer exists. An orphan collection is an operation that checks
+every relationship's endpoints and, unless it is report-only, deletes the
+orphans it finds (so they also leave export tombstones).
+
+The operation's processed_count is the number of relationships checked and
+its affected_count the number of orphans found (report-only) or deleted.
+Its result reports the counts per relationship type and a sample of orphans
+with their missing endpoints. The orphan collector runs a collection over
+every tenant periodically when ORPHAN_GC_INTERVAL_SECONDS is set.
+"""
+
+import logging
+from typing import Any, Awaitable, Callable, Dict, List, Tuple
+
+from app.repository import (
+    Operation, Relationship, RelationshipFilter, RelationshipRepository, TenantRepository,
+)
+from app.service.operation_service import OperationProgress, OperationService
+
+logger = logging.getLogger(__name__)
+
+OPERATION_KIND = "collect_orphans"
+# Relationships checked per query
+BATCH_SIZE = 1000
+# Orphans listed in an operation's result (the rest are counted)
+MAX_SAMPLE = 100
+
+
+class OrphanCollectionService:
+    """Finds and removes relationships whose source or target node no longer exists."""
+
+    def __init__(self, relationship_repo: RelationshipRepository, operation_service: OperationService):
+        self.relationship_repo = relationship_repo
+        self.operation_service = operation_service
+
+    async def start(self, dry_run: bool = True) -> Operation:
+        """Start a collection in the background (report-only if dry_run); returns the running operation."""
+        return await self.operation_service.start(
+            OPERATION_KIND, {"dry_run": dry_run}, await self._total(), self._work(dry_run)
+        )
+
+    async def collect(self, dry_run: bool = True) -> Operation:
+        """Run a collection in the current task (report-only if dry_run); returns the finished operation."""
+        op = await self.operation_service.prepare(OPERATION_KIND, {"dry_run": dry_run}, await self._total())
+        return await self.operation_service.run(op.id, OPERATION_KIND, self._work(dry_run))
+
+    async def _total(self) -> int:
+        return await self.relationship_repo.count_matching(RelationshipFilter())
+
+    def _work(self, dry_run: bool) -> Callable[[OperationProgress], Awaitable[None]]:
+        async def work(progress: OperationProgress) -> None:
+            report: Dict[str, Any] = {"orphan_count": 0, "deleted_count": 0, "by_relationship_type": {}, "sample": []}
+            progress.report(report)
+            after = ""
+            while True:
+                checked, after, orphans = await self.relationship_repo.find_orphans(after, BATCH_SIZE)
+                if not checked:
+                    return
+                deleted = 0
+                if orphans and not dry_run:
+                    deleted = await self.relationship_repo.delete_orphans([rel.id for rel, _ in orphans])
+                _record(report, orphans, deleted)
+                progress.op.processed_count += checked
+                progress.op.affected_count = report["orphan_count"] if dry_run else report["deleted_count"]
+                await progress.checkpoint(force=True)
+
+        return work
+
+
+def _record(report: Dict[str, Any], orphans: List[Tuple[Relationship, List[str]]], deleted: int) -> None:
+    report["orphan_count"] += len(orphans)
+    report["deleted_count"] += deleted
+    by_type = report["by_relationship_type"]
+    for rel, missing in orphans:
+        by_type[rel.relationship_type] = by_type.get(rel.relationship_type, 0) + 1
+        if len(report["sample"]) < MAX_SAMPLE:
+            report["sample"].append({
+                "id": rel.id,
+                "relationship_type": rel.relationship_type,
+                "source_node_id": rel.source_node_id,
+                "target_node_id": rel.target_node_id,
+                "missing": missing,
+            })
+
+
+class OrphanCollector:
+    """Periodically collects every tenant's orphaned relationships."""
+
+    def __init__(
+        self,
+        tenant_repo: TenantRepository,
+        tenant_services: Callable[[str], Awaitable[Dict[str, Any]]],
+        delete: bool = False,
+    ):
+        self.tenant_repo = tenant_repo
+        self.tenant_services = tenant_services
+        # Report only unless set
+        self.delete = delete
+
+    async def run(self) -> int:
+        """Collect every tenant's orphans; returns how many were found (or deleted)."""
+        count = 0
+        for tenant_id, slug, _ in await self.tenant_repo.list_databases():
+            try:
+                service: OrphanCollectionService = (await self.tenant_services(tenant_id))["orphan_gc"]
+                op = await service.collect(dry_run=not self.delete)
+                if op.status == "failed":
+                    raise RuntimeError(op.error)
+                if op.affected_count:
+                    action = "deleted" if self.delete else "found"
+                    logger.warning(f"Orphaned relationships of tenant {slug} ({tenant_id}): {op.affected_count} {action}")
+                count += op.affected_count
+            except Exception as e:
+                logger.error(f"Collecting orphaned relationships of tenant {slug} ({tenant_id}) failed: {e}")
+        return count
@@ -0,0 +1,147 @@
+"""
+JSON document patching for node and relationship data.
+"""
+
+import copy
+from typing import Any, Dict, List, Tuple
+
+
+def apply_merge_patch(target: Any, patch: Any) -> Any:
+    """
+    Apply an RFC 7386 JSON merge patch and return the result.
+
+    Object members in the patch replace those in the target, recursively;
+    a null member removes the key. A non-object patch replaces the target.
+    The target is not modified.
+    """
+    if not isinstance(patch, dict):
+        return copy.deepcopy(patch)
+    result = dict(target) if isinstance(target, dict) else {}
+    for key, value in patch.items():
+        if value is None:
+            result.pop(key, None)
+        else:
+            result[key] = apply_merge_patch(result.get(key), value)
+    return result
+
+
+JSON_PATCH_OPS = ("add", "remove", "replace", "move", "copy", "test")
+
+
+def apply_json_patch(target: Any, operations: List[Dict[str, Any]]) -> Any:
+    """
+    Apply an RFC 6902 JSON patch and return the result.
+
+    The patch is applied atomically: if any operation fails (including a
+    failed "test"), ValueError is raised and no result is produced.
+    The target is not modified.
+    """
+    if not isinstance(operations, list):
+        raise ValueError("JSON patch must be an array of operations")
+    doc = copy.deepcopy(target)
+    for i, op in enumerate(operations):
+        if not isinstance(op, dict) or op.get("op") not in JSON_PATCH_OPS:
+            raise ValueError(f"patch[{i}]: op must be one of {', '.join(JSON_PATCH_OPS)}")
+        try:
+            doc = _apply_operation(doc, op)
+        except (KeyError, IndexError, TypeError) as e:
+            raise ValueError(f"patch[{i}]: {op['op']} {op.get('path', '')!r} failed: {e}")
+    return doc
+
+
+def apply_patch(target: Any, patch: Any) -> Any:
+    """Apply a JSON patch (array of operations) or a JSON merge patch (object)."""
+    if isinstance(patch, list):
+        return apply_json_patch(target, patch)
+    if isinstance(patch, dict):
+        return apply_merge_patch(target, patch)
+    raise ValueError("patch must be an array (RFC 6902 JSON patch) or an object (RFC 7386 merge patch)")
+
+
+def _apply_operation(doc: Any, op: Dict[str, Any]) -> Any:
+    name = op["op"]
+    path = parse_pointer(_member(op, "path"))
+
+    if name == "add":
+        return _add(doc, path, copy.deepcopy(_member(op, "value")))
+    if name == "remove":
+        return _remove(doc, path)[0]
+    if name == "replace":
+        doc, _ = _remove(doc, path)
+        return _add(doc, path, copy.deepcopy(_member(op, "value")))
+    if name == "test":
+        if _get(doc, path) != _member(op, "value"):
+            raise ValueError(f"test failed at {op['path']!r}")
+        return doc
+
+    from_path = parse_pointer(_member(op, "from"))
+    if name == "move":
+        if path[:len(from_path)] == from_path and len(path) > len(from_path):
+            raise ValueError(f"cannot move {op['from']!r} into itself")
+        doc, value = _remove(doc, from_path)
+        return _add(doc, path, value)
+    # copy
+    return _add(doc, path, copy.deepcopy(_get(doc, from_path)))
+
+
+def _member(op: Dict[str, Any], key: str) -> Any:
+    if key not in op:
+        raise ValueError(f"{op['op']} operation requires {key!r}")
+    return op[key]
+
+
+def parse_pointer(pointer: Any) -> List[str]:
+    """Split an RFC 6901 JSON pointer into unescaped reference tokens."""
+    if not isinstance(pointer, str) or (pointer and not pointer.startswith("/")):
+        raise ValueError(f"invalid JSON pointer: {pointer!r}")
+    if not pointer:
+        return []
+    return [token.replace("~1", "/").replace("~0", "~") for token in pointer[1:].split("/")]
+
+
+def _array_index(container: List[Any], token: str, allow_end: bool) -> int:
+    if token == "-" and allow_end:
+        return len(container)
+    if not token.isdigit() or (len(token) > 1 and token.startswith("0")):
+        raise KeyError(f"invalid array index {token!r}")
+    index = int(token)
+    if index > len(container) or (index == len(container) and not allow_end):
+        raise IndexError(f"array index {index} out of range")
+    return index
+
+
+def _get(doc: Any, path: List[str]) -> Any:
+    for token in path:
+        if isinstance(doc, list):
+            doc = doc[_array_index(doc, token, allow_end=False)]
+        elif isinstance(doc, dict):
+            doc = doc[token]
+        else:
+            raise TypeError("path traverses a scalar value")
+    return doc
+
+
+def _add(doc: Any, path: List[str], value: Any) -> Any:
+    if not path:
+        return value
+    parent = _get(doc, path[:-1])
+    token = path[-1]
+    if isinstance(parent, list):
+        parent.insert(_array_index(parent, token, allow_end=True), value)
+    elif isinstance(parent, dict):
+        parent[token] = value
+    else:
+        raise TypeError("parent is not an object or array")
+    return doc
+
+
+def _remove(doc: Any, path: List[str]) -> Tuple[Any, Any]:
+    if not path:
+        return None, doc
+    parent = _get(doc, path[:-1])
+    token = path[-1]
+    if isinstance(parent, list):
+        return doc, parent.pop(_array_index(parent, token, allow_end=False))
+    if isinstance(parent, dict):
+        return doc, parent.pop(token)
+    raise TypeError("parent is not an object or array")
@@ -0,0 +1,22 @@
+"""
+Conditional request (etag) checks for service parameters.
+"""
+
+from datetime import datetime
+from typing import Optional
+
+from app.repository.errors import PreconditionFailedError
+
+
+def check_if_match(etag: str, updated_at: datetime, if_match: str, what: str) -> Optional[datetime]:
+    """
+    Check an If-Match etag against an entity's current etag.
+
+    Returns the updated_at the write must still see to apply atomically,
+    or None when no precondition was given ("*" matches any version).
+    """
+    if not if_match or if_match == "*":
+        return None
+    if if_match != etag:
+        raise PreconditionFailedError(f"etag mismatch for {what}: current etag is {etag}")
+    return updated_at
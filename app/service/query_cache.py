@@ -0,0 +1,127 @@
+"""
+Query result caching.
+
+Results of read calls such as list_nodes and search_nodes can be cached per
+tenant, keyed by the method and its normalized parameters, so repeated
+identical queries (dashboards polling the same lists) do not hit the
+database each time. Caching is enabled per tenant with the
+query_cache_seconds limit, the longest an entry is served.
+
+Entries are invalidated by the tenant's change stream (the change tracking
+behind incremental exports): each entry remembers the transaction ID horizon
+taken before its query ran, and is served only while no node, relationship
+or deletion has been recorded at or after it. That check is one indexed
+query, much cheaper than the cached list and count queries. Writes made on
+any server therefore invalidate every server's entries at once, and
+long-running transactions can only make entries expire early, never serve
+stale results.
+"""
+
+import json
+import time
+from collections import OrderedDict
+from dataclasses import dataclass
+from typing import Any, Awaitable, Callable, Dict, Optional, Tuple
+
+from app.db.tracing import capturing
+from app.metrics import metrics
+from app.repository import TombstoneRepository
+from app.service.limits import TenantLimits
+
+# Entries kept per process, across tenants, by default
+DEFAULT_MAX_ENTRIES = 10000
+
+
+@dataclass
+class CacheEntry:
+    """A cached result with the change position and time it is valid from and until."""
+    value: Any
+    position: str
+    expires_at: float
+
+
+class QueryCache:
+    """Process-wide LRU store of cached query results."""
+
+    def __init__(self, max_entries: int = DEFAULT_MAX_ENTRIES):
+        self.max_entries = max_entries
+        self._entries: "OrderedDict[Tuple[str, str, str], CacheEntry]" = OrderedDict()
+
+    def get(self, key: Tuple[str, str, str]) -> Optional[CacheEntry]:
+        """Return an unexpired entry, marking it recently used."""
+        entry = self._entries.get(key)
+        if entry is None:
+            return None
+        if entry.expires_at <= time.monotonic():
+            del self._entries[key]
+            return None
+        self._entries.move_to_end(key)
+        return entry
+
+    def put(self, key: Tuple[str, str, str], entry: CacheEntry) -> None:
+        """Store an entry, evicting the least recently used ones past max_entries."""
+        self._entries[key] = entry
+        self._entries.move_to_end(key)
+        while len(self._entries) > self.max_entries:
+            self._entries.popitem(last=False)
+
+    def discard(self, key: Tuple[str, str, str]) -> None:
+        """Drop an entry."""
+        self._entries.pop(key, None)
+
+    def clear_tenant(self, tenant_id: str) -> None:
+        """Drop every entry of a tenant."""
+        for key in [k for k in self._entries if k[0] == tenant_id]:
+            del self._entries[key]
+
+    def __len__(self) -> int:
+        return len(self._entries)
+
+
+def normalize_params(params: Dict[str, Any]) -> str:
+    """Return a canonical form of call parameters: key order and unset parameters do not matter."""
+    present = {k: v for k, v in params.items() if v not in (None, "", [], {})}
+    return json.dumps(present, sort_keys=True, separators=(",", ":"), default=str)
+
+
+class QueryCacheService:
+    """Tenant-scoped query result caching."""
+
+    def __init__(
+        self,
+        tombstone_repo: TombstoneRepository,
+        tenant_id: str,
+        limits: Optional[TenantLimits] = None,
+        cache: Optional[QueryCache] = None,
+    ):
+        self.tombstone_repo = tombstone_repo
+        self.tenant_id = tenant_id
+        self.limits = limits or TenantLimits()
+        self.cache = cache
+
+    @property
+    def enabled(self) -> bool:
+        return self.cache is not None and self.limits.query_cache_seconds > 0
+
+    async def get_or_load(self, method: str, params: Dict[str, Any], load: Callable[[], Awaitable[Any]]) -> Any:
+        """Return the cached result of a call, or load and cache it."""
+        if not self.enabled or capturing():
+            # Explained calls run their queries
+            return await load()
+        key = (self.tenant_id, method, normalize_params(params))
+        entry = self.cache.get(key)
+        if entry is not None:
+            if not await self.tombstone_repo.changed_since(entry.position):
+                metrics.inc("query_cache_requests_total", labels={"method": method, "result": "hit"})
+                return entry.value
+            self.cache.discard(key)
+            metrics.inc("query_cache_requests_total", labels={"method": method, "result": "invalidated"})
+        else:
+            metrics.inc("query_cache_requests_total", labels={"method": method, "result": "miss"})
+
+        # Taken before the query, so writes committing while it runs invalidate the entry
+        position = await self.tombstone_repo.sync_position()
+        value = await load()
+        expires_at = time.monotonic() + self.limits.query_cache_seconds
+        self.cache.put(key, CacheEntry(value=value, position=position, expires_at=expires_at))
+        return value
@@ -0,0 +1,402 @@
+"""
+Streaming bulk relationship ingestion.
+
+A client reserves an import with the ``begin_relationship_import`` JSON-RPC
+method (which is subject to authorization policies), then streams the rows
+in the body of one HTTP request to the import's upload path, either as
+newline-delimited JSON (the default):
+
+    {"source_node_id": "...", "target_node_id": "...", "relationship_type": "KNOWS", "data": {...}}
+
+or as CSV with a header of the same keys (``data`` holding a JSON object):
+
+    source_alias.email,target_alias.email,relationship_type,valid_from
+    ada@example.com,alan@example.com,KNOWS,2024-01-01T00:00:00Z
+
+Instead of a node ID, an endpoint can be referenced by one of its aliases,
+``{"source_alias": {"email": "ada@example.com"}}`` (a ``source_alias.<name>``
+column in CSV), or by its external ID when external IDs are configured.
+Aliases are resolved a batch at a time.
+
+Rows are validated and inserted in transactions of batch_size rows. A row
+that fails validation, references a node that cannot be resolved, or cannot
+be inserted is reported with its line number (and the unresolved reference)
+and the import continues with the next row. Progress and the outcome are
+recorded on the import's operation. Imports are limited and paced so they
+do not slow down other calls (see import_throttle.py).
+"""
+
+import json
+import uuid
+from dataclasses import dataclass
+from typing import Any, AsyncIterator, Callable, Dict, List, Optional, Tuple
+
+from app.repository import (
+    NodeAliasRepository,
+    NodeRepository,
+    Operation,
+    Relationship,
+    RelationshipRepository,
+    RelationshipType,
+    RelationshipTypeRepository,
+)
+from app.service.csv_records import csv_records
+from app.service.import_throttle import ImportPacer, ImportThrottle
+from app.service.limits import TenantLimits
+from app.service.node_alias_service import ALIAS_NAME_PATTERN
+from app.service.operation_service import OperationProgress, OperationService
+from app.service.relationship_service import check_validity
+from app.service.timestamps import parse_timestamp
+
+IMPORT_KIND = "import_relationships"
+DEFAULT_BATCH_SIZE = 500
+MAX_BATCH_SIZE = 5000
+MAX_LINE_BYTES = 1024 * 1024
+# Failed rows returned in the import summary (the failure count is always exact)
+MAX_REPORTED_FAILURES = 1000
+FORMATS = ("ndjson", "csv")
+ROW_KEYS = (
+    "source_node_id", "target_node_id", "source_alias", "target_alias",
+    "relationship_type", "data", "valid_from", "valid_to",
+)
+ENDPOINTS = ("source", "target")
+
+
+@dataclass
+class RowFailure:
+    """A row that was not imported."""
+    line: int
+    error: str
+    # The endpoint reference that could not be resolved, e.g. "source_alias.email=ada@example.com"
+    reference: str = ""
+
+    def to_dict(self) -> dict:
+        """Convert to dictionary."""
+        result = {"line": self.line, "error": self.error}
+        if self.reference:
+            result["reference"] = self.reference
+        return result
+
+
+@dataclass
+class _Row:
+    """A parsed row; endpoints referenced by alias are resolved with its batch."""
+    line: int
+    rel: Relationship
+    # Endpoint ("source" or "target") -> (alias name, value)
+    aliases: Dict[str, Tuple[str, str]]
+
+
+class RelationshipImportService:
+    """Streaming relationship import business logic service."""
+
+    def __init__(
+        self,
+        relationship_repo: RelationshipRepository,
+        node_repo: NodeRepository,
+        rel_type_repo: RelationshipTypeRepository,
+        operation_service: OperationService,
+        limits: Optional[TenantLimits] = None,
+        alias_repo: Optional[NodeAliasRepository] = None,
+        decode_id: Optional[Callable[[str], Optional[uuid.UUID]]] = None,
+        throttle: Optional[ImportThrottle] = None,
+        tenant_id: str = "",
+    ):
+        """decode_id returns the node ID of an external ID (None if the value is not one)."""
+        self.relationship_repo = relationship_repo
+        self.node_repo = node_repo
+        self.rel_type_repo = rel_type_repo
+        self.operation_service = operation_service
+        self.limits = limits or TenantLimits()
+        self.alias_repo = alias_repo
+        self.decode_id = decode_id
+        self.throttle = throttle or ImportThrottle()
+        self.tenant_id = tenant_id
+
+    async def begin(self, batch_size: int = 0, format: str = "") -> Operation:
+        """
+        Reserve an import of rows in a format (ndjson or csv); its operation ID identifies the upload.
+
+        The rows must be uploaded within the operation confirmation window.
+        """
+        batch_size = batch_size or DEFAULT_BATCH_SIZE
+        if not 1 <= batch_size <= MAX_BATCH_SIZE:
+            raise ValueError(f"batch_size must be between 1 and {MAX_BATCH_SIZE}")
+        format = format or FORMATS[0]
+        if format not in FORMATS:
+            raise ValueError(f"format must be one of: {', '.join(FORMATS)}")
+        return await self.operation_service.prepare(IMPORT_KIND, {"batch_size": batch_size, "format": format}, 0)
+
+    async def ingest(self, id: str, chunks: AsyncIterator[bytes]) -> Tuple[Operation, List[RowFailure]]:
+        """
+        Import the rows of a reserved import.
+
+        Returns the finished operation and the failed rows (up to
+        MAX_REPORTED_FAILURES). A fatal error, such as exceeding
+        max_batch_size rows or an unknown CSV column, fails the operation;
+        batches committed before it stay imported.
+
+        Raises:
+            ValueError: If the import is unknown, expired or already uploaded
+            PreconditionFailedError: If no import slot frees up in time
+        """
+        if not id:
+            raise ValueError("id is required")
+        failures: List[RowFailure] = []
+
+        async def work(progress: OperationProgress) -> None:
+            pacer = self.throttle.pacer(IMPORT_KIND, lambda: self.operation_service.is_paused(progress.op.id))
+            batch_size = progress.op.params.get("batch_size", DEFAULT_BATCH_SIZE)
+            rows = _csv_rows(chunks) if progress.op.params.get("format") == "csv" else _json_rows(chunks)
+            batch: List[_Row] = []
+            async for line_number, row in rows:
+                if progress.op.processed_count + len(batch) >= self.limits.max_batch_size:
+                    raise ValueError(f"import exceeds {self.limits.max_batch_size} rows (max_batch_size)")
+                try:
+                    batch.append(self._parse_row(line_number, row))
+                except ValueError as e:
+                    _fail(progress, failures, line_number, e)
+                if len(batch) >= batch_size:
+                    await self._import_batch(batch, progress, failures, pacer)
+                    batch = []
+            if batch:
+                await self._import_batch(batch, progress, failures, pacer)
+
+        async with self.throttle.admit(self.tenant_id, self.limits.max_concurrent_imports):
+            return await self.operation_service.run(id, IMPORT_KIND, work), failures
+
+    def _parse_row(self, line_number: int, row: Any) -> _Row:
+        if isinstance(row, ValueError):
+            raise row
+        if not isinstance(row, dict):
+            raise ValueError("row must be a JSON object")
+        unknown = [k for k in row if k not in ROW_KEYS]
+        if unknown:
+            raise ValueError(f"unknown keys: {', '.join(unknown)}")
+
+        node_ids = {}
+        aliases = {}
+        for endpoint in ENDPOINTS:
+            id_key, alias_key = f"{endpoint}_node_id", f"{endpoint}_alias"
+            if (row.get(id_key) is None) == (row.get(alias_key) is None):
+                raise ValueError(f"{id_key} or {alias_key} is required, but not both")
+            if row.get(alias_key) is not None:
+                aliases[endpoint] = _parse_alias(alias_key, row[alias_key])
+                node_ids[endpoint] = ""
+            else:
+                node_ids[endpoint] = self._parse_node_id(id_key, row[id_key])
+        if not row.get("relationship_type") or not isinstance(row["relationship_type"], str):
+            raise ValueError("relationship_type is required")
+
+        data = row.get("data", {})
+        if isinstance(data, str):
+            try:
+                data = json.loads(data)
+            except ValueError as e:
+                raise ValueError(f"data is not valid JSON: {e}") from e
+        if not isinstance(data, dict):
+            raise ValueError("data must be a JSON object")
+
+        valid_from = parse_timestamp(str(row.get("valid_from") or ""), "valid_from")
+        valid_to = parse_timestamp(str(row.get("valid_to") or ""), "valid_to")
+        check_validity(valid_from, valid_to)
+
+        return _Row(line_number, Relationship(
+            source_node_id=node_ids["source"],
+            target_node_id=node_ids["target"],
+            relationship_type=row["relationship_type"],
+            data=json.dumps(data),
+            valid_from=valid_from,
+            valid_to=valid_to,
+        ), aliases)
+
+    def _parse_node_id(self, key: str, value: Any) -> str:
+        """Return a node ID given as a UUID or, when external IDs are configured, an external ID."""
+        try:
+            return str(uuid.UUID(str(value or "")))
+        except ValueError:
+            pass
+        decoded = self.decode_id(value) if self.decode_id and isinstance(value, str) else None
+        if decoded is None:
+            raise ValueError(f"{key} must be a node ID")
+        return str(decoded)
+
+    async def _resolve_aliases(
+        self,
+        batch: List[_Row],
+        progress: OperationProgress,
+        failures: List[RowFailure],
+    ) -> List[_Row]:
+        """Fill in the endpoints referenced by alias; returns the rows whose references all resolved."""
+        wanted = list({alias for row in batch for alias in row.aliases.values()})
+        if not wanted:
+            return batch
+        if self.alias_repo is None:
+            raise ValueError("aliases cannot be resolved in this import")
+        found = await self.alias_repo.find_many(wanted)
+
+        resolved = []
+        for row in batch:
+            unresolved = [(endpoint, alias) for endpoint, alias in row.aliases.items() if alias not in found]
+            if unresolved:
+                endpoint, (name, value) = unresolved[0]
+                error = ValueError(f"node not found: {name}={value!r}")
+                _fail(progress, failures, row.line, error, f"{endpoint}_alias.{name}={value}")
+                continue
+            for endpoint, alias in row.aliases.items():
+                setattr(row.rel, f"{endpoint}_node_id", found[alias])
+            resolved.append(row)
+        return resolved
+
+    async def _import_batch(
+        self,
+        batch: List[_Row],
+        progress: OperationProgress,
+        failures: List[RowFailure],
+        pacer: ImportPacer,
+    ) -> None:
+        async with pacer.write(len(batch)):
+            await self._write_batch(batch, progress, failures)
+        await progress.checkpoint(force=True)
+
+    async def _write_batch(
+        self,
+        batch: List[_Row],
+        progress: OperationProgress,
+        failures: List[RowFailure],
+    ) -> None:
+        batch = await self._resolve_aliases(batch, progress, failures)
+        node_ids = list({row.rel.source_node_id for row in batch} | {row.rel.target_node_id for row in batch})
+        node_types = await self.node_repo.get_node_type_ids(node_ids) if node_ids else {}
+        registered = {
+            t.name: t for t in await self.rel_type_repo.get_by_names(list({row.rel.relationship_type for row in batch}))
+        } if batch else {}
+
+        valid = []
+        for row in batch:
+            error, reference = _check_row(row.rel, node_types, registered)
+            if error:
+                _fail(progress, failures, row.line, ValueError(error), reference)
+            else:
+                valid.append((row.line, row.rel))
+
+        try:
+            await self.relationship_repo.create_many([rel for _, rel in valid])
+            for _ in valid:
+                progress.succeeded()
+        except Exception:
+            # Isolate the rows that fail (e.g. a node deleted meanwhile) and keep the rest
+            for line_number, rel in valid:
+                try:
+                    await self.relationship_repo.create(rel)
+                    progress.succeeded()
+                except Exception as e:
+                    _fail(progress, failures, line_number, e)
+
+
+async def _lines(chunks: AsyncIterator[bytes]) -> AsyncIterator[Tuple[int, bytes]]:
+    """Split a byte stream into numbered non-blank lines."""
+    buffer = b""
+    line_number = 0
+    async for chunk in chunks:
+        buffer += chunk
+        *lines, buffer = buffer.split(b"\n")
+        for line in lines:
+            line_number += 1
+            if line.strip():
+                yield line_number, line
+        if len(buffer) > MAX_LINE_BYTES:
+            raise ValueError(f"line {line_number + 1} exceeds {MAX_LINE_BYTES} bytes")
+    if buffer.strip():
+        yield line_number + 1, buffer
+
+
+async def _json_rows(chunks: AsyncIterator[bytes]) -> AsyncIterator[Tuple[int, Any]]:
+    """Yield the numbered rows of newline-delimited JSON; a line that is not JSON yields the ValueError."""
+    async for line_number, line in _lines(chunks):
+        try:
+            yield line_number, json.loads(line)
+        except (ValueError, UnicodeDecodeError) as e:
+            yield line_number, ValueError(f"invalid JSON: {e}")
+
+
+async def _csv_rows(chunks: AsyncIterator[bytes]) -> AsyncIterator[Tuple[int, Any]]:
+    """
+    Yield the numbered rows of a CSV with a header, leaving out empty cells.
+
+    Raises:
+        ValueError: If the header has an unknown or repeated column
+    """
+    header: List[Tuple[str, str]] = []
+    async for line_number, record in csv_records(chunks, ","):
+        if not header:
+            header = [_csv_column(name.strip()) for name in record]
+            if len(set(header)) != len(header):
+                raise ValueError("CSV header has duplicate column names")
+            continue
+        if len(record) > len(header):
+            yield line_number, ValueError(f"record has {len(record)} values but the header has {len(header)} columns")
+            continue
+        row: Dict[str, Any] = {}
+        for (key, alias_name), cell in zip(header, record):
+            if cell == "":
+                continue
+            if alias_name:
+                row[key] = {alias_name: cell}
+            else:
+                row[key] = cell
+        yield line_number, row
+    if not header:
+        raise ValueError("CSV has no header")
+
+
+def _csv_column(name: str) -> Tuple[str, str]:
+    """Return the row key of a CSV column and, for an alias column, the alias name."""
+    for endpoint in ENDPOINTS:
+        prefix = f"{endpoint}_alias."
+        if name.startswith(prefix) and len(name) > len(prefix):
+            return f"{endpoint}_alias", name[len(prefix):]
+    if name not in ROW_KEYS or name.endswith("_alias"):
+        raise ValueError(f"unknown CSV column: {name!r}")
+    return name, ""
+
+
+def _parse_alias(key: str, value: Any) -> Tuple[str, str]:
+    if not isinstance(value, dict) or len(value) != 1:
+        raise ValueError(f"{key} must be an object with one alias, e.g. {{\"email\": \"ada@example.com\"}}")
+    name, alias_value = next(iter(value.items()))
+    if not ALIAS_NAME_PATTERN.match(name):
+        raise ValueError(f"{key} has an invalid alias name {name!r}")
+    if not isinstance(alias_value, str) or not alias_value:
+        raise ValueError(f"{key}.{name} must be a non-empty string")
+    return name, alias_value
+
+
+def _check_row(
+    rel: Relationship, node_types: Dict[str, str], registered: Dict[str, RelationshipType]
+) -> Tuple[str, str]:
+    """Return why a row cannot be imported (or an empty string) and the node reference that did not resolve."""
+    for key in ("source_node_id", "target_node_id"):
+        if getattr(rel, key) not in node_types:
+            return f"node not found: {getattr(rel, key)}", f"{key}={getattr(rel, key)}"
+    rel_type = registered.get(rel.relationship_type)
+    if rel_type and rel_type.derived:
+        return f"relationship_type {rel.relationship_type} is derived; its relationships cannot be written", ""
+    if rel_type and not rel_type.allows(node_types[rel.source_node_id], node_types[rel.target_node_id]):
+        return (
+            f"relationship_type {rel.relationship_type} does not allow node_type "
+            f"{node_types[rel.source_node_id]} -> node_type {node_types[rel.target_node_id]}"
+        ), ""
+    return "", ""
+
+
+def _fail(
+    progress: OperationProgress,
+    failures: List[RowFailure],
+    line_number: int,
+    err: Exception,
+    reference: str = "",
+) -> None:
+    progress.failed(f"line {line_number}", err)
+    if len(failures) < MAX_REPORTED_FAILURES:
+        failures.append(RowFailure(line_number, str(err), reference))
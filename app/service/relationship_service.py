@@ -2,32 +2,113 @@
 Relationship service implementation.
 """
 
+from datetime import datetime
 from typing import List, Optional, Tuple
 
-from app.repository import Relationship, RelationshipRepository, NodeRepository, ListOptions, ListResult
+from app.repository import (
+    DuplicateRelationshipError,
+    Node,
+    FacetValue,
+    Relationship,
+    RelationshipRepository,
+    RelationshipType,
+    RelationshipTypeRepository,
+    NodeRepository,
+    ListResult,
+)
+from app.service.client_ids import parse_client_id
+from app.service.limits import TenantLimits
+from app.service.preconditions import check_if_match
+from app.service.relationship_type_service import check_duplicate_action
+from app.service.timestamps import check_date_interval, check_timezone, parse_local_timestamp, parse_timestamp
+
+
+def check_validity(valid_from: Optional[datetime], valid_to: Optional[datetime]) -> None:
+    """Raise ValueError unless a relationship validity interval is non-empty."""
+    if valid_from and valid_to and valid_to <= valid_from:
+        raise ValueError("valid_to must be after valid_from")
 
 
 class RelationshipService:
     """Relationship business logic service."""
 
-    def __init__(self, repo: RelationshipRepository, node_repo: NodeRepository):
+    def __init__(
+        self,
+        repo: RelationshipRepository,
+        node_repo: NodeRepository,
+        rel_type_repo: Optional[RelationshipTypeRepository] = None,
+        limits: Optional[TenantLimits] = None,
+    ):
         self.repo = repo
         self.node_repo = node_repo
+        self.rel_type_repo = rel_type_repo
+        self.limits = limits or TenantLimits()
 
     async def create(
         self,
         source_node_id: str,
         target_node_id: str,
         rel_type: str,
-        data: str
+        data: str,
+        valid_from: str = "",
+        valid_to: str = "",
+        id: str = "",
+        on_duplicate: str = "",
     ) -> Relationship:
-        """Create a new relationship."""
+        """
+        Create a new relationship, optionally valid only from valid_from and/or until valid_to.
+
+        id is an optional client-generated UUID for the relationship.
+        on_duplicate overrides the relationship type's rule for an identical
+        existing relationship (see create_or_get).
+
+        Raises:
+            AlreadyExistsError: If a relationship with that id exists
+            DuplicateRelationshipError: If an identical relationship exists and duplicates are rejected
+        """
+        rel, _ = await self.create_or_get(
+            source_node_id, target_node_id, rel_type, data, valid_from, valid_to, id, on_duplicate
+        )
+        return rel
+
+    async def create_or_get(
+        self,
+        source_node_id: str,
+        target_node_id: str,
+        rel_type: str,
+        data: str,
+        valid_from: str = "",
+        valid_to: str = "",
+        id: str = "",
+        on_duplicate: str = "",
+    ) -> Tuple[Relationship, bool]:
+        """
+        Create a new relationship like create(); returns it and whether it was created.
+
+        A relationship is a duplicate of an existing one with the same source,
+        target and type (in either orientation for undirected types), whatever
+        their data and validity. on_duplicate ("allow", "reject" or
+        "return_existing") decides what creating one does, defaulting to the
+        registered type's on_duplicate ("allow" for unregistered types). With
+        "return_existing", the oldest identical relationship is returned
+        unchanged and nothing is created.
+
+        Raises:
+            AlreadyExistsError: If a relationship with that id exists
+            DuplicateRelationshipError: If an identical relationship exists and duplicates are rejected
+        """
         if not source_node_id:
             raise ValueError("source_node_id is required")
         if not target_node_id:
             raise ValueError("target_node_id is required")
         if not rel_type:
             raise ValueError("relationship_type is required")
+        if on_duplicate:
+            check_duplicate_action(on_duplicate)
+        id = parse_client_id(id)
+        valid_from_ts = parse_timestamp(valid_from, "valid_from")
+        valid_to_ts = parse_timestamp(valid_to, "valid_to")
+        check_validity(valid_from_ts, valid_to_ts)
 
         # Validate that the source node exists (repository is already scoped to tenant database)
         source_node = await self.node_repo.get_by_id(source_node_id)
@@ -35,14 +116,27 @@ class RelationshipService:
         # Validate that the target node exists (repository is already scoped to tenant database)
         target_node = await self.node_repo.get_by_id(target_node_id)
 
+        registered = await self._validate_type_constraints(rel_type, source_node, target_node)
+
         rel = Relationship(
+            id=id,
             tenant_id="",  # Not stored in tenant database
             source_node_id=source_node_id,
             target_node_id=target_node_id,
             relationship_type=rel_type,
             data=data,
+            valid_from=valid_from_ts,
+            valid_to=valid_to_ts,
         )
-        return await self.repo.create(rel)
+        on_duplicate = on_duplicate or (registered.on_duplicate if registered else "allow")
+        if on_duplicate == "allow":
+            return await self.repo.create(rel), True
+
+        undirected = registered is not None and registered.directionality == "undirected"
+        rel, created = await self.repo.create_unless_exists(rel, undirected)
+        if not created and on_duplicate == "reject":
+            raise DuplicateRelationshipError(rel_type, source_node_id, target_node_id, rel.id)
+        return rel, created
 
     async def get_by_id(self, id: str) -> Relationship:
         """Retrieve a relationship by ID."""
@@ -50,19 +144,40 @@ class RelationshipService:
             raise ValueError("id is required")
         return await self.repo.get_by_id(id)
 
-    async def update(self, id: str, rel_type: str, data: str) -> Relationship:
-        """Update an existing relationship."""
+    async def update(
+        self,
+        id: str,
+        rel_type: str,
+        data: str,
+        if_match: str = "",
+        valid_from: Optional[str] = None,
+        valid_to: Optional[str] = None,
+    ) -> Relationship:
+        """
+        Update an existing relationship, only if its etag matches if_match when given.
+
+        valid_from and valid_to are unchanged when None; an empty string clears them.
+        """
         if not id:
             raise ValueError("id is required")
 
         rel = await self.repo.get_by_id(id)
+        expected_updated_at = check_if_match(rel.etag, rel.updated_at, if_match, f"relationship {id}")
 
-        if rel_type:
+        if rel_type and rel_type != rel.relationship_type:
+            source_node = await self.node_repo.get_by_id(rel.source_node_id)
+            target_node = await self.node_repo.get_by_id(rel.target_node_id)
+            await self._validate_type_constraints(rel_type, source_node, target_node)
             rel.relationship_type = rel_type
         if data:
             rel.data = data
+        if valid_from is not None:
+            rel.valid_from = parse_timestamp(valid_from, "valid_from")
+        if valid_to is not None:
+            rel.valid_to = parse_timestamp(valid_to, "valid_to")
+        check_validity(rel.valid_from, rel.valid_to)
 
-        return await self.repo.update(rel)
+        return await self.repo.update(rel, expected_updated_at)
 
     async def delete(self, id: str) -> None:
         """Delete a relationship."""
@@ -76,8 +191,77 @@ class RelationshipService:
         target_node_id: Optional[str],
         rel_type: Optional[str],
         page_size: int,
-        page_token: str
+        page_token: str,
+        order_by: str = "",
+        valid_at: str = "",
+        dedupe: bool = False
     ) -> Tuple[List[Relationship], ListResult]:
-        """Retrieve relationships with pagination and optional filtering."""
-        opts = ListOptions(page_size=page_size, page_token=page_token)
-        return await self.repo.list(source_node_id, target_node_id, rel_type, opts)
+        """Retrieve relationships with pagination and optional filtering.
+
+        Relationships of derived types are listed when filtering by that type.
+        With valid_at (ISO 8601), only relationships valid at that time are listed.
+        With dedupe, parallel relationships of the same type between the same
+        nodes are listed once, with parallel_count.
+        """
+        valid_at_ts = parse_timestamp(valid_at, "valid_at")
+        opts = self.limits.list_options(page_size, page_token, order_by)
+        derived = None
+        if rel_type and self.rel_type_repo:
+            registered = await self.rel_type_repo.get_by_name(rel_type)
+            if registered and registered.derived:
+                derived = registered
+        return await self.repo.list(source_node_id, target_node_id, rel_type, opts, derived, valid_at_ts, dedupe)
+
+    async def distinct_values(
+        self,
+        field: str,
+        rel_type: Optional[str],
+        limit: int
+    ) -> List[FacetValue]:
+        """Count relationships per distinct value of a field (for faceted filters)."""
+        if not field:
+            raise ValueError("field is required")
+        return await self.repo.distinct_values(field, rel_type, limit)
+
+    async def date_histogram(
+        self,
+        field: str,
+        rel_type: Optional[str],
+        interval: str = "day",
+        time_zone: str = "UTC",
+        start: str = "",
+        end: str = "",
+    ) -> List[FacetValue]:
+        """
+        Count relationships per calendar interval (e.g. per day) of created_at or
+        updated_at, with intervals local to time_zone so that daily counts
+        match the tenant's days. start and end (ISO 8601 timestamps, or dates
+        meaning local midnight) bound the counted range.
+        """
+        if not field:
+            raise ValueError("field is required")
+        check_date_interval(interval)
+        check_timezone(time_zone)
+        start_ts = parse_local_timestamp(start, "start", time_zone)
+        end_ts = parse_local_timestamp(end, "end", time_zone)
+        return await self.repo.date_histogram(field, rel_type, interval, time_zone, start_ts, end_ts)
+
+    async def _validate_type_constraints(
+        self, rel_type: str, source_node: Node, target_node: Node
+    ) -> Optional[RelationshipType]:
+        """Enforce endpoint constraints of a registered relationship type; returns the registered type.
+
+        Unregistered relationship types are accepted without constraints.
+        Relationships of derived types are computed and cannot be written.
+        """
+        if not self.rel_type_repo:
+            return None
+        registered = await self.rel_type_repo.get_by_name(rel_type)
+        if registered and registered.derived:
+            raise ValueError(f"relationship_type {rel_type} is derived; its relationships cannot be written")
+        if registered and not registered.allows(source_node.node_type_id, target_node.node_type_id):
+            raise ValueError(
+                f"relationship_type {rel_type} does not allow node_type {source_node.node_type_id} "
+                f"-> node_type {target_node.node_type_id}"
+            )
+        return registered
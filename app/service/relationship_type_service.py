@@ -0,0 +1,311 @@
+"""
+RelationshipType service implementation.
+
+A derived relationship type has no stored relationships; they are computed
+from a path of other relationship types. For example, COLLEAGUE with the
+path EMPLOYED_BY (out), EMPLOYED_BY (in) connects every two people employed
+by the same organization. Derived relationships are computed at query time,
+or read from a snapshot for materialized types (refreshed on demand).
+"""
+
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import (
+    RelationshipType,
+    RelationshipTypeRepository,
+    RelationshipRepository,
+    NodeTypeRepository,
+    ListResult,
+)
+from app.service.limits import TenantLimits
+
+DIRECTIONALITIES = ("directed", "undirected")
+DELETE_ACTIONS = ("delete_edge", "delete_node", "restrict")
+DUPLICATE_ACTIONS = ("allow", "reject", "return_existing")
+STEP_DIRECTIONS = ("out", "in")
+MIN_DERIVATION_STEPS = 2
+MAX_DERIVATION_STEPS = 3
+
+
+class RelationshipTypeService:
+    """RelationshipType business logic service."""
+
+    def __init__(
+        self,
+        repo: RelationshipTypeRepository,
+        node_type_repo: NodeTypeRepository,
+        limits: Optional[TenantLimits] = None,
+        relationship_repo: Optional[RelationshipRepository] = None,
+    ):
+        self.repo = repo
+        self.node_type_repo = node_type_repo
+        self.limits = limits or TenantLimits()
+        # Needed for derived types (checks and materialized snapshots)
+        self.relationship_repo = relationship_repo
+
+    async def create(
+        self,
+        name: str,
+        description: str,
+        directionality: str,
+        allowed_source_node_type_ids: Optional[List[str]],
+        allowed_target_node_type_ids: Optional[List[str]],
+        on_source_delete: str = "",
+        on_target_delete: str = "",
+        derivation: Optional[Dict[str, Any]] = None,
+        on_duplicate: str = "",
+    ) -> RelationshipType:
+        """Register a new relationship type; with derivation, a derived type."""
+        if not name:
+            raise ValueError("name is required")
+        directionality = directionality or "directed"
+        self._validate_directionality(directionality)
+        on_source_delete = on_source_delete or "delete_edge"
+        on_target_delete = on_target_delete or "delete_edge"
+        self._validate_delete_action("on_source_delete", on_source_delete)
+        self._validate_delete_action("on_target_delete", on_target_delete)
+        on_duplicate = on_duplicate or "allow"
+        check_duplicate_action(on_duplicate)
+
+        sources = list(allowed_source_node_type_ids or [])
+        targets = list(allowed_target_node_type_ids or [])
+        await self._validate_node_types(sources + targets)
+        if derivation is not None:
+            derivation = await self._check_derivation(name, derivation)
+
+        rel_type = RelationshipType(
+            tenant_id="",  # Not stored in tenant database
+            name=name,
+            description=description,
+            directionality=directionality,
+            allowed_source_node_type_ids=sources,
+            allowed_target_node_type_ids=targets,
+            on_source_delete=on_source_delete,
+            on_target_delete=on_target_delete,
+            on_duplicate=on_duplicate,
+            derivation=derivation,
+        )
+        rel_type = await self.repo.create(rel_type)
+        await self._refresh_snapshot(rel_type)
+        return rel_type
+
+    async def get_by_id(self, id: str) -> RelationshipType:
+        """Retrieve a relationship type by ID."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_by_id(id)
+
+    async def update(
+        self,
+        id: str,
+        name: str,
+        description: str,
+        directionality: str,
+        allowed_source_node_type_ids: Optional[List[str]],
+        allowed_target_node_type_ids: Optional[List[str]],
+        on_source_delete: str = "",
+        on_target_delete: str = "",
+        derivation: Optional[Dict[str, Any]] = None,
+        on_duplicate: str = "",
+    ) -> RelationshipType:
+        """Update an existing relationship type.
+
+        Endpoint lists are replaced when provided (pass an empty list to allow any node type).
+        derivation replaces the type's derivation when provided; an empty
+        object makes a derived type a stored one again.
+        """
+        if not id:
+            raise ValueError("id is required")
+
+        rel_type = await self.repo.get_by_id(id)
+        previous_name, was_materialized = rel_type.name, _materialized(rel_type)
+
+        if name:
+            rel_type.name = name
+        if description:
+            rel_type.description = description
+        if directionality:
+            self._validate_directionality(directionality)
+            rel_type.directionality = directionality
+        if allowed_source_node_type_ids is not None:
+            await self._validate_node_types(allowed_source_node_type_ids)
+            rel_type.allowed_source_node_type_ids = list(allowed_source_node_type_ids)
+        if allowed_target_node_type_ids is not None:
+            await self._validate_node_types(allowed_target_node_type_ids)
+            rel_type.allowed_target_node_type_ids = list(allowed_target_node_type_ids)
+        if on_source_delete:
+            self._validate_delete_action("on_source_delete", on_source_delete)
+            rel_type.on_source_delete = on_source_delete
+        if on_target_delete:
+            self._validate_delete_action("on_target_delete", on_target_delete)
+            rel_type.on_target_delete = on_target_delete
+        if on_duplicate:
+            check_duplicate_action(on_duplicate)
+            rel_type.on_duplicate = on_duplicate
+        if derivation is not None:
+            rel_type.derivation = await self._check_derivation(rel_type.name, derivation, rel_type.id) or None
+        elif rel_type.derived and rel_type.name != previous_name:
+            rel_type.derivation = await self._check_derivation(rel_type.name, rel_type.derivation, rel_type.id)
+
+        rel_type = await self.repo.update(rel_type)
+        if was_materialized and self.relationship_repo:
+            await self.relationship_repo.clear_derived(previous_name)
+        await self._refresh_snapshot(rel_type)
+        return rel_type
+
+    async def delete(self, id: str) -> None:
+        """Delete a relationship type."""
+        if not id:
+            raise ValueError("id is required")
+        rel_type = await self.repo.get_by_id(id)
+        await self.repo.delete(id)
+        if _materialized(rel_type) and self.relationship_repo:
+            await self.relationship_repo.clear_derived(rel_type.name)
+
+    async def refresh_derived(self, id: str) -> Tuple[RelationshipType, int]:
+        """
+        Recompute the snapshot of a materialized derived type; returns the
+        type and its number of relationships.
+
+        Raises:
+            ValueError: If the type is not a materialized derived type
+        """
+        if not id:
+            raise ValueError("id is required")
+        rel_type = await self.repo.get_by_id(id)
+        if not _materialized(rel_type):
+            raise ValueError(f"relationship_type {rel_type.name} is not a materialized derived type")
+        return rel_type, await self._refresh_snapshot(rel_type)
+
+    async def list(self, page_size: int, page_token: str, order_by: str = "") -> Tuple[List[RelationshipType], ListResult]:
+        """Retrieve relationship types with pagination."""
+        opts = self.limits.list_options(page_size, page_token, order_by)
+        return await self.repo.list(opts)
+
+    async def discover(
+        self,
+        source_node_type_id: Optional[str],
+        target_node_type_id: Optional[str],
+    ) -> List[dict]:
+        """
+        Describe which relationship types can connect the given node types.
+
+        Returns one entry per applicable relationship type with the node types
+        that may appear on the other end, for rendering connection pickers.
+        """
+        result = []
+        for rel_type in await self.repo.list_all():
+            # Derived relationships cannot be created
+            if rel_type.derived:
+                continue
+            if source_node_type_id and target_node_type_id:
+                if not rel_type.allows(source_node_type_id, target_node_type_id):
+                    continue
+            elif source_node_type_id:
+                if not self._accepts_endpoint(rel_type, source_node_type_id, as_source=True):
+                    continue
+            elif target_node_type_id:
+                if not self._accepts_endpoint(rel_type, target_node_type_id, as_source=False):
+                    continue
+
+            result.append({
+                "relationship_type": rel_type.to_dict(),
+                "source_node_type_ids": list(rel_type.allowed_source_node_type_ids),
+                "target_node_type_ids": list(rel_type.allowed_target_node_type_ids),
+            })
+        return result
+
+    @staticmethod
+    def _accepts_endpoint(rel_type: RelationshipType, node_type_id: str, as_source: bool) -> bool:
+        """Check whether a node type may appear on one end of a relationship type."""
+        sources = rel_type.allowed_source_node_type_ids
+        targets = rel_type.allowed_target_node_type_ids
+        primary, secondary = (sources, targets) if as_source else (targets, sources)
+        if not primary or node_type_id in primary:
+            return True
+        return rel_type.directionality == "undirected" and (not secondary or node_type_id in secondary)
+
+    @staticmethod
+    def _validate_directionality(directionality: str) -> None:
+        if directionality not in DIRECTIONALITIES:
+            raise ValueError(f"directionality must be one of: {', '.join(DIRECTIONALITIES)}")
+
+    @staticmethod
+    def _validate_delete_action(name: str, action: str) -> None:
+        if action not in DELETE_ACTIONS:
+            raise ValueError(f"{name} must be one of: {', '.join(DELETE_ACTIONS)}")
+
+    async def _check_derivation(
+        self, name: str, derivation: Dict[str, Any], id: str = ""
+    ) -> Optional[Dict[str, Any]]:
+        """
+        Validate and normalize a derivation; returns None for an empty one.
+
+        Steps must name stored (non-derived) relationship types other than
+        the type itself, and a type with stored relationships, or that other
+        derived types are built from, cannot become derived.
+        """
+        if not isinstance(derivation, dict):
+            raise ValueError("derivation must be an object")
+        if not derivation:
+            return None
+        unknown = [k for k in derivation if k not in ("path", "materialized")]
+        if unknown:
+            raise ValueError(f"unknown derivation keys: {', '.join(unknown)} (allowed: path, materialized)")
+        materialized = derivation.get("materialized", False)
+        if not isinstance(materialized, bool):
+            raise ValueError("derivation.materialized must be a boolean")
+        path = derivation.get("path")
+        if not isinstance(path, list) or not MIN_DERIVATION_STEPS <= len(path) <= MAX_DERIVATION_STEPS:
+            raise ValueError(
+                f"derivation.path must have {MIN_DERIVATION_STEPS} to {MAX_DERIVATION_STEPS} steps"
+            )
+
+        steps = []
+        for i, step in enumerate(path):
+            step_type = step.get("relationship_type") if isinstance(step, dict) else None
+            direction = (step.get("direction") or "out") if isinstance(step, dict) else None
+            if not step_type or not isinstance(step_type, str):
+                raise ValueError(f"derivation.path[{i}].relationship_type is required")
+            if direction not in STEP_DIRECTIONS:
+                raise ValueError(f"derivation.path[{i}].direction must be one of: {', '.join(STEP_DIRECTIONS)}")
+            if step_type == name:
+                raise ValueError(f"derivation.path[{i}]: a derived type cannot be derived from itself")
+            registered = await self.repo.get_by_name(step_type)
+            if registered and registered.derived:
+                raise ValueError(f"derivation.path[{i}]: {step_type} is itself derived")
+            steps.append({"relationship_type": step_type, "direction": direction})
+
+        await self._check_not_derived_from(name, id)
+        if self.relationship_repo and await self.relationship_repo.count_by_type(name):
+            raise ValueError(f"relationship_type {name} has stored relationships and cannot be derived")
+        return {"path": steps, "materialized": materialized}
+
+    async def _check_not_derived_from(self, name: str, id: str) -> None:
+        """Reject changes to a type that derived types are built from."""
+        for other in await self.repo.list_all():
+            if other.derived and other.id != id and any(
+                step["relationship_type"] == name for step in other.derivation["path"]
+            ):
+                raise ValueError(f"relationship_type {name} is used by derived type {other.name}")
+
+    async def _refresh_snapshot(self, rel_type: RelationshipType) -> int:
+        """Recompute the snapshot of a materialized derived type (no-op for other types)."""
+        if not _materialized(rel_type) or not self.relationship_repo:
+            return 0
+        return await self.relationship_repo.refresh_derived(rel_type)
+
+    async def _validate_node_types(self, node_type_ids: List[str]) -> None:
+        """Ensure every referenced node type exists."""
+        for node_type_id in set(node_type_ids):
+            await self.node_type_repo.get_by_id(node_type_id)
+
+
+def check_duplicate_action(action: str) -> None:
+    """Raise ValueError unless action is a valid on_duplicate value."""
+    if action not in DUPLICATE_ACTIONS:
+        raise ValueError(f"on_duplicate must be one of: {', '.join(DUPLICATE_ACTIONS)}")
+
+
+def _materialized(rel_type: RelationshipType) -> bool:
+    return rel_type.derived and bool(rel_type.derivation.get("materialized"))
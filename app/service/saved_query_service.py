@@ -0,0 +1,172 @@
+"""
+Saved queries: named query definitions a tenant stores once and runs by name.
+
+A saved query names a read method (such as ``list_nodes`` or
+``get_subgraph``) and its parameters, so dashboards run ``run_saved_query``
+with a name and a few arguments instead of shipping the whole definition on
+every call. Parameter values of the form ``"$name"`` are placeholders,
+replaced by the run's argument of that name (keeping its JSON type):
+
+    {"method": "list_nodes",
+     "params": {"node_type_id": "$type", "metadata": {"source": "$source"}, "order_by": "updated_at desc"},
+     "parameters": {"type": null, "source": "crm"}}
+
+``parameters`` declares every placeholder with its default; a null default
+makes the argument required. Each run is counted with its duration, so the
+hottest and slowest saved queries can be found and tuned server-side.
+"""
+
+import re
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from app.repository import ListResult, SavedQuery, SavedQueryRepository
+from app.service.limits import TenantLimits
+
+# Read methods a saved query can run
+QUERY_METHODS = (
+    "list_nodes", "search_nodes", "list_relationships", "lookup_node_by_alias",
+    "get_subgraph", "get_graph_view", "get_distinct_values", "get_date_histogram",
+)
+# Parameters every run supplies itself
+RESERVED_PARAMS = ("tenant_id",)
+NAME_PATTERN = re.compile(r"^[a-z][a-z0-9_.-]{0,127}$")
+PARAMETER_PATTERN = re.compile(r"^[a-z][a-z0-9_]{0,62}$")
+
+
+def placeholders(value: Any) -> Set[str]:
+    """Return the names of the "$name" placeholders in a definition's parameters."""
+    if isinstance(value, dict):
+        return set().union(*(placeholders(v) for v in value.values())) if value else set()
+    if isinstance(value, list):
+        return set().union(*(placeholders(v) for v in value)) if value else set()
+    if isinstance(value, str) and value.startswith("$") and PARAMETER_PATTERN.match(value[1:]):
+        return {value[1:]}
+    return set()
+
+
+def substitute(value: Any, args: Dict[str, Any]) -> Any:
+    """Return a definition's parameters with their placeholders replaced by args."""
+    if isinstance(value, dict):
+        return {k: substitute(v, args) for k, v in value.items()}
+    if isinstance(value, list):
+        return [substitute(v, args) for v in value]
+    if isinstance(value, str) and value.startswith("$") and value[1:] in args:
+        return args[value[1:]]
+    return value
+
+
+class SavedQueryService:
+    """Saved query business logic service."""
+
+    def __init__(self, repo: SavedQueryRepository, limits: Optional[TenantLimits] = None):
+        self.repo = repo
+        self.limits = limits or TenantLimits()
+
+    async def create(
+        self,
+        name: str,
+        method: str,
+        params: Optional[Dict[str, Any]] = None,
+        parameters: Optional[Dict[str, Any]] = None,
+        description: str = "",
+    ) -> SavedQuery:
+        """
+        Save a query definition.
+
+        Raises:
+            ValueError: If the definition is invalid
+            AlreadyExistsError: If a saved query has the name
+        """
+        query = SavedQuery(
+            name=name, description=description or "", method=method,
+            params=params or {}, parameters=parameters or {},
+        )
+        _validate(query)
+        return await self.repo.create(query)
+
+    async def get_by_id(self, id: str) -> SavedQuery:
+        """Retrieve a saved query by ID."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_by_id(id)
+
+    async def update(
+        self,
+        id: str,
+        name: str = "",
+        method: str = "",
+        params: Optional[Dict[str, Any]] = None,
+        parameters: Optional[Dict[str, Any]] = None,
+        description: Optional[str] = None,
+    ) -> SavedQuery:
+        """Update a saved query; omitted fields are kept."""
+        query = await self.get_by_id(id)
+        if name:
+            query.name = name
+        if method:
+            query.method = method
+        if params is not None:
+            query.params = params
+        if parameters is not None:
+            query.parameters = parameters
+        if description is not None:
+            query.description = description
+        _validate(query)
+        return await self.repo.update(query)
+
+    async def delete(self, id: str) -> None:
+        """Delete a saved query."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete(id)
+
+    async def list(self, page_size: int, page_token: str) -> Tuple[List[SavedQuery], ListResult]:
+        """Retrieve saved queries by name."""
+        return await self.repo.list(self.limits.list_options(page_size, page_token))
+
+    async def resolve(self, name: str, args: Optional[Dict[str, Any]] = None) -> Tuple[SavedQuery, Dict[str, Any]]:
+        """
+        Return a saved query and the parameters to run its method with, given the run's arguments.
+
+        Raises:
+            NotFoundError: If no saved query has the name
+            ValueError: If an argument is unknown or a required one is missing
+        """
+        if not name:
+            raise ValueError("name is required")
+        args = args or {}
+        if not isinstance(args, dict):
+            raise ValueError("params must be an object")
+        query = await self.repo.get_by_name(name)
+        unknown = sorted(set(args) - set(query.parameters))
+        if unknown:
+            raise ValueError(f"unknown parameters of saved query {name}: {', '.join(unknown)}")
+        values = {**{k: v for k, v in query.parameters.items() if v is not None}, **args}
+        missing = sorted(set(query.parameters) - set(values))
+        if missing:
+            raise ValueError(f"missing parameters of saved query {name}: {', '.join(missing)}")
+        return query, substitute(query.params, values)
+
+    async def record_run(self, id: str, seconds: float) -> None:
+        """Count a run of a saved query."""
+        await self.repo.record_run(id, seconds * 1000)
+
+
+def _validate(query: SavedQuery) -> None:
+    if not NAME_PATTERN.match(query.name or ""):
+        raise ValueError("name must be up to 128 lowercase letters, digits, '_', '.' or '-', starting with a letter")
+    if query.method not in QUERY_METHODS:
+        raise ValueError(f"method must be one of: {', '.join(QUERY_METHODS)}")
+    if not isinstance(query.params, dict):
+        raise ValueError("params must be an object")
+    if not isinstance(query.parameters, dict):
+        raise ValueError("parameters must be an object of parameter names and defaults")
+    reserved = sorted(set(query.params) & set(RESERVED_PARAMS))
+    if reserved:
+        raise ValueError(f"params cannot set {', '.join(reserved)}; runs supply it")
+    invalid = sorted(p for p in query.parameters if not PARAMETER_PATTERN.match(p))
+    if invalid:
+        raise ValueError(f"invalid parameter names: {', '.join(invalid)}")
+    undeclared = sorted(placeholders(query.params) - set(query.parameters))
+    if undeclared:
+        raise ValueError(f"placeholders must be declared in parameters: {', '.join(undeclared)}")
@@ -0,0 +1,190 @@
+"""
+Full-text node search.
+
+search_nodes ranks nodes with the configured search index (see
+app/search/search_index.py) or, without one, with PostgreSQL full-text
+search over node data.
+
+The search indexer keeps each tenant's index in step with its nodes by
+following the tenant's incremental export stream (ExportService.changes):
+written nodes are upserted and node tombstones deleted, and the sync cursor
+reached is stored per tenant in the control database. A tenant without a
+cursor, or whose cursor has expired, is indexed again from a full export.
+Changes may be applied twice, which upserts make harmless.
+
+The index lags behind the tenant database until the indexer's next run. A
+search with a consistency token uses the index only if the tenant's stored
+cursor includes the write behind the token, and PostgreSQL full-text search
+otherwise.
+"""
+
+import logging
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from app.repository import (
+    ListResult,
+    Node,
+    NodeFilter,
+    NodeRepository,
+    PreconditionFailedError,
+    SearchCursorRepository,
+    TenantRepository,
+)
+from app.search import SearchIndex
+from app.service.consistency import covers, parse_token
+from app.service.export_service import cursor_position
+from app.service.limits import TenantLimits
+
+logger = logging.getLogger(__name__)
+
+# Nodes read per export or change page while indexing (capped by the tenant's max page size)
+INDEX_PAGE_SIZE = 500
+
+
+def _offset(page_token: str) -> int:
+    if not page_token:
+        return 0
+    try:
+        offset = int(page_token)
+    except ValueError:
+        raise ValueError("invalid page_token")
+    if offset < 0:
+        raise ValueError("invalid page_token")
+    return offset
+
+
+class SearchService:
+    """Tenant full-text node search business logic service."""
+
+    def __init__(
+        self,
+        node_repo: NodeRepository,
+        tenant_id: str = "",
+        limits: Optional[TenantLimits] = None,
+        index: Optional[SearchIndex] = None,
+        cursor_repo: Optional[SearchCursorRepository] = None,
+    ):
+        self.node_repo = node_repo
+        self.tenant_id = tenant_id
+        self.limits = limits or TenantLimits()
+        self.index = index
+        self.cursor_repo = cursor_repo
+
+    async def search(
+        self,
+        query: str,
+        node_type_id: str,
+        page_size: int,
+        page_token: str,
+        consistency_token: str = "",
+        filters: Optional[NodeFilter] = None,
+    ) -> Tuple[List[Tuple[Node, float]], ListResult]:
+        """
+        Return a page of the nodes whose data matches a query, best first, with their scores.
+
+        With a consistency token, the results include the write that returned it.
+        filters, a whole node filter (such as a view's), replaces node_type_id;
+        filters on more than the node type are searched in PostgreSQL.
+
+        Raises:
+            ValueError: If the query is empty or the page token or consistency token is invalid
+        """
+        if not query or not query.strip():
+            raise ValueError("query is required")
+        offset = _offset(page_token)
+        limit = self.limits.list_options(page_size, page_token).effective_page_size()
+        if consistency_token:
+            parse_token(consistency_token)
+
+        if filters:
+            node_type_id = filters.node_type_id
+        # The index only filters by node type
+        indexable = not filters or filters == NodeFilter(node_type_id=node_type_id)
+
+        if indexable and self.index and (not consistency_token or await self.index_covers(consistency_token)):
+            hits, total = await self.index.search(self.tenant_id, query, node_type_id, offset, limit)
+            # The database is authoritative; nodes deleted since they were indexed are left out
+            nodes = {n.id: n for n in await self.node_repo.get_many([h.node_id for h in hits])}
+            results = [(nodes[h.node_id], h.score) for h in hits if h.node_id in nodes]
+        else:
+            results, total = await self.node_repo.search_text(query, node_type_id or None, offset, limit, filters)
+            hits = results
+
+        result = ListResult(total_count=total)
+        if offset + len(hits) < total:
+            result.next_page_token = str(offset + len(hits))
+        return results, result
+
+    async def index_covers(self, consistency_token: str) -> bool:
+        """Whether the search index includes the write behind a consistency token."""
+        if not self.index or not self.cursor_repo:
+            return False
+        cursor = await self.cursor_repo.get(self.tenant_id)
+        return bool(cursor) and covers(cursor_position(cursor), consistency_token)
+
+
+class SearchIndexer:
+    """Mirrors every tenant's node writes into the search index."""
+
+    def __init__(
+        self,
+        tenant_repo: TenantRepository,
+        cursor_repo: SearchCursorRepository,
+        tenant_services: Callable[[str], Awaitable[Dict[str, Any]]],
+        index: SearchIndex,
+    ):
+        self.tenant_repo = tenant_repo
+        self.cursor_repo = cursor_repo
+        self.tenant_services = tenant_services
+        self.index = index
+
+    async def run(self) -> int:
+        """Bring every tenant's index up to date; returns the number of nodes indexed or removed."""
+        count = 0
+        for tenant_id, slug, _ in await self.tenant_repo.list_databases():
+            try:
+                count += await self.sync_tenant(tenant_id)
+            except Exception as e:
+                logger.error(f"Indexing tenant {slug} ({tenant_id}) for search failed: {e}")
+        return count
+
+    async def sync_tenant(self, tenant_id: str) -> int:
+        """Bring a tenant's index up to date; returns the number of nodes indexed or removed."""
+        export = (await self.tenant_services(tenant_id))["export"]
+        cursor = await self.cursor_repo.get(tenant_id)
+        if cursor:
+            try:
+                return await self._apply_changes(tenant_id, export, cursor)
+            except PreconditionFailedError:
+                logger.warning(f"Search index cursor of tenant {tenant_id} expired; indexing it again")
+        return await self._reindex(tenant_id, export)
+
+    async def _reindex(self, tenant_id: str, export: Any) -> int:
+        await self.index.delete_tenant(tenant_id)
+        count, page_token, cursor = 0, "", ""
+        while True:
+            nodes, _, result, cursor = await export.export(None, False, INDEX_PAGE_SIZE, page_token)
+            await self.index.upsert_nodes(tenant_id, nodes)
+            count += len(nodes)
+            page_token = result.next_page_token
+            if not page_token:
+                break
+        # Changes made during the export are picked up from its cursor on the next run
+        await self.cursor_repo.set(tenant_id, cursor)
+        return count
+
+    async def _apply_changes(self, tenant_id: str, export: Any, cursor: str) -> int:
+        count, page_token, next_cursor = 0, "", cursor
+        while True:
+            nodes, _, tombstones, result, next_cursor = await export.changes(
+                cursor, None, False, INDEX_PAGE_SIZE, page_token
+            )
+            await self.index.upsert_nodes(tenant_id, nodes)
+            deleted = [t.entity_id for t in tombstones if t.entity_type == "node"]
+            await self.index.delete_nodes(tenant_id, deleted)
+            count += len(nodes) + len(deleted)
+            page_token = result.next_page_token
+            if not page_token:
+                break
+        await self.cursor_repo.set(tenant_id, next_cursor)
+        return count
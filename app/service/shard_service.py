@@ -0,0 +1,92 @@
+"""
+Shard administration: shard health and tenant counts, rebalancing plans and
+tenant moves between shards (see app/db/sharding.py).
+
+Placement by consistent hashing only applies to new tenants, so adding a
+shard leaves existing tenants where they are. plan_rebalance lists the
+tenants whose shard differs from their ring placement, which are about
+1/N of them after an Nth shard is added, and move() moves one of them.
+
+A move copies the tenant's database while the tenant is read-only for
+maintenance; afterwards, instances switch to the new shard within
+POOL_RECHECK_SECONDS, so maintenance should not end before then.
+"""
+
+import logging
+from typing import Any, Dict, List, Tuple
+
+from app.db.tenant_db_manager import TenantDatabaseManager
+from app.repository import PreconditionFailedError, ShardMapRepository, TenantRepository
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_REBALANCE_LIMIT = 100
+MAX_REBALANCE_LIMIT = 1000
+
+
+class ShardService:
+    """Reports on and rebalances the shards tenant databases live on."""
+
+    def __init__(self, repo: ShardMapRepository, db_manager: TenantDatabaseManager, tenant_repo: TenantRepository):
+        self.repo = repo
+        self.db_manager = db_manager
+        self.tenant_repo = tenant_repo
+
+    async def list_shards(self) -> List[Dict[str, Any]]:
+        """The configured shards with their servers, last health check and tenant counts."""
+        router = self.db_manager.shards
+        counts = await self.repo.count_by_shard()
+        shards = []
+        for shard in router.shards:
+            host, port = router.server(shard)
+            health = router.health.get(shard)
+            shards.append({
+                "name": shard,
+                "host": host,
+                "port": port,
+                "healthy": router.is_healthy(shard),
+                "health": health.to_dict() if health else None,
+                "tenant_count": counts.get(shard, 0),
+            })
+        return shards
+
+    async def plan_rebalance(self, limit: int = 0) -> Tuple[List[Dict[str, str]], int]:
+        """
+        List tenants whose shard differs from their placement on the hash
+        ring, with the shard to move each to; returns up to limit moves and
+        the number of tenants to move in all.
+        """
+        limit = limit or DEFAULT_REBALANCE_LIMIT
+        if not 1 <= limit <= MAX_REBALANCE_LIMIT:
+            raise ValueError(f"limit must be between 1 and {MAX_REBALANCE_LIMIT}")
+        router = self.db_manager.shards
+        moves = []
+        for tenant_id, region, shard in await self.repo.list_placements():
+            if not self.db_manager.shardable(region):
+                continue
+            target = router.home(tenant_id)
+            if target != shard:
+                moves.append({"tenant_id": tenant_id, "shard": shard, "target_shard": target})
+        return moves[:limit], len(moves)
+
+    async def move(self, tenant_id: str, shard: str) -> Dict[str, Any]:
+        """
+        Move a tenant's database to another shard. The tenant must be
+        read-only for maintenance, so no writes are lost.
+
+        Raises:
+            NotFoundError: If the tenant does not exist
+            PreconditionFailedError: If the tenant is not in maintenance
+            ValueError: If the shard is not configured or the tenant cannot move to it
+        """
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        if not shard:
+            raise ValueError("shard is required")
+        tenant = await self.tenant_repo.get_by_id(tenant_id)
+        if not tenant.maintenance_started_at:
+            raise PreconditionFailedError(f"tenant {tenant_id} must be read-only for maintenance while it is moved")
+        if not self.db_manager.shards.is_healthy(shard):
+            raise PreconditionFailedError(f"shard {shard} is unhealthy")
+        moved = await self.db_manager.move_tenant_database(tenant_id, shard)
+        return {"tenant_id": tenant_id, "shard": shard, **moved}
@@ -0,0 +1,122 @@
+"""
+Operator statistics service implementation.
+
+Answers capacity questions (database sizes, row counts, index sizes, cache
+hit rates, slowest query classes) over JSON-RPC, so operators do not need
+psql access. All statistics come from PostgreSQL's statistics views; row
+counts are the planner's estimates, which are cheap on large tables.
+"""
+
+from typing import Any, Dict
+
+from app.db.tenant_db_manager import TenantDatabaseManager
+from app.repository import DatabaseStatsRepository, TenantRepository
+
+DEFAULT_SLOW_QUERY_LIMIT = 10
+MAX_SLOW_QUERY_LIMIT = 100
+
+
+class StatsService:
+    """System-wide and per-tenant database statistics for operators."""
+
+    def __init__(
+        self,
+        control_stats_repo: DatabaseStatsRepository,
+        tenant_repo: TenantRepository,
+        tenant_db_manager: TenantDatabaseManager,
+    ):
+        self.control_stats_repo = control_stats_repo
+        self.tenant_repo = tenant_repo
+        self.tenant_db_manager = tenant_db_manager
+
+    async def system_stats(self, slow_query_limit: int = DEFAULT_SLOW_QUERY_LIMIT) -> Dict[str, Any]:
+        """Cluster cache hit ratio, the control database, every tenant database's size and the slowest queries."""
+        _check_slow_query_limit(slow_query_limit)
+        databases = await self.tenant_repo.list_databases()
+        sizes = await self.control_stats_repo.list_database_sizes([name for _, _, name in databases])
+
+        tenants = []
+        for tenant_id, slug, name in databases:
+            size = sizes.get(name, {})
+            tenants.append({
+                "tenant_id": tenant_id,
+                "slug": slug,
+                "database_name": name,
+                "size_bytes": size.get("size_bytes"),
+                "cache_hit_ratio": size.get("cache_hit_ratio"),
+            })
+        tenants.sort(key=lambda t: t["size_bytes"] or 0, reverse=True)
+
+        slow_queries = await self.control_stats_repo.list_slow_queries(slow_query_limit, current_database_only=False)
+        return {
+            "cache_hit_ratio": await self.control_stats_repo.get_cluster_cache_hit_ratio(),
+            "control_database": await self.control_stats_repo.get_database_stats(),
+            "tenant_count": len(tenants),
+            "total_tenant_size_bytes": sum(t["size_bytes"] or 0 for t in tenants),
+            "tenants": tenants,
+            "slow_queries": slow_queries or [],
+            "slow_queries_available": slow_queries is not None,
+        }
+
+    async def tenant_stats(self, tenant_id: str, slow_query_limit: int = DEFAULT_SLOW_QUERY_LIMIT) -> Dict[str, Any]:
+        """Size, cache hit ratio, table and index statistics and slowest queries of one tenant database."""
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        _check_slow_query_limit(slow_query_limit)
+        await self.tenant_repo.get_by_id(tenant_id)
+        repo = DatabaseStatsRepository(await self.tenant_db_manager.get_tenant_db(tenant_id))
+
+        tables = await repo.list_table_stats()
+        slow_queries = await repo.list_slow_queries(slow_query_limit, current_database_only=True)
+        return {
+            "tenant_id": tenant_id,
+            "database": await repo.get_database_stats(),
+            "row_counts": {t["table"]: t["row_count"] for t in tables},
+            "tables": tables,
+            "indexes": await repo.list_index_stats(),
+            "slow_queries": slow_queries or [],
+            "slow_queries_available": slow_queries is not None,
+        }
+
+
+    async def tenant_usage(self, tenant_id: str) -> Dict[str, Any]:
+        """
+        Database size and estimated node and relationship counts of a tenant and
+        each of its sub-tenants, with totals for the whole organization.
+        """
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        tenant = await self.tenant_repo.get_by_id(tenant_id)
+        tenants = [tenant] + await self.tenant_repo.list_descendants(tenant_id)
+        databases = {tid: name for tid, _, name in await self.tenant_repo.list_databases()}
+        sizes = await self.control_stats_repo.list_database_sizes([databases[t.id] for t in tenants if t.id in databases])
+
+        usage = []
+        for t in tenants:
+            row_counts: Dict[str, int] = {}
+            if t.id in databases and t.status != "pending_deletion":
+                repo = DatabaseStatsRepository(await self.tenant_db_manager.get_tenant_db(t.id))
+                row_counts = {table["table"]: table["row_count"] for table in await repo.list_table_stats()}
+            usage.append({
+                "tenant_id": t.id,
+                "slug": t.slug,
+                "parent_id": t.parent_id or None,
+                "status": t.status,
+                "size_bytes": sizes.get(databases.get(t.id, ""), {}).get("size_bytes") or 0,
+                "node_count": row_counts.get("nodes", 0),
+                "relationship_count": row_counts.get("relationships", 0),
+            })
+
+        return {
+            "tenant_id": tenant_id,
+            "tenants": usage,
+            "totals": {
+                "tenant_count": len(usage),
+                **{key: sum(u[key] for u in usage) for key in ("size_bytes", "node_count", "relationship_count")},
+            },
+        }
+
+
+def _check_slow_query_limit(limit: int) -> None:
+    if limit < 0 or limit > MAX_SLOW_QUERY_LIMIT:
+        raise ValueError(f"slow_query_limit must be between 0 and {MAX_SLOW_QUERY_LIMIT}")
@@ -0,0 +1,277 @@
+"""
+Offline sync: pushing changes made by edge clients while offline.
+
+Clients pull with export_tenant_changes and a sync cursor, and push the
+changes they made locally as a list applied in order. Each change names the
+entity version it was made against (base_etag) and, for updates, the values
+the changed top-level data fields had then (base_data). A field conflicts
+when both sides changed it: the server value is no longer the base value
+and differs from the client's. Fields changed on one side only merge, so two
+users editing different fields of a record both keep their edits. The
+conflict policy decides conflicting fields:
+
+- ``reject``: a change with any conflict is not applied
+- ``server_wins``: the client's other fields are applied
+- ``client_wins``: every field is applied (last writer wins per field)
+
+Conflicts are reported with the base, server and client values either way,
+so the client can show them or push a resolution. Creates use
+client-generated IDs, so pushing the same changes again is harmless.
+"""
+
+import json
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional, Tuple, Union
+
+from app.repository import (
+    AlreadyExistsError, DuplicateRelationshipError, Node, NotFoundError, PreconditionFailedError, Relationship,
+)
+from app.service.limits import TenantLimits
+from app.service.node_service import NodeService
+from app.service.relationship_service import RelationshipService
+
+ENTITIES = ("node", "relationship")
+ACTIONS = ("create", "update", "delete")
+CONFLICT_POLICIES = ("reject", "server_wins", "client_wins")
+# Times an update is re-merged when the entity changes while it is applied
+MERGE_ATTEMPTS = 3
+
+_MISSING = object()
+
+
+@dataclass
+class FieldConflict:
+    """A data field both the client and the server changed."""
+    field: str
+    base: Any = None
+    server: Any = None
+    client: Any = None
+    # "server" or "client": the value kept
+    kept: str = "server"
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {"field": self.field, "base": self.base, "server": self.server, "client": self.client, "kept": self.kept}
+
+
+@dataclass
+class ChangeResult:
+    """Outcome of one pushed change."""
+    index: int
+    entity: str = ""
+    id: str = ""
+    # "applied", "unchanged" (already in effect), "conflict" (not applied) or "failed"
+    status: str = "applied"
+    etag: str = ""
+    conflicts: List[FieldConflict] = field(default_factory=list)
+    error: str = ""
+
+    def to_dict(self) -> Dict[str, Any]:
+        result = {"index": self.index, "entity": self.entity, "id": self.id, "status": self.status}
+        if self.etag:
+            result["etag"] = self.etag
+        if self.conflicts:
+            result["conflicts"] = [c.to_dict() for c in self.conflicts]
+        if self.error:
+            result["error"] = self.error
+        return result
+
+
+def _data_object(data: Any, name: str) -> Dict[str, Any]:
+    if data is None:
+        return {}
+    if isinstance(data, str):
+        data = json.loads(data or "{}")
+    if not isinstance(data, dict):
+        raise ValueError(f"{name} must be a JSON object")
+    return data
+
+
+def merge_fields(
+    server: Dict[str, Any], client: Dict[str, Any], base: Optional[Dict[str, Any]], policy: str
+) -> Tuple[Dict[str, Any], List[FieldConflict]]:
+    """
+    Merge client field values into server data; returns the fields to write
+    (None removes a field) and the conflicts. Without base every field the
+    server has a different value for conflicts.
+    """
+    patch: Dict[str, Any] = {}
+    conflicts: List[FieldConflict] = []
+    for name, value in client.items():
+        server_value = server.get(name)
+        if server_value == value:
+            continue
+        base_value = base.get(name, _MISSING) if base is not None else _MISSING
+        if base_value is not _MISSING and base_value == server_value:
+            patch[name] = value
+            continue
+        kept = "client" if policy == "client_wins" else "server"
+        conflicts.append(FieldConflict(
+            name, None if base_value is _MISSING else base_value, server_value, value, kept
+        ))
+        if kept == "client":
+            patch[name] = value
+    return patch, conflicts
+
+
+class SyncService:
+    """Offline sync business logic service."""
+
+    def __init__(
+        self,
+        node_service: NodeService,
+        relationship_service: RelationshipService,
+        limits: Optional[TenantLimits] = None,
+    ):
+        self.node_service = node_service
+        self.relationship_service = relationship_service
+        self.limits = limits or TenantLimits()
+
+    async def push(self, changes: List[Dict[str, Any]], conflict_policy: str = "reject") -> List[ChangeResult]:
+        """
+        Apply changes made offline, in order, each on its own; returns one result per change.
+
+        Each change is an object with:
+            entity: "node" or "relationship"
+            action: "create", "update" or "delete"
+            id: Entity ID (client-generated UUID for creates)
+            base_etag: Etag of the version the change was made against (updates and deletes)
+            data: Object of new top-level field values (null removes a field)
+            base_data: Values of those fields in the base version (updates)
+            node_type_id: For node creates
+            source_node_id, target_node_id, relationship_type: For relationship creates
+
+        Raises:
+            ValueError: If the batch or the conflict policy is invalid
+        """
+        if conflict_policy not in CONFLICT_POLICIES:
+            raise ValueError(f"conflict_policy must be one of: {', '.join(CONFLICT_POLICIES)}")
+        if not isinstance(changes, list) or not changes:
+            raise ValueError("changes must be a non-empty array")
+        if len(changes) > self.limits.max_batch_size:
+            raise ValueError(f"at most {self.limits.max_batch_size} changes can be pushed at once (max_batch_size)")
+
+        results = []
+        for index, change in enumerate(changes):
+            result = ChangeResult(index)
+            try:
+                if not isinstance(change, dict):
+                    raise ValueError("change must be an object")
+                result.entity = change.get("entity", "")
+                result.id = change.get("id", "")
+                if result.entity not in ENTITIES:
+                    raise ValueError(f"entity must be one of: {', '.join(ENTITIES)}")
+                action = change.get("action", "")
+                if action not in ACTIONS:
+                    raise ValueError(f"action must be one of: {', '.join(ACTIONS)}")
+                if not result.id:
+                    raise ValueError("id is required")
+                if action == "create":
+                    await self._create(change, result)
+                elif action == "update":
+                    await self._update(change, conflict_policy, result)
+                else:
+                    await self._delete(change, conflict_policy, result)
+            except Exception as e:
+                result.status = "failed"
+                result.error = str(e)
+            results.append(result)
+        return results
+
+    async def _get(self, entity: str, id: str) -> Union[Node, Relationship]:
+        if entity == "node":
+            return await self.node_service.get_by_id(id)
+        return await self.relationship_service.get_by_id(id)
+
+    async def _create(self, change: Dict[str, Any], result: ChangeResult) -> None:
+        data = _data_object(change.get("data"), "data")
+        try:
+            if result.entity == "node":
+                created = await self.node_service.create(
+                    change.get("node_type_id", ""), json.dumps(data), id=result.id
+                )
+            else:
+                created = await self.relationship_service.create(
+                    change.get("source_node_id", ""), change.get("target_node_id", ""),
+                    change.get("relationship_type", ""), json.dumps(data), id=result.id,
+                )
+        except DuplicateRelationshipError as e:
+            # An identical relationship exists and its type rejects duplicates
+            result.status = "conflict"
+            result.error = str(e)
+            return
+        except AlreadyExistsError:
+            # Pushed before (e.g. a retried push): unchanged if the data still matches
+            try:
+                existing = await self._get(result.entity, result.id)
+            except NotFoundError:
+                result.status = "conflict"
+                result.error = f"{result.entity} {result.id} was deleted"
+                return
+            server = _data_object(existing.data, "data")
+            _, conflicts = merge_fields(server, data, None, "reject")
+            result.id, result.etag = existing.id, existing.etag
+            result.status = "conflict" if conflicts else "unchanged"
+            result.conflicts = conflicts
+            return
+        result.id, result.etag = created.id, created.etag
+
+    async def _update(self, change: Dict[str, Any], policy: str, result: ChangeResult) -> None:
+        client = _data_object(change.get("data"), "data")
+        base = _data_object(change["base_data"], "base_data") if change.get("base_data") is not None else None
+        base_etag = change.get("base_etag", "")
+
+        for attempt in range(MERGE_ATTEMPTS):
+            try:
+                current = await self._get(result.entity, result.id)
+            except NotFoundError:
+                result.status = "conflict"
+                result.error = f"{result.entity} {result.id} was deleted"
+                return
+            server = _data_object(current.data, "data")
+            if base_etag and base_etag == current.etag:
+                # Nothing changed on the server since the client's base
+                patch, conflicts = {k: v for k, v in client.items() if server.get(k) != v}, []
+            else:
+                patch, conflicts = merge_fields(server, client, base, policy)
+            result.conflicts = conflicts
+            result.etag = current.etag
+            if conflicts and policy == "reject":
+                result.status = "conflict"
+                return
+            if not patch:
+                result.status = "unchanged"
+                return
+
+            merged = {k: v for k, v in {**server, **patch}.items() if v is not None}
+            try:
+                if result.entity == "node":
+                    updated = await self.node_service.update(result.id, json.dumps(merged), if_match=current.etag)
+                else:
+                    updated = await self.relationship_service.update(
+                        result.id, "", json.dumps(merged), if_match=current.etag
+                    )
+            except PreconditionFailedError:
+                # Written meanwhile: merge again with the newer server data
+                if attempt == MERGE_ATTEMPTS - 1:
+                    raise
+                continue
+            result.etag = updated.etag
+            return
+
+    async def _delete(self, change: Dict[str, Any], policy: str, result: ChangeResult) -> None:
+        try:
+            current = await self._get(result.entity, result.id)
+        except NotFoundError:
+            result.status = "unchanged"
+            return
+        base_etag = change.get("base_etag", "")
+        if base_etag and base_etag != current.etag and policy != "client_wins":
+            # Edited on the server after the client's version: keep the edit
+            result.status = "conflict"
+            result.etag = current.etag
+            result.error = f"{result.entity} {result.id} changed since base_etag"
+            return
+        if result.entity == "node":
+            await self.node_service.delete(result.id)
+        else:
+            await self.relationship_service.delete(result.id)
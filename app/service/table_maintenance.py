@@ -0,0 +1,134 @@
+"""
+Table maintenance: dead tuple and bloat reports, VACUUM and ANALYZE per tenant.
+
+Tenants with heavy delete workloads (bulk deletes, purges, cascades) leave
+dead tuples that autovacuum reclaims late on large tables, so the tables
+and their indexes bloat. The maintenance service reports each table's dead
+tuples and estimated bloat, and vacuums the tables that need it:
+
+- a table is vacuumed (with ANALYZE) when dead tuples are at least
+  dead_ratio of its rows and at least min_dead_rows;
+- a partitioned table is analyzed when it has never been, or when its
+  partitions were modified since (autovacuum never analyzes partitioned
+  tables, only their partitions).
+
+The scheduler runs this for every tenant periodically; operators can also
+run it for one tenant, or for named tables regardless of thresholds.
+"""
+
+import logging
+import time
+from typing import Any, Dict, List, Optional
+
+from app.db.tenant_db_manager import TenantDatabaseManager
+from app.repository import TableMaintenanceRepository, TenantRepository
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_DEAD_RATIO = 0.2
+DEFAULT_MIN_DEAD_ROWS = 10000
+
+
+class TableMaintenanceService:
+    """Reports bloat of tenant databases and vacuums and analyzes their tables."""
+
+    def __init__(
+        self,
+        tenant_repo: TenantRepository,
+        tenant_db_manager: TenantDatabaseManager,
+        dead_ratio: float = DEFAULT_DEAD_RATIO,
+        min_dead_rows: int = DEFAULT_MIN_DEAD_ROWS,
+    ):
+        self.tenant_repo = tenant_repo
+        self.tenant_db_manager = tenant_db_manager
+        self.dead_ratio = dead_ratio
+        self.min_dead_rows = min_dead_rows
+
+    async def _repo(self, tenant_id: str) -> TableMaintenanceRepository:
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        await self.tenant_repo.get_by_id(tenant_id)
+        return TableMaintenanceRepository(await self.tenant_db_manager.get_tenant_db(tenant_id))
+
+    async def report(self, tenant_id: str) -> Dict[str, Any]:
+        """Dead tuples and estimated bloat of a tenant's tables, and which maintenance each needs."""
+        tables = await (await self._repo(tenant_id)).list_bloat()
+        for table in tables:
+            table["needs"] = self._needs(table)
+        return {
+            "tenant_id": tenant_id,
+            "dead_row_count": sum(t["dead_row_count"] for t in tables),
+            "estimated_bloat_bytes": sum(t["estimated_bloat_bytes"] for t in tables),
+            "tables": tables,
+        }
+
+    async def run(self, tenant_id: str, tables: Optional[List[str]] = None, analyze_only: bool = False) -> Dict[str, Any]:
+        """
+        Vacuum and analyze a tenant's tables: the named ones, or those past the thresholds.
+
+        Returns what was done to each table and how long it took.
+
+        Raises:
+            ValueError: If a named table does not exist
+        """
+        repo = await self._repo(tenant_id)
+        report = await repo.list_bloat()
+        by_name = {t["table"]: t for t in report}
+        if tables:
+            unknown = sorted(set(tables) - by_name.keys())
+            if unknown:
+                raise ValueError(f"unknown tables: {', '.join(unknown)}")
+            plan = {name: ("analyze" if analyze_only or by_name[name]["partitioned"] else "vacuum") for name in tables}
+        else:
+            plan = {t["table"]: self._needs(t) for t in report if self._needs(t)}
+            if analyze_only:
+                plan = {name: "analyze" for name in plan}
+
+        actions = []
+        for name, action in plan.items():
+            started = time.monotonic()
+            if action == "vacuum":
+                await repo.vacuum(name)
+            else:
+                await repo.analyze(name)
+            actions.append({
+                "table": name,
+                "action": action,
+                "dead_row_count": by_name[name]["dead_row_count"],
+                "duration_ms": round((time.monotonic() - started) * 1000, 1),
+            })
+        return {"tenant_id": tenant_id, "actions": actions}
+
+    def _needs(self, table: Dict[str, Any]) -> Optional[str]:
+        """The maintenance a table needs ("vacuum" or "analyze"), or None."""
+        if table["partitioned"]:
+            stale = table["last_analyze"] is None or table["modified_since_analyze"] > 0
+            return "analyze" if stale else None
+        dead = table["dead_row_count"]
+        if dead >= self.min_dead_rows and table["dead_row_ratio"] >= self.dead_ratio:
+            return "vacuum"
+        return None
+
+
+class TableMaintenanceScheduler:
+    """Periodically maintains the tables of every tenant that needs it."""
+
+    def __init__(self, tenant_repo: TenantRepository, service: TableMaintenanceService):
+        self.tenant_repo = tenant_repo
+        self.service = service
+
+    async def run(self) -> int:
+        """Maintain every tenant's tables past the thresholds; returns how many tables were maintained."""
+        count = 0
+        for tenant_id, slug, _ in await self.tenant_repo.list_databases():
+            try:
+                result = await self.service.run(tenant_id)
+                for action in result["actions"]:
+                    logger.info(
+                        f"Table maintenance of tenant {slug} ({tenant_id}): {action['action']} {action['table']} "
+                        f"({action['dead_row_count']} dead rows, {action['duration_ms']} ms)"
+                    )
+                count += len(result["actions"])
+            except Exception as e:
+                logger.error(f"Table maintenance of tenant {slug} ({tenant_id}) failed: {e}")
+        return count
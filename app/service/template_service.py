@@ -0,0 +1,318 @@
+"""
+Tenant templates.
+
+A template describes what a new tenant starts with, so onboarding a customer
+is a single create_tenant call with from_template:
+
+    {
+      "node_types": [{"name": "Person", "description": "", "schema": {...}}],
+      "relationship_types": [{"name": "WORKS_AT", "directionality": "directed",
+                              "source_node_types": ["Person"], "target_node_types": ["Company"]}],
+      "nodes": [{"ref": "acme", "node_type": "Company", "data": {"name": "Acme"}}],
+      "relationships": [{"source": "alice", "target": "acme", "relationship_type": "WORKS_AT", "data": {}}]
+    }
+
+Node types are referenced by name and seed nodes by their template-local
+"ref", since IDs are only assigned when the template is applied.
+
+The same definition format carries an organization's node and relationship
+types to its sub-tenants: capture() reads them from a tenant and
+sync_schemas() creates or updates them, by name, in another tenant.
+"""
+
+import json
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from app.repository import ListOptions, ListResult, TenantTemplate, TenantTemplateRepository
+
+SECTIONS = ("node_types", "relationship_types", "nodes", "relationships")
+# Node types, relationship types, nodes and relationships in one template
+MAX_TEMPLATE_ITEMS = 10000
+
+
+def check_definition(definition: Any) -> Dict[str, List[Dict[str, Any]]]:
+    """
+    Validate a template definition; returns it with every section present.
+
+    Raises:
+        ValueError: If the definition is malformed or references something it does not define
+    """
+    if not isinstance(definition, dict):
+        raise ValueError("definition must be an object")
+    unknown = [k for k in definition if k not in SECTIONS]
+    if unknown:
+        raise ValueError(f"unknown definition sections: {', '.join(unknown)} (allowed: {', '.join(SECTIONS)})")
+    result = {}
+    for section in SECTIONS:
+        items = definition.get(section) or []
+        if not isinstance(items, list) or not all(isinstance(item, dict) for item in items):
+            raise ValueError(f"{section} must be an array of objects")
+        result[section] = items
+    if sum(len(items) for items in result.values()) > MAX_TEMPLATE_ITEMS:
+        raise ValueError(f"a template can define at most {MAX_TEMPLATE_ITEMS} items")
+
+    node_types = _unique_names(result["node_types"], "name", "node_types")
+    _unique_names(result["relationship_types"], "name", "relationship_types")
+    for i, rel_type in enumerate(result["relationship_types"]):
+        for key in ("source_node_types", "target_node_types"):
+            for name in rel_type.get(key) or []:
+                if name not in node_types:
+                    raise ValueError(f"relationship_types[{i}].{key}: node type {name!r} is not defined")
+
+    refs = _unique_names([n for n in result["nodes"] if n.get("ref")], "ref", "nodes")
+    for i, node in enumerate(result["nodes"]):
+        if node.get("node_type") not in node_types:
+            raise ValueError(f"nodes[{i}].node_type must name a node type defined by the template")
+        _check_data(node.get("data"), f"nodes[{i}].data")
+    for i, rel in enumerate(result["relationships"]):
+        for key in ("source", "target"):
+            if rel.get(key) not in refs:
+                raise ValueError(f"relationships[{i}].{key} must be the ref of a node defined by the template")
+        if not rel.get("relationship_type"):
+            raise ValueError(f"relationships[{i}].relationship_type is required")
+        _check_data(rel.get("data"), f"relationships[{i}].data")
+    return result
+
+
+def _unique_names(items: List[Dict[str, Any]], key: str, section: str) -> set:
+    names = set()
+    for i, item in enumerate(items):
+        name = item.get(key)
+        if not name or not isinstance(name, str):
+            raise ValueError(f"{section}[{i}].{key} is required")
+        if name in names:
+            raise ValueError(f"{section}[{i}]: duplicate {key} {name!r}")
+        names.add(name)
+    return names
+
+
+def _check_data(data: Any, path: str) -> None:
+    if data is not None and not isinstance(data, dict):
+        raise ValueError(f"{path} must be an object")
+
+
+def _json_text(value: Any) -> str:
+    """Template values may be given as JSON objects or JSON-encoded strings."""
+    if value is None or value == "":
+        return "{}"
+    return value if isinstance(value, str) else json.dumps(value)
+
+
+class TemplateService:
+    """Tenant template business logic service."""
+
+    def __init__(
+        self,
+        repo: TenantTemplateRepository,
+        tenant_services: Optional[Callable[[str], Awaitable[Dict[str, Any]]]] = None,
+    ):
+        self.repo = repo
+        # Resolves a tenant's tenant-scoped services, used to apply templates
+        self.tenant_services = tenant_services
+
+    async def create(self, name: str, definition: Dict[str, Any], description: str = "") -> TenantTemplate:
+        """Create a new tenant template."""
+        if not name:
+            raise ValueError("name is required")
+        definition = check_definition(definition)
+        if await self.repo.find_by_name(name):
+            raise ValueError(f"tenant_template already exists: {name}")
+
+        template = TenantTemplate(name=name, description=description, definition=definition)
+        return await self.repo.create(template)
+
+    async def get_by_id(self, id: str) -> TenantTemplate:
+        """Retrieve a template by ID."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_by_id(id)
+
+    async def get_by_name(self, name: str) -> TenantTemplate:
+        """
+        Retrieve a template by name.
+
+        Raises:
+            ValueError: If no template has that name
+        """
+        template = await self.repo.find_by_name(name)
+        if not template:
+            raise ValueError(f"unknown tenant template: {name}")
+        return template
+
+    async def update(
+        self,
+        id: str,
+        name: str = "",
+        description: Optional[str] = None,
+        definition: Optional[Dict[str, Any]] = None,
+    ) -> TenantTemplate:
+        """Update an existing template; tenants already created from it are not changed."""
+        if not id:
+            raise ValueError("id is required")
+
+        template = await self.repo.get_by_id(id)
+        if name and name != template.name:
+            if await self.repo.find_by_name(name):
+                raise ValueError(f"tenant_template already exists: {name}")
+            template.name = name
+        if description is not None:
+            template.description = description
+        if definition is not None:
+            template.definition = check_definition(definition)
+
+        return await self.repo.update(template)
+
+    async def delete(self, id: str) -> None:
+        """Delete a template."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete(id)
+
+    async def list(self, page_size: int, page_token: str) -> Tuple[List[TenantTemplate], ListResult]:
+        """Retrieve templates with pagination."""
+        opts = ListOptions(page_size=page_size, page_token=page_token)
+        return await self.repo.list(opts)
+
+    async def apply(self, template: TenantTemplate, tenant_id: str) -> Dict[str, int]:
+        """
+        Create a template's node types, relationship types and seed data in a
+        tenant; returns how many of each were created.
+
+        Items are created through the tenant's services, so schemas, write
+        hooks and relationship type constraints apply to seed data as usual.
+        """
+        if not self.tenant_services:
+            raise ValueError("tenant templates are not configured")
+        services = await self.tenant_services(tenant_id)
+        definition = check_definition(template.definition)
+
+        node_type_ids = {}
+        for item in definition["node_types"]:
+            node_type = await services["node_type"].create(
+                item["name"], item.get("description", ""), _json_text(item.get("schema"))
+            )
+            node_type_ids[node_type.name] = node_type.id
+
+        for item in definition["relationship_types"]:
+            await services["relationship_type"].create(
+                item["name"],
+                item.get("description", ""),
+                item.get("directionality", ""),
+                [node_type_ids[n] for n in item.get("source_node_types") or []],
+                [node_type_ids[n] for n in item.get("target_node_types") or []],
+                item.get("on_source_delete", ""),
+                item.get("on_target_delete", ""),
+                on_duplicate=item.get("on_duplicate", ""),
+            )
+
+        node_ids = {}
+        for item in definition["nodes"]:
+            node = await services["node"].create(node_type_ids[item["node_type"]], _json_text(item.get("data")))
+            if item.get("ref"):
+                node_ids[item["ref"]] = node.id
+
+        for item in definition["relationships"]:
+            await services["relationship"].create(
+                node_ids[item["source"]], node_ids[item["target"]], item["relationship_type"],
+                _json_text(item.get("data")),
+            )
+
+        return {section: len(definition[section]) for section in SECTIONS}
+
+    async def capture(self, tenant_id: str) -> Dict[str, List[Dict[str, Any]]]:
+        """Describe a tenant's node types and (stored) relationship types as a template definition."""
+        if not self.tenant_services:
+            raise ValueError("tenant templates are not configured")
+        services = await self.tenant_services(tenant_id)
+        node_types = await list_all(services["node_type"])
+        names = {t.id: t.name for t in node_types}
+        relationship_types = [t for t in await list_all(services["relationship_type"]) if not t.derived]
+
+        return {
+            "node_types": [
+                {"name": t.name, "description": t.description, "schema": t.schema or "{}"} for t in node_types
+            ],
+            "relationship_types": [
+                {
+                    "name": t.name,
+                    "description": t.description,
+                    "directionality": t.directionality,
+                    "source_node_types": [names[i] for i in t.allowed_source_node_type_ids if i in names],
+                    "target_node_types": [names[i] for i in t.allowed_target_node_type_ids if i in names],
+                    "on_source_delete": t.on_source_delete,
+                    "on_target_delete": t.on_target_delete,
+                    "on_duplicate": t.on_duplicate,
+                }
+                for t in relationship_types
+            ],
+            "nodes": [],
+            "relationships": [],
+        }
+
+    async def sync_schemas(self, definition: Dict[str, Any], tenant_id: str) -> Dict[str, int]:
+        """
+        Create a definition's node and relationship types in a tenant, or update
+        the ones the tenant already has with the same names; types only the
+        tenant has are kept. Returns how many types were created and updated.
+        """
+        if not self.tenant_services:
+            raise ValueError("tenant templates are not configured")
+        services = await self.tenant_services(tenant_id)
+        definition = check_definition(definition)
+        counts = {"created": 0, "updated": 0}
+
+        existing = {t.name: t for t in await list_all(services["node_type"])}
+        for item in definition["node_types"]:
+            description, schema = item.get("description", ""), _json_text(item.get("schema"))
+            current = existing.get(item["name"])
+            if current is None:
+                existing[item["name"]] = await services["node_type"].create(item["name"], description, schema)
+                counts["created"] += 1
+            elif (current.description, current.schema or "{}") != (description or current.description, schema):
+                await services["node_type"].update(current.id, "", description, schema)
+                counts["updated"] += 1
+        node_type_ids = {name: t.id for name, t in existing.items()}
+
+        current_rel_types = {t.name: t for t in await list_all(services["relationship_type"])}
+        for item in definition["relationship_types"]:
+            args = (
+                item.get("description", ""),
+                item.get("directionality", ""),
+                [node_type_ids[n] for n in item.get("source_node_types") or []],
+                [node_type_ids[n] for n in item.get("target_node_types") or []],
+                item.get("on_source_delete", ""),
+                item.get("on_target_delete", ""),
+            )
+            on_duplicate = item.get("on_duplicate", "")
+            current = current_rel_types.get(item["name"])
+            if current is None:
+                await services["relationship_type"].create(item["name"], *args, on_duplicate=on_duplicate)
+                counts["created"] += 1
+            elif not current.derived and _rel_type_fields(current) != (
+                args[0] or current.description, args[1] or current.directionality, sorted(args[2]), sorted(args[3]),
+                args[4] or current.on_source_delete, args[5] or current.on_target_delete,
+                on_duplicate or current.on_duplicate,
+            ):
+                await services["relationship_type"].update(current.id, "", *args, on_duplicate=on_duplicate)
+                counts["updated"] += 1
+        return counts
+
+
+def _rel_type_fields(rel_type: Any) -> Tuple[Any, ...]:
+    return (
+        rel_type.description, rel_type.directionality,
+        sorted(rel_type.allowed_source_node_type_ids), sorted(rel_type.allowed_target_node_type_ids),
+        rel_type.on_source_delete, rel_type.on_target_delete, rel_type.on_duplicate,
+    )
+
+
+async def list_all(service: Any) -> List[Any]:
+    """Page through every item of a tenant service's list()."""
+    items: List[Any] = []
+    page_token = ""
+    while True:
+        page, result = await service.list(service.limits.max_page_size, page_token)
+        items += page
+        page_token = result.next_page_token
+        if not page_token:
+            return items
@@ -0,0 +1,55 @@
+"""
+Per-tenant debug logging.
+
+An administrator can switch on verbose logging for one tenant for a limited
+time with ``set_tenant_debug``, to debug one customer without raising the
+log level of the whole server:
+
+- ``requests``: every JSON-RPC call to the tenant is logged with its
+  parameters and its result or error (see app/jsonrpc/debug_log.py).
+- ``queries``: every SQL statement those calls run is logged with its
+  parameters and duration (see app/db/tracing.py).
+
+Both go to the ``flexdb.debug`` logger. Logging stops by itself at the
+deadline. Every server caches each tenant's setting for DEBUG_CACHE_SECONDS,
+so changes reach other servers within that time.
+"""
+
+import time
+from datetime import datetime, timezone
+from typing import Dict, Tuple
+
+from app.repository import NotFoundError, TenantRepository
+
+DEBUG_SCOPES = ("requests", "queries")
+DEFAULT_DEBUG_TTL_SECONDS = 900
+MAX_DEBUG_TTL_SECONDS = 4 * 3600
+DEBUG_CACHE_SECONDS = 5.0
+
+
+class TenantDebugCache:
+    """Caches the debug logging scopes switched on for each tenant."""
+
+    def __init__(self, tenant_repo: TenantRepository, ttl_seconds: float = DEBUG_CACHE_SECONDS):
+        self.tenant_repo = tenant_repo
+        self.ttl_seconds = ttl_seconds
+        self._entries: Dict[str, Tuple[float, Tuple[str, ...], datetime]] = {}
+
+    async def scopes(self, tenant_id: str) -> Tuple[str, ...]:
+        """Return the debug scopes active for a tenant (none once past its deadline)."""
+        entry = self._entries.get(tenant_id)
+        if not entry or time.monotonic() - entry[0] >= self.ttl_seconds:
+            try:
+                tenant = await self.tenant_repo.get_by_id(tenant_id)
+            except NotFoundError:
+                # The call itself reports the unknown tenant
+                return ()
+            until = tenant.debug_until or datetime.min.replace(tzinfo=timezone.utc)
+            entry = (time.monotonic(), tuple(tenant.debug_scopes), until)
+            self._entries[tenant_id] = entry
+        _, scopes, until = entry
+        return scopes if until > datetime.now(timezone.utc) else ()
+
+    def invalidate(self, tenant_id: str) -> None:
+        """Drop a tenant's cached setting after it changes."""
+        self._entries.pop(tenant_id, None)
@@ -0,0 +1,142 @@
+"""
+Per-tenant data encryption keys and their rotation.
+
+Rotating a tenant's key creates a new key version and re-encrypts the
+tenant's documents with it in a background operation (progress via
+``get_operation``). The first rotation turns encryption on for the tenant.
+Every server caches each tenant's keys for ``cache_seconds``, so a rotation:
+
+1. creates the new key as "pending" and waits until every server has loaded
+   it, so any server can read documents encrypted with it;
+2. makes it "active" (the previous key starts "retiring") and waits until
+   every server encrypts new writes with it;
+3. re-encrypts the documents not yet encrypted with it, then retires the
+   older keys. Retired keys are kept, so data restored from a backup taken
+   before the rotation can still be read.
+"""
+
+import asyncio
+import logging
+import time
+from typing import Dict, List, Optional, Tuple
+
+from app.db.tenant_db_manager import TenantDatabaseManager
+from app.repository import (
+    EncryptedDataRepository,
+    Operation,
+    OperationRepository,
+    TenantKey,
+    TenantKeyRepository,
+    UniqueConstraintRepository,
+)
+from app.repository.encrypted_data_repo import DOCUMENT_TABLES
+from app.repository.encryption import DataKey, encryption_enabled, new_wrapped_key, unwrap_key
+from app.service.operation_service import OperationProgress, OperationService
+
+logger = logging.getLogger(__name__)
+
+# How long each server caches a tenant's keys
+KEY_CACHE_SECONDS = 30
+# Documents read per batch while re-encrypting
+ROTATION_BATCH_SIZE = 500
+
+
+class TenantKeyService:
+    """Tenant data key business logic service."""
+
+    def __init__(
+        self,
+        repo: TenantKeyRepository,
+        tenant_db_manager: TenantDatabaseManager,
+        cache_seconds: float = KEY_CACHE_SECONDS,
+    ):
+        self.repo = repo
+        self.tenant_db_manager = tenant_db_manager
+        self.cache_seconds = cache_seconds
+        self._entries: Dict[str, Tuple[float, Optional[DataKey]]] = {}
+        # Unwrapped keys by ID
+        self._keys: Dict[str, DataKey] = {}
+
+    async def active_key(self, tenant_id: str) -> Optional[DataKey]:
+        """
+        Return the key a tenant's documents are encrypted with, or None when
+        the tenant is not encrypted; loads all of the tenant's keys for reads.
+        """
+        if not encryption_enabled():
+            return None
+        entry = self._entries.get(tenant_id)
+        if entry and time.monotonic() - entry[0] < self.cache_seconds:
+            return entry[1]
+
+        active = None
+        for key in await self.repo.list(tenant_id):
+            if key.id not in self._keys:
+                self._keys[key.id] = unwrap_key(key.id, tenant_id, key.wrapped_key)
+            if key.status == "active":
+                active = self._keys[key.id]
+        self._entries[tenant_id] = (time.monotonic(), active)
+        return active
+
+    def invalidate(self, tenant_id: str) -> None:
+        """Drop a tenant's cached keys after they change."""
+        self._entries.pop(tenant_id, None)
+
+    async def list(self, tenant_id: str) -> List[TenantKey]:
+        """List a tenant's key versions, newest first."""
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        return await self.repo.list(tenant_id)
+
+    async def rotate(self, tenant_id: str) -> Tuple[TenantKey, Operation]:
+        """
+        Create a new key version for a tenant and re-encrypt its documents in
+        the background; returns the key and the running operation.
+
+        Raises:
+            ValueError: If encryption is not configured, the tenant does not exist or has unique constraints
+        """
+        if not tenant_id:
+            raise ValueError("tenant_id is required")
+        if not encryption_enabled():
+            raise ValueError("tenant encryption is not configured (set ENCRYPTION_MASTER_KEY)")
+
+        tenant_db = await self.tenant_db_manager.get_tenant_db(tenant_id)
+        # Unique constraints read plain documents
+        if await UniqueConstraintRepository(tenant_db).exists():
+            raise ValueError("tenants with unique constraints cannot be encrypted; delete the constraints first")
+        key = await self.repo.create(tenant_id, new_wrapped_key(tenant_id))
+        data_key = self._keys[key.id] = unwrap_key(key.id, tenant_id, key.wrapped_key)
+        data_repo = EncryptedDataRepository(tenant_db)
+
+        async def work(progress: OperationProgress) -> None:
+            await asyncio.sleep(self.cache_seconds)
+            await self.repo.activate(key.id)
+            self.invalidate(tenant_id)
+            await asyncio.sleep(self.cache_seconds)
+
+            for table in DOCUMENT_TABLES:
+                after = None
+                while True:
+                    rows = await data_repo.list_stale(table, data_key, after, ROTATION_BATCH_SIZE)
+                    if not rows:
+                        break
+                    for id, data, blob in rows:
+                        try:
+                            # A document changed meanwhile was written with the new key
+                            progress.succeeded(await data_repo.reencrypt(table, data_key, id, data, blob))
+                        except Exception as e:
+                            progress.failed(str(id), e)
+                        await progress.checkpoint()
+                    after = rows[-1][0]
+
+            if progress.op.failed_count:
+                logger.warning(f"Key rotation for tenant {tenant_id} left {progress.op.failed_count} documents under older keys")
+                return
+            await self.repo.retire_before(tenant_id, key.version)
+
+        operations = OperationService(OperationRepository(tenant_db))
+        params = {"key_id": key.id, "version": key.version}
+        op = await operations.start("tenant_key_rotation", params, await data_repo.count_stale(data_key), work)
+        await self.repo.set_operation(key.id, op.id)
+        key.operation_id = op.id
+        return key, op
@@ -1,48 +1,239 @@
 """
 Tenant service implementation.
+
+Tenants can be organized in hierarchies: an organization (such as a
+reseller) has sub-tenants, which may have sub-tenants of their own. A
+sub-tenant inherits its ancestors' limit overrides and feature flags unless
+it sets them itself, starts with its parent's node and relationship types
+(sync_schemas pushes later changes down), and its ancestors' members keep
+their roles in it for authorization policies.
 """
 
-from typing import List, Tuple, Optional
+import logging
+import re
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Tuple, Optional
 
-from app.repository import Tenant, TenantRepository, ListOptions, ListResult
+from app.repository import (
+    PreconditionFailedError, SlugTakenError, Tenant, TenantFilter, TenantRepository, ListOptions, ListResult,
+)
+from app.repository.tenant_repo import MAX_HIERARCHY_DEPTH
+from app.service.limits import TenantLimits, TenantLimitsCache, effective_limits, merge_overrides
+from app.service.maintenance import TenantMaintenanceCache
+from app.service.template_service import TemplateService
+from app.service.tenant_debug import DEBUG_SCOPES, DEFAULT_DEBUG_TTL_SECONDS, MAX_DEBUG_TTL_SECONDS, TenantDebugCache
+from app.service.timestamps import check_timezone, parse_timestamp
 from app.db.tenant_db_manager import TenantDatabaseManager
 
+logger = logging.getLogger(__name__)
+
+# Deleted tenants stay recoverable with undelete for this long by default
+DEFAULT_DELETE_GRACE_SECONDS = 7 * 24 * 3600
+
+MAX_ANNOTATIONS = 64
+MAX_ANNOTATION_VALUE_LENGTH = 1024
+# Alphanumeric at both ends, with ".", "_", "-" and "/" allowed in between
+ANNOTATION_KEY_PATTERN = re.compile(r"^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$")
+
+MAX_FEATURES = 64
+FEATURE_NAME_PATTERN = re.compile(r"^[a-z][a-z0-9_.-]{0,62}$")
+MAX_MAINTENANCE_REASON_LENGTH = 500
+
+# Available slugs suggested for a taken one, picked from its numbered and dated variants
+MAX_SLUG_SUGGESTIONS = 3
+SLUG_SUGGESTION_CANDIDATES = 12
+
+
+def slug_candidates(slug: str, year: int) -> List[str]:
+    """Return variants of a slug to suggest when it is taken, e.g. acme-2, acme-2025, acme-3 for acme."""
+    # A numbered slug (acme-2) is varied from its base (acme-3, not acme-2-2)
+    base = re.sub(r"-[0-9]+$", "", slug) or slug
+    candidates = [f"{base}-2", f"{base}-{year}"] + [f"{base}-{n}" for n in range(3, SLUG_SUGGESTION_CANDIDATES)]
+    return [c for c in candidates if c != slug]
+
+
+def merge_annotations(current: Dict[str, str], changes: Optional[Dict[str, Optional[str]]]) -> Dict[str, str]:
+    """
+    Merge annotation changes into a tenant's annotations; a null value removes the key.
+
+    Raises:
+        ValueError: If a key or value is invalid or there would be too many annotations
+    """
+    if changes is None:
+        return current
+    if not isinstance(changes, dict):
+        raise ValueError("annotations must be an object of string values")
+    merged = dict(current)
+    for key, value in changes.items():
+        if not ANNOTATION_KEY_PATTERN.match(key):
+            raise ValueError(f"invalid annotation key {key!r}: use up to 63 letters, digits, '.', '_', '-' or '/'")
+        if value is None:
+            merged.pop(key, None)
+        elif not isinstance(value, str) or len(value) > MAX_ANNOTATION_VALUE_LENGTH:
+            raise ValueError(f"annotation {key!r} must be a string of at most {MAX_ANNOTATION_VALUE_LENGTH} characters")
+        else:
+            merged[key] = value
+    if len(merged) > MAX_ANNOTATIONS:
+        raise ValueError(f"a tenant can have at most {MAX_ANNOTATIONS} annotations")
+    return merged
+
+
+def merge_features(current: Dict[str, bool], changes: Dict[str, Optional[bool]]) -> Dict[str, bool]:
+    """
+    Merge feature flag changes into a tenant's own flags; a null value
+    removes the flag so it is inherited again.
+
+    Raises:
+        ValueError: If a flag name or value is invalid or there would be too many flags
+    """
+    if not isinstance(changes, dict) or not changes:
+        raise ValueError("features must be a non-empty object of boolean values")
+    merged = dict(current)
+    for name, value in changes.items():
+        if not FEATURE_NAME_PATTERN.match(name):
+            raise ValueError(f"invalid feature name {name!r}: use up to 63 lowercase letters, digits, '_', '.' or '-'")
+        if value is None:
+            merged.pop(name, None)
+        elif not isinstance(value, bool):
+            raise ValueError(f"feature {name!r} must be true, false or null")
+        else:
+            merged[name] = value
+    if len(merged) > MAX_FEATURES:
+        raise ValueError(f"a tenant can set at most {MAX_FEATURES} features")
+    return merged
+
 
 class TenantService:
     """Tenant business logic service."""
 
-    def __init__(self, repo: TenantRepository, tenant_db_manager: Optional[TenantDatabaseManager] = None):
+    def __init__(
+        self,
+        repo: TenantRepository,
+        tenant_db_manager: Optional[TenantDatabaseManager] = None,
+        delete_grace_seconds: int = DEFAULT_DELETE_GRACE_SECONDS,
+        limits_cache: Optional[TenantLimitsCache] = None,
+        template_service: Optional[TemplateService] = None,
+        maintenance_cache: Optional[TenantMaintenanceCache] = None,
+        debug_cache: Optional[TenantDebugCache] = None,
+    ):
         self.repo = repo
         self.tenant_db_manager = tenant_db_manager
+        self.delete_grace_seconds = delete_grace_seconds
+        self.limits_cache = limits_cache
+        self.template_service = template_service
+        self.maintenance_cache = maintenance_cache
+        self.debug_cache = debug_cache
+
+    async def create(
+        self,
+        slug: str,
+        name: str,
+        annotations: Optional[Dict[str, str]] = None,
+        from_template: str = "",
+        parent_id: str = "",
+        timezone: str = "",
+        suggest_slugs: bool = False,
+        region: str = "",
+    ) -> Tenant:
+        """
+        Create a new tenant and its associated tenant database.
 
-    async def create(self, slug: str, name: str) -> Tenant:
-        """Create a new tenant and its associated tenant database."""
+        from_template names a tenant template whose node types, relationship
+        types and seed data are created in the new tenant. With parent_id the
+        tenant is a sub-tenant of that organization and also starts with its
+        node and relationship types. If applying either fails, the tenant is
+        removed again. timezone is the IANA time zone date histograms
+        bucket by (UTC by default). region is the data residency region the
+        tenant's database is created in (DEFAULT_REGION by default); it
+        cannot be changed later.
+
+        Raises:
+            SlugTakenError: If another tenant has the slug; with suggest_slugs it lists available alternatives
+        """
         if not slug:
             raise ValueError("slug is required")
         if not name:
             raise ValueError("name is required")
+        if timezone:
+            check_timezone(timezone)
+        if self.tenant_db_manager:
+            region = self.tenant_db_manager.regions.check_region(region)
+        template = None
+        if from_template:
+            if not self.template_service:
+                raise ValueError("tenant templates are not configured")
+            template = await self.template_service.get_by_name(from_template)
+        if parent_id:
+            await self._check_parent(parent_id)
 
         # Create tenant record in control database
-        tenant = Tenant(slug=slug, name=name)
-        tenant = await self.repo.create(tenant)
+        tenant = Tenant(
+            slug=slug, name=name, annotations=merge_annotations({}, annotations), parent_id=parent_id,
+            timezone=timezone or "UTC", region=region,
+        )
+        try:
+            tenant = await self.repo.create(tenant)
+        except SlugTakenError as e:
+            if suggest_slugs:
+                e.suggestions = await self.suggest_slugs(slug)
+            raise
 
         # Create tenant database and run migrations
         if self.tenant_db_manager:
             await self.tenant_db_manager.create_tenant_database(
                 tenant_id=tenant.id,
-                slug=tenant.slug
+                slug=tenant.slug,
+                region=tenant.region
             )
 
+        if template:
+            try:
+                await self.template_service.apply(template, tenant.id)
+            except Exception as e:
+                logger.error(f"Applying template {template.name} to tenant {tenant.id} failed: {e}")
+                await self._purge(tenant)
+                raise
+        if parent_id and self.template_service and self.template_service.tenant_services:
+            try:
+                definition = await self.template_service.capture(parent_id)
+                await self.template_service.sync_schemas(definition, tenant.id)
+            except Exception as e:
+                logger.error(f"Inheriting the schemas of tenant {parent_id} into tenant {tenant.id} failed: {e}")
+                await self._purge(tenant)
+                raise
+
         return tenant
 
+    async def check_slug_availability(self, slug: str) -> Tuple[bool, List[str]]:
+        """Return whether no tenant has a slug and, if one does, available alternatives."""
+        if not slug:
+            raise ValueError("slug is required")
+        if not await self.repo.taken_slugs([slug]):
+            return True, []
+        return False, await self.suggest_slugs(slug)
+
+    async def suggest_slugs(self, slug: str) -> List[str]:
+        """Return up to MAX_SLUG_SUGGESTIONS available variants of a slug."""
+        candidates = slug_candidates(slug, datetime.now(timezone.utc).year)
+        taken = set(await self.repo.taken_slugs(candidates))
+        return [c for c in candidates if c not in taken][:MAX_SLUG_SUGGESTIONS]
+
     async def get_by_id(self, id: str) -> Tenant:
         """Retrieve a tenant by ID."""
         if not id:
             raise ValueError("id is required")
         return await self.repo.get_by_id(id)
 
-    async def update(self, id: str, slug: str, name: str, status: str) -> Tenant:
-        """Update an existing tenant."""
+    async def update(
+        self,
+        id: str,
+        slug: str,
+        name: str,
+        status: str,
+        annotations: Optional[Dict[str, Optional[str]]] = None,
+        timezone: str = "",
+    ) -> Tenant:
+        """Update an existing tenant; annotations are merged into the existing ones."""
         if not id:
             raise ValueError("id is required")
 
@@ -53,17 +244,264 @@ class TenantService:
         if name:
             tenant.name = name
         if status:
+            if status == "pending_deletion" or tenant.status == "pending_deletion":
+                raise ValueError("use delete_tenant and undelete_tenant to change deletion status")
             tenant.status = status
+        tenant.annotations = merge_annotations(tenant.annotations, annotations)
+        if timezone:
+            check_timezone(timezone)
+            tenant.timezone = timezone
 
         return await self.repo.update(tenant)
 
-    async def delete(self, id: str) -> None:
-        """Delete a tenant."""
+    async def get_limits(self, id: str) -> TenantLimits:
+        """Return a tenant's effective limits (server defaults with its ancestors' and its own overrides applied)."""
+        if not id:
+            raise ValueError("id is required")
+        tenant = await self.repo.get_by_id(id)
+        return await effective_limits(self.repo, tenant)
+
+    async def set_limits(self, id: str, limits: Dict[str, Any]) -> TenantLimits:
+        """
+        Override some of a tenant's limits; a null value restores the server default.
+
+        Raises:
+            ValueError: If a limit is unknown or out of range
+        """
+        if not id:
+            raise ValueError("id is required")
+        if not isinstance(limits, dict) or not limits:
+            raise ValueError("limits must be a non-empty object")
+
+        tenant = await self.repo.get_by_id(id)
+        tenant = await self.repo.set_limits(id, merge_overrides(tenant.limits, limits))
+        if self.limits_cache:
+            # Sub-tenants inherit the change too
+            self.limits_cache.clear()
+        return await effective_limits(self.repo, tenant)
+
+    async def get_features(self, id: str) -> Tuple[Dict[str, bool], Dict[str, str]]:
+        """
+        Return a tenant's effective feature flags and, for each flag, the ID
+        of the tenant it is set on (the tenant itself or the nearest ancestor).
+        """
+        if not id:
+            raise ValueError("id is required")
+        tenant = await self.repo.get_by_id(id)
+        chain = [tenant] + (await self.repo.list_ancestors(id) if tenant.parent_id else [])
+        features: Dict[str, bool] = {}
+        sources: Dict[str, str] = {}
+        for t in chain:
+            for name, value in t.features.items():
+                if name not in features:
+                    features[name] = value
+                    sources[name] = t.id
+        return features, sources
+
+    async def set_features(self, id: str, features: Dict[str, Optional[bool]]) -> Tuple[Dict[str, bool], Dict[str, str]]:
+        """
+        Set some of a tenant's own feature flags (null inherits the flag
+        again); returns the effective flags as get_features does.
+        """
+        if not id:
+            raise ValueError("id is required")
+        tenant = await self.repo.get_by_id(id)
+        await self.repo.set_features(id, merge_features(tenant.features, features))
+        return await self.get_features(id)
+
+    async def set_maintenance(self, id: str, enabled: bool, reason: str = "") -> Tenant:
+        """
+        Put a tenant into read-only maintenance mode, or end it. Writes are
+        rejected with the reason until maintenance ends.
+        """
+        if not id:
+            raise ValueError("id is required")
+        reason = (reason or "").strip()
+        if enabled and not reason:
+            raise ValueError("reason is required to start maintenance")
+        if len(reason) > MAX_MAINTENANCE_REASON_LENGTH:
+            raise ValueError(f"reason must be at most {MAX_MAINTENANCE_REASON_LENGTH} characters")
+        tenant = await self.repo.set_maintenance(id, enabled, reason)
+        if self.maintenance_cache:
+            self.maintenance_cache.invalidate(id)
+        logger.info(f"Tenant {id} maintenance {'started: ' + reason if enabled else 'ended'}")
+        return tenant
+
+    async def set_protected(self, id: str, protected: bool) -> Tenant:
+        """Protect a tenant from deletion, or remove its protection."""
+        if not id:
+            raise ValueError("id is required")
+        tenant = await self.repo.set_protected(id, protected)
+        logger.info(f"Tenant {id} delete protection {'set' if protected else 'removed'}")
+        return tenant
+
+    async def set_debug(self, id: str, scopes: List[str], ttl_seconds: int = 0) -> Tenant:
+        """
+        Switch on debug logging scopes ("requests", "queries") for a tenant
+        for ttl_seconds (default 15 minutes), or switch it off with no scopes.
+
+        Raises:
+            ValueError: If a scope or ttl_seconds is invalid
+            NotFoundError: If the tenant does not exist
+        """
+        if not id:
+            raise ValueError("id is required")
+        scopes = list(dict.fromkeys(scopes or []))
+        unknown = [s for s in scopes if s not in DEBUG_SCOPES]
+        if unknown:
+            raise ValueError(f"scopes must be some of: {', '.join(DEBUG_SCOPES)}")
+        ttl_seconds = ttl_seconds or DEFAULT_DEBUG_TTL_SECONDS
+        if not 1 <= ttl_seconds <= MAX_DEBUG_TTL_SECONDS:
+            raise ValueError(f"ttl_seconds must be between 1 and {MAX_DEBUG_TTL_SECONDS}")
+        until = datetime.now(timezone.utc) + timedelta(seconds=ttl_seconds)
+        tenant = await self.repo.set_debug(id, scopes, until)
+        if self.debug_cache:
+            self.debug_cache.invalidate(id)
+        if scopes:
+            logger.info(f"Tenant {id} debug logging of {', '.join(scopes)} on until {until.isoformat()}")
+        else:
+            logger.info(f"Tenant {id} debug logging off")
+        return tenant
+
+    async def set_parent(self, id: str, parent_id: str) -> Tenant:
+        """
+        Move a tenant under an organization, or make it top-level with an empty
+        parent_id. Its schemas are not changed; use sync_schemas to bring the
+        new organization's types down.
+        """
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.get_by_id(id)
+        if parent_id:
+            descendants = await self.repo.list_descendants(id)
+            if parent_id == id or parent_id in [t.id for t in descendants]:
+                raise ValueError("a tenant cannot be moved under itself or one of its sub-tenants")
+            levels = {id: 1}
+            for t in descendants:
+                levels[t.id] = levels[t.parent_id] + 1
+            await self._check_parent(parent_id, max(levels.values()))
+        tenant = await self.repo.set_parent(id, parent_id)
+        if self.limits_cache:
+            self.limits_cache.clear()
+        return tenant
+
+    async def sync_schemas(self, id: str) -> List[Dict[str, Any]]:
+        """
+        Push an organization's node and relationship types down its hierarchy:
+        each sub-tenant receives its parent's types, created or updated by
+        name. Returns the created and updated counts per sub-tenant.
+        """
         if not id:
             raise ValueError("id is required")
-        await self.repo.delete(id)
+        if not self.template_service or not self.template_service.tenant_services:
+            raise ValueError("tenant templates are not configured")
+        await self.repo.get_by_id(id)
+
+        definitions: Dict[str, Dict[str, Any]] = {}
+        results = []
+        for tenant in await self.repo.list_descendants(id):
+            if tenant.status == "pending_deletion":
+                continue
+            # Parents come first, so a parent's definition already includes what it received
+            if tenant.parent_id not in definitions:
+                definitions[tenant.parent_id] = await self.template_service.capture(tenant.parent_id)
+            counts = await self.template_service.sync_schemas(definitions[tenant.parent_id], tenant.id)
+            results.append({"tenant_id": tenant.id, **counts})
+        return results
+
+    async def delete(self, id: str, force: bool = False) -> Tenant:
+        """
+        Schedule a tenant for deletion.
+
+        The tenant becomes inaccessible immediately and stays recoverable with
+        undelete until its grace period ends, after which purge_expired removes
+        it and drops its database.
+
+        Raises:
+            PreconditionFailedError: If the tenant is protected and force is not given
+        """
+        if not id:
+            raise ValueError("id is required")
+        if (await self.repo.get_by_id(id)).protected and not force:
+            raise PreconditionFailedError(f"tenant {id} is protected from deletion; delete it with force")
+        remaining = [t for t in await self.repo.list_descendants(id) if t.status != "pending_deletion"]
+        if remaining:
+            raise ValueError(f"tenant has {len(remaining)} sub-tenants; delete or move them first")
+
+        delete_after = datetime.now(timezone.utc) + timedelta(seconds=self.delete_grace_seconds)
+        tenant = await self.repo.schedule_deletion(id, delete_after)
+        if self.tenant_db_manager:
+            await self.tenant_db_manager.evict_tenant_pool(id)
+
+        if self.delete_grace_seconds <= 0:
+            await self._purge(tenant)
+        return tenant
+
+    async def undelete(self, id: str) -> Tenant:
+        """Restore a tenant that is pending deletion."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.cancel_deletion(id)
+
+    async def discard(self, tenant: Tenant) -> None:
+        """Permanently remove a tenant right away, without a grace period (to undo a failed provisioning)."""
+        await self._purge(tenant)
+
+    async def purge_expired(self, now: Optional[datetime] = None) -> List[str]:
+        """Permanently remove tenants whose deletion grace period has ended; returns their IDs."""
+        due = await self.repo.list_due_for_purge(now or datetime.now(timezone.utc))
+        purged = []
+        for tenant in due:
+            try:
+                await self._purge(tenant)
+                purged.append(tenant.id)
+            except Exception as e:
+                logger.error(f"Failed to purge tenant {tenant.id}: {e}")
+        return purged
+
+    async def _check_parent(self, parent_id: str, levels: int = 1) -> None:
+        """Check that a tenant with sub-tenants `levels` deep (1 for none) can be placed under parent_id."""
+        parent = await self.repo.get_by_id(parent_id)
+        if parent.status == "pending_deletion":
+            raise ValueError(f"tenant is pending deletion: {parent_id}")
+        if len(await self.repo.list_ancestors(parent_id)) + 1 + levels > MAX_HIERARCHY_DEPTH:
+            raise ValueError(f"tenant hierarchies can be at most {MAX_HIERARCHY_DEPTH} levels deep")
+
+    async def _purge(self, tenant: Tenant) -> None:
+        if self.tenant_db_manager:
+            await self.tenant_db_manager.drop_tenant_database(tenant.id)
+        await self.repo.delete(tenant.id)
+        logger.info(f"Purged tenant {tenant.id} ({tenant.slug})")
+
+    async def list(
+        self,
+        page_size: int,
+        page_token: str,
+        order_by: str = "",
+        status: str = "",
+        slug_prefix: str = "",
+        name_contains: str = "",
+        created_after: str = "",
+        created_before: str = "",
+        annotations: Optional[Dict[str, str]] = None,
+        parent_id: str = "",
+    ) -> Tuple[List[Tenant], ListResult]:
+        """
+        Retrieve tenants with pagination and optional filtering (created_* are ISO 8601).
 
-    async def list(self, page_size: int, page_token: str) -> Tuple[List[Tenant], ListResult]:
-        """Retrieve tenants with pagination."""
-        opts = ListOptions(page_size=page_size, page_token=page_token)
-        return await self.repo.list(opts)
+        annotations selects tenants having all of the given annotation values;
+        parent_id selects an organization's direct sub-tenants.
+        """
+        if annotations and not all(isinstance(v, str) for v in annotations.values()):
+            raise ValueError("annotations filter values must be strings")
+        filters = TenantFilter(
+            status=status,
+            slug_prefix=slug_prefix,
+            name_contains=name_contains,
+            created_after=parse_timestamp(created_after, "created_after"),
+            created_before=parse_timestamp(created_before, "created_before"),
+            annotations=annotations or {},
+            parent_id=parent_id,
+        )
+        opts = ListOptions(page_size=page_size, page_token=page_token, order_by=order_by)
+        return await self.repo.list(opts, filters)
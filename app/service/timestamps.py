@@ -0,0 +1,54 @@
+"""
+Timestamp parsing for service parameters.
+"""
+
+from datetime import date, datetime, timezone
+from typing import Optional
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+
+# Calendar intervals of date histograms
+DATE_INTERVALS = ("hour", "day", "week", "month", "year")
+
+
+def parse_timestamp(value: str, name: str) -> Optional[datetime]:
+    """Parse an ISO 8601 timestamp; naive values are taken as UTC. Empty returns None."""
+    if not value:
+        return None
+    try:
+        ts = datetime.fromisoformat(value.replace("Z", "+00:00"))
+    except ValueError as e:
+        raise ValueError(f"{name} must be an ISO 8601 timestamp: {value}") from e
+    return ts if ts.tzinfo else ts.replace(tzinfo=timezone.utc)
+
+
+def check_timezone(name: str) -> str:
+    """Validate an IANA time zone name such as "Asia/Tokyo"; returns it."""
+    try:
+        ZoneInfo(name)
+    except (ZoneInfoNotFoundError, ValueError) as e:
+        raise ValueError(f"unknown time zone: {name}") from e
+    return name
+
+
+def parse_local_timestamp(value: str, name: str, tz: str) -> Optional[datetime]:
+    """
+    Parse an ISO 8601 timestamp or date (YYYY-MM-DD, meaning local midnight);
+    dates and naive timestamps are local to time zone tz. Empty returns None.
+    """
+    if not value:
+        return None
+    try:
+        if len(value) == 10:
+            day = date.fromisoformat(value)
+            return datetime(day.year, day.month, day.day, tzinfo=ZoneInfo(tz))
+        ts = datetime.fromisoformat(value.replace("Z", "+00:00"))
+    except ValueError as e:
+        raise ValueError(f"{name} must be an ISO 8601 timestamp or date: {value}") from e
+    return ts if ts.tzinfo else ts.replace(tzinfo=ZoneInfo(tz))
+
+
+def check_date_interval(interval: str) -> str:
+    """Validate a date histogram interval; returns it."""
+    if interval not in DATE_INTERVALS:
+        raise ValueError(f"interval must be one of: {', '.join(DATE_INTERVALS)}")
+    return interval
@@ -0,0 +1,94 @@
+"""
+Unique constraints on node data paths.
+
+A unique constraint makes a data path, such as ``data.email``, unique among
+the nodes of a node type. It is enforced by the database with a partial
+unique index, so concurrent writes cannot both succeed; the losing write
+fails with AlreadyExistsError. Nodes without the path are not constrained.
+Values are compared as text, optionally case-insensitively.
+
+Creating a constraint builds its index without blocking writes and fails,
+listing duplicate values, if existing nodes violate it. Documents of node
+types with unique constraints are not compressed, and tenants with
+encrypted data cannot have unique constraints.
+"""
+
+from typing import List
+
+from app.repository import (
+    FieldViolationError,
+    NodeRepository,
+    NodeTypeRepository,
+    UniqueConstraint,
+    UniqueConstraintRepository,
+)
+from app.repository.ordering import json_path_expression
+
+MAX_CONSTRAINTS_PER_NODE_TYPE = 10
+# Duplicate values listed when a constraint cannot be created
+MAX_REPORTED_DUPLICATES = 10
+
+
+class UniqueConstraintService:
+    """Unique constraint business logic service."""
+
+    def __init__(
+        self,
+        repo: UniqueConstraintRepository,
+        node_type_repo: NodeTypeRepository,
+        node_repo: NodeRepository,
+    ):
+        self.repo = repo
+        self.node_type_repo = node_type_repo
+        self.node_repo = node_repo
+
+    async def create(self, node_type_id: str, path: str, case_insensitive: bool = False) -> UniqueConstraint:
+        """
+        Make a data path (e.g. "data.email") unique among a node type's nodes.
+
+        Raises:
+            ValueError: If the path is invalid, existing nodes have duplicate values, or the tenant's data is encrypted
+            AlreadyExistsError: If the node type already has a constraint on the path
+            NotFoundError: If the node type does not exist
+        """
+        if not node_type_id:
+            raise ValueError("node_type_id is required")
+        if not isinstance(path, str) or not path.startswith("data."):
+            raise FieldViolationError("path must be a data path, e.g. data.email", [("path", "must start with data.")])
+        try:
+            json_path_expression("data", path[len("data."):])
+        except ValueError as e:
+            raise FieldViolationError(str(e), [("path", str(e))]) from None
+        if self.node_repo.data_key is not None:
+            raise ValueError("unique constraints are not available for tenants whose data is encrypted")
+        await self.node_type_repo.get_by_id(node_type_id)
+        if len(await self.repo.list(node_type_id)) >= MAX_CONSTRAINTS_PER_NODE_TYPE:
+            raise ValueError(f"a node type can have at most {MAX_CONSTRAINTS_PER_NODE_TYPE} unique constraints")
+
+        constraint = await self.repo.create(UniqueConstraint(
+            node_type_id=node_type_id, path=path[len("data."):].split("."), case_insensitive=bool(case_insensitive),
+        ))
+        try:
+            # Compressed documents are invisible to the index
+            await self.repo.store_plain(node_type_id)
+            duplicates = await self.repo.find_duplicates(constraint, MAX_REPORTED_DUPLICATES)
+            if duplicates:
+                listed = ", ".join(f"{value!r} ({count} nodes)" for value, count in duplicates)
+                raise ValueError(f"existing nodes have duplicate values of {path}: {listed}")
+            await self.repo.build_index(constraint)
+            # Writes that began before the constraint existed may have stored compressed documents
+            await self.repo.store_plain(node_type_id)
+        except Exception:
+            await self.repo.delete(constraint.id)
+            raise
+        return await self.repo.activate(constraint.id)
+
+    async def list(self, node_type_id: str = "") -> List[UniqueConstraint]:
+        """List the unique constraints of a node type (or of every node type)."""
+        return await self.repo.list(node_type_id)
+
+    async def delete(self, id: str) -> None:
+        """Drop a unique constraint."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete(id)
@@ -49,9 +49,9 @@ class UserService:
             raise ValueError("id is required")
         await self.repo.delete(id)
 
-    async def list(self, page_size: int, page_token: str) -> Tuple[List[User], ListResult]:
+    async def list(self, page_size: int, page_token: str, order_by: str = "") -> Tuple[List[User], ListResult]:
         """Retrieve users with pagination."""
-        opts = ListOptions(page_size=page_size, page_token=page_token)
+        opts = ListOptions(page_size=page_size, page_token=page_token, order_by=order_by)
         return await self.repo.list(opts)
 
     async def add_to_tenant(self, tenant_id: str, user_id: str, role: str) -> TenantUser:
@@ -0,0 +1,237 @@
+"""
+Data validation reports.
+
+Before enforcing node type schemas on existing data, tenants need to know
+how much of it does not conform. A validation report is a background
+operation that checks every stored node against:
+
+- its node type's schema, as a JSON Schema (schemas without JSON Schema
+  keywords constrain nothing);
+- the pre-write validate hooks that apply to updates of its type, as if the
+  node were written back unchanged;
+
+and every relationship against its relationship type's allowed source and
+target node types. Nodes are checked as they read, migrated to their node
+type's current schema version.
+
+The report is newline-delimited JSON in the attachment object store, one
+line per non-conforming entity, downloaded through a presigned URL once the
+operation completes. The operation's affected_count is the number of
+non-conforming entities.
+"""
+
+import json
+from typing import Any, AsyncIterator, Dict, List, Optional, Tuple
+
+from jsonschema import SchemaError, validators
+
+from app.repository import (
+    NodeFilter,
+    NodeRepository,
+    NodeTypeRepository,
+    NotFoundError,
+    Operation,
+    RelationshipFilter,
+    RelationshipRepository,
+    RelationshipType,
+    RelationshipTypeRepository,
+)
+from app.service.data_migrations import DataMigrationService
+from app.service.operation_service import OperationProgress, OperationService
+from app.service.write_hook_service import WriteHookService
+from app.storage import attachment_settings, get_object_store
+
+OPERATION_KIND = "validation_report"
+# Entities read per query
+BATCH_SIZE = 500
+# Violations listed per entity (the rest are counted)
+MAX_VIOLATIONS_PER_ENTITY = 20
+MAX_REPORT_BYTES = 1024 * 1024 * 1024
+
+
+def report_key(tenant_id: str, operation_id: str) -> str:
+    """Object store key of a validation report."""
+    return "/".join(p for p in (tenant_id, "validation-reports", f"{operation_id}.ndjson") if p)
+
+
+def _line(entity: Dict[str, Any], violations: List[Dict[str, str]]) -> bytes:
+    entity["violation_count"] = len(violations)
+    entity["violations"] = violations[:MAX_VIOLATIONS_PER_ENTITY]
+    return (json.dumps(entity, separators=(",", ":")) + "\n").encode()
+
+
+class _NodeTypeRules:
+    """A node type's schema validator and validate hooks, or why its schema cannot be used."""
+
+    def __init__(self, validator: Any, schema_error: str, hooks: List[Any]):
+        self.validator = validator
+        self.schema_error = schema_error
+        self.hooks = hooks
+
+
+def compile_schema(schema: str) -> Tuple[Any, str]:
+    """Return a JSON Schema validator for a node type schema (None if it is empty) and an error if it is invalid."""
+    if not schema or not schema.strip():
+        return None, ""
+    try:
+        parsed = json.loads(schema)
+    except ValueError as e:
+        return None, f"schema is not valid JSON: {e}"
+    if not isinstance(parsed, (dict, bool)):
+        return None, "schema must be a JSON object"
+    cls = validators.validator_for(parsed)
+    try:
+        cls.check_schema(parsed)
+    except SchemaError as e:
+        return None, f"schema is not a valid JSON Schema: {e.message}"
+    return cls(parsed, format_checker=cls.FORMAT_CHECKER), ""
+
+
+class ValidationReportService:
+    """Validates a tenant's stored data against its schemas and constraints in the background."""
+
+    def __init__(
+        self,
+        node_repo: NodeRepository,
+        node_type_repo: NodeTypeRepository,
+        relationship_repo: RelationshipRepository,
+        relationship_type_repo: RelationshipTypeRepository,
+        hook_service: WriteHookService,
+        operation_service: OperationService,
+        data_migrations: Optional[DataMigrationService] = None,
+        tenant_id: str = "",
+    ):
+        self.node_repo = node_repo
+        self.node_type_repo = node_type_repo
+        self.relationship_repo = relationship_repo
+        self.relationship_type_repo = relationship_type_repo
+        self.hook_service = hook_service
+        self.operation_service = operation_service
+        self.data_migrations = data_migrations
+        self.tenant_id = tenant_id
+
+    async def start(self) -> Operation:
+        """
+        Start validating every node and relationship; returns the running operation.
+
+        Raises:
+            RuntimeError: If attachment storage (where reports are kept) is not configured
+        """
+        store = get_object_store()
+        total = await self.node_repo.count_matching(NodeFilter())
+        total += await self.relationship_repo.count_matching(RelationshipFilter())
+
+        async def work(progress: OperationProgress) -> None:
+            key = report_key(self.tenant_id, progress.op.id)
+            await store.put_stream(key, self._report(progress), "application/x-ndjson", MAX_REPORT_BYTES)
+
+        return await self.operation_service.start(OPERATION_KIND, {}, total, work)
+
+    async def get(self, operation_id: str) -> Tuple[Operation, str]:
+        """
+        Return a validation report's operation and, once it has completed, a download URL for the report.
+
+        Raises:
+            NotFoundError: If there is no validation report with that ID
+        """
+        op = await self.operation_service.get_by_id(operation_id)
+        if op.kind != OPERATION_KIND:
+            raise NotFoundError(f"validation report not found: {operation_id}")
+        if op.status != "completed":
+            return op, ""
+        settings = attachment_settings()
+        url = await get_object_store().presigned_get_url(
+            report_key(self.tenant_id, op.id), settings.url_expiry_seconds, f"validation-report-{op.id}.ndjson"
+        )
+        return op, url
+
+    async def _report(self, progress: OperationProgress) -> AsyncIterator[bytes]:
+        async for line in self._node_lines(progress):
+            yield line
+        async for line in self._relationship_lines(progress):
+            yield line
+        await progress.checkpoint(force=True)
+
+    async def _node_lines(self, progress: OperationProgress) -> AsyncIterator[bytes]:
+        rules: Dict[str, _NodeTypeRules] = {}
+        after = ""
+        while True:
+            nodes = await self.node_repo.list_matching(NodeFilter(), after, BATCH_SIZE)
+            if not nodes:
+                return
+            after = nodes[-1].id
+            for node in nodes:
+                if node.node_type_id not in rules:
+                    type_rules = await self._node_type_rules(node.node_type_id)
+                    rules[node.node_type_id] = type_rules
+                    if type_rules.schema_error:
+                        # Reported once for the type; its nodes are checked against the hooks only
+                        yield _line(
+                            {"entity": "node_type", "id": node.node_type_id},
+                            [{"rule": "schema", "message": type_rules.schema_error}],
+                        )
+                violations = await self._check_node(node, rules[node.node_type_id])
+                progress.succeeded(affected=bool(violations))
+                if violations:
+                    yield _line({"entity": "node", "id": node.id, "node_type_id": node.node_type_id}, violations)
+                await progress.checkpoint()
+
+    async def _node_type_rules(self, node_type_id: str) -> _NodeTypeRules:
+        try:
+            node_type = await self.node_type_repo.get_by_id(node_type_id)
+        except NotFoundError:
+            return _NodeTypeRules(None, "node type does not exist", [])
+        validator, error = compile_schema(node_type.schema)
+        return _NodeTypeRules(validator, error, await self.hook_service.validation_hooks(node_type_id))
+
+    async def _check_node(self, node: Any, rules: _NodeTypeRules) -> List[Dict[str, str]]:
+        violations: List[Dict[str, str]] = []
+        try:
+            if self.data_migrations:
+                await self.data_migrations.migrate([node])
+            data = json.loads(node.data or "{}")
+        except ValueError as e:
+            return [{"rule": "data", "message": str(e)}]
+        if rules.validator is not None:
+            for error in rules.validator.iter_errors(data):
+                violations.append({"rule": "schema", "path": error.json_path, "message": error.message})
+        for message in await self.hook_service.check(rules.hooks, node.id, node.node_type_id, node.data):
+            violations.append({"rule": "write_hook", "message": message})
+        return violations
+
+    async def _relationship_lines(self, progress: OperationProgress) -> AsyncIterator[bytes]:
+        types: Dict[str, RelationshipType] = {
+            t.name: t for t in await self.relationship_type_repo.list_all()
+            if t.allowed_source_node_type_ids or t.allowed_target_node_type_ids
+        }
+        after = ""
+        while True:
+            # Every transaction ID is at or after 0
+            rels = await self.relationship_repo.list_changed(NodeFilter(), "0", after, BATCH_SIZE)
+            if not rels:
+                return
+            after = rels[-1].id
+            constrained = [r for r in rels if r.relationship_type in types]
+            endpoint_ids = {r.source_node_id for r in constrained} | {r.target_node_id for r in constrained}
+            node_types = await self.node_repo.get_node_type_ids(list(endpoint_ids)) if endpoint_ids else {}
+            for rel in rels:
+                rel_type = types.get(rel.relationship_type)
+                violations = []
+                if rel_type:
+                    source_type = node_types.get(rel.source_node_id, "")
+                    target_type = node_types.get(rel.target_node_id, "")
+                    if not rel_type.allows(source_type, target_type):
+                        violations.append({
+                            "rule": "endpoint_types",
+                            "message": f"{rel.relationship_type} does not allow {source_type or '?'} -> {target_type or '?'}",
+                        })
+                progress.succeeded(affected=bool(violations))
+                if violations:
+                    yield _line({
+                        "entity": "relationship",
+                        "id": rel.id,
+                        "relationship_type": rel.relationship_type,
+                        "source_node_id": rel.source_node_id,
+                        "target_node_id": rel.target_node_id,
+                    }, violations)
+                await progress.checkpoint()
@@ -0,0 +1,281 @@
+"""
+WriteHook service implementation.
+
+Write hooks are tenant-registered CEL expressions evaluated around node
+writes. Each expression sees these variables:
+
+- ``data``: the node data being written (map)
+- ``previous``: the stored data before an update (empty map on create)
+- ``node``: ``{"id", "node_type_id"}`` (``id`` is empty in pre-write hooks on create)
+- ``operation``: ``"create"`` or ``"update"``
+
+``validate`` hooks must return a bool (false rejects the write) or a string
+(non-empty string rejects the write with that message). ``enrich`` hooks must
+return a map that is shallow-merged into the node data.
+"""
+
+import json
+import logging
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.repository import (
+    WriteHook,
+    WriteHookRepository,
+    NodeTypeRepository,
+    ListResult,
+)
+from app.scripting import ExpressionError, compile_expression, evaluate
+from app.scripting.cel import DEFAULT_TIMEOUT_MS, MAX_TIMEOUT_MS
+from app.service.limits import TenantLimits
+
+logger = logging.getLogger(__name__)
+
+PHASES = ("pre_write", "post_write")
+ACTIONS = ("validate", "enrich")
+OPERATIONS = ("create", "update")
+
+
+class WriteHookService:
+    """WriteHook business logic service."""
+
+    def __init__(
+        self,
+        repo: WriteHookRepository,
+        node_type_repo: NodeTypeRepository,
+        limits: Optional[TenantLimits] = None,
+    ):
+        self.repo = repo
+        self.node_type_repo = node_type_repo
+        self.limits = limits or TenantLimits()
+
+    async def create(
+        self,
+        name: str,
+        expression: str,
+        phase: str = "pre_write",
+        action: str = "validate",
+        node_type_id: str = "",
+        operations: Optional[List[str]] = None,
+        timeout_ms: int = DEFAULT_TIMEOUT_MS,
+        priority: int = 0,
+        enabled: bool = True,
+    ) -> WriteHook:
+        """Register a new write hook."""
+        if not name:
+            raise ValueError("name is required")
+
+        hook = WriteHook(
+            tenant_id="",  # Not stored in tenant database
+            name=name,
+            node_type_id=node_type_id,
+            phase=phase or "pre_write",
+            action=action or "validate",
+            operations=list(operations) if operations else list(OPERATIONS),
+            expression=expression,
+            timeout_ms=timeout_ms or DEFAULT_TIMEOUT_MS,
+            priority=priority,
+            enabled=enabled,
+        )
+        await self._validate(hook)
+        return await self.repo.create(hook)
+
+    async def get_by_id(self, id: str) -> WriteHook:
+        """Retrieve a write hook by ID."""
+        if not id:
+            raise ValueError("id is required")
+        return await self.repo.get_by_id(id)
+
+    async def update(
+        self,
+        id: str,
+        name: str = "",
+        expression: str = "",
+        phase: str = "",
+        action: str = "",
+        node_type_id: Optional[str] = None,
+        operations: Optional[List[str]] = None,
+        timeout_ms: Optional[int] = None,
+        priority: Optional[int] = None,
+        enabled: Optional[bool] = None,
+    ) -> WriteHook:
+        """Update an existing write hook."""
+        if not id:
+            raise ValueError("id is required")
+
+        hook = await self.repo.get_by_id(id)
+
+        if name:
+            hook.name = name
+        if expression:
+            hook.expression = expression
+        if phase:
+            hook.phase = phase
+        if action:
+            hook.action = action
+        if node_type_id is not None:
+            hook.node_type_id = node_type_id
+        if operations:
+            hook.operations = list(operations)
+        if timeout_ms:
+            hook.timeout_ms = timeout_ms
+        if priority is not None:
+            hook.priority = priority
+        if enabled is not None:
+            hook.enabled = enabled
+
+        await self._validate(hook)
+        return await self.repo.update(hook)
+
+    async def delete(self, id: str) -> None:
+        """Delete a write hook."""
+        if not id:
+            raise ValueError("id is required")
+        await self.repo.delete(id)
+
+    async def list(self, page_size: int, page_token: str) -> Tuple[List[WriteHook], ListResult]:
+        """Retrieve write hooks with pagination."""
+        opts = self.limits.list_options(page_size, page_token)
+        return await self.repo.list(opts)
+
+    async def run_pre_write(
+        self,
+        operation: str,
+        node_type_id: str,
+        data: str,
+        previous: Optional[str] = None,
+        node_id: str = "",
+    ) -> str:
+        """
+        Run pre-write hooks and return the (possibly enriched) data.
+
+        Raises:
+            ValueError: If a validate hook rejects the write or a hook fails
+        """
+        hooks = await self.repo.list_active(node_type_id, "pre_write", operation)
+        if not hooks:
+            return data
+
+        current = _parse_object(data)
+        for hook in hooks:
+            activation = _activation(operation, node_id, node_type_id, current, previous)
+            try:
+                result = await evaluate(compile_expression(hook.expression), activation, hook.timeout_ms)
+            except ExpressionError as e:
+                raise ValueError(f"write hook {hook.name} failed: {e}") from e
+            current = _apply_result(hook, result, current)
+
+        return json.dumps(current)
+
+    async def validation_hooks(self, node_type_id: str) -> List[WriteHook]:
+        """The active pre-write validate hooks that apply to updates of a node type."""
+        hooks = await self.repo.list_active(node_type_id, "pre_write", "update")
+        return [h for h in hooks if h.action == "validate"]
+
+    async def check(self, hooks: List[WriteHook], node_id: str, node_type_id: str, data: str) -> List[str]:
+        """
+        Run validate hooks against stored data as if it were written back
+        unchanged; returns the rejection messages (empty if every hook accepts).
+        """
+        current = _parse_object(data)
+        rejections = []
+        for hook in hooks:
+            activation = _activation("update", node_id, node_type_id, current, data)
+            try:
+                result = await evaluate(compile_expression(hook.expression), activation, hook.timeout_ms)
+                _apply_result(hook, result, current)
+            except ExpressionError as e:
+                rejections.append(f"write hook {hook.name} failed: {e}")
+            except ValueError as e:
+                rejections.append(str(e))
+        return rejections
+
+    async def run_post_write(
+        self,
+        operation: str,
+        node_id: str,
+        node_type_id: str,
+        data: str,
+        previous: Optional[str] = None,
+    ) -> Optional[str]:
+        """
+        Run post-write hooks after a node has been stored.
+
+        The write is already committed, so failures are logged rather than
+        raised. Returns enriched data if any enrich hook changed it, else None.
+        """
+        hooks = await self.repo.list_active(node_type_id, "post_write", operation)
+        if not hooks:
+            return None
+
+        current = _parse_object(data)
+        changed = False
+        for hook in hooks:
+            activation = _activation(operation, node_id, node_type_id, current, previous)
+            try:
+                result = await evaluate(compile_expression(hook.expression), activation, hook.timeout_ms)
+                enriched = _apply_result(hook, result, current)
+            except (ExpressionError, ValueError) as e:
+                logger.warning(f"Post-write hook {hook.name} failed for node {node_id}: {e}")
+                continue
+            if enriched != current:
+                current = enriched
+                changed = True
+
+        return json.dumps(current) if changed else None
+
+    async def _validate(self, hook: WriteHook) -> None:
+        """Validate hook configuration and compile its expression."""
+        if hook.phase not in PHASES:
+            raise ValueError(f"phase must be one of: {', '.join(PHASES)}")
+        if hook.action not in ACTIONS:
+            raise ValueError(f"action must be one of: {', '.join(ACTIONS)}")
+        for op in hook.operations:
+            if op not in OPERATIONS:
+                raise ValueError(f"operations must be a subset of: {', '.join(OPERATIONS)}")
+        if hook.timeout_ms < 1 or hook.timeout_ms > MAX_TIMEOUT_MS:
+            raise ValueError(f"timeout_ms must be between 1 and {MAX_TIMEOUT_MS}")
+        if hook.node_type_id:
+            await self.node_type_repo.get_by_id(hook.node_type_id)
+        compile_expression(hook.expression)
+
+
+def _parse_object(data: Optional[str]) -> Dict[str, Any]:
+    """Parse node data into a dict, treating non-objects as empty."""
+    if not data:
+        return {}
+    try:
+        value = json.loads(data)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"data must be valid JSON: {e}") from e
+    return value if isinstance(value, dict) else {}
+
+
+def _activation(
+    operation: str,
+    node_id: str,
+    node_type_id: str,
+    data: Dict[str, Any],
+    previous: Optional[str],
+) -> Dict[str, Any]:
+    return {
+        "data": data,
+        "previous": _parse_object(previous),
+        "node": {"id": node_id, "node_type_id": node_type_id},
+        "operation": operation,
+    }
+
+
+def _apply_result(hook: WriteHook, result: Any, data: Dict[str, Any]) -> Dict[str, Any]:
+    """Apply a hook result to the data according to the hook's action."""
+    if hook.action == "validate":
+        if result is True or result == "":
+            return data
+        if isinstance(result, str):
+            raise ValueError(f"write rejected by hook {hook.name}: {result}")
+        if result is False:
+            raise ValueError(f"write rejected by hook {hook.name}")
+        raise ValueError(f"validate hook {hook.name} must return a bool or string")
+
+    if not isinstance(result, dict):
+        raise ValueError(f"enrich hook {hook.name} must return a map")
+    return {**data, **result}
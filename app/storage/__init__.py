@@ -0,0 +1,49 @@
+"""
+Object storage module for binary attachments.
+"""
+
+from app.storage.object_store import (
+    ObjectStore,
+    S3ObjectStore,
+    MemoryObjectStore,
+    StoredObject,
+    AttachmentSettings,
+    attachment_settings,
+    configure_object_store,
+    get_object_store,
+)
+from app.storage.export_destinations import (
+    DESTINATION_SECRET_KEYS,
+    ExportDestination,
+    S3Destination,
+    GCSDestination,
+    AzureBlobDestination,
+    SFTPDestination,
+    LocalDestination,
+    configure_local_exports,
+    destination_scheme,
+    open_destination,
+    register_destination,
+)
+
+__all__ = [
+    "ObjectStore",
+    "S3ObjectStore",
+    "MemoryObjectStore",
+    "StoredObject",
+    "AttachmentSettings",
+    "attachment_settings",
+    "configure_object_store",
+    "get_object_store",
+    "DESTINATION_SECRET_KEYS",
+    "ExportDestination",
+    "S3Destination",
+    "GCSDestination",
+    "AzureBlobDestination",
+    "SFTPDestination",
+    "LocalDestination",
+    "configure_local_exports",
+    "destination_scheme",
+    "open_destination",
+    "register_destination",
+]
@@ -0,0 +1,365 @@
+"""
+Destinations of scheduled exports.
+
+A destination is a URL naming where export files are written. Each scheme
+is handled by a driver; the built-in drivers are:
+
+- ``s3://bucket/prefix/``: an S3 bucket
+- ``gcs://bucket/prefix/``: a Google Cloud Storage bucket
+- ``azure://account/container/prefix/``: an Azure Blob Storage container
+- ``sftp://host[:port]/path/``: a directory on an SFTP server
+- ``file:///nightly/``: a directory below EXPORT_LOCAL_ROOT on the server
+  (unavailable unless EXPORT_LOCAL_ROOT is set)
+
+Drivers may be given a secret, the tenant's destination credential for the
+scheme, as a JSON object with the keys listed in DESTINATION_SECRET_KEYS.
+S3 and GCS fall back to the server's own credentials without one; Azure
+and SFTP require one. register_destination adds drivers for other schemes.
+
+Each run writes one new file, named after the schedule and the run's start
+time, below the destination. A file is only visible once completely
+written: an interrupted write leaves nothing behind (or, for SFTP, a
+``.partial`` file).
+
+Client libraries are imported when a destination is first opened, so only
+the ones in use need to be installed: boto3 (S3), google-cloud-storage
+(GCS), azure-storage-blob (Azure) and paramiko (SFTP).
+"""
+
+import asyncio
+import base64
+import hashlib
+import io
+import os
+import posixpath
+from typing import Any, AsyncIterator, Callable, Dict, Optional, Tuple
+from urllib.parse import ParseResult, unquote, urlparse
+
+from app.storage.object_store import PART_SIZE, S3ObjectStore, StoredObject
+
+# Directory file:// destinations are confined to ("" disables them)
+_local_root = ""
+
+SFTP_TIMEOUT_SECONDS = 30.0
+
+
+def configure_local_exports(root: str) -> None:
+    """Set the directory file:// export destinations are written below ("" disables them)."""
+    global _local_root
+    _local_root = os.path.abspath(root) if root else ""
+
+
+class ExportDestination:
+    """Interface for export destinations."""
+
+    async def write(
+        self, name: str, chunks: AsyncIterator[bytes], content_type: str, max_bytes: int
+    ) -> Tuple[str, StoredObject]:
+        """
+        Write a file from a stream of chunks; returns its URL and size.
+
+        Raises:
+            ValueError: If the stream exceeds max_bytes (nothing is kept)
+        """
+        raise NotImplementedError
+
+
+class _Meter:
+    """Counts and hashes written bytes, enforcing the size limit."""
+
+    def __init__(self, max_bytes: int):
+        self.max_bytes = max_bytes
+        self.size = 0
+        self.digest = hashlib.sha256()
+
+    def add(self, chunk: bytes) -> bytes:
+        self.size += len(chunk)
+        if self.size > self.max_bytes:
+            raise ValueError(f"export exceeds maximum size of {self.max_bytes} bytes")
+        self.digest.update(chunk)
+        return chunk
+
+    def stored(self) -> StoredObject:
+        return StoredObject(size_bytes=self.size, sha256=self.digest.hexdigest())
+
+
+def _prefix(path: str) -> str:
+    prefix = unquote(path).lstrip("/")
+    return prefix if not prefix or prefix.endswith("/") else prefix + "/"
+
+
+class S3Destination(ExportDestination):
+    """Export files in an S3 bucket."""
+
+    def __init__(self, bucket: str, prefix: str, secret: Optional[Dict[str, Any]] = None):
+        secret = secret or {}
+        self.bucket = bucket
+        self.prefix = prefix
+        self.store = S3ObjectStore(
+            bucket,
+            endpoint_url=secret.get("endpoint_url", ""),
+            region=secret.get("region", ""),
+            key_prefix=prefix,
+            access_key_id=secret.get("access_key_id", ""),
+            secret_access_key=secret.get("secret_access_key", ""),
+            session_token=secret.get("session_token", ""),
+        )
+
+    async def write(
+        self, name: str, chunks: AsyncIterator[bytes], content_type: str, max_bytes: int
+    ) -> Tuple[str, StoredObject]:
+        stored = await self.store.put_stream(name, chunks, content_type, max_bytes)
+        return f"s3://{self.bucket}/{self.prefix}{name}", stored
+
+
+class GCSDestination(ExportDestination):
+    """Export files in a Google Cloud Storage bucket (resumable uploads, finalized when complete)."""
+
+    def __init__(self, bucket: str, prefix: str, secret: Optional[Dict[str, Any]] = None):
+        from google.cloud import storage
+
+        account = (secret or {}).get("service_account")
+        # Without a service account key, credentials come from the server's environment
+        client = storage.Client.from_service_account_info(account) if account else storage.Client()
+        self.bucket_name = bucket
+        self.bucket = client.bucket(bucket)
+        self.prefix = prefix
+
+    async def write(
+        self, name: str, chunks: AsyncIterator[bytes], content_type: str, max_bytes: int
+    ) -> Tuple[str, StoredObject]:
+        meter = _Meter(max_bytes)
+        blob = self.bucket.blob(self.prefix + name)
+        writer = await asyncio.to_thread(blob.open, "wb", content_type=content_type, chunk_size=PART_SIZE)
+        async for chunk in chunks:
+            await asyncio.to_thread(writer.write, meter.add(chunk))
+        # An upload that is not closed is never finalized
+        await asyncio.to_thread(writer.close)
+        return f"gcs://{self.bucket_name}/{self.prefix}{name}", meter.stored()
+
+
+class AzureBlobDestination(ExportDestination):
+    """Export files in an Azure Blob Storage container (staged blocks, committed when complete)."""
+
+    def __init__(self, account: str, container: str, prefix: str, secret: Dict[str, Any]):
+        from azure.storage.blob import BlobServiceClient
+
+        credential = secret.get("account_key") or secret.get("sas_token")
+        if not credential:
+            raise ValueError("azure destinations need a credential with account_key or sas_token")
+        self.account = account
+        self.container = container
+        self.prefix = prefix
+        self.service = BlobServiceClient(f"https://{account}.blob.core.windows.net", credential=credential)
+
+    async def write(
+        self, name: str, chunks: AsyncIterator[bytes], content_type: str, max_bytes: int
+    ) -> Tuple[str, StoredObject]:
+        from azure.storage.blob import BlobBlock, ContentSettings
+
+        meter = _Meter(max_bytes)
+        blob = self.service.get_blob_client(self.container, self.prefix + name)
+        blocks = []
+        buffer = bytearray()
+
+        async def stage() -> None:
+            block_id = base64.b64encode(f"{len(blocks):08d}".encode()).decode()
+            await asyncio.to_thread(blob.stage_block, block_id, bytes(buffer))
+            blocks.append(BlobBlock(block_id=block_id))
+            buffer.clear()
+
+        # Uncommitted blocks are discarded by the service
+        async for chunk in chunks:
+            buffer.extend(meter.add(chunk))
+            if len(buffer) >= PART_SIZE:
+                await stage()
+        if buffer or not blocks:
+            await stage()
+        await asyncio.to_thread(
+            blob.commit_block_list, blocks, content_settings=ContentSettings(content_type=content_type)
+        )
+        return f"azure://{self.account}/{self.container}/{self.prefix}{name}", meter.stored()
+
+
+class SFTPDestination(ExportDestination):
+    """Export files in a directory on an SFTP server, verified by its host key."""
+
+    def __init__(self, host: str, port: int, directory: str, username: str, secret: Dict[str, Any]):
+        self.host = host
+        self.port = port
+        self.directory = directory
+        self.username = secret.get("username") or username
+        self.secret = secret
+        if not self.username:
+            raise ValueError("sftp destinations need a username")
+        if not secret.get("host_key"):
+            raise ValueError("sftp destinations need a credential with the server's host_key")
+        if not secret.get("password") and not secret.get("private_key"):
+            raise ValueError("sftp destinations need a credential with a password or private_key")
+
+    def _connect(self) -> Any:
+        import paramiko
+
+        transport = paramiko.Transport((self.host, self.port))
+        try:
+            transport.start_client(timeout=SFTP_TIMEOUT_SECONDS)
+            key = transport.get_remote_server_key()
+            expected = self.secret["host_key"].split()
+            if [key.get_name(), key.get_base64()] != expected[:2]:
+                raise ValueError(f"host key of {self.host} does not match the credential's host_key")
+            if self.secret.get("private_key"):
+                transport.auth_publickey(self.username, _private_key(
+                    self.secret["private_key"], self.secret.get("passphrase") or None
+                ))
+            else:
+                transport.auth_password(self.username, self.secret["password"])
+            return paramiko.SFTPClient.from_transport(transport)
+        except BaseException:
+            transport.close()
+            raise
+
+    async def write(
+        self, name: str, chunks: AsyncIterator[bytes], content_type: str, max_bytes: int
+    ) -> Tuple[str, StoredObject]:
+        meter = _Meter(max_bytes)
+        sftp = await asyncio.to_thread(self._connect)
+        path = posixpath.join(self.directory, name)
+        try:
+            remote = await asyncio.to_thread(sftp.open, path + ".partial", "wb")
+            with remote:
+                async for chunk in chunks:
+                    await asyncio.to_thread(remote.write, meter.add(chunk))
+            await asyncio.to_thread(sftp.posix_rename, path + ".partial", path)
+        finally:
+            await asyncio.to_thread(sftp.get_channel().get_transport().close)
+        return f"sftp://{self.host}:{self.port}{path}", meter.stored()
+
+
+def _private_key(text: str, passphrase: Optional[str]) -> Any:
+    import paramiko
+
+    for key_type in (paramiko.Ed25519Key, paramiko.ECDSAKey, paramiko.RSAKey):
+        try:
+            return key_type.from_private_key(io.StringIO(text), password=passphrase)
+        except paramiko.SSHException:
+            continue
+    raise ValueError("private_key must be an Ed25519, ECDSA or RSA private key in PEM or OpenSSH format")
+
+
+class LocalDestination(ExportDestination):
+    """Export files in a directory on the server."""
+
+    def __init__(self, directory: str):
+        self.directory = directory
+
+    async def write(
+        self, name: str, chunks: AsyncIterator[bytes], content_type: str, max_bytes: int
+    ) -> Tuple[str, StoredObject]:
+        await asyncio.to_thread(os.makedirs, self.directory, exist_ok=True)
+        meter = _Meter(max_bytes)
+        path = os.path.join(self.directory, name)
+        partial = path + ".partial"
+        try:
+            with open(partial, "wb") as f:
+                async for chunk in chunks:
+                    await asyncio.to_thread(f.write, meter.add(chunk))
+            # Readers never see a partly written file
+            await asyncio.to_thread(os.replace, partial, path)
+        except BaseException:
+            if os.path.exists(partial):
+                os.remove(partial)
+            raise
+        return f"file://{path}", meter.stored()
+
+
+def _open_s3(url: ParseResult, secret: Optional[Dict[str, Any]]) -> ExportDestination:
+    if not url.netloc:
+        raise ValueError("destination must name a bucket, e.g. s3://bucket/prefix/")
+    return S3Destination(url.netloc, _prefix(url.path), secret)
+
+
+def _open_gcs(url: ParseResult, secret: Optional[Dict[str, Any]]) -> ExportDestination:
+    if not url.netloc:
+        raise ValueError("destination must name a bucket, e.g. gcs://bucket/prefix/")
+    return GCSDestination(url.netloc, _prefix(url.path), secret)
+
+
+def _open_azure(url: ParseResult, secret: Optional[Dict[str, Any]]) -> ExportDestination:
+    container, _, prefix = url.path.lstrip("/").partition("/")
+    if not url.netloc or not container:
+        raise ValueError("destination must name an account and container, e.g. azure://account/container/prefix/")
+    if not secret:
+        raise ValueError("azure destinations need a credential")
+    return AzureBlobDestination(url.netloc, container, _prefix(prefix), secret)
+
+
+def _open_sftp(url: ParseResult, secret: Optional[Dict[str, Any]]) -> ExportDestination:
+    if not url.hostname:
+        raise ValueError("destination must name a host, e.g. sftp://host/path/")
+    if url.password:
+        raise ValueError("sftp passwords belong in a destination credential, not the destination")
+    if not secret:
+        raise ValueError("sftp destinations need a credential")
+    return SFTPDestination(url.hostname, url.port or 22, unquote(url.path) or "/", unquote(url.username or ""), secret)
+
+
+def _open_file(url: ParseResult, secret: Optional[Dict[str, Any]]) -> ExportDestination:
+    if not _local_root:
+        raise ValueError("file destinations are not enabled (set EXPORT_LOCAL_ROOT)")
+    if url.netloc:
+        raise ValueError("file destinations must not name a host, e.g. file:///nightly/")
+    directory = os.path.abspath(os.path.join(_local_root, unquote(url.path).lstrip("/")))
+    if os.path.commonpath([directory, _local_root]) != _local_root:
+        raise ValueError("destination must be below EXPORT_LOCAL_ROOT")
+    return LocalDestination(directory)
+
+
+DestinationOpener = Callable[[ParseResult, Optional[Dict[str, Any]]], ExportDestination]
+
+_drivers: Dict[str, DestinationOpener] = {
+    "s3": _open_s3,
+    "gcs": _open_gcs,
+    "azure": _open_azure,
+    "sftp": _open_sftp,
+    "file": _open_file,
+}
+
+# Keys of the secret of each scheme's destination credentials
+DESTINATION_SECRET_KEYS: Dict[str, Tuple[str, ...]] = {
+    "s3": ("access_key_id", "secret_access_key", "session_token", "region", "endpoint_url"),
+    "gcs": ("service_account",),
+    "azure": ("account_key", "sas_token"),
+    "sftp": ("username", "password", "private_key", "passphrase", "host_key"),
+}
+
+
+def register_destination(scheme: str, opener: DestinationOpener, secret_keys: Tuple[str, ...] = ()) -> None:
+    """Add (or replace) the driver of a destination URL scheme; secret_keys enables credentials for it."""
+    _drivers[scheme] = opener
+    if secret_keys:
+        DESTINATION_SECRET_KEYS[scheme] = secret_keys
+
+
+def destination_scheme(url: str) -> str:
+    """
+    Return the scheme of a destination URL.
+
+    Raises:
+        ValueError: If the URL is empty or its scheme has no driver
+    """
+    if not isinstance(url, str) or not url:
+        raise ValueError("destination is required")
+    scheme = urlparse(url).scheme
+    if scheme not in _drivers:
+        raise ValueError(f"destination scheme must be one of: {', '.join(_drivers)} (got {scheme or url!r})")
+    return scheme
+
+
+def open_destination(url: str, secret: Optional[Dict[str, Any]] = None) -> ExportDestination:
+    """
+    Return the destination a URL names, writing with a credential's secret if given.
+
+    Raises:
+        ValueError: If the URL is malformed, its scheme is not supported or the secret is insufficient
+    """
+    return _drivers[destination_scheme(url)](urlparse(url), secret)
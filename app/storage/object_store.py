@@ -0,0 +1,223 @@
+"""
+Object storage backends.
+
+Attachments are streamed into S3-compatible object storage (AWS S3, MinIO,
+Ceph, ...) and served to clients through presigned download URLs, so blob
+bytes never pass through the database or the JSON-RPC API.
+"""
+
+import asyncio
+import hashlib
+from dataclasses import dataclass
+from typing import AsyncIterator, Dict, Optional
+from urllib.parse import quote
+
+# S3 requires every multipart part except the last to be at least 5 MiB
+PART_SIZE = 8 * 1024 * 1024
+
+
+@dataclass
+class StoredObject:
+    """Result of storing an object."""
+    size_bytes: int
+    sha256: str
+
+
+class ObjectStore:
+    """Interface for object storage backends."""
+
+    async def put_stream(
+        self,
+        key: str,
+        chunks: AsyncIterator[bytes],
+        content_type: str,
+        max_bytes: int,
+    ) -> StoredObject:
+        """
+        Store an object from a stream of chunks.
+
+        Raises:
+            ValueError: If the stream exceeds max_bytes (nothing is stored)
+        """
+        raise NotImplementedError
+
+    async def delete(self, key: str) -> None:
+        """Delete an object; deleting a missing object is not an error."""
+        raise NotImplementedError
+
+    async def presigned_get_url(self, key: str, expires_seconds: int, filename: str = "") -> str:
+        """Return a time-limited URL for downloading an object."""
+        raise NotImplementedError
+
+
+class S3ObjectStore(ObjectStore):
+    """S3-compatible object store (boto3 calls run off the event loop)."""
+
+    def __init__(
+        self,
+        bucket: str,
+        endpoint_url: str = "",
+        region: str = "",
+        key_prefix: str = "",
+        access_key_id: str = "",
+        secret_access_key: str = "",
+        session_token: str = "",
+    ):
+        import boto3
+
+        self.bucket = bucket
+        self.key_prefix = key_prefix
+        # Without explicit keys, credentials come from the standard AWS environment
+        self.client = boto3.client(
+            "s3",
+            endpoint_url=endpoint_url or None,
+            region_name=region or None,
+            aws_access_key_id=access_key_id or None,
+            aws_secret_access_key=secret_access_key or None,
+            aws_session_token=session_token or None,
+        )
+
+    def _key(self, key: str) -> str:
+        return f"{self.key_prefix}{key}"
+
+    async def put_stream(
+        self,
+        key: str,
+        chunks: AsyncIterator[bytes],
+        content_type: str,
+        max_bytes: int,
+    ) -> StoredObject:
+        full_key = self._key(key)
+        digest = hashlib.sha256()
+        size = 0
+        buffer = bytearray()
+        upload_id: Optional[str] = None
+        parts = []
+
+        try:
+            async for chunk in chunks:
+                size += len(chunk)
+                if size > max_bytes:
+                    raise ValueError(f"attachment exceeds maximum size of {max_bytes} bytes")
+                digest.update(chunk)
+                buffer.extend(chunk)
+
+                if len(buffer) >= PART_SIZE:
+                    if upload_id is None:
+                        upload = await asyncio.to_thread(
+                            self.client.create_multipart_upload,
+                            Bucket=self.bucket, Key=full_key, ContentType=content_type,
+                        )
+                        upload_id = upload["UploadId"]
+                    parts.append(await self._upload_part(full_key, upload_id, len(parts) + 1, bytes(buffer)))
+                    buffer.clear()
+
+            if upload_id is None:
+                await asyncio.to_thread(
+                    self.client.put_object,
+                    Bucket=self.bucket, Key=full_key, Body=bytes(buffer), ContentType=content_type,
+                )
+            else:
+                if buffer:
+                    parts.append(await self._upload_part(full_key, upload_id, len(parts) + 1, bytes(buffer)))
+                await asyncio.to_thread(
+                    self.client.complete_multipart_upload,
+                    Bucket=self.bucket, Key=full_key, UploadId=upload_id,
+                    MultipartUpload={"Parts": parts},
+                )
+        except BaseException:
+            if upload_id is not None:
+                await asyncio.to_thread(
+                    self.client.abort_multipart_upload,
+                    Bucket=self.bucket, Key=full_key, UploadId=upload_id,
+                )
+            raise
+
+        return StoredObject(size_bytes=size, sha256=digest.hexdigest())
+
+    async def _upload_part(self, key: str, upload_id: str, number: int, body: bytes) -> Dict[str, object]:
+        response = await asyncio.to_thread(
+            self.client.upload_part,
+            Bucket=self.bucket, Key=key, UploadId=upload_id, PartNumber=number, Body=body,
+        )
+        return {"PartNumber": number, "ETag": response["ETag"]}
+
+    async def delete(self, key: str) -> None:
+        await asyncio.to_thread(self.client.delete_object, Bucket=self.bucket, Key=self._key(key))
+
+    async def presigned_get_url(self, key: str, expires_seconds: int, filename: str = "") -> str:
+        params = {"Bucket": self.bucket, "Key": self._key(key)}
+        if filename:
+            params["ResponseContentDisposition"] = f"attachment; filename*=UTF-8''{quote(filename)}"
+        return await asyncio.to_thread(
+            self.client.generate_presigned_url,
+            "get_object", Params=params, ExpiresIn=expires_seconds,
+        )
+
+
+class MemoryObjectStore(ObjectStore):
+    """In-process object store for tests and local development."""
+
+    def __init__(self, base_url: str = "memory://"):
+        self.base_url = base_url
+        self.objects: Dict[str, bytes] = {}
+
+    async def put_stream(
+        self,
+        key: str,
+        chunks: AsyncIterator[bytes],
+        content_type: str,
+        max_bytes: int,
+    ) -> StoredObject:
+        buffer = bytearray()
+        async for chunk in chunks:
+            buffer.extend(chunk)
+            if len(buffer) > max_bytes:
+                raise ValueError(f"attachment exceeds maximum size of {max_bytes} bytes")
+        self.objects[key] = bytes(buffer)
+        return StoredObject(size_bytes=len(buffer), sha256=hashlib.sha256(buffer).hexdigest())
+
+    async def delete(self, key: str) -> None:
+        self.objects.pop(key, None)
+
+    async def presigned_get_url(self, key: str, expires_seconds: int, filename: str = "") -> str:
+        return f"{self.base_url}{key}?expires={expires_seconds}"
+
+
+@dataclass
+class AttachmentSettings:
+    """Limits applied to attachment uploads and downloads."""
+    max_bytes: int = 100 * 1024 * 1024
+    # How long a reserved upload may wait for its content
+    upload_ttl_seconds: int = 900
+    # Lifetime of presigned download URLs
+    url_expiry_seconds: int = 300
+
+
+_store: Optional[ObjectStore] = None
+_settings = AttachmentSettings()
+
+
+def configure_object_store(store: Optional[ObjectStore], settings: Optional[AttachmentSettings] = None) -> None:
+    """Set the object store used for attachments (None disables attachments)."""
+    global _store, _settings
+    _store = store
+    if settings is not None:
+        _settings = settings
+
+
+def attachment_settings() -> AttachmentSettings:
+    """Return the configured attachment limits."""
+    return _settings
+
+
+def get_object_store() -> ObjectStore:
+    """
+    Return the configured object store.
+
+    Raises:
+        RuntimeError: If attachments are not configured
+    """
+    if _store is None:
+        raise RuntimeError("attachment storage is not configured")
+    return _store
@@ -0,0 +1,180 @@
+"""
+flex-db as a library.
+
+Runs the flex-db services in-process, without the JSON-RPC server, for
+programs that embed flex-db:
+
+    import flexdb
+
+    async with await flexdb.open(config) as db:
+        tenant = await db.tenants.create("acme", "Acme")
+        acme = await db.tenant(tenant.id)
+        article = await acme.node_types.create("Article", "", "{}")
+        node = await acme.nodes.create(article.id, '{"title": "Hello"}')
+
+open() connects to PostgreSQL and runs the control migrations like the
+server does at startup. open_memory() returns a single tenant held in
+memory (see app/repository/memory_repo.py), with no database at all.
+
+Calls go straight to the services, so the JSON-RPC interceptors (request
+logging, authentication, authorization policies, maintenance mode) do not
+apply; the embedding program is trusted.
+"""
+
+from typing import Any, Dict, Optional
+
+from app.api.dependencies import create_tenant_services
+from app.config import Config, config_from_env
+from app.db import (
+    Database,
+    TenantDatabaseManager,
+    connect_control_db,
+    ensure_control_database_exists,
+    run_control_migrations,
+)
+from app.repository import (
+    MemoryNodeRepository,
+    MemoryNodeTypeRepository,
+    MemoryRelationshipRepository,
+    MemoryRelationshipTypeRepository,
+    MemoryStore,
+    TenantKeyRepository,
+    TenantRepository,
+    TenantTemplateRepository,
+    UserRepository,
+)
+from app.repository.compression import configure_compression
+from app.repository.encryption import configure_encryption
+from app.repository.retry import RetryPolicy, configure_retry_policy
+from app.service import (
+    NodeService,
+    NodeTypeService,
+    RelationshipService,
+    RelationshipTypeService,
+    TemplateService,
+    TenantKeyService,
+    TenantLimits,
+    TenantLimitsCache,
+    TenantMaintenanceCache,
+    TenantService,
+    UserService,
+)
+
+__all__ = ["FlexDB", "TenantHandle", "open", "open_memory"]
+
+
+class TenantHandle:
+    """The services of one tenant."""
+
+    def __init__(self, services: Dict[str, Any]):
+        # Every tenant-scoped service by name, as create_tenant_services returns them
+        self.services = services
+        self.node_types: NodeTypeService = services["node_type"]
+        self.nodes: NodeService = services["node"]
+        self.relationships: RelationshipService = services["relationship"]
+        self.relationship_types: RelationshipTypeService = services["relationship_type"]
+
+
+class FlexDB:
+    """An open flex-db instance: control services and access to tenants."""
+
+    def __init__(
+        self,
+        control_db: Database,
+        tenant_db_manager: TenantDatabaseManager,
+        tenants: TenantService,
+        users: UserService,
+        limits_cache: TenantLimitsCache,
+        tenant_keys: TenantKeyService,
+    ):
+        self.control_db = control_db
+        self.tenant_db_manager = tenant_db_manager
+        self.tenants = tenants
+        self.users = users
+        self.limits_cache = limits_cache
+        self.tenant_keys = tenant_keys
+
+    async def tenant(self, tenant_id: str) -> TenantHandle:
+        """Return the services of a tenant, with its limits and data key."""
+        return TenantHandle(await self._tenant_services(tenant_id))
+
+    async def _tenant_services(self, tenant_id: str) -> Dict[str, Any]:
+        tenant_db = await self.tenant_db_manager.get_tenant_db(tenant_id)
+        limits = await self.limits_cache.get(tenant_id)
+        data_key = await self.tenant_keys.active_key(tenant_id)
+        return create_tenant_services(tenant_db, tenant_id, limits, data_key)
+
+    async def close(self) -> None:
+        """Close every database connection."""
+        await self.tenant_db_manager.close_all_pools()
+        await self.control_db.close()
+
+    async def __aenter__(self) -> "FlexDB":
+        return self
+
+    async def __aexit__(self, *exc_info) -> None:
+        await self.close()
+
+
+async def open(cfg: Optional[Config] = None) -> FlexDB:
+    """
+    Connect to flex-db's PostgreSQL databases (configured from the
+    environment by default) and run the control database migrations.
+    """
+    cfg = cfg or config_from_env()
+    configure_compression(cfg.compression_threshold_bytes, cfg.compression_level)
+    configure_encryption(cfg.encryption_master_key)
+    configure_retry_policy(RetryPolicy(
+        max_attempts=cfg.db_retry_max_attempts,
+        base_delay_seconds=cfg.db_retry_base_delay_ms / 1000,
+        max_delay_seconds=cfg.db_retry_max_delay_ms / 1000,
+    ))
+
+    await ensure_control_database_exists(cfg)
+    control_db = await connect_control_db(cfg)
+    try:
+        await run_control_migrations(control_db)
+    except Exception:
+        await control_db.close()
+        raise
+    tenant_db_manager = TenantDatabaseManager(cfg, control_db)
+
+    tenant_repo = TenantRepository(control_db)
+    limits_cache = TenantLimitsCache(tenant_repo)
+    tenant_keys = TenantKeyService(TenantKeyRepository(control_db), tenant_db_manager)
+    db: Optional[FlexDB] = None
+
+    async def tenant_services(tenant_id: str) -> Dict[str, Any]:
+        return await db._tenant_services(tenant_id)
+
+    template_svc = TemplateService(TenantTemplateRepository(control_db), tenant_services)
+    tenant_svc = TenantService(
+        tenant_repo, tenant_db_manager, cfg.tenant_delete_grace_seconds, limits_cache, template_svc,
+        TenantMaintenanceCache(tenant_repo),
+    )
+    db = FlexDB(
+        control_db, tenant_db_manager, tenant_svc, UserService(UserRepository(control_db)), limits_cache, tenant_keys
+    )
+    return db
+
+
+def open_memory(limits: Optional[TenantLimits] = None) -> TenantHandle:
+    """
+    Return a single tenant held in memory, with the node type, node,
+    relationship and relationship type services.
+    """
+    store = MemoryStore()
+    node_type_repo = MemoryNodeTypeRepository(store)
+    node_repo = MemoryNodeRepository(store)
+    relationship_repo = MemoryRelationshipRepository(store)
+    relationship_type_repo = MemoryRelationshipTypeRepository(store)
+    limits = limits or TenantLimits()
+    return TenantHandle({
+        "node_type": NodeTypeService(node_type_repo, limits),
+        "node": NodeService(
+            node_repo, node_type_repo, relationship_repo=relationship_repo,
+            rel_type_repo=relationship_type_repo, limits=limits,
+        ),
+        "relationship": RelationshipService(relationship_repo, node_repo, relationship_type_repo, limits),
+        "relationship_type": RelationshipTypeService(relationship_type_repo, node_type_repo, limits, relationship_repo),
+    })
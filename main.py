@@ -11,27 +11,121 @@ import sys
 
 from contextlib import asynccontextmanager
 from dotenv import load_dotenv
-from fastapi import FastAPI
+from fastapi import FastAPI, Response
 from fastapi.middleware.cors import CORSMiddleware
 import uvicorn
 
-from app.config import config_from_env
+from app.config import config_from_env, server_config_from_env
+from app.metrics import metrics
+from app.metrics_statsd import StatsdSink
 from app.db import (
     connect_control_db,
     run_control_migrations,
     ensure_control_database_exists,
     TenantDatabaseManager,
 )
+from app.db.online_migrations import MIGRATION_MODES
+from app.db.priority import set_batch_pool_share
+from app.db.read_sessions import ReadSessionManager
+from app.service.change_stream import ChangeStreamManager
 from app.repository import (
     TenantRepository,
     UserRepository,
+    AuthzPolicyRepository,
+    ImpersonationRepository,
+    ApiKeyRepository,
+    RequestNonceRepository,
+    AuditRepository,
+    DatabaseStatsRepository,
+    TenantTemplateRepository,
+    TenantKeyRepository,
+    SearchCursorRepository,
+    BillingEventRepository,
+    TombstoneRepository,
+    ExportScheduleRepository,
+    DestinationCredentialRepository,
+    DualWriteTargetRepository,
+    ShardMapRepository,
 )
+from app.repository.compression import configure_compression
+from app.repository.encryption import configure_encryption
+from app.repository.retry import RetryPolicy, configure_retry_policy
+from app.search import OpenSearchIndex, configure_search_index
+from app.storage import AttachmentSettings, S3ObjectStore, configure_local_exports, configure_object_store
 from app.service import (
     TenantService,
     UserService,
+    AuthzPolicyService,
+    AuditService,
+    ImpersonationService,
+    TenantLimitsCache,
+    TenantMaintenanceCache,
+    TenantDebugCache,
+    StatsService,
+    TemplateService,
+    TenantKeyService,
+    SearchIndexer,
+    QueryCache,
+    ImportThrottle,
+    BillingService,
+    TenantComparisonService,
+    ExportScheduleService,
+    AdminSearchService,
+    OrphanCollector,
+    TableMaintenanceService,
+    TableMaintenanceScheduler,
+    ExplainService,
+    ApiKeyService,
+    OnboardingService,
+    DualWriteService,
+    ShardService,
 )
+from app.authz import (
+    CertificateMapper,
+    PolicyEngine,
+    authz_interceptor,
+    client_cert_interceptor,
+    api_key_interceptor,
+    RequestSignatureVerifier,
+    request_signature_interceptor,
+    impersonation_interceptor,
+    principal_interceptor,
+)
+from app.jobs import PeriodicJob, SideEffectQueue
 from app.jsonrpc import register_methods, jsonrpc_router
-from app.api.dependencies import set_tenant_db_manager
+from app.jsonrpc.billing import billing_interceptor
+from app.jsonrpc.consistency import consistency_interceptor
+from app.jsonrpc.db_labels import db_label_interceptor
+from app.jsonrpc.debug_log import debug_log_interceptor
+from app.jsonrpc.external_ids import AesIdCodec, external_id_interceptor
+from app.jsonrpc.interceptors import add_interceptor
+from app.jsonrpc.json_limits import JsonLimits, json_limits_interceptor
+from app.jsonrpc.maintenance import maintenance_interceptor
+from app.jsonrpc.priority import priority_interceptor
+from app.jsonrpc.request_log import request_log_interceptor
+from app.jsonrpc.server import set_message_limits, set_request_verifier
+from app.api.dependencies import (
+    get_tenant_db,
+    resolve_tenant_services,
+    set_tenant_db_manager,
+    set_tenant_limits_cache,
+    set_tenant_maintenance_cache,
+    set_tenant_key_service,
+    set_read_session_manager,
+    set_change_stream_manager,
+    set_query_cache,
+    set_import_throttle,
+    set_search_cursor_repo,
+    set_external_id_decoder,
+    open_backup_services,
+    resolve_database_services,
+    set_dual_write_service,
+)
+from app.api.routers.admin import configure_admin_console, router as admin_router
+from app.api.routers.attachments import router as attachments_router
+from app.api.routers.changes import router as changes_router
+from app.api.routers.contracts import router as contracts_router
+from app.api.routers.imports import router as imports_router
 
 # Configure logging
 logging.basicConfig(
@@ -44,12 +138,29 @@ logger = logging.getLogger(__name__)
 # Global database instances
 _control_db = None
 _tenant_db_manager = None
+_tenant_purger = None
+_read_sessions = None
+_dual_writes = None
+_read_session_expirer = None
+_search_indexer = None
+_billing_jobs = []
+_billing_svc = None
+_export_scheduler = None
+_orphan_collector = None
+_table_maintainer = None
+_nonce_purger = None
+_shard_health_checker = None
+_side_effects = None
+_statsd_sink = None
+_statsd_flusher = None
 
 
 @asynccontextmanager
 async def lifespan(app: FastAPI):
     """Lifespan context manager for FastAPI app."""
-    global _control_db, _tenant_db_manager
+    global _control_db, _tenant_db_manager, _tenant_purger, _read_sessions, _read_session_expirer, _search_indexer
+    global _billing_jobs, _billing_svc, _export_scheduler, _orphan_collector, _table_maintainer, _nonce_purger
+    global _side_effects, _statsd_sink, _statsd_flusher, _dual_writes, _shard_health_checker
     
     # Startup
     logger.info("Starting up...")
@@ -62,6 +173,52 @@ async def lifespan(app: FastAPI):
 
     # Load configuration from environment variables
     cfg = config_from_env()
+    configure_compression(cfg.compression_threshold_bytes, cfg.compression_level)
+    configure_encryption(cfg.encryption_master_key)
+    configure_retry_policy(RetryPolicy(
+        max_attempts=cfg.db_retry_max_attempts,
+        base_delay_seconds=cfg.db_retry_base_delay_ms / 1000,
+        max_delay_seconds=cfg.db_retry_max_delay_ms / 1000,
+    ))
+    # Batch-priority work holds at most this share of each pool's connections
+    set_batch_pool_share(cfg.db_batch_pool_share)
+
+    # Metrics are also pushed to a StatsD/DogStatsD agent, for hosts that are not scraped
+    if cfg.metrics_statsd_address:
+        _statsd_sink = StatsdSink(
+            cfg.metrics_statsd_address,
+            cfg.metrics_statsd_format,
+            cfg.metrics_statsd_prefix,
+            [t.strip() for t in cfg.metrics_statsd_tags.split(",")],
+        )
+        metrics.add_sink(_statsd_sink)
+        _statsd_flusher = PeriodicJob("statsd-flush", cfg.metrics_statsd_flush_seconds, _statsd_sink.flush)
+        _statsd_flusher.start()
+        logger.info(f"Pushing {cfg.metrics_statsd_format} metrics to {cfg.metrics_statsd_address}")
+
+    # Attachment storage (credentials come from the standard AWS environment)
+    if cfg.attachment_s3_bucket:
+        configure_object_store(
+            S3ObjectStore(cfg.attachment_s3_bucket, cfg.attachment_s3_endpoint, cfg.attachment_s3_region),
+            AttachmentSettings(
+                max_bytes=cfg.attachment_max_bytes,
+                upload_ttl_seconds=cfg.attachment_upload_ttl_seconds,
+                url_expiry_seconds=cfg.attachment_url_expiry_seconds,
+            ),
+        )
+        logger.info(f"Attachment storage: s3://{cfg.attachment_s3_bucket}")
+
+    # Directory file:// export destinations are confined to (unset: only S3 destinations)
+    configure_local_exports(cfg.export_local_root)
+
+    # Full-text search index (nodes are mirrored by the search indexer job below)
+    search_index = None
+    if cfg.opensearch_url:
+        search_index = OpenSearchIndex(
+            cfg.opensearch_url, cfg.opensearch_index_prefix, cfg.opensearch_username, cfg.opensearch_password
+        )
+        configure_search_index(search_index)
+        logger.info(f"Search index: {cfg.opensearch_url}")
 
     # Ensure control database exists
     logger.info("Ensuring control database exists...")
@@ -81,9 +238,13 @@ async def lifespan(app: FastAPI):
         sys.exit(1)
 
     # Run control database migrations
+    if cfg.migration_mode not in MIGRATION_MODES:
+        logger.error(f"MIGRATION_MODE must be one of: {', '.join(MIGRATION_MODES)}")
+        await _control_db.close()
+        sys.exit(1)
     logger.info("Running control database migrations...")
     try:
-        await run_control_migrations(_control_db)
+        await run_control_migrations(_control_db, cfg)
         logger.info("Control database migrations completed successfully")
     except Exception as e:
         logger.error(f"Failed to run control database migrations: {e}")
@@ -106,27 +267,271 @@ async def lifespan(app: FastAPI):
     user_repo = UserRepository(_control_db)
 
     # Initialize control database services (tenant and user services work with control DB)
-    tenant_svc = TenantService(tenant_repo, _tenant_db_manager)
+    limits_cache = TenantLimitsCache(tenant_repo)
+    set_tenant_limits_cache(limits_cache)
+    tenant_key_svc = TenantKeyService(TenantKeyRepository(_control_db), _tenant_db_manager)
+    set_tenant_key_service(tenant_key_svc)
+    maintenance_cache = TenantMaintenanceCache(tenant_repo)
+    set_tenant_maintenance_cache(maintenance_cache)
+    debug_cache = TenantDebugCache(tenant_repo)
+    template_svc = TemplateService(TenantTemplateRepository(_control_db), resolve_tenant_services)
+    tenant_svc = TenantService(
+        tenant_repo, _tenant_db_manager, cfg.tenant_delete_grace_seconds, limits_cache, template_svc,
+        maintenance_cache, debug_cache,
+    )
     user_svc = UserService(user_repo)
 
+    # Work detached from calls, so cancelled calls still write it and slow webhooks do not hold them
+    _side_effects = SideEffectQueue(
+        cfg.side_effect_max_pending, cfg.side_effect_workers, cfg.side_effect_deadline_seconds
+    )
+    _side_effects.start()
+
+    # Sampled and slow request log; registered first so its latency covers every interceptor
+    add_interceptor(request_log_interceptor(cfg.request_log_sample_rate, cfg.request_log_slow_ms))
+
+    # Pathological JSON documents are rejected before any other work
+    add_interceptor(json_limits_interceptor(JsonLimits(
+        cfg.json_max_depth, cfg.json_max_keys, cfg.json_max_string_length
+    )))
+
+    # Opaque external IDs (EXTERNAL_ID_KEY), translated before anything reads IDs from the parameters
+    id_codec = None
+    if cfg.external_id_key:
+        id_codec = AesIdCodec(cfg.external_id_key)
+        add_interceptor(external_id_interceptor(id_codec))
+        # Streamed import rows bypass the interceptors and are decoded by the import
+        set_external_id_decoder(id_codec.decode)
+
+    # Database sessions labeled with the call's tenant and method (DB_APPLICATION_NAME)
+    if cfg.db_application_name:
+        add_interceptor(db_label_interceptor())
+
+    # Verbose logging and query tracing of tenants an administrator switched debug logging on for
+    add_interceptor(debug_log_interceptor(debug_cache))
+
+    # Usage counted for billing events (after ID translation, so tenant IDs are the stored ones)
+    stats_repo = DatabaseStatsRepository(_control_db)
+    if cfg.billing_flush_interval_seconds > 0:
+        _billing_svc = BillingService(BillingEventRepository(_control_db), tenant_repo, stats_repo)
+        add_interceptor(billing_interceptor(_billing_svc.recorder))
+
+    # Mesh client certificate identities mapped to callers (CLIENT_CERT_MAPPING_FILE)
+    if cfg.client_cert_mapping_file:
+        add_interceptor(client_cert_interceptor(CertificateMapper(cfg.client_cert_mapping_file)))
+
+    # HMAC-signed requests of machine callers (REQUEST_SIGNING_KEYS_FILE), verified before dispatch
+    if cfg.request_signing_keys_file:
+        set_request_verifier(RequestSignatureVerifier(
+            cfg.request_signing_keys_file, RequestNonceRepository(_control_db), cfg.request_signing_tolerance_seconds
+        ).verify)
+        add_interceptor(request_signature_interceptor())
+
+    # Priority classes: batch calls (X-Priority or mapped callers) yield database connections to interactive ones
+    add_interceptor(priority_interceptor(cfg.batch_max_concurrent_calls))
+
+    # Admin impersonation (audited); runs before authorization so policies see the impersonated subject
+    audit_svc = AuditService(AuditRepository(_control_db))
+    impersonation_svc = ImpersonationService(
+        ImpersonationRepository(_control_db),
+        tenant_repo,
+        user_repo,
+        audit_svc,
+        admin_user_ids=[a.strip() for a in cfg.admin_user_ids.split(",")],
+        max_ttl_seconds=cfg.impersonation_max_ttl_seconds,
+    )
+    # Scoped API keys (Authorization: Bearer), applied before impersonation and authorization
+    api_key_svc = ApiKeyService(ApiKeyRepository(_control_db), impersonation_svc.is_admin)
+    add_interceptor(api_key_interceptor(api_key_svc, require_authentication=cfg.require_authentication))
+    add_interceptor(impersonation_interceptor(impersonation_svc, audit_svc, _side_effects))
+    configure_admin_console(impersonation_svc.is_admin)
+
+    # Tenant directory users: disabled users are denied, active ones are exposed to policies
+    async def resolve_principal(tenant_id: str, subject_id: str):
+        return await (await resolve_tenant_services(tenant_id))["directory"].resolve(subject_id)
+
+    add_interceptor(principal_interceptor(resolve_principal))
+
+    # Authorization policies (from AUTHZ_POLICY_FILE and the authz_policies table)
+    authz_repo = AuthzPolicyRepository(_control_db)
+    policy_engine = PolicyEngine(
+        authz_repo,
+        user_repo,
+        policy_file=cfg.authz_policy_file,
+        refresh_seconds=cfg.authz_refresh_seconds,
+        default_decision=cfg.authz_default_decision,
+    )
+    add_interceptor(authz_interceptor(policy_engine))
+
+    # Tenants in read-only maintenance reject writes (after authorization, so denied calls stay denied)
+    add_interceptor(maintenance_interceptor(maintenance_cache))
+
+    # Tenant writes return consistency tokens that reads can wait for
+    async def commit_position(tenant_id: str) -> str:
+        return await TombstoneRepository(await get_tenant_db(tenant_id)).commit_position()
+
+    add_interceptor(consistency_interceptor(commit_position))
+    authz_policy_svc = AuthzPolicyService(authz_repo, on_change=policy_engine.invalidate)
+
+    # Operator statistics, read from the cluster through the control database connection
+    stats_svc = StatsService(stats_repo, tenant_repo, _tenant_db_manager)
+    table_maintenance_svc = TableMaintenanceService(
+        tenant_repo, _tenant_db_manager, cfg.table_maintenance_dead_ratio, cfg.table_maintenance_min_dead_rows
+    )
+    explain_svc = ExplainService(tenant_repo, _tenant_db_manager)
+
+    # Recurring exports, billed like export methods
+    export_schedule_svc = ExportScheduleService(
+        ExportScheduleRepository(_control_db), tenant_repo, resolve_tenant_services,
+        DestinationCredentialRepository(_control_db), usage=_billing_svc.recorder if _billing_svc else None,
+        side_effects=_side_effects,
+    )
+
+    # Support lookups across tenants (admins only), recorded in the audit log
+    admin_search_svc = AdminSearchService(
+        tenant_repo, resolve_tenant_services, audit_svc, id_codec.decode if id_codec else None
+    )
+
+    # Backend migrations: tenants' writes mirrored to a second database
+    _dual_writes = DualWriteService(
+        DualWriteTargetRepository(_control_db), _tenant_db_manager, resolve_tenant_services, resolve_database_services
+    )
+    set_dual_write_service(_dual_writes)
+
     # Register JSON-RPC methods (tenant-scoped services are resolved per-request)
-    register_methods(tenant_svc, user_svc)
+    register_methods(
+        tenant_svc, user_svc, authz_policy_svc, impersonation_svc, audit_svc, stats_svc, template_svc, tenant_key_svc,
+        _billing_svc, TenantComparisonService(resolve_tenant_services, open_backup_services), export_schedule_svc,
+        admin_search_svc, table_maintenance_svc, explain_svc, api_key_svc,
+        OnboardingService(tenant_svc, api_key_svc, resolve_tenant_services), _dual_writes,
+        ShardService(ShardMapRepository(_control_db), _tenant_db_manager, tenant_repo),
+    )
 
     logger.info("Services initialized successfully")
+
+    # Permanently remove tenants whose deletion grace period has ended
+    _tenant_purger = PeriodicJob("tenant-purger", cfg.tenant_purge_interval_seconds, tenant_svc.purge_expired)
+    _tenant_purger.start()
+
+    # Snapshot read sessions, closed once past their lifetime
+    _read_sessions = ReadSessionManager(cfg.read_session_ttl_seconds, cfg.read_session_max)
+    set_read_session_manager(_read_sessions)
+    _read_session_expirer = PeriodicJob("read-session-expirer", 10, _read_sessions.expire)
+    _read_session_expirer.start()
+
+    # Change subscriptions streamed to browser clients
+    set_change_stream_manager(ChangeStreamManager(
+        cfg.change_stream_poll_seconds, cfg.change_stream_max, cfg.change_subscription_ttl_seconds
+    ))
+
+    # Query results of tenants that enable caching (query_cache_seconds limit)
+    set_query_cache(QueryCache(cfg.query_cache_max_entries))
+
+    # Concurrency limits and backpressure for node and relationship imports
+    set_import_throttle(ImportThrottle(
+        cfg.import_max_concurrent, cfg.import_max_delay_ms / 1000, cfg.import_queue_timeout_seconds
+    ))
+
+    # Mirror node writes into the search index from each tenant's change stream
+    if search_index:
+        cursor_repo = SearchCursorRepository(_control_db)
+        set_search_cursor_repo(cursor_repo)
+        indexer = SearchIndexer(tenant_repo, cursor_repo, resolve_tenant_services, search_index)
+        _search_indexer = PeriodicJob("search-indexer", cfg.search_index_interval_seconds, indexer.run)
+        _search_indexer.start()
+
+    # Write counted usage and tenant database size changes as billing events
+    if _billing_svc:
+        _billing_jobs = [
+            PeriodicJob("billing-flush", cfg.billing_flush_interval_seconds, _billing_svc.flush),
+            PeriodicJob("billing-storage", cfg.billing_storage_interval_seconds, _billing_svc.record_storage),
+        ]
+        for job in _billing_jobs:
+            job.start()
+
+    # Run export schedules as they come due
+    if cfg.export_schedule_interval_seconds > 0:
+        _export_scheduler = PeriodicJob(
+            "export-scheduler", cfg.export_schedule_interval_seconds, export_schedule_svc.run_due
+        )
+        _export_scheduler.start()
+
+    # Report (or delete) relationships whose source or target node no longer exists
+    if cfg.orphan_gc_interval_seconds > 0:
+        collector = OrphanCollector(tenant_repo, resolve_tenant_services, cfg.orphan_gc_delete)
+        _orphan_collector = PeriodicJob("orphan-collector", cfg.orphan_gc_interval_seconds, collector.run)
+        _orphan_collector.start()
+
+    # Vacuum bloated tables and analyze partitioned tables of every tenant
+    if cfg.table_maintenance_interval_seconds > 0:
+        maintainer = TableMaintenanceScheduler(tenant_repo, table_maintenance_svc)
+        _table_maintainer = PeriodicJob("table-maintenance", cfg.table_maintenance_interval_seconds, maintainer.run)
+        _table_maintainer.start()
+
+    # Forget nonces of signed requests once their timestamps are too old to replay
+    if cfg.request_signing_keys_file:
+        nonce_repo = RequestNonceRepository(_control_db)
+        _nonce_purger = PeriodicJob("request-nonce-purger", cfg.request_signing_tolerance_seconds, nonce_repo.purge_expired)
+        _nonce_purger.start()
+
+    # Check the shards' servers, so new tenants are not placed on unhealthy ones
+    if len(_tenant_db_manager.shards.shards) > 1 and cfg.shard_health_interval_seconds > 0:
+        _shard_health_checker = PeriodicJob(
+            "shard-health", cfg.shard_health_interval_seconds, _tenant_db_manager.check_shards
+        )
+        _shard_health_checker.start()
     
     yield
     
     # Shutdown
     logger.info("Shutting down...")
+    if _tenant_purger:
+        await _tenant_purger.stop()
+    if _read_session_expirer:
+        await _read_session_expirer.stop()
+    if _search_indexer:
+        await _search_indexer.stop()
+    if search_index:
+        await search_index.close()
+    for job in _billing_jobs:
+        await job.stop()
+    if _export_scheduler:
+        await _export_scheduler.stop()
+    if _orphan_collector:
+        await _orphan_collector.stop()
+    if _nonce_purger:
+        await _nonce_purger.stop()
+    if _shard_health_checker:
+        await _shard_health_checker.stop()
+    if _table_maintainer:
+        await _table_maintainer.stop()
+    if _side_effects:
+        # Audit records and alerts of calls that already returned
+        await _side_effects.stop()
+    if _billing_svc:
+        # Usage counted since the last flush
+        await PeriodicJob("billing-flush", 0, _billing_svc.flush).run_once()
+    if _read_sessions:
+        await _read_sessions.close_all()
+    if _dual_writes:
+        await _dual_writes.close_all()
     if _tenant_db_manager:
         await _tenant_db_manager.close_all_pools()
     if _control_db:
         await _control_db.close()
+    if _statsd_sink:
+        # Metrics counted during shutdown
+        await _statsd_flusher.stop()
+        metrics.remove_sink(_statsd_sink)
+        _statsd_sink.close()
     logger.info("Shutdown complete")
 
 
 def create_app() -> FastAPI:
     """Create and configure the FastAPI application."""
+    server_cfg = server_config_from_env()
+    set_message_limits(server_cfg.max_receive_bytes, server_cfg.max_send_bytes)
+
     app = FastAPI(
         title="flex-db API",
         description="Database-as-a-Service with JSON-RPC 2.0 API",
@@ -150,12 +555,23 @@ def create_app() -> FastAPI:
     
     # Register JSON-RPC router
     app.include_router(jsonrpc_router)
+    app.include_router(attachments_router)
+    app.include_router(changes_router)
+    app.include_router(contracts_router)
+    app.include_router(imports_router)
+    app.include_router(admin_router)
     
     # Health check endpoint
     @app.get("/health")
     async def health_check():
         """Health check endpoint."""
         return {"status": "ok"}
+
+    # Metrics endpoint (Prometheus text format)
+    @app.get("/metrics")
+    async def get_metrics():
+        """Expose in-process metrics."""
+        return Response(content=metrics.render_text(), media_type="text/plain; version=0.0.4")
     
     return app
 
@@ -165,18 +581,21 @@ app = create_app()
 
 
 if __name__ == "__main__":
-    # Get server configuration
-    host = os.getenv("JSONRPC_HOST", "0.0.0.0")
-    port = int(os.getenv("JSONRPC_PORT", "5000"))
+    # Get server configuration (listener address, TLS, keepalive)
+    server_cfg = server_config_from_env()
+    host, port = server_cfg.host, server_cfg.port
+    scheme = "https" if server_cfg.tls_enabled else "http"
     
     logger.info(f"Starting flex-db server on {host}:{port}...")
-    logger.info(f"JSON-RPC endpoint: http://{host}:{port}/jsonrpc")
-    logger.info(f"OpenRPC spec: http://{host}:{port}/openrpc.json")
-    logger.info(f"Health check: http://{host}:{port}/health")
+    logger.info(f"JSON-RPC endpoint: {scheme}://{host}:{port}/jsonrpc")
+    logger.info(f"OpenRPC spec: {scheme}://{host}:{port}/openrpc.json")
+    logger.info(f"API contracts: {scheme}://{host}:{port}/contracts")
+    logger.info(f"Health check: {scheme}://{host}:{port}/health")
+    if server_cfg.tls_client_ca_file:
+        logger.info(f"TLS client certificates: {server_cfg.tls_client_auth}")
     
     uvicorn.run(
         "main:app",
-        host=host,
-        port=port,
         reload=os.getenv("RELOAD", "false").lower() == "true",
+        **server_cfg.uvicorn_options(),
     )
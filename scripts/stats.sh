@@ -0,0 +1,27 @@
+#!/bin/bash
+
+# Print operator statistics: system-wide, or for one tenant when given its ID
+#
+# Usage: scripts/stats.sh [TENANT_ID]
+#
+# FLEXDB_URL       JSON-RPC endpoint (default: http://localhost:5000/jsonrpc)
+# FLEXDB_ADMIN_ID  User ID listed in the server's ADMIN_USER_IDS
+
+BASE_URL="${FLEXDB_URL:-http://localhost:5000/jsonrpc}"
+
+if [ -z "$FLEXDB_ADMIN_ID" ]; then
+    echo "Error: set FLEXDB_ADMIN_ID to an administrator user ID"
+    exit 1
+fi
+
+if [ -n "$1" ]; then
+    REQUEST=$(jq -n --arg tenant_id "$1" \
+        '{jsonrpc: "2.0", method: "get_tenant_stats", params: {tenant_id: $tenant_id}, id: 1}')
+else
+    REQUEST='{"jsonrpc": "2.0", "method": "get_system_stats", "params": {}, "id": 1}'
+fi
+
+curl -s -X POST "$BASE_URL" \
+  -H "Content-Type: application/json" \
+  -H "X-User-ID: $FLEXDB_ADMIN_ID" \
+  -d "$REQUEST" | jq '.error // .result.stats'
@@ -2,10 +2,14 @@
 End-to-end API integration tests.
 """
 
+import json
+
 import pytest
 from httpx import AsyncClient
 
+from app.authz.client_certs import CertificateMapper, client_cert_interceptor
 from app.jsonrpc.handlers import register_methods
+from app.jsonrpc.interceptors import add_interceptor, remove_interceptor
 
 
 @pytest.mark.asyncio
@@ -173,6 +177,62 @@ async def test_openrpc_spec(async_client: AsyncClient):
     # The response should be valid OpenRPC spec (either wrapped in openrpc key or direct)
 
 
+@pytest.mark.asyncio
+async def test_contracts(async_client: AsyncClient):
+    """Test listing and fetching versioned API contracts."""
+    response = await async_client.get("/contracts")
+    assert response.status_code == 200
+    contracts = {c["name"]: c for c in response.json()["contracts"]}
+    assert set(contracts) == {"openrpc", "openrpc-v2", "openapi"}
+
+    response = await async_client.get(contracts["openrpc"]["url"])
+    assert response.status_code == 200
+    assert "methods" in response.json()
+    etag = response.headers["etag"]
+    assert etag == f'"{contracts["openrpc"]["digest"]}"'
+
+    response = await async_client.get("/contracts/openrpc.json", headers={"If-None-Match": etag})
+    assert response.status_code == 304
+
+    response = await async_client.get("/contracts/openrpc/0.0.1.json")
+    assert response.status_code == 404
+
+
+@pytest.mark.asyncio
+async def test_admin_console(async_client: AsyncClient, admin_user_id, test_tenant, tmp_path):
+    """Test that the admin console is limited to authenticated administrators and read methods."""
+    response = await async_client.get("/admin")
+    assert response.status_code == 200
+    assert "admin console" in response.text
+
+    mapping_file = tmp_path / "certs.json"
+    mapping_file.write_text(json.dumps({"mappings": [
+        {"subject_cn": "support", "subject_id": admin_user_id},
+        {"subject_cn": "web", "subject_id": "someone"},
+    ]}))
+    interceptor = client_cert_interceptor(CertificateMapper(str(mapping_file)))
+    add_interceptor(interceptor)
+    try:
+        request = {"jsonrpc": "2.0", "method": "get_tenant", "params": {"id": test_tenant["id"]}, "id": 1}
+        # X-User-ID alone does not make an administrator
+        response = await async_client.post("/admin/rpc", json=request, headers={"X-User-ID": admin_user_id})
+        assert response.json()["error"]["code"] == -32003
+
+        web = {"X-Forwarded-Client-Cert": 'Hash=aa;Subject="CN=web"'}
+        response = await async_client.post("/admin/rpc", json=request, headers=web)
+        assert response.json()["error"]["code"] == -32003
+
+        support = {"X-Forwarded-Client-Cert": 'Hash=bb;Subject="CN=support"'}
+        response = await async_client.post("/admin/rpc", json=request, headers=support)
+        assert response.json()["result"]["tenant"]["slug"] == test_tenant["slug"]
+
+        request = {"jsonrpc": "2.0", "method": "delete_tenant", "params": {"id": test_tenant["id"]}, "id": 2}
+        response = await async_client.post("/admin/rpc", json=request, headers=support)
+        assert response.json()["error"]["code"] == -32003
+    finally:
+        remove_interceptor(interceptor)
+
+
 @pytest.mark.asyncio
 async def test_update_tenant_via_api(
     async_client: AsyncClient,
@@ -230,7 +290,9 @@ async def test_delete_tenant_via_api(
     data = response.json()
     assert "result" in data
     
-    # Verify tenant is deleted
+    assert data["result"]["tenant"]["status"] == "pending_deletion"
+
+    # Verify tenant is pending deletion (restorable until delete_after)
     request = {
         "jsonrpc": "2.0",
         "method": "get_tenant",
@@ -241,7 +303,8 @@ async def test_delete_tenant_via_api(
     response = await async_client.post("/jsonrpc", json=request)
     assert response.status_code == 200
     data = response.json()
-    assert "error" in data  # Should return error for deleted tenant
+    assert data["result"]["tenant"]["status"] == "pending_deletion"
+    assert data["result"]["tenant"]["delete_after"]
 
 
 @pytest.mark.asyncio
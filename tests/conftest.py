@@ -20,6 +20,22 @@ from app.repository import (
     NodeTypeRepository,
     NodeRepository,
     RelationshipRepository,
+    RelationshipTypeRepository,
+    WriteHookRepository,
+    AuthzPolicyRepository,
+    AttachmentRepository,
+    ImpersonationRepository,
+    AuditRepository,
+    OperationRepository,
+    DatabaseStatsRepository,
+    DataMigrationRepository,
+    TenantTemplateRepository,
+    DirectoryRepository,
+    TombstoneRepository,
+    TenantKeyRepository,
+    NodeAliasRepository,
+    NodeProtectionRepository,
+    UniqueConstraintRepository,
 )
 from app.service import (
     TenantService,
@@ -27,13 +43,36 @@ from app.service import (
     NodeTypeService,
     NodeService,
     RelationshipService,
+    RelationshipTypeService,
+    WriteHookService,
+    AuthzPolicyService,
+    AttachmentService,
+    AuditService,
+    ImpersonationService,
+    OperationService,
+    BulkService,
+    ExpansionService,
+    StatsService,
+    DataMigrationService,
+    RelationshipImportService,
+    NodeImportService,
+    TemplateService,
+    DirectoryService,
+    ExportService,
+    TenantKeyService,
+    NodeAliasService,
+    UniqueConstraintService,
+    SubgraphService,
+    GraphViewService,
 )
+from app.storage import AttachmentSettings, MemoryObjectStore
 from main import create_app
 
 
 # Test database configuration
 TEST_CONTROL_DB_NAME = "dbaas_control_test"
 TEST_TENANT_DB_PREFIX = "dbaas_tenant_test_"
+TEST_ADMIN_USER_ID = "00000000-0000-0000-0000-00000000ad01"
 
 
 @pytest.fixture(scope="session")
@@ -90,6 +129,16 @@ async def clean_control_db(test_config: Config) -> AsyncGenerator[Database, None
         await conn.execute("SET session_replication_role = 'replica';")
         
         # Delete all data (in reverse order of dependencies)
+        await conn.execute("DELETE FROM authz_policies")
+        await conn.execute("DELETE FROM tenant_templates")
+        await conn.execute("DELETE FROM tenant_keys")
+        await conn.execute("DELETE FROM audit_events")
+        await conn.execute("DELETE FROM billing_events")
+        await conn.execute("DELETE FROM export_runs")
+        await conn.execute("DELETE FROM export_schedules")
+        await conn.execute("DELETE FROM destination_credentials")
+        await conn.execute("DELETE FROM api_keys")
+        await conn.execute("DELETE FROM impersonation_tokens")
         await conn.execute("DELETE FROM tenant_users")
         await conn.execute("DELETE FROM tenant_migrations")
         await conn.execute("DELETE FROM tenant_databases")
@@ -124,9 +173,36 @@ async def user_repo(clean_control_db: Database) -> UserRepository:
 
 
 @pytest.fixture
-async def tenant_service(tenant_repo: TenantRepository, tenant_db_manager: TenantDatabaseManager) -> TenantService:
+async def template_service(clean_control_db: Database, tenant_db_manager: TenantDatabaseManager) -> TemplateService:
+    """Create tenant template service."""
+    from app.api.dependencies import create_tenant_services
+
+    async def tenant_services(tenant_id: str):
+        return create_tenant_services(await tenant_db_manager.get_tenant_db(tenant_id), tenant_id)
+
+    return TemplateService(TenantTemplateRepository(clean_control_db), tenant_services)
+
+
+@pytest.fixture
+async def tenant_service(
+    tenant_repo: TenantRepository, tenant_db_manager: TenantDatabaseManager, template_service: TemplateService
+) -> TenantService:
     """Create tenant service."""
-    return TenantService(tenant_repo, tenant_db_manager)
+    return TenantService(tenant_repo, tenant_db_manager, template_service=template_service)
+
+
+@pytest.fixture
+async def tenant_key_service(clean_control_db: Database, tenant_db_manager: TenantDatabaseManager) -> TenantKeyService:
+    """Create tenant key service (without waiting for other servers to load keys)."""
+    return TenantKeyService(TenantKeyRepository(clean_control_db), tenant_db_manager, cache_seconds=0)
+
+
+@pytest.fixture
+async def stats_service(
+    clean_control_db: Database, tenant_repo: TenantRepository, tenant_db_manager: TenantDatabaseManager
+) -> StatsService:
+    """Create stats service."""
+    return StatsService(DatabaseStatsRepository(clean_control_db), tenant_repo, tenant_db_manager)
 
 
 @pytest.fixture
@@ -135,6 +211,44 @@ async def user_service(user_repo: UserRepository) -> UserService:
     return UserService(user_repo)
 
 
+@pytest.fixture
+async def authz_policy_repo(clean_control_db: Database) -> AuthzPolicyRepository:
+    """Create authorization policy repository."""
+    return AuthzPolicyRepository(clean_control_db)
+
+
+@pytest.fixture
+async def authz_policy_service(authz_policy_repo: AuthzPolicyRepository) -> AuthzPolicyService:
+    """Create authorization policy service."""
+    return AuthzPolicyService(authz_policy_repo)
+
+
+@pytest.fixture
+async def audit_service(clean_control_db: Database) -> AuditService:
+    """Create audit log service."""
+    return AuditService(AuditRepository(clean_control_db))
+
+
+@pytest.fixture
+def admin_user_id() -> str:
+    """ID of the administrator configured for impersonation tests."""
+    return TEST_ADMIN_USER_ID
+
+
+@pytest.fixture
+async def impersonation_service(
+    clean_control_db: Database,
+    tenant_repo: TenantRepository,
+    user_repo: UserRepository,
+    audit_service: AuditService
+) -> ImpersonationService:
+    """Create impersonation service with a single test administrator."""
+    return ImpersonationService(
+        ImpersonationRepository(clean_control_db), tenant_repo, user_repo, audit_service,
+        admin_user_ids=[TEST_ADMIN_USER_ID]
+    )
+
+
 @pytest.fixture
 async def tenant_db(
     test_config: Config,
@@ -158,6 +272,12 @@ async def tenant_db(
     async with tenant_db.pool.acquire() as conn:
         await conn.execute("SET session_replication_role = 'replica';")
         await conn.execute("DELETE FROM relationships")
+        await conn.execute("DELETE FROM relationship_types")
+        await conn.execute("DELETE FROM write_hooks")
+        await conn.execute("DELETE FROM attachments")
+        await conn.execute("DELETE FROM graph_stats")
+        await conn.execute("DELETE FROM operations")
+        await conn.execute("DELETE FROM node_versions")
         await conn.execute("DELETE FROM nodes")
         await conn.execute("DELETE FROM node_types")
         await conn.execute("DELETE FROM schema_migrations")  # Clean migrations table too
@@ -187,6 +307,12 @@ async def relationship_repo(tenant_db: Database) -> RelationshipRepository:
     return RelationshipRepository(tenant_db)
 
 
+@pytest.fixture
+async def relationship_type_repo(tenant_db: Database) -> RelationshipTypeRepository:
+    """Create relationship type repository for tenant database."""
+    return RelationshipTypeRepository(tenant_db)
+
+
 @pytest.fixture
 async def nodetype_service(nodetype_repo: NodeTypeRepository) -> NodeTypeService:
     """Create node type service."""
@@ -194,15 +320,166 @@ async def nodetype_service(nodetype_repo: NodeTypeRepository) -> NodeTypeService
 
 
 @pytest.fixture
-async def node_service(node_repo: NodeRepository, nodetype_repo: NodeTypeRepository) -> NodeService:
+async def write_hook_service(tenant_db: Database, nodetype_repo: NodeTypeRepository) -> WriteHookService:
+    """Create write hook service."""
+    return WriteHookService(WriteHookRepository(tenant_db), nodetype_repo)
+
+
+@pytest.fixture
+async def directory_service(tenant_db: Database) -> DirectoryService:
+    """Create tenant directory service."""
+    return DirectoryService(DirectoryRepository(tenant_db))
+
+
+@pytest.fixture
+async def node_alias_service(tenant_db: Database, node_service: NodeService) -> NodeAliasService:
+    """Create node alias service."""
+    return NodeAliasService(NodeAliasRepository(tenant_db), node_service)
+
+
+@pytest.fixture
+async def unique_constraint_service(
+    tenant_db: Database, nodetype_repo: NodeTypeRepository, node_repo: NodeRepository
+) -> UniqueConstraintService:
+    """Create unique constraint service."""
+    return UniqueConstraintService(UniqueConstraintRepository(tenant_db), nodetype_repo, node_repo)
+
+
+@pytest.fixture
+def object_store() -> MemoryObjectStore:
+    """Create in-memory object store for attachments."""
+    return MemoryObjectStore()
+
+
+@pytest.fixture
+async def attachment_service(
+    tenant_db: Database,
+    node_repo: NodeRepository,
+    object_store: MemoryObjectStore
+) -> AttachmentService:
+    """Create attachment service backed by the in-memory object store."""
+    return AttachmentService(
+        AttachmentRepository(tenant_db), node_repo, "test-tenant", object_store,
+        AttachmentSettings(max_bytes=1024)
+    )
+
+
+@pytest.fixture
+async def data_migration_service(
+    tenant_db: Database,
+    nodetype_repo: NodeTypeRepository,
+    node_repo: NodeRepository,
+) -> DataMigrationService:
+    """Create data migration service."""
+    return DataMigrationService(
+        DataMigrationRepository(tenant_db), nodetype_repo, node_repo, OperationService(OperationRepository(tenant_db))
+    )
+
+
+@pytest.fixture
+async def node_service(
+    node_repo: NodeRepository,
+    nodetype_repo: NodeTypeRepository,
+    write_hook_service: WriteHookService,
+    attachment_service: AttachmentService,
+    relationship_repo: RelationshipRepository,
+    relationship_type_repo: RelationshipTypeRepository,
+    data_migration_service: DataMigrationService,
+    tenant_db: Database,
+) -> NodeService:
     """Create node service."""
-    return NodeService(node_repo, nodetype_repo)
+    return NodeService(
+        node_repo, nodetype_repo, write_hook_service, attachment_service,
+        relationship_repo, relationship_type_repo, data_migrations=data_migration_service,
+        protection_repo=NodeProtectionRepository(tenant_db),
+    )
+
+
+@pytest.fixture
+async def bulk_service(
+    tenant_db: Database,
+    node_repo: NodeRepository,
+    node_service: NodeService,
+    relationship_repo: RelationshipRepository,
+) -> BulkService:
+    """Create bulk operation service."""
+    return BulkService(node_repo, node_service, OperationService(OperationRepository(tenant_db)), relationship_repo)
+
+
+@pytest.fixture
+async def export_service(
+    tenant_db: Database, node_repo: NodeRepository, relationship_repo: RelationshipRepository
+) -> ExportService:
+    """Create export service."""
+    return ExportService(node_repo, relationship_repo, TombstoneRepository(tenant_db))
 
 
 @pytest.fixture
-async def relationship_service(relationship_repo: RelationshipRepository, node_repo: NodeRepository) -> RelationshipService:
+async def subgraph_service(node_repo: NodeRepository, relationship_repo: RelationshipRepository) -> SubgraphService:
+    """Create subgraph service."""
+    return SubgraphService(node_repo, relationship_repo)
+
+
+@pytest.fixture
+async def graph_view_service(
+    subgraph_service: SubgraphService, node_repo: NodeRepository, nodetype_repo: NodeTypeRepository
+) -> GraphViewService:
+    """Create graph view service."""
+    return GraphViewService(subgraph_service, node_repo, nodetype_repo)
+
+
+@pytest.fixture
+async def expansion_service(
+    node_repo: NodeRepository,
+    relationship_repo: RelationshipRepository,
+    relationship_type_repo: RelationshipTypeRepository,
+) -> ExpansionService:
+    """Create expansion service."""
+    return ExpansionService(node_repo, relationship_repo, rel_type_repo=relationship_type_repo)
+
+
+@pytest.fixture
+async def relationship_service(
+    relationship_repo: RelationshipRepository,
+    node_repo: NodeRepository,
+    relationship_type_repo: RelationshipTypeRepository
+) -> RelationshipService:
     """Create relationship service."""
-    return RelationshipService(relationship_repo, node_repo)
+    return RelationshipService(relationship_repo, node_repo, relationship_type_repo)
+
+
+@pytest.fixture
+async def node_import_service(
+    tenant_db: Database,
+    node_service: NodeService,
+    nodetype_repo: NodeTypeRepository,
+) -> NodeImportService:
+    """Create CSV node import service."""
+    return NodeImportService(node_service, nodetype_repo, OperationService(OperationRepository(tenant_db)))
+
+
+@pytest.fixture
+async def relationship_import_service(
+    tenant_db: Database,
+    relationship_repo: RelationshipRepository,
+    node_repo: NodeRepository,
+    relationship_type_repo: RelationshipTypeRepository,
+) -> RelationshipImportService:
+    """Create relationship import service."""
+    return RelationshipImportService(
+        relationship_repo, node_repo, relationship_type_repo, OperationService(OperationRepository(tenant_db)),
+        alias_repo=NodeAliasRepository(tenant_db),
+    )
+
+
+@pytest.fixture
+async def relationship_type_service(
+    relationship_type_repo: RelationshipTypeRepository,
+    nodetype_repo: NodeTypeRepository,
+    relationship_repo: RelationshipRepository,
+) -> RelationshipTypeService:
+    """Create relationship type service."""
+    return RelationshipTypeService(relationship_type_repo, nodetype_repo, relationship_repo=relationship_repo)
 
 
 @pytest.fixture
@@ -271,10 +548,14 @@ async def async_client(
     from app.api.dependencies import set_tenant_db_manager
     from app.jsonrpc.handlers import register_methods
     from app.jsonrpc.server import router as jsonrpc_router
+    from app.api.routers.contracts import router as contracts_router
+    from app.api.routers.imports import router as imports_router
+    from app.api.routers.admin import configure_admin_console, router as admin_router
     
     # Initialize app dependencies before creating app
     set_tenant_db_manager(tenant_db_manager)
     register_methods(tenant_service, user_service)
+    configure_admin_console(lambda subject_id: subject_id == TEST_ADMIN_USER_ID)
     
     # Create minimal app for testing (without lifespan to avoid database setup)
     app = FastAPI(
@@ -283,6 +564,9 @@ async def async_client(
         version="1.0.0",
     )
     app.include_router(jsonrpc_router)
+    app.include_router(contracts_router)
+    app.include_router(imports_router)
+    app.include_router(admin_router)
     
     @app.get("/health")
     async def health_check():
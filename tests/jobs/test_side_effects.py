@@ -0,0 +1,60 @@
+"""
+Tests for SideEffectQueue.
+"""
+
+import asyncio
+
+import pytest
+
+from app.db.tracing import capturing, start_capture, stop_capture
+from app.jobs import SideEffectQueue
+
+
+@pytest.mark.asyncio
+async def test_side_effects_outlive_cancelled_callers():
+    """Test that queued work runs detached from its caller, even when the caller is cancelled."""
+    queue = SideEffectQueue(max_pending=10, workers=1)
+    queue.start()
+    done = []
+
+    async def effect():
+        done.append(capturing())
+
+    async def call():
+        token = start_capture()
+        try:
+            await asyncio.sleep(10)
+        finally:
+            queue.submit("audit", effect)
+            stop_capture(token)
+
+    task = asyncio.get_running_loop().create_task(call())
+    await asyncio.sleep(0)
+    task.cancel()
+    with pytest.raises(asyncio.CancelledError):
+        await task
+    await queue.stop()
+    # The caller's capture did not carry over to the work
+    assert done == [False]
+
+
+@pytest.mark.asyncio
+async def test_side_effect_queue_bounds_and_deadline():
+    """Test that work past the queue size is dropped and slow work is abandoned at its deadline."""
+    queue = SideEffectQueue(max_pending=1, workers=1, deadline_seconds=0.05)
+    queue.start()
+    finished = []
+
+    async def slow():
+        await asyncio.sleep(1)
+        finished.append("slow")
+
+    async def fast():
+        finished.append("fast")
+
+    assert queue.submit("slow", slow)
+    await asyncio.sleep(0)
+    assert queue.submit("fast", fast)
+    assert not queue.submit("dropped", fast)
+    await queue.stop(grace_seconds=1)
+    assert finished == ["fast"]
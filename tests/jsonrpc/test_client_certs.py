@@ -0,0 +1,71 @@
+"""
+Tests for client certificate identity mapping.
+"""
+
+import json
+
+import pytest
+from jsonrpcserver import Success
+
+from app.authz.client_certs import CertificateMapper, client_cert_interceptor, parse_client_cert_header
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall, result_error_code
+
+
+def test_parse_client_cert_header():
+    """Test that the SPIFFE ID and CN of the nearest hop's certificate are extracted."""
+    header = (
+        'Hash=aa;URI=spiffe://acme/ns/web/sa/front,'
+        'Hash=bb;Subject="CN=billing,O=Acme, Inc.";URI=spiffe://acme/ns/billing/sa/api'
+    )
+    assert parse_client_cert_header(header) == {
+        "spiffe_id": "spiffe://acme/ns/billing/sa/api",
+        "subject_cn": "billing",
+    }
+    assert parse_client_cert_header('Hash=cc;Subject="CN=reporting"') == {"subject_cn": "reporting"}
+    assert parse_client_cert_header("Hash=dd") == {}
+
+
+@pytest.mark.asyncio
+async def test_client_cert_interceptor(tmp_path):
+    """Test that mapped certificates run as their caller and are limited to their tenants."""
+    mapping_file = tmp_path / "certs.json"
+    mapping_file.write_text(json.dumps({"mappings": [
+        {"spiffe_id": "spiffe://acme/ns/billing/*", "subject_id": "svc-billing", "tenant_ids": ["t1"], "role": "admin",
+         "priority": "batch"},
+    ]}))
+    interceptor = client_cert_interceptor(CertificateMapper(str(mapping_file)))
+    seen = []
+
+    async def call_next(call):
+        ctx = call.context
+        seen.append((ctx.subject_id, ctx.attributes.get("client_certificate"), ctx.admin_subject_id()))
+        return Success({})
+
+    def call(tenant_id, uri):
+        headers = {"x-user-id": "spoofed", "x-forwarded-client-cert": f"Hash=aa;URI={uri}"}
+        return RpcCall("list_nodes", {"tenant_id": tenant_id}, RequestContext.from_headers(headers))
+
+    result = await interceptor(call("t1", "spiffe://acme/ns/billing/sa/api"), call_next)
+    assert result_error_code(result) is None
+    assert seen[-1][0] == "svc-billing"
+    assert seen[-1][1]["role"] == "admin"
+    assert seen[-1][1]["priority"] == "batch"
+    assert seen[-1][2] == "svc-billing"
+
+    result = await interceptor(call("t2", "spiffe://acme/ns/billing/sa/api"), call_next)
+    assert result_error_code(result) == -32003
+
+    result = await interceptor(call("t1", "spiffe://acme/ns/web/sa/front"), call_next)
+    assert result_error_code(result) == -32003
+
+    # Tenant-limited certificates cannot call methods that target no tenant
+    result = await interceptor(call("", "spiffe://acme/ns/billing/sa/api"), call_next)
+    assert result_error_code(result) == -32003
+    assert len(seen) == 1
+
+    no_cert = RpcCall("list_nodes", {"tenant_id": "t2"}, RequestContext.from_headers({"x-user-id": "u1"}))
+    assert result_error_code(await interceptor(no_cert, call_next)) is None
+    assert seen[-1][0] == "u1"
+    # X-User-ID alone never identifies an administrator
+    assert seen[-1][2] == ""
@@ -0,0 +1,52 @@
+"""
+Tests for consistency tokens on write results.
+"""
+
+import pytest
+from jsonrpcserver import Error, Success
+
+from app.jsonrpc.consistency import consistency_interceptor
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall
+from app.service.consistency import covers
+
+
+def test_covers():
+    """Test that a position covers the tokens of writes before it."""
+    assert covers("101", "100")
+    assert covers("100", "100")
+    assert not covers("99", "100")
+    with pytest.raises(ValueError):
+        covers("100", "abc")
+
+
+@pytest.mark.asyncio
+async def test_consistency_interceptor():
+    """Test that successful tenant writes get a consistency token and other calls do not."""
+    positions = []
+
+    async def commit_position(tenant_id):
+        positions.append(tenant_id)
+        return "42"
+
+    interceptor = consistency_interceptor(commit_position)
+    context = RequestContext()
+
+    async def succeed(call):
+        return Success({"id": "n1"})
+
+    result = await interceptor(RpcCall("create_node", {"tenant_id": "t1"}, context), succeed)
+    assert result._value.result == {"id": "n1", "consistency_token": "42"}
+    assert positions == ["t1"]
+
+    result = await interceptor(RpcCall("get_node", {"tenant_id": "t1"}, context), succeed)
+    assert result._value.result == {"id": "n1"}
+    result = await interceptor(RpcCall("create_user", {}, context), succeed)
+    assert result._value.result == {"id": "n1"}
+
+    async def fail(call):
+        return Error(-32602, "invalid")
+
+    result = await interceptor(RpcCall("create_node", {"tenant_id": "t1"}, context), fail)
+    assert result._value.code == -32602
+    assert positions == ["t1"]
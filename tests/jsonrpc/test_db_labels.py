@@ -0,0 +1,65 @@
+"""
+Tests for database connection labels.
+"""
+
+import pytest
+from jsonrpcserver import Success
+
+from app.db.labels import connection_label, pool_options, reset_label, set_label
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.db_labels import db_label_interceptor
+from app.jsonrpc.interceptors import RpcCall
+
+TENANT_ID = "6f1c2a7e-3d4b-4f0a-9a51-2b8e7c9d0e13"
+
+
+class FakeSettings:
+    def __init__(self, application_name):
+        self.application_name = application_name
+
+
+class FakeConnection:
+    def __init__(self, application_name):
+        self.application_name = application_name
+        self.queries = 0
+
+    def get_settings(self):
+        return FakeSettings(self.application_name)
+
+    async def execute(self, query, label):
+        self.queries += 1
+        self.application_name = label
+
+
+@pytest.mark.asyncio
+async def test_db_label_interceptor():
+    """Test that connections acquired during a call are labeled with its tenant and method."""
+    setup = pool_options("flexdb")["setup"]
+    conn = FakeConnection("flexdb")
+    labels = []
+
+    async def acquire(call):
+        await setup(conn)
+        labels.append(conn.application_name)
+        return Success({})
+
+    interceptor = db_label_interceptor()
+    await interceptor(RpcCall("list_nodes", {"tenant_id": TENANT_ID}, RequestContext()), acquire)
+    await interceptor(RpcCall("list_nodes", {"tenant_id": TENANT_ID}, RequestContext()), acquire)
+    await interceptor(RpcCall("list_tenants", {}, RequestContext()), acquire)
+
+    assert labels == [f"flexdb:{TENANT_ID}:list_nodes", f"flexdb:{TENANT_ID}:list_nodes", "flexdb:list_tenants"]
+    # An unchanged label is not set again
+    assert conn.queries == 2
+    assert connection_label("flexdb") == "flexdb"
+
+
+def test_label_length():
+    """Test that labels fit application_name and that an empty name disables labels."""
+    token = set_label(TENANT_ID, "list_relationship_types_for_a_very_long_method")
+    try:
+        assert len(connection_label("flexdb")) == 63
+        assert connection_label("flexdb").startswith(f"flexdb:{TENANT_ID}:")
+    finally:
+        reset_label(token)
+    assert pool_options("") == {}
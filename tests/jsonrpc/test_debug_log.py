@@ -0,0 +1,90 @@
+"""
+Tests for per-tenant debug logging.
+"""
+
+import asyncio
+import json
+import logging
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+
+import pytest
+from jsonrpcserver import Error, Success
+
+from app.db.tracing import _log_query, debug_logger
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.debug_log import debug_log_interceptor
+from app.jsonrpc.interceptors import RpcCall
+from app.repository import NotFoundError, Tenant
+from app.service.tenant_debug import TenantDebugCache
+
+
+class _FakeTenantRepository:
+    def __init__(self, scopes, until):
+        self.tenant = Tenant(id="t1", debug_scopes=scopes, debug_until=until)
+
+    async def get_by_id(self, id):
+        if id != "t1":
+            raise NotFoundError(f"tenant not found: {id}")
+        return self.tenant
+
+
+class _Records(logging.Handler):
+    def __init__(self):
+        super().__init__()
+        self.entries = []
+
+    def emit(self, record):
+        self.entries.append(json.loads(record.getMessage()))
+
+
+@pytest.fixture
+def debug_records():
+    handler = _Records()
+    debug_logger.addHandler(handler)
+    debug_logger.setLevel(logging.INFO)
+    yield handler.entries
+    debug_logger.removeHandler(handler)
+
+
+@pytest.mark.asyncio
+async def test_debug_cache_expires_scopes():
+    """Test that scopes apply until their deadline and unknown tenants have none."""
+    soon = datetime.now(timezone.utc) + timedelta(minutes=5)
+    cache = TenantDebugCache(_FakeTenantRepository(["requests"], soon))
+    assert await cache.scopes("t1") == ("requests",)
+    assert await cache.scopes("t2") == ()
+
+    cache = TenantDebugCache(_FakeTenantRepository(["requests"], datetime.now(timezone.utc) - timedelta(seconds=1)))
+    assert await cache.scopes("t1") == ()
+
+
+@pytest.mark.asyncio
+async def test_debug_log_interceptor(debug_records):
+    """Test that calls to a debugged tenant are logged with their params, results and statements."""
+    soon = datetime.now(timezone.utc) + timedelta(minutes=5)
+    interceptor = debug_log_interceptor(TenantDebugCache(_FakeTenantRepository(["requests", "queries"], soon)))
+    query = SimpleNamespace(query="SELECT *\n  FROM nodes WHERE id = $1", args=("n1",), elapsed=0.0012, exception=None)
+
+    async def call_next(call):
+        # asyncpg calls query loggers soon after the statement, in its context
+        asyncio.get_running_loop().call_soon(_log_query, query)
+        await asyncio.sleep(0)
+        if call.method == "delete_node":
+            return Error(-32001, "node not found")
+        return Success({"node": {"id": "n1"}})
+
+    context = RequestContext(request_id="r1")
+    await interceptor(RpcCall("get_node", {"tenant_id": "t1", "id": "n1"}, context), call_next)
+    await interceptor(RpcCall("delete_node", {"tenant_id": "t1", "id": "n2"}, context), call_next)
+    await interceptor(RpcCall("get_node", {"tenant_id": "t2", "id": "n1"}, context), call_next)
+
+    statements = [e for e in debug_records if "query" in e]
+    calls = [e for e in debug_records if "method" in e]
+    assert len(statements) == 2
+    assert statements[0]["query"] == "SELECT * FROM nodes WHERE id = $1"
+    assert statements[0]["args"] == '["n1"]'
+    assert [c["method"] for c in calls] == ["get_node", "delete_node"]
+    assert json.loads(calls[0]["params"])["id"] == "n1"
+    assert json.loads(calls[0]["result"]) == {"node": {"id": "n1"}}
+    assert json.loads(calls[1]["error"])["code"] == -32001
@@ -0,0 +1,65 @@
+"""
+Tests for opaque external IDs.
+"""
+
+import base64
+import uuid
+
+import pytest
+from jsonrpcserver import Success
+
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.external_ids import AesIdCodec, external_id_interceptor, translate_ids
+from app.jsonrpc.interceptors import RpcCall
+
+KEY = base64.b64encode(bytes(range(32))).decode()
+
+
+def test_aes_codec_round_trip():
+    """Test that external IDs decode to the UUIDs they encode and look nothing alike."""
+    codec = AesIdCodec(KEY)
+    first, second = uuid.UUID(int=1), uuid.UUID(int=2)
+    external = codec.encode(first)
+    assert len(external) == 26 and external.islower()
+    assert codec.decode(external) == first
+    assert codec.encode(second)[:8] != external[:8]
+    assert codec.decode(str(first)) is None
+    assert codec.decode("!" * 26) is None
+    with pytest.raises(ValueError):
+        AesIdCodec(base64.b64encode(b"short").decode())
+
+
+def test_translate_ids_skips_documents():
+    """Test that IDs are encoded under ID keys only, and never inside tenant documents."""
+    codec = AesIdCodec(KEY)
+    node_id, type_id = str(uuid.uuid4()), str(uuid.uuid4())
+    result = {
+        "node": {"id": node_id, "node_type_id": type_id, "data_object": {"owner_id": node_id}, "name": node_id},
+        "deleted_node_ids": [node_id, "client-key"],
+    }
+    encoded = translate_ids(result, codec, encode=True)
+    assert encoded["node"]["id"] == codec.encode(uuid.UUID(node_id))
+    assert encoded["node"]["node_type_id"] == codec.encode(uuid.UUID(type_id))
+    assert encoded["node"]["data_object"] == {"owner_id": node_id}
+    assert encoded["node"]["name"] == node_id
+    assert encoded["deleted_node_ids"] == [encoded["node"]["id"], "client-key"]
+    assert translate_ids(encoded, codec, encode=False) == result
+
+
+@pytest.mark.asyncio
+async def test_external_id_interceptor():
+    """Test that methods receive internal IDs and callers receive external ones."""
+    codec = AesIdCodec(KEY)
+    interceptor = external_id_interceptor(codec)
+    node_id = str(uuid.uuid4())
+    received = {}
+
+    async def call_next(call):
+        received.update(call.params)
+        return Success({"node": {"id": call.params["id"]}})
+
+    # Internal UUIDs are still accepted
+    for given in [codec.encode(uuid.UUID(node_id)), node_id]:
+        result = await interceptor(RpcCall("get_node", {"id": given}, RequestContext()), call_next)
+        assert received["id"] == node_id
+        assert result._value.result == {"node": {"id": codec.encode(uuid.UUID(node_id))}}
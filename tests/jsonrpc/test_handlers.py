@@ -202,3 +202,40 @@ async def test_jsonrpc_method_not_found(async_client: AsyncClient):
     assert "error" in data
     assert data["error"]["code"] == -32601  # Method not found
 
+
+
+def test_data_param_accepts_object_and_string():
+    """Test that entity data may be sent as an object or a JSON string."""
+    from app.jsonrpc.handlers import _data_param
+
+    assert json.loads(_data_param({"title": "Hello"})) == {"title": "Hello"}
+    assert _data_param('{"title": "Hello"}') == '{"title": "Hello"}'
+    assert _data_param("") == ""
+    with pytest.raises(ValueError, match="data must be valid JSON"):
+        _data_param("{not json")
+
+
+def test_apply_read_mask():
+    """Test that read masks project entities and reject unknown fields."""
+    from app.jsonrpc.handlers import _apply_read_mask, _mask_needs_data
+
+    entity = {"id": "n1", "node_type_id": "t1", "data": "{}", "data_object": {}}
+    assert _apply_read_mask(entity, None) == entity
+    assert _apply_read_mask(entity, ["node_type_id"]) == {"id": "n1", "node_type_id": "t1"}
+    assert not _mask_needs_data(["id", "node_type_id"])
+    assert _mask_needs_data(["data_object"])
+    with pytest.raises(ValueError, match="unknown fields in read mask: payload"):
+        _apply_read_mask(entity, ["payload"])
+
+
+def test_field_violations():
+    """Test that validation errors name their offending fields."""
+    from app.jsonrpc.handlers import _field_violations
+    from app.repository import FieldViolationError
+
+    assert _field_violations(ValueError("limit must be between 1 and 1000")) == [
+        {"field": "limit", "description": "limit must be between 1 and 1000"}
+    ]
+    assert _field_violations(ValueError("delete would cascade to more than 100 nodes")) == []
+    error = FieldViolationError("bad aliases", [("aliases.a", "too long"), ("aliases.b", "empty")])
+    assert [v["field"] for v in _field_violations(error)] == ["aliases.a", "aliases.b"]
@@ -0,0 +1,46 @@
+"""
+Tests for JSON document shape limits.
+"""
+
+import json
+
+import pytest
+from jsonrpcserver import Success
+
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall, result_error_code
+from app.jsonrpc.json_limits import JsonLimits, check_document, json_limits_interceptor
+
+LIMITS = JsonLimits(max_depth=3, max_keys=4, max_string_length=5)
+
+
+def test_check_document():
+    """Test that depth, key count and string lengths are each limited."""
+    assert check_document({"a": {"b": [1, "abc"]}}, LIMITS) is None
+    assert "deeper" in check_document({"a": {"b": [[1]]}}, LIMITS)
+    assert "keys" in check_document({"a": 1, "b": 2, "c": {"d": 3, "e": 4}}, LIMITS)
+    assert "string" in check_document({"a": "abcdef"}, LIMITS)
+    assert "string" in check_document({"abcdef": 1}, LIMITS)
+    assert check_document({"a": {"b": [[1]]}}, JsonLimits(max_depth=0)) is None
+
+
+@pytest.mark.asyncio
+async def test_json_limits_interceptor():
+    """Test that calls with documents past the limits are rejected with the offending parameter."""
+    interceptor = json_limits_interceptor(LIMITS)
+
+    async def call_next(call):
+        return Success({})
+
+    context = RequestContext()
+    ok = RpcCall("create_node", {"data": '{"a": 1}', "node_type_id": "x" * 100}, context)
+    assert result_error_code(await interceptor(ok, call_next)) is None
+
+    deep = json.dumps({"a": {"b": {"c": {}}}})
+    batch = RpcCall("batch_create_nodes", {"nodes": [{"data": "{}"}, {"data": deep}]}, context)
+    result = await interceptor(batch, call_next)
+    assert result_error_code(result) == -32602
+    assert result._value.data["field_violations"][0]["field"] == "nodes[1].data"
+
+    # Data that is not JSON is left for the method to report
+    assert result_error_code(await interceptor(RpcCall("create_node", {"data": "{"}, context), call_next)) is None
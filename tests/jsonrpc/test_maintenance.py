@@ -0,0 +1,58 @@
+"""
+Tests for read-only tenant maintenance mode.
+"""
+
+from datetime import datetime
+
+import pytest
+from jsonrpcserver import Success
+
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall, result_error_code
+from app.jsonrpc.maintenance import is_write_method, maintenance_interceptor
+from app.repository import Tenant
+from app.service import TenantMaintenanceCache
+
+
+class _FakeTenantRepository:
+    def __init__(self):
+        self.tenant = Tenant(id="t1", maintenance_started_at=datetime.now(), maintenance_reason="restore")
+
+    async def get_by_id(self, id):
+        return self.tenant
+
+
+def test_is_write_method():
+    """Test that reads and tenant administration are allowed during maintenance."""
+    assert is_write_method("create_node")
+    assert is_write_method("push_changes")
+    assert is_write_method("sync_tenant_schemas")
+    assert not is_write_method("list_nodes")
+    assert not is_write_method("export_tenant_changes")
+    assert not is_write_method("search_nodes")
+    assert not is_write_method("run_saved_query")
+    assert not is_write_method("begin_read_session")
+    assert is_write_method("begin_node_import")
+    assert not is_write_method("set_tenant_maintenance")
+    assert not is_write_method("update_tenant")
+
+
+@pytest.mark.asyncio
+async def test_maintenance_rejects_writes():
+    """Test that writes to a tenant in maintenance fail with the reason until it ends."""
+    repo = _FakeTenantRepository()
+    cache = TenantMaintenanceCache(repo)
+    interceptor = maintenance_interceptor(cache)
+
+    async def call_next(call):
+        return Success({})
+
+    context = RequestContext()
+    result = await interceptor(RpcCall("create_node", {"tenant_id": "t1"}, context), call_next)
+    assert result_error_code(result) == -32004
+    assert "restore" in result._value.message
+    assert result_error_code(await interceptor(RpcCall("get_node", {"tenant_id": "t1"}, context), call_next)) is None
+
+    repo.tenant.maintenance_started_at = None
+    cache.invalidate("t1")
+    assert result_error_code(await interceptor(RpcCall("create_node", {"tenant_id": "t1"}, context), call_next)) is None
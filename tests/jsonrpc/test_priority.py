@@ -0,0 +1,106 @@
+"""
+Tests for call priority classes.
+"""
+
+import asyncio
+
+import pytest
+from jsonrpcserver import Success
+
+from app.db.priority import BATCH, INTERACTIVE, PrioritizedPool, current_priority, reset_priority, set_priority
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall, result_error_code
+from app.jsonrpc.priority import priority_interceptor
+
+
+class FakePool:
+    def __init__(self):
+        self.held = 0
+
+    async def acquire(self, timeout=None):
+        self.held += 1
+        return object()
+
+    async def release(self, conn, timeout=None):
+        self.held -= 1
+
+    def get_max_size(self):
+        return 4
+
+
+@pytest.mark.asyncio
+async def test_priority_interceptor():
+    """Test that calls run at the lowest of the requested and the caller's priority."""
+    interceptor = priority_interceptor()
+    seen = []
+
+    async def call_next(call):
+        seen.append(current_priority())
+        return Success({})
+
+    async def call(headers, certificate=None):
+        context = RequestContext.from_headers(headers)
+        if certificate is not None:
+            context.attributes["client_certificate"] = certificate
+        return await interceptor(RpcCall("list_nodes", {}, context), call_next)
+
+    await call({})
+    await call({"X-Priority": "batch"})
+    await call({"X-Priority": "interactive"}, {"priority": "batch"})
+    await call({}, {"priority": ""})
+    assert seen == [INTERACTIVE, BATCH, BATCH, INTERACTIVE]
+    assert current_priority() == INTERACTIVE
+
+    assert result_error_code(await call({"X-Priority": "urgent"})) == -32602
+
+
+@pytest.mark.asyncio
+async def test_batch_calls_limited():
+    """Test that batch calls beyond the limit wait while interactive calls do not."""
+    interceptor = priority_interceptor(max_concurrent_batch=1)
+    release = asyncio.Event()
+    running = []
+
+    async def call_next(call):
+        running.append(call.method)
+        if call.method == "slow":
+            await release.wait()
+        return Success({})
+
+    def call(method, priority):
+        return interceptor(RpcCall(method, {}, RequestContext.from_headers({"X-Priority": priority})), call_next)
+
+    slow = asyncio.ensure_future(call("slow", "batch"))
+    queued = asyncio.ensure_future(call("queued", "batch"))
+    await asyncio.sleep(0)
+    await call("interactive", "interactive")
+    assert running == ["slow", "interactive"]
+
+    release.set()
+    await asyncio.gather(slow, queued)
+    assert running[-1] == "queued"
+
+
+@pytest.mark.asyncio
+async def test_prioritized_pool_reserves_connections_for_interactive_work():
+    """Test that batch work holds at most its share of connections and releases it with them."""
+    pool = PrioritizedPool(FakePool(), max_batch=2)
+    token = set_priority(BATCH)
+    try:
+        first = await pool.acquire()
+        async with pool.acquire():
+            waiting = asyncio.ensure_future(pool.acquire())
+            await asyncio.sleep(0)
+            assert not waiting.done()
+            reset_priority(token)
+            token = None
+            async with pool.acquire():
+                assert pool.held == 3
+        third = await waiting
+        await pool.release(first)
+        await pool.release(third)
+    finally:
+        if token:
+            reset_priority(token)
+    assert pool.held == 0
+    assert pool.get_max_size() == 4
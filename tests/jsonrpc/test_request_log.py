@@ -0,0 +1,52 @@
+"""
+Tests for the sampled and slow request log.
+"""
+
+import json
+import logging
+
+import pytest
+from jsonrpcserver import Success
+
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall
+from app.jsonrpc.request_log import request_log_interceptor
+from app.repository.retry import with_retry
+
+
+class _FakeRepository:
+    @with_retry(idempotent=True)
+    async def list(self):
+        return ["a", "b", "c"], None
+
+
+@pytest.mark.asyncio
+async def test_slow_calls_logged_with_repository_timings(caplog):
+    """Test that slow calls are logged with per-operation repository timings."""
+    repo = _FakeRepository()
+
+    async def call_next(call):
+        await repo.list()
+        await repo.list()
+        return Success({})
+
+    call = RpcCall("list_nodes", {"tenant_id": "t1"}, RequestContext(request_id="r1"))
+    caplog.set_level(logging.INFO, logger="flexdb.requests")
+
+    await request_log_interceptor(sample_rate=0, slow_threshold_ms=0)(call, call_next)
+    assert not caplog.records
+
+    await request_log_interceptor(sample_rate=0, slow_threshold_ms=0.0001)(call, call_next)
+    entry = json.loads(caplog.records[-1].getMessage())
+    assert caplog.records[-1].levelno == logging.WARNING
+    assert entry["reason"] == "slow"
+    assert entry["tenant_id"] == "t1"
+    assert entry["method"] == "list_nodes"
+    assert entry["rows"] == 6
+    assert entry["repository"]["_FakeRepository.list"]["calls"] == 2
+
+
+def test_request_log_rejects_invalid_sample_rate():
+    """Test that sample rates outside [0, 1] are rejected."""
+    with pytest.raises(ValueError, match="sample_rate"):
+        request_log_interceptor(sample_rate=2, slow_threshold_ms=0)
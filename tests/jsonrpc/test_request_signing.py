@@ -0,0 +1,90 @@
+"""
+Tests for HMAC-signed requests.
+"""
+
+import json
+
+import pytest
+from jsonrpcserver import Success
+
+from app.authz.request_signing import RequestSignatureVerifier, request_signature_interceptor, sign_request
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall, result_error_code
+from app.repository.errors import PermissionDeniedError
+
+SECRET = "s" * 32
+
+
+class MemoryNonces:
+    """Nonces claimed in memory, like the request_nonces table."""
+
+    def __init__(self):
+        self.claimed = set()
+
+    async def claim(self, key_id, nonce, expires_at):
+        if (key_id, nonce) in self.claimed:
+            return False
+        self.claimed.add((key_id, nonce))
+        return True
+
+
+def _signed(body: bytes, nonce: str = "n1", timestamp: str = "1700000000", secret: str = SECRET) -> RequestContext:
+    return RequestContext.from_headers({
+        "X-Signature-Key": "billing",
+        "X-Signature-Timestamp": timestamp,
+        "X-Signature-Nonce": nonce,
+        "X-Signature": sign_request(secret, timestamp, nonce, "/jsonrpc", body),
+        "X-User-ID": "spoofed",
+    })
+
+
+@pytest.fixture
+def verifier(tmp_path):
+    keys_file = tmp_path / "keys.json"
+    keys_file.write_text(json.dumps({"keys": [
+        {"key_id": "billing", "secret": SECRET, "subject_id": "svc-billing", "tenant_ids": ["t1"], "scope": "read"},
+    ]}))
+    return RequestSignatureVerifier(str(keys_file), MemoryNonces(), tolerance_seconds=300)
+
+
+@pytest.mark.asyncio
+async def test_verify_signed_request(verifier):
+    """Test that valid signatures run as the key's caller and stale, forged or replayed ones are rejected."""
+    body = b'{"jsonrpc": "2.0", "method": "list_nodes", "id": 1}'
+    ctx = _signed(body)
+    await verifier.verify(ctx, "/jsonrpc", body, now=1700000100)
+    assert ctx.subject_id == "svc-billing"
+    assert ctx.attributes["request_signature"]["scope"] == "read"
+
+    with pytest.raises(PermissionDeniedError, match="already been used"):
+        await verifier.verify(_signed(body), "/jsonrpc", body, now=1700000100)
+    with pytest.raises(PermissionDeniedError, match="invalid request signature"):
+        await verifier.verify(_signed(body, "n2"), "/jsonrpc", body + b" ", now=1700000100)
+    with pytest.raises(PermissionDeniedError, match="invalid request signature"):
+        await verifier.verify(_signed(body, "n3", secret="x" * 32), "/jsonrpc", body, now=1700000100)
+    with pytest.raises(PermissionDeniedError, match="server's clock"):
+        await verifier.verify(_signed(body, "n4"), "/jsonrpc", body, now=1700000301)
+
+    unsigned = RequestContext.from_headers({"X-User-ID": "u1"})
+    await verifier.verify(unsigned, "/jsonrpc", body)
+    assert unsigned.subject_id == "u1"
+
+
+@pytest.mark.asyncio
+async def test_request_signature_interceptor():
+    """Test that signed requests are limited to their key's tenants and scope."""
+    interceptor = request_signature_interceptor()
+
+    async def call_next(call):
+        return Success(None)
+
+    def call(method, tenant_id):
+        ctx = RequestContext()
+        ctx.attributes["request_signature"] = {"key_id": "billing", "tenant_ids": ["t1"], "scope": "read"}
+        return RpcCall(method=method, params={"tenant_id": tenant_id}, context=ctx)
+
+    assert result_error_code(await interceptor(call("list_nodes", "t1"), call_next)) is None
+    assert result_error_code(await interceptor(call("create_node", "t1"), call_next)) == -32003
+    assert result_error_code(await interceptor(call("list_nodes", "t2"), call_next)) == -32003
+    assert result_error_code(await interceptor(call("list_tenants", ""), call_next)) == -32003
+    assert result_error_code(await interceptor(call("rpc_discover", ""), call_next)) is None
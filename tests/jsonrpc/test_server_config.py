@@ -0,0 +1,50 @@
+"""
+Tests for the server listener configuration (TLS, keepalive, message sizes).
+"""
+
+import ssl
+
+import pytest
+
+from app.config import ServerConfig
+
+
+def test_server_config_validation(tmp_path):
+    """Test that inconsistent TLS and size settings are rejected."""
+    cert = tmp_path / "server.pem"
+    cert.write_text("cert")
+
+    ServerConfig().validate()
+
+    with pytest.raises(ValueError, match="must be set together"):
+        ServerConfig(tls_cert_file=str(cert)).validate()
+    with pytest.raises(ValueError, match="requires SERVER_TLS_CERT_FILE"):
+        ServerConfig(tls_client_ca_file=str(cert)).validate()
+    with pytest.raises(ValueError, match="not a readable file"):
+        ServerConfig(tls_cert_file=str(cert), tls_key_file=str(tmp_path / "missing.pem")).validate()
+    with pytest.raises(ValueError, match="SERVER_TLS_CLIENT_AUTH"):
+        ServerConfig(tls_client_auth="maybe").validate()
+    with pytest.raises(ValueError, match="SERVER_MAX_RECEIVE_BYTES"):
+        ServerConfig(max_receive_bytes=10).validate()
+    with pytest.raises(ValueError, match="SERVER_KEEPALIVE_TIMEOUT_SECONDS"):
+        ServerConfig(keepalive_timeout_seconds=0).validate()
+
+
+def test_server_config_uvicorn_options(tmp_path):
+    """Test that TLS and keepalive settings map to uvicorn options."""
+    assert ServerConfig(port=8443).uvicorn_options() == {"host": "0.0.0.0", "port": 8443, "timeout_keep_alive": 5.0}
+
+    for name in ("cert.pem", "key.pem", "ca.pem"):
+        (tmp_path / name).write_text(name)
+    cfg = ServerConfig(
+        tls_cert_file=str(tmp_path / "cert.pem"),
+        tls_key_file=str(tmp_path / "key.pem"),
+        tls_client_ca_file=str(tmp_path / "ca.pem"),
+        tls_client_auth="optional",
+    )
+    cfg.validate()
+    options = cfg.uvicorn_options()
+    assert cfg.tls_enabled
+    assert options["ssl_certfile"] == str(tmp_path / "cert.pem")
+    assert options["ssl_ca_certs"] == str(tmp_path / "ca.pem")
+    assert options["ssl_cert_reqs"] == ssl.CERT_OPTIONAL
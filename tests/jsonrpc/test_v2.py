@@ -0,0 +1,52 @@
+"""
+Tests for the JSON-RPC v2 translation shims.
+"""
+
+import pytest
+
+from app.jsonrpc.v2 import V2ParamsError, parse_field_mask, translate_request, translate_result
+
+
+def test_parse_field_mask():
+    """Test that field masks are accepted as lists or comma-separated strings."""
+    assert parse_field_mask("id, data.address.city") == [["id"], ["data", "address", "city"]]
+    assert parse_field_mask(["id", "data"]) == [["id"], ["data"]]
+    with pytest.raises(V2ParamsError):
+        parse_field_mask("data..city")
+    with pytest.raises(V2ParamsError):
+        parse_field_mask(5)
+
+
+def test_translate_request_rejects_v1_params():
+    """Test that v1-only parameter forms are rejected in v2."""
+    request = {"jsonrpc": "2.0", "method": "get_node", "id": 1}
+    with pytest.raises(V2ParamsError, match="field_mask"):
+        translate_request({**request, "params": {"fields": ["id"]}})
+    with pytest.raises(V2ParamsError, match="JSON object"):
+        translate_request({**request, "method": "create_node", "params": {"data": '{"a": 1}'}})
+    with pytest.raises(V2ParamsError, match="not supported"):
+        translate_request({**request, "method": "no_such_method", "params": {"field_mask": "id"}})
+
+    translated, data_paths = translate_request({**request, "method": "create_node", "params": {"data": {"a": 1}}})
+    assert translated["params"] == {"data": {"a": 1}}
+    assert data_paths is None
+
+
+def test_translate_result():
+    """Test that entities carry their data object as data, pruned to the masked paths."""
+    node = {
+        "id": "n1",
+        "data": '{"name": "Ada", "address": {"city": "London", "street": "Baker"}}',
+        "data_object": {"name": "Ada", "address": {"city": "London", "street": "Baker"}},
+        "metadata": {"data_object": "kept"},
+    }
+    result = translate_result({"nodes": [node], "pagination": {"next_page_token": ""}})
+    assert result["nodes"][0]["data"] == node["data_object"]
+    assert "data_object" not in result["nodes"][0]
+    assert result["nodes"][0]["metadata"] == {"data_object": "kept"}
+
+    masked = translate_result({"node": node}, [["address", "city"], ["missing"]])
+    assert masked["node"]["data"] == {"address": {"city": "London"}}
+
+    # Results without entities are unchanged
+    assert translate_result({"data": "text", "count": 1}) == {"data": "text", "count": 1}
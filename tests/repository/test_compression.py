@@ -0,0 +1,88 @@
+"""
+Tests for transparent data compression.
+"""
+
+import json
+import logging
+
+import pytest
+
+from app.metrics import metrics
+from app.repository import compression
+from app.repository.compression import check_data_query, configure_compression, encode_data
+from app.repository.models import Node
+
+
+@pytest.fixture
+def small_threshold():
+    """Compress documents of 1KB and above for the duration of a test."""
+    configure_compression(1024)
+    yield
+    configure_compression(0)
+
+
+def _large_document() -> str:
+    return json.dumps({"body": "lorem ipsum " * 500})
+
+
+def test_encode_below_threshold_is_not_compressed(small_threshold):
+    """Test that small documents are stored as plain JSONB."""
+    data = '{"title": "small"}'
+    value, blob = encode_data(data, "node")
+
+    assert value == data
+    assert blob is None
+
+
+def test_data_queries_warn_once_that_compressed_documents_are_skipped(small_threshold, monkeypatch, caplog):
+    """Test that data path queries log a single warning while compression is on."""
+    monkeypatch.setattr(compression, "_warned_compressed_queries", False)
+
+    with caplog.at_level(logging.WARNING, logger=compression.logger.name):
+        check_data_query("filtering on data", None)
+        check_data_query("ordering by data paths", None)
+
+    warnings = [r for r in caplog.records if "DATA_COMPRESSION_THRESHOLD" in r.getMessage()]
+    assert len(warnings) == 1
+
+
+def test_encode_above_threshold_is_compressed(small_threshold):
+    """Test that large documents are compressed and replaced with a placeholder."""
+    metrics.reset()
+    data = _large_document()
+    value, blob = encode_data(data, "node")
+
+    assert value == "{}"
+    assert blob is not None
+    assert len(blob) < len(data)
+    assert metrics.summary("data_compression_ratio", {"entity": "node"}).count == 1
+
+
+def test_node_decompresses_lazily(small_threshold):
+    """Test that a node loaded with compressed data decompresses on access."""
+    data = _large_document()
+    _, blob = encode_data(data, "node")
+
+    node = Node(id="n1", data="{}", compressed_data=blob)
+    assert "data" not in node.__dict__
+
+    assert node.data == data
+    assert node.compressed_data is None
+
+
+@pytest.mark.asyncio
+async def test_node_repo_roundtrip_compressed(node_repo, nodetype_repo, small_threshold):
+    """Test creating and reading back a compressed node."""
+    from app.repository.models import NodeType
+
+    node_type = await nodetype_repo.create(NodeType(name="Document"))
+    data = _large_document()
+
+    created = await node_repo.create(Node(node_type_id=node_type.id, data=data))
+    retrieved = await node_repo.get_by_id(created.id)
+
+    assert retrieved.data == data
+
+    async with node_repo.db.pool.acquire() as conn:
+        stored = await conn.fetchval("SELECT data::text FROM nodes WHERE id = $1", created.id)
+    assert stored == "{}"
@@ -0,0 +1,100 @@
+"""
+Tests for dual writes to a second backend.
+"""
+
+import json
+
+import pytest
+
+from app.repository import (
+    MemoryNodeRepository,
+    MemoryNodeTypeRepository,
+    MemoryRelationshipRepository,
+    MemoryStore,
+    Node,
+    NodeType,
+    NotFoundError,
+    Relationship,
+)
+from app.repository.dual_write import (
+    DualWriteNodeRepository,
+    DualWriteNodeTypeRepository,
+    DualWriteRelationshipRepository,
+    sync,
+)
+
+
+class Backend:
+    """Memory repositories of one backend."""
+
+    def __init__(self):
+        store = MemoryStore()
+        self.node_types = MemoryNodeTypeRepository(store)
+        self.nodes = MemoryNodeRepository(store)
+        self.relationships = MemoryRelationshipRepository(store)
+
+
+@pytest.fixture
+def backends():
+    primary, secondary = Backend(), Backend()
+    dual = (
+        DualWriteNodeTypeRepository(primary.node_types, secondary.node_types, "t1"),
+        DualWriteNodeRepository(primary.nodes, secondary.nodes, "t1"),
+        DualWriteRelationshipRepository(primary.relationships, secondary.relationships, "t1"),
+    )
+    return primary, secondary, dual
+
+
+@pytest.mark.asyncio
+async def test_writes_are_mirrored_with_the_primary_ids(backends):
+    """Test that creates, updates and deletes reach the secondary under the same IDs."""
+    primary, secondary, (node_types, nodes, relationships) = backends
+    person = await node_types.create(NodeType(name="Person"))
+    alice = await nodes.create(Node(node_type_id=person.id, data='{"name": "alice"}'))
+    bob = await nodes.create(Node(node_type_id=person.id, data='{"name": "bob"}'))
+    await relationships.create_many([Relationship(source_node_id=alice.id, target_node_id=bob.id, relationship_type="knows")])
+
+    alice.data = '{"name": "alice", "age": 30}'
+    await nodes.update(alice)
+    await nodes.increment_field(alice.id, ["age"], 1)
+    await nodes.delete(bob.id)
+
+    assert (await secondary.node_types.get_by_id(person.id)).name == "Person"
+    assert json.loads((await secondary.nodes.get_by_id(alice.id)).data) == {"name": "alice", "age": 31}
+    with pytest.raises(NotFoundError):
+        await secondary.nodes.get_by_id(bob.id)
+    assert await secondary.relationships.list_from_sources([alice.id]) == []
+
+
+@pytest.mark.asyncio
+async def test_failed_mirrors_do_not_fail_writes(backends):
+    """Test that the primary's result is returned when the secondary rejects a write, and reads use the primary."""
+    primary, secondary, (node_types, nodes, _) = backends
+    # Created before dual writes started, so the secondary lacks it
+    person = await primary.node_types.create(NodeType(name="Person"))
+
+    alice = await nodes.create(Node(node_type_id=person.id, data='{"name": "alice"}'))
+
+    assert (await nodes.get_by_id(alice.id)).id == alice.id
+    with pytest.raises(NotFoundError):
+        await secondary.nodes.get_by_id(alice.id)
+
+
+@pytest.mark.asyncio
+async def test_sync_repairs_missing_changed_and_extra_entities(backends):
+    """Test that sync brings the secondary's entities to the primary's state."""
+    primary, secondary, _ = backends
+    person = await primary.node_types.create(NodeType(name="Person"))
+    alice = await primary.nodes.create(Node(node_type_id=person.id, data='{"name": "alice"}'))
+    assert await sync(primary.node_types, secondary.node_types, "node_types", [person.id]) == []
+    await secondary.nodes.create(Node(id=alice.id, node_type_id=person.id, data='{"name": "old"}'))
+    extra = await secondary.nodes.create(Node(node_type_id=person.id))
+    bob = await primary.nodes.create(Node(node_type_id=person.id, data='{"name": "bob"}'))
+
+    failed = await sync(primary.nodes, secondary.nodes, "nodes", [alice.id, extra.id, bob.id])
+
+    assert failed == []
+    assert json.loads((await secondary.nodes.get_by_id(alice.id)).data) == {"name": "alice"}
+    assert json.loads((await secondary.nodes.get_by_id(bob.id)).data) == {"name": "bob"}
+    with pytest.raises(NotFoundError):
+        await secondary.nodes.get_by_id(extra.id)
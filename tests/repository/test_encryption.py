@@ -0,0 +1,83 @@
+"""
+Tests for per-tenant data encryption.
+"""
+
+import base64
+import json
+import os
+import uuid
+
+import pytest
+
+from app.repository.compression import check_data_query, configure_compression, decode_data, encode_data
+from app.repository.encryption import (
+    configure_encryption,
+    is_encrypted,
+    new_wrapped_key,
+    open_secret,
+    seal_secret,
+    unwrap_key,
+)
+
+
+@pytest.fixture
+def master_key():
+    """Configure a random master key for the duration of a test."""
+    configure_encryption(base64.b64encode(os.urandom(32)).decode())
+    yield
+    configure_encryption("")
+
+
+def test_encode_encrypted_roundtrip(master_key):
+    """Test that documents are encrypted with the data key, compressed first when large."""
+    tenant_id = str(uuid.uuid4())
+    key = unwrap_key(str(uuid.uuid4()), tenant_id, new_wrapped_key(tenant_id))
+
+    value, blob = encode_data('{"secret": "x"}', "node", key)
+    assert value == "{}"
+    assert blob.startswith(key.prefix)
+    assert b"secret" not in blob
+    assert decode_data(blob) == '{"secret": "x"}'
+
+    configure_compression(1024)
+    try:
+        data = json.dumps({"body": "lorem ipsum " * 500})
+        _, blob = encode_data(data, "node", key)
+        assert is_encrypted(blob) and len(blob) < len(data)
+        assert decode_data(blob) == data
+    finally:
+        configure_compression(0)
+
+
+def test_data_queries_refused_for_encrypted_tenants(master_key):
+    """Test that data path queries fail rather than silently skip encrypted documents."""
+    tenant_id = str(uuid.uuid4())
+    key = unwrap_key(str(uuid.uuid4()), tenant_id, new_wrapped_key(tenant_id))
+
+    with pytest.raises(ValueError, match="encrypted"):
+        check_data_query("filtering on data", key)
+    check_data_query("filtering on data", None)
+
+
+def test_wrapped_key_is_bound_to_tenant(master_key):
+    """Test that a wrapped data key cannot be unwrapped for another tenant."""
+    wrapped = new_wrapped_key(str(uuid.uuid4()))
+    with pytest.raises(Exception):
+        unwrap_key(str(uuid.uuid4()), str(uuid.uuid4()), wrapped)
+
+
+def test_sealed_secret_is_bound_to_context(master_key):
+    """Test that a sealed secret opens only with the context it was sealed with."""
+    sealed = seal_secret(b'{"password": "hunter2"}', "destination_credential:a:sftp")
+    assert b"hunter2" not in sealed
+    assert open_secret(sealed, "destination_credential:a:sftp") == b'{"password": "hunter2"}'
+    with pytest.raises(Exception):
+        open_secret(sealed, "destination_credential:b:sftp")
+
+
+def test_master_key_must_be_32_bytes():
+    """Test master key validation."""
+    with pytest.raises(ValueError, match="32 bytes"):
+        configure_encryption(base64.b64encode(b"short").decode())
+    with pytest.raises(ValueError, match="ENCRYPTION_MASTER_KEY"):
+        seal_secret(b"{}", "destination_credential:a:sftp")
@@ -0,0 +1,137 @@
+"""
+Tests for the in-memory repositories.
+"""
+
+import asyncio
+import json
+import threading
+from datetime import datetime, timezone
+
+import pytest
+
+from app.repository import (
+    AlreadyExistsError,
+    ListOptions,
+    MemoryNodeRepository,
+    MemoryNodeTypeRepository,
+    MemoryRelationshipRepository,
+    MemoryRelationshipTypeRepository,
+    MemoryStore,
+    NodeFilter,
+    NotFoundError,
+    PreconditionFailedError,
+)
+from app.service import NodeService, NodeTypeService, RelationshipService
+
+
+@pytest.fixture
+def memory_services():
+    store = MemoryStore()
+    node_repo = MemoryNodeRepository(store)
+    nodetype_repo = MemoryNodeTypeRepository(store)
+    relationship_repo = MemoryRelationshipRepository(store)
+    rel_type_repo = MemoryRelationshipTypeRepository(store)
+    return (
+        NodeTypeService(nodetype_repo),
+        NodeService(node_repo, nodetype_repo, relationship_repo=relationship_repo, rel_type_repo=rel_type_repo),
+        RelationshipService(relationship_repo, node_repo, rel_type_repo),
+        node_repo,
+    )
+
+
+@pytest.mark.asyncio
+async def test_memory_services_crud(memory_services):
+    """Test that the services work on the memory repositories, including optimistic concurrency and cascades."""
+    nodetype_service, node_service, relationship_service, _ = memory_services
+    article = await nodetype_service.create("Article", "", '{}')
+    a = await node_service.create(article.id, '{"title": "A"}')
+    b = await node_service.create(article.id, '{"title": "B"}')
+    rel = await relationship_service.create(a.id, b.id, "cites", '{}')
+
+    updated = await node_service.update(a.id, '{"title": "A2"}', if_match=a.etag)
+    assert updated.etag != a.etag
+    with pytest.raises(PreconditionFailedError):
+        await node_service.update(a.id, '{"title": "A3"}', if_match=a.etag)
+
+    # Deleting a node deletes its relationships; its ID cannot be reused
+    await node_service.delete(b.id)
+    with pytest.raises(NotFoundError):
+        await relationship_service.get_by_id(rel.id)
+    with pytest.raises(AlreadyExistsError):
+        await node_service.create(article.id, '{}', id=b.id)
+
+
+@pytest.mark.asyncio
+async def test_memory_node_list_filters_and_pages(memory_services):
+    """Test that listing filters, orders and pages like the PostgreSQL repository."""
+    nodetype_service, node_service, _, node_repo = memory_services
+    task = await nodetype_service.create("Task", "", '{}')
+    for priority in [3, 1, 2, 1, None]:
+        data = '{}' if priority is None else f'{{"priority": {priority}, "tags": ["x"]}}'
+        await node_service.create(task.id, data)
+
+    seen = []
+    token = ""
+    while True:
+        nodes, result = await node_repo.list(
+            task.id, ListOptions(page_size=2, page_token=token, order_by="data.priority desc")
+        )
+        assert result.total_count == 5
+        seen += nodes
+        token = result.next_page_token
+        if not token:
+            break
+    assert [json.loads(n.data).get("priority") for n in seen] == [3, 2, 1, 1, None]
+    assert len({n.id for n in seen}) == 5
+
+    assert await node_repo.count_matching(NodeFilter(data_contains={"priority": 1})) == 2
+    assert await node_repo.count_matching(NodeFilter(data_contains={"tags": ["x"]})) == 4
+    with pytest.raises(ValueError):
+        await node_repo.list(task.id, ListOptions(order_by="password"))
+
+
+@pytest.mark.asyncio
+async def test_memory_store_concurrent_writes(memory_services):
+    """Test that writes from several threads are neither lost nor given the same etag."""
+    nodetype_service, node_service, _, node_repo = memory_services
+    counter = await nodetype_service.create("Counter", "", '{}')
+    node = await node_service.create(counter.id, '{}')
+
+    def increment():
+        for _ in range(50):
+            asyncio.run(node_repo.increment_field(node.id, ["count"], 1))
+
+    threads = [threading.Thread(target=increment) for _ in range(4)]
+    for thread in threads:
+        thread.start()
+    for thread in threads:
+        thread.join()
+
+    stored = await node_repo.get_by_id(node.id)
+    assert json.loads(stored.data)["count"] == 200
+
+
+@pytest.mark.asyncio
+async def test_memory_date_histogram_uses_time_zone(memory_services):
+    """Test that daily counts follow the requested time zone's days, not UTC days."""
+    nodetype_service, node_service, _, node_repo = memory_services
+    event = await nodetype_service.create("Event", "", '{}')
+    for hour in [1, 9, 23]:
+        node = await node_service.create(event.id, '{}')
+        node_repo.store.nodes[node.id].created_at = datetime(2026, 3, 10, hour, tzinfo=timezone.utc)
+
+    utc = await node_service.date_histogram("created_at", event.id, "day", "UTC")
+    assert [(b.value, b.count) for b in utc] == [("2026-03-10", 3)]
+
+    # 23:00 UTC is already the next day in Tokyo (UTC+9); 01:00 UTC is the previous day in New York
+    tokyo = await node_service.date_histogram("created_at", event.id, "day", "Asia/Tokyo")
+    assert [(b.value, b.count) for b in tokyo] == [("2026-03-10", 2), ("2026-03-11", 1)]
+    new_york = await node_service.date_histogram(
+        "created_at", event.id, "day", "America/New_York", start="2026-03-10"
+    )
+    assert [(b.value, b.count) for b in new_york] == [("2026-03-10", 2)]
+
+    with pytest.raises(ValueError):
+        await node_service.date_histogram("created_at", event.id, "day", "Mars/Olympus")
+    with pytest.raises(ValueError):
+        await node_service.date_histogram("data.when", event.id, "day", "UTC")
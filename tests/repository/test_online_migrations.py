@@ -0,0 +1,82 @@
+"""
+Tests for online migration planning.
+"""
+
+from app.db.online_migrations import plan_migration, split_statements
+
+
+def test_split_statements_keeps_quoted_text():
+    """Test that semicolons in strings and function bodies do not split statements."""
+    sql = """
+        -- comment; not a statement
+        INSERT INTO t VALUES ('a;b');
+        CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql;
+    """
+    statements = split_statements(sql)
+
+    assert statements[0] == "INSERT INTO t VALUES ('a;b')"
+    assert statements[1].startswith("CREATE FUNCTION") and statements[1].endswith("LANGUAGE plpgsql")
+    assert len(statements) == 2
+
+
+def test_index_on_existing_table_is_built_concurrently():
+    """Test that indexes of existing tables are built concurrently, and of new tables as written."""
+    plan = plan_migration("001", """
+        CREATE TABLE IF NOT EXISTS notes (id UUID PRIMARY KEY, node_id UUID);
+        CREATE INDEX IF NOT EXISTS idx_notes_node ON notes(node_id);
+        CREATE INDEX IF NOT EXISTS idx_nodes_updated ON nodes(updated_at);
+    """)
+
+    assert not plan.blocked
+    assert [f.rewritten for f in plan.findings] == [True]
+    assert plan.steps[1].transactional
+    assert plan.steps[2].sql == "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_nodes_updated ON nodes(updated_at)"
+    assert not plan.steps[2].transactional and plan.steps[2].invalid_index == "idx_nodes_updated"
+
+
+def test_volatile_default_is_backfilled_in_batches():
+    """Test that a NOT NULL column with a volatile default is added, backfilled and then constrained."""
+    plan = plan_migration(
+        "002", "ALTER TABLE nodes ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();",
+        batch_size=500,
+    )
+
+    assert not plan.blocked
+    sqls = [s.sql for s in plan.steps]
+    assert sqls[0] == "ALTER TABLE nodes ADD COLUMN IF NOT EXISTS public_id UUID"
+    assert sqls[1] == "ALTER TABLE nodes ALTER COLUMN public_id SET DEFAULT gen_random_uuid()"
+    assert plan.steps[2].batched and "LIMIT 500" in sqls[2]
+    assert "ADD CONSTRAINT nodes_public_id_not_null CHECK (public_id IS NOT NULL) NOT VALID" in sqls[4]
+    assert sqls[5] == "ALTER TABLE nodes VALIDATE CONSTRAINT nodes_public_id_not_null"
+    assert sqls[6] == "ALTER TABLE nodes ALTER COLUMN public_id SET NOT NULL"
+
+
+def test_constant_default_needs_no_rewrite():
+    """Test that constant defaults (stored in the catalog) are left as written."""
+    plan = plan_migration("003", "ALTER TABLE nodes ADD COLUMN IF NOT EXISTS flag TEXT NOT NULL DEFAULT 'x';")
+
+    assert not plan.findings
+    assert len(plan.steps) == 1
+
+
+def test_constraints_are_validated_separately():
+    """Test that named check constraints are added NOT VALID and then validated."""
+    plan = plan_migration("004", "ALTER TABLE nodes ADD CONSTRAINT nodes_x CHECK (x > 0), ADD COLUMN y TEXT;")
+
+    sqls = [s.sql for s in plan.steps]
+    assert sqls == [
+        "ALTER TABLE nodes DROP CONSTRAINT IF EXISTS nodes_x",
+        "ALTER TABLE nodes ADD CONSTRAINT nodes_x CHECK (x > 0) NOT VALID",
+        "ALTER TABLE nodes VALIDATE CONSTRAINT nodes_x",
+        "ALTER TABLE nodes ADD COLUMN y TEXT",
+    ]
+
+
+def test_statements_without_online_equivalent_block():
+    """Test that type changes block a migration unless it allows locking."""
+    sql = "ALTER TABLE nodes ALTER COLUMN data TYPE TEXT;"
+
+    assert plan_migration("005", sql).blocked
+    assert not plan_migration("005", "-- migration: allow-locking\n" + sql).blocked
+    assert plan_migration("006", "ALTER TABLE nodes ADD COLUMN owner TEXT NOT NULL;").blocked
+    assert plan_migration("007", "UPDATE nodes SET data = '{}';").blocked
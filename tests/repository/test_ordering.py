@@ -0,0 +1,57 @@
+"""
+Tests for multi-field order_by parsing and list ordering.
+"""
+
+import pytest
+
+from app.repository.models import Node, NodeType, ListOptions
+from app.repository.ordering import build_order_by, parse_order_by
+
+COLUMNS = ("created_at", "updated_at")
+
+
+def test_build_order_by_default():
+    """Test that an empty specification falls back to the default order."""
+    assert build_order_by("", COLUMNS) == " ORDER BY created_at DESC, id"
+
+
+def test_build_order_by_multiple_fields():
+    """Test data paths and columns combined in one specification."""
+    clause = build_order_by("data.priority desc, created_at asc", COLUMNS, json_column="data")
+    assert clause == (
+        " ORDER BY data #> '{priority}' DESC NULLS LAST, created_at ASC NULLS FIRST, id"
+    )
+
+
+def test_parse_order_by_nested_path():
+    """Test nested JSON paths render as literal path arrays."""
+    fields = parse_order_by("data.address.city", COLUMNS, json_column="data")
+    assert fields[0].expression == "data #> '{address,city}'"
+    assert fields[0].descending is False
+
+
+@pytest.mark.parametrize("spec", [
+    "password desc",
+    "created_at sideways",
+    "data.prio'rity",
+    "created_at desc extra",
+    "data.priority",  # json paths not enabled
+])
+def test_parse_order_by_rejects_invalid(spec):
+    """Test that unknown fields, directions, and unsafe paths are rejected."""
+    with pytest.raises(ValueError):
+        parse_order_by(spec, COLUMNS)
+
+
+@pytest.mark.asyncio
+async def test_node_list_orders_by_data_path(node_repo, nodetype_repo):
+    """Test listing nodes ordered by a JSON data path then created_at."""
+    node_type = await nodetype_repo.create(NodeType(name="Task"))
+    for priority in (2, 3, 1):
+        await node_repo.create(Node(node_type_id=node_type.id, data=f'{{"priority": {priority}}}'))
+
+    nodes, _ = await node_repo.list(
+        node_type.id, ListOptions(page_size=10, order_by="data.priority desc, created_at asc")
+    )
+
+    assert [n.data for n in nodes] == ['{"priority": 3}', '{"priority": 2}', '{"priority": 1}']
@@ -0,0 +1,33 @@
+"""
+Tests for snapshot read sessions.
+"""
+
+import pytest
+
+from app.db.read_sessions import ReadSessionManager
+from app.repository import ListOptions, Node, NodeRepository
+from app.repository.errors import NotFoundError
+
+
+@pytest.mark.asyncio
+async def test_read_session_sees_snapshot(tenant_db, node_repo, test_node):
+    """Test that reads in a session do not see writes made after it began."""
+    manager = ReadSessionManager(ttl_seconds=60, max_sessions=2)
+    session = await manager.begin("tenant-a", tenant_db)
+    try:
+        await node_repo.create(Node(node_type_id=test_node["node_type_id"], data='{"late": true}'))
+
+        snapshot_repo = NodeRepository(manager.get(session.token, "tenant-a").database())
+        nodes, _ = await snapshot_repo.list(None, ListOptions())
+        assert [n.id for n in nodes] == [test_node["id"]]
+
+        _, live = await node_repo.list(None, ListOptions())
+        assert live.total_count == 2
+
+        with pytest.raises(NotFoundError):
+            manager.get(session.token, "tenant-b")
+    finally:
+        await manager.end(session.token, "tenant-a")
+
+    with pytest.raises(NotFoundError):
+        manager.get(session.token, "tenant-a")
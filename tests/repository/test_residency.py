@@ -0,0 +1,70 @@
+"""
+Tests for data residency routing.
+"""
+
+import pytest
+
+from app.db.residency import RegionRouter, check_mapping, check_marker, parse_regions
+from app.repository import DataResidencyError
+
+
+class FakeConn:
+    """Answers the server region setting and the marker upsert."""
+
+    def __init__(self, declared=None, marked=None):
+        self.declared = declared
+        self.marked = marked
+
+    async def fetchval(self, query, *args):
+        if "current_setting" in query:
+            return self.declared
+        self.marked = self.marked or args[0]
+        return self.marked
+
+
+def test_parse_regions():
+    """Test parsing region=host[:port] pairs."""
+    assert parse_regions("eu=pg-eu:6432, us=pg-us") == {"eu": ("pg-eu", 6432), "us": ("pg-us", 5432)}
+    assert parse_regions("") == {}
+    with pytest.raises(ValueError, match="invalid DB_REGIONS entry"):
+        parse_regions("eu")
+    with pytest.raises(ValueError, match="twice"):
+        parse_regions("eu=a,eu=b")
+
+
+def test_router_maps_regions_to_servers():
+    """Test that regions route to their servers and DB_HOST serves the default region."""
+    router = RegionRouter("pg-main", 5432, {"us": ("pg-us", 5432)}, default_region="eu")
+
+    assert router.regions == ("eu", "us")
+    assert router.check_region("") == "eu"
+    assert router.server("eu") == ("pg-main", 5432)
+    assert router.server("us") == ("pg-us", 5432)
+    assert router.server("") == ("pg-main", 5432)
+    with pytest.raises(ValueError, match="configured regions"):
+        router.check_region("ap")
+    with pytest.raises(DataResidencyError):
+        router.server("ap")
+
+
+def test_mapping_of_another_region_is_refused():
+    """Test that a database recorded for another region is refused."""
+    check_mapping("t1", "eu", "eu")
+    with pytest.raises(DataResidencyError) as exc_info:
+        check_mapping("t1", "eu", "us")
+    assert (exc_info.value.tenant_region, exc_info.value.database_region) == ("eu", "us")
+
+
+@pytest.mark.asyncio
+async def test_marker_is_written_once_and_checked():
+    """Test that the first use marks a database, and markers or servers of other regions are refused."""
+    conn = FakeConn()
+    await check_marker(conn, "t1", "eu")
+    assert conn.marked == "eu"
+
+    with pytest.raises(DataResidencyError, match="marked for region eu"):
+        await check_marker(conn, "t1", "us")
+    with pytest.raises(DataResidencyError, match="server is in region us"):
+        await check_marker(FakeConn(declared="us"), "t1", "eu")
+    # Tenants without a region are not checked
+    await check_marker(FakeConn(declared="us", marked="eu"), "t1", "")
@@ -0,0 +1,58 @@
+"""
+Tests for retries of transient database errors.
+"""
+
+import asyncpg
+import pytest
+
+from app.repository.retry import RetryPolicy, call_with_retry, transient_kind
+
+NO_DELAY = RetryPolicy(max_attempts=3, base_delay_seconds=0, max_delay_seconds=0)
+
+
+def _failing(errors):
+    calls = []
+
+    async def fn():
+        calls.append(1)
+        if errors:
+            raise errors.pop(0)
+        return "ok"
+
+    return fn, calls
+
+
+def test_transient_kind():
+    """Test classification of transient errors."""
+    assert transient_kind(asyncpg.SerializationError("conflict")) == "rolled_back"
+    assert transient_kind(ConnectionResetError()) == "connection_lost"
+    assert transient_kind(ValueError("bad input")) is None
+
+
+@pytest.mark.asyncio
+async def test_retries_rolled_back_errors():
+    """Test that errors known to have rolled back are retried for any call."""
+    fn, calls = _failing([asyncpg.DeadlockDetectedError("deadlock")])
+    assert await call_with_retry(fn, idempotent=False, policy=NO_DELAY) == "ok"
+    assert len(calls) == 2
+
+
+@pytest.mark.asyncio
+async def test_connection_loss_retried_only_when_idempotent():
+    """Test that a dropped connection is only retried for idempotent calls."""
+    fn, calls = _failing([ConnectionResetError()])
+    assert await call_with_retry(fn, idempotent=True, policy=NO_DELAY) == "ok"
+
+    fn, calls = _failing([ConnectionResetError()])
+    with pytest.raises(ConnectionResetError):
+        await call_with_retry(fn, idempotent=False, policy=NO_DELAY)
+    assert len(calls) == 1
+
+
+@pytest.mark.asyncio
+async def test_gives_up_after_max_attempts():
+    """Test that retries stop after max_attempts."""
+    fn, calls = _failing([asyncpg.SerializationError("conflict")] * 5)
+    with pytest.raises(asyncpg.SerializationError):
+        await call_with_retry(fn, idempotent=True, policy=NO_DELAY)
+    assert len(calls) == 3
@@ -0,0 +1,65 @@
+"""
+Tests for tenant sharding.
+"""
+
+import uuid
+from collections import Counter
+
+import pytest
+
+from app.db.residency import parse_regions
+from app.db.sharding import DEFAULT_SHARD, HashRing, ShardHealth, ShardRouter
+
+TENANTS = [str(uuid.UUID(int=i)) for i in range(2000)]
+
+
+def test_ring_spreads_keys_evenly():
+    """Test that every member gets a fair share of the keys."""
+    ring = HashRing(["a", "b", "c", "d"])
+
+    counts = Counter(ring.lookup(t) for t in TENANTS)
+
+    assert set(counts) == {"a", "b", "c", "d"}
+    assert all(300 < n < 700 for n in counts.values())
+
+
+def test_adding_a_member_only_moves_keys_to_it():
+    """Test that a new member takes about its share of keys, all from other members, and the rest stay."""
+    before = HashRing(["a", "b", "c"])
+    after = HashRing(["a", "b", "c", "d"])
+
+    moved = [t for t in TENANTS if before.lookup(t) != after.lookup(t)]
+
+    assert all(after.lookup(t) == "d" for t in moved)
+    assert 300 < len(moved) < 700
+
+
+def test_router_skips_unhealthy_shards():
+    """Test that tenants of an unhealthy shard are placed on the next shard, and on their own when none is healthy."""
+    router = ShardRouter("pg-main", 5432, parse_regions("s2=pg-2,s3=pg-3:6432", "DB_SHARDS", "shard"))
+    assert router.shards == (DEFAULT_SHARD, "s2", "s3")
+    assert router.server("s3") == ("pg-3", 6432)
+    homed = [t for t in TENANTS[:200] if router.home(t) == "s2"]
+
+    router.health["s2"] = ShardHealth("s2", healthy=False, error="connection refused")
+
+    assert homed
+    assert all(router.place(t) in (DEFAULT_SHARD, "s3") for t in homed)
+    assert all(router.home(t) == "s2" for t in homed)
+    for shard in router.shards:
+        router.health[shard] = ShardHealth(shard, healthy=False)
+    assert all(router.place(t) == "s2" for t in homed)
+
+
+def test_router_validates_shards():
+    """Test that the default shard name is reserved and unknown shards are refused."""
+    with pytest.raises(ValueError, match="reserved"):
+        ShardRouter("pg-main", 5432, {DEFAULT_SHARD: ("pg-2", 5432)})
+    router = ShardRouter("pg-main", 5432)
+    assert router.place(TENANTS[0]) == DEFAULT_SHARD
+    with pytest.raises(ValueError, match="configured shards"):
+        router.check_shard("s9")
+    with pytest.raises(RuntimeError, match="DB_SHARDS"):
+        router.server("s9")
+    with pytest.raises(ValueError, match="invalid DB_SHARDS entry"):
+        parse_regions("s2", "DB_SHARDS", "shard")
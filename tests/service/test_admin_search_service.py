@@ -0,0 +1,55 @@
+"""
+Tests for AdminSearchService.
+"""
+
+import uuid
+
+import pytest
+
+from app.repository import AuditRepository, ListOptions, TenantRepository
+from app.repository.errors import NotFoundError
+from app.service import AdminSearchService, AuditService
+
+
+@pytest.mark.asyncio
+async def test_admin_search_across_tenants(tenant_service, template_service, clean_control_db):
+    """Test that admin searches find nodes by ID, alias and content in every tenant and audit the access."""
+    first = await tenant_service.create(f"search-a-{uuid.uuid4().hex[:8]}", "Acme")
+    second = await tenant_service.create(f"search-b-{uuid.uuid4().hex[:8]}", "Globex")
+    nodes = {}
+    for tenant in (first, second):
+        services = await template_service.tenant_services(tenant.id)
+        person = await services["node_type"].create("Person", "", '{}')
+        nodes[tenant.id] = await services["node"].create(person.id, '{"bio": "likes quokkas"}')
+    await (await template_service.tenant_services(first.id))["node_alias"].set(
+        nodes[first.id].id, {"email": "jane@example.com"}
+    )
+
+    audit = AuditService(AuditRepository(clean_control_db))
+    service = AdminSearchService(TenantRepository(clean_control_db), template_service.tenant_services, audit)
+
+    search = await service.search("admin-1", "jane@example.com", ["alias"], request_id="req-1")
+    assert [(r.tenant.id, r.node.id, r.alias_name) for r in search.results] == [
+        (first.id, nodes[first.id].id, "email")
+    ]
+    assert search.tenants_searched >= 2 and not search.errors
+
+    search = await service.search("admin-1", nodes[second.id].id, ["id"])
+    assert [(r.tenant.slug, r.match) for r in search.results] == [(second.slug, "id")]
+
+    search = await service.search("admin-1", "quokkas", ["content"], tenant_ids=[first.id, second.id], max_results=1)
+    assert len(search.results) == 1 and search.truncated
+    assert search.results[0].to_dict()["tenant"]["name"] in ("Acme", "Globex")
+
+    events, _ = await audit.repo.list(ListOptions(page_size=10), first.id)
+    assert events[-1].actor_id == "admin-1" and events[-1].method == "admin_search_nodes"
+    assert events[-1].details == {"value": "jane@example.com", "node_ids": [nodes[first.id].id]}
+    global_events = [e for e in (await audit.repo.list(ListOptions(page_size=100)))[0] if e.request_id == "req-1"]
+    assert {e.tenant_id for e in global_events} == {"", first.id}
+
+    with pytest.raises(ValueError, match="match"):
+        await service.search("admin-1", "x", ["phone"])
+    with pytest.raises(ValueError, match="value"):
+        await service.search("admin-1", "  ")
+    with pytest.raises(NotFoundError):
+        await service.search("admin-1", "x", tenant_ids=[str(uuid.uuid4())])
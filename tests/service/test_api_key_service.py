@@ -0,0 +1,146 @@
+"""
+Tests for ApiKeyService and the API key interceptor.
+"""
+
+import uuid
+from datetime import datetime, timedelta, timezone
+
+import pytest
+from jsonrpcserver import Success
+
+from app.authz.api_keys import api_key_interceptor, scope_allows
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall, result_error_code
+from app.repository import ApiKeyRepository
+from app.repository.errors import PermissionDeniedError
+from app.service import ApiKeyService
+
+
+def test_scope_allows():
+    """Test which methods each scope can call."""
+    assert scope_allows("read", "list_nodes")
+    assert scope_allows("read", "export_tenant")
+    assert scope_allows("read", "run_saved_query")
+    assert scope_allows("export", "begin_change_subscription")
+    assert not scope_allows("read", "create_node")
+    assert not scope_allows("read", "list_audit_events")
+    assert scope_allows("export", "export_graph")
+    assert scope_allows("export", "get_operation")
+    assert not scope_allows("export", "get_node")
+    assert scope_allows("write", "delete_node")
+    assert not scope_allows("write", "create_api_key")
+    assert not scope_allows("write", "rotate_tenant_key")
+    assert not scope_allows("read", "list_tenant_keys")
+    assert not scope_allows("write", "list_tenant_keys")
+    assert not scope_allows("write", "set_tenant_maintenance")
+    assert scope_allows("admin", "start_impersonation")
+    assert scope_allows("export", "rpc_discover")
+
+
+@pytest.fixture
+def api_key_service(clean_control_db, admin_user_id):
+    return ApiKeyService(ApiKeyRepository(clean_control_db), lambda subject_id: subject_id == admin_user_id)
+
+
+@pytest.mark.asyncio
+async def test_create_and_resolve_api_key(api_key_service, admin_user_id):
+    """Test that keys resolve until they expire or are revoked, and only admins manage others' keys."""
+    caller = str(uuid.uuid4())
+    token, key = await api_key_service.create(caller, "analytics", "read", ttl_seconds=60)
+    assert token.startswith("fdb_")
+    assert key.subject_id == caller
+
+    assert (await api_key_service.resolve(token)).id == key.id
+    with pytest.raises(PermissionDeniedError, match="expired"):
+        await api_key_service.resolve(token, now=datetime.now(timezone.utc) + timedelta(seconds=61))
+
+    with pytest.raises(PermissionDeniedError):
+        await api_key_service.create(caller, "other", "read", subject_id=str(uuid.uuid4()))
+    with pytest.raises(ValueError, match="scope admin"):
+        await api_key_service.create(caller, "root", "admin")
+    with pytest.raises(PermissionDeniedError):
+        await api_key_service.revoke(str(uuid.uuid4()), key.id)
+
+    keys, _ = await api_key_service.list(caller, "", 10, "")
+    assert [k.id for k in keys] == [key.id]
+
+    await api_key_service.revoke(admin_user_id, key.id, admin_user_id)
+    with pytest.raises(PermissionDeniedError, match="revoked"):
+        await api_key_service.resolve(token)
+    with pytest.raises(PermissionDeniedError):
+        await api_key_service.resolve("fdb_not-a-key")
+
+
+@pytest.mark.asyncio
+async def test_admin_key_management_needs_a_credential(api_key_service, admin_user_id):
+    """Test that an administrator identified only by X-User-ID cannot mint admin keys or manage others' keys."""
+    other = str(uuid.uuid4())
+    _, key = await api_key_service.create(other, "pipeline", "read")
+
+    # admin_subject_id() is "" for calls identified only by the X-User-ID header
+    spoofed = RequestContext.from_headers({"X-User-ID": admin_user_id})
+    assert spoofed.admin_subject_id() == ""
+    with pytest.raises(ValueError, match="scope admin"):
+        await api_key_service.create(spoofed.subject_id, "root", "admin", admin_id=spoofed.admin_subject_id())
+    with pytest.raises(PermissionDeniedError):
+        await api_key_service.create(spoofed.subject_id, "other", "read", subject_id=other)
+    with pytest.raises(PermissionDeniedError):
+        await api_key_service.list(spoofed.subject_id, other, 10, "", spoofed.admin_subject_id())
+    with pytest.raises(PermissionDeniedError):
+        await api_key_service.revoke(spoofed.subject_id, key.id, spoofed.admin_subject_id())
+
+    # The same administrator identified by a client certificate can
+    certified = RequestContext.from_headers({"X-User-ID": admin_user_id})
+    certified.attributes["client_certificate"] = {"identity": "CN=admin"}
+    _, root = await api_key_service.create(
+        certified.subject_id, "root", "admin", admin_id=certified.admin_subject_id()
+    )
+    assert root.scope == "admin"
+    await api_key_service.revoke(certified.subject_id, key.id, certified.admin_subject_id())
+
+
+@pytest.mark.asyncio
+async def test_api_key_interceptor(api_key_service):
+    """Test that calls with a key run as its subject, within its scope and tenants."""
+    caller = str(uuid.uuid4())
+    token, _ = await api_key_service.create(caller, "pipeline", "read", tenant_ids=["t1"])
+    interceptor = api_key_interceptor(api_key_service)
+    seen = []
+
+    async def call_next(call):
+        seen.append(call.context.subject_id)
+        # Only admin-scoped keys make their subject an administrator
+        assert call.context.admin_subject_id() == ""
+        return Success(None)
+
+    def call(method, tenant_id):
+        ctx = RequestContext.from_headers({"Authorization": f"Bearer {token}", "X-User-ID": "spoofed"})
+        return RpcCall(method=method, params={"tenant_id": tenant_id}, context=ctx)
+
+    assert result_error_code(await interceptor(call("list_nodes", "t1"), call_next)) is None
+    assert seen == [caller]
+    assert result_error_code(await interceptor(call("create_node", "t1"), call_next)) == -32003
+    assert result_error_code(await interceptor(call("list_nodes", "t2"), call_next)) == -32003
+    # Tenant-limited keys cannot call methods that target no tenant
+    assert result_error_code(await interceptor(call("list_tenants", ""), call_next)) == -32003
+    assert len(seen) == 1
+
+
+@pytest.mark.asyncio
+async def test_api_key_interceptor_requires_authentication():
+    """Test that require_authentication rejects calls identified only by X-User-ID."""
+    interceptor = api_key_interceptor(None, require_authentication=True)
+
+    async def call_next(call):
+        return Success(None)
+
+    def call(method, **attributes):
+        ctx = RequestContext.from_headers({"X-User-ID": "u1"})
+        ctx.attributes.update(attributes)
+        return RpcCall(method=method, params={}, context=ctx)
+
+    assert result_error_code(await interceptor(call("list_tenants"), call_next)) == -32003
+    assert result_error_code(await interceptor(call("rpc_discover"), call_next)) is None
+    certificate = {"identity": "CN=svc", "tenant_ids": [], "role": "", "priority": ""}
+    assert result_error_code(await interceptor(call("list_tenants", client_certificate=certificate), call_next)) is None
+    assert result_error_code(await api_key_interceptor(None)(call("list_tenants"), call_next)) is None
@@ -0,0 +1,77 @@
+"""
+Tests for AttachmentService.
+"""
+
+import hashlib
+
+import pytest
+
+from app.repository.errors import NotFoundError
+
+
+async def _chunks(*parts: bytes):
+    for part in parts:
+        yield part
+
+
+@pytest.mark.asyncio
+async def test_upload_attachment(attachment_service, object_store, test_node):
+    """Test reserving and uploading an attachment."""
+    attachment = await attachment_service.reserve(test_node["id"], "report.pdf", "application/pdf")
+    assert attachment.status == "pending"
+
+    # Pending attachments are not listed or downloadable
+    attachments, _ = await attachment_service.list(test_node["id"], 10, "")
+    assert attachments == []
+    with pytest.raises(NotFoundError):
+        await attachment_service.get_by_id(attachment.id)
+
+    uploaded = await attachment_service.upload(attachment.id, _chunks(b"hello ", b"world"))
+
+    assert uploaded.status == "ready"
+    assert uploaded.size_bytes == 11
+    assert uploaded.sha256 == hashlib.sha256(b"hello world").hexdigest()
+    assert object_store.objects[attachment.storage_key] == b"hello world"
+
+    _, url, _ = await attachment_service.download_url(attachment.id)
+    assert attachment.storage_key in url
+
+
+@pytest.mark.asyncio
+async def test_upload_twice_rejected(attachment_service, test_node):
+    """Test that an attachment's content can only be uploaded once."""
+    attachment = await attachment_service.reserve(test_node["id"], "a.txt")
+    await attachment_service.upload(attachment.id, _chunks(b"a"))
+
+    with pytest.raises(ValueError, match="already been uploaded"):
+        await attachment_service.upload(attachment.id, _chunks(b"b"))
+
+
+@pytest.mark.asyncio
+async def test_upload_too_large(attachment_service, object_store, test_node):
+    """Test that uploads over the size limit are rejected and not stored."""
+    attachment = await attachment_service.reserve(test_node["id"], "big.bin")
+
+    with pytest.raises(ValueError, match="exceeds maximum size"):
+        await attachment_service.upload(attachment.id, _chunks(b"x" * 2048))
+    assert attachment.storage_key not in object_store.objects
+
+
+@pytest.mark.asyncio
+async def test_reserve_invalid_filename(attachment_service, test_node):
+    """Test that filenames with path separators are rejected."""
+    with pytest.raises(ValueError, match="filename"):
+        await attachment_service.reserve(test_node["id"], "../etc/passwd")
+
+
+@pytest.mark.asyncio
+async def test_delete_node_removes_attachment_content(attachment_service, node_service, object_store, test_node):
+    """Test that deleting a node deletes its attachments' stored content."""
+    attachment = await attachment_service.reserve(test_node["id"], "a.txt")
+    await attachment_service.upload(attachment.id, _chunks(b"a"))
+
+    await node_service.delete(test_node["id"])
+
+    assert attachment.storage_key not in object_store.objects
+    with pytest.raises(NotFoundError):
+        await attachment_service.get_by_id(attachment.id)
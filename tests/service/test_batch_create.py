@@ -0,0 +1,61 @@
+"""
+Tests for batch node creation with conflict policies.
+"""
+
+import json
+import uuid
+
+import pytest
+
+from app.service.batch_create import BatchCreateService
+
+
+@pytest.mark.asyncio
+async def test_batch_create_conflict_policies(node_service, node_alias_service, test_node_type):
+    """Test that items matching existing nodes by alias or ID follow their conflict policy."""
+    service = BatchCreateService(node_service, node_alias_service)
+    type_id = test_node_type["id"]
+    bob_id = str(uuid.uuid4())
+    items = [
+        {"key": "email", "aliases": {"email": "alice@example.com"}, "data": {"title": "Alice", "age": 30}},
+        {"id": bob_id, "data": {"title": "Bob"}},
+    ]
+    first = await service.create_nodes(items, "error", type_id)
+    assert [r.status for r in first] == ["created", "created"]
+    assert first[1].id == bob_id
+
+    again = await service.create_nodes(items, "error", type_id)
+    assert [r.status for r in again] == ["conflict", "conflict"]
+    assert again[0].id == first[0].id
+
+    assert [r.status for r in await service.create_nodes(items, "skip", type_id)] == ["skipped", "skipped"]
+
+    items[0]["data"] = {"title": "Alicia", "age": None}
+    items[1]["on_conflict"] = "replace"
+    results = await service.create_nodes(items, "merge", type_id)
+    assert [r.status for r in results] == ["merged", "unchanged"]
+    alice = await node_service.get_by_id(first[0].id)
+    assert json.loads(alice.data) == {"title": "Alicia"}
+
+    items[1]["data"] = {"name": "Robert"}
+    assert (await service.create_nodes(items[1:], "error", type_id))[0].status == "replaced"
+    assert json.loads((await node_service.get_by_id(bob_id)).data) == {"name": "Robert"}
+
+
+@pytest.mark.asyncio
+async def test_batch_create_reports_failures_per_item(node_service, node_alias_service, test_node_type):
+    """Test that a failing item does not stop the rest of the batch."""
+    service = BatchCreateService(node_service, node_alias_service)
+    results = await service.create_nodes([
+        {"data": {"title": "no type"}},
+        {"node_type_id": test_node_type["id"], "key": "email", "data": {}},
+        {"node_type_id": test_node_type["id"], "data": {"title": "ok"}, "on_conflict": "upsert"},
+        {"node_type_id": test_node_type["id"], "data": {"title": "ok"}},
+    ])
+    assert [r.status for r in results] == ["failed", "failed", "failed", "created"]
+    assert "aliases.email is required" in results[1].error
+
+    with pytest.raises(ValueError, match="on_conflict"):
+        await service.create_nodes([{}], "overwrite")
+    with pytest.raises(ValueError, match="non-empty"):
+        await service.create_nodes([])
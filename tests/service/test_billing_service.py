@@ -0,0 +1,48 @@
+"""
+Tests for BillingService.
+"""
+
+import uuid
+from datetime import datetime, timezone
+
+import pytest
+
+from app.repository import BillingEventRepository, DatabaseStatsRepository
+from app.service import BillingService
+
+
+@pytest.fixture
+async def billing_service(clean_control_db, tenant_repo) -> BillingService:
+    return BillingService(BillingEventRepository(clean_control_db), tenant_repo, DatabaseStatsRepository(clean_control_db))
+
+
+@pytest.mark.asyncio
+async def test_flush_and_rollup(billing_service, tenant_service):
+    """Test that counted usage and database size changes are written as events and rolled up per month."""
+    tenant = await tenant_service.create(f"billing-{uuid.uuid4().hex[:8]}", "Billing Tenant")
+    recorder = billing_service.recorder
+    recorder.record(tenant.id, "api_calls", "list_nodes")
+    recorder.record(tenant.id, "api_calls", "list_nodes")
+    recorder.record(tenant.id, "api_calls", "export_tenant")
+    recorder.record(tenant.id, "export_bytes", "export_tenant", 1200)
+
+    assert await billing_service.flush() == 3
+    assert await billing_service.flush() == 0
+    assert await billing_service.record_storage() == 1
+    # Unchanged sizes are not recorded again
+    assert await billing_service.record_storage() == 0
+
+    events, token = await billing_service.list_events("", 0, "")
+    assert [e.event_type for e in events] == ["api_calls", "api_calls", "export_bytes", "storage_bytes"]
+    later, next_token = await billing_service.list_events(tenant.id, 0, token)
+    assert later == [] and next_token == token
+
+    rollup = await billing_service.monthly_rollup(tenant.id)
+    assert rollup["month"] == datetime.now(timezone.utc).strftime("%Y-%m")
+    assert rollup["api_calls"] == {"total": 3, "by_method": {"export_tenant": 1, "list_nodes": 2}}
+    assert rollup["export_bytes"]["total"] == 1200
+    assert rollup["storage_bytes"]["start"] == 0
+    assert rollup["storage_bytes"]["end"] == events[-1].quantity > 0
+
+    with pytest.raises(ValueError, match="month"):
+        await billing_service.monthly_rollup(tenant.id, "2024-13")
@@ -0,0 +1,93 @@
+"""
+Tests for BulkService and JSON merge patches.
+"""
+
+import asyncio
+import json
+
+import pytest
+
+from app.service.patching import apply_merge_patch
+
+
+def test_apply_merge_patch():
+    """Test RFC 7386 merge patch semantics."""
+    target = {"a": 1, "b": {"c": 2, "d": 3}, "e": [1]}
+    patched = apply_merge_patch(target, {"a": None, "b": {"c": 5}, "e": [2], "f": "new"})
+
+    assert patched == {"b": {"c": 5, "d": 3}, "e": [2], "f": "new"}
+    assert target["a"] == 1  # target is not modified
+
+
+async def _wait(bulk_service, op_id):
+    for _ in range(100):
+        op = await bulk_service.operation_service.get_by_id(op_id)
+        if op.done:
+            return op
+        await asyncio.sleep(0.05)
+    raise AssertionError("operation did not finish")
+
+
+@pytest.mark.asyncio
+async def test_update_nodes_by_filter(bulk_service, node_service, nodetype_service):
+    """Test that a bulk update patches only the matching nodes."""
+    node_type = await nodetype_service.create("Task", "", '{}')
+    drafts = [await node_service.create(node_type.id, '{"status": "draft", "n": %d}' % i) for i in range(3)]
+    done = await node_service.create(node_type.id, '{"status": "done"}')
+
+    matched, op = await bulk_service.update_by_filter(
+        {"node_type_id": node_type.id, "data": {"status": "draft"}}, {"status": "review"}, 10, dry_run=True
+    )
+    assert matched == 3
+    assert op is None
+
+    matched, op = await bulk_service.update_by_filter(
+        {"data": {"status": "draft"}}, {"status": "review", "n": None}, 10
+    )
+    op = await _wait(bulk_service, op.id)
+    assert op.status == "completed"
+    assert op.affected_count == 3
+
+    for node in drafts:
+        assert json.loads((await node_service.get_by_id(node.id)).data) == {"status": "review"}
+    assert json.loads((await node_service.get_by_id(done.id)).data) == {"status": "done"}
+
+
+@pytest.mark.asyncio
+async def test_update_nodes_by_filter_max_affected(bulk_service, node_service, nodetype_service):
+    """Test that bulk updates matching more than max_affected nodes are refused."""
+    node_type = await nodetype_service.create("Task", "", '{}')
+    for _ in range(3):
+        await node_service.create(node_type.id, '{"status": "draft"}')
+
+    with pytest.raises(ValueError, match="more than max_affected"):
+        await bulk_service.update_by_filter({"node_type_id": node_type.id}, {"status": "x"}, 2)
+    with pytest.raises(ValueError, match="unknown filter keys"):
+        await bulk_service.update_by_filter({"type": node_type.id}, {"status": "x"}, 2)
+
+
+@pytest.mark.asyncio
+async def test_delete_nodes_by_filter_requires_confirmation(bulk_service, node_service, nodetype_service):
+    """Test that a bulk delete only previews until confirmed with a matching token."""
+    node_type = await nodetype_service.create("Task", "", '{}')
+    archived = [await node_service.create(node_type.id, '{"status": "archived"}') for _ in range(2)]
+    kept = await node_service.create(node_type.id, '{"status": "open"}')
+    filter = {"node_type_id": node_type.id, "data": {"status": "archived"}}
+
+    matched, sample, preview = await bulk_service.delete_nodes_by_filter(filter, 10)
+    assert matched == 2
+    assert sorted(sample) == sorted(n.id for n in archived)
+    assert preview.status == "awaiting_confirmation"
+    await node_service.get_by_id(archived[0].id)  # nothing deleted yet
+
+    with pytest.raises(ValueError, match="does not match"):
+        await bulk_service.delete_nodes_by_filter({"node_type_id": node_type.id}, 10, preview.id)
+
+    _, _, op = await bulk_service.delete_nodes_by_filter(filter, 10, preview.id)
+    op = await _wait(bulk_service, op.id)
+    assert op.status == "completed"
+    assert op.affected_count == 2
+    await node_service.get_by_id(kept.id)
+
+    with pytest.raises(ValueError, match="already used"):
+        await bulk_service.delete_nodes_by_filter(filter, 10, preview.id)
@@ -0,0 +1,59 @@
+"""
+Tests for CentralityService and the centrality algorithms.
+"""
+
+import asyncio
+
+import pytest
+
+from app.repository import GraphStatsRepository, OperationRepository
+from app.service import CentralityService, OperationService
+from app.service.centrality_service import betweenness, degree, pagerank
+
+
+@pytest.fixture
+async def centrality_service(tenant_db, node_repo) -> CentralityService:
+    return CentralityService(node_repo, GraphStatsRepository(tenant_db), OperationService(OperationRepository(tenant_db)))
+
+
+def test_centrality_algorithms():
+    """Test the algorithms on a star (0 at the center) with one extra edge 3 -> 4."""
+    edges = [(1, 0), (2, 0), (3, 0), (4, 0), (3, 4)]
+
+    ranks = pagerank(5, edges)
+    assert sum(ranks) == pytest.approx(1.0)
+    assert max(range(5), key=lambda i: ranks[i]) == 0
+
+    assert degree(5, edges, "both") == [1.0, 0.25, 0.25, 0.5, 0.5]
+    assert degree(5, edges, "out")[0] == 0.0
+
+    scores = betweenness(5, edges, "both")
+    assert scores[0] == pytest.approx(5 / 6)
+    assert scores[1] == 0.0
+    assert betweenness(3, [(0, 1), (1, 2)], "out") == pytest.approx([0.0, 0.5, 0.0])
+
+
+@pytest.mark.asyncio
+async def test_compute_and_list_central_nodes(centrality_service, node_service, relationship_service, test_node_type):
+    """Test that scores are stored in node metadata and listed highest first."""
+    hub = await node_service.create(test_node_type["id"], '{}')
+    spokes = [await node_service.create(test_node_type["id"], '{}') for _ in range(3)]
+    for spoke in spokes:
+        await relationship_service.create(spoke.id, hub.id, "links", '{}')
+
+    op = await centrality_service.start("pagerank")
+    for _ in range(100):
+        op = await centrality_service.operation_service.get_by_id(op.id)
+        if op.done:
+            break
+        await asyncio.sleep(0.05)
+    assert op.status == "completed"
+    assert op.affected_count == 4
+
+    top = await centrality_service.top("pagerank", 2)
+    assert [node.id for node, _ in top][0] == hub.id
+    assert top[0][1] > top[1][1]
+    assert "pagerank" in (await node_service.get_by_id(hub.id)).metadata["centrality"]
+
+    with pytest.raises(ValueError, match="algorithm"):
+        await centrality_service.start("closeness")
@@ -0,0 +1,76 @@
+"""
+Tests for change subscriptions.
+"""
+
+import pytest
+
+from app.repository import ListResult, NotFoundError
+from app.service.change_stream import ChangeStreamManager, TooManyStreamsError, watch_changes
+
+
+class FakeExport:
+    """Serves scripted pages of changes: {cursor: [(nodes, next_page_token), ...]}."""
+
+    def __init__(self, pages):
+        self.pages = pages
+        self.calls = []
+
+    async def current_cursor(self):
+        return "now"
+
+    async def changes(self, sync_cursor, filter, include_relationships, page_size, page_token, transform=None):
+        self.calls.append((sync_cursor, page_token))
+        index = int(page_token or 0)
+        nodes, next_token = self.pages.get(sync_cursor, [([], "")])[index]
+        return nodes, [], [], ListResult(next_page_token=next_token), sync_cursor + "+"
+
+
+@pytest.mark.asyncio
+async def test_watch_changes_resumes_from_poll_start_until_last_page():
+    """Test that pages of a poll carry the poll's starting cursor, and its last page the next cursor."""
+    export = FakeExport({"c1": [(["a"], "1"), (["b"], "")]})
+    stream = watch_changes(export, "c1", None, True, poll_seconds=0)
+
+    first, second, idle = [await stream.__anext__() for _ in range(3)]
+    await stream.aclose()
+
+    assert (first.nodes, first.sync_cursor) == (["a"], "c1")
+    assert (second.nodes, second.sync_cursor) == (["b"], "c1+")
+    assert idle.empty and idle.sync_cursor == "c1++"
+    assert export.calls == [("c1", ""), ("c1", "1"), ("c1+", "")]
+
+
+@pytest.mark.asyncio
+async def test_subscriptions_belong_to_their_tenant():
+    """Test that subscriptions default to changes from now and resolve only for their tenant."""
+    manager = ChangeStreamManager()
+    subscription = await manager.begin("t1", FakeExport({}), "", {"node_type_id": "type-1"}, True)
+
+    assert subscription.token.startswith("cs_") and subscription.sync_cursor == "now"
+    assert manager.get(subscription.token, "t1") is subscription
+    with pytest.raises(NotFoundError):
+        manager.get(subscription.token, "t2")
+
+    manager.end(subscription.token, "t1")
+    with pytest.raises(NotFoundError):
+        manager.get(subscription.token, "t1")
+
+
+@pytest.mark.asyncio
+async def test_streams_are_bounded():
+    """Test that streams beyond max_streams are refused and slots are released."""
+    manager = ChangeStreamManager(max_streams=1)
+    subscription = await manager.begin("t1", FakeExport({}), "c1", None, True)
+
+    async with manager.stream(subscription.token, "t1"):
+        with pytest.raises(TooManyStreamsError):
+            async with manager.stream(subscription.token, "t1"):
+                pass
+    assert manager.open_streams == 0
+
+
+@pytest.mark.asyncio
+async def test_invalid_filter_is_rejected():
+    """Test that subscriptions validate their filter when reserved."""
+    with pytest.raises(ValueError):
+        await ChangeStreamManager().begin("t1", FakeExport({}), "c1", {"unknown_key": 1}, True)
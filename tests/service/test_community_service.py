@@ -0,0 +1,62 @@
+"""
+Tests for CommunityService and the community detection algorithms.
+"""
+
+import asyncio
+
+import pytest
+
+from app.repository import GraphStatsRepository, OperationRepository
+from app.service import CommunityService, OperationService
+from app.service.community_service import connected_components, label_propagation
+
+
+@pytest.fixture
+async def community_service(tenant_db, node_repo) -> CommunityService:
+    return CommunityService(node_repo, GraphStatsRepository(tenant_db), OperationService(OperationRepository(tenant_db)))
+
+
+def test_community_algorithms():
+    """Test the algorithms on two triangles joined by one edge, plus an isolated node."""
+    ids = ["a", "b", "c", "d", "e", "f", "g"]
+    edges = [(0, 1), (1, 2), (2, 0), (3, 4), (4, 5), (5, 3), (2, 3)]
+
+    assert connected_components(ids, edges) == ["a"] * 6 + ["g"]
+    assert connected_components(ids, edges[:6]) == ["a"] * 3 + ["d"] * 3 + ["g"]
+
+    labels = label_propagation(ids, edges)
+    assert labels[0] == labels[1] == labels[2]
+    assert labels[3] == labels[4] == labels[5]
+    assert labels[0] != labels[3]
+    assert labels[6] == "g"
+
+
+@pytest.mark.asyncio
+async def test_detect_and_list_communities(community_service, node_service, relationship_service, test_node_type):
+    """Test that community IDs are stored in node metadata, listed and usable as a list_nodes filter."""
+    ring = [await node_service.create(test_node_type["id"], '{}') for _ in range(3)]
+    for a, b in zip(ring, ring[1:]):
+        await relationship_service.create(a.id, b.id, "shares_card", '{}')
+    loner = await node_service.create(test_node_type["id"], '{}')
+
+    op = await community_service.start("components")
+    for _ in range(100):
+        op = await community_service.operation_service.get_by_id(op.id)
+        if op.done:
+            break
+        await asyncio.sleep(0.05)
+    assert op.status == "completed"
+    assert op.affected_count == 4
+
+    communities = await community_service.list_communities("components")
+    assert communities == [(min(n.id for n in ring), 3)]
+    assert len(await community_service.list_communities("components", min_size=1)) == 2
+    assert (await node_service.get_by_id(loner.id)).metadata["community"]["components"] == loner.id
+
+    members, _ = await node_service.list(
+        test_node_type["id"], metadata={"community": {"components": communities[0][0]}}
+    )
+    assert {n.id for n in members} == {n.id for n in ring}
+
+    with pytest.raises(ValueError, match="algorithm"):
+        await community_service.start("louvain")
@@ -0,0 +1,65 @@
+"""
+Tests for TenantComparisonService.
+"""
+
+import pytest
+
+from app.repository import Node, Relationship
+from app.service import TenantComparisonService, TenantLimits
+
+
+class FakeExport:
+    """Stands in for ExportService.scan over fixed nodes and relationships."""
+
+    def __init__(self, nodes, relationships=()):
+        self.items = {"nodes": sorted(nodes, key=lambda n: n.id), "relationships": sorted(relationships, key=lambda r: r.id)}
+        self.limits = TenantLimits()
+
+    async def scan(self, entity, after_id, limit):
+        return [i for i in self.items[entity] if i.id > after_id][:limit]
+
+
+def _id(n: int) -> str:
+    return f"00000000-0000-0000-0000-{n:012d}"
+
+
+@pytest.mark.asyncio
+async def test_compare_tenants_pages_through_both_sides():
+    """Test that added, removed and changed entities are found across pages of unequal sides."""
+    base = FakeExport(
+        [Node(id=_id(i), node_type_id="t", data='{"n": %d}' % i) for i in (1, 2, 3, 5, 6)],
+        [Relationship(id=_id(1), source_node_id=_id(1), target_node_id=_id(2), relationship_type="knows")],
+    )
+    other = FakeExport(
+        # Same document serialized differently, one changed, one added and one removed
+        [Node(id=_id(1), node_type_id="t", data='{ "n" : 1 }'), Node(id=_id(2), node_type_id="t", data='{"n": 20}')]
+        + [Node(id=_id(i), node_type_id="t", data='{"n": %d}' % i) for i in (3, 4, 5)],
+        [Relationship(id=_id(1), source_node_id=_id(1), target_node_id=_id(3), relationship_type="knows")],
+    )
+    services = {"base": {"export": base}, "other": {"export": other}}
+
+    async def tenant_services(tenant_id):
+        return services[tenant_id]
+
+    comparison = TenantComparisonService(tenant_services, None)
+    added, removed, changed, token, compared = [], [], [], "", 0
+    relationship_changes = []
+    while True:
+        differences, count, result = await comparison.compare("base", "other", "", True, 2, token)
+        compared += count
+        added += [n["id"] for n in differences["nodes"]["added"]]
+        removed += [n["id"] for n in differences["nodes"]["removed"]]
+        changed += [(n["id"], n["fields"]) for n in differences["nodes"]["changed"]]
+        relationship_changes += [(r["id"], r["fields"]) for r in differences["relationships"]["changed"]]
+        token = result.next_page_token
+        if not token:
+            break
+
+    assert added == [_id(4)]
+    assert removed == [_id(6)]
+    assert changed == [(_id(2), ["data"])]
+    assert relationship_changes == [(_id(1), ["target_node_id"])]
+    assert compared == 7
+
+    with pytest.raises(ValueError, match="other_tenant_id or database_name"):
+        await comparison.compare("base", "other", "backup", True, 2, "")
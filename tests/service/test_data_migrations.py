@@ -0,0 +1,84 @@
+"""
+Tests for DataMigrationService and migration rules.
+"""
+
+import asyncio
+import json
+
+import pytest
+
+from app.service.data_migrations import apply_rules, check_rules
+
+
+def test_apply_rules():
+    """Test JSON path remapping rules."""
+    data = {"fullName": "Ada", "email": "ada@example.com", "legacy": 1, "tags": ["x"]}
+    rules = check_rules([
+        {"op": "rename", "from": "/fullName", "to": "/name/full"},
+        {"op": "copy", "from": "/email", "to": "/contact/email"},
+        {"op": "rename", "from": "/missing", "to": "/other"},
+        {"op": "set", "path": "/status", "value": "active"},
+        {"op": "default", "path": "/tags", "value": []},
+        {"op": "default", "path": "/labels", "value": []},
+        {"op": "remove", "path": "/legacy"},
+    ])
+
+    assert apply_rules(data, rules) == {
+        "name": {"full": "Ada"},
+        "email": "ada@example.com",
+        "contact": {"email": "ada@example.com"},
+        "status": "active",
+        "tags": ["x"],
+        "labels": [],
+    }
+    assert data["fullName"] == "Ada"  # data is not modified
+
+    with pytest.raises(ValueError):
+        check_rules([{"op": "rename", "from": "/a"}])
+    with pytest.raises(ValueError):
+        check_rules([{"op": "set", "path": "/a"}])
+
+
+@pytest.mark.asyncio
+async def test_data_migration_lazy_and_backfill(data_migration_service, node_service, nodetype_service, node_repo):
+    """Test that nodes are migrated on read, on write and by a backfill."""
+    node_type = await nodetype_service.create("Person", "", '{}')
+    first = await node_service.create(node_type.id, '{"first": "Ada", "last": "Lovelace"}')
+    second = await node_service.create(node_type.id, '{"first": "Alan", "last": "Turing"}')
+    assert first.schema_version == 1
+
+    migration, node_type = await data_migration_service.create(
+        node_type.id, "full name",
+        rules=[{"op": "default", "path": "/title", "value": ""}],
+        expression='{"name": data.first + " " + data.last, "title": data.title}',
+    )
+    assert migration.from_version == 1
+    assert node_type.schema_version == 2
+
+    # Lazily migrated on read, not yet stored
+    node = await node_service.get_by_id(first.id)
+    assert json.loads(node.data) == {"name": "Ada Lovelace", "title": ""}
+    assert node.schema_version == 2
+    assert node.etag == first.etag
+    assert (await node_repo.get_by_id(first.id)).schema_version == 1
+
+    # Stored migrated when written
+    node = await node_service.update(first.id, json.dumps({"name": "Ada King", "title": "Countess"}))
+    assert node.schema_version == 2
+
+    op = await data_migration_service.backfill(node_type.id)
+    assert op.total_count == 1
+    for _ in range(100):
+        op = await data_migration_service.operation_service.get_by_id(op.id)
+        if op.done:
+            break
+        await asyncio.sleep(0.05)
+    assert op.status == "completed"
+    assert op.affected_count == 1
+
+    stored = await node_repo.get_by_id(second.id)
+    assert stored.schema_version == 2
+    assert json.loads(stored.data) == {"name": "Alan Turing", "title": ""}
+    assert stored.updated_at == second.updated_at
+
+    assert [m.id for m in await data_migration_service.list(node_type.id)] == [migration.id]
@@ -0,0 +1,70 @@
+"""
+Tests for DirectoryService and directory principals.
+"""
+
+import pytest
+from jsonrpcserver import Success
+
+from app.authz.principals import principal_interceptor
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall, result_error_code
+from app.repository.attribution import current_actor
+
+
+@pytest.mark.asyncio
+async def test_directory_users_and_groups(directory_service):
+    """Test creating users, group membership, disabling and resolving callers."""
+    ada = await directory_service.create_user("ada", "Ada Lovelace", "ada@example.com", external_id="okta|1")
+    alan = await directory_service.create_user("alan", "Alan Turing")
+    with pytest.raises(ValueError, match="already exists"):
+        await directory_service.create_user("ada")
+
+    finance = await directory_service.create_group("finance")
+    group = await directory_service.add_member(finance.id, ada.id)
+    assert group.member_count == 1
+    assert (await directory_service.get_user(ada.id)).groups == ["finance"]
+
+    members, _ = await directory_service.list_users(0, "", group_id=finance.id)
+    assert [u.user_name for u in members] == ["ada"]
+
+    await directory_service.disable_user(alan.id)
+    active, result = await directory_service.list_users(0, "", active=True)
+    assert [u.user_name for u in active] == ["ada"]
+    assert result.total_count == 1
+
+    assert (await directory_service.resolve("okta|1")).id == ada.id
+    assert await directory_service.resolve("nobody") is None
+
+    await directory_service.delete_group(finance.id)
+    assert (await directory_service.get_user(ada.id)).groups == []
+
+
+@pytest.mark.asyncio
+async def test_principal_interceptor(directory_service):
+    """Test that disabled users are denied and active ones become the call's principal."""
+    ada = await directory_service.create_user("ada")
+    bob = await directory_service.create_user("bob", active=False)
+    editors = await directory_service.create_group("editors")
+    await directory_service.add_member(editors.id, ada.id)
+
+    async def resolve(tenant_id, subject_id):
+        return await directory_service.resolve(subject_id)
+
+    interceptor = principal_interceptor(resolve, cache_seconds=0)
+    seen = {}
+
+    async def call_next(call):
+        seen["actor"] = current_actor()
+        seen["principal"] = call.context.attributes.get("principal")
+        return Success({})
+
+    call = RpcCall("list_nodes", {"tenant_id": "t1"}, RequestContext(subject_id="ada"))
+    await interceptor(call, call_next)
+    assert seen == {"actor": "ada", "principal": {"id": ada.id, "user_name": "ada", "groups": ["editors"]}}
+
+    call = RpcCall("list_nodes", {"tenant_id": "t1"}, RequestContext(subject_id="api-key-7"))
+    await interceptor(call, call_next)
+    assert seen == {"actor": "api-key-7", "principal": None}
+
+    call = RpcCall("list_nodes", {"tenant_id": "t1"}, RequestContext(subject_id=bob.user_name))
+    assert result_error_code(await interceptor(call, call_next)) == -32003
@@ -0,0 +1,40 @@
+"""
+Tests for ExpansionService.
+"""
+
+import pytest
+
+from app.service.expansion import parse_expand
+
+
+def test_parse_expand():
+    """Test parsing expansion expressions and their bounds."""
+    specs = parse_expand(["relationships", "neighbors(type=OWNS, direction=out, depth=2)"])
+    assert [s.key for s in specs] == ["relationships", "neighbors:OWNS"]
+    assert (specs[1].direction, specs[1].depth) == ("out", 2)
+
+    with pytest.raises(ValueError, match="invalid expand expression"):
+        parse_expand(["children"])
+    with pytest.raises(ValueError, match="depth must be between"):
+        parse_expand(["neighbors(depth=10)"])
+    with pytest.raises(ValueError, match="only applies to neighbors"):
+        parse_expand(["relationships(depth=2)"])
+
+
+@pytest.mark.asyncio
+async def test_expand_neighbors(expansion_service, node_service, nodetype_service, relationship_service):
+    """Test embedding neighbors up to a depth for several nodes at once."""
+    node_type = await nodetype_service.create("Person", "", '{}')
+    a, b, c, d = [await node_service.create(node_type.id, '{}') for _ in range(4)]
+    await relationship_service.create(a.id, b.id, "OWNS", '{}')
+    await relationship_service.create(b.id, c.id, "OWNS", '{}')
+    await relationship_service.create(d.id, a.id, "KNOWS", '{}')
+
+    specs = expansion_service.parse(["neighbors(type=OWNS,direction=out,depth=2)", "relationships"])
+    expanded = await expansion_service.expand([a, c], specs)
+
+    neighbors = expanded[a.id]["neighbors:OWNS"]
+    assert [(n["node"]["id"], n["depth"], n["direction"]) for n in neighbors] == [(b.id, 1, "out"), (c.id, 2, "out")]
+    assert len(expanded[a.id]["relationships"]) == 2
+    assert expanded[c.id]["neighbors:OWNS"] == []
+    assert len(expanded[c.id]["relationships"]) == 1
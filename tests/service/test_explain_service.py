@@ -0,0 +1,35 @@
+"""
+Tests for ExplainService.
+"""
+
+import uuid
+
+import pytest
+
+from app.service import ExplainService
+
+
+@pytest.mark.asyncio
+async def test_explain_captures_tenant_statements(tenant_repo, tenant_db_manager, tenant_service):
+    """Test that the statements a call runs on the tenant database are returned with their plans."""
+    tenant = await tenant_service.create(f"explain-{uuid.uuid4().hex[:8]}", "Explain Tenant")
+    service = ExplainService(tenant_repo, tenant_db_manager)
+    db = await tenant_db_manager.get_tenant_db(tenant.id)
+
+    async def run():
+        async with db.pool.acquire() as conn:
+            await conn.fetch("SELECT id FROM nodes WHERE node_type_id = $1", str(uuid.uuid4()))
+        return None
+
+    explanation = await service.explain(tenant.id, "list_nodes", run)
+    statement = next(s for s in explanation["statements"] if "FROM nodes" in s["sql"])
+    assert statement["sql"] == "SELECT id FROM nodes WHERE node_type_id = $1"
+    assert statement["plan"]["Plan"]["Actual Loops"] >= 0
+    assert explanation["error"] is None
+
+    estimated = await service.explain(tenant.id, "list_nodes", run, analyze=False)
+    statement = next(s for s in estimated["statements"] if "FROM nodes" in s["sql"])
+    assert "Actual Loops" not in statement["plan"]["Plan"]
+
+    with pytest.raises(ValueError, match="method must be one of"):
+        await service.explain(tenant.id, "delete_node", run)
@@ -0,0 +1,145 @@
+"""
+Tests for scheduled exports and cron expressions.
+"""
+
+import json
+import uuid
+from datetime import datetime, timezone
+
+import pytest
+
+from app.repository import ExportScheduleRepository, TenantRepository
+from app.repository.errors import NotFoundError
+from app.service import ExportScheduleService
+from app.service.cron import parse_cron
+from app.storage import ExportDestination, LocalDestination, StoredObject, configure_local_exports, open_destination
+
+
+def test_parse_cron():
+    """Test cron fields, day matching and time zones."""
+    after = datetime(2024, 5, 2, 14, 3, tzinfo=timezone.utc)  # a Thursday
+    assert parse_cron("*/15 * * * *").next_after(after) == datetime(2024, 5, 2, 14, 15, tzinfo=timezone.utc)
+    assert parse_cron("@daily").next_after(after) == datetime(2024, 5, 3, tzinfo=timezone.utc)
+    assert parse_cron("0 9 * * mon-fri").next_after(datetime(2024, 5, 3, 10, tzinfo=timezone.utc)) == datetime(
+        2024, 5, 6, 9, tzinfo=timezone.utc
+    )
+    # Either day field matches when both are restricted
+    assert parse_cron("0 0 1 * sun").next_after(after) == datetime(2024, 5, 5, tzinfo=timezone.utc)
+    # 02:00 in Tokyo is 17:00 UTC the day before
+    assert parse_cron("0 2 * * *").next_after(after, "Asia/Tokyo") == datetime(2024, 5, 2, 17, tzinfo=timezone.utc)
+    # 02:30 does not exist in New York on 2024-03-10
+    assert parse_cron("30 2 * * *").next_after(
+        datetime(2024, 3, 10, 5, tzinfo=timezone.utc), "America/New_York"
+    ) == datetime(2024, 3, 11, 6, 30, tzinfo=timezone.utc)
+
+    for expression in ["", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "0 0 30 2 *", "0 0 * foo *"]:
+        with pytest.raises(ValueError):
+            parse_cron(expression)
+
+
+def test_open_destination(tmp_path):
+    """Test destination URL validation and the confinement of file destinations."""
+    for url, secret in [
+        ("", None),
+        ("ftp://example.com/exports/", None),
+        ("azure://account/", {"account_key": "k"}),
+        ("azure://account/container/", None),
+        ("sftp://example.com/exports/", None),
+        ("sftp://example.com/exports/", {"username": "u", "password": "p"}),
+        ("sftp://u:p@example.com/exports/", {"password": "p", "host_key": "ssh-ed25519 AAAA"}),
+        ("file:///exports/", None),
+    ]:
+        with pytest.raises(ValueError):
+            open_destination(url, secret)
+
+    configure_local_exports(str(tmp_path))
+    try:
+        destination = open_destination("file:///nightly/")
+        assert isinstance(destination, LocalDestination)
+        assert destination.directory == str(tmp_path / "nightly")
+        with pytest.raises(ValueError):
+            open_destination("file:///../outside/")
+    finally:
+        configure_local_exports("")
+
+
+class MemoryDestination(ExportDestination):
+    """Keeps written files in memory; a destination named fail:// rejects writes."""
+
+    def __init__(self, url: str, files: dict):
+        self.url = url
+        self.files = files
+
+    async def write(self, name, chunks, content_type, max_bytes):
+        if self.url.startswith("fail://"):
+            raise RuntimeError("destination unavailable")
+        content = b"".join([chunk async for chunk in chunks])
+        self.files[self.url + name] = content
+        return self.url + name, StoredObject(size_bytes=len(content), sha256="")
+
+
+@pytest.mark.asyncio
+async def test_export_schedule(tenant_service, template_service, clean_control_db):
+    """Test that due schedules write exports, record runs and alert on failures."""
+    tenant = await tenant_service.create(f"exports-{uuid.uuid4().hex[:8]}", "Exports")
+    services = await template_service.tenant_services(tenant.id)
+    person = await services["node_type"].create("Person", "", '{}')
+    await services["node"].create(person.id, '{"name": "Ada"}')
+    await services["node"].create(person.id, '{"name": "Alan"}')
+
+    files, alerts = {}, []
+
+    async def alert(url, payload):
+        alerts.append((url, payload))
+
+    repo = ExportScheduleRepository(clean_control_db)
+    schedules = ExportScheduleService(
+        repo, TenantRepository(clean_control_db), template_service.tenant_services,
+        destinations=lambda url, secret: MemoryDestination(url, files), alert=alert,
+    )
+
+    schedule = await schedules.create(tenant.id, "nightly", "0 2 * * *", "memory://exports/")
+    assert schedule.next_run_at > datetime.now(timezone.utc)
+    with pytest.raises(ValueError):
+        await schedules.create(tenant.id, "bad", "0 2 * *", "memory://exports/")
+    with pytest.raises(ValueError):
+        await schedules.create(tenant.id, "bad", "0 2 * * *", "memory://exports/", format="csv")
+
+    # Not yet due
+    assert await schedules.run_due() == 0
+    async with clean_control_db.pool.acquire() as conn:
+        await conn.execute("UPDATE export_schedules SET next_run_at = NOW() - INTERVAL '1 minute'")
+    assert await schedules.run_due() == 1
+    assert await schedules.run_due() == 0
+
+    runs, result = await schedules.list_runs(tenant.id, schedule.id, 10, "")
+    assert result.total_count == 1
+    assert runs[0].status == "succeeded" and runs[0].node_count == 2
+    lines = [json.loads(line) for line in files[runs[0].location].decode().splitlines()]
+    assert sorted(line["node"]["data_object"]["name"] for line in lines) == ["Ada", "Alan"]
+    assert (await schedules.get(tenant.id, schedule.id)).next_run_at > datetime.now(timezone.utc)
+
+    # Transforms apply to the written files
+    await schedules.update(tenant.id, schedule.id, transform={"redact": [{"path": "data.name"}]})
+    run = await schedules.run_now(tenant.id, schedule.id)
+    lines = [json.loads(line) for line in files[run.location].decode().splitlines()]
+    assert [line["node"]["data_object"]["name"] for line in lines] == ["[REDACTED]", "[REDACTED]"]
+    with pytest.raises(ValueError, match="transform"):
+        await schedules.update(tenant.id, schedule.id, transform={"include": "data.name"})
+
+    await schedules.update(tenant.id, schedule.id, destination="fail://exports/", alert_url="https://hooks.example.com/x")
+    run = await schedules.run_now(tenant.id, schedule.id)
+    assert run.status == "failed" and "unavailable" in run.error
+    assert alerts[0][0] == "https://hooks.example.com/x"
+    assert alerts[0][1]["run"]["id"] == run.id
+    assert (await schedules.get(tenant.id, schedule.id)).consecutive_failures == 1
+
+    # Schedules of other tenants are not visible
+    with pytest.raises(NotFoundError):
+        await schedules.get(str(uuid.uuid4()), schedule.id)
+
+    disabled = await schedules.update(tenant.id, schedule.id, enabled=False)
+    assert disabled.next_run_at is None
+    await schedules.delete(tenant.id, schedule.id)
+    schedules_left, _ = await schedules.list(tenant.id, 10, "")
+    assert schedules_left == []
@@ -0,0 +1,149 @@
+"""
+Tests for ExportService.
+"""
+
+import json
+
+import pytest
+
+
+@pytest.mark.asyncio
+async def test_export_filtered_pages(export_service, node_service, nodetype_service, relationship_service):
+    """Test that exports page through only the matching nodes, with their outgoing relationships."""
+    task_type = await nodetype_service.create("Task", "", '{}')
+    note_type = await nodetype_service.create("Note", "", '{}')
+    tasks = [await node_service.create(task_type.id, '{"n": %d}' % i) for i in range(5)]
+    note = await node_service.create(note_type.id, '{}')
+    await relationship_service.create(tasks[0].id, note.id, "mentions", '{}')
+    await relationship_service.create(note.id, tasks[1].id, "mentions", '{}')
+
+    exported, relationships, page_token = [], [], ""
+    while True:
+        nodes, rels, result, _ = await export_service.export({"node_type_id": task_type.id}, True, 2, page_token)
+        assert result.total_count == 5
+        exported += nodes
+        relationships += rels
+        page_token = result.next_page_token
+        if not page_token:
+            break
+
+    assert sorted(n.id for n in exported) == sorted(t.id for t in tasks)
+    assert [n.id for n in exported] == sorted(n.id for n in exported)
+    assert [(r.source_node_id, r.target_node_id) for r in relationships] == [(tasks[0].id, note.id)]
+
+
+@pytest.mark.asyncio
+async def test_export_updated_after(export_service, node_service, nodetype_service):
+    """Test that exports can be limited to nodes modified since a time."""
+    node_type = await nodetype_service.create("Task", "", '{}')
+    old = await node_service.create(node_type.id, '{}')
+    new = await node_service.create(node_type.id, '{}')
+
+    nodes, _, _, _ = await export_service.export(
+        {"updated_after": new.updated_at.isoformat()}, False, 0, ""
+    )
+    assert [n.id for n in nodes] == [new.id]
+    assert old.id not in [n.id for n in nodes]
+
+    with pytest.raises(ValueError):
+        await export_service.export({"modified": "yesterday"}, False, 0, "")
+    with pytest.raises(ValueError):
+        await export_service.export(None, False, 0, "not-a-node-id")
+
+
+@pytest.mark.asyncio
+async def test_export_changes_since_cursor(export_service, node_service, nodetype_service, relationship_service):
+    """Test that incremental exports return only changes since the cursor, with tombstones for deletions."""
+    node_type = await nodetype_service.create("Task", "", '{}')
+    kept = await node_service.create(node_type.id, '{"n": 1}')
+    changed = await node_service.create(node_type.id, '{"n": 2}')
+    removed = await node_service.create(node_type.id, '{"n": 3}')
+    rel = await relationship_service.create(removed.id, kept.id, "blocks", '{}')
+
+    _, _, _, cursor = await export_service.export(None, True, 0, "")
+
+    await node_service.update(changed.id, '{"n": 20}')
+    await node_service.delete(removed.id)
+    added = await node_service.create(node_type.id, '{"n": 4}')
+
+    nodes, rels, tombstones, page_token = [], [], [], ""
+    while True:
+        n, r, t, result, next_cursor = await export_service.changes(cursor, None, True, 1, page_token)
+        nodes += n
+        rels += r
+        tombstones += t
+        page_token = result.next_page_token
+        if not page_token:
+            break
+
+    assert sorted(n.id for n in nodes) == sorted([changed.id, added.id])
+    assert rels == []
+    assert sorted((t.entity_type, t.entity_id) for t in tombstones) == sorted(
+        [("node", removed.id), ("relationship", rel.id)]
+    )
+
+    nodes, _, tombstones, _, _ = await export_service.changes(next_cursor, None, True, 0, "")
+    assert nodes == [] and tombstones == []
+
+    with pytest.raises(ValueError):
+        await export_service.changes("not-a-cursor", None, True, 0, "")
+
+
+@pytest.mark.asyncio
+async def test_export_graph(export_service, node_service, nodetype_service, relationship_service):
+    """Test GraphML and DOT exports of a filtered subgraph."""
+    import xml.etree.ElementTree as ET
+
+    person_type = await nodetype_service.create("Person", "", '{}')
+    other_type = await nodetype_service.create("Other", "", '{}')
+    ada = await node_service.create(person_type.id, '{"name": "Ada", "age": 36, "tags": ["math"]}')
+    alan = await node_service.create(person_type.id, '{"name": "Alan & co", "age": 41.5}')
+    other = await node_service.create(other_type.id, '{}')
+    await relationship_service.create(ada.id, alan.id, "knows", '{"since": 1840}')
+    await relationship_service.create(ada.id, other.id, "knows", '{}')
+
+    content, node_count, rel_count = await export_service.export_graph(
+        "graphml", {"node_type_id": person_type.id}, "name"
+    )
+    assert (node_count, rel_count) == (2, 1)
+    ns = {"g": "http://graphml.graphdrawing.org/xmlns"}
+    root = ET.fromstring(content)
+    keys = {k.get("attr.name"): k.get("attr.type") for k in root.findall("g:key", ns) if k.get("for") == "node"}
+    assert keys["data.age"] == "double"
+    assert keys["data.tags"] == "string"
+    labels = [d.text for d in root.iter("{http://graphml.graphdrawing.org/xmlns}data") if d.get("key") == "label"]
+    assert sorted(labels) == ["Ada", "Alan & co"]
+    assert len(root.findall("g:graph/g:edge", ns)) == 1
+
+    content, _, _ = await export_service.export_graph("dot", None)
+    assert content.startswith("digraph tenant {")
+    assert f'"{ada.id}" -> "{other.id}" [label="knows"' in content
+
+    with pytest.raises(ValueError, match="format"):
+        await export_service.export_graph("gexf", None)
+
+
+@pytest.mark.asyncio
+async def test_export_transform(export_service, node_service, nodetype_service, relationship_service):
+    """Test that export transforms select and redact fields of exported nodes and relationships."""
+    person_type = await nodetype_service.create("Person", "", '{}')
+    ada = await node_service.create(person_type.id, '{"name": "Ada", "email": "ada@example.com", "ssn": "123"}')
+    bob = await node_service.create(person_type.id, '{"name": "Bob"}')
+    await relationship_service.create(ada.id, bob.id, "knows", '{"notes": "private", "since": 2020}')
+
+    transform = {
+        "exclude": ["data.ssn"],
+        "redact": [{"path": "data.email", "with": "mask"}],
+        "relationships": {"include": ["data.since"]},
+    }
+    nodes, relationships, _, _ = await export_service.export(None, True, 0, "", transform)
+    exported = {n.id: json.loads(n.data) for n in nodes}
+    assert exported[ada.id] == {"name": "Ada", "email": "[REDACTED]"}
+    assert exported[bob.id] == {"name": "Bob"}
+    assert [json.loads(r.data) for r in relationships] == [{"since": 2020}]
+
+    content, _, _ = await export_service.export_graph("dot", None, "email", transform)
+    assert "ada@example.com" not in content
+
+    with pytest.raises(ValueError, match="hash_salt"):
+        await export_service.export(None, True, 0, "", {"redact": [{"path": "data.email", "with": "hash"}]})
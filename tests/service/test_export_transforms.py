@@ -0,0 +1,89 @@
+"""
+Tests for export transforms.
+"""
+
+import json
+
+import pytest
+
+from app.repository import Node, Relationship
+from app.service.export_transforms import MASK, parse_transform
+
+SALT = "0123456789abcdef"
+
+
+def _node(data, metadata=None, node_type_id="person"):
+    return Node(id="n1", node_type_id=node_type_id, data=json.dumps(data), metadata=metadata or {})
+
+
+def test_include_exclude_and_arrays():
+    """Test that include keeps only listed paths, exclude drops paths and both descend through arrays."""
+    transform = parse_transform({
+        "include": ["data.name", "data.contacts.email", "data.contacts.phone", "metadata.source"],
+        "exclude": ["data.contacts.phone"],
+    })
+    node = transform.node(_node(
+        {"name": "Ada", "ssn": "123", "contacts": [{"email": "a@x.io", "phone": "1", "note": "n"}, "junk"]},
+        {"source": "crm", "sync_token": "t"},
+    ))
+    assert json.loads(node.data) == {"name": "Ada", "contacts": [{"email": "a@x.io"}]}
+    assert node.metadata == {"source": "crm"}
+
+    node = parse_transform({"exclude": ["metadata", "data.missing.path"]}).node(_node({"a": 1}, {"k": "v"}))
+    assert (json.loads(node.data), node.metadata) == ({"a": 1}, {})
+
+
+def test_redactions():
+    """Test that redactions mask, hash with the salt or replace values, leaving nulls and missing paths alone."""
+    transform = parse_transform({
+        "redact": [
+            {"path": "data.email", "with": "hash"},
+            {"path": "data.phone"},
+            {"path": "data.birth", "with": "value", "value": "1900-01-01"},
+            {"path": "data.fax", "with": "mask"},
+        ],
+        "hash_salt": SALT,
+    })
+    first = json.loads(transform.node(_node({"email": "a@x.io", "phone": "1", "birth": "1990-02-03", "fax": None})).data)
+    second = json.loads(transform.node(_node({"email": "a@x.io"})).data)
+    assert first["email"].startswith("sha256:") and first["email"] == second["email"]
+    assert first["phone"] == MASK
+    assert first["birth"] == "1900-01-01"
+    assert first["fax"] is None
+    assert "phone" not in second
+
+    other_salt = parse_transform({"redact": [{"path": "data.email", "with": "hash"}], "hash_salt": SALT + "x"})
+    assert json.loads(other_salt.node(_node({"email": "a@x.io"})).data)["email"] != first["email"]
+
+
+def test_node_type_and_relationship_rules():
+    """Test that node type rules replace the top-level rules and relationship rules apply to relationship data."""
+    transform = parse_transform({
+        "exclude": ["data.secret"],
+        "node_types": {"company": {"include": ["data.name"]}},
+        "relationships": {"redact": [{"path": "data.amount"}]},
+    })
+    assert json.loads(transform.node(_node({"name": "P", "secret": 1, "x": 2})).data) == {"name": "P", "x": 2}
+    company = transform.node(_node({"name": "C", "secret": 1, "x": 2}, node_type_id="company"))
+    assert json.loads(company.data) == {"name": "C"}
+    relationship = transform.relationship(Relationship(id="r1", data='{"amount": 10, "since": 2020}'))
+    assert json.loads(relationship.data) == {"amount": MASK, "since": 2020}
+
+
+def test_parse_transform_errors():
+    """Test that invalid transforms are rejected."""
+    assert parse_transform(None) is None
+    assert parse_transform({}) is None
+    for transform, message in [
+        ({"drop": ["data.a"]}, "unknown keys"),
+        ({"include": "data.a"}, "array"),
+        ({"exclude": ["name"]}, "paths such as"),
+        ({"exclude": ["data"]}, "paths such as"),
+        ({"relationships": {"exclude": ["metadata.a"]}}, "paths such as"),
+        ({"redact": [{"path": "data.a", "with": "encrypt"}]}, "with must be"),
+        ({"redact": [{"path": "data.a", "with": "value"}]}, "need a value"),
+        ({"redact": [{"path": "data.a", "with": "hash"}], "hash_salt": "short"}, "hash_salt"),
+        ({"node_types": {"t": {"include": ["data.a..b"]}}}, "paths such as"),
+    ]:
+        with pytest.raises(ValueError, match=message):
+            parse_transform(transform)
@@ -0,0 +1,113 @@
+"""
+Tests for typed data fields.
+"""
+
+import json
+
+import pytest
+
+from app.repository import (
+    ListOptions,
+    MemoryNodeRepository,
+    MemoryNodeTypeRepository,
+    MemoryRelationshipRepository,
+    MemoryRelationshipTypeRepository,
+    MemoryStore,
+)
+from app.service import NodeService, NodeTypeService
+from app.service.bulk_service import parse_node_filter
+from app.service.field_types import coerce_data, field_types
+
+SCHEMA = json.dumps({"properties": {
+    "due": {"type": "string", "format": "date"},
+    "closed_at": {"type": "string", "format": "date-time"},
+    "stats": {"properties": {"seats": {"type": "integer"}}},
+    "amount": {"type": "number"},
+    "status": {"enum": ["open", "won"]},
+    "owner_id": {"x-reference": True},
+}})
+
+
+def test_typed_fields_coerced():
+    """Test that declared fields are found through nested properties and coerced to their stored form."""
+    types = field_types(SCHEMA)
+    assert sorted(types) == ["amount", "closed_at", "due", "owner_id", "stats.seats", "status"]
+    data, references = coerce_data({
+        "due": "2024-03-01", "closed_at": "2024-03-01T10:30:00+01:00", "stats": {"seats": "12"},
+        "amount": "4.5", "status": "won", "owner_id": None, "other": "x",
+    }, types)
+    assert data == {
+        "due": "2024-03-01", "closed_at": "2024-03-01T09:30:00Z", "stats": {"seats": 12},
+        "amount": 4.5, "status": "won", "owner_id": None, "other": "x",
+    }
+    assert references == []
+    for bad in ({"due": "3/1/2024"}, {"stats": {"seats": "1.5"}}, {"amount": True}, {"status": "lost"},
+                {"owner_id": "not-an-id"}):
+        with pytest.raises(ValueError):
+            coerce_data(bad, types)
+    with pytest.raises(ValueError, match="x-reference"):
+        field_types(json.dumps({"properties": {"owner_id": {"x-reference": "Person"}}}))
+    assert field_types("not json") == {}
+
+
+@pytest.mark.asyncio
+async def test_typed_writes_and_range_filters():
+    """Test that writes store typed fields canonically, so range filters compare them correctly."""
+    store = MemoryStore()
+    node_repo = MemoryNodeRepository(store)
+    nodetype_repo = MemoryNodeTypeRepository(store)
+    node_service = NodeService(node_repo, nodetype_repo)
+    deal = await NodeTypeService(nodetype_repo).create("Deal", "", SCHEMA)
+
+    owner = await node_service.create(deal.id, '{}')
+    node = await node_service.create(deal.id, json.dumps({"amount": "250", "owner_id": owner.id}))
+    assert json.loads(node.data) == {"amount": 250, "owner_id": owner.id}
+    with pytest.raises(ValueError, match="missing node"):
+        await node_service.create(deal.id, json.dumps({"owner_id": "00000000-0000-0000-0000-000000000000"}))
+    with pytest.raises(ValueError, match="data.amount"):
+        await node_service.update(node.id, json.dumps({"amount": "lots"}))
+
+    for closed_at in ("2024-03-01T09:00:00+02:00", "2024-03-01T08:30:00Z", "2024-03-02T00:00:00Z"):
+        await node_service.create(deal.id, json.dumps({"closed_at": closed_at, "amount": 9}))
+    filters = parse_node_filter({"node_type_id": deal.id, "ranges": {
+        "closed_at": {"gte": "2024-03-01T07:30:00Z", "lt": "2024-03-02"},
+        "amount": {"lt": 100},
+    }})
+    nodes, _ = await node_repo.list(None, ListOptions(page_size=10), filters=filters)
+    assert [json.loads(n.data)["closed_at"] for n in nodes] == ["2024-03-01T08:30:00Z"]
+    with pytest.raises(ValueError, match="unknown bound"):
+        parse_node_filter({"ranges": {"amount": {"between": [1, 2]}}})
+
+
+@pytest.mark.asyncio
+async def test_reference_fields_kept_as_relationships():
+    """Test that reference fields with a relationship type are mirrored as relationships and cleared on delete."""
+    store = MemoryStore()
+    node_repo = MemoryNodeRepository(store)
+    nodetype_repo = MemoryNodeTypeRepository(store)
+    relationship_repo = MemoryRelationshipRepository(store)
+    node_service = NodeService(
+        node_repo, nodetype_repo, relationship_repo=relationship_repo,
+        rel_type_repo=MemoryRelationshipTypeRepository(store),
+    )
+    person = await NodeTypeService(nodetype_repo).create("Person", "", '{}')
+    task = await NodeTypeService(nodetype_repo).create("Task", "", json.dumps({"properties": {
+        "owner_id": {"x-reference": {"node_type_id": person.id, "relationship_type": "owned_by"}},
+    }}))
+    alice = await node_service.create(person.id, '{}')
+    bob = await node_service.create(person.id, '{}')
+
+    async def edges(node_id):
+        return [(r.relationship_type, r.target_node_id) for r in await relationship_repo.list_from_sources([node_id])]
+
+    node = await node_service.create(task.id, json.dumps({"owner_id": alice.id}))
+    assert await edges(node.id) == [("owned_by", alice.id)]
+    with pytest.raises(ValueError, match="of type"):
+        await node_service.update(node.id, json.dumps({"owner_id": node.id}))
+
+    await node_service.update(node.id, json.dumps({"owner_id": bob.id}))
+    assert await edges(node.id) == [("owned_by", bob.id)]
+
+    await node_service.delete(bob.id)
+    assert json.loads((await node_service.get_by_id(node.id)).data) == {}
+    assert await edges(node.id) == []
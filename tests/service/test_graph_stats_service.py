@@ -0,0 +1,49 @@
+"""
+Tests for GraphStatsService.
+"""
+
+import pytest
+
+from app.repository import GraphStatsRepository
+from app.service import GraphStatsService
+
+
+@pytest.fixture
+async def graph_stats_service(tenant_db) -> GraphStatsService:
+    return GraphStatsService(GraphStatsRepository(tenant_db))
+
+
+@pytest.mark.asyncio
+async def test_graph_stats(graph_stats_service, node_service, relationship_service, test_node_type):
+    """Test counts, degrees and components on a small graph."""
+    a = await node_service.create(test_node_type["id"], '{}')
+    b = await node_service.create(test_node_type["id"], '{}')
+    c = await node_service.create(test_node_type["id"], '{}')
+    await node_service.create(test_node_type["id"], '{}')  # isolated
+    await relationship_service.create(a.id, b.id, "links", '{}')
+    await relationship_service.create(a.id, c.id, "links", '{}')
+
+    stats = await graph_stats_service.get(refresh=True)
+
+    assert stats["node_count"] == 4
+    assert stats["relationship_count"] == 2
+    assert stats["relationships_by_type"] == [{"relationship_type": "links", "count": 2}]
+    assert stats["degree"]["max"] == 2
+    assert stats["degree"]["isolated_nodes"] == 1
+    assert stats["top_degree_nodes"][0]["node_id"] == a.id
+    assert stats["top_degree_nodes"][0]["out_degree"] == 2
+    assert stats["connected_components"] == {"count": 2, "largest_size": 3}
+    assert stats["stale"] is False
+
+
+@pytest.mark.asyncio
+async def test_graph_stats_cached(graph_stats_service, node_service, test_node_type):
+    """Test that a fresh snapshot is served from cache."""
+    await graph_stats_service.get(refresh=True)
+    await node_service.create(test_node_type["id"], '{}')
+
+    cached = await graph_stats_service.get()
+    assert cached["node_count"] == 0
+
+    refreshed = await graph_stats_service.get(refresh=True)
+    assert refreshed["node_count"] == 1
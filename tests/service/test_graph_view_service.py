@@ -0,0 +1,72 @@
+"""
+Tests for GraphViewService.
+"""
+
+import pytest
+
+from app.service.graph_view_service import LAYOUT_SPACING, _parse_paths, _positions
+
+
+def test_parse_paths():
+    """Test that label and data paths must be data paths."""
+    assert _parse_paths(["data.name", "data.contact.email"], "label_paths") == [["name"], ["contact", "email"]]
+    assert _parse_paths(None, "label_paths") == []
+    for bad in (["name"], ["data."], ["data.a..b"], [1], "data.name"):
+        with pytest.raises(ValueError, match="label_paths"):
+            _parse_paths(bad, "label_paths")
+
+
+def test_layout_positions():
+    """Test that layouts place nodes deterministically and concentric rings grow with depth."""
+    order = ["s", "a", "b", "c"]
+    depths = {"s": 0, "a": 1, "b": 1, "c": 2}
+
+    grid = _positions(order, depths, "grid")
+    assert grid == {"s": (0.0, 0.0), "a": (LAYOUT_SPACING, 0.0), "b": (0.0, LAYOUT_SPACING), "c": (LAYOUT_SPACING, LAYOUT_SPACING)}
+
+    concentric = _positions(order, depths, "concentric")
+    assert concentric["s"] == (0.0, 0.0)
+    radius = lambda id: (concentric[id][0] ** 2 + concentric[id][1] ** 2) ** 0.5
+    assert radius("a") == pytest.approx(radius("b"))
+    assert radius("c") > radius("a") > 0
+
+    circle = _positions(order, depths, "circle")
+    assert len({round((x ** 2 + y ** 2) ** 0.5, 1) for x, y in circle.values()}) == 1
+    assert circle == _positions(order, depths, "circle")
+
+
+@pytest.mark.asyncio
+async def test_graph_view_formats(graph_view_service, node_service, nodetype_service, relationship_service):
+    """Test that views label nodes from their paths and render Cytoscape and D3 payloads."""
+    person = await nodetype_service.create("Person", "", '{}')
+    company = await nodetype_service.create("Company", "", '{}')
+    ada = await node_service.create(person.id, '{"name": "Ada", "role": "engineer"}')
+    bob = await node_service.create(person.id, '{"role": "manager"}')
+    acme = await node_service.create(company.id, '{"name": "acme", "legal_name": "Acme Corp"}')
+    knows = await relationship_service.create(ada.id, bob.id, "knows", '{}')
+    await relationship_service.create(ada.id, acme.id, "works_at", '{}')
+
+    view = await graph_view_service.view(
+        [ada.id], label_paths=["data.name"], label_paths_by_type={"Company": ["data.legal_name"]},
+        data_paths=["data.role"], layout="concentric",
+    )
+    nodes = {n["data"]["id"]: n for n in view["elements"]["nodes"]}
+    assert nodes[ada.id]["data"]["label"] == "Ada"
+    assert nodes[ada.id]["data"]["seed"] and nodes[ada.id]["position"] == {"x": 0.0, "y": 0.0}
+    assert nodes[ada.id]["data"]["properties"] == {"data.role": "engineer"}
+    assert nodes[bob.id]["data"]["label"] == "Person"
+    assert nodes[acme.id]["data"]["label"] == "Acme Corp"
+    assert nodes[acme.id]["data"]["type"] == "Company"
+    edge = next(e["data"] for e in view["elements"]["edges"] if e["data"]["id"] == knows.id)
+    assert (edge["source"], edge["target"], edge["label"]) == (ada.id, bob.id, "knows")
+    assert not view["truncated"]
+
+    view = await graph_view_service.view(seed_filter={"node_type_id": company.id}, format="d3")
+    assert [n["id"] for n in view["nodes"]][0] == acme.id
+    assert "x" not in view["nodes"][0]
+    assert {(l["source"], l["target"]) for l in view["links"]} >= {(ada.id, acme.id)}
+
+    with pytest.raises(ValueError, match="exactly one"):
+        await graph_view_service.view([ada.id], seed_filter={"node_type_id": company.id})
+    with pytest.raises(ValueError, match="format"):
+        await graph_view_service.view([ada.id], format="graphml")
@@ -0,0 +1,77 @@
+"""
+Tests for ImpersonationService and the impersonation interceptor.
+"""
+
+import uuid
+from datetime import datetime, timedelta, timezone
+
+import pytest
+from jsonrpcserver import Success
+
+from app.authz.impersonation import impersonation_interceptor
+from app.jsonrpc.context import RequestContext
+from app.jsonrpc.interceptors import RpcCall
+from app.repository.errors import PermissionDeniedError
+
+
+async def _tenant(tenant_service):
+    return await tenant_service.create(f"imp-{uuid.uuid4().hex[:8]}", "Impersonated")
+
+
+@pytest.mark.asyncio
+async def test_start_impersonation_requires_admin(impersonation_service, tenant_service, admin_user_id):
+    """Test that only configured administrators can impersonate."""
+    tenant = await _tenant(tenant_service)
+
+    with pytest.raises(PermissionDeniedError):
+        await impersonation_service.start(str(uuid.uuid4()), tenant.id, "debugging")
+    with pytest.raises(ValueError, match="reason is required"):
+        await impersonation_service.start(admin_user_id, tenant.id, "")
+
+
+@pytest.mark.asyncio
+async def test_resolve_impersonation_token(impersonation_service, tenant_service, admin_user_id):
+    """Test that tokens resolve until they expire or are revoked."""
+    tenant = await _tenant(tenant_service)
+    token, grant = await impersonation_service.start(admin_user_id, tenant.id, "ticket 1234", ttl_seconds=60)
+
+    resolved = await impersonation_service.resolve(token)
+    assert resolved.id == grant.id
+    assert resolved.tenant_id == tenant.id
+
+    with pytest.raises(PermissionDeniedError, match="expired"):
+        await impersonation_service.resolve(token, now=datetime.now(timezone.utc) + timedelta(seconds=61))
+
+    await impersonation_service.end(grant.id, admin_user_id)
+    with pytest.raises(PermissionDeniedError, match="revoked"):
+        await impersonation_service.resolve(token)
+    with pytest.raises(PermissionDeniedError):
+        await impersonation_service.resolve("imp_not-a-token")
+
+
+@pytest.mark.asyncio
+async def test_impersonated_calls_are_audited(impersonation_service, audit_service, tenant_service, admin_user_id):
+    """Test that impersonated calls are scoped to the tenant and flagged in the audit log."""
+    tenant = await _tenant(tenant_service)
+    other = await _tenant(tenant_service)
+    token, grant = await impersonation_service.start(admin_user_id, tenant.id, "ticket 1234")
+    interceptor = impersonation_interceptor(impersonation_service, audit_service)
+
+    seen = []
+
+    async def call_next(call):
+        seen.append(call.context.subject_id)
+        return Success({})
+
+    ctx = RequestContext.from_headers({"X-Impersonation-Token": token})
+    await interceptor(RpcCall("list_nodes", {"tenant_id": tenant.id}, ctx), call_next)
+    await interceptor(RpcCall("list_nodes", {"tenant_id": other.id}, ctx), call_next)
+
+    assert seen == [admin_user_id]
+
+    events, result = await audit_service.list(10, "", tenant.id, impersonated_only=True)
+    methods = [(e.method, e.outcome) for e in events]
+    assert ("list_nodes", "ok") in methods
+    assert ("list_nodes", "error") in methods
+    assert ("start_impersonation", "ok") in methods
+    assert all(e.to_dict()["impersonated"] and e.impersonated_by == admin_user_id for e in events)
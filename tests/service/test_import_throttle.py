@@ -0,0 +1,139 @@
+"""
+Tests for import concurrency limits, backpressure and pausing.
+"""
+
+import asyncio
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from app.repository import OperationRepository
+from app.repository.errors import PreconditionFailedError
+from app.service.import_throttle import MIN_DELAY_SECONDS, WARMUP_WRITES, ImportPacer, ImportThrottle
+from app.service.limits import TenantLimits
+from app.service.operation_service import OperationService
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 0.0
+        self.slept = []
+
+    def __call__(self) -> float:
+        return self.now
+
+    async def sleep(self, seconds: float) -> None:
+        self.slept.append(seconds)
+        self.now += seconds
+
+
+def _pacer(clock, max_delay=1.0, paused=None):
+    async def is_paused():
+        return bool(paused and paused.pop(0))
+
+    return ImportPacer("import_nodes", max_delay, is_paused, clock, clock.sleep)
+
+
+def test_pacer_backs_off_while_latency_is_raised():
+    """Test that the delay doubles while writes are slow and halves once they recover."""
+    pacer = _pacer(FakeClock(), max_delay=0.1)
+    for _ in range(WARMUP_WRITES):
+        pacer.observe(0.01)
+    assert pacer.delay == 0
+
+    delays = []
+    for _ in range(6):
+        pacer.observe(0.1)
+        delays.append(pacer.delay)
+    assert delays[0] == MIN_DELAY_SECONDS
+    assert delays == sorted(delays) and delays[-1] == 0.1
+
+    for _ in range(20):
+        pacer.observe(0.01)
+    assert pacer.delay == 0
+    assert pacer.baseline < 0.02
+
+
+def test_pacer_without_max_delay_never_waits():
+    """Test that a max delay of 0 disables backpressure."""
+    pacer = _pacer(FakeClock(), max_delay=0)
+    for seconds in [0.01] * WARMUP_WRITES + [1.0] * 10:
+        pacer.observe(seconds)
+    assert pacer.delay == 0
+
+
+@pytest.mark.asyncio
+async def test_pacer_holds_writes_while_paused():
+    """Test that a paused import waits until it is resumed and is checked at most once a second."""
+    clock = FakeClock()
+    pacer = _pacer(clock, paused=[True, True, True, False])
+    clock.now = 5.0
+    async with pacer.write():
+        pass
+    assert clock.slept == [1.0, 1.0]
+
+    async with pacer.write():
+        pass
+    assert clock.slept == [1.0, 1.0]
+
+
+@pytest.mark.asyncio
+async def test_throttle_limits_imports_per_tenant_and_server():
+    """Test that imports beyond the tenant or server limit wait for a slot, then time out."""
+    throttle = ImportThrottle(max_concurrent=2, queue_timeout_seconds=0.05)
+    async with throttle.admit("a", 1):
+        with pytest.raises(PreconditionFailedError):
+            async with throttle.admit("a", 1):
+                pass
+        async with throttle.admit("b", 2):
+            assert throttle.running() == 2
+            with pytest.raises(PreconditionFailedError):
+                async with throttle.admit("c", 2):
+                    pass
+    assert throttle.running() == 0
+
+    admitted = []
+
+    async def waiting():
+        async with throttle.admit("a", 1):
+            admitted.append(True)
+
+    async with throttle.admit("a", 1):
+        task = asyncio.ensure_future(waiting())
+        await asyncio.sleep(0.01)
+        assert not admitted
+    await task
+    assert admitted == [True]
+
+
+def test_max_concurrent_imports_limit():
+    """Test that max_concurrent_imports is validated against its ceiling."""
+    assert TenantLimits.from_overrides({"max_concurrent_imports": 4}).max_concurrent_imports == 4
+    with pytest.raises(ValueError):
+        TenantLimits.from_overrides({"max_concurrent_imports": 0})
+    with pytest.raises(ValueError):
+        TenantLimits.from_overrides({"max_concurrent_imports": 17})
+
+
+@pytest.mark.asyncio
+async def test_pause_and_resume_import(tenant_db):
+    """Test that only running imports can be paused and only paused ones resumed."""
+    repo = OperationRepository(tenant_db)
+    service = OperationService(repo)
+    kinds = ["import_nodes", "import_relationships"]
+    op = await service.prepare("import_nodes", {}, 0)
+
+    with pytest.raises(PreconditionFailedError):
+        await service.pause(op.id, kinds)
+    await repo.confirm(op.id, "import_nodes", 0, datetime.now(timezone.utc) - timedelta(minutes=1))
+
+    assert (await service.pause(op.id, kinds)).status == "paused"
+    assert await service.is_paused(op.id)
+    with pytest.raises(PreconditionFailedError):
+        await service.pause(op.id, kinds)
+    assert (await service.resume(op.id, kinds)).status == "running"
+    assert not await service.is_paused(op.id)
+
+    other = await service.start("delete_nodes", {}, 0, lambda progress: asyncio.sleep(0))
+    with pytest.raises(PreconditionFailedError):
+        await service.pause(other.id, kinds)
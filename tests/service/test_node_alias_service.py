@@ -0,0 +1,38 @@
+"""
+Tests for NodeAliasService.
+"""
+
+import pytest
+
+from app.repository.errors import NotFoundError
+
+
+@pytest.mark.asyncio
+async def test_node_aliases(node_alias_service, node_service, test_node_type):
+    """Test setting, merging, looking up and removing node aliases."""
+    alice = await node_service.create(test_node_type["id"], '{"title": "Alice"}')
+    bob = await node_service.create(test_node_type["id"], '{"title": "Bob"}')
+
+    aliases = await node_alias_service.set(alice.id, {"email": "alice@example.com", "legacy_id": "C-1"})
+    assert aliases == {"email": "alice@example.com", "legacy_id": "C-1"}
+    aliases = await node_alias_service.set(alice.id, {"legacy_id": None, "slug": "alice"})
+    assert aliases == {"email": "alice@example.com", "slug": "alice"}
+
+    found = await node_alias_service.lookup("email", "alice@example.com")
+    assert found.id == alice.id
+    with pytest.raises(NotFoundError):
+        await node_alias_service.lookup("email", "bob@example.com")
+
+    with pytest.raises(ValueError, match="already used"):
+        await node_alias_service.set(bob.id, {"email": "alice@example.com"})
+    # The same value under another name is a different alias
+    assert await node_alias_service.set(bob.id, {"slug2": "alice"}) == {"slug2": "alice"}
+
+    with pytest.raises(ValueError, match="invalid alias name"):
+        await node_alias_service.set(bob.id, {"Email": "x"})
+    with pytest.raises(NotFoundError):
+        await node_alias_service.set("00000000-0000-0000-0000-000000000000", {"email": "x"})
+
+    await node_service.delete(alice.id)
+    with pytest.raises(NotFoundError):
+        await node_alias_service.lookup("email", "alice@example.com")
@@ -0,0 +1,84 @@
+"""
+Tests for bi-temporal node history.
+"""
+
+import asyncio
+import json
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from app.repository.errors import NotFoundError
+
+
+def _iso(ts: datetime) -> str:
+    return ts.isoformat()
+
+
+@pytest.mark.asyncio
+async def test_update_keeps_previous_version(node_service, nodetype_service):
+    """Test that an update closes the previous valid-time version."""
+    node_type = await nodetype_service.create("Account", "", '{}')
+    node = await node_service.create(node_type.id, '{"balance": 100}')
+    after_create = datetime.now(timezone.utc)
+    await asyncio.sleep(0.01)
+
+    await node_service.update(node.id, '{"balance": 150}')
+
+    then = await node_service.get_as_of(node.id, valid_at=_iso(after_create))
+    now = await node_service.get_as_of(node.id)
+    assert json.loads(then.data) == {"balance": 100}
+    assert json.loads(now.data) == {"balance": 150}
+
+
+@pytest.mark.asyncio
+async def test_correction_is_visible_only_after_recording(node_service, nodetype_service):
+    """Test querying a valid time as recorded before and after a correction."""
+    node_type = await nodetype_service.create("Account", "", '{}')
+    start = datetime.now(timezone.utc) - timedelta(days=30)
+    node = await node_service.create(node_type.id, '{"balance": 100}', valid_from=_iso(start))
+
+    before_correction = datetime.now(timezone.utc)
+    await asyncio.sleep(0.01)
+    quarter_end = start + timedelta(days=10)
+    await node_service.correct(
+        node.id, '{"balance": 90}', _iso(start), _iso(quarter_end)
+    )
+
+    valid_at = _iso(start + timedelta(days=5))
+    as_recorded = await node_service.get_as_of(node.id, valid_at, _iso(before_correction))
+    corrected = await node_service.get_as_of(node.id, valid_at)
+    assert json.loads(as_recorded.data) == {"balance": 100}
+    assert json.loads(corrected.data) == {"balance": 90}
+
+    # The current value is outside the corrected interval and is unchanged
+    current = await node_service.get_by_id(node.id)
+    assert json.loads(current.data) == {"balance": 100}
+
+
+@pytest.mark.asyncio
+async def test_deleted_node_history(node_service, nodetype_service):
+    """Test that deleting a node ends its validity but keeps its history."""
+    node_type = await nodetype_service.create("Account", "", '{}')
+    node = await node_service.create(node_type.id, '{"balance": 1}')
+    before_delete = datetime.now(timezone.utc)
+    await asyncio.sleep(0.01)
+
+    await node_service.delete(node.id)
+
+    with pytest.raises(NotFoundError):
+        await node_service.get_as_of(node.id)
+    version = await node_service.get_as_of(node.id, valid_at=_iso(before_delete))
+    assert json.loads(version.data) == {"balance": 1}
+
+    versions, _ = await node_service.history(node.id, 10, "")
+    assert len(versions) >= 2
+
+
+@pytest.mark.asyncio
+async def test_future_valid_from_rejected(node_service, nodetype_service):
+    """Test that regular writes cannot be future-dated."""
+    node_type = await nodetype_service.create("Account", "", '{}')
+    future = _iso(datetime.now(timezone.utc) + timedelta(days=1))
+    with pytest.raises(ValueError, match="cannot be in the future"):
+        await node_service.create(node_type.id, '{}', valid_from=future)
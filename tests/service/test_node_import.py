@@ -0,0 +1,95 @@
+"""
+Tests for NodeImportService and CSV mapping.
+"""
+
+import json
+
+import pytest
+
+from app.service.csv_records import csv_records
+from app.service.node_import import check_mapping, map_record, read_header
+
+
+async def _chunks(text, size=16):
+    body = text.encode()
+    for i in range(0, len(body), size):
+        yield body[i:i + size]
+
+
+@pytest.mark.asyncio
+async def test_csv_records():
+    """Test that records are split across chunks, with quoted line breaks and line numbers."""
+    text = '﻿Name,Note\n\nAda,"Wrote the ""first"" program,\nin 1843"\r\nAlan,plain'
+    records = [r async for r in csv_records(_chunks(text, 7), ",")]
+    assert records == [
+        (1, ["Name", "Note"]),
+        (3, ["Ada", 'Wrote the "first" program,\nin 1843']),
+        (5, ["Alan", "plain"]),
+    ]
+
+
+def test_map_record():
+    """Test that cells are converted and placed at their paths and fields."""
+    mapping = check_mapping({"fields": [
+        {"column": "ID", "field": "id"},
+        {"column": "Email", "path": "contact.email", "required": True},
+        {"column": "Age", "path": "age", "type": "integer"},
+        {"column": "Active", "path": "active", "type": "boolean"},
+        {"column": "Tags", "path": "tags", "type": "json", "default": []},
+        {"column": "Source", "field": "metadata.source"},
+        {"value": "crm", "path": "origin"},
+    ]})
+    header = read_header(mapping, ["ID", "Email", "Age", "Active", "Tags", "Source", "Ignored"])
+
+    row = map_record(mapping, header, [
+        "6f1c2a7e-3d4b-4f0a-9a51-2b8e7c9d0e13", "ada@example.com", " 36 ", "yes", "", "web", "x"
+    ])
+    assert row.id == "6f1c2a7e-3d4b-4f0a-9a51-2b8e7c9d0e13"
+    assert row.data == {"contact": {"email": "ada@example.com"}, "age": 36, "active": True, "tags": [], "origin": "crm"}
+    assert row.metadata == {"source": "web"}
+
+    with pytest.raises(ValueError, match="Email is required"):
+        map_record(mapping, header, ["", "", "1"])
+    with pytest.raises(ValueError, match="not an integer"):
+        map_record(mapping, header, ["", "a@example.com", "old"])
+    with pytest.raises(ValueError, match="lacks mapped columns"):
+        read_header(mapping, ["ID", "Email"])
+    with pytest.raises(ValueError, match="more than once"):
+        check_mapping({"fields": [{"column": "A", "path": "x"}, {"value": 1, "path": "x"}]})
+    with pytest.raises(ValueError):
+        check_mapping({"fields": [{"column": "A", "field": "node_type_id"}]})
+
+
+@pytest.mark.asyncio
+async def test_node_import(node_import_service, nodetype_service, node_service, write_hook_service):
+    """Test that CSV rows are imported as nodes and failed rows are reported."""
+    person = await nodetype_service.create("Person", "", '{}')
+    await write_hook_service.create("adults", 'data.age >= 18 ? true : "too young"', node_type_id=person.id)
+    mapping = {"fields": [
+        {"column": "name", "path": "name"},
+        {"column": "age", "path": "age", "type": "integer"},
+    ]}
+    text = "name,age\nAda,36\nAlan,unknown\nKid,9\nGrace,85\n"
+
+    columns, rows = await node_import_service.preview(person.id, mapping, text, limit=3)
+    assert columns == ["name", "age"]
+    assert rows[0] == {"line": 2, "node": {"id": "", "data": {"name": "Ada", "age": 36}, "metadata": {}, "valid_from": ""}}
+    assert "not an integer" in rows[1]["error"]
+    assert "too young" in rows[2]["error"]
+    assert len(rows) == 3
+
+    op = await node_import_service.begin(person.id, mapping)
+    op, failures = await node_import_service.ingest(op.id, _chunks(text))
+    assert op.status == "completed"
+    assert op.affected_count == 2
+    assert [f.line for f in failures] == [3, 4]
+
+    nodes, _ = await node_service.list(person.id, 10, "")
+    assert sorted(json.loads(n.data)["name"] for n in nodes) == ["Ada", "Grace"]
+
+    # A header without the mapped columns fails the import
+    op = await node_import_service.begin(person.id, mapping)
+    op, _ = await node_import_service.ingest(op.id, _chunks("full_name,age\nAda,36\n"))
+    assert op.status == "failed"
+    with pytest.raises(ValueError):
+        await node_import_service.begin(person.id, {"fields": []})
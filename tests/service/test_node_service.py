@@ -2,9 +2,13 @@
 Tests for NodeService.
 """
 
+import asyncio
+import json
+import uuid
+
 import pytest
 
-from app.repository.errors import NotFoundError
+from app.repository.errors import AlreadyExistsError, NotFoundError, PreconditionFailedError
 
 
 @pytest.mark.asyncio
@@ -36,6 +40,24 @@ async def test_create_node_invalid_node_type(node_service):
         await node_service.create(non_existent_id, '{}')
 
 
+@pytest.mark.asyncio
+async def test_create_node_with_client_id(node_service, nodetype_service):
+    """Test that a client-generated ID is used and cannot be taken twice, even after deletion."""
+    node_type = await nodetype_service.create("Article", "Blog article", '{}')
+    id = str(uuid.uuid4())
+
+    node = await node_service.create(node_type.id, '{}', id=id.upper())
+    assert node.id == id
+    with pytest.raises(AlreadyExistsError):
+        await node_service.create(node_type.id, '{}', id=id)
+
+    await node_service.delete(id)
+    with pytest.raises(AlreadyExistsError):
+        await node_service.create(node_type.id, '{}', id=id)
+    with pytest.raises(ValueError, match="id must be a UUID"):
+        await node_service.create(node_type.id, '{}', id="not-a-uuid")
+
+
 @pytest.mark.asyncio
 async def test_get_node_by_id(node_service, nodetype_service):
     """Test retrieving a node by ID."""
@@ -107,3 +129,145 @@ async def test_list_nodes_filtered_by_type(node_service, nodetype_service):
     assert len(nodes) == 3
     assert all(n.node_type_id == node_type1.id for n in nodes)
 
+
+
+@pytest.mark.asyncio
+async def test_distinct_values_by_node_type(node_service, nodetype_service):
+    """Test counting nodes per node type."""
+    article = await nodetype_service.create("Article", "", '{}')
+    comment = await nodetype_service.create("Comment", "", '{}')
+    for _ in range(2):
+        await node_service.create(article.id, '{}')
+    await node_service.create(comment.id, '{}')
+
+    values = await node_service.distinct_values("node_type_id", None, 10)
+
+    assert [(v.value, v.count) for v in values] == [(article.id, 2), (comment.id, 1)]
+
+
+@pytest.mark.asyncio
+async def test_distinct_values_by_data_path(node_service, nodetype_service):
+    """Test counting nodes per distinct JSON data value."""
+    node_type = await nodetype_service.create("Task", "", '{}')
+    for status in ("open", "open", "closed"):
+        await node_service.create(node_type.id, f'{{"status": "{status}"}}')
+    await node_service.create(node_type.id, '{}')
+
+    values = await node_service.distinct_values("data.status", node_type.id, 10)
+
+    assert [(v.value, v.count) for v in values] == [("open", 2), ("closed", 1)]
+
+
+@pytest.mark.asyncio
+async def test_distinct_values_invalid_field(node_service):
+    """Test that unknown fields raise ValueError."""
+    with pytest.raises(ValueError):
+        await node_service.distinct_values("created_at", None, 10)
+
+
+@pytest.mark.asyncio
+async def test_update_node_if_match(node_service, test_node):
+    """Test that conditional updates apply only while the etag still matches."""
+    etag = test_node["etag"]
+
+    updated = await node_service.update(test_node["id"], '{"title": "First"}', if_match=etag)
+    assert updated.etag != etag
+
+    # A second writer holding the old etag is rejected instead of overwriting
+    with pytest.raises(PreconditionFailedError):
+        await node_service.update(test_node["id"], '{"title": "Second"}', if_match=etag)
+
+    current = await node_service.get_by_id(test_node["id"])
+    assert current.etag == updated.etag
+
+
+@pytest.mark.asyncio
+async def test_patch_node(node_service, nodetype_service):
+    """Test applying JSON patches and merge patches to node data."""
+    node_type = await nodetype_service.create("Article", "", '{}')
+    node = await node_service.create(node_type.id, '{"title": "Draft", "meta": {"tags": ["a"], "views": 1}}')
+
+    patched = await node_service.patch(node.id, [
+        {"op": "add", "path": "/meta/tags/-", "value": "b"},
+        {"op": "remove", "path": "/meta/views"},
+    ])
+    assert json.loads(patched.data) == {"title": "Draft", "meta": {"tags": ["a", "b"]}}
+
+    patched = await node_service.patch(node.id, {"title": "Final"})
+    assert json.loads(patched.data)["title"] == "Final"
+
+    # A failed test operation leaves the node unchanged
+    with pytest.raises(ValueError, match="test failed"):
+        await node_service.patch(node.id, [
+            {"op": "test", "path": "/title", "value": "Draft"},
+            {"op": "replace", "path": "/title", "value": "Other"},
+        ])
+    assert (await node_service.get_by_id(node.id)).etag == patched.etag
+
+
+@pytest.mark.asyncio
+async def test_node_metadata(node_service, nodetype_service):
+    """Test that metadata updates are independent of the node data."""
+    node_type = await nodetype_service.create("Contact", "", '{}')
+    node = await node_service.create(node_type.id, '{"name": "Ada"}', metadata={"source": "crm"})
+    assert node.metadata == {"source": "crm"}
+
+    # Metadata-only updates leave the data and etag alone
+    updated = await node_service.update(node.id, "", metadata={"cursor": "c1", "source": None})
+    assert updated.metadata == {"cursor": "c1"}
+    assert updated.etag == node.etag
+    assert json.loads(updated.data) == {"name": "Ada"}
+
+    # Only masked paths are written
+    updated = await node_service.update(
+        node.id, '{"name": "Grace"}', metadata={"cursor": "c2", "other": "x"},
+        update_mask=["metadata.cursor"]
+    )
+    assert updated.metadata == {"cursor": "c2"}
+    assert json.loads(updated.data) == {"name": "Ada"}
+
+    updated = await node_service.update(node.id, '{"name": "Grace"}', update_mask=["data", "metadata"])
+    assert updated.metadata == {}
+    assert json.loads(updated.data) == {"name": "Grace"}
+
+    with pytest.raises(ValueError, match="unknown update_mask path"):
+        await node_service.update(node.id, "", update_mask=["name"])
+
+
+@pytest.mark.asyncio
+async def test_increment_node_field(node_service, nodetype_service):
+    """Test that concurrent increments of a data field are not lost."""
+    node_type = await nodetype_service.create("Post", "", '{}')
+    node = await node_service.create(node_type.id, '{"title": "Hello"}')
+
+    await asyncio.gather(*(node_service.increment_field(node.id, "data.stats.views") for _ in range(10)))
+    updated, value = await node_service.increment_field(node.id, "data.stats.views", -2.5)
+
+    assert value == 7.5
+    assert json.loads(updated.data) == {"title": "Hello", "stats": {"views": 7.5}}
+    assert updated.etag != node.etag
+
+    with pytest.raises(ValueError, match="not a number"):
+        await node_service.increment_field(node.id, "data.title")
+    with pytest.raises(ValueError):
+        await node_service.increment_field(node.id, "title")
+
+
+@pytest.mark.asyncio
+async def test_protected_node_is_only_deleted_with_force(node_service, nodetype_service):
+    """Test that deleting a protected node takes force until its protection is removed."""
+    node_type = await nodetype_service.create("Post", "", '{}')
+    node = await node_service.create(node_type.id, '{"title": "Demo"}')
+    await node_service.set_protected(node.id, True)
+    assert await node_service.is_protected(node.id)
+
+    with pytest.raises(PreconditionFailedError, match="protected"):
+        await node_service.delete(node.id)
+    await node_service.get_by_id(node.id)
+
+    await node_service.set_protected(node.id, False)
+    assert not await node_service.is_protected(node.id)
+    await node_service.set_protected(node.id, True)
+    assert await node_service.delete(node.id, force=True) == [node.id]
+    with pytest.raises(NotFoundError):
+        await node_service.set_protected(node.id, True)
@@ -0,0 +1,68 @@
+"""
+Tests for node views.
+"""
+
+import json
+
+import pytest
+
+from app.repository import AlreadyExistsError, NodeRepository, NodeTypeRepository, NodeViewRepository, NotFoundError
+from app.repository.models import ListOptions, Node, NodeType
+from app.service.bulk_service import parse_node_filter
+from app.service.node_view_service import NodeViewService, combine_filters
+
+
+def test_combine_filters_narrows_the_view():
+    """Test that a call's filter keys narrow a view's filter and cannot contradict it."""
+    view_filter = {"node_type_id": "t1", "data": {"status": "active"}}
+    assert combine_filters(view_filter, {"node_type_id": "", "metadata": None}) == view_filter
+    assert combine_filters(view_filter, {"node_type_id": "t1", "data": {"region": "eu"}}) == {
+        "node_type_id": "t1", "data": {"status": "active", "region": "eu"},
+    }
+    with pytest.raises(ValueError, match="node_type_id"):
+        combine_filters(view_filter, {"node_type_id": "t2"})
+    with pytest.raises(ValueError, match="data.status"):
+        combine_filters(view_filter, {"data": {"status": "closed"}})
+
+
+@pytest.mark.asyncio
+async def test_node_views(tenant_db):
+    """Test defining views and listing their nodes."""
+    node_type = await NodeTypeRepository(tenant_db).create(NodeType(name="Customer", schema='{}'))
+    nodes = NodeRepository(tenant_db)
+    for status, region in [("active", "eu"), ("active", "us"), ("closed", "eu")]:
+        await nodes.create(Node(node_type_id=node_type.id, data=json.dumps({"status": status, "region": region})))
+
+    service = NodeViewService(NodeViewRepository(tenant_db))
+    view = await service.create(
+        "active-customers",
+        {"node_type_id": node_type.id, "data": {"status": "active", "region": "$region"}},
+        {"include": ["data.region"]},
+        {"region": "eu"},
+    )
+    with pytest.raises(AlreadyExistsError):
+        await service.create("active-customers")
+    with pytest.raises(ValueError, match="declared"):
+        await service.create("other", {"data": {"region": "$region"}})
+    with pytest.raises(ValueError, match="unknown filter keys"):
+        await service.create("other", {"kind": "x"})
+
+    resolved = await service.resolve("active-customers", {"region": "us"})
+    found, result = await nodes.list(None, ListOptions(page_size=10, page_token=""), filters=resolved.node_filter())
+    assert [json.loads(n.data)["region"] for n in found] == ["us"]
+    assert result.total_count == 1
+    resolved = await service.resolve("active-customers")
+    narrowed = parse_node_filter(combine_filters(resolved.filter, {"node_type_id": node_type.id}))
+    found, _ = await nodes.list(None, ListOptions(page_size=10, page_token=""), filters=narrowed)
+    assert [json.loads(n.data)["region"] for n in found] == ["eu"]
+    with pytest.raises(ValueError, match="unknown parameters"):
+        await service.resolve("active-customers", {"status": "closed"})
+    with pytest.raises(NotFoundError):
+        await service.resolve("nope")
+
+    view = await service.update(view.id, description="Active customers")
+    views, _ = await service.list(0, "")
+    assert [v.description for v in views] == ["Active customers"]
+    await service.delete(view.id)
+    with pytest.raises(NotFoundError):
+        await service.get_by_id(view.id)
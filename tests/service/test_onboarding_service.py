@@ -0,0 +1,72 @@
+"""
+Tests for tenant onboarding.
+"""
+
+import uuid
+
+import pytest
+
+from app.repository import ApiKeyRepository, PermissionDeniedError
+from app.service import ApiKeyService, OnboardingService
+
+
+@pytest.fixture
+def onboarding_service(clean_control_db, tenant_service, template_service, admin_user_id):
+    api_keys = ApiKeyService(ApiKeyRepository(clean_control_db), lambda subject_id: subject_id == admin_user_id)
+    return OnboardingService(tenant_service, api_keys, template_service.tenant_services)
+
+
+@pytest.mark.asyncio
+async def test_provision_tenant(onboarding_service, template_service, admin_user_id):
+    """Test that a tenant is created from a template with an admin key limited to it and hooks on template types."""
+    await template_service.create("crm", {"node_types": [{"name": "Contact"}]})
+
+    provisioned = await onboarding_service.provision(
+        admin_user_id, f"acme-{uuid.uuid4().hex[:8]}", "Acme", from_template="crm",
+        api_key={"name": "pipeline"},
+        write_hooks=[{"name": "require-email", "node_type": "Contact", "expression": "has(data.email)"}],
+    )
+
+    tenant = provisioned.tenant
+    assert provisioned.key.startswith("fdb_")
+    assert (provisioned.api_key.name, provisioned.api_key.scope) == ("pipeline", "admin")
+    assert provisioned.api_key.tenant_ids == [tenant.id]
+    node_types, _ = await (await template_service.tenant_services(tenant.id))["node_type"].list(0, "")
+    assert provisioned.write_hooks[0].node_type_id == node_types[0].id
+
+
+@pytest.mark.asyncio
+async def test_failed_provisioning_is_undone(onboarding_service, tenant_service):
+    """Test that a failing step revokes the key and removes the tenant."""
+    caller = str(uuid.uuid4())
+    slug = f"acme-{uuid.uuid4().hex[:8]}"
+
+    with pytest.raises(ValueError, match=r"write_hooks\[1\]"):
+        await onboarding_service.provision(caller, slug, "Acme", api_key={"scope": "write"}, write_hooks=[
+            {"name": "require-title", "expression": "has(data.title)"},
+            {"name": "broken", "expression": "has(("},
+        ])
+
+    assert (await tenant_service.check_slug_availability(slug))[0]
+    keys, _ = await onboarding_service.api_key_service.list(caller, "", 10, "")
+    assert [k.revoked_at is not None for k in keys] == [True]
+
+
+@pytest.mark.asyncio
+async def test_provision_needs_an_identified_caller(onboarding_service, tenant_service):
+    """Test that a caller no credential identified (admin_subject_id() == "") cannot provision tenants."""
+    slug = f"acme-{uuid.uuid4().hex[:8]}"
+    with pytest.raises(PermissionDeniedError):
+        await onboarding_service.provision("", slug, "Acme")
+    assert (await tenant_service.check_slug_availability(slug))[0]
+
+
+@pytest.mark.asyncio
+async def test_provision_rejects_unknown_options(onboarding_service, tenant_service):
+    """Test that invalid options fail before anything is created."""
+    slug = f"acme-{uuid.uuid4().hex[:8]}"
+    with pytest.raises(ValueError, match="unknown keys: color"):
+        await onboarding_service.provision(str(uuid.uuid4()), slug, "Acme", write_hooks=[{"color": "red"}])
+    with pytest.raises(ValueError, match="api_key.scope"):
+        await onboarding_service.provision(str(uuid.uuid4()), slug, "Acme", api_key={"scope": "root"})
+    assert (await tenant_service.check_slug_availability(slug))[0]
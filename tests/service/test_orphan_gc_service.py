@@ -0,0 +1,42 @@
+"""
+Tests for orphaned relationship collection.
+"""
+
+import pytest
+
+from app.repository import NotFoundError, OperationRepository
+from app.service.operation_service import OperationService
+from app.service.orphan_gc_service import OrphanCollectionService
+
+
+@pytest.mark.asyncio
+async def test_collect_orphans(tenant_db, nodetype_service, node_service, relationship_repo, relationship_service):
+    """Test that relationships with a missing endpoint are reported, then deleted unless report-only."""
+    node_type = await nodetype_service.create("Person", "", "")
+    alice = await node_service.create(node_type.id, "{}")
+    bob = await node_service.create(node_type.id, "{}")
+    carol = await node_service.create(node_type.id, "{}")
+    kept = await relationship_service.create(alice.id, bob.id, "knows", "{}")
+    orphan = await relationship_service.create(alice.id, carol.id, "knows", "{}")
+
+    # Tables created before integrity checks had no foreign keys to cascade deletes
+    async with tenant_db.pool.acquire() as conn:
+        await conn.execute("ALTER TABLE relationships DROP CONSTRAINT relationships_target_node_id_fkey")
+        await conn.execute("DELETE FROM nodes WHERE id = $1", carol.id)
+
+    service = OrphanCollectionService(relationship_repo, OperationService(OperationRepository(tenant_db)))
+    op = await service.collect(dry_run=True)
+    assert op.status == "completed"
+    assert (op.processed_count, op.affected_count) == (2, 1)
+    assert op.result["orphan_count"] == 1
+    assert op.result["deleted_count"] == 0
+    assert op.result["by_relationship_type"] == {"knows": 1}
+    assert op.result["sample"][0]["id"] == orphan.id
+    assert op.result["sample"][0]["missing"] == ["target"]
+    await relationship_repo.get_by_id(orphan.id)
+
+    op = await service.collect(dry_run=False)
+    assert (op.affected_count, op.result["deleted_count"]) == (1, 1)
+    with pytest.raises(NotFoundError):
+        await relationship_repo.get_by_id(orphan.id)
+    await relationship_repo.get_by_id(kept.id)
@@ -0,0 +1,60 @@
+"""
+Tests for query result caching.
+"""
+
+import pytest
+
+from app.repository import TombstoneRepository
+from app.service.limits import TenantLimits
+from app.service.query_cache import CacheEntry, QueryCache, QueryCacheService, normalize_params
+
+
+def test_normalize_params():
+    """Test that parameter order and unset parameters do not change the cache key."""
+    assert normalize_params({"a": 1, "b": "", "c": None}) == normalize_params({"a": 1})
+    assert normalize_params({"a": 1, "b": 2}) == normalize_params({"b": 2, "a": 1})
+    assert normalize_params({"a": 0}) != normalize_params({})
+
+
+@pytest.mark.asyncio
+async def test_query_cache_invalidated_by_writes(tenant_db, node_service, nodetype_service):
+    """Test that cached results are served until the tenant's data changes."""
+    node_type = await nodetype_service.create("Task", "", '{}')
+    cache = QueryCacheService(TombstoneRepository(tenant_db), "tenant", TenantLimits(query_cache_seconds=60), QueryCache())
+    loads = []
+
+    async def load():
+        nodes, _ = await node_service.list(node_type.id, 10, "")
+        loads.append(len(nodes))
+        return len(nodes)
+
+    params = {"node_type_id": node_type.id}
+    assert await cache.get_or_load("list_nodes", params, load) == 0
+    assert await cache.get_or_load("list_nodes", params, load) == 0
+    assert loads == [0]
+
+    node = await node_service.create(node_type.id, '{}')
+    assert await cache.get_or_load("list_nodes", params, load) == 1
+    await node_service.delete(node.id)
+    assert await cache.get_or_load("list_nodes", params, load) == 0
+    assert loads == [0, 1, 0]
+
+    # Caching is off unless the tenant enables it
+    disabled = QueryCacheService(TombstoneRepository(tenant_db), "tenant", TenantLimits(), QueryCache())
+    await disabled.get_or_load("list_nodes", params, load)
+    await disabled.get_or_load("list_nodes", params, load)
+    assert loads == [0, 1, 0, 0, 0]
+
+
+def test_query_cache_evicts_least_recently_used():
+    """Test that the cache keeps at most max_entries, dropping the least recently used."""
+    cache = QueryCache(max_entries=2)
+    far = float("inf")
+    cache.put(("t", "m", "a"), CacheEntry("a", "1", far))
+    cache.put(("t", "m", "b"), CacheEntry("b", "1", far))
+    assert cache.get(("t", "m", "a")).value == "a"
+    cache.put(("t", "m", "c"), CacheEntry("c", "1", far))
+    assert cache.get(("t", "m", "b")) is None
+    assert len(cache) == 2
+    cache.put(("t", "m", "d"), CacheEntry("d", "1", 0.0))
+    assert cache.get(("t", "m", "d")) is None
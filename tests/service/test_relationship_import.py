@@ -0,0 +1,96 @@
+"""
+Tests for RelationshipImportService.
+"""
+
+import json
+
+import pytest
+
+
+async def _chunks(rows, size=64):
+    body = "\n".join(rows).encode()
+    for i in range(0, len(body), size):
+        yield body[i:i + size]
+
+
+@pytest.mark.asyncio
+async def test_relationship_import(
+    relationship_import_service, relationship_service, relationship_type_service, nodetype_service, node_service
+):
+    """Test that valid rows are imported in batches and failed rows are reported."""
+    person = await nodetype_service.create("Person", "", '{}')
+    company = await nodetype_service.create("Company", "", '{}')
+    ada = await node_service.create(person.id, '{"name": "Ada"}')
+    alan = await node_service.create(person.id, '{"name": "Alan"}')
+    acme = await node_service.create(company.id, '{"name": "Acme"}')
+    await relationship_type_service.create("WORKS_AT", "", "directed", [person.id], [company.id])
+
+    def row(source, target, rel_type, data=None):
+        return json.dumps(
+            {"source_node_id": source, "target_node_id": target, "relationship_type": rel_type, "data": data or {}}
+        )
+
+    rows = [
+        row(ada.id, alan.id, "KNOWS", {"since": 2020}),
+        "{not json",
+        row(ada.id, acme.id, "WORKS_AT"),
+        "",
+        row(ada.id, "00000000-0000-0000-0000-000000000000", "KNOWS"),
+        row(acme.id, ada.id, "WORKS_AT"),
+        row(alan.id, acme.id, "WORKS_AT", '{"role": "engineer"}'),
+    ]
+
+    op = await relationship_import_service.begin(batch_size=2)
+    op, failures = await relationship_import_service.ingest(op.id, _chunks(rows))
+
+    assert op.status == "completed"
+    assert op.affected_count == 3
+    assert op.failed_count == 3
+    assert [f.line for f in failures] == [2, 5, 6]
+    assert "not allow" in failures[2].error
+
+    rels, _ = await relationship_service.list(ada.id, None, None, 0, "")
+    assert sorted(r.relationship_type for r in rels) == ["KNOWS", "WORKS_AT"]
+
+    # An import can only be uploaded once
+    with pytest.raises(ValueError):
+        await relationship_import_service.ingest(op.id, _chunks(rows))
+    with pytest.raises(ValueError):
+        await relationship_import_service.begin(batch_size=100000)
+
+
+@pytest.mark.asyncio
+async def test_relationship_import_csv_aliases(
+    relationship_import_service, relationship_service, nodetype_service, node_service, node_alias_service
+):
+    """Test that CSV rows resolve endpoints by alias and report unresolved references."""
+    person = await nodetype_service.create("Person", "", '{}')
+    ada = await node_service.create(person.id, '{"name": "Ada"}')
+    alan = await node_service.create(person.id, '{"name": "Alan"}')
+    await node_alias_service.set(ada.id, {"email": "ada@example.com"})
+    await node_alias_service.set(alan.id, {"email": "alan@example.com"})
+
+    rows = [
+        "source_alias.email,target_alias.email,target_node_id,relationship_type,data",
+        'ada@example.com,alan@example.com,,KNOWS,"{""since"": 2020}"',
+        "ada@example.com,grace@example.com,,KNOWS,",
+        f"alan@example.com,,{ada.id},KNOWS,",
+        f"alan@example.com,alan@example.com,{ada.id},KNOWS,",
+    ]
+    op = await relationship_import_service.begin(format="csv")
+    op, failures = await relationship_import_service.ingest(op.id, _chunks(rows, size=16))
+
+    assert op.status == "completed"
+    assert op.affected_count == 2
+    assert [(f.line, f.reference) for f in failures] == [(3, "target_alias.email=grace@example.com"), (5, "")]
+
+    rels, _ = await relationship_service.list(None, None, "KNOWS", 0, "")
+    assert sorted((r.source_node_id, r.target_node_id) for r in rels) == sorted([(ada.id, alan.id), (alan.id, ada.id)])
+    assert json.loads([r for r in rels if r.source_node_id == ada.id][0].data) == {"since": 2020}
+
+    # An unknown column fails the import
+    op = await relationship_import_service.begin(format="csv")
+    op, _ = await relationship_import_service.ingest(op.id, _chunks(["source,target_node_id", "a,b"]))
+    assert op.status == "failed"
+    with pytest.raises(ValueError):
+        await relationship_import_service.begin(format="xml")
@@ -2,9 +2,11 @@
 Tests for RelationshipService.
 """
 
+import uuid
+
 import pytest
 
-from app.repository.errors import NotFoundError
+from app.repository.errors import AlreadyExistsError, DuplicateRelationshipError, NotFoundError
 
 
 @pytest.mark.asyncio
@@ -30,6 +32,50 @@ async def test_create_relationship(relationship_service, node_service, nodetype_
     assert rel.data == rel_data
 
 
+@pytest.mark.asyncio
+async def test_create_relationship_with_client_id(relationship_service, node_service, nodetype_service):
+    """Test that retrying a create with the same client-generated ID fails instead of duplicating."""
+    node_type = await nodetype_service.create("Article", "Blog article", '{}')
+    source_node = await node_service.create(node_type.id, '{}')
+    target_node = await node_service.create(node_type.id, '{}')
+    id = str(uuid.uuid4())
+
+    rel = await relationship_service.create(source_node.id, target_node.id, "references", '{}', id=id)
+    assert rel.id == id
+    with pytest.raises(AlreadyExistsError):
+        await relationship_service.create(source_node.id, target_node.id, "references", '{}', id=id)
+
+
+@pytest.mark.asyncio
+async def test_create_relationship_duplicates(
+    relationship_service, relationship_type_service, node_service, nodetype_service
+):
+    """Test that the type's on_duplicate rule, or the call's, rejects or returns identical relationships."""
+    node_type = await nodetype_service.create("Person", "", '{}')
+    a = await node_service.create(node_type.id, '{}')
+    b = await node_service.create(node_type.id, '{}')
+    await relationship_type_service.create("knows", "", "undirected", None, None, on_duplicate="reject")
+
+    rel = await relationship_service.create(a.id, b.id, "knows", '{}')
+    with pytest.raises(DuplicateRelationshipError) as exc:
+        await relationship_service.create(b.id, a.id, "knows", '{}')
+    assert exc.value.existing_id == rel.id
+
+    existing, created = await relationship_service.create_or_get(
+        a.id, b.id, "knows", '{}', on_duplicate="return_existing"
+    )
+    assert (existing.id, created) == (rel.id, False)
+
+    # Unregistered types allow duplicates unless the call says otherwise
+    first = await relationship_service.create(a.id, b.id, "references", '{}')
+    second = await relationship_service.create(a.id, b.id, "references", '{}')
+    assert first.id != second.id
+    again, created = await relationship_service.create_or_get(
+        b.id, a.id, "references", '{}', on_duplicate="return_existing"
+    )
+    assert created and again.id not in (first.id, second.id)
+
+
 @pytest.mark.asyncio
 async def test_create_relationship_missing_source(relationship_service):
     """Test creating a relationship without source_node_id raises ValueError."""
@@ -158,3 +204,73 @@ async def test_list_relationships_filtered(relationship_service, node_service, n
     assert len(rels) == 1
     assert rels[0].relationship_type == "references"
 
+
+@pytest.mark.asyncio
+async def test_list_relationships_dedupe(relationship_service, node_service, nodetype_service):
+    """Test that dedupe lists parallel relationships once, with their count."""
+    node_type = await nodetype_service.create("Article", "Blog article", '{}')
+    a = await node_service.create(node_type.id, '{}')
+    b = await node_service.create(node_type.id, '{}')
+    first = await relationship_service.create(a.id, b.id, "references", '{}')
+    await relationship_service.create(a.id, b.id, "references", '{}')
+    await relationship_service.create(a.id, b.id, "references", '{}')
+    await relationship_service.create(a.id, b.id, "links_to", '{}')
+    await relationship_service.create(b.id, a.id, "references", '{}')
+
+    rels, result = await relationship_service.list(
+        None, None, None, page_size=10, page_token="", order_by="created_at asc", dedupe=True
+    )
+    assert result.total_count == 3
+    assert [(r.relationship_type, r.parallel_count) for r in rels] == [
+        ("references", 3), ("links_to", 1), ("references", 1)
+    ]
+    # The oldest relationship of a set stands for it
+    assert rels[0].id == first.id
+    assert rels[0].to_dict()["parallel_count"] == 3
+
+    rels, _ = await relationship_service.list(None, None, None, page_size=10, page_token="")
+    assert len(rels) == 5
+    assert "parallel_count" not in rels[0].to_dict()
+
+
+@pytest.mark.asyncio
+async def test_distinct_relationship_types(relationship_service, node_service, nodetype_service):
+    """Test counting relationships per relationship type."""
+    node_type = await nodetype_service.create("Article", "Blog article", '{}')
+    a = await node_service.create(node_type.id, '{}')
+    b = await node_service.create(node_type.id, '{}')
+    await relationship_service.create(a.id, b.id, "references", '{}')
+    await relationship_service.create(b.id, a.id, "references", '{}')
+    await relationship_service.create(a.id, b.id, "mentions", '{}')
+
+    values = await relationship_service.distinct_values("relationship_type", None, 10)
+
+    assert [(v.value, v.count) for v in values] == [("references", 2), ("mentions", 1)]
+
+
+@pytest.mark.asyncio
+async def test_relationship_validity(relationship_service, node_service, nodetype_service, expansion_service):
+    """Test listing and expanding relationships by validity interval."""
+    node_type = await nodetype_service.create("Employee", "", '{}')
+    a, old_manager, manager = [await node_service.create(node_type.id, '{}') for _ in range(3)]
+    await relationship_service.create(
+        a.id, old_manager.id, "REPORTS_TO", '{}',
+        valid_from="2020-01-01T00:00:00Z", valid_to="2024-01-01T00:00:00Z",
+    )
+    await relationship_service.create(a.id, manager.id, "REPORTS_TO", '{}', valid_from="2024-01-01T00:00:00Z")
+
+    rels, _ = await relationship_service.list(a.id, None, None, page_size=10, page_token="")
+    assert len(rels) == 2
+    rels, _ = await relationship_service.list(
+        a.id, None, None, page_size=10, page_token="", valid_at="2023-06-30T00:00:00Z"
+    )
+    assert [r.target_node_id for r in rels] == [old_manager.id]
+
+    expanded = await expansion_service.expand([a], expansion_service.parse(["neighbors(type=REPORTS_TO)"]))
+    assert [n["node"]["id"] for n in expanded[a.id]["neighbors:REPORTS_TO"]] == [manager.id]
+
+    with pytest.raises(ValueError, match="valid_to must be after valid_from"):
+        await relationship_service.create(
+            a.id, manager.id, "REPORTS_TO", '{}',
+            valid_from="2024-01-01T00:00:00Z", valid_to="2023-01-01T00:00:00Z",
+        )
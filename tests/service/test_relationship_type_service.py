@@ -0,0 +1,165 @@
+"""
+Tests for RelationshipTypeService.
+"""
+
+import json
+
+import pytest
+
+from app.repository.errors import NotFoundError
+
+
+@pytest.mark.asyncio
+async def test_create_relationship_type(relationship_type_service, nodetype_service):
+    """Test registering a relationship type with endpoint constraints."""
+    author = await nodetype_service.create("Author", "", '{}')
+    book = await nodetype_service.create("Book", "", '{}')
+
+    rel_type = await relationship_type_service.create(
+        "wrote", "Author wrote a book", "directed", [author.id], [book.id]
+    )
+
+    assert rel_type.id is not None
+    assert rel_type.name == "wrote"
+    assert rel_type.directionality == "directed"
+    assert rel_type.allowed_source_node_type_ids == [author.id]
+    assert rel_type.allowed_target_node_type_ids == [book.id]
+
+
+@pytest.mark.asyncio
+async def test_create_relationship_type_invalid_directionality(relationship_type_service):
+    """Test that an unknown directionality raises ValueError."""
+    with pytest.raises(ValueError, match="directionality must be one of"):
+        await relationship_type_service.create("wrote", "", "sideways", None, None)
+
+
+@pytest.mark.asyncio
+async def test_create_relationship_type_unknown_node_type(relationship_type_service):
+    """Test that referencing a missing node type raises NotFoundError."""
+    import uuid
+    with pytest.raises(NotFoundError):
+        await relationship_type_service.create("wrote", "", "directed", [str(uuid.uuid4())], None)
+
+
+@pytest.mark.asyncio
+async def test_relationship_creation_enforces_registered_type(
+    relationship_type_service, relationship_service, node_service, nodetype_service
+):
+    """Test that RelationshipService rejects connections a registered type disallows."""
+    author = await nodetype_service.create("Author", "", '{}')
+    book = await nodetype_service.create("Book", "", '{}')
+    await relationship_type_service.create("wrote", "", "directed", [author.id], [book.id])
+
+    a = await node_service.create(author.id, '{}')
+    b = await node_service.create(book.id, '{}')
+
+    rel = await relationship_service.create(a.id, b.id, "wrote", '{}')
+    assert rel.relationship_type == "wrote"
+
+    with pytest.raises(ValueError, match="does not allow"):
+        await relationship_service.create(b.id, a.id, "wrote", '{}')
+
+
+@pytest.mark.asyncio
+async def test_undirected_type_allows_both_orientations(
+    relationship_type_service, relationship_service, node_service, nodetype_service
+):
+    """Test that undirected types accept either endpoint order."""
+    person = await nodetype_service.create("Person", "", '{}')
+    company = await nodetype_service.create("Company", "", '{}')
+    await relationship_type_service.create("partner", "", "undirected", [person.id], [company.id])
+
+    p = await node_service.create(person.id, '{}')
+    c = await node_service.create(company.id, '{}')
+
+    rel = await relationship_service.create(c.id, p.id, "partner", '{}')
+    assert rel.relationship_type == "partner"
+
+
+@pytest.mark.asyncio
+async def test_discover_relationship_types(relationship_type_service, nodetype_service):
+    """Test discovery filtered by source node type."""
+    author = await nodetype_service.create("Author", "", '{}')
+    book = await nodetype_service.create("Book", "", '{}')
+    await relationship_type_service.create("wrote", "", "directed", [author.id], [book.id])
+    await relationship_type_service.create("cites", "", "directed", [book.id], [book.id])
+
+    options = await relationship_type_service.discover(author.id, None)
+
+    assert [o["relationship_type"]["name"] for o in options] == ["wrote"]
+    assert options[0]["target_node_type_ids"] == [book.id]
+
+
+@pytest.mark.asyncio
+async def test_delete_rules_cascade_and_restrict(
+    relationship_type_service, relationship_service, node_service, nodetype_service
+):
+    """Test delete_node cascades and restrict blocks only direct deletes."""
+    thing = await nodetype_service.create("Thing", "", '{}')
+    await relationship_type_service.create(
+        "owns", "", "directed", None, None,
+        on_source_delete="delete_node", on_target_delete="restrict"
+    )
+
+    owner = await node_service.create(thing.id, '{}')
+    owned = await node_service.create(thing.id, '{}')
+    grandchild = await node_service.create(thing.id, '{}')
+    await relationship_service.create(owner.id, owned.id, "owns", '{}')
+    await relationship_service.create(owned.id, grandchild.id, "owns", '{}')
+
+    with pytest.raises(ValueError, match="restricts deletion"):
+        await node_service.delete(owned.id)
+
+    deleted = await node_service.delete(owner.id)
+    assert deleted == [owner.id, owned.id, grandchild.id]
+
+
+@pytest.mark.asyncio
+async def test_invalid_delete_rule(relationship_type_service):
+    """Test that unknown delete actions are rejected."""
+    with pytest.raises(ValueError, match="on_source_delete must be one of"):
+        await relationship_type_service.create("owns", "", "directed", None, None, on_source_delete="nuke")
+
+
+@pytest.mark.asyncio
+async def test_derived_relationship_type(
+    relationship_type_service, relationship_service, node_service, nodetype_service
+):
+    """Test that derived relationships are computed and materialized from a path."""
+    person = await nodetype_service.create("Person", "", '{}')
+    company = await nodetype_service.create("Company", "", '{}')
+    ada, alan, grace = [await node_service.create(person.id, '{}') for _ in range(3)]
+    acme = await node_service.create(company.id, '{}')
+    await relationship_service.create(ada.id, acme.id, "EMPLOYED_BY", "{}")
+    await relationship_service.create(alan.id, acme.id, "EMPLOYED_BY", "{}")
+
+    path = [
+        {"relationship_type": "EMPLOYED_BY", "direction": "out"},
+        {"relationship_type": "EMPLOYED_BY", "direction": "in"},
+    ]
+    colleague = await relationship_type_service.create(
+        "COLLEAGUE", "", "undirected", None, None, derivation={"path": path}
+    )
+    rels, result = await relationship_service.list(ada.id, None, "COLLEAGUE", 10, "")
+    assert result.total_count == 1
+    assert rels[0].target_node_id == alan.id
+    assert json.loads(rels[0].data) == {"path_count": 1}
+
+    with pytest.raises(ValueError, match="is derived"):
+        await relationship_service.create(ada.id, grace.id, "COLLEAGUE", "{}")
+
+    # Materialized: read from the snapshot until refreshed
+    colleague = await relationship_type_service.update(
+        colleague.id, "", "", "", None, None, derivation={"path": path, "materialized": True}
+    )
+    await relationship_service.create(grace.id, acme.id, "EMPLOYED_BY", "{}")
+    _, result = await relationship_service.list(None, None, "COLLEAGUE", 10, "")
+    assert result.total_count == 2
+    _, count = await relationship_type_service.refresh_derived(colleague.id)
+    assert count == 6
+
+    with pytest.raises(ValueError, match="itself derived"):
+        await relationship_type_service.create(
+            "NETWORK", "", "directed", None, None,
+            derivation={"path": [{"relationship_type": "COLLEAGUE"}, {"relationship_type": "COLLEAGUE"}]},
+        )
@@ -0,0 +1,57 @@
+"""
+Tests for saved queries.
+"""
+
+import pytest
+
+from app.repository import AlreadyExistsError, NotFoundError, SavedQueryRepository
+from app.service.saved_query_service import SavedQueryService, placeholders, substitute
+
+
+def test_placeholders_substituted_with_their_types():
+    """Test that "$name" values anywhere in the parameters are replaced by arguments."""
+    params = {"node_type_id": "$type", "metadata": {"tags": ["$tag", "fixed"]}, "depth": "$depth", "price": "$5"}
+    assert placeholders(params) == {"type", "tag", "depth"}
+    assert substitute(params, {"type": "t1", "tag": "vip", "depth": 2}) == {
+        "node_type_id": "t1", "metadata": {"tags": ["vip", "fixed"]}, "depth": 2, "price": "$5",
+    }
+
+
+@pytest.mark.asyncio
+async def test_saved_queries(tenant_db):
+    """Test saving, resolving and counting runs of saved queries."""
+    service = SavedQueryService(SavedQueryRepository(tenant_db))
+    query = await service.create(
+        "by-source", "list_nodes",
+        {"node_type_id": "$type", "metadata": {"source": "$source"}},
+        {"type": None, "source": "crm"},
+    )
+    with pytest.raises(AlreadyExistsError):
+        await service.create("by-source", "list_nodes")
+    with pytest.raises(ValueError, match="declared"):
+        await service.create("other", "list_nodes", {"node_type_id": "$type"})
+    with pytest.raises(ValueError, match="method"):
+        await service.create("other", "delete_node")
+    with pytest.raises(ValueError, match="tenant_id"):
+        await service.create("other", "list_nodes", {"tenant_id": "t2"})
+
+    _, params = await service.resolve("by-source", {"type": "t1"})
+    assert params == {"node_type_id": "t1", "metadata": {"source": "crm"}}
+    with pytest.raises(ValueError, match="missing parameters"):
+        await service.resolve("by-source", {})
+    with pytest.raises(ValueError, match="unknown parameters"):
+        await service.resolve("by-source", {"type": "t1", "limit": 5})
+    with pytest.raises(NotFoundError):
+        await service.resolve("nope", {})
+
+    await service.record_run(query.id, 0.25)
+    await service.record_run(query.id, 0.75)
+    query = await service.update(query.id, description="CRM nodes")
+    assert (query.run_count, query.to_dict()["avg_run_ms"]) == (2, 500.0)
+    assert query.description == "CRM nodes"
+
+    queries, result = await service.list(0, "")
+    assert [q.name for q in queries] == ["by-source"]
+    await service.delete(query.id)
+    with pytest.raises(NotFoundError):
+        await service.get_by_id(query.id)
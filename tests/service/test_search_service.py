@@ -0,0 +1,81 @@
+"""
+Tests for full-text node search and the search indexer.
+"""
+
+import uuid
+
+import pytest
+
+from app.repository import SearchCursorRepository
+from app.search import MemorySearchIndex
+from app.service import SearchIndexer, SearchService
+
+
+@pytest.mark.asyncio
+async def test_search_nodes_postgres(node_repo, node_service, nodetype_service):
+    """Test that without a search index, node data is searched with PostgreSQL full-text search."""
+    article = await nodetype_service.create("Article", "", '{}')
+    match = await node_service.create(article.id, '{"title": "Graph databases", "tags": ["postgres"]}')
+    await node_service.create(article.id, '{"title": "Cooking"}')
+
+    search = SearchService(node_repo)
+    results, result = await search.search("graph postgres", "", 10, "")
+    assert [node.id for node, _ in results] == [match.id]
+    assert result.total_count == 1
+    with pytest.raises(ValueError):
+        await search.search("  ", "", 10, "")
+
+
+@pytest.mark.asyncio
+async def test_search_indexer_follows_changes(tenant_service, template_service, clean_control_db):
+    """Test that the indexer mirrors creates, updates and deletes, and searches use the index."""
+    tenant = await tenant_service.create(f"search-{uuid.uuid4().hex[:8]}", "Search")
+    services = await template_service.tenant_services(tenant.id)
+    index = MemorySearchIndex()
+    indexer = SearchIndexer(None, SearchCursorRepository(clean_control_db), template_service.tenant_services, index)
+
+    article = await services["node_type"].create("Article", "", '{}')
+    first = await services["node"].create(article.id, '{"title": "Red apples"}')
+    second = await services["node"].create(article.id, '{"title": "Green apples"}')
+    assert await indexer.sync_tenant(tenant.id) == 2
+
+    await services["node"].update(first.id, '{"title": "Red pears"}')
+    await services["node"].delete(second.id)
+    await indexer.sync_tenant(tenant.id)
+
+    search = SearchService(services["node"].repo, tenant.id, index=index)
+    results, _ = await search.search("apples", "", 10, "")
+    assert results == []
+    results, result = await search.search("red", article.id, 10, "")
+    assert [node.id for node, _ in results] == [first.id]
+    assert result.total_count == 1
+
+
+@pytest.mark.asyncio
+async def test_search_with_consistency_token(tenant_service, template_service, clean_control_db):
+    """Test that searches with a consistency token use PostgreSQL until the index includes the write."""
+    tenant = await tenant_service.create(f"search-{uuid.uuid4().hex[:8]}", "Search")
+    services = await template_service.tenant_services(tenant.id)
+    index = MemorySearchIndex()
+    cursor_repo = SearchCursorRepository(clean_control_db)
+    indexer = SearchIndexer(None, cursor_repo, template_service.tenant_services, index)
+    search = SearchService(services["node"].repo, tenant.id, index=index, cursor_repo=cursor_repo)
+
+    article = await services["node_type"].create("Article", "", '{}')
+    await indexer.sync_tenant(tenant.id)
+    node = await services["node"].create(article.id, '{"title": "Blue apples"}')
+    token = await services["export"].tombstone_repo.commit_position()
+
+    # Not indexed yet
+    results, _ = await search.search("apples", "", 10, "")
+    assert results == []
+    results, _ = await search.search("apples", "", 10, "", token)
+    assert [n.id for n, _ in results] == [node.id]
+    assert not await search.index_covers(token)
+
+    await indexer.sync_tenant(tenant.id)
+    assert await search.index_covers(token)
+    results, _ = await search.search("apples", "", 10, "", token)
+    assert [n.id for n, _ in results] == [node.id]
+    with pytest.raises(ValueError):
+        await search.search("apples", "", 10, "", "not-a-token")
@@ -0,0 +1,28 @@
+"""
+Tests for StatsService.
+"""
+
+import uuid
+
+import pytest
+
+
+@pytest.mark.asyncio
+async def test_system_and_tenant_stats(stats_service, tenant_service):
+    """Test that tenant databases appear in system stats with their table statistics."""
+    tenant = await tenant_service.create(f"stats-{uuid.uuid4().hex[:8]}", "Stats Tenant")
+
+    stats = await stats_service.system_stats(slow_query_limit=5)
+    assert [t["tenant_id"] for t in stats["tenants"]] == [tenant.id]
+    assert stats["tenants"][0]["size_bytes"] > 0
+    assert stats["control_database"]["size_bytes"] > 0
+    database_name = stats["tenants"][0]["database_name"]
+    assert len(stats["slow_queries"]) <= 5
+
+    stats = await stats_service.tenant_stats(tenant.id)
+    assert stats["database"]["database_name"] == database_name
+    assert "nodes" in stats["row_counts"]
+    assert any(i["table"] == "nodes" for i in stats["indexes"])
+
+    with pytest.raises(ValueError, match="slow_query_limit"):
+        await stats_service.tenant_stats(tenant.id, slow_query_limit=1000)
@@ -0,0 +1,40 @@
+"""
+Tests for SubgraphService.
+"""
+
+import pytest
+
+from app.repository.errors import NotFoundError
+
+
+@pytest.mark.asyncio
+async def test_subgraph_depth_and_filters(subgraph_service, node_service, nodetype_service, relationship_service):
+    """Test that subgraphs follow the selected types to a depth and include every relationship between their nodes."""
+    person = await nodetype_service.create("Person", "", '{}')
+    company = await nodetype_service.create("Company", "", '{}')
+    a, b, c, d = [await node_service.create(person.id, '{}') for _ in range(4)]
+    acme = await node_service.create(company.id, '{}')
+    ab = await relationship_service.create(a.id, b.id, "knows", '{}')
+    bc = await relationship_service.create(b.id, c.id, "knows", '{}')
+    await relationship_service.create(c.id, d.id, "knows", '{}')
+    ca = await relationship_service.create(c.id, a.id, "likes", '{}')
+    await relationship_service.create(b.id, acme.id, "works_at", '{}')
+
+    subgraph = await subgraph_service.extract([a.id], depth=2, relationship_types=["knows"])
+    assert subgraph.depths == {a.id: 0, b.id: 1, c.id: 2}
+    assert sorted(r.id for r in subgraph.relationships) == sorted([ab.id, bc.id])
+    assert not subgraph.truncated
+
+    # The induced subgraph includes relationships that were not followed
+    subgraph = await subgraph_service.extract([a.id], depth=2, node_type_ids=[person.id], direction="out")
+    assert set(subgraph.depths) == {a.id, b.id, c.id}
+    assert ca.id in [r.id for r in subgraph.relationships]
+
+    subgraph = await subgraph_service.extract([a.id], depth=3, max_nodes=2)
+    assert len(subgraph.nodes) == 2
+    assert subgraph.truncated
+
+    with pytest.raises(NotFoundError):
+        await subgraph_service.extract(["00000000-0000-0000-0000-000000000000"])
+    with pytest.raises(ValueError, match="direction"):
+        await subgraph_service.extract([a.id], direction="sideways")
@@ -0,0 +1,74 @@
+"""
+Tests for SyncService.
+"""
+
+import json
+import uuid
+
+import pytest
+
+from app.service import SyncService
+from app.service.sync_service import merge_fields
+
+
+@pytest.fixture
+def sync_service(node_service, relationship_service) -> SyncService:
+    return SyncService(node_service, relationship_service)
+
+
+def test_merge_fields():
+    """Test that only fields changed on both sides conflict, and how policies keep them."""
+    server = {"title": "Server", "status": "open", "owner": "bob"}
+    client = {"title": "Client", "status": "done", "owner": "bob"}
+    base = {"title": "Base", "status": "open", "owner": "alice"}
+
+    patch, conflicts = merge_fields(server, client, base, "server_wins")
+    assert patch == {"status": "done"}
+    assert [(c.field, c.base, c.server, c.client, c.kept) for c in conflicts] == [
+        ("title", "Base", "Server", "Client", "server")
+    ]
+
+    patch, conflicts = merge_fields(server, client, base, "client_wins")
+    assert patch == {"title": "Client", "status": "done"}
+    assert conflicts[0].kept == "client"
+
+    patch, conflicts = merge_fields(server, {"status": "done"}, None, "reject")
+    assert patch == {} and conflicts[0].field == "status"
+
+
+@pytest.mark.asyncio
+async def test_push_changes(sync_service, node_service, test_node_type):
+    """Test pushing offline creates, merged updates, conflicts and deletes."""
+    node = await node_service.create(test_node_type["id"], '{"title": "Visit", "status": "open"}')
+    base_etag = node.etag
+    await node_service.update(node.id, '{"title": "Site visit", "status": "open"}')
+    new_id = str(uuid.uuid4())
+
+    changes = [
+        {"entity": "node", "action": "create", "id": new_id, "node_type_id": test_node_type["id"], "data": {"n": 1}},
+        # Only the server changed title: status merges
+        {"entity": "node", "action": "update", "id": node.id, "base_etag": base_etag,
+         "data": {"status": "done"}, "base_data": {"status": "open"}},
+        # The server changed title too: conflict
+        {"entity": "node", "action": "update", "id": node.id, "base_etag": base_etag,
+         "data": {"title": "Visit 2"}, "base_data": {"title": "Visit"}},
+        {"entity": "node", "action": "delete", "id": str(uuid.uuid4())},
+        {"entity": "edge", "action": "create", "id": new_id},
+    ]
+    results = await sync_service.push(changes)
+    assert [r.status for r in results] == ["applied", "applied", "conflict", "unchanged", "failed"]
+    assert results[2].conflicts[0].server == "Site visit"
+    assert json.loads((await node_service.get_by_id(node.id)).data) == {"title": "Site visit", "status": "done"}
+
+    # Pushing the create again is harmless
+    assert (await sync_service.push(changes[:1]))[0].status == "unchanged"
+
+    results = await sync_service.push(changes[2:3], "client_wins")
+    assert results[0].status == "applied"
+    assert json.loads((await node_service.get_by_id(node.id)).data)["title"] == "Visit 2"
+
+    results = await sync_service.push([{"entity": "node", "action": "delete", "id": node.id, "base_etag": base_etag}])
+    assert results[0].status == "conflict"
+
+    with pytest.raises(ValueError, match="conflict_policy"):
+        await sync_service.push(changes, "newest")
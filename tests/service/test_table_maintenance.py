@@ -0,0 +1,31 @@
+"""
+Tests for TableMaintenanceService.
+"""
+
+import uuid
+
+import pytest
+
+from app.service import TableMaintenanceService
+
+
+@pytest.mark.asyncio
+async def test_bloat_report_and_maintenance(tenant_repo, tenant_db_manager, tenant_service):
+    """Test that the bloat report lists tenant tables and that maintenance vacuums named and bloated tables."""
+    tenant = await tenant_service.create(f"vacuum-{uuid.uuid4().hex[:8]}", "Vacuum Tenant")
+    service = TableMaintenanceService(tenant_repo, tenant_db_manager, dead_ratio=0.0, min_dead_rows=0)
+
+    report = await service.report(tenant.id)
+    nodes = next(t for t in report["tables"] if t["table"] == "nodes")
+    assert nodes["partitioned"] is False
+    assert nodes["estimated_bloat_bytes"] >= 0
+
+    result = await service.run(tenant.id, ["nodes", "relationships"])
+    assert [(a["table"], a["action"]) for a in result["actions"]] == [("nodes", "vacuum"), ("relationships", "vacuum")]
+    result = await service.run(tenant.id, ["nodes"], analyze_only=True)
+    assert result["actions"][0]["action"] == "analyze"
+    with pytest.raises(ValueError, match="unknown tables"):
+        await service.run(tenant.id, ["nodes; DROP TABLE nodes"])
+
+    strict = TableMaintenanceService(tenant_repo, tenant_db_manager, dead_ratio=1.0, min_dead_rows=10**9)
+    assert (await strict.run(tenant.id))["actions"] == []
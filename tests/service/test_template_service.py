@@ -0,0 +1,65 @@
+"""
+Tests for TemplateService and creating tenants from templates.
+"""
+
+import uuid
+
+import pytest
+
+DEFINITION = {
+    "node_types": [{"name": "Person"}, {"name": "Company", "schema": {"type": "object"}}],
+    "relationship_types": [
+        {"name": "WORKS_AT", "source_node_types": ["Person"], "target_node_types": ["Company"]},
+    ],
+    "nodes": [
+        {"ref": "acme", "node_type": "Company", "data": {"name": "Acme"}},
+        {"ref": "alice", "node_type": "Person", "data": {"name": "Alice"}},
+    ],
+    "relationships": [{"source": "alice", "target": "acme", "relationship_type": "WORKS_AT"}],
+}
+
+
+@pytest.mark.asyncio
+async def test_create_template_validates_definition(template_service):
+    """Test that definitions referencing undefined node types or nodes are rejected."""
+    with pytest.raises(ValueError, match="node type 'Robot' is not defined"):
+        await template_service.create("bad", {
+            "node_types": [{"name": "Person"}],
+            "relationship_types": [{"name": "OWNS", "source_node_types": ["Robot"]}],
+        })
+    with pytest.raises(ValueError, match="relationships\\[0\\].target"):
+        await template_service.create("bad", {
+            "node_types": [{"name": "Person"}],
+            "nodes": [{"ref": "a", "node_type": "Person"}],
+            "relationships": [{"source": "a", "target": "b", "relationship_type": "KNOWS"}],
+        })
+
+    template = await template_service.create("crm", DEFINITION)
+    with pytest.raises(ValueError, match="already exists"):
+        await template_service.create("crm", DEFINITION)
+    assert (await template_service.get_by_id(template.id)).definition["nodes"] == DEFINITION["nodes"]
+
+
+@pytest.mark.asyncio
+async def test_create_tenant_from_template(tenant_service, template_service):
+    """Test that a tenant created from a template starts with its types and seed data."""
+    await template_service.create("crm", DEFINITION)
+    tenant = await tenant_service.create(f"acme-{uuid.uuid4().hex[:8]}", "Acme", from_template="crm")
+
+    services = await template_service.tenant_services(tenant.id)
+    node_types, _ = await services["node_type"].list(0, "")
+    assert sorted(t.name for t in node_types) == ["Company", "Person"]
+    rels, _ = await services["relationship"].list(None, None, "WORKS_AT", 0, "")
+    assert len(rels) == 1
+
+    with pytest.raises(ValueError, match="unknown tenant template"):
+        await tenant_service.create(f"other-{uuid.uuid4().hex[:8]}", "Other", from_template="missing")
+
+    # A template that cannot be applied leaves no tenant behind
+    broken = dict(DEFINITION, relationships=[{"source": "acme", "target": "alice", "relationship_type": "WORKS_AT"}])
+    await template_service.create("broken", broken)
+    slug = f"broken-{uuid.uuid4().hex[:8]}"
+    with pytest.raises(ValueError):
+        await tenant_service.create(slug, "Broken", from_template="broken")
+    tenants, _ = await tenant_service.list(0, "", slug_prefix=slug)
+    assert tenants == []
@@ -0,0 +1,69 @@
+"""
+Tests for TenantKeyService.
+"""
+
+import asyncio
+import base64
+import json
+import os
+import uuid
+
+import pytest
+
+from app.api.dependencies import create_tenant_services
+from app.repository.encryption import configure_encryption
+
+
+@pytest.fixture
+def master_key():
+    """Configure a random master key for the duration of a test."""
+    configure_encryption(base64.b64encode(os.urandom(32)).decode())
+    yield
+    configure_encryption("")
+
+
+async def _finished(services, op):
+    for _ in range(100):
+        op = await services["operation"].get_by_id(op.id)
+        if op.done:
+            return op
+        await asyncio.sleep(0.05)
+    return op
+
+
+@pytest.mark.asyncio
+async def test_rotate_tenant_key(tenant_key_service, tenant_service, tenant_db_manager, master_key):
+    """Test encrypting a tenant's data with a first key, then rotating to a second one."""
+    tenant = await tenant_service.create(f"keys-{uuid.uuid4().hex[:8]}", "Keys")
+    tenant_db = await tenant_db_manager.get_tenant_db(tenant.id)
+    services = create_tenant_services(tenant_db, tenant.id)
+    node_type = await services["node_type"].create("Document", "", '{}')
+    node = await services["node"].create(node_type.id, '{"secret": "x"}')
+
+    key, op = await tenant_key_service.rotate(tenant.id)
+    assert (key.version, key.operation_id) == (1, op.id)
+    assert op.total_count == 2  # the node and its history version
+    op = await _finished(services, op)
+    assert (op.status, op.affected_count) == ("completed", 2)
+
+    async with tenant_db.pool.acquire() as conn:
+        stored, blob = await conn.fetchrow("SELECT data::text, data_compressed FROM nodes WHERE id = $1", node.id)
+    assert stored == "{}" and b"secret" not in blob
+
+    data_key = await tenant_key_service.active_key(tenant.id)
+    services = create_tenant_services(tenant_db, tenant.id, data_key=data_key)
+    assert json.loads((await services["node"].get_by_id(node.id)).data) == {"secret": "x"}
+
+    key, op = await tenant_key_service.rotate(tenant.id)
+    assert (await _finished(services, op)).status == "completed"
+    keys = await tenant_key_service.list(tenant.id)
+    assert [(k.version, k.status) for k in keys] == [(2, "active"), (1, "retired")]
+    assert json.loads((await services["node"].get_by_id(node.id)).data) == {"secret": "x"}
+
+
+@pytest.mark.asyncio
+async def test_rotate_requires_master_key(tenant_key_service, tenant_service):
+    """Test that rotation fails without a configured master key."""
+    tenant = await tenant_service.create(f"keys-{uuid.uuid4().hex[:8]}", "Keys")
+    with pytest.raises(ValueError, match="ENCRYPTION_MASTER_KEY"):
+        await tenant_key_service.rotate(tenant.id)
@@ -4,7 +4,8 @@ Tests for TenantService.
 
 import pytest
 
-from app.repository.errors import NotFoundError
+from app.repository.errors import NotFoundError, PreconditionFailedError, SlugTakenError
+from app.service.tenant_service import slug_candidates
 
 
 @pytest.mark.asyncio
@@ -101,12 +102,99 @@ async def test_delete_tenant(tenant_service):
     unique_slug = f"test-tenant-{uuid.uuid4().hex[:8]}"
     created = await tenant_service.create(unique_slug, "Test Tenant")
     
+    deleted = await tenant_service.delete(created.id)
+    assert deleted.status == "pending_deletion"
+    assert deleted.delete_after is not None
+
+    # Still recoverable during the grace period
+    restored = await tenant_service.undelete(created.id)
+    assert restored.status == "active"
+    assert restored.delete_after is None
+
+
+@pytest.mark.asyncio
+async def test_other_instances_stop_serving_deleted_tenant(tenant_service, test_config, clean_control_db, monkeypatch):
+    """Test that an instance with a cached pool stops serving a tenant another instance deleted."""
+    import uuid
+    from app.db import tenant_db_manager as manager_module
+    from app.db.tenant_db_manager import TenantDatabaseManager
+
+    created = await tenant_service.create(f"test-tenant-{uuid.uuid4().hex[:8]}", "Test Tenant")
+    other = TenantDatabaseManager(test_config, clean_control_db)
+    try:
+        await other.get_tenant_db(created.id)
+        await tenant_service.delete(created.id)
+
+        monkeypatch.setattr(manager_module, "POOL_RECHECK_SECONDS", 0.0)
+        with pytest.raises(ValueError, match="pending deletion"):
+            await other.get_tenant_db(created.id)
+    finally:
+        await other.close_all_pools()
+
+
+@pytest.mark.asyncio
+async def test_protected_tenant_is_only_deleted_with_force(tenant_service):
+    """Test that deleting a protected tenant takes force, and unprotected tenants delete as before."""
+    import uuid
+    created = await tenant_service.create(f"test-tenant-{uuid.uuid4().hex[:8]}", "Test Tenant")
+    protected = await tenant_service.set_protected(created.id, True)
+    assert protected.to_dict()["protected"] is True
+
+    with pytest.raises(PreconditionFailedError, match="protected"):
+        await tenant_service.delete(created.id)
+    assert (await tenant_service.get_by_id(created.id)).status == "active"
+
+    deleted = await tenant_service.delete(created.id, force=True)
+    assert deleted.status == "pending_deletion"
+
+
+@pytest.mark.asyncio
+async def test_purge_deleted_tenant_after_grace_period(tenant_service):
+    """Test that the purger removes tenants only once their grace period ends."""
+    import uuid
+    from datetime import datetime, timedelta, timezone
+    created = await tenant_service.create(f"test-tenant-{uuid.uuid4().hex[:8]}", "Test Tenant")
     await tenant_service.delete(created.id)
-    
+
+    assert created.id not in await tenant_service.purge_expired()
+
+    later = datetime.now(timezone.utc) + timedelta(seconds=tenant_service.delete_grace_seconds + 1)
+    assert created.id in await tenant_service.purge_expired(now=later)
     with pytest.raises(NotFoundError):
         await tenant_service.get_by_id(created.id)
 
 
+@pytest.mark.asyncio
+async def test_undelete_active_tenant_rejected(tenant_service):
+    """Test that only tenants pending deletion can be undeleted."""
+    import uuid
+    created = await tenant_service.create(f"test-tenant-{uuid.uuid4().hex[:8]}", "Test Tenant")
+
+    with pytest.raises(ValueError, match="not pending deletion"):
+        await tenant_service.undelete(created.id)
+
+
+@pytest.mark.asyncio
+async def test_set_tenant_limits(tenant_service):
+    """Test overriding and resetting tenant limits."""
+    import uuid
+    created = await tenant_service.create(f"test-tenant-{uuid.uuid4().hex[:8]}", "Test Tenant")
+    assert (await tenant_service.get_limits(created.id)).max_page_size == 100
+
+    limits = await tenant_service.set_limits(created.id, {"max_page_size": 500, "default_page_size": 50})
+    assert limits.max_page_size == 500
+    assert limits.default_page_size == 50
+
+    limits = await tenant_service.set_limits(created.id, {"default_page_size": None})
+    assert limits.default_page_size == 10
+    assert (await tenant_service.get_limits(created.id)).max_page_size == 500
+
+    with pytest.raises(ValueError, match="between 1 and"):
+        await tenant_service.set_limits(created.id, {"max_page_size": 5000})
+    with pytest.raises(ValueError, match="unknown limits"):
+        await tenant_service.set_limits(created.id, {"page_size": 5})
+
+
 @pytest.mark.asyncio
 async def test_list_tenants(tenant_service):
     """Test listing tenants with pagination."""
@@ -138,3 +226,146 @@ async def test_list_tenants_pagination(tenant_service):
     assert result1.total_count == 15
     assert result1.next_page_token == "5"
 
+
+
+@pytest.mark.asyncio
+async def test_list_tenants_filtered(tenant_service):
+    """Test filtering tenants by status, slug prefix and name substring."""
+    import uuid
+    suffix = uuid.uuid4().hex[:8]
+    acme = await tenant_service.create(f"acme-{suffix}", "Acme Corporation")
+    await tenant_service.create(f"acme_labs-{suffix}", "Acme Labs")
+    await tenant_service.create(f"globex-{suffix}", "Globex")
+    await tenant_service.update(acme.id, "", "", "suspended")
+
+    tenants, result = await tenant_service.list(10, "", slug_prefix="acme")
+    assert result.total_count == 2
+
+    # Wildcards in the prefix match literally
+    tenants, result = await tenant_service.list(10, "", slug_prefix="acme_")
+    assert [t.slug for t in tenants] == [f"acme_labs-{suffix}"]
+
+    tenants, result = await tenant_service.list(10, "", name_contains="CORP")
+    assert [t.id for t in tenants] == [acme.id]
+
+    tenants, result = await tenant_service.list(10, "", status="suspended")
+    assert [t.id for t in tenants] == [acme.id]
+
+    tenants, result = await tenant_service.list(10, "", created_after="2000-01-01", order_by="name")
+    assert result.total_count == 3
+
+
+@pytest.mark.asyncio
+async def test_tenant_annotations(tenant_service):
+    """Test merging annotations on update and filtering tenants by them."""
+    import uuid
+    suffix = uuid.uuid4().hex[:8]
+    gold = await tenant_service.create(f"gold-{suffix}", "Gold", {"tier": "gold", "team": suffix})
+    await tenant_service.create(f"free-{suffix}", "Free", {"tier": "free", "team": suffix})
+
+    updated = await tenant_service.update(gold.id, "", "", "", {"region": "eu", "team": None})
+    assert updated.annotations == {"tier": "gold", "region": "eu"}
+
+    tenants, result = await tenant_service.list(10, "", annotations={"tier": "free", "team": suffix})
+    assert result.total_count == 1
+    assert tenants[0].slug == f"free-{suffix}"
+
+    with pytest.raises(ValueError, match="invalid annotation key"):
+        await tenant_service.update(gold.id, "", "", "", {"-bad": "x"})
+    with pytest.raises(ValueError, match="must be a string"):
+        await tenant_service.update(gold.id, "", "", "", {"count": 3})
+
+
+@pytest.mark.asyncio
+async def test_list_tenants_invalid_created_range(tenant_service):
+    """Test that malformed timestamps are rejected."""
+    with pytest.raises(ValueError, match="created_after must be an ISO 8601 timestamp"):
+        await tenant_service.list(10, "", created_after="yesterday")
+
+
+@pytest.mark.asyncio
+async def test_tenant_hierarchy(tenant_service, template_service):
+    """Test that sub-tenants inherit limits, features and types from their organization."""
+    import uuid
+    org = await tenant_service.create(f"org-{uuid.uuid4().hex[:8]}", "Org")
+    org_services = await template_service.tenant_services(org.id)
+    await org_services["node_type"].create("Customer", "", "")
+
+    child = await tenant_service.create(f"child-{uuid.uuid4().hex[:8]}", "Child", parent_id=org.id)
+    assert child.parent_id == org.id
+    child_services = await template_service.tenant_services(child.id)
+    node_types, _ = await child_services["node_type"].list(0, "")
+    assert [t.name for t in node_types] == ["Customer"]
+
+    await tenant_service.set_limits(org.id, {"max_page_size": 500})
+    await tenant_service.set_limits(child.id, {"default_page_size": 50})
+    limits = await tenant_service.get_limits(child.id)
+    assert (limits.max_page_size, limits.default_page_size) == (500, 50)
+
+    await tenant_service.set_features(org.id, {"exports": True, "beta": True})
+    features, sources = await tenant_service.set_features(child.id, {"beta": False})
+    assert features == {"exports": True, "beta": False}
+    assert sources == {"exports": org.id, "beta": child.id}
+
+    await org_services["node_type"].create("Invoice", "", "")
+    results = await tenant_service.sync_schemas(org.id)
+    assert results == [{"tenant_id": child.id, "created": 1, "updated": 0}]
+
+    tenants, _ = await tenant_service.list(0, "", parent_id=org.id)
+    assert [t.id for t in tenants] == [child.id]
+
+    with pytest.raises(ValueError, match="under itself"):
+        await tenant_service.set_parent(org.id, child.id)
+    with pytest.raises(ValueError, match="sub-tenants"):
+        await tenant_service.delete(org.id)
+
+    moved = await tenant_service.set_parent(child.id, "")
+    assert moved.parent_id == ""
+    assert (await tenant_service.get_limits(child.id)).max_page_size == 100
+    await tenant_service.delete(org.id)
+
+
+@pytest.mark.asyncio
+async def test_tenant_maintenance(tenant_service):
+    """Test starting and ending maintenance mode."""
+    import uuid
+    tenant = await tenant_service.create(f"test-tenant-{uuid.uuid4().hex[:8]}", "Test Tenant")
+
+    with pytest.raises(ValueError, match="reason is required"):
+        await tenant_service.set_maintenance(tenant.id, True)
+
+    tenant = await tenant_service.set_maintenance(tenant.id, True, "restoring backup")
+    assert tenant.to_dict()["maintenance"]["reason"] == "restoring backup"
+    started_at = tenant.maintenance_started_at
+    tenant = await tenant_service.set_maintenance(tenant.id, True, "restore takes longer")
+    assert tenant.maintenance_started_at == started_at
+
+    tenant = await tenant_service.set_maintenance(tenant.id, False)
+    assert tenant.to_dict()["maintenance"] is None
+
+
+def test_slug_candidates():
+    """Test that taken slugs are varied from their base."""
+    assert slug_candidates("acme", 2025)[:3] == ["acme-2", "acme-2025", "acme-3"]
+    assert slug_candidates("acme-2", 2025)[:3] == ["acme-2025", "acme-3", "acme-4"]
+
+
+@pytest.mark.asyncio
+async def test_slug_suggestions(tenant_service):
+    """Test that a taken slug is reported with available alternatives."""
+    import uuid
+    slug = f"test-tenant-{uuid.uuid4().hex[:8]}"
+    await tenant_service.create(slug, "Test Tenant")
+    await tenant_service.create(f"{slug}-2", "Test Tenant")
+
+    assert await tenant_service.check_slug_availability(f"{slug}-3") == (True, [])
+    available, suggestions = await tenant_service.check_slug_availability(slug)
+    assert not available
+    assert f"{slug}-2" not in suggestions and f"{slug}-3" in suggestions
+
+    with pytest.raises(SlugTakenError) as e:
+        await tenant_service.create(slug, "Test Tenant")
+    assert e.value.suggestions == []
+    with pytest.raises(SlugTakenError) as e:
+        await tenant_service.create(slug, "Test Tenant", suggest_slugs=True)
+    assert e.value.suggestions == suggestions
@@ -0,0 +1,60 @@
+"""
+Tests for UniqueConstraintService.
+"""
+
+import asyncio
+
+import pytest
+
+from app.repository.errors import AlreadyExistsError, FieldViolationError
+
+
+@pytest.mark.asyncio
+async def test_unique_constraint(unique_constraint_service, nodetype_service, node_service):
+    """Test that a constrained data path rejects duplicate values, also under concurrency."""
+    person = await nodetype_service.create("Person", "", '{}')
+    company = await nodetype_service.create("Company", "", '{}')
+    ada = await node_service.create(person.id, '{"email": "ada@example.com"}')
+    await node_service.create(person.id, '{"name": "no email"}')
+
+    constraint = await unique_constraint_service.create(person.id, "data.email", case_insensitive=True)
+    assert constraint.status == "active"
+    assert constraint.to_dict()["path"] == "data.email"
+    assert [c.id for c in await unique_constraint_service.list(person.id)] == [constraint.id]
+
+    with pytest.raises(AlreadyExistsError):
+        await node_service.create(person.id, '{"email": "ADA@example.com"}')
+    alan = await node_service.create(person.id, '{"email": "alan@example.com"}')
+    with pytest.raises(AlreadyExistsError):
+        await node_service.update(alan.id, '{"email": "ada@example.com"}')
+    # Other node types and nodes without the path are not constrained
+    await node_service.create(company.id, '{"email": "ada@example.com"}')
+    await node_service.create(person.id, '{"name": "no email either"}')
+
+    results = await asyncio.gather(
+        *(node_service.create(person.id, '{"email": "grace@example.com"}') for _ in range(5)),
+        return_exceptions=True,
+    )
+    assert len([r for r in results if not isinstance(r, Exception)]) == 1
+    assert all(isinstance(r, AlreadyExistsError) for r in results if isinstance(r, Exception))
+
+    with pytest.raises(AlreadyExistsError):
+        await unique_constraint_service.create(person.id, "data.email")
+    with pytest.raises(FieldViolationError):
+        await unique_constraint_service.create(person.id, "email")
+
+    await unique_constraint_service.delete(constraint.id)
+    await node_service.create(person.id, '{"email": "ada@example.com"}')
+    assert ada.id
+
+
+@pytest.mark.asyncio
+async def test_unique_constraint_with_duplicates(unique_constraint_service, nodetype_service, node_service):
+    """Test that a constraint existing nodes violate is not created."""
+    person = await nodetype_service.create("Person", "", '{}')
+    await node_service.create(person.id, '{"email": "ada@example.com"}')
+    await node_service.create(person.id, '{"email": "ada@example.com"}')
+
+    with pytest.raises(ValueError, match="ada@example.com"):
+        await unique_constraint_service.create(person.id, "data.email")
+    assert await unique_constraint_service.list(person.id) == []
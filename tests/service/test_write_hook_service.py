@@ -0,0 +1,75 @@
+"""
+Tests for WriteHookService and write hook execution.
+"""
+
+import json
+
+import pytest
+
+
+@pytest.mark.asyncio
+async def test_create_write_hook(write_hook_service):
+    """Test registering a write hook."""
+    hook = await write_hook_service.create("require-title", "has(data.title)")
+
+    assert hook.id is not None
+    assert hook.phase == "pre_write"
+    assert hook.action == "validate"
+    assert hook.operations == ["create", "update"]
+
+
+@pytest.mark.asyncio
+async def test_create_write_hook_invalid_expression(write_hook_service):
+    """Test that an expression that does not compile is rejected."""
+    with pytest.raises(ValueError, match="invalid expression"):
+        await write_hook_service.create("broken", "data.title ==")
+
+
+@pytest.mark.asyncio
+async def test_create_write_hook_invalid_phase(write_hook_service):
+    """Test that an unknown phase is rejected."""
+    with pytest.raises(ValueError, match="phase must be one of"):
+        await write_hook_service.create("bad-phase", "true", phase="during_write")
+
+
+@pytest.mark.asyncio
+async def test_validate_hook_rejects_write(write_hook_service, node_service, nodetype_service):
+    """Test that a failing validate hook blocks node creation."""
+    node_type = await nodetype_service.create("Article", "", '{}')
+    await write_hook_service.create("require-title", "has(data.title)", node_type_id=node_type.id)
+
+    with pytest.raises(ValueError, match="rejected by hook require-title"):
+        await node_service.create(node_type.id, '{"body": "no title"}')
+
+    node = await node_service.create(node_type.id, '{"title": "ok"}')
+    assert json.loads(node.data) == {"title": "ok"}
+
+
+@pytest.mark.asyncio
+async def test_validate_hook_string_message(write_hook_service, node_service, nodetype_service):
+    """Test that a validate hook can return a rejection message."""
+    node_type = await nodetype_service.create("Order", "", '{}')
+    await write_hook_service.create(
+        "positive-total", 'data.total > 0 ? "" : "total must be positive"'
+    )
+
+    with pytest.raises(ValueError, match="total must be positive"):
+        await node_service.create(node_type.id, '{"total": 0}')
+
+
+@pytest.mark.asyncio
+async def test_enrich_hooks(write_hook_service, node_service, nodetype_service):
+    """Test pre- and post-write enrich hooks merge data into the node."""
+    node_type = await nodetype_service.create("Ticket", "", '{}')
+    await write_hook_service.create(
+        "default-status", '{"status": "open"}', action="enrich", operations=["create"]
+    )
+    await write_hook_service.create(
+        "self-link", '{"self": "/nodes/" + node.id}', phase="post_write", action="enrich"
+    )
+
+    node = await node_service.create(node_type.id, '{"title": "Broken"}')
+
+    data = json.loads(node.data)
+    assert data["status"] == "open"
+    assert data["self"] == f"/nodes/{node.id}"
@@ -0,0 +1,27 @@
+"""
+Tests for the flexdb library package.
+"""
+
+import pytest
+
+import flexdb
+from app.repository import NotFoundError
+
+
+@pytest.mark.asyncio
+async def test_open_memory():
+    """Test that an in-memory tenant serves the node and relationship APIs."""
+    tenant = flexdb.open_memory()
+    person = await tenant.node_types.create("Person", "", '{}')
+    alice = await tenant.nodes.create(person.id, '{"name": "Alice"}')
+    bob = await tenant.nodes.create(person.id, '{"name": "Bob"}')
+    await tenant.relationship_types.create("knows", "", "undirected", [person.id], [person.id])
+    knows = await tenant.relationships.create(alice.id, bob.id, "knows", '{}')
+
+    relationships, result = await tenant.relationships.list(alice.id, None, None, 10, "")
+    assert [r.id for r in relationships] == [knows.id]
+    assert result.total_count == 1
+
+    await tenant.nodes.delete(alice.id)
+    with pytest.raises(NotFoundError):
+        await tenant.relationships.get_by_id(knows.id)
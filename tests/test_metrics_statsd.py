@@ -0,0 +1,85 @@
+"""
+Tests for the StatsD metrics sink.
+"""
+
+import socket
+
+import pytest
+
+from app.metrics import MetricsRegistry
+from app.metrics_statsd import MAX_PACKET_BYTES, StatsdSink, parse_address
+
+
+def _agent():
+    sock = socket.socket(socket.AF_INET, socket.SOCK_DGRAM)
+    sock.bind(("127.0.0.1", 0))
+    sock.settimeout(2)
+    return sock, f"127.0.0.1:{sock.getsockname()[1]}"
+
+
+@pytest.mark.asyncio
+async def test_dogstatsd_sends_labels_as_tags():
+    """Test that registry metrics reach a DogStatsD agent with labels as tags."""
+    agent, address = _agent()
+    sink = StatsdSink(address, "dogstatsd", "flexdb", ["env:test"])
+    registry = MetricsRegistry()
+    registry.add_sink(sink)
+
+    registry.inc("rpc_calls_total", labels={"method": "get_node"})
+    registry.observe("rpc_seconds", 0.25)
+    await sink.flush()
+
+    lines = agent.recv(MAX_PACKET_BYTES).decode().split("\n")
+    assert lines == [
+        "flexdb.rpc_calls_total:1|c|#env:test,method:get_node",
+        "flexdb.rpc_seconds:0.25|h|#env:test",
+    ]
+    sink.close()
+    agent.close()
+
+
+@pytest.mark.asyncio
+async def test_statsd_appends_labels_to_names():
+    """Test that plain StatsD folds label values into metric names."""
+    agent, address = _agent()
+    sink = StatsdSink(address, "statsd", "")
+
+    sink.count("rpc_calls_total", 2, (("method", "get_node"), ("tenant", "a.b")))
+    sink.observe("rpc_seconds", 1.5, ())
+    await sink.flush()
+
+    assert agent.recv(MAX_PACKET_BYTES).decode() == (
+        "rpc_calls_total.method.get_node.tenant.a_b:2|c\nrpc_seconds:1.5|ms"
+    )
+    sink.close()
+    agent.close()
+
+
+def test_full_packets_are_sent_without_flush():
+    """Test that lines past the packet size are sent right away."""
+    agent, address = _agent()
+    sink = StatsdSink(address, "statsd", "")
+
+    for i in range(200):
+        sink.count(f"metric_{i}", 1, ())
+
+    packet = agent.recv(MAX_PACKET_BYTES * 2)
+    assert 0 < len(packet) <= MAX_PACKET_BYTES
+    assert packet.decode().startswith("metric_0:1|c\n")
+    sink.close()
+    agent.close()
+
+
+def test_parse_address():
+    """Test StatsD address parsing."""
+    assert parse_address("localhost") == ("localhost", 8125)
+    assert parse_address("agent:9125") == ("agent", 9125)
+    assert parse_address("[::1]:9125") == ("::1", 9125)
+    with pytest.raises(ValueError, match="invalid StatsD address"):
+        parse_address("agent:port")
+
+
+def test_unknown_format():
+    """Test that unknown formats are rejected."""
+    with pytest.raises(ValueError, match="format must be one of"):
+        StatsdSink("127.0.0.1:8125", "graphite")